@@ -0,0 +1,481 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"html"
+	"log"
+	"os"
+	"os/signal"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+	// embed the zone database so displayTimezone works in minimal images
+	_ "time/tzdata"
+
+	"github.com/guarzo/eve-chainkills/internal/attribution"
+	"github.com/guarzo/eve-chainkills/internal/audit"
+	"github.com/guarzo/eve-chainkills/internal/discord"
+	"github.com/guarzo/eve-chainkills/internal/killtags"
+	"github.com/guarzo/eve-chainkills/internal/logging"
+	"github.com/guarzo/eve-chainkills/internal/templates"
+	"github.com/guarzo/eve-chainkills/pkg/chainkills"
+	"github.com/guarzo/eve-chainkills/pkg/killmail"
+	"github.com/guarzo/eve-chainkills/pkg/logger/logruslogger"
+	"github.com/guarzo/eve-chainkills/pkg/notify"
+	"github.com/sirupsen/logrus"
+)
+
+func main() {
+	selfTestOnly := flag.Bool("self-test", false, "run the startup self-test and exit")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %[1]s [-self-test]\n       %[1]s why <killID>\n       %[1]s simulate [-since 24h]\n       %[1]s preview [-html preview.html] <killID>\n       %[1]s sso login|list\n       %[1]s leaderboard [-since 168h]\n       %[1]s tags [-tag name] [-since 168h]\n       %[1]s intel [-instance name] <system>\n       %[1]s import -since 2024-01-01 [-entity <id>] [-type corporationID]\n       %[1]s templates funcs\n       %[1]s bench replay -file kills.ndjson [-speed 0] [-enrich] [-cpuprofile cpu.out] [-memprofile mem.out]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.Arg(0) == "templates" {
+		templatesCommand(flag.Args()[1:])
+		return
+	}
+
+	// 1) Load configuration
+	cfg, err := chainkills.LoadConfig("config.json")
+	if err != nil {
+		log.Fatalf("Error loading config: %v", err)
+	}
+
+	if flag.Arg(0) == "why" {
+		why(cfg, flag.Args()[1:])
+		return
+	}
+	if flag.Arg(0) == "leaderboard" {
+		leaderboard(cfg, flag.Args()[1:])
+		return
+	}
+	if flag.Arg(0) == "tags" {
+		tagsCommand(cfg, flag.Args()[1:])
+		return
+	}
+	if flag.Arg(0) == "sso" {
+		ssoCommand(cfg, flag.Args()[1:])
+		return
+	}
+
+	// 2) Create logger (simple version using stdlib)
+	logger := logrus.New()
+
+	logger.SetFormatter(&logrus.TextFormatter{
+		ForceColors:   true,
+		FullTimestamp: false,
+	})
+
+	lvl, parseErr := logrus.ParseLevel(strings.ToLower(cfg.LogLevel))
+	if parseErr != nil {
+		logger.Warnf("Invalid log level '%s', defaulting to 'info'", cfg.LogLevel)
+		lvl = logrus.InfoLevel
+	}
+	logger.SetLevel(lvl)
+	if len(cfg.LogLevels) > 0 {
+		// component loggers filter for themselves, so let the most verbose one through
+		if levels, err := logging.ParseLevels(lvl.String(), cfg.LogLevels); err == nil {
+			logger.SetLevel(logrus.Level(levels.Max()))
+		}
+	}
+
+	// 3) Initialize and start the checkers for every instance
+	hub, err := chainkills.New(chainkills.WithLogger(logruslogger.New(logger)), chainkills.WithConfig(cfg))
+	if err != nil {
+		logger.Fatalf("Failed to create ChainKillChecker: %v\n", err)
+	}
+
+	// 4) Run until SIGINT/SIGTERM cancels the context
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if flag.Arg(0) == "simulate" {
+		simulate(ctx, hub, flag.Args()[1:])
+		return
+	}
+	if flag.Arg(0) == "preview" {
+		preview(ctx, hub, flag.Args()[1:])
+		return
+	}
+	if flag.Arg(0) == "import" {
+		importHistory(ctx, hub, flag.Args()[1:])
+		return
+	}
+	if flag.Arg(0) == "intel" {
+		intel(ctx, hub, flag.Args()[1:])
+		return
+	}
+	if flag.Arg(0) == "bench" {
+		bench(ctx, hub, flag.Args()[1:])
+		return
+	}
+
+	if *selfTestOnly {
+		if err := hub.SelfTest(ctx); err != nil {
+			logger.Fatalf("Self-test failed: %v", err)
+		}
+		logger.Println("Self-test passed.")
+		return
+	}
+
+	// Start the zKillboard WebSocket listener
+	if err := hub.Start(ctx); err != nil {
+		logger.Fatalf("Failed to start ChainKillChecker: %v", err)
+	}
+
+	logger.Println("Started chain kill checker.")
+
+	// 5) Wait for a signal, then give in-flight kills a moment to finish
+	<-ctx.Done()
+	logger.Println("Received shutdown signal, shutting down.")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := hub.Stop(shutdownCtx); err != nil {
+		logger.Printf("Error stopping: %v", err)
+	}
+}
+
+// why prints the audit log records for a kill
+func why(cfg *chainkills.Config, args []string) {
+	if len(args) != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	killID, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		log.Fatalf("Invalid kill ID %q", args[0])
+	}
+	if cfg.Audit.Path == "" {
+		log.Fatalf("The audit log is not enabled; set audit.path in config.json")
+	}
+	records, err := audit.Find(cfg.Audit.Path, killID)
+	if err != nil {
+		log.Fatalf("Error reading audit log: %v", err)
+	}
+	if len(records) == 0 {
+		fmt.Printf("No decisions recorded for kill %d; it was not received while the audit log was enabled.\n", killID)
+		return
+	}
+	for _, r := range records {
+		fmt.Println(r)
+	}
+	if cfg.Tags.Path != "" {
+		if store, err := killtags.Load(cfg.Tags.Path); err == nil && len(store.Names(killID)) > 0 {
+			fmt.Printf("Tags: %s\n", strings.Join(store.Names(killID), ", "))
+		}
+	}
+}
+
+// leaderboard prints per-pilot kill stats from the attribution records
+func leaderboard(cfg *chainkills.Config, args []string) {
+	fs := flag.NewFlagSet("leaderboard", flag.ExitOnError)
+	since := fs.Duration("since", 7*24*time.Hour, "how far back to count kills")
+	_ = fs.Parse(args)
+
+	if cfg.Attribution.Path == "" {
+		log.Fatalf("Attribution is not enabled; set attribution.path in config.json")
+	}
+	records, err := attribution.Read(cfg.Attribution.Path, time.Now().Add(-*since))
+	if err != nil {
+		log.Fatalf("Error reading attribution records: %v", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PILOT\tKILLS\tFINAL BLOWS\tDAMAGE\tISK\tLAST KILL")
+	for _, ps := range attribution.Leaderboard(records) {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\t%s\n", valueOr(ps.Name, strconv.FormatInt(ps.CharacterID, 10)),
+			ps.Kills, ps.FinalBlows, ps.Damage, killmail.FormatISKValue(ps.Value), ps.LastKill.UTC().Format("2006-01-02 15:04"))
+	}
+	_ = w.Flush()
+}
+
+// tagsCommand prints the tagged kills, e.g. for an SRP spreadsheet
+func tagsCommand(cfg *chainkills.Config, args []string) {
+	fs := flag.NewFlagSet("tags", flag.ExitOnError)
+	tag := fs.String("tag", "", "only kills with this tag")
+	since := fs.Duration("since", 7*24*time.Hour, "how far back to look for tags")
+	_ = fs.Parse(args)
+
+	if cfg.Tags.Path == "" {
+		log.Fatalf("Kill tags are not enabled; set tags.path in config.json")
+	}
+	store, err := killtags.Load(cfg.Tags.Path)
+	if err != nil {
+		log.Fatalf("Error reading kill tags: %v", err)
+	}
+	kills := store.Tagged(strings.ToLower(*tag), time.Now().Add(-*since))
+	ids := make([]int64, 0, len(kills))
+	for id := range kills {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] > ids[j] })
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KILL\tTAG\tBY\tTAGGED\tZKILLBOARD")
+	for _, id := range ids {
+		for _, t := range kills[id] {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\thttps://zkillboard.com/kill/%d/\n", id, t.Tag, t.By, t.Time.UTC().Format("2006-01-02 15:04"), id)
+		}
+	}
+	_ = w.Flush()
+}
+
+// templatesCommand lists what embed templates can use
+func templatesCommand(args []string) {
+	if len(args) != 1 || args[0] != "funcs" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FUNCTION\tDESCRIPTION\tEXAMPLE")
+	for _, f := range templates.Funcs(context.Background(), nil) {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", f.Signature(), f.Doc, f.Example)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "FIELD\tTYPE")
+	for _, f := range templates.Fields(notify.Notification{}) {
+		fmt.Fprintf(w, "%s\t%s\n", f.Path, f.Type)
+	}
+	_ = w.Flush()
+}
+
+// ssoCommand signs a character in through EVE SSO, or lists the signed-in ones
+func ssoCommand(cfg *chainkills.Config, args []string) {
+	if len(args) != 1 || (args[0] != "login" && args[0] != "list") {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if cfg.SSO.ClientID == "" {
+		log.Fatalf("EVE SSO is not configured; set sso.clientId in config.json")
+	}
+	client, err := chainkills.NewSSO(logruslogger.New(logrus.New()), cfg.SSO)
+	if err != nil {
+		log.Fatalf("Error opening SSO token file: %v", err)
+	}
+
+	if args[0] == "login" {
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+		ch, err := client.Login(ctx, cfg.SSO.Scopes, func(authURL string) {
+			fmt.Printf("Open this URL and sign in with the character to use:\n\n%s\n\n", authURL)
+		})
+		if err != nil {
+			log.Fatalf("SSO login failed: %v", err)
+		}
+		fmt.Printf("Signed in %s (%d) with %s\n", ch.Name, ch.ID, strings.Join(ch.Scopes, ", "))
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CHARACTER\tID\tSCOPES")
+	for _, ch := range client.Characters() {
+		fmt.Fprintf(w, "%s\t%d\t%s\n", ch.Name, ch.ID, strings.Join(ch.Scopes, " "))
+	}
+	_ = w.Flush()
+}
+
+// simulate reports how the current config would have matched recent kills
+func simulate(ctx context.Context, hub *chainkills.Hub, args []string) {
+	fs := flag.NewFlagSet("simulate", flag.ExitOnError)
+	since := fs.Duration("since", 24*time.Hour, "how far back to look; zKillboard allows up to 168h")
+	_ = fs.Parse(args)
+
+	results, err := hub.Simulate(ctx, *since)
+	if err != nil {
+		log.Fatalf("Simulation failed: %v", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tINSTANCE\tKILL\tSYSTEM\tRESULT\tREASON")
+	alerts := 0
+	for _, r := range results {
+		result := "no alert"
+		switch {
+		case r.Err != nil:
+			result = "error"
+			r.Reason.Text = r.Err.Error()
+		case r.Kind != "":
+			alerts++
+			result = string(r.Kind)
+			if r.Kind == notify.KindCorpKill && r.IsKill {
+				result += " (kill)"
+			} else if r.Kind == notify.KindCorpKill {
+				result += " (loss)"
+			}
+		}
+		when := "-"
+		if !r.Time.IsZero() {
+			when = r.Time.UTC().Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\t%s\n", when, valueOr(r.Instance, "-"), r.KillID, r.SystemID, result, r.Reason.Text)
+	}
+	_ = w.Flush()
+	fmt.Printf("\n%d kills in the last %s, %d would have alerted. Nothing was sent.\n", len(results), *since, alerts)
+}
+
+// preview prints the Discord message each instance would post for a kill,
+// and optionally writes it as HTML
+func preview(ctx context.Context, hub *chainkills.Hub, args []string) {
+	fs := flag.NewFlagSet("preview", flag.ExitOnError)
+	htmlPath := fs.String("html", "", "also write an HTML rendering of the message(s) to this file")
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	killID, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+	if err != nil {
+		log.Fatalf("Invalid kill ID %q", fs.Arg(0))
+	}
+	previews, err := hub.Preview(ctx, killID)
+	if err != nil {
+		log.Fatalf("Preview failed: %v", err)
+	}
+
+	var page strings.Builder
+	for _, p := range previews {
+		heading := fmt.Sprintf("%s alert", p.Kind)
+		if !p.Matched {
+			heading = "no alert"
+		}
+		if p.Instance != "" {
+			heading = p.Instance + ": " + heading
+		}
+		fmt.Printf("── %s ──\n%s\n", heading, discord.RenderANSI(p.Text, p.Embed))
+		fmt.Fprintf(&page, "<h3>%s</h3>\n%s", html.EscapeString(heading), discord.RenderHTML(p.Text, p.Embed))
+	}
+	if *htmlPath != "" {
+		if err := os.WriteFile(*htmlPath, []byte(page.String()), 0o644); err != nil {
+			log.Fatalf("Error writing %s: %v", *htmlPath, err)
+		}
+		fmt.Printf("Wrote %s. Nothing was sent.\n", *htmlPath)
+		return
+	}
+	fmt.Println("Nothing was sent.")
+}
+
+// intel prints recent kills and zKillboard stats for one system
+func intel(ctx context.Context, hub *chainkills.Hub, args []string) {
+	fs := flag.NewFlagSet("intel", flag.ExitOnError)
+	instance := fs.String("instance", "", "instance whose map aliases to use; required with several instances")
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	report, err := hub.Intel(ctx, *instance, fs.Arg(0))
+	if err != nil {
+		log.Fatalf("Intel failed: %v", err)
+	}
+	fmt.Println(report)
+}
+
+// importHistory fills the attribution store from zKillboard's history
+func importHistory(ctx context.Context, hub *chainkills.Hub, args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	entity := fs.Int("entity", 0, "corporation, alliance or character ID to import; default every tracked ID")
+	entityType := fs.String("type", "", "zKillboard entity type (corporationID, allianceID, characterID); default guessed from the ID")
+	sinceFlag := fs.String("since", "", "oldest kill date to import, e.g. 2024-01-01")
+	esiGap := fs.Duration("esi-gap", 250*time.Millisecond, "pause between ESI requests")
+	_ = fs.Parse(args)
+
+	since, err := time.Parse("2006-01-02", *sinceFlag)
+	if err != nil {
+		log.Fatalf("Invalid -since %q, expected YYYY-MM-DD", *sinceFlag)
+	}
+	res, err := hub.Import(ctx, chainkills.ImportOptions{Entity: *entityType, ID: *entity, Since: since, ESIGap: *esiGap})
+	fmt.Printf("%d kills listed, %d already stored, %d failed, %d imported with %d pilot records.\n",
+		res.Listed, res.Skipped, res.Failed, res.Imported, res.Records)
+	if err != nil {
+		log.Fatalf("Import stopped: %v", err)
+	}
+}
+
+// bench replays an archived killstream through the pipeline and reports where the time went
+func bench(ctx context.Context, hub *chainkills.Hub, args []string) {
+	if len(args) == 0 || args[0] != "replay" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	fs := flag.NewFlagSet("bench replay", flag.ExitOnError)
+	file := fs.String("file", "", "killstream archive, one message per line")
+	speed := fs.Float64("speed", 0, "replay at this multiple of the kills' own pace; 0 is as fast as possible")
+	enrich := fs.Bool("enrich", false, "look kills up on ESI like the live pipeline")
+	cpuProfile := fs.String("cpuprofile", "", "write a CPU profile to this file")
+	memProfile := fs.String("memprofile", "", "write a heap profile to this file when done")
+	_ = fs.Parse(args[1:])
+
+	f, err := os.Open(*file)
+	if err != nil {
+		log.Fatalf("Error opening the archive: %v", err)
+	}
+	defer f.Close()
+	if *cpuProfile != "" {
+		out, err := os.Create(*cpuProfile)
+		if err != nil {
+			log.Fatalf("Error creating the CPU profile: %v", err)
+		}
+		defer out.Close()
+		if err := pprof.StartCPUProfile(out); err != nil {
+			log.Fatalf("Error starting the CPU profile: %v", err)
+		}
+		defer pprof.StopCPUProfile()
+	}
+
+	res, err := hub.Replay(ctx, f, chainkills.ReplayOptions{Speed: *speed, Enrich: *enrich})
+	if err != nil {
+		log.Printf("Replay stopped: %v", err)
+	}
+
+	stages := make([]string, 0, len(res.Stages))
+	for name := range res.Stages {
+		stages = append(stages, name)
+	}
+	sort.Slice(stages, func(i, j int) bool { return res.Stages[stages[i]].TotalTime > res.Stages[stages[j]].TotalTime })
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STAGE\tRUNS\tDROPPED\tERRORS\tTOTAL\tAVERAGE")
+	for _, name := range stages {
+		st := res.Stages[name]
+		avg := time.Duration(0)
+		if st.Processed > 0 {
+			avg = st.TotalTime / time.Duration(st.Processed)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\t%s\n", name, st.Processed, st.Dropped, st.Errors, st.TotalTime, avg)
+	}
+	_ = w.Flush()
+	rate := 0.0
+	if res.Elapsed > 0 {
+		rate = float64(res.Messages) / res.Elapsed.Seconds()
+	}
+	fmt.Printf("\n%d messages in %s (%.0f/s), %d would have alerted, %d errors. Nothing was sent.\n",
+		res.Messages, res.Elapsed.Round(time.Millisecond), rate, res.Matched, res.Errors)
+
+	if *memProfile != "" {
+		out, err := os.Create(*memProfile)
+		if err != nil {
+			log.Fatalf("Error creating the heap profile: %v", err)
+		}
+		defer out.Close()
+		runtime.GC()
+		if err := pprof.WriteHeapProfile(out); err != nil {
+			log.Fatalf("Error writing the heap profile: %v", err)
+		}
+	}
+}
+
+func valueOr(s, fallback string) string {
+	if s == "" {
+		return fallback
+	}
+	return s
+}
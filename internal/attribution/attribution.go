@@ -0,0 +1,169 @@
+// Package attribution keeps a JSON Lines record of which of our pilots took
+// part in each matched kill, for per-pilot stats and leaderboards.
+package attribution
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Record is one of our pilots on the attacking side of one kill
+type Record struct {
+	// Time is when the kill happened
+	Time          time.Time `json:"time"`
+	KillID        int64     `json:"kill_id"`
+	Instance      string    `json:"instance,omitempty"`
+	Kind          string    `json:"kind"`
+	SystemID      int       `json:"system_id,omitempty"`
+	CharacterID   int64     `json:"character_id"`
+	Name          string    `json:"name,omitempty"`
+	CorporationID int       `json:"corporation_id,omitempty"`
+	ShipTypeID    int       `json:"ship_type_id,omitempty"`
+	Damage        int       `json:"damage"`
+	FinalBlow     bool      `json:"final_blow,omitempty"`
+	// Value is the whole kill's zKillboard value
+	Value float64 `json:"value"`
+}
+
+// Store appends records to a file
+type Store struct {
+	path string
+
+	mu sync.Mutex
+	f  *os.File
+}
+
+// Open opens or creates the store at path
+func Open(path string) (*Store, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{path: path, f: f}, nil
+}
+
+// Path is the file the store writes to
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Write appends the records of one kill
+func (s *Store) Write(records ...Record) error {
+	var buf []byte
+	for _, r := range records {
+		line, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return errors.New("attribution store is closed")
+	}
+	_, err := s.f.Write(buf)
+	return err
+}
+
+// Close closes the file; later writes fail
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+	return err
+}
+
+// Read returns the records of kills at or after since from the store at path.
+// It is safe to call while another process writes.
+func Read(path string, since time.Time) ([]Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		var r Record
+		// a line being written right now may be incomplete
+		if json.Unmarshal(scanner.Bytes(), &r) == nil && !r.Time.Before(since) {
+			records = append(records, r)
+		}
+	}
+	return records, scanner.Err()
+}
+
+// PilotStats sums up one pilot's kills
+type PilotStats struct {
+	CharacterID int64     `json:"character_id"`
+	Name        string    `json:"name,omitempty"`
+	Kills       int       `json:"kills"`
+	FinalBlows  int       `json:"final_blows"`
+	Damage      int       `json:"damage"`
+	Value       float64   `json:"value"`
+	LastKill    time.Time `json:"last_kill"`
+}
+
+// Leaderboard sums records per pilot, most kills first, then most ISK. A kill
+// recorded more than once, e.g. by two instances, counts once.
+func Leaderboard(records []Record) []PilotStats {
+	type key struct {
+		killID      int64
+		characterID int64
+	}
+	seen := map[key]bool{}
+	byPilot := map[int64]*PilotStats{}
+	for _, r := range records {
+		k := key{r.KillID, r.CharacterID}
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+
+		ps := byPilot[r.CharacterID]
+		if ps == nil {
+			ps = &PilotStats{CharacterID: r.CharacterID}
+			byPilot[r.CharacterID] = ps
+		}
+		if r.Name != "" {
+			ps.Name = r.Name
+		}
+		ps.Kills++
+		if r.FinalBlow {
+			ps.FinalBlows++
+		}
+		ps.Damage += r.Damage
+		ps.Value += r.Value
+		if r.Time.After(ps.LastKill) {
+			ps.LastKill = r.Time
+		}
+	}
+
+	board := make([]PilotStats, 0, len(byPilot))
+	for _, ps := range byPilot {
+		board = append(board, *ps)
+	}
+	sort.Slice(board, func(i, j int) bool {
+		if board[i].Kills != board[j].Kills {
+			return board[i].Kills > board[j].Kills
+		}
+		if board[i].Value != board[j].Value {
+			return board[i].Value > board[j].Value
+		}
+		return board[i].CharacterID < board[j].CharacterID
+	})
+	return board
+}
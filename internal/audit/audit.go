@@ -0,0 +1,224 @@
+// Package audit keeps a JSON Lines log of what happened to every processed
+// kill, so "why did (or didn't) this kill alert?" can be answered later
+// without debug logs.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Delivery is the result of sending a notification to one sink
+type Delivery struct {
+	Sink  string `json:"sink"`
+	Error string `json:"error,omitempty"`
+	// MessageID identifies the sent message, for sinks that edit it later
+	MessageID string `json:"message_id,omitempty"`
+	// URL links to the sent message, for sinks that know it
+	URL string `json:"url,omitempty"`
+}
+
+// Record is the decision made for one kill by one instance
+type Record struct {
+	Time     time.Time `json:"time"`
+	KillID   int64     `json:"kill_id"`
+	Instance string    `json:"instance,omitempty"`
+	SystemID int       `json:"system_id,omitempty"`
+	Matched  bool      `json:"matched"`
+	Kind     string    `json:"kind,omitempty"`
+	// Rule and Reason come from the filter; unmatched kills have a Reason but no Rule
+	Rule   string `json:"rule,omitempty"`
+	Reason string `json:"reason,omitempty"`
+	// StoppedAt is the stage that dropped the kill, e.g. "dedup" or "match"
+	StoppedAt  string     `json:"stopped_at,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	Deliveries []Delivery `json:"deliveries,omitempty"`
+}
+
+// String renders the record in one line for "chainkills why"
+func (r Record) String() string {
+	var sb strings.Builder
+	sb.WriteString(r.Time.UTC().Format(time.RFC3339))
+	if r.Instance != "" {
+		fmt.Fprintf(&sb, " [%s]", r.Instance)
+	}
+	fmt.Fprintf(&sb, " kill %d: ", r.KillID)
+	switch {
+	case r.Matched:
+		fmt.Fprintf(&sb, "matched as %s", r.Kind)
+	case r.StoppedAt == "" || r.StoppedAt == "match" || r.StoppedAt == "prefilter":
+		sb.WriteString("not matched")
+	default:
+		fmt.Fprintf(&sb, "dropped at %s", r.StoppedAt)
+	}
+	if r.Reason != "" {
+		fmt.Fprintf(&sb, " (%s)", r.Reason)
+	}
+	if r.Matched && r.StoppedAt != "" {
+		fmt.Fprintf(&sb, ", dropped at %s", r.StoppedAt)
+	}
+	if r.Error != "" {
+		fmt.Fprintf(&sb, ", failed: %s", r.Error)
+	}
+
+	var sent, failed []string
+	for _, d := range r.Deliveries {
+		if d.Error == "" && d.URL != "" {
+			sent = append(sent, fmt.Sprintf("%s (%s)", d.Sink, d.URL))
+		} else if d.Error == "" {
+			sent = append(sent, d.Sink)
+		} else {
+			failed = append(failed, fmt.Sprintf("%s failed: %s", d.Sink, d.Error))
+		}
+	}
+	if len(sent) > 0 {
+		fmt.Fprintf(&sb, ", sent to %s", strings.Join(sent, ", "))
+	}
+	if len(failed) > 0 {
+		fmt.Fprintf(&sb, ", %s", strings.Join(failed, "; "))
+	}
+	if r.Matched && r.StoppedAt == "" && r.Error == "" && len(r.Deliveries) == 0 {
+		sb.WriteString(", no sinks were routed")
+	}
+	return sb.String()
+}
+
+// Log appends records to a file, moving it to "<path>.1" once it grows past maxBytes
+type Log struct {
+	path     string
+	maxBytes int64
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// Open opens or creates the log at path
+func Open(path string, maxBytes int64) (*Log, error) {
+	l := &Log{path: path, maxBytes: maxBytes}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Log) open() error {
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.f, l.size = f, info.Size()
+	return nil
+}
+
+// Path is the file the log writes to
+func (l *Log) Path() string {
+	return l.path
+}
+
+// Write appends one record
+func (l *Log) Write(r Record) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return errors.New("audit log is closed")
+	}
+	if l.maxBytes > 0 && l.size > 0 && l.size+int64(len(line)) > l.maxBytes {
+		if err = l.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := l.f.Write(line)
+	l.size += int64(n)
+	return err
+}
+
+// rotate replaces "<path>.1" with the current file and starts a new one
+func (l *Log) rotate() error {
+	if err := l.f.Close(); err != nil {
+		return err
+	}
+	l.f = nil
+	if err := os.Rename(l.path, l.path+".1"); err != nil {
+		return err
+	}
+	return l.open()
+}
+
+// Find returns the records for a kill, oldest first
+func (l *Log) Find(killID int64) ([]Record, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return Find(l.path, killID)
+}
+
+// Close closes the file; later writes fail
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
+
+// Find reads the records for a kill from the log at path and its rotated
+// predecessor, oldest first. It is safe to call while another process writes.
+func Find(path string, killID int64) ([]Record, error) {
+	var records []Record
+	for _, p := range []string{path + ".1", path} {
+		found, err := find(p, killID)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		records = append(records, found...)
+	}
+	return records, nil
+}
+
+func find(path string, killID int64) ([]Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// cheap pre-check so only candidate lines are decoded
+	needle := []byte(fmt.Sprintf(`"kill_id":%d,`, killID))
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if !bytes.Contains(line, needle) {
+			continue
+		}
+		var r Record
+		// a line being written right now may be incomplete
+		if json.Unmarshal(line, &r) == nil && r.KillID == killID {
+			records = append(records, r)
+		}
+	}
+	return records, scanner.Err()
+}
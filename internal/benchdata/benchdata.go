@@ -0,0 +1,115 @@
+// Package benchdata builds kills shaped like the real killstream for
+// benchmarks: a small gank, a 50-pilot fleet fight and a 4000-attacker
+// structure kill. They are generated, not recorded, so they need no network
+// and stay the same from run to run.
+package benchdata
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/pkg/killmail"
+)
+
+// IDs the kills use, so benchmarks can track them or map the system
+const (
+	// TrackedCorp is the victim's corporation in the small kill and one
+	// attacker's in the fleet fight; the structure kill doesn't involve it
+	TrackedCorp = 98000001
+	// ChainSystem is where the small kill and the fleet fight happen
+	ChainSystem = 31000001
+	// StructureSystem is a system no chain reaches
+	StructureSystem = 30004759
+)
+
+// Kill is one fixture, as a decoded message and as the killstream sent it
+type Kill struct {
+	Name  string
+	Zkill killmail.ZkillMail
+	Raw   []byte
+}
+
+// Kills returns the fixtures, smallest first
+func Kills() []Kill {
+	rng := rand.New(rand.NewSource(1))
+	return []Kill{
+		build("small", rng, 1001, ChainSystem, 3, 0),
+		build("fleet-50", rng, 1002, ChainSystem, 50, 49),
+		build("structure-4000", rng, 1003, StructureSystem, 4000, -1),
+	}
+}
+
+// build makes a kill with n attackers; trackedAt 0 puts the victim in
+// TrackedCorp, a positive trackedAt that attacker, and -1 neither
+func build(name string, rng *rand.Rand, id int64, system, n, trackedAt int) Kill {
+	zm := killmail.ZkillMail{
+		KillmailID:    id,
+		KillmailTime:  time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		SolarSystemID: system,
+		Victim: killmail.Victim{
+			CharacterID:   2110000000 + rng.Int63n(1000000),
+			CorporationID: 98100000 + rng.Intn(100000),
+			AllianceID:    99000000 + rng.Intn(10000),
+			ShipTypeID:    ships[rng.Intn(len(ships))],
+			DamageTaken:   5000 + rng.Intn(50000),
+			Position:      &killmail.Position{X: rng.Float64() * 1e12, Y: rng.Float64() * 1e10, Z: rng.Float64() * 1e12},
+		},
+	}
+	if trackedAt == 0 {
+		zm.Victim.CorporationID = TrackedCorp
+	}
+	if n >= 1000 {
+		// structures: an Astrahus' worth of damage and hull
+		zm.Victim.CharacterID = 0
+		zm.Victim.ShipTypeID = 35832
+		zm.Victim.DamageTaken = 9000000
+	}
+	// fleets fly with a few dozen corporations at most
+	corps := make([]int, 1+n/20)
+	for i := range corps {
+		corps[i] = 98200000 + rng.Intn(100000)
+	}
+	for i := 0; i < n; i++ {
+		a := killmail.Attacker{
+			CharacterID:    2120000000 + rng.Int63n(10000000),
+			CorporationID:  corps[rng.Intn(len(corps))],
+			AllianceID:     99100000 + rng.Intn(100),
+			DamageDone:     rng.Intn(20000),
+			SecurityStatus: rng.Float64()*10 - 5,
+			ShipTypeID:     ships[rng.Intn(len(ships))],
+			WeaponTypeID:   2000 + rng.Intn(1000),
+			FinalBlow:      i == n/2,
+		}
+		if i == trackedAt && trackedAt > 0 {
+			a.CorporationID = TrackedCorp
+		}
+		zm.Attackers = append(zm.Attackers, a)
+	}
+	zm.ZKB = killmail.ZKB{
+		LocationID:     40000000 + int64(rng.Intn(100000)),
+		Hash:           fmt.Sprintf("%040x", rng.Uint64()),
+		FittedValue:    rng.Float64() * 1e9,
+		DroppedValue:   rng.Float64() * 1e8,
+		DestroyedValue: rng.Float64() * 1e9,
+		TotalValue:     rng.Float64() * 2e9,
+		Points:         rng.Intn(100),
+		Solo:           n == 1,
+	}
+	raw, err := json.Marshal(zm)
+	if err != nil {
+		panic(err)
+	}
+	return Kill{Name: name, Zkill: zm, Raw: raw}
+}
+
+// ships are common hulls: Rifter, Loki, Legion, Sabre, Guardian, Muninn, Eagle
+var ships = []int{587, 29990, 29986, 22456, 11987, 12015, 12011}
+
+// Flattened is the kill as enrichment would leave it, with IDs for names
+func (k Kill) Flattened() killmail.FlattenedKillMail {
+	var fkm killmail.FlattenedKillMail
+	fkm.FillMissing(k.Zkill)
+	return fkm
+}
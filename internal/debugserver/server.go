@@ -0,0 +1,115 @@
+// Package debugserver serves net/http/pprof and a /debug/vars JSON endpoint
+// for diagnosing a running instance in place. It is off unless configured.
+package debugserver
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+)
+
+// Server exposes the debug endpoints on its own listener, never on http.DefaultServeMux
+type Server struct {
+	logger logger.Logger
+	token  string
+	vars   func() map[string]interface{}
+	srv    *http.Server
+}
+
+// New creates a server listening on addr. Non-loopback addresses require a
+// token, which clients send as "Authorization: Bearer <token>" or ?token=.
+// vars supplies the application values merged into /debug/vars.
+func New(logger logger.Logger, addr, token string, vars func() map[string]interface{}) (*Server, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if ip := net.ParseIP(host); token == "" && host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, errors.New("debug endpoint on a non-loopback address requires a token")
+	}
+
+	s := &Server{logger: logger, token: token, vars: vars}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/vars", s.handleVars)
+	s.srv = &http.Server{
+		Addr:              addr,
+		Handler:           s.authorize(mux),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return s, nil
+}
+
+// Start listens in the background
+func (s *Server) Start() error {
+	ln, err := net.Listen("tcp", s.srv.Addr)
+	if err != nil {
+		return err
+	}
+	s.logger.Printf("Debug endpoints listening on %s", ln.Addr())
+	go func() {
+		if err := s.srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Printf("Debug server error: %v", err)
+		}
+	}()
+	return nil
+}
+
+// Shutdown stops the listener and waits for open requests until ctx is done
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.srv.Shutdown(ctx)
+}
+
+func (s *Server) authorize(next http.Handler) http.Handler {
+	if s.token == "" {
+		return next
+	}
+	want := []byte("Bearer " + s.token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := r.Header.Get("Authorization")
+		if t := r.URL.Query().Get("token"); t != "" {
+			got = "Bearer " + t
+		}
+		if subtle.ConstantTimeCompare([]byte(got), want) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleVars mirrors expvar's output (cmdline, memstats) plus runtime counts and the application vars
+func (s *Server) handleVars(w http.ResponseWriter, r *http.Request) {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	out := map[string]interface{}{
+		"cmdline":    os.Args,
+		"memstats":   ms,
+		"goroutines": runtime.NumGoroutine(),
+		"gomaxprocs": runtime.GOMAXPROCS(0),
+	}
+	if s.vars != nil {
+		for k, v := range s.vars() {
+			out[k] = v
+		}
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		s.logger.Printf("Error writing debug vars: %v", err)
+	}
+}
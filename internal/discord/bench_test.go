@@ -0,0 +1,27 @@
+package discord
+
+import (
+	"testing"
+
+	"github.com/guarzo/eve-chainkills/internal/benchdata"
+	"github.com/guarzo/eve-chainkills/pkg/killmail"
+)
+
+func BenchmarkKillEmbed(b *testing.B) {
+	style := EmbedStyle{
+		Colors:    Colors{Kill: "#00FF00", Loss: "#FF0000"},
+		Images:    killmail.Images{IconSize: 128, ThumbnailSize: 256, ShipRenders: true},
+		ISKFormat: killmail.ISKFormatBoth,
+	}
+	for _, k := range benchdata.Kills() {
+		fkm := k.Flattened()
+		b.Run(k.Name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				embed := NewKillEmbed(testLogger(), style, fkm, true).CreateEmbed()
+				content := ""
+				FitLimits(&content, &embed)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package discord
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+
+	"github.com/guarzo/eve-chainkills/internal/httpclient"
+)
+
+// Message is the part of a Discord channel message needed to read commands
+type Message struct {
+	ID      string `json:"id"`
+	Content string `json:"content"`
+	Author  struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+		Bot      bool   `json:"bot"`
+	} `json:"author"`
+}
+
+// User is a Discord user, e.g. one who reacted to a message
+type User struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Bot      bool   `json:"bot"`
+}
+
+// Reactions lists up to 100 users who reacted to a message with emoji, which
+// is a unicode emoji such as "✅" or "name:id" for a custom one. A nil client
+// uses the default one.
+func Reactions(ctx context.Context, client *http.Client, botToken, channelID, messageID, emoji string) ([]User, error) {
+	u := fmt.Sprintf("https://discord.com/api/v10/channels/%s/messages/%s/reactions/%s?limit=100",
+		channelID, messageID, url.PathEscape(emoji))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bot "+botToken)
+
+	resp, err := httpclient.Or(client).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("discord reactions got status %d", resp.StatusCode)
+	}
+	var users []User
+	if err = json.NewDecoder(resp.Body).Decode(&users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// ChannelPoller reads new messages from one channel with a bot token. It only
+// reads; the bot needs View Channel, Read Message History and the Message
+// Content intent, and never connects to the gateway.
+type ChannelPoller struct {
+	client    *http.Client
+	botToken  string
+	channelID string
+	// after is the newest message already seen; empty until the first poll
+	after string
+}
+
+// NewChannelPoller constructor; a nil client uses the default one
+func NewChannelPoller(client *http.Client, botToken, channelID string) *ChannelPoller {
+	return &ChannelPoller{client: client, botToken: botToken, channelID: channelID}
+}
+
+// Poll returns messages posted since the previous poll, oldest first. The first
+// poll only records where the channel is, so old commands are not replayed.
+func (cp *ChannelPoller) Poll(ctx context.Context) ([]Message, error) {
+	query := url.Values{"limit": {"50"}}
+	if cp.after == "" {
+		query.Set("limit", "1")
+	} else {
+		query.Set("after", cp.after)
+	}
+	u := fmt.Sprintf("https://discord.com/api/v10/channels/%s/messages?%s", cp.channelID, query.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bot "+cp.botToken)
+
+	resp, err := httpclient.Or(cp.client).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("discord channel messages got status %d", resp.StatusCode)
+	}
+
+	var msgs []Message
+	if err = json.NewDecoder(resp.Body).Decode(&msgs); err != nil {
+		return nil, err
+	}
+	// Discord returns newest first; snowflakes of equal length sort as strings
+	sort.Slice(msgs, func(i, j int) bool {
+		if len(msgs[i].ID) != len(msgs[j].ID) {
+			return len(msgs[i].ID) < len(msgs[j].ID)
+		}
+		return msgs[i].ID < msgs[j].ID
+	})
+
+	first := cp.after == ""
+	if len(msgs) > 0 {
+		cp.after = msgs[len(msgs)-1].ID
+	} else if first {
+		// an empty channel has nothing to skip
+		cp.after = "0"
+	}
+	if first {
+		return nil, nil
+	}
+	return msgs, nil
+}
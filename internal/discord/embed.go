@@ -1,45 +1,45 @@
-package main
+package discord
 
 import (
 	"fmt"
-	"math"
 	"time"
 
-	"github.com/sirupsen/logrus"
+	"github.com/guarzo/eve-chainkills/pkg/killmail"
+	"github.com/guarzo/eve-chainkills/pkg/logger"
 )
 
 // -------------------------------------------------------------------
 // Detailed Discord Embed Structures
 // -------------------------------------------------------------------
 
-// DiscordEmbed matches Discord’s Embed JSON structure
-type DiscordEmbed struct {
-	Title       string            `json:"title,omitempty"`
-	URL         string            `json:"url,omitempty"`
-	Description string            `json:"description,omitempty"`
-	Color       int               `json:"color,omitempty"`
-	Timestamp   string            `json:"timestamp,omitempty"`
-	Footer      *DiscordFooter    `json:"footer,omitempty"`
-	Thumbnail   *DiscordThumbnail `json:"thumbnail,omitempty"`
-	Author      *DiscordAuthor    `json:"author,omitempty"`
-	Fields      []DiscordField    `json:"fields,omitempty"`
+// Embed matches Discord’s Embed JSON structure
+type Embed struct {
+	Title       string     `json:"title,omitempty"`
+	URL         string     `json:"url,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Color       int        `json:"color,omitempty"`
+	Timestamp   string     `json:"timestamp,omitempty"`
+	Footer      *Footer    `json:"footer,omitempty"`
+	Thumbnail   *Thumbnail `json:"thumbnail,omitempty"`
+	Author      *Author    `json:"author,omitempty"`
+	Fields      []Field    `json:"fields,omitempty"`
 }
 
-type DiscordFooter struct {
+type Footer struct {
 	Text string `json:"text,omitempty"`
 }
 
-type DiscordThumbnail struct {
+type Thumbnail struct {
 	URL string `json:"url,omitempty"`
 }
 
-type DiscordAuthor struct {
+type Author struct {
 	Name    string `json:"name,omitempty"`
 	URL     string `json:"url,omitempty"`
 	IconURL string `json:"icon_url,omitempty"`
 }
 
-type DiscordField struct {
+type Field struct {
 	Name   string `json:"name,omitempty"`
 	Value  string `json:"value,omitempty"`
 	Inline bool   `json:"inline,omitempty"`
@@ -49,26 +49,51 @@ type DiscordField struct {
 // KillEmbed
 // -------------------------------------------------------------------
 
+// Colors holds the "#RRGGBB" embed colors for kills and losses
+type Colors struct {
+	Kill string
+	Loss string
+}
+
+// EmbedStyle is how a sink wants its kill embeds to look
+type EmbedStyle struct {
+	Colors    Colors
+	Images    killmail.Images
+	ISKFormat string // one of the killmail.ISKFormat* values
+	// PlainTime renders the kill time as text in Timezone instead of Discord
+	// timestamp markup, for chat servers that don't understand <t:...>
+	PlainTime bool
+	Timezone  *time.Location
+}
+
+// Timestamp renders t as Discord's dynamic timestamp markup in the given
+// style, e.g. "R" for "3 minutes ago"
+func Timestamp(t time.Time, style string) string {
+	return fmt.Sprintf("<t:%d:%s>", t.Unix(), style)
+}
+
+// KillEmbed formats one enriched kill as a Discord embed
 type KillEmbed struct {
-	logger      *logrus.Logger
-	config      *AppConfig
-	killDetails *KillDetails
+	logger logger.Logger
+	style  EmbedStyle
+	fkm    killmail.FlattenedKillMail
+	isKill bool
 }
 
 // NewKillEmbed constructor
-func NewKillEmbed(logger *logrus.Logger, config *AppConfig, kd *KillDetails) *KillEmbed {
+func NewKillEmbed(logger logger.Logger, style EmbedStyle, fkm killmail.FlattenedKillMail, isKill bool) *KillEmbed {
 	return &KillEmbed{
-		logger:      logger,
-		config:      config,
-		killDetails: kd,
+		logger: logger,
+		style:  style,
+		fkm:    fkm,
+		isKill: isKill,
 	}
 }
 
 // CreateEmbed replicates your old JS logic, but also uses real ship + character + system names
-func (ke *KillEmbed) CreateEmbed() DiscordEmbed {
-	kd := ke.killDetails
-	fkm := kd.FKM // FlattenedKillMail
-	isKill := kd.IsKill
+func (ke *KillEmbed) CreateEmbed() Embed {
+	fkm := ke.fkm // FlattenedKillMail
+	isKill := ke.isKill
 	isAwox := fkm.Awox
 
 	colorHex := ke.pickColor(isKill)
@@ -87,12 +112,12 @@ func (ke *KillEmbed) CreateEmbed() DiscordEmbed {
 		authorText = "Cowardly Awox"
 	}
 
-	// If alliance > 0, use alliance image, else corp
+	// The victim's portrait; structures and NPCs have no character, so use their corp's logo
 	authorImage := ""
-	if fkm.Victim.AllianceID > 0 {
-		authorImage = fmt.Sprintf("https://image.eveonline.com/Alliance/%d_64.png", fkm.Victim.AllianceID)
+	if fkm.Victim.CharacterID > 0 {
+		authorImage = ke.style.Images.CharacterPortrait(fkm.Victim.CharacterID)
 	} else {
-		authorImage = fmt.Sprintf("https://image.eveonline.com/Corporation/%d_64.png", fkm.Victim.CorporationID)
+		authorImage = ke.style.Images.CorporationLogo(fkm.Victim.CorporationID)
 	}
 
 	// Final attacker name/ship
@@ -123,7 +148,7 @@ func (ke *KillEmbed) CreateEmbed() DiscordEmbed {
 	}
 
 	// If multiple attackers, mention "and X others"
-	attackersCount := len(fkm.Attackers)
+	attackersCount := fkm.AttackerCount()
 	descEnd := "solo"
 	if attackersCount > 1 {
 		descEnd = fmt.Sprintf("and **%d** others", attackersCount-1)
@@ -153,6 +178,12 @@ func (ke *KillEmbed) CreateEmbed() DiscordEmbed {
 		descEnd,
 	)
 
+	if killmail.IsAbyssal(fkm.SolarSystemID) && fkm.FinalAttackerID == 0 {
+		// no pilot to name, only the Abyss's NPCs
+		description = fmt.Sprintf("**[%s](%s)(%s)** lost their **%s**; it died in Abyssal space.",
+			victimCharName, victimZkillURL, victimGroupName, victimShipName)
+	}
+
 	// If you have a system name, use it. Otherwise fallback to "SystemID:%d"
 	systemName := fkm.SystemName
 	if systemName == "" {
@@ -161,23 +192,51 @@ func (ke *KillEmbed) CreateEmbed() DiscordEmbed {
 	// Title: "Hurricane destroyed in J123456"
 	title := fmt.Sprintf("%s destroyed in %s", victimShipName, systemName)
 
-	embed := DiscordEmbed{
+	var fields []Field
+	if fkm.ThreatSummary != "" && !isKill {
+		fields = append(fields, Field{Name: "Threat", Value: fkm.ThreatSummary})
+	}
+	if fkm.FactionWarfare != "" {
+		fields = append(fields, Field{Name: "Faction Warfare", Value: fkm.FactionWarfare})
+	}
+	if fkm.NearestCelestial != "" {
+		fields = append(fields, Field{
+			Name:  "Location",
+			Value: fmt.Sprintf("%s off %s", killmail.FormatDistance(fkm.CelestialDistance), fkm.NearestCelestial),
+		})
+	}
+
+	// the embed shows when the ship died, not when it was sent, so retries
+	// and backfills are not misleading
+	killTime := fkm.KillMailTime
+	if killTime.IsZero() {
+		killTime = time.Now()
+	} else {
+		value := Timestamp(killTime, "R") + " · " + killmail.FormatEVETime(killTime)
+		if ke.style.PlainTime {
+			value = killmail.FormatKillTime(killTime, ke.style.Timezone)
+		}
+		fields = append(fields, Field{Name: "Time", Value: value})
+	}
+
+	embed := Embed{
 		Title:     title,
 		URL:       zkillLink,
 		Color:     intColor,
-		Timestamp: time.Now().UTC().Format(time.RFC3339),
-		Author: &DiscordAuthor{
+		Timestamp: killTime.UTC().Format(time.RFC3339),
+		Author: &Author{
 			Name:    authorText,
 			URL:     zkillLink,
 			IconURL: authorImage,
 		},
 		Description: description,
-		Thumbnail: &DiscordThumbnail{
-			// Victim’s ship 64x64
-			URL: fmt.Sprintf("https://image.eveonline.com/Type/%d_64.png", fkm.Victim.ShipTypeID),
+		Thumbnail: &Thumbnail{
+			// Victim’s ship
+			URL: ke.style.Images.Ship(fkm.Victim.ShipTypeID),
 		},
-		Footer: &DiscordFooter{
-			Text: fmt.Sprintf("Value: %s", formatISKValue(fkm.TotalValue)),
+		Fields: fields,
+		Footer: &Footer{
+			Text: fmt.Sprintf("Value: %s", killmail.FormatISK(fkm.TotalValue, ke.style.ISKFormat)),
 		},
 	}
 
@@ -187,9 +246,9 @@ func (ke *KillEmbed) CreateEmbed() DiscordEmbed {
 // pickColor returns the hex code string from config
 func (ke *KillEmbed) pickColor(isKill bool) string {
 	if isKill {
-		return ke.config.DiscordKillNotifications.KillColor
+		return ke.style.Colors.Kill
 	}
-	return ke.config.DiscordKillNotifications.LossColor
+	return ke.style.Colors.Loss
 }
 
 // parseHexColor parses "#RRGGBB" into an int
@@ -200,23 +259,3 @@ func parseHexColor(hexStr string) int {
 	}
 	return (r << 16) + (g << 8) + b
 }
-
-// formatISKValue now handles M, B, T
-func formatISKValue(amount float64) string {
-	switch {
-	case amount < 1_000_000:
-		return "<1 M ISK"
-	case amount < 1_000_000_000:
-		millions := amount / 1_000_000
-		millions = math.Round(millions*100) / 100
-		return fmt.Sprintf("%.2fm ISK", millions)
-	case amount < 1_000_000_000_000:
-		billions := amount / 1_000_000_000
-		billions = math.Round(billions*100) / 100
-		return fmt.Sprintf("%.2fb ISK", billions)
-	default:
-		trillions := amount / 1_000_000_000_000
-		trillions = math.Round(trillions*100) / 100
-		return fmt.Sprintf("%.2ft ISK", trillions)
-	}
-}
@@ -0,0 +1,125 @@
+package discord
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/internal/esi"
+	"github.com/guarzo/eve-chainkills/pkg/killmail"
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files under testdata/kills")
+
+// Each directory under testdata/kills is one kill: zkill.json is the
+// killstream message, esi/ holds the ESI responses by URL path, e.g.
+// esi/universe/types/29990.json, and embed.golden.json is the expected
+// output. ESI requests without a file get a 404, like a missing name would.
+
+// fixtureTransport answers ESI requests from a kill's esi directory
+type fixtureTransport struct {
+	dir string
+}
+
+func (ft fixtureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	path := strings.Trim(strings.TrimPrefix(req.URL.Path, "/latest"), "/")
+	status := http.StatusOK
+	data, err := os.ReadFile(filepath.Join(ft.dir, filepath.FromSlash(path)+".json"))
+	if err != nil {
+		status = http.StatusNotFound
+		data = []byte(`{"error":"not found"}`)
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(data)),
+		Request:    req,
+	}, nil
+}
+
+func testLogger() logger.Logger {
+	return logger.Slog(slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+// loadKill enriches a fixture's killstream message through the ESI client,
+// served from the fixture's esi directory
+func loadKill(t *testing.T, dir string) killmail.FlattenedKillMail {
+	t.Helper()
+	raw, err := os.ReadFile(filepath.Join(dir, "zkill.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := http.DefaultTransport
+	http.DefaultTransport = fixtureTransport{dir: filepath.Join(dir, "esi")}
+	defer func() { http.DefaultTransport = orig }()
+
+	fkm, err := esi.NewClient(testLogger()).GetKillDetails(context.Background(), raw)
+	if err != nil {
+		t.Fatalf("GetKillDetails: %v", err)
+	}
+	return fkm
+}
+
+func TestKillEmbedGolden(t *testing.T) {
+	dirs, err := filepath.Glob("testdata/kills/*")
+	if err != nil || len(dirs) == 0 {
+		t.Fatalf("no fixtures under testdata/kills: %v", err)
+	}
+	discordStyle := EmbedStyle{Colors: Colors{Kill: "#00FF00", Loss: "#FF0000"}}
+	chatStyle := EmbedStyle{
+		Colors:    Colors{Kill: "#00FF00", Loss: "#FF0000"},
+		Images:    killmail.Images{IconSize: 128, ThumbnailSize: 256, ShipRenders: true},
+		ISKFormat: killmail.ISKFormatBoth,
+		PlainTime: true,
+		Timezone:  time.FixedZone("EDT", -4*60*60),
+	}
+
+	for _, dir := range dirs {
+		t.Run(filepath.Base(dir), func(t *testing.T) {
+			fkm := loadKill(t, dir)
+			// the Discord sink's kill embed and a chat webhook's loss embed
+			got := map[string]Embed{
+				"discord_kill": NewKillEmbed(testLogger(), discordStyle, fkm, true).CreateEmbed(),
+				"chat_loss":    NewKillEmbed(testLogger(), chatStyle, fkm, false).CreateEmbed(),
+			}
+			compareGolden(t, filepath.Join(dir, "embed.golden.json"), got)
+		})
+	}
+}
+
+// compareGolden checks got against the golden file, or rewrites it with -update
+func compareGolden(t *testing.T, path string, got interface{}) {
+	t.Helper()
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	// keep Discord's <t:...> markup readable
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(got); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if *update {
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v; run go test ./internal/discord -update to create it", err)
+	}
+	if !bytes.Equal(data, want) {
+		t.Errorf("%s is out of date; if the change is intended, run go test ./internal/discord -update\ngot:\n%s\nwant:\n%s", path, data, want)
+	}
+}
@@ -0,0 +1,94 @@
+package discord
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// Discord's message and embed limits, in characters
+const (
+	maxContent     = 2000
+	maxTitle       = 256
+	maxDescription = 4096
+	maxFields      = 25
+	maxFieldName   = 256
+	maxFieldValue  = 1024
+	maxFooter      = 2048
+	maxAuthorName  = 256
+	maxEmbedTotal  = 6000
+)
+
+// FitLimits truncates content and the embed in place so Discord accepts them
+// instead of rejecting the whole message with a 400. It returns a description
+// of every change made, for logging.
+func FitLimits(content *string, embed *Embed) []string {
+	var changes []string
+	cut := func(what string, s *string, limit int) {
+		if n := utf8.RuneCountInString(*s); n > limit {
+			*s = truncate(*s, limit)
+			changes = append(changes, fmt.Sprintf("%s cut from %d to %d characters", what, n, limit))
+		}
+	}
+
+	cut("content", content, maxContent)
+	if embed == nil {
+		return changes
+	}
+	cut("title", &embed.Title, maxTitle)
+	cut("description", &embed.Description, maxDescription)
+	if embed.Author != nil {
+		cut("author name", &embed.Author.Name, maxAuthorName)
+	}
+	if embed.Footer != nil {
+		cut("footer", &embed.Footer.Text, maxFooter)
+	}
+	if n := len(embed.Fields); n > maxFields {
+		embed.Fields = embed.Fields[:maxFields]
+		changes = append(changes, fmt.Sprintf("dropped %d fields over the limit of %d", n-maxFields, maxFields))
+	}
+	for i := range embed.Fields {
+		cut(fmt.Sprintf("field %d name", i), &embed.Fields[i].Name, maxFieldName)
+		cut(fmt.Sprintf("field %d value", i), &embed.Fields[i].Value, maxFieldValue)
+	}
+
+	// the whole embed is limited too: drop fields from the end, then shorten the description
+	dropped := 0
+	for embedLength(embed) > maxEmbedTotal && len(embed.Fields) > 0 {
+		embed.Fields = embed.Fields[:len(embed.Fields)-1]
+		dropped++
+	}
+	if dropped > 0 {
+		changes = append(changes, fmt.Sprintf("dropped the last %d fields to fit the embed total of %d", dropped, maxEmbedTotal))
+	}
+	if over := embedLength(embed) - maxEmbedTotal; over > 0 {
+		cut("description (embed total)", &embed.Description, max(utf8.RuneCountInString(embed.Description)-over, 0))
+	}
+	return changes
+}
+
+// embedLength counts the characters Discord includes in the 6000 total
+func embedLength(e *Embed) int {
+	n := utf8.RuneCountInString(e.Title) + utf8.RuneCountInString(e.Description)
+	if e.Author != nil {
+		n += utf8.RuneCountInString(e.Author.Name)
+	}
+	if e.Footer != nil {
+		n += utf8.RuneCountInString(e.Footer.Text)
+	}
+	for _, f := range e.Fields {
+		n += utf8.RuneCountInString(f.Name) + utf8.RuneCountInString(f.Value)
+	}
+	return n
+}
+
+// truncate shortens s to limit characters, ending with an ellipsis
+func truncate(s string, limit int) string {
+	if limit <= 0 {
+		return ""
+	}
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	return string(runes[:limit-1]) + "…"
+}
@@ -0,0 +1,230 @@
+package discord
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Discord markdown the previews understand; anything else is shown as typed
+var (
+	mdTimestamp = regexp.MustCompile(`<t:(-?\d+)(?::[tTdDfFR])?>`)
+	mdLink      = regexp.MustCompile(`\[([^\]]+)\]\((https?://[^)\s]+)\)`)
+	mdBold      = regexp.MustCompile(`\*\*(.+?)\*\*`)
+	mdCode      = regexp.MustCompile("`([^`]+)`")
+)
+
+// ANSI escapes for the terminal preview
+const (
+	ansiReset     = "\x1b[0m"
+	ansiBold      = "\x1b[1m"
+	ansiDim       = "\x1b[2m"
+	ansiUnderline = "\x1b[4m"
+	ansiLink      = "\x1b[38;2;0;168;252m"
+	// closing only bold/dim, or underline and color, keeps enclosing styles
+	ansiNormal  = "\x1b[22m"
+	ansiLinkEnd = "\x1b[24;39m"
+)
+
+// previewTime renders Discord timestamp markup as UTC text, since a preview
+// has no viewer's timezone or clock to show it against
+func previewTime(s string) string {
+	return mdTimestamp.ReplaceAllStringFunc(s, func(m string) string {
+		sub := mdTimestamp.FindStringSubmatch(m)
+		unix, err := strconv.ParseInt(sub[1], 10, 64)
+		if err != nil {
+			return m
+		}
+		return time.Unix(unix, 0).UTC().Format("2006-01-02 15:04") + " UTC"
+	})
+}
+
+// embedRGB splits an embed color into its channels; Discord draws uncolored
+// embeds with a grey bar
+func embedRGB(color int) (r, g, b int) {
+	if color == 0 {
+		return 0x1e, 0x1f, 0x22
+	}
+	return color >> 16 & 0xff, color >> 8 & 0xff, color & 0xff
+}
+
+// RenderANSI draws a message as it would appear in Discord, for a terminal
+// with 24-bit color: the text, then the embed behind a bar in its color
+func RenderANSI(text string, embed *Embed) string {
+	var sb strings.Builder
+	if text != "" {
+		sb.WriteString(ansiMarkdown(text))
+		sb.WriteString("\n")
+	}
+	if embed == nil {
+		return sb.String()
+	}
+	r, g, b := embedRGB(embed.Color)
+	bar := fmt.Sprintf("\x1b[38;2;%d;%d;%dm▌%s ", r, g, b, ansiReset)
+	line := func(s string) {
+		for _, l := range strings.Split(s, "\n") {
+			sb.WriteString(bar + l + "\n")
+		}
+	}
+	if a := embed.Author; a != nil && a.Name != "" {
+		line(ansiBold + a.Name + ansiReset)
+	}
+	if embed.Title != "" {
+		title := ansiBold + ansiMarkdown(embed.Title) + ansiReset
+		if embed.URL != "" {
+			title = ansiLink + ansiUnderline + ansiBold + embed.Title + ansiReset + " " + ansiDim + embed.URL + ansiReset
+		}
+		line(title)
+	}
+	if embed.Description != "" {
+		line(ansiMarkdown(embed.Description))
+	}
+	for i := 0; i < len(embed.Fields); {
+		// Discord puts up to three inline fields side by side
+		row := []Field{embed.Fields[i]}
+		i++
+		for row[0].Inline && i < len(embed.Fields) && embed.Fields[i].Inline && len(row) < 3 {
+			row = append(row, embed.Fields[i])
+			i++
+		}
+		names := make([]string, len(row))
+		values := make([]string, len(row))
+		for j, f := range row {
+			names[j] = ansiBold + f.Name + ansiReset
+			values[j] = ansiMarkdown(f.Value)
+		}
+		line(strings.Join(names, "  │  "))
+		line(strings.Join(values, "  │  "))
+	}
+	if embed.Thumbnail != nil && embed.Thumbnail.URL != "" {
+		line(ansiDim + "thumbnail: " + embed.Thumbnail.URL + ansiReset)
+	}
+	footer := ""
+	if embed.Footer != nil {
+		footer = embed.Footer.Text
+	}
+	if embed.Timestamp != "" {
+		footer = strings.TrimPrefix(footer+" • "+embed.Timestamp, " • ")
+	}
+	if footer != "" {
+		line(ansiDim + footer + ansiReset)
+	}
+	return sb.String()
+}
+
+// ansiMarkdown turns the markdown a kill message uses into terminal escapes
+func ansiMarkdown(s string) string {
+	s = previewTime(s)
+	s = mdLink.ReplaceAllString(s, ansiLink+ansiUnderline+"$1"+ansiLinkEnd)
+	s = mdBold.ReplaceAllString(s, ansiBold+"$1"+ansiNormal)
+	s = mdCode.ReplaceAllString(s, ansiDim+"$1"+ansiNormal)
+	var lines []string
+	for _, l := range strings.Split(s, "\n") {
+		if rest, ok := strings.CutPrefix(l, "-# "); ok {
+			l = ansiDim + rest + ansiNormal
+		}
+		lines = append(lines, l)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// previewCSS approximates Discord's dark theme
+const previewCSS = `.ck-preview{background:#313338;color:#dbdee1;font:16px/1.375 "gg sans","Noto Sans","Helvetica Neue",Helvetica,Arial,sans-serif;padding:16px;max-width:560px}
+.ck-preview a{color:#00a8fc;text-decoration:none}
+.ck-preview .ck-text{white-space:pre-wrap;margin-bottom:4px}
+.ck-preview .ck-sub{font-size:12px;color:#949ba4}
+.ck-preview .ck-embed{background:#2b2d31;border-left:4px solid;border-radius:4px;padding:8px 16px 16px 12px;display:grid;grid-template-columns:auto min-content;gap:8px 16px}
+.ck-preview .ck-body{display:flex;flex-direction:column;gap:8px;min-width:0}
+.ck-preview .ck-author{font-size:14px;font-weight:600;display:flex;align-items:center;gap:8px}
+.ck-preview .ck-author img{width:24px;height:24px;border-radius:50%}
+.ck-preview .ck-title{font-weight:600}
+.ck-preview .ck-desc{font-size:14px;white-space:pre-wrap}
+.ck-preview .ck-fields{display:grid;grid-template-columns:repeat(3,1fr);gap:8px}
+.ck-preview .ck-field{font-size:14px;grid-column:1/-1}
+.ck-preview .ck-field.ck-inline{grid-column:auto}
+.ck-preview .ck-field b{display:block;font-weight:600}
+.ck-preview .ck-thumb img{max-width:80px;max-height:80px;border-radius:4px}
+.ck-preview .ck-footer{font-size:12px;color:#b5bac1}`
+
+// RenderHTML renders a message as a standalone HTML snippet that approximates
+// how Discord shows it
+func RenderHTML(text string, embed *Embed) string {
+	var sb strings.Builder
+	sb.WriteString("<style>\n" + previewCSS + "\n</style>\n<div class=\"ck-preview\">\n")
+	if text != "" {
+		fmt.Fprintf(&sb, "<div class=\"ck-text\">%s</div>\n", htmlMarkdown(text))
+	}
+	if embed != nil {
+		r, g, b := embedRGB(embed.Color)
+		fmt.Fprintf(&sb, "<div class=\"ck-embed\" style=\"border-left-color:#%02x%02x%02x\">\n<div class=\"ck-body\">\n", r, g, b)
+		if a := embed.Author; a != nil && a.Name != "" {
+			sb.WriteString("<div class=\"ck-author\">")
+			if a.IconURL != "" {
+				fmt.Fprintf(&sb, "<img src=\"%s\" alt=\"\">", html.EscapeString(a.IconURL))
+			}
+			sb.WriteString(htmlLink(a.Name, a.URL))
+			sb.WriteString("</div>\n")
+		}
+		if embed.Title != "" {
+			fmt.Fprintf(&sb, "<div class=\"ck-title\">%s</div>\n", htmlLink(embed.Title, embed.URL))
+		}
+		if embed.Description != "" {
+			fmt.Fprintf(&sb, "<div class=\"ck-desc\">%s</div>\n", htmlMarkdown(embed.Description))
+		}
+		if len(embed.Fields) > 0 {
+			sb.WriteString("<div class=\"ck-fields\">\n")
+			for _, f := range embed.Fields {
+				class := "ck-field"
+				if f.Inline {
+					class += " ck-inline"
+				}
+				fmt.Fprintf(&sb, "<div class=\"%s\"><b>%s</b>%s</div>\n", class, htmlMarkdown(f.Name), htmlMarkdown(f.Value))
+			}
+			sb.WriteString("</div>\n")
+		}
+		footer := ""
+		if embed.Footer != nil {
+			footer = embed.Footer.Text
+		}
+		if embed.Timestamp != "" {
+			footer = strings.TrimPrefix(footer+" • "+embed.Timestamp, " • ")
+		}
+		if footer != "" {
+			fmt.Fprintf(&sb, "<div class=\"ck-footer\">%s</div>\n", html.EscapeString(footer))
+		}
+		sb.WriteString("</div>\n")
+		if embed.Thumbnail != nil && embed.Thumbnail.URL != "" {
+			fmt.Fprintf(&sb, "<div class=\"ck-thumb\"><img src=\"%s\" alt=\"\"></div>\n", html.EscapeString(embed.Thumbnail.URL))
+		}
+		sb.WriteString("</div>\n")
+	}
+	sb.WriteString("</div>\n")
+	return sb.String()
+}
+
+// htmlLink escapes text and links it to url when there is one
+func htmlLink(text, url string) string {
+	if url == "" {
+		return html.EscapeString(text)
+	}
+	return fmt.Sprintf("<a href=\"%s\">%s</a>", html.EscapeString(url), html.EscapeString(text))
+}
+
+// htmlMarkdown escapes s and turns the markdown a kill message uses into HTML
+func htmlMarkdown(s string) string {
+	s = html.EscapeString(previewTime(s))
+	s = mdLink.ReplaceAllString(s, `<a href="$2">$1</a>`)
+	s = mdBold.ReplaceAllString(s, "<strong>$1</strong>")
+	s = mdCode.ReplaceAllString(s, "<code>$1</code>")
+	var lines []string
+	for _, l := range strings.Split(s, "\n") {
+		if rest, ok := strings.CutPrefix(l, "-# "); ok {
+			l = "<span class=\"ck-sub\">" + rest + "</span>"
+		}
+		lines = append(lines, l)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,52 @@
+package discord
+
+import (
+	"context"
+	"math"
+	"net/http"
+
+	"github.com/guarzo/eve-chainkills/internal/httpclient"
+	"github.com/guarzo/eve-chainkills/internal/lanes"
+)
+
+// Webhooks sends to Discord webhooks through one HTTP client, letting at most
+// a set number of requests run at once. A nil *Webhooks uses the default
+// client and never makes a send wait.
+type Webhooks struct {
+	client *http.Client
+	// sends is nil when sends aren't limited
+	sends *lanes.Gate
+}
+
+// NewWebhooks lets at most slots webhook requests run at once, taking waiting
+// ones by the priority set with lanes.WithPriority, so an urgent alert jumps
+// a backlog of routine posts. slots <= 0 lifts the limit; a nil client uses
+// the default one.
+func NewWebhooks(client *http.Client, slots, priorities int) *Webhooks {
+	return &Webhooks{client: client, sends: lanes.New(slots, priorities)}
+}
+
+// QueuedSends counts the webhook requests waiting at each priority
+func (w *Webhooks) QueuedSends() []int {
+	if w == nil {
+		return nil
+	}
+	return w.sends.Waiting()
+}
+
+// acquireSend waits for a send slot; untagged requests, e.g. status
+// messages, go last
+func (w *Webhooks) acquireSend(ctx context.Context) (func(), error) {
+	if w == nil {
+		return func() {}, nil
+	}
+	return w.sends.Acquire(ctx, lanes.Priority(ctx, math.MaxInt))
+}
+
+// Client returns the HTTP client the webhooks are sent through
+func (w *Webhooks) Client() *http.Client {
+	if w == nil {
+		return httpclient.Client()
+	}
+	return httpclient.Or(w.client)
+}
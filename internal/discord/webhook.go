@@ -0,0 +1,247 @@
+// Package discord sends messages and kill embeds to Discord webhooks.
+package discord
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+)
+
+// webhookBody is the shape for a basic message or embed
+type webhookBody struct {
+	Content string  `json:"content,omitempty"`
+	Embeds  []Embed `json:"embeds,omitempty"`
+}
+
+// MaxEmbeds is how many embeds Discord accepts in one message
+const MaxEmbeds = 10
+
+// SendWebhook sends either a text message or an embed, truncating anything
+// over Discord's limits and logging what was cut
+func (w *Webhooks) SendWebhook(ctx context.Context, logger logger.Logger, webhookID, webhookToken, textMessage string, embed *Embed) error {
+	var embeds []Embed
+	if embed != nil {
+		embeds = []Embed{*embed}
+	}
+	return w.SendWebhookEmbeds(ctx, logger, webhookID, webhookToken, textMessage, embeds)
+}
+
+// Posted identifies a message sent through a webhook
+type Posted struct {
+	ID        string `json:"id"`
+	ChannelID string `json:"channel_id"`
+	// GuildID is the server the webhook posts to; message responses leave it
+	// out, so PostWebhookMessage looks it up from the webhook
+	GuildID string `json:"guild_id"`
+}
+
+// URL links to the message in the Discord client, or is "" when a part is unknown
+func (p Posted) URL() string {
+	if p.GuildID == "" || p.ChannelID == "" || p.ID == "" {
+		return ""
+	}
+	return fmt.Sprintf("https://discord.com/channels/%s/%s/%s", p.GuildID, p.ChannelID, p.ID)
+}
+
+// PostWebhookMessage sends a text message and optional embed like SendWebhook
+// and returns where the message landed, so it can be edited or watched later
+func (w *Webhooks) PostWebhookMessage(ctx context.Context, logger logger.Logger, webhookID, webhookToken, textMessage string, embed *Embed) (Posted, error) {
+	var msg Posted
+	if webhookID == "" || webhookToken == "" {
+		return msg, fmt.Errorf("discord webhook not configured properly (ID/Token missing)")
+	}
+	body := fittedBody(logger, textMessage, embed)
+	url := fmt.Sprintf("https://discord.com/api/webhooks/%s/%s?wait=true", webhookID, webhookToken)
+	if err := w.doWebhook(ctx, http.MethodPost, url, body, &msg); err != nil {
+		return msg, err
+	}
+	if msg.GuildID == "" {
+		guildID, err := w.webhookGuild(ctx, webhookID, webhookToken)
+		if err != nil {
+			// the message was sent; only its link is missing
+			logger.Printf("Error looking up the server of discord webhook %s: %v", webhookID, err)
+		}
+		msg.GuildID = guildID
+	}
+	return msg, nil
+}
+
+// guilds caches each webhook's server ID; a webhook never moves servers
+var guilds sync.Map
+
+// webhookGuild returns the ID of the server a webhook posts to
+func (w *Webhooks) webhookGuild(ctx context.Context, webhookID, webhookToken string) (string, error) {
+	if id, ok := guilds.Load(webhookID); ok {
+		return id.(string), nil
+	}
+	url := fmt.Sprintf("https://discord.com/api/webhooks/%s/%s", webhookID, webhookToken)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := w.Client().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("discord webhook got status %d", resp.StatusCode)
+	}
+	var hook struct {
+		GuildID string `json:"guild_id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&hook); err != nil {
+		return "", err
+	}
+	guilds.Store(webhookID, hook.GuildID)
+	return hook.GuildID, nil
+}
+
+// EditWebhookMessage replaces the text and embed of a message the webhook posted
+func (w *Webhooks) EditWebhookMessage(ctx context.Context, logger logger.Logger, webhookID, webhookToken, messageID, textMessage string, embed Embed) error {
+	if webhookID == "" || webhookToken == "" {
+		return fmt.Errorf("discord webhook not configured properly (ID/Token missing)")
+	}
+	body := fittedBody(logger, textMessage, &embed)
+	url := fmt.Sprintf("https://discord.com/api/webhooks/%s/%s/messages/%s", webhookID, webhookToken, messageID)
+	return w.doWebhook(ctx, http.MethodPatch, url, body, nil)
+}
+
+// fittedBody is a message with at most one embed, cut to Discord's limits
+func fittedBody(logger logger.Logger, textMessage string, embed *Embed) webhookBody {
+	if embed == nil {
+		for _, change := range FitLimits(&textMessage, nil) {
+			logger.Warnf("Discord message over limits: %s", change)
+		}
+		return webhookBody{Content: textMessage}
+	}
+	e := *embed
+	e.Fields = append([]Field(nil), embed.Fields...)
+	if e.Author != nil {
+		author := *e.Author
+		e.Author = &author
+	}
+	if e.Footer != nil {
+		footer := *e.Footer
+		e.Footer = &footer
+	}
+	for _, change := range FitLimits(&textMessage, &e) {
+		logger.Warnf("Discord message over limits: %s", change)
+	}
+	return webhookBody{Content: textMessage, Embeds: []Embed{e}}
+}
+
+// SendWebhookEmbeds sends a text message with several embeds, split over as
+// many messages as Discord's per-message limits need; the text goes with the first
+func (w *Webhooks) SendWebhookEmbeds(ctx context.Context, logger logger.Logger, webhookID, webhookToken, textMessage string, embeds []Embed) error {
+	_, err := w.postEmbeds(ctx, logger, webhookID, webhookToken, textMessage, embeds, false)
+	return err
+}
+
+// PostWebhookEmbeds sends like SendWebhookEmbeds and returns where each embed
+// landed, by index
+func (w *Webhooks) PostWebhookEmbeds(ctx context.Context, logger logger.Logger, webhookID, webhookToken, textMessage string, embeds []Embed) ([]Posted, error) {
+	return w.postEmbeds(ctx, logger, webhookID, webhookToken, textMessage, embeds, true)
+}
+
+func (w *Webhooks) postEmbeds(ctx context.Context, logger logger.Logger, webhookID, webhookToken, textMessage string, embeds []Embed, wait bool) ([]Posted, error) {
+	if webhookID == "" || webhookToken == "" {
+		return nil, fmt.Errorf("discord webhook not configured properly (ID/Token missing)")
+	}
+	for _, change := range FitLimits(&textMessage, nil) {
+		logger.Warnf("Discord message over limits: %s", change)
+	}
+	// work on copies so the caller's embeds are untouched
+	fitted := make([]Embed, len(embeds))
+	for i, embed := range embeds {
+		e := embed
+		e.Fields = append([]Field(nil), embed.Fields...)
+		if e.Author != nil {
+			author := *e.Author
+			e.Author = &author
+		}
+		if e.Footer != nil {
+			footer := *e.Footer
+			e.Footer = &footer
+		}
+		var none string
+		for _, change := range FitLimits(&none, &e) {
+			logger.Warnf("Discord message over limits: %s", change)
+		}
+		fitted[i] = e
+	}
+
+	url := fmt.Sprintf("https://discord.com/api/webhooks/%s/%s", webhookID, webhookToken)
+	if wait {
+		url += "?wait=true"
+	}
+	var posted []Posted
+	post := func(body webhookBody) error {
+		if !wait {
+			return w.doWebhook(ctx, http.MethodPost, url, body, nil)
+		}
+		var msg Posted
+		if err := w.doWebhook(ctx, http.MethodPost, url, body, &msg); err != nil {
+			return err
+		}
+		if msg.GuildID == "" {
+			msg.GuildID, _ = w.webhookGuild(ctx, webhookID, webhookToken)
+		}
+		for range body.Embeds {
+			posted = append(posted, msg)
+		}
+		return nil
+	}
+	body := webhookBody{Content: textMessage}
+	total := 0
+	for _, e := range fitted {
+		// the embed total applies to all embeds of a message together
+		if n := embedLength(&e); len(body.Embeds) == MaxEmbeds || (len(body.Embeds) > 0 && total+n > maxEmbedTotal) {
+			if err := post(body); err != nil {
+				return posted, err
+			}
+			body, total = webhookBody{}, 0
+		}
+		body.Embeds = append(body.Embeds, e)
+		total += embedLength(&e)
+	}
+	return posted, post(body)
+}
+
+// doWebhook sends body to a webhook URL, decoding the response into out when it isn't nil
+func (w *Webhooks) doWebhook(ctx context.Context, method, url string, body webhookBody, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewBuffer(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	release, err := w.acquireSend(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	resp, err := w.Client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("discord webhook got status %d", resp.StatusCode)
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
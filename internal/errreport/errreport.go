@@ -0,0 +1,242 @@
+// Package errreport forwards errors and panics to Sentry and/or a generic
+// JSON webhook, so broken notifications surface somewhere other than the log.
+// Repeats of the same error are folded into one report per window.
+package errreport
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/internal/httpclient"
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+)
+
+// Config selects where errors are reported; with neither set, reporting is off
+type Config struct {
+	SentryDSN   string `json:"sentryDsn"`
+	WebhookURL  string `json:"webhookUrl"`
+	Environment string `json:"environment"`
+	// RepeatWindowMinutes is how long repeats of one error are folded into a single report (default 60)
+	RepeatWindowMinutes int `json:"repeatWindowMinutes"`
+	// HTTPClient sends the reports; nil uses the default client
+	HTTPClient *http.Client `json:"-"`
+}
+
+// Event is one error or panic, also the JSON body sent to WebhookURL
+type Event struct {
+	Message     string            `json:"message"`
+	Panic       bool              `json:"panic"`
+	Stack       string            `json:"stack,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Occurrences int               `json:"occurrences"` // times seen since the last report
+	Environment string            `json:"environment,omitempty"`
+	Timestamp   time.Time         `json:"timestamp"`
+}
+
+// Reporter sends events. A nil *Reporter is valid and does nothing.
+type Reporter struct {
+	logger logger.Logger
+	config Config
+	sentry *sentryDSN
+	window time.Duration
+
+	mu   sync.Mutex
+	seen map[string]*seenError
+}
+
+type seenError struct {
+	lastSent time.Time
+	count    int
+}
+
+type sentryDSN struct {
+	endpoint  string
+	publicKey string
+}
+
+// New returns nil when neither Sentry nor a webhook is configured
+func New(logger logger.Logger, config Config) (*Reporter, error) {
+	if config.SentryDSN == "" && config.WebhookURL == "" {
+		return nil, nil
+	}
+	r := &Reporter{
+		logger: logger,
+		config: config,
+		window: time.Duration(config.RepeatWindowMinutes) * time.Minute,
+		seen:   map[string]*seenError{},
+	}
+	if r.window <= 0 {
+		r.window = time.Hour
+	}
+	if config.SentryDSN != "" {
+		dsn, err := parseSentryDSN(config.SentryDSN)
+		if err != nil {
+			return nil, err
+		}
+		r.sentry = dsn
+	}
+	return r, nil
+}
+
+// parseSentryDSN turns https://key@host/[path/]project into the envelope endpoint
+func parseSentryDSN(raw string) (*sentryDSN, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("parse sentry dsn: %w", err)
+	}
+	if u.User == nil || u.User.Username() == "" {
+		return nil, fmt.Errorf("sentry dsn has no public key")
+	}
+	path := strings.Trim(u.Path, "/")
+	idx := strings.LastIndex(path, "/")
+	prefix, project := "", path
+	if idx >= 0 {
+		prefix, project = "/"+path[:idx], path[idx+1:]
+	}
+	if project == "" {
+		return nil, fmt.Errorf("sentry dsn has no project id")
+	}
+	return &sentryDSN{
+		endpoint:  fmt.Sprintf("%s://%s%s/api/%s/envelope/", u.Scheme, u.Host, prefix, project),
+		publicKey: u.User.Username(),
+	}, nil
+}
+
+// Error reports err, tagged e.g. with the kill ID and pipeline stage
+func (r *Reporter) Error(ctx context.Context, err error, tags map[string]string) {
+	if r == nil || err == nil {
+		return
+	}
+	r.report(ctx, Event{Message: err.Error(), Tags: tags})
+}
+
+// Panic reports a recovered panic along with its stack
+func (r *Reporter) Panic(ctx context.Context, recovered interface{}, stack []byte, tags map[string]string) {
+	if r == nil {
+		return
+	}
+	r.report(ctx, Event{Message: fmt.Sprintf("panic: %v", recovered), Panic: true, Stack: string(stack), Tags: tags})
+}
+
+// digits are stripped when grouping, so the same failure on different kills is one error
+var digits = regexp.MustCompile(`[0-9]+`)
+
+func (r *Reporter) report(ctx context.Context, ev Event) {
+	key := ev.Tags["stage"] + "|" + ev.Tags["sink"] + "|" + digits.ReplaceAllString(ev.Message, "N")
+
+	r.mu.Lock()
+	se, ok := r.seen[key]
+	if !ok {
+		se = &seenError{}
+		r.seen[key] = se
+	}
+	se.count++
+	if ok && time.Since(se.lastSent) < r.window {
+		r.mu.Unlock()
+		return
+	}
+	ev.Occurrences = se.count
+	se.count = 0
+	se.lastSent = time.Now()
+	r.mu.Unlock()
+
+	ev.Environment = r.config.Environment
+	ev.Timestamp = time.Now().UTC()
+	// don't let a cancelled kill stop its own error report
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+
+	if r.sentry != nil {
+		if err := r.sendSentry(ctx, ev); err != nil {
+			r.logger.Printf("Error reporting to sentry: %v", err)
+		}
+	}
+	if r.config.WebhookURL != "" {
+		if err := r.sendWebhook(ctx, ev); err != nil {
+			r.logger.Printf("Error reporting to error webhook: %v", err)
+		}
+	}
+}
+
+func (r *Reporter) sendWebhook(ctx context.Context, ev Event) error {
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	return r.post(ctx, r.config.WebhookURL, "application/json", nil, payload)
+}
+
+func (r *Reporter) sendSentry(ctx context.Context, ev Event) error {
+	var id [16]byte
+	_, _ = rand.Read(id[:])
+	eventID := hex.EncodeToString(id[:])
+	host, _ := os.Hostname()
+
+	extra := map[string]interface{}{"occurrences": ev.Occurrences}
+	if ev.Stack != "" {
+		extra["stack"] = ev.Stack
+	}
+	level := "error"
+	if ev.Panic {
+		level = "fatal"
+	}
+	event := map[string]interface{}{
+		"event_id":    eventID,
+		"timestamp":   float64(ev.Timestamp.UnixNano()) / 1e9,
+		"platform":    "go",
+		"level":       level,
+		"logger":      "eve-chainkills",
+		"server_name": host,
+		"environment": ev.Environment,
+		"message":     map[string]string{"formatted": ev.Message},
+		"tags":        ev.Tags,
+		"extra":       extra,
+	}
+	eventJSON, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	fmt.Fprintf(&body, `{"event_id":%q,"sent_at":%q}`+"\n", eventID, ev.Timestamp.Format(time.RFC3339))
+	fmt.Fprintf(&body, `{"type":"event","length":%d}`+"\n", len(eventJSON))
+	body.Write(eventJSON)
+	body.WriteByte('\n')
+
+	auth := fmt.Sprintf("Sentry sentry_version=7, sentry_key=%s, sentry_client=eve-chainkills/1.0", r.sentry.publicKey)
+	return r.post(ctx, r.sentry.endpoint, "application/x-sentry-envelope",
+		map[string]string{"X-Sentry-Auth": auth}, body.Bytes())
+}
+
+func (r *Reporter) post(ctx context.Context, endpoint, contentType string, headers map[string]string, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewBuffer(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	client := httpclient.Or(r.config.HTTPClient)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("got status %d", resp.StatusCode)
+	}
+	return nil
+}
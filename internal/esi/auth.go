@@ -0,0 +1,189 @@
+package esi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/guarzo/eve-chainkills/internal/schema"
+	"github.com/guarzo/eve-chainkills/pkg/killmail"
+)
+
+// ErrNotAuthenticated is returned, wrapped, when no signed-in character has
+// the scope an endpoint needs
+var ErrNotAuthenticated = errors.New("no signed-in character has the scope")
+
+// Authenticator supplies EVE SSO access tokens for endpoints that need them
+type Authenticator interface {
+	// CharacterWithScope returns a signed-in character whose token was granted scope
+	CharacterWithScope(scope string) (int64, bool)
+	AccessToken(ctx context.Context, characterID int64) (string, error)
+}
+
+// SetAuth enables authenticated endpoints
+func (c *Client) SetAuth(auth Authenticator) {
+	c.auth = auth
+}
+
+// authCharacter picks the signed-in character to make calls needing scope as
+func (c *Client) authCharacter(endpoint, scope string) (int64, error) {
+	if c.auth == nil {
+		return 0, fmt.Errorf("%s: %w %s (EVE SSO is not configured)", endpoint, ErrNotAuthenticated, scope)
+	}
+	characterID, ok := c.auth.CharacterWithScope(scope)
+	if !ok {
+		return 0, fmt.Errorf("%s: %w %s", endpoint, ErrNotAuthenticated, scope)
+	}
+	return characterID, nil
+}
+
+// doAuthGetRequest issues a GET to an ESI path as a signed-in character
+func (c *Client) doAuthGetRequest(ctx context.Context, characterID int64, endpoint, path string) (*http.Response, error) {
+	token, err := c.auth.AccessToken(ctx, characterID)
+	if err != nil {
+		return nil, err
+	}
+	return c.do(ctx, http.MethodGet, endpoint, "https://esi.evetech.net/latest"+path, nil, token)
+}
+
+// isStructureID reports whether a zKillboard locationID is an Upwell
+// structure rather than a celestial; structure IDs start at 10^12
+func isStructureID(id int64) bool {
+	return id >= 1_000_000_000_000
+}
+
+// Structure looks up the name and position of an Upwell structure. It needs a
+// character with esi-universe.read_structures.v1 that has docking access.
+// systemID is unused; it matches Celestial's signature.
+func (c *Client) Structure(ctx context.Context, systemID int, structureID int64) (string, killmail.Position, error) {
+	characterID, err := c.authCharacter("universe/structures", "esi-universe.read_structures.v1")
+	if err != nil {
+		return "", killmail.Position{}, err
+	}
+	path := fmt.Sprintf("/universe/structures/%d/?datasource=tranquility", structureID)
+	resp, err := c.doAuthGetRequest(ctx, characterID, "universe/structures", path)
+	if err != nil {
+		return "", killmail.Position{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", killmail.Position{}, fmt.Errorf("fetchStructure got status %d", resp.StatusCode)
+	}
+
+	var st struct {
+		Name     string            `json:"name"`
+		Position killmail.Position `json:"position"`
+	}
+	if err = schema.DecodeReader("esi universe/structures", resp.Body, &st); err != nil {
+		return "", killmail.Position{}, err
+	}
+	return st.Name, st.Position, nil
+}
+
+// CorporationMembers lists the character IDs in the corporation of a
+// signed-in character granted esi-corporations.read_corporation_membership.v1,
+// who needs the director role
+func (c *Client) CorporationMembers(ctx context.Context) (int, []int64, error) {
+	characterID, err := c.authCharacter("corporations/members", "esi-corporations.read_corporation_membership.v1")
+	if err != nil {
+		return 0, nil, err
+	}
+	corpID, err := c.CharacterCorporation(ctx, characterID)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	path := fmt.Sprintf("/corporations/%d/members/?datasource=tranquility", corpID)
+	resp, err := c.doAuthGetRequest(ctx, characterID, "corporations/members", path)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return 0, nil, fmt.Errorf("fetchCorporationMembers got status %d", resp.StatusCode)
+	}
+
+	var members []int64
+	if err = schema.DecodeReader("esi corporations/members", resp.Body, &members); err != nil {
+		return 0, nil, err
+	}
+	return corpID, members, nil
+}
+
+// FleetMembers lists the character IDs in the fleet of a signed-in character
+// granted esi-fleets.read_fleet.v1, who has to be the fleet boss. fleetID is 0,
+// with no error, when the character isn't in a fleet.
+func (c *Client) FleetMembers(ctx context.Context) (int64, []int64, error) {
+	characterID, err := c.authCharacter("fleets/members", "esi-fleets.read_fleet.v1")
+	if err != nil {
+		return 0, nil, err
+	}
+
+	path := fmt.Sprintf("/characters/%d/fleet/?datasource=tranquility", characterID)
+	resp, err := c.doAuthGetRequest(ctx, characterID, "characters/fleet", path)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return 0, nil, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return 0, nil, fmt.Errorf("fetchCharacterFleet got status %d", resp.StatusCode)
+	}
+	var fleet struct {
+		FleetID int64 `json:"fleet_id"`
+	}
+	if err = schema.DecodeReader("esi characters/fleet", resp.Body, &fleet); err != nil {
+		return 0, nil, err
+	}
+
+	path = fmt.Sprintf("/fleets/%d/members/?datasource=tranquility", fleet.FleetID)
+	resp, err = c.doAuthGetRequest(ctx, characterID, "fleets/members", path)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fleet.FleetID, nil, fmt.Errorf("fetchFleetMembers got status %d; only the fleet boss can list members", resp.StatusCode)
+	}
+	var members []struct {
+		CharacterID int64 `json:"character_id"`
+	}
+	if err = schema.DecodeReader("esi fleets/members", resp.Body, &members); err != nil {
+		return fleet.FleetID, nil, err
+	}
+	ids := make([]int64, 0, len(members))
+	for _, m := range members {
+		ids = append(ids, m.CharacterID)
+	}
+	return fleet.FleetID, ids, nil
+}
+
+// SetWaypoint sets a signed-in character's autopilot destination, clearing
+// its other waypoints. The character needs esi-ui.write_waypoint.v1 and has
+// to be logged in to the game for the route to show.
+func (c *Client) SetWaypoint(ctx context.Context, characterID int64, destinationID int) error {
+	if c.auth == nil {
+		return fmt.Errorf("ui/autopilot/waypoint: %w esi-ui.write_waypoint.v1 (EVE SSO is not configured)", ErrNotAuthenticated)
+	}
+	token, err := c.auth.AccessToken(ctx, characterID)
+	if err != nil {
+		return err
+	}
+	url := fmt.Sprintf("https://esi.evetech.net/latest/ui/autopilot/waypoint/?datasource=tranquility"+
+		"&add_to_beginning=false&clear_other_waypoints=true&destination_id=%d", destinationID)
+	resp, err := c.do(ctx, http.MethodPost, "ui/autopilot/waypoint", url, nil, token)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusForbidden {
+		return fmt.Errorf("ui/autopilot/waypoint: %w esi-ui.write_waypoint.v1", ErrNotAuthenticated)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("setWaypoint got status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,672 @@
+// Package esi looks up killmails and names from EVE's ESI API.
+package esi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/guarzo/eve-chainkills/internal/httpclient"
+	"github.com/guarzo/eve-chainkills/internal/schema"
+	"github.com/guarzo/eve-chainkills/internal/tracing"
+	"github.com/guarzo/eve-chainkills/pkg/killmail"
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+	"golang.org/x/exp/slices"
+)
+
+// Client talks to ESI on tranquility
+type Client struct {
+	logger logger.Logger
+	// http is nil for the default client
+	http *http.Client
+	// auth is nil unless EVE SSO is configured
+	auth Authenticator
+
+	requests atomic.Int64
+	failures atomic.Int64
+
+	lookups lookups
+
+	// regionMu guards the constellation and region lookups, which never change
+	regionMu       sync.Mutex
+	constellations map[int]int
+	regionNames    map[int]string
+}
+
+// NewClient constructor
+func NewClient(logger logger.Logger) *Client {
+	return &Client{logger: logger}
+}
+
+// SetHTTPClient sends requests through client instead of the default one
+func (c *Client) SetHTTPClient(client *http.Client) {
+	c.http = client
+}
+
+// Stats returns the number of ESI requests made and how many of them failed
+func (c *Client) Stats() (requests, failures int64) {
+	return c.requests.Load(), c.failures.Load()
+}
+
+// GetKillDetails merges the data from zKill + ESI into a FlattenedKillMail.
+// On error the returned kill still holds whatever could be resolved.
+func (c *Client) GetKillDetails(ctx context.Context, raw []byte) (killmail.FlattenedKillMail, error) {
+	var fkm killmail.FlattenedKillMail
+	var zm killmail.ZkillMail
+	if err := json.Unmarshal(raw, &zm); err != nil {
+		return fkm, fmt.Errorf("unmarshal zkill message: %w", err)
+	}
+	if err := zm.Validate(); err != nil {
+		return fkm, fmt.Errorf("invalid zkill message: %w", err)
+	}
+
+	fkm.KillMailID = zm.KillmailID
+	fkm.SolarSystemID = zm.SolarSystemID
+
+	if fkm.KillMailID == 0 || zm.ZKB.Hash == "" {
+		c.logger.Printf("No killmail_id or hash found in zkill message.")
+		return fkm, nil
+	}
+	fkm.Hash = zm.ZKB.Hash
+
+	km, err := c.Killmail(ctx, fkm.KillMailID, fkm.Hash)
+	if err != nil {
+		return fkm, err
+	}
+	if err := km.Validate(); err != nil {
+		return fkm, fmt.Errorf("invalid ESI killmail %d: %w", fkm.KillMailID, err)
+	}
+	if km.KillMailID != fkm.KillMailID {
+		return fkm, fmt.Errorf("ESI returned killmail %d for %d", km.KillMailID, fkm.KillMailID)
+	}
+
+	fkm.KillMailTime = km.KillMailTime
+	fkm.SolarSystemID = km.SolarSystemID
+	fkm.Victim = km.Victim
+	fkm.Attackers = km.Attackers
+
+	fkm.TotalValue = zm.ZKB.TotalValue
+	fkm.DestroyedValue = zm.ZKB.DestroyedValue
+	fkm.DroppedValue = zm.ZKB.DroppedValue
+	fkm.FittedValue = zm.ZKB.FittedValue
+	fkm.Points = zm.ZKB.Points
+	fkm.NPC = zm.ZKB.NPC
+	fkm.Solo = zm.ZKB.Solo
+	fkm.Awox = zm.ZKB.Awox
+
+	if fkm.SolarSystemID > 0 {
+		sysName, sysErr := c.SystemName(ctx, fkm.SolarSystemID)
+		if sysErr != nil {
+			c.logger.Printf("Error fetching system name: %v", sysErr)
+		} else {
+			fkm.SystemName = sysName
+		}
+	}
+
+	// Abyssal pockets are gone by the time ESI could describe their celestials
+	if zm.ZKB.LocationID > 0 && fkm.Victim.Position != nil && !killmail.IsAbyssal(fkm.SolarSystemID) {
+		lookup := c.Celestial
+		if isStructureID(zm.ZKB.LocationID) && c.auth != nil {
+			lookup = c.Structure
+		}
+		name, pos, celErr := lookup(ctx, fkm.SolarSystemID, zm.ZKB.LocationID)
+		if celErr != nil {
+			c.logger.Printf("Error fetching nearest celestial: %v", celErr)
+		} else {
+			fkm.NearestCelestial = name
+			fkm.CelestialDistance = fkm.Victim.Position.Distance(pos)
+		}
+	}
+
+	if fkm.Victim.ShipTypeID > 0 {
+		vsn, groupID, vsnErr := c.TypeInfo(ctx, fkm.Victim.ShipTypeID)
+		if vsnErr != nil {
+			c.logger.Printf("Error fetching victim ship name: %v", vsnErr)
+		} else {
+			fkm.VictimShipName = vsn
+			fkm.VictimShipGroupID = groupID
+		}
+	}
+
+	finalIdx := -1
+	for i, att := range km.Attackers {
+		if att.FinalBlow {
+			finalIdx = i
+			break
+		}
+	}
+	if finalIdx < 0 && len(km.Attackers) > 0 {
+		finalIdx = 0 // fallback
+	}
+	if finalIdx >= 0 {
+		final := km.Attackers[finalIdx]
+		fkm.FinalAttackerID = final.CharacterID
+		fkm.FinalAttackerCorpID = final.CorporationID
+		fkm.FinalAttackerAllianceID = final.AllianceID
+
+		if final.CharacterID > 0 {
+			attName, _ := c.CharacterName(ctx, final.CharacterID)
+			fkm.FinalAttackerName = attName
+		}
+
+		if fkm.FinalAttackerCorpID > 0 {
+			corpName, _ := c.CorporationName(ctx, final.CorporationID)
+			fkm.FinalAttackerCorpName = corpName
+		}
+
+		if fkm.FinalAttackerAllianceID > 0 {
+			alliName, _ := c.AllianceName(ctx, final.AllianceID)
+			fkm.FinalAttackerAllianceName = alliName
+		}
+
+		if final.ShipTypeID > 0 {
+			attShipName, _ := c.TypeName(ctx, final.ShipTypeID)
+			fkm.FinalAttackerShipName = attShipName
+		}
+	}
+
+	if fkm.Victim.CharacterID > 0 {
+		name, errName := c.CharacterName(ctx, fkm.Victim.CharacterID)
+		if errName != nil {
+			c.logger.Printf("Error fetching victim name: %v", errName)
+		} else {
+			fkm.VictimCharacterName = name
+		}
+	}
+
+	if fkm.Victim.CorporationID > 0 {
+		corpName, _ := c.CorporationName(ctx, fkm.Victim.CorporationID)
+		fkm.VictimCorpName = corpName
+	}
+
+	if fkm.Victim.AllianceID > 0 {
+		alliName, _ := c.AllianceName(ctx, fkm.Victim.AllianceID)
+		fkm.VictimAllianceName = alliName
+	}
+
+	c.logger.Printf("Fetched kill details, system=%s, victimShip=%s", fkm.SystemName, fkm.VictimShipName)
+	return fkm, nil
+}
+
+// Killmail fetches the full killmail for an ID + hash pair
+func (c *Client) Killmail(ctx context.Context, killmailID int64, hash string) (killmail.EsiKillMail, error) {
+	// every instance enriches the same kill at about the same time
+	return lookup(ctx, &c.lookups, fmt.Sprintf("killmails/%d/%s", killmailID, hash), killmailTTL,
+		func(ctx context.Context) (killmail.EsiKillMail, error) { return c.fetchKillmail(ctx, killmailID, hash) })
+}
+
+func (c *Client) fetchKillmail(ctx context.Context, killmailID int64, hash string) (killmail.EsiKillMail, error) {
+	var km killmail.EsiKillMail
+	killmailURL := fmt.Sprintf("https://esi.evetech.net/latest/killmails/%d/%s/?datasource=tranquility",
+		killmailID, hash)
+	resp, err := c.doGetRequest(ctx, "killmails", killmailURL)
+	if err != nil {
+		return km, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return km, fmt.Errorf("ESI killmail returned status %d", resp.StatusCode)
+	}
+
+	if err = schema.DecodeReader("esi killmails", resp.Body, &km); err != nil {
+		return km, fmt.Errorf("JSON decode error: %w", err)
+	}
+	return km, nil
+}
+
+// CharacterName queries ESI for character info, returns its name.
+func (c *Client) CharacterName(ctx context.Context, charID int64) (string, error) {
+	ch, err := c.character(ctx, charID)
+	return ch.Name, err
+}
+
+// CharacterCorporation returns the corporation a character is in
+func (c *Client) CharacterCorporation(ctx context.Context, charID int64) (int, error) {
+	ch, err := c.character(ctx, charID)
+	return ch.CorporationID, err
+}
+
+func (c *Client) character(ctx context.Context, charID int64) (killmail.EsiCharacterResponse, error) {
+	return lookup(ctx, &c.lookups, fmt.Sprintf("characters/%d", charID), characterTTL,
+		func(ctx context.Context) (killmail.EsiCharacterResponse, error) { return c.fetchCharacter(ctx, charID) })
+}
+
+func (c *Client) fetchCharacter(ctx context.Context, charID int64) (killmail.EsiCharacterResponse, error) {
+	var ch killmail.EsiCharacterResponse
+	url := fmt.Sprintf("https://esi.evetech.net/latest/characters/%d/?datasource=tranquility", charID)
+	resp, err := c.doGetRequest(ctx, "characters", url)
+	if err != nil {
+		return ch, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return ch, fmt.Errorf("bad status %d", resp.StatusCode)
+	}
+	err = schema.DecodeReader("esi characters", resp.Body, &ch)
+	return ch, err
+}
+
+// CorporationName queries ESI for corporation info, returns its name.
+func (c *Client) CorporationName(ctx context.Context, corpID int) (string, error) {
+	name, _, err := c.CorporationInfo(ctx, corpID)
+	return name, err
+}
+
+// CorporationInfo queries ESI for corporation info, returns its name and ticker.
+func (c *Client) CorporationInfo(ctx context.Context, corpID int) (string, string, error) {
+	info, err := lookup(ctx, &c.lookups, fmt.Sprintf("corporations/%d", corpID), entityTTL,
+		func(ctx context.Context) ([2]string, error) {
+			name, ticker, err := c.fetchCorporation(ctx, corpID)
+			return [2]string{name, ticker}, err
+		})
+	return info[0], info[1], err
+}
+
+func (c *Client) fetchCorporation(ctx context.Context, corpID int) (string, string, error) {
+	url := fmt.Sprintf("https://esi.evetech.net/latest/corporations/%d/?datasource=tranquility", corpID)
+	resp, err := c.doGetRequest(ctx, "corporations", url)
+	if err != nil {
+		return "", "", fmt.Errorf("fetchCorporationName: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", "", fmt.Errorf("fetchCorporationName got status %d", resp.StatusCode)
+	}
+
+	var corp struct {
+		Name   string `json:"name"`
+		Ticker string `json:"ticker"`
+	}
+	if err = schema.DecodeReader("esi corporations", resp.Body, &corp); err != nil {
+		return "", "", fmt.Errorf("JSON decode error (corp): %w", err)
+	}
+
+	return corp.Name, corp.Ticker, nil
+}
+
+// AllianceName queries ESI for alliance info, returns its name.
+func (c *Client) AllianceName(ctx context.Context, allianceID int) (string, error) {
+	name, _, err := c.AllianceInfo(ctx, allianceID)
+	return name, err
+}
+
+// AllianceInfo queries ESI for alliance info, returns its name and ticker.
+func (c *Client) AllianceInfo(ctx context.Context, allianceID int) (string, string, error) {
+	info, err := lookup(ctx, &c.lookups, fmt.Sprintf("alliances/%d", allianceID), entityTTL,
+		func(ctx context.Context) ([2]string, error) {
+			name, ticker, err := c.fetchAlliance(ctx, allianceID)
+			return [2]string{name, ticker}, err
+		})
+	return info[0], info[1], err
+}
+
+func (c *Client) fetchAlliance(ctx context.Context, allianceID int) (string, string, error) {
+	url := fmt.Sprintf("https://esi.evetech.net/latest/alliances/%d/?datasource=tranquility", allianceID)
+	resp, err := c.doGetRequest(ctx, "alliances", url)
+	if err != nil {
+		return "", "", fmt.Errorf("fetchAllianceName: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", "", fmt.Errorf("fetchAllianceName got status %d", resp.StatusCode)
+	}
+
+	var alli struct {
+		Name   string `json:"name"`
+		Ticker string `json:"ticker"`
+	}
+	if err = schema.DecodeReader("esi alliances", resp.Body, &alli); err != nil {
+		return "", "", fmt.Errorf("JSON decode error (alliance): %w", err)
+	}
+
+	return alli.Name, alli.Ticker, nil
+}
+
+// Names resolves up to 1000 IDs of any kind (types, corporations, alliances, ...) in one request
+func (c *Client) Names(ctx context.Context, ids []int) (map[int]string, error) {
+	payload, err := json.Marshal(ids)
+	if err != nil {
+		return nil, err
+	}
+	url := "https://esi.evetech.net/latest/universe/names/?datasource=tranquility"
+	resp, err := c.doRequest(ctx, http.MethodPost, "universe/names", url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("fetchNames got status %d", resp.StatusCode)
+	}
+
+	var entries []struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	if err = schema.DecodeReader("esi universe/names", resp.Body, &entries); err != nil {
+		return nil, err
+	}
+	names := make(map[int]string, len(entries))
+	for _, e := range entries {
+		names[e.ID] = e.Name
+	}
+	return names, nil
+}
+
+// TypeName queries ESI for an inventory type, returns its name.
+func (c *Client) TypeName(ctx context.Context, typeID int) (string, error) {
+	name, _, err := c.TypeInfo(ctx, typeID)
+	return name, err
+}
+
+// TypeInfo returns a type's name and the inventory group it belongs to
+func (c *Client) TypeInfo(ctx context.Context, typeID int) (string, int, error) {
+	type info struct {
+		name    string
+		groupID int
+	}
+	ti, err := lookup(ctx, &c.lookups, fmt.Sprintf("types/%d", typeID), foreverTTL,
+		func(ctx context.Context) (info, error) {
+			name, groupID, err := c.fetchType(ctx, typeID)
+			return info{name, groupID}, err
+		})
+	return ti.name, ti.groupID, err
+}
+
+func (c *Client) fetchType(ctx context.Context, typeID int) (string, int, error) {
+	url := fmt.Sprintf("https://esi.evetech.net/latest/universe/types/%d/?datasource=tranquility", typeID)
+	resp, err := c.doGetRequest(ctx, "universe/types", url)
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", 0, fmt.Errorf("bad status %d", resp.StatusCode)
+	}
+	var body struct {
+		Name    string `json:"name"`
+		GroupID int    `json:"group_id"`
+	}
+	if err = schema.DecodeReader("esi universe/types", resp.Body, &body); err != nil {
+		return "", 0, err
+	}
+	return body.Name, body.GroupID, nil
+}
+
+// SystemName queries ESI for a solar system, returns its name. Abyssal
+// pockets aren't in ESI and are all called killmail.AbyssalSystemName.
+func (c *Client) SystemName(ctx context.Context, systemID int) (string, error) {
+	if killmail.IsAbyssal(systemID) {
+		return killmail.AbyssalSystemName, nil
+	}
+	return lookup(ctx, &c.lookups, fmt.Sprintf("systems/%d", systemID), foreverTTL,
+		func(ctx context.Context) (string, error) { return c.fetchSystemName(ctx, systemID) })
+}
+
+func (c *Client) fetchSystemName(ctx context.Context, systemID int) (string, error) {
+	url := fmt.Sprintf("https://esi.evetech.net/latest/universe/systems/%d/?datasource=tranquility", systemID)
+	resp, err := c.doGetRequest(ctx, "universe/systems", url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("fetchSystemName got status %d", resp.StatusCode)
+	}
+
+	var sys struct {
+		Name string `json:"name"`
+	}
+	if err = schema.DecodeReader("esi universe/systems", resp.Body, &sys); err != nil {
+		return "", err
+	}
+	return sys.Name, nil
+}
+
+// SystemRegion returns the ID and name of the region a solar system is in
+func (c *Client) SystemRegion(ctx context.Context, systemID int) (int, string, error) {
+	if killmail.IsAbyssal(systemID) {
+		return 0, "", fmt.Errorf("system %d is an Abyssal pocket, outside any region", systemID)
+	}
+	var sys struct {
+		ConstellationID int `json:"constellation_id"`
+	}
+	url := fmt.Sprintf("https://esi.evetech.net/latest/universe/systems/%d/?datasource=tranquility", systemID)
+	if err := c.getJSON(ctx, "universe/systems", url, &sys); err != nil {
+		return 0, "", err
+	}
+
+	c.regionMu.Lock()
+	regionID, ok := c.constellations[sys.ConstellationID]
+	c.regionMu.Unlock()
+	if !ok {
+		var con struct {
+			RegionID int `json:"region_id"`
+		}
+		url = fmt.Sprintf("https://esi.evetech.net/latest/universe/constellations/%d/?datasource=tranquility", sys.ConstellationID)
+		if err := c.getJSON(ctx, "universe/constellations", url, &con); err != nil {
+			return 0, "", err
+		}
+		regionID = con.RegionID
+	}
+
+	c.regionMu.Lock()
+	name, ok := c.regionNames[regionID]
+	c.regionMu.Unlock()
+	if !ok {
+		var reg struct {
+			Name string `json:"name"`
+		}
+		url = fmt.Sprintf("https://esi.evetech.net/latest/universe/regions/%d/?datasource=tranquility", regionID)
+		if err := c.getJSON(ctx, "universe/regions", url, &reg); err != nil {
+			return 0, "", err
+		}
+		name = reg.Name
+	}
+
+	c.regionMu.Lock()
+	if c.constellations == nil {
+		c.constellations, c.regionNames = map[int]int{}, map[int]string{}
+	}
+	c.constellations[sys.ConstellationID] = regionID
+	c.regionNames[regionID] = name
+	c.regionMu.Unlock()
+	return regionID, name, nil
+}
+
+// RegionSystems lists the solar systems in a region
+func (c *Client) RegionSystems(ctx context.Context, regionID int) ([]int, error) {
+	var reg struct {
+		Constellations []int `json:"constellations"`
+	}
+	url := fmt.Sprintf("https://esi.evetech.net/latest/universe/regions/%d/?datasource=tranquility", regionID)
+	if err := c.getJSON(ctx, "universe/regions", url, &reg); err != nil {
+		return nil, err
+	}
+	var systems []int
+	for _, id := range reg.Constellations {
+		var con struct {
+			Systems []int `json:"systems"`
+		}
+		url = fmt.Sprintf("https://esi.evetech.net/latest/universe/constellations/%d/?datasource=tranquility", id)
+		if err := c.getJSON(ctx, "universe/constellations", url, &con); err != nil {
+			return nil, err
+		}
+		systems = append(systems, con.Systems...)
+	}
+	return systems, nil
+}
+
+// FWSystems lists the status of every Faction Warfare system
+func (c *Client) FWSystems(ctx context.Context) ([]killmail.EsiFWSystem, error) {
+	var systems []killmail.EsiFWSystem
+	url := "https://esi.evetech.net/latest/fw/systems/?datasource=tranquility"
+	if err := c.getJSON(ctx, "fw/systems", url, &systems); err != nil {
+		return nil, err
+	}
+	return systems, nil
+}
+
+// getJSON fetches and decodes one ESI resource
+func (c *Client) getJSON(ctx context.Context, endpoint, url string, v interface{}) error {
+	resp, err := c.doGetRequest(ctx, endpoint, url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s got status %d", endpoint, resp.StatusCode)
+	}
+	return schema.DecodeReader("esi "+endpoint, resp.Body, v)
+}
+
+// SystemID resolves an exact solar system name, e.g. "J123456", to its ID
+func (c *Client) SystemID(ctx context.Context, name string) (int, error) {
+	payload, err := json.Marshal([]string{name})
+	if err != nil {
+		return 0, err
+	}
+	url := "https://esi.evetech.net/latest/universe/ids/?datasource=tranquility"
+	resp, err := c.doRequest(ctx, http.MethodPost, "universe/ids", url, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return 0, fmt.Errorf("fetchSystemID got status %d", resp.StatusCode)
+	}
+
+	var ids struct {
+		Systems []struct {
+			ID int `json:"id"`
+		} `json:"systems"`
+	}
+	if err = schema.DecodeReader("esi universe/ids", resp.Body, &ids); err != nil {
+		return 0, err
+	}
+	if len(ids.Systems) == 0 {
+		return 0, fmt.Errorf("no solar system named %q", name)
+	}
+	return ids.Systems[0].ID, nil
+}
+
+// Celestial looks up the name and position of a star, planet, moon, belt,
+// stargate or station in a system. Structures are not public and return an error.
+func (c *Client) Celestial(ctx context.Context, systemID int, celestialID int64) (string, killmail.Position, error) {
+	url := fmt.Sprintf("https://esi.evetech.net/latest/universe/systems/%d/?datasource=tranquility", systemID)
+	resp, err := c.doGetRequest(ctx, "universe/systems", url)
+	if err != nil {
+		return "", killmail.Position{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", killmail.Position{}, fmt.Errorf("fetchSystem got status %d", resp.StatusCode)
+	}
+
+	var sys struct {
+		StarID  int64 `json:"star_id"`
+		Planets []struct {
+			PlanetID      int64   `json:"planet_id"`
+			Moons         []int64 `json:"moons"`
+			AsteroidBelts []int64 `json:"asteroid_belts"`
+		} `json:"planets"`
+		Stargates []int64 `json:"stargates"`
+		Stations  []int64 `json:"stations"`
+	}
+	if err = schema.DecodeReader("esi universe/systems", resp.Body, &sys); err != nil {
+		return "", killmail.Position{}, err
+	}
+
+	kind := ""
+	switch {
+	case celestialID == sys.StarID:
+		kind = "stars"
+	case slices.Contains(sys.Stargates, celestialID):
+		kind = "stargates"
+	case slices.Contains(sys.Stations, celestialID):
+		kind = "stations"
+	}
+	for _, p := range sys.Planets {
+		switch {
+		case celestialID == p.PlanetID:
+			kind = "planets"
+		case slices.Contains(p.Moons, celestialID):
+			kind = "moons"
+		case slices.Contains(p.AsteroidBelts, celestialID):
+			kind = "asteroid_belts"
+		}
+	}
+	if kind == "" {
+		return "", killmail.Position{}, fmt.Errorf("location %d is not a public celestial in system %d", celestialID, systemID)
+	}
+
+	url = fmt.Sprintf("https://esi.evetech.net/latest/universe/%s/%d/?datasource=tranquility", kind, celestialID)
+	resp, err = c.doGetRequest(ctx, "universe/"+kind, url)
+	if err != nil {
+		return "", killmail.Position{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", killmail.Position{}, fmt.Errorf("fetchCelestial got status %d", resp.StatusCode)
+	}
+
+	// stars have no position; they sit at the system origin
+	var cel struct {
+		Name     string            `json:"name"`
+		Position killmail.Position `json:"position"`
+	}
+	if err = schema.DecodeReader("esi universe/"+kind, resp.Body, &cel); err != nil {
+		return "", killmail.Position{}, err
+	}
+	return cel.Name, cel.Position, nil
+}
+
+// doGetRequest issues a GET, traced as a span named after the ESI endpoint
+func (c *Client) doGetRequest(ctx context.Context, endpoint, url string) (*http.Response, error) {
+	return c.doRequest(ctx, http.MethodGet, endpoint, url, nil)
+}
+
+func (c *Client) doRequest(ctx context.Context, method, endpoint, url string, body io.Reader) (*http.Response, error) {
+	return c.do(ctx, method, endpoint, url, body, "")
+}
+
+// do issues a request, with an SSO access token when token is set
+func (c *Client) do(ctx context.Context, method, endpoint, url string, body io.Reader, token string) (*http.Response, error) {
+	c.requests.Add(1)
+	ctx, span := tracing.Start(ctx, "esi "+endpoint, tracing.KindClient)
+	defer span.End()
+	span.SetAttr("http.url", url)
+
+	client := httpclient.Or(c.http)
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		c.failures.Add(1)
+		span.RecordError(err)
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		c.failures.Add(1)
+	}
+	span.SetAttr("http.status_code", resp.StatusCode)
+	return resp, nil
+}
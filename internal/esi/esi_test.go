@@ -0,0 +1,109 @@
+package esi
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+)
+
+// fuzzTransport answers the killmail lookup with body and everything else with a 404
+type fuzzTransport struct {
+	body []byte
+}
+
+func (ft fuzzTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	status, body := http.StatusNotFound, []byte(`{"error":"not found"}`)
+	if strings.Contains(req.URL.Path, "/killmails/") {
+		status, body = http.StatusOK, ft.body
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+func FuzzGetKillDetails(f *testing.F) {
+	f.Add(
+		[]byte(`{"killmail_id":123,"solar_system_id":31000001,"zkb":{"hash":"abc123","locationID":40000001,"totalValue":1e9}}`),
+		[]byte(`{"killmail_id":123,"killmail_time":"2024-05-01T12:00:00Z","solar_system_id":31000001,"victim":{"ship_type_id":587,"character_id":90000001,"position":{"x":1,"y":2,"z":3}},"attackers":[{"character_id":90000002,"final_blow":true,"ship_type_id":11971}]}`))
+	f.Add(
+		[]byte(`{"killmail_id":-1,"zkb":{"hash":"../../characters/1"}}`),
+		[]byte(`{"killmail_id":-1,"solar_system_id":-1,"victim":{"character_id":-1}}`))
+	f.Add(
+		[]byte(`{"killmail_id":124,"zkb":{"hash":"ff"}}`),
+		[]byte(`{"killmail_id":125,"attackers":[{},{},{}]}`))
+	f.Add(
+		[]byte(`{"killmail_id":126,"zkb":{"hash":"ff"`),
+		[]byte(`{"killmail_id":126,"victim":`))
+	f.Add([]byte(`null`), []byte(`[]`))
+
+	log := logger.Slog(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	f.Fuzz(func(t *testing.T, raw, esiBody []byte) {
+		orig := http.DefaultTransport
+		http.DefaultTransport = fuzzTransport{body: esiBody}
+		defer func() { http.DefaultTransport = orig }()
+
+		fkm, err := NewClient(log).GetKillDetails(context.Background(), raw)
+		if err == nil && fkm.KillMailID < 0 {
+			t.Fatalf("accepted negative killmail_id %d", fkm.KillMailID)
+		}
+	})
+}
+
+// pathTransport answers requests by URL path and everything else with a 404
+type pathTransport map[string]string
+
+func (pt pathTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	status, body := http.StatusNotFound, `{"error":"not found"}`
+	if b, ok := pt[req.URL.Path]; ok {
+		status, body = http.StatusOK, b
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+func TestRegionSystems(t *testing.T) {
+	orig := http.DefaultTransport
+	defer func() { http.DefaultTransport = orig }()
+	log := logger.Slog(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	http.DefaultTransport = pathTransport{
+		"/latest/universe/regions/10000070/":        `{"constellations":[20000788,20000789]}`,
+		"/latest/universe/constellations/20000788/": `{"systems":[30000021,30000157]}`,
+		"/latest/universe/constellations/20000789/": `{"systems":[30001372]}`,
+	}
+	systems, err := NewClient(log).RegionSystems(context.Background(), 10000070)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []int{30000021, 30000157, 30001372}
+	if len(systems) != len(want) {
+		t.Fatalf("RegionSystems = %v, want %v", systems, want)
+	}
+	for i := range want {
+		if systems[i] != want[i] {
+			t.Fatalf("RegionSystems = %v, want %v", systems, want)
+		}
+	}
+
+	// a missing constellation fails the whole region rather than returning part of it
+	http.DefaultTransport = pathTransport{
+		"/latest/universe/regions/10000070/":        `{"constellations":[20000788,20000789]}`,
+		"/latest/universe/constellations/20000788/": `{"systems":[30000021]}`,
+	}
+	if systems, err := NewClient(log).RegionSystems(context.Background(), 10000070); err == nil {
+		t.Fatalf("RegionSystems = %v, want an error for the missing constellation", systems)
+	}
+}
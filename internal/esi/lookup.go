@@ -0,0 +1,104 @@
+package esi
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// How long looked-up values are kept. Names and tickers rarely change and a
+// character's corporation only now and then; types and systems never do.
+const (
+	killmailTTL  = 10 * time.Minute
+	characterTTL = time.Hour
+	entityTTL    = 24 * time.Hour
+	foreverTTL   = 0
+
+	// maxCached bounds the cache; once full, expired entries are dropped,
+	// then everything if that isn't enough
+	maxCached = 50000
+)
+
+// lookups caches ESI results and coalesces concurrent fetches of the same
+// resource, so ten kills from one fleet cost one request per corporation.
+// Failures aren't cached: the next caller tries again.
+type lookups struct {
+	mu       sync.Mutex
+	cache    map[string]cached
+	inflight map[string]*call
+}
+
+type cached struct {
+	val     interface{}
+	expires time.Time // zero never expires
+}
+
+type call struct {
+	done chan struct{}
+	val  interface{}
+	err  error
+}
+
+// lookup returns key's cached value, waits for a fetch of it already in
+// flight, or else runs fetch and caches its result for ttl. The fetch isn't
+// cancelled with the caller who started it, since others may be waiting.
+func lookup[T any](ctx context.Context, l *lookups, key string, ttl time.Duration, fetch func(ctx context.Context) (T, error)) (T, error) {
+	var zero T
+	l.mu.Lock()
+	if l.cache == nil {
+		l.cache, l.inflight = map[string]cached{}, map[string]*call{}
+	}
+	if e, ok := l.cache[key]; ok && (e.expires.IsZero() || time.Now().Before(e.expires)) {
+		l.mu.Unlock()
+		return e.val.(T), nil
+	}
+	cl, joined := l.inflight[key]
+	if !joined {
+		cl = &call{done: make(chan struct{})}
+		l.inflight[key] = cl
+	}
+	l.mu.Unlock()
+
+	if !joined {
+		go func() {
+			val, err := fetch(context.WithoutCancel(ctx))
+			l.mu.Lock()
+			cl.val, cl.err = val, err
+			delete(l.inflight, key)
+			if err == nil {
+				l.store(key, val, ttl)
+			}
+			l.mu.Unlock()
+			close(cl.done)
+		}()
+	}
+	select {
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	case <-cl.done:
+	}
+	if cl.err != nil {
+		return zero, cl.err
+	}
+	return cl.val.(T), nil
+}
+
+// store caches a value; call with mu held
+func (l *lookups) store(key string, val interface{}, ttl time.Duration) {
+	if len(l.cache) >= maxCached {
+		now := time.Now()
+		for k, e := range l.cache {
+			if !e.expires.IsZero() && now.After(e.expires) {
+				delete(l.cache, k)
+			}
+		}
+		if len(l.cache) >= maxCached {
+			l.cache = map[string]cached{}
+		}
+	}
+	e := cached{val: val}
+	if ttl > 0 {
+		e.expires = time.Now().Add(ttl)
+	}
+	l.cache[key] = e
+}
@@ -0,0 +1,158 @@
+package esi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLookupCoalesces(t *testing.T) {
+	var l lookups
+	var fetches atomic.Int32
+	release := make(chan struct{})
+	fetch := func(ctx context.Context) (string, error) {
+		fetches.Add(1)
+		<-release
+		return "Corp", nil
+	}
+
+	// ten kills from one fleet ask for the same corporation at once
+	var wg sync.WaitGroup
+	results := make([]string, 10)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], _ = lookup(context.Background(), &l, "corporations/1", time.Hour, fetch)
+		}()
+	}
+	// callers that come after the fetch returns are served from the cache, so
+	// either way there's one fetch
+	for {
+		l.mu.Lock()
+		joined := len(l.inflight) == 1
+		l.mu.Unlock()
+		if joined && fetches.Load() == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("fetched %d times, want 1", n)
+	}
+	for i, r := range results {
+		if r != "Corp" {
+			t.Errorf("caller %d got %q", i, r)
+		}
+	}
+	// later callers are served from the cache
+	if v, err := lookup(context.Background(), &l, "corporations/1", time.Hour, fetch); err != nil || v != "Corp" || fetches.Load() != 1 {
+		t.Errorf("cached lookup = %q, %v after %d fetches, want Corp from the cache", v, err, fetches.Load())
+	}
+}
+
+func TestLookupExpiresAndRetriesErrors(t *testing.T) {
+	var l lookups
+	var fetches int
+	fetch := func(ctx context.Context) (int, error) {
+		fetches++
+		if fetches == 1 {
+			return 0, errors.New("esi got status 502")
+		}
+		return fetches, nil
+	}
+	ctx := context.Background()
+
+	if _, err := lookup(ctx, &l, "characters/1", time.Hour, fetch); err == nil {
+		t.Fatal("first lookup didn't fail")
+	}
+	// failures aren't cached
+	if v, err := lookup(ctx, &l, "characters/1", time.Hour, fetch); err != nil || v != 2 {
+		t.Fatalf("lookup after a failure = %d, %v, want a new fetch", v, err)
+	}
+	if v, _ := lookup(ctx, &l, "characters/1", time.Hour, fetch); v != 2 {
+		t.Errorf("lookup within the ttl = %d, want the cached 2", v)
+	}
+
+	l.mu.Lock()
+	e := l.cache["characters/1"]
+	e.expires = time.Now().Add(-time.Second)
+	l.cache["characters/1"] = e
+	l.mu.Unlock()
+	if v, _ := lookup(ctx, &l, "characters/1", time.Hour, fetch); v != 3 {
+		t.Errorf("lookup after expiry = %d, want a new fetch", v)
+	}
+
+	// a zero ttl never expires
+	if v, _ := lookup(ctx, &l, "types/587", foreverTTL, fetch); v != 4 {
+		t.Fatalf("types lookup = %d, want a fetch", v)
+	}
+	if e := l.cache["types/587"]; !e.expires.IsZero() {
+		t.Errorf("forever entry expires at %s", e.expires)
+	}
+}
+
+func TestLookupCallerCancelled(t *testing.T) {
+	var l lookups
+	release := make(chan struct{})
+	fetched := make(chan struct{})
+	fetch := func(ctx context.Context) (string, error) {
+		defer close(fetched)
+		<-release
+		// the fetch outlives the caller who started it
+		return "Alliance", ctx.Err()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := lookup(ctx, &l, "alliances/1", time.Hour, fetch); !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled lookup = %v, want context.Canceled", err)
+	}
+	close(release)
+	<-fetched
+	for {
+		l.mu.Lock()
+		pending := len(l.inflight)
+		l.mu.Unlock()
+		if pending == 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if v, err := lookup(context.Background(), &l, "alliances/1", time.Hour, func(context.Context) (string, error) {
+		return "", errors.New("fetched again")
+	}); err != nil || v != "Alliance" {
+		t.Errorf("lookup after the cancelled caller = %q, %v, want the cached result", v, err)
+	}
+}
+
+func TestLookupStoreBounded(t *testing.T) {
+	var l lookups
+	l.cache = map[string]cached{}
+	for i := 0; i < maxCached-1; i++ {
+		l.cache[fmt.Sprintf("characters/%d", i)] = cached{expires: time.Now().Add(-time.Minute)}
+	}
+	l.cache["types/1"] = cached{val: "Rifter"}
+
+	// a full cache drops its expired entries first
+	l.store("types/2", "Slasher", 0)
+	if len(l.cache) != 2 || l.cache["types/1"].val != "Rifter" || l.cache["types/2"].val != "Slasher" {
+		t.Errorf("cache holds %d entries after dropping expired ones, want the 2 live ones", len(l.cache))
+	}
+
+	// and everything when that isn't enough
+	for i := 0; len(l.cache) < maxCached; i++ {
+		l.cache[fmt.Sprintf("systems/%d", i)] = cached{val: i}
+	}
+	l.store("types/3", "Breacher", 0)
+	if len(l.cache) != 1 || l.cache["types/3"].val != "Breacher" {
+		t.Errorf("cache holds %d entries after overflowing, want only the new one", len(l.cache))
+	}
+}
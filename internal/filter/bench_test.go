@@ -0,0 +1,25 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/guarzo/eve-chainkills/internal/benchdata"
+	"github.com/guarzo/eve-chainkills/pkg/killmail"
+)
+
+func BenchmarkMatchIndex(b *testing.B) {
+	m := newTestMatcher()
+	m.SetTrackedIds([]int{benchdata.TrackedCorp})
+	ix := NewIndex(
+		[]killmail.SystemInfo{{SystemId: benchdata.ChainSystem, Alias: "C3a"}},
+		[]killmail.MapCharacter{{CharacterId: 2112625428, Name: "scout", SolarSystemId: benchdata.ChainSystem, Online: true}},
+	)
+	for _, k := range benchdata.Kills() {
+		b.Run(k.Name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				m.MatchIndex(k.Zkill, ix)
+			}
+		})
+	}
+}
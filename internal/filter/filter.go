@@ -0,0 +1,413 @@
+// Package filter decides whether a zKill message is a tracked corp kill/loss,
+// a chain kill worth alerting on, or neither.
+package filter
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/guarzo/eve-chainkills/pkg/killmail"
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+	"golang.org/x/exp/slices"
+)
+
+// Kind is the outcome of matching one kill
+type Kind int
+
+const (
+	// NoMatch means nothing should be sent
+	NoMatch Kind = iota
+	// CorpKill means the victim or an attacker is tracked
+	CorpKill
+	// ChainKill means the kill happened in a chain system without mapped attackers
+	ChainKill
+)
+
+// Rules that can match a kill, reported in Reason.Rule
+const (
+	RuleVictimTracked   = "victim_tracked"
+	RuleAttackerTracked = "attacker_tracked"
+	RuleMappedCharacter = "mapped_character"
+	RuleMappedVictim    = "mapped_victim"
+	RuleBothSides       = "both_sides"
+	RuleChainSystem     = "chain_system"
+)
+
+// Reason is the rule that matched a kill and the ID it matched on
+type Reason struct {
+	Rule string
+	ID   int64
+	Text string // e.g. "attacker corp 98012345 in tracked list"
+}
+
+// Result describes why a kill matched
+type Result struct {
+	Kind   Kind
+	IsKill bool // for CorpKill: tracked entity was on the attacking side
+	// BothSides is set on a CorpKill loss when a tracked or mapped pilot is also
+	// among the attackers, e.g. an awox
+	BothSides bool
+
+	// System is the matched chain system for ChainKill
+	System *killmail.SystemInfo
+	Reason Reason
+}
+
+// Matcher holds the tracking configuration, which can be replaced while running
+type Matcher struct {
+	logger logger.Logger
+
+	mu                sync.RWMutex
+	insightTrackedIds []int
+	typed             Tracked
+	ignoreSystemIds   []int
+	// remoteInsight and remoteTyped come from an external list and are matched
+	// on top of the configured IDs
+	remoteInsight []int
+	remoteTyped   Tracked
+	// mappedVictim and mappedAttackers pick the sides map characters are matched on
+	mappedVictim    bool
+	mappedAttackers bool
+
+	// sets is rebuilt from the fields above on every change
+	sets atomic.Pointer[trackedSets]
+}
+
+// trackedSets is the tracking as sets, replaced whole whenever it changes so
+// Match reads it without locking
+type trackedSets struct {
+	insight         map[int]struct{}
+	corporations    map[int]struct{}
+	alliances       map[int]struct{}
+	characters      map[int64]struct{}
+	ignored         map[int]struct{}
+	mappedVictim    bool
+	mappedAttackers bool
+}
+
+// NewMatcher constructor
+func NewMatcher(logger logger.Logger, insightTrackedIds, ignoreSystemIds []int) *Matcher {
+	m := &Matcher{
+		logger:            logger,
+		insightTrackedIds: insightTrackedIds,
+		ignoreSystemIds:   ignoreSystemIds,
+		mappedVictim:      true,
+		mappedAttackers:   true,
+	}
+	m.rebuild()
+	return m
+}
+
+// rebuild replaces the sets; call with mu held, or before m is shared
+func (m *Matcher) rebuild() {
+	m.sets.Store(&trackedSets{
+		insight:         intSet(m.insightTrackedIds, m.remoteInsight),
+		corporations:    intSet(m.typed.Corporations, m.remoteTyped.Corporations),
+		alliances:       intSet(m.typed.Alliances, m.remoteTyped.Alliances),
+		characters:      intSet(m.typed.Characters, m.remoteTyped.Characters),
+		ignored:         intSet(m.ignoreSystemIds),
+		mappedVictim:    m.mappedVictim,
+		mappedAttackers: m.mappedAttackers,
+	})
+}
+
+func intSet[T int | int64](lists ...[]T) map[T]struct{} {
+	set := map[T]struct{}{}
+	for _, ids := range lists {
+		for _, id := range ids {
+			set[id] = struct{}{}
+		}
+	}
+	return set
+}
+
+func (s *trackedSets) corporation(id int) bool {
+	_, insight := s.insight[id]
+	_, typed := s.corporations[id]
+	return id != 0 && (insight || typed)
+}
+
+func (s *trackedSets) alliance(id int) bool {
+	_, insight := s.insight[id]
+	_, typed := s.alliances[id]
+	return id != 0 && (insight || typed)
+}
+
+func (s *trackedSets) character(id int64) bool {
+	_, ok := s.characters[id]
+	return id != 0 && ok
+}
+
+// SetMappedSides picks whether a map character as the victim makes a corp
+// loss and as an attacker a corp kill; both are on by default. Mapped
+// attackers always suppress chain alerts.
+func (m *Matcher) SetMappedSides(victim, attackers bool) {
+	m.mu.Lock()
+	m.mappedVictim, m.mappedAttackers = victim, attackers
+	m.rebuild()
+	m.mu.Unlock()
+}
+
+// TrackedIds returns a copy of the tracked corporation/alliance IDs
+func (m *Matcher) TrackedIds() []int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return slices.Clone(m.insightTrackedIds)
+}
+
+// SetTrackedIds replaces the tracked corporation/alliance IDs
+func (m *Matcher) SetTrackedIds(ids []int) {
+	m.mu.Lock()
+	m.insightTrackedIds = slices.Clone(ids)
+	m.rebuild()
+	m.mu.Unlock()
+}
+
+// Tracks reports whether the ID under a killmail field, "corporation_id",
+// "alliance_id" or "character_id", is tracked. Map characters aren't covered.
+func (m *Matcher) Tracks(field string, id int64) bool {
+	sets := m.sets.Load()
+	switch field {
+	case "corporation_id":
+		return sets.corporation(int(id))
+	case "alliance_id":
+		return sets.alliance(int(id))
+	case "character_id":
+		return sets.character(id)
+	}
+	return false
+}
+
+// Tracked lists IDs whose entity type is known, so a corporation ID is never
+// mistaken for an alliance with the same number
+type Tracked struct {
+	Corporations []int
+	Alliances    []int
+	Characters   []int64
+}
+
+// Typed returns a copy of the typed tracked IDs
+func (m *Matcher) Typed() Tracked {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return Tracked{
+		Corporations: slices.Clone(m.typed.Corporations),
+		Alliances:    slices.Clone(m.typed.Alliances),
+		Characters:   slices.Clone(m.typed.Characters),
+	}
+}
+
+// SetTyped replaces the typed tracked IDs
+func (m *Matcher) SetTyped(t Tracked) {
+	m.mu.Lock()
+	m.typed = Tracked{
+		Corporations: slices.Clone(t.Corporations),
+		Alliances:    slices.Clone(t.Alliances),
+		Characters:   slices.Clone(t.Characters),
+	}
+	m.rebuild()
+	m.mu.Unlock()
+}
+
+// SetRemote replaces the IDs from an external list. They are matched like the
+// configured IDs but aren't returned by TrackedIds or Typed, so edits and saves
+// of the configured lists leave them out.
+func (m *Matcher) SetRemote(insight []int, typed Tracked) {
+	m.mu.Lock()
+	m.remoteInsight = slices.Clone(insight)
+	m.remoteTyped = Tracked{
+		Corporations: slices.Clone(typed.Corporations),
+		Alliances:    slices.Clone(typed.Alliances),
+		Characters:   slices.Clone(typed.Characters),
+	}
+	m.rebuild()
+	m.mu.Unlock()
+}
+
+// IgnoreSystemIds returns a copy of the ignored system IDs
+func (m *Matcher) IgnoreSystemIds() []int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return slices.Clone(m.ignoreSystemIds)
+}
+
+// SetIgnoreSystemIds replaces the ignored system IDs
+func (m *Matcher) SetIgnoreSystemIds(ids []int) {
+	m.mu.Lock()
+	m.ignoreSystemIds = slices.Clone(ids)
+	m.rebuild()
+	m.mu.Unlock()
+}
+
+// Match checks a kill against the tracked IDs, then the chain systems. It
+// indexes systems and mapCharacters on every call; MatchIndex takes an Index
+// built once per map refresh.
+func (m *Matcher) Match(zm killmail.ZkillMail, systems []killmail.SystemInfo, mapCharacters []killmail.MapCharacter) Result {
+	return m.MatchIndex(zm, NewIndex(systems, mapCharacters))
+}
+
+// MatchIndex is Match against an already built index of the map
+func (m *Matcher) MatchIndex(zm killmail.ZkillMail, ix *Index) Result {
+	sets := m.sets.Load()
+	corpTracked, allianceTracked, characterTracked := sets.corporation, sets.alliance, sets.character
+	mappedVictim, mappedAttackers := sets.mappedVictim, sets.mappedAttackers
+
+	// Check if kill is by/against a tracked corp, alliance or character
+	matchedCorpKill := false
+	isKill := false
+	var reason Reason
+
+	// is the victim tracked?
+	victim := zm.Victim
+	switch {
+	case corpTracked(victim.CorporationID):
+		reason = Reason{RuleVictimTracked, int64(victim.CorporationID), fmt.Sprintf("victim corp %d in tracked list", victim.CorporationID)}
+	case allianceTracked(victim.AllianceID):
+		reason = Reason{RuleVictimTracked, int64(victim.AllianceID), fmt.Sprintf("victim alliance %d in tracked list", victim.AllianceID)}
+	case characterTracked(victim.CharacterID):
+		reason = Reason{RuleVictimTracked, victim.CharacterID, fmt.Sprintf("victim character %d in tracked list", victim.CharacterID)}
+	}
+	if reason.Rule != "" {
+		m.logger.Printf("KillId %d => victim match. corpId=%d, allianceId=%d, characterId=%d",
+			zm.KillmailID, victim.CorporationID, victim.AllianceID, victim.CharacterID)
+		matchedCorpKill = true
+	}
+
+	// a map character in an alt corp loses a ship
+	if !matchedCorpKill && mappedVictim && ix.Mapped(victim.CharacterID) {
+		m.logger.Printf("KillId %d => victim char match. characterId=%d", zm.KillmailID, victim.CharacterID)
+		matchedCorpKill = true
+		reason = Reason{RuleMappedVictim, victim.CharacterID,
+			fmt.Sprintf("victim character %d is on the map", victim.CharacterID)}
+	}
+	// check attackers, even after a victim match, to spot kills with us on both sides
+	var attackerReason Reason
+	for _, att := range zm.Attackers {
+		switch {
+		case corpTracked(att.CorporationID):
+			attackerReason = Reason{RuleAttackerTracked, int64(att.CorporationID),
+				fmt.Sprintf("attacker corp %d in tracked list", att.CorporationID)}
+		case allianceTracked(att.AllianceID):
+			attackerReason = Reason{RuleAttackerTracked, int64(att.AllianceID),
+				fmt.Sprintf("attacker alliance %d in tracked list", att.AllianceID)}
+		case characterTracked(att.CharacterID):
+			attackerReason = Reason{RuleAttackerTracked, att.CharacterID,
+				fmt.Sprintf("attacker character %d in tracked list", att.CharacterID)}
+		// Also check if the attacker’s character ID is in mapCharacters
+		case mappedAttackers && ix.Mapped(att.CharacterID):
+			attackerReason = Reason{RuleMappedCharacter, att.CharacterID,
+				fmt.Sprintf("attacker character %d is on the map", att.CharacterID)}
+		default:
+			continue
+		}
+		break
+	}
+	if matchedCorpKill && attackerReason.Rule != "" {
+		m.logger.Printf("KillId %d => both sides match: %s, %s", zm.KillmailID, reason.Text, attackerReason.Text)
+		return Result{Kind: CorpKill, BothSides: true, Reason: Reason{RuleBothSides, reason.ID,
+			fmt.Sprintf("%s, and %s", reason.Text, attackerReason.Text)}}
+	}
+	if !matchedCorpKill && attackerReason.Rule != "" {
+		m.logger.Printf("KillId %d => attacker match: %s", zm.KillmailID, attackerReason.Text)
+		matchedCorpKill = true
+		isKill = true
+		reason = attackerReason
+	}
+
+	if matchedCorpKill {
+		return Result{Kind: CorpKill, IsKill: isKill, Reason: reason}
+	}
+
+	// else check if it happened in a system we track
+	sys, ok := ix.System(zm.SolarSystemID)
+	if !ok {
+		return Result{Kind: NoMatch, Reason: Reason{Text: fmt.Sprintf(
+			"no tracked victim or attacker, and system %d is not in the chain", zm.SolarSystemID)}}
+	}
+	matchedSystem := &sys
+	if _, ignored := sets.ignored[matchedSystem.SystemId]; ignored {
+		return Result{Kind: NoMatch, Reason: Reason{Text: fmt.Sprintf(
+			"chain system %d alias %s is in ignoreSystemIds", matchedSystem.SystemId, matchedSystem.Alias)}}
+	}
+	m.logger.Printf("SystemId (%d) matched; checking map characters...", matchedSystem.SystemId)
+	// see if any of the attackers are in mapCharacters
+	foundMappedAttacker := false
+	for _, att := range zm.Attackers {
+		if ix.Mapped(att.CharacterID) {
+			foundMappedAttacker = true
+			break
+		}
+	}
+
+	if !foundMappedAttacker {
+		m.logger.Printf("Zero mapped attackers out of %d. Sending chain message.", len(zm.Attackers))
+		return Result{Kind: ChainKill, System: matchedSystem, Reason: Reason{
+			RuleChainSystem, int64(matchedSystem.SystemId),
+			fmt.Sprintf("chain system %d alias %s", matchedSystem.SystemId, matchedSystem.Alias),
+		}}
+	}
+	m.logger.Printf("Skipping chain message; found mapped attackers.")
+	return Result{Kind: NoMatch, Reason: Reason{Text: fmt.Sprintf(
+		"chain system %d alias %s, but an attacker is on the map", matchedSystem.SystemId, matchedSystem.Alias)}}
+}
+
+// Index is the map state kills are matched against: the chain systems, the
+// map characters and the systems an online map character is in, by ID. Build
+// one when the map is refreshed rather than for every kill.
+type Index struct {
+	systems    map[int]killmail.SystemInfo
+	characters map[int64]struct{}
+	occupied   map[int]struct{}
+}
+
+// NewIndex indexes systems and map characters. Character ID zero is left out,
+// since NPC attackers have no character ID and must never count as mapped.
+func NewIndex(systems []killmail.SystemInfo, chars []killmail.MapCharacter) *Index {
+	ix := &Index{
+		systems:    make(map[int]killmail.SystemInfo, len(systems)),
+		characters: make(map[int64]struct{}, len(chars)),
+		occupied:   map[int]struct{}{},
+	}
+	for _, s := range systems {
+		if _, dup := ix.systems[s.SystemId]; !dup {
+			ix.systems[s.SystemId] = s
+		}
+	}
+	for _, mc := range chars {
+		if mc.CharacterId != 0 {
+			ix.characters[mc.CharacterId] = struct{}{}
+		}
+		if mc.Online && mc.SolarSystemId != 0 {
+			ix.occupied[mc.SolarSystemId] = struct{}{}
+		}
+	}
+	return ix
+}
+
+// System returns the chain system with the given ID
+func (ix *Index) System(id int) (killmail.SystemInfo, bool) {
+	if ix == nil {
+		return killmail.SystemInfo{}, false
+	}
+	s, ok := ix.systems[id]
+	return s, ok
+}
+
+// Mapped reports whether a character is on the map
+func (ix *Index) Mapped(id int64) bool {
+	if ix == nil || id == 0 {
+		return false
+	}
+	_, ok := ix.characters[id]
+	return ok
+}
+
+// Occupied reports whether an online map character is in the system
+func (ix *Index) Occupied(systemID int) bool {
+	if ix == nil {
+		return false
+	}
+	_, ok := ix.occupied[systemID]
+	return ok
+}
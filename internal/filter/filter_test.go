@@ -0,0 +1,73 @@
+package filter
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/guarzo/eve-chainkills/pkg/killmail"
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+)
+
+func newTestMatcher() *Matcher {
+	discard := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return NewMatcher(logger.Slog(discard), []int{98000001}, nil)
+}
+
+func TestMatchCharacterIDs(t *testing.T) {
+	systems := []killmail.SystemInfo{{SystemId: 31000123, Alias: "C3a"}}
+	chainKill := func(attackers ...killmail.Attacker) killmail.ZkillMail {
+		return killmail.ZkillMail{KillmailID: 1, SolarSystemID: 31000123, Attackers: attackers}
+	}
+
+	tests := []struct {
+		name     string
+		zm       killmail.ZkillMail
+		chars    []killmail.MapCharacter
+		wantKind Kind
+		wantRule string
+	}{
+		{
+			name:     "mapped attacker makes a corp kill",
+			zm:       chainKill(killmail.Attacker{CharacterID: 2112625428}),
+			chars:    []killmail.MapCharacter{{CharacterId: 2112625428}},
+			wantKind: CorpKill,
+			wantRule: RuleMappedCharacter,
+		},
+		{
+			name:     "NPC attacker does not match a character without an ID",
+			zm:       chainKill(killmail.Attacker{CharacterID: 0}),
+			chars:    []killmail.MapCharacter{{CharacterId: 0, Name: "no id"}},
+			wantKind: ChainKill,
+			wantRule: RuleChainSystem,
+		},
+		{
+			name:     "NPC and unmapped attackers in a chain system",
+			zm:       chainKill(killmail.Attacker{}, killmail.Attacker{CharacterID: 95465499}),
+			chars:    []killmail.MapCharacter{{CharacterId: 2112625428}},
+			wantKind: ChainKill,
+			wantRule: RuleChainSystem,
+		},
+		{
+			name:     "no map characters at all",
+			zm:       chainKill(killmail.Attacker{CharacterID: 95465499}),
+			wantKind: ChainKill,
+			wantRule: RuleChainSystem,
+		},
+		{
+			name:     "victim without a character ID is still matched by corp",
+			zm:       killmail.ZkillMail{KillmailID: 2, Victim: killmail.Victim{CorporationID: 98000001}},
+			wantKind: CorpKill,
+			wantRule: RuleVictimTracked,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newTestMatcher().Match(tt.zm, systems, tt.chars)
+			if got.Kind != tt.wantKind || got.Reason.Rule != tt.wantRule {
+				t.Errorf("Match = kind %d rule %q, want kind %d rule %q (%s)",
+					got.Kind, got.Reason.Rule, tt.wantKind, tt.wantRule, got.Reason.Text)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+// Package httpclient builds the HTTP client a hub hands to ESI, the map API,
+// Discord and the other sinks, so they reuse pooled keep-alive connections
+// instead of each request building its own client.
+package httpclient
+
+import (
+	"crypto/tls"
+	"net/http"
+	"time"
+)
+
+// Defaults for zero Config fields
+const (
+	DefaultTimeout             = 10 * time.Second
+	DefaultMaxIdleConns        = 100
+	DefaultMaxIdleConnsPerHost = 10
+	DefaultIdleConnTimeout     = 90 * time.Second
+)
+
+// Config tunes the shared client; zero fields take the defaults
+type Config struct {
+	Timeout             time.Duration
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	// MaxConnsPerHost caps connections to one host; 0 is unlimited
+	MaxConnsPerHost int
+	IdleConnTimeout time.Duration
+	DisableHTTP2    bool
+}
+
+// plain is the default client: it uses http.DefaultTransport at request
+// time, so tests can swap that out
+var plain = &http.Client{Timeout: DefaultTimeout}
+
+// Client returns the default client, for callers that weren't given one
+func Client() *http.Client {
+	return plain
+}
+
+// Or returns c, or the default client when c is nil
+func Or(c *http.Client) *http.Client {
+	if c != nil {
+		return c
+	}
+	return plain
+}
+
+// New builds a client with its own connection pool
+func New(c Config) *http.Client {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxIdleConns = orDefault(c.MaxIdleConns, DefaultMaxIdleConns)
+	t.MaxIdleConnsPerHost = orDefault(c.MaxIdleConnsPerHost, DefaultMaxIdleConnsPerHost)
+	t.MaxConnsPerHost = c.MaxConnsPerHost
+	t.IdleConnTimeout = orDefault(c.IdleConnTimeout, DefaultIdleConnTimeout)
+	t.ForceAttemptHTTP2 = !c.DisableHTTP2
+	if c.DisableHTTP2 {
+		// a non-nil, empty map turns off the transport's HTTP/2 upgrade
+		t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	return &http.Client{Timeout: orDefault(c.Timeout, DefaultTimeout), Transport: t}
+}
+
+func orDefault[T int | time.Duration](v, def T) T {
+	if v > 0 {
+		return v
+	}
+	return def
+}
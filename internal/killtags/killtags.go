@@ -0,0 +1,185 @@
+// Package killtags keeps the labels people put on kills, e.g. "srp-approved" or
+// "bait", as a JSON Lines log of changes that is replayed on open.
+package killtags
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+// validTag is what a tag may look like: lower case words joined by dashes
+var validTag = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+// Change is one tag added to, or removed from, a kill
+type Change struct {
+	Time    time.Time `json:"time"`
+	KillID  int64     `json:"kill_id"`
+	Tag     string    `json:"tag"`
+	By      string    `json:"by,omitempty"`
+	Removed bool      `json:"removed,omitempty"`
+}
+
+// Tag is one label on a kill, with who put it there and when
+type Tag struct {
+	Tag  string    `json:"tag"`
+	By   string    `json:"by,omitempty"`
+	Time time.Time `json:"time"`
+}
+
+// Store holds the current tags of every kill and appends changes to a file
+type Store struct {
+	path string
+
+	mu    sync.Mutex
+	f     *os.File
+	kills map[int64][]Tag
+}
+
+// Open replays the store at path, creating it if needed
+func Open(path string) (*Store, error) {
+	s := &Store{path: path, kills: map[int64][]Tag{}}
+	if err := s.replay(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	s.f = f
+	return s, nil
+}
+
+// Load replays the store at path read-only, e.g. for the CLI while the
+// service runs; changes to it fail
+func Load(path string) (*Store, error) {
+	s := &Store{path: path, kills: map[int64][]Tag{}}
+	if err := s.replay(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Store) replay() error {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var c Change
+		// a line cut short by a crash is skipped
+		if json.Unmarshal(scanner.Bytes(), &c) == nil {
+			s.apply(c)
+		}
+	}
+	return scanner.Err()
+}
+
+// apply updates the in-memory tags; it reports whether anything changed
+func (s *Store) apply(c Change) bool {
+	tags := s.kills[c.KillID]
+	i := sort.Search(len(tags), func(i int) bool { return tags[i].Tag >= c.Tag })
+	has := i < len(tags) && tags[i].Tag == c.Tag
+	switch {
+	case c.Removed && has:
+		tags = append(tags[:i], tags[i+1:]...)
+	case !c.Removed && !has:
+		tags = append(tags[:i], append([]Tag{{Tag: c.Tag, By: c.By, Time: c.Time}}, tags[i:]...)...)
+	default:
+		return false
+	}
+	if len(tags) == 0 {
+		delete(s.kills, c.KillID)
+	} else {
+		s.kills[c.KillID] = tags
+	}
+	return true
+}
+
+// Add tags a kill; adding a tag it already has does nothing
+func (s *Store) Add(killID int64, tag, by string) error {
+	return s.change(Change{Time: time.Now().UTC(), KillID: killID, Tag: tag, By: by})
+}
+
+// Remove takes a tag off a kill
+func (s *Store) Remove(killID int64, tag, by string) error {
+	return s.change(Change{Time: time.Now().UTC(), KillID: killID, Tag: tag, By: by, Removed: true})
+}
+
+func (s *Store) change(c Change) error {
+	if !validTag.MatchString(c.Tag) {
+		return fmt.Errorf("tag %q must be 1-32 lower case letters, digits or dashes", c.Tag)
+	}
+	if c.KillID <= 0 {
+		return fmt.Errorf("kill ID %d is not valid", c.KillID)
+	}
+	line, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return errors.New("tag store is closed or read-only")
+	}
+	if !s.apply(c) {
+		return nil
+	}
+	_, err = s.f.Write(append(line, '\n'))
+	return err
+}
+
+// Tags returns a kill's tags in name order
+func (s *Store) Tags(killID int64) []Tag {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Tag(nil), s.kills[killID]...)
+}
+
+// Names returns just the tag names of a kill
+func (s *Store) Names(killID int64) []string {
+	tags := s.Tags(killID)
+	names := make([]string, len(tags))
+	for i, t := range tags {
+		names[i] = t.Tag
+	}
+	return names
+}
+
+// Tagged lists the kills with tag, or with any tag when it is empty, tagged at
+// or after since, by kill ID
+func (s *Store) Tagged(tag string, since time.Time) map[int64][]Tag {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := map[int64][]Tag{}
+	for id, tags := range s.kills {
+		for _, t := range tags {
+			if (tag == "" || t.Tag == tag) && !t.Time.Before(since) {
+				out[id] = append([]Tag(nil), tags...)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// Close closes the file; later changes fail
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+	return err
+}
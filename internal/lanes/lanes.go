@@ -0,0 +1,117 @@
+// Package lanes limits how many callers do something at once and, when they
+// have to wait, lets the most important ones through first.
+package lanes
+
+import (
+	"context"
+	"sync"
+)
+
+// Gate hands out a fixed number of slots. Waiting callers are served in
+// priority order, 0 first, and first come first served within a priority.
+// A nil Gate never makes anyone wait.
+type Gate struct {
+	mu      sync.Mutex
+	free    int
+	waiting [][]chan struct{}
+}
+
+// New returns a gate with slots slots for priorities 0 to priorities-1, or
+// nil when slots isn't positive
+func New(slots, priorities int) *Gate {
+	if slots <= 0 {
+		return nil
+	}
+	if priorities < 1 {
+		priorities = 1
+	}
+	return &Gate{free: slots, waiting: make([][]chan struct{}, priorities)}
+}
+
+// Acquire waits for a slot and returns the func that gives it back; priorities
+// out of range are clamped
+func (g *Gate) Acquire(ctx context.Context, priority int) (func(), error) {
+	if g == nil {
+		return func() {}, nil
+	}
+	priority = max(0, min(priority, len(g.waiting)-1))
+
+	g.mu.Lock()
+	if g.free > 0 {
+		g.free--
+		g.mu.Unlock()
+		return g.releaser(), nil
+	}
+	ch := make(chan struct{})
+	g.waiting[priority] = append(g.waiting[priority], ch)
+	g.mu.Unlock()
+
+	select {
+	case <-ch:
+		return g.releaser(), nil
+	case <-ctx.Done():
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		for i, w := range g.waiting[priority] {
+			if w == ch {
+				g.waiting[priority] = append(g.waiting[priority][:i], g.waiting[priority][i+1:]...)
+				return nil, ctx.Err()
+			}
+		}
+		// the slot was handed over as ctx ended; pass it on
+		g.handOff()
+		return nil, ctx.Err()
+	}
+}
+
+func (g *Gate) releaser() func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			g.mu.Lock()
+			defer g.mu.Unlock()
+			g.handOff()
+		})
+	}
+}
+
+// handOff gives a freed slot to the most important waiter; g.mu must be held
+func (g *Gate) handOff() {
+	for p, queue := range g.waiting {
+		if len(queue) > 0 {
+			close(queue[0])
+			g.waiting[p] = queue[1:]
+			return
+		}
+	}
+	g.free++
+}
+
+// Waiting counts the callers waiting at each priority
+func (g *Gate) Waiting() []int {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	counts := make([]int, len(g.waiting))
+	for p, queue := range g.waiting {
+		counts[p] = len(queue)
+	}
+	return counts
+}
+
+type priorityKey struct{}
+
+// WithPriority tags ctx with the priority of the work done under it
+func WithPriority(ctx context.Context, priority int) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+// Priority reads the priority WithPriority set, or returns fallback
+func Priority(ctx context.Context, fallback int) int {
+	if p, ok := ctx.Value(priorityKey{}).(int); ok {
+		return p
+	}
+	return fallback
+}
@@ -0,0 +1,53 @@
+package logging
+
+import "github.com/guarzo/eve-chainkills/pkg/logger"
+
+// filtered drops entries above its level before they reach base
+type filtered struct {
+	base  logger.Logger
+	level Level
+}
+
+func (f *filtered) on(lvl Level) bool {
+	return lvl <= f.level
+}
+
+func (f *filtered) WithField(key string, value interface{}) logger.Logger {
+	return &filtered{base: f.base.WithField(key, value), level: f.level}
+}
+
+func (f *filtered) Debugf(format string, args ...interface{}) {
+	if f.on(DebugLevel) {
+		f.base.Debugf(format, args...)
+	}
+}
+
+func (f *filtered) Infof(format string, args ...interface{}) {
+	if f.on(InfoLevel) {
+		f.base.Infof(format, args...)
+	}
+}
+
+func (f *filtered) Printf(format string, args ...interface{}) {
+	if f.on(InfoLevel) {
+		f.base.Printf(format, args...)
+	}
+}
+
+func (f *filtered) Warnf(format string, args ...interface{}) {
+	if f.on(WarnLevel) {
+		f.base.Warnf(format, args...)
+	}
+}
+
+func (f *filtered) Errorf(format string, args ...interface{}) {
+	if f.on(ErrorLevel) {
+		f.base.Errorf(format, args...)
+	}
+}
+
+func (f *filtered) Println(args ...interface{}) {
+	if f.on(InfoLevel) {
+		f.base.Println(args...)
+	}
+}
@@ -0,0 +1,123 @@
+// Package logging gives each subsystem its own log level on top of a shared
+// logger, so e.g. the zKill feed can log at debug while ESI stays at warn.
+package logging
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+)
+
+// Level is a log level; higher is more verbose. The names and order are logrus's.
+type Level int
+
+// Log levels
+const (
+	PanicLevel Level = iota
+	FatalLevel
+	ErrorLevel
+	WarnLevel
+	InfoLevel
+	DebugLevel
+	TraceLevel
+)
+
+var levelNames = []string{"panic", "fatal", "error", "warning", "info", "debug", "trace"}
+
+func (l Level) String() string {
+	if l < PanicLevel || l > TraceLevel {
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+// ParseLevel accepts the logrus level names, e.g. "warn" or "debug"
+func ParseLevel(name string) (Level, error) {
+	name = strings.ToLower(name)
+	if name == "warn" {
+		return WarnLevel, nil
+	}
+	for i, n := range levelNames {
+		if n == name {
+			return Level(i), nil
+		}
+	}
+	return InfoLevel, fmt.Errorf("not a valid log level: %q", name)
+}
+
+// Component names accepted in the logLevels config
+const (
+	Zkill    = "zkill"
+	ESI      = "esi"
+	Map      = "map"
+	Discord  = "discord"
+	Filter   = "filter"
+	Pipeline = "pipeline"
+	Sinks    = "sinks"
+)
+
+var components = []string{Zkill, ESI, Map, Discord, Filter, Pipeline, Sinks}
+
+// Levels holds the default level and any per-component overrides
+type Levels struct {
+	Default    Level
+	Components map[string]Level
+}
+
+// ParseLevels parses the global level (empty means info) and per-component overrides
+func ParseLevels(def string, perComponent map[string]string) (Levels, error) {
+	levels := Levels{Default: InfoLevel, Components: map[string]Level{}}
+	if def != "" {
+		lvl, err := ParseLevel(def)
+		if err != nil {
+			return levels, err
+		}
+		levels.Default = lvl
+	}
+	for name, l := range perComponent {
+		if !validComponent(name) {
+			return levels, fmt.Errorf("unknown log component %q, expected one of %s", name, strings.Join(components, ", "))
+		}
+		lvl, err := ParseLevel(l)
+		if err != nil {
+			return levels, fmt.Errorf("log level for %s: %w", name, err)
+		}
+		levels.Components[name] = lvl
+	}
+	return levels, nil
+}
+
+func validComponent(name string) bool {
+	for _, c := range components {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Max is the most verbose level in use; the underlying logger must be set to
+// it so that components logging below the default still get through
+func (l Levels) Max() Level {
+	max := l.Default
+	for _, lvl := range l.Components {
+		if lvl > max {
+			max = lvl
+		}
+	}
+	return max
+}
+
+// For returns base filtered to the component's level and tagged with a
+// "component" field. An empty component gets the default level and no field.
+func (l Levels) For(base logger.Logger, component string) logger.Logger {
+	lvl, ok := l.Components[component]
+	if !ok {
+		lvl = l.Default
+	}
+	if component != "" {
+		base = base.WithField("component", component)
+	}
+	return &filtered{base: base, level: lvl}
+}
@@ -0,0 +1,246 @@
+// Package mapapi reads chain systems and characters from the mapping tool's API.
+// It lives in internal/map; "map" itself is a reserved word.
+package mapapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/guarzo/eve-chainkills/internal/httpclient"
+	"github.com/guarzo/eve-chainkills/internal/schema"
+	"github.com/guarzo/eve-chainkills/internal/tracing"
+	"github.com/guarzo/eve-chainkills/pkg/killmail"
+)
+
+// ErrForbidden is returned, wrapped, when every token for an endpoint was refused
+var ErrForbidden = errors.New("map API access denied")
+
+// Token is a map API token and the endpoints it may call ("systems",
+// "characters", "connections"); no scopes means every endpoint
+type Token struct {
+	Value  string   `json:"token"`
+	Scopes []string `json:"scopes"`
+	// OAuth, when set, supplies renewed access tokens instead of Value
+	OAuth *OAuth `json:"-"`
+}
+
+func (t Token) value(ctx context.Context) (string, error) {
+	if t.OAuth != nil {
+		return t.OAuth.AccessToken(ctx)
+	}
+	return t.Value, nil
+}
+
+func (t Token) allows(endpoint string) bool {
+	if len(t.Scopes) == 0 {
+		return true
+	}
+	for _, s := range t.Scopes {
+		if s == endpoint {
+			return true
+		}
+	}
+	return false
+}
+
+// Client is bound to one map slug
+type Client struct {
+	baseURL string
+	slug    string
+	tokens  []Token
+	// http is nil for the default client
+	http *http.Client
+
+	mu sync.Mutex
+	// preferred is the index of the token that last worked for each endpoint
+	preferred map[string]int
+}
+
+// NewClient constructor. Each request tries the tokens scoped to its endpoint,
+// starting with the one that last worked, until one is not refused.
+func NewClient(baseURL, slug string, tokens ...Token) *Client {
+	return &Client{
+		baseURL:   baseURL,
+		slug:      slug,
+		tokens:    tokens,
+		preferred: map[string]int{},
+	}
+}
+
+// SetHTTPClient sends requests through client instead of the default one
+func (c *Client) SetHTTPClient(client *http.Client) {
+	c.http = client
+}
+
+// Systems fetches the chain's systems. Systems whose name ends with a letter are skipped.
+func (c *Client) Systems(ctx context.Context) ([]killmail.SystemInfo, error) {
+	var body struct {
+		Data []struct {
+			ID            string `json:"id"`
+			Name          string `json:"name"`
+			SolarSystemId int    `json:"solar_system_id"`
+		} `json:"data"`
+	}
+	if err := c.get(ctx, "systems", &body); err != nil {
+		return nil, err
+	}
+
+	var systems []killmail.SystemInfo
+	for _, item := range body.Data {
+		if len(item.Name) == 0 {
+			continue
+		}
+		// Example skip if name ends with letter
+		lastChar := item.Name[len(item.Name)-1]
+		if (lastChar >= 'A' && lastChar <= 'Z') || (lastChar >= 'a' && lastChar <= 'z') {
+			// skip
+			continue
+		}
+		systems = append(systems, killmail.SystemInfo{
+			SystemId: item.SolarSystemId,
+			Alias:    item.Name,
+		})
+	}
+	return systems, nil
+}
+
+// Characters fetches the characters registered on the map
+func (c *Client) Characters(ctx context.Context) ([]killmail.MapCharacter, error) {
+	var body struct {
+		Data []struct {
+			ID        string `json:"id"`
+			Character struct {
+				ID            string `json:"id"`
+				EveID         eveID  `json:"eve_id"`
+				CorporationID int    `json:"corporation_id"`
+				AllianceID    int    `json:"alliance_id"`
+				Name          string `json:"name"`
+				SolarSystemID int    `json:"solar_system_id"`
+				Online        *bool  `json:"online"`
+			} `json:"character"`
+		} `json:"data"`
+	}
+	if err := c.get(ctx, "characters", &body); err != nil {
+		return nil, err
+	}
+
+	var chars []killmail.MapCharacter
+	for _, item := range body.Data {
+		chars = append(chars, killmail.MapCharacter{
+			CharacterId:   int64(item.Character.EveID),
+			CorporationId: item.Character.CorporationID,
+			AllianceId:    item.Character.AllianceID,
+			Name:          item.Character.Name,
+			SolarSystemId: item.Character.SolarSystemID,
+			// maps that don't report online status are assumed to list active pilots
+			Online: item.Character.Online == nil || *item.Character.Online,
+		})
+	}
+	return chars, nil
+}
+
+// Connections fetches the links between the chain's systems
+func (c *Client) Connections(ctx context.Context) ([]killmail.Connection, error) {
+	var body struct {
+		Data []struct {
+			Source int `json:"solar_system_source"`
+			Target int `json:"solar_system_target"`
+		} `json:"data"`
+	}
+	if err := c.get(ctx, "connections", &body); err != nil {
+		return nil, err
+	}
+
+	conns := make([]killmail.Connection, 0, len(body.Data))
+	for _, item := range body.Data {
+		conns = append(conns, killmail.Connection{Source: item.Source, Target: item.Target})
+	}
+	return conns, nil
+}
+
+// get calls {baseURL}/{endpoint}?slug={slug} with the tokens allowed to, and
+// decodes the JSON body into out. A 401 or 403 moves on to the next token.
+func (c *Client) get(ctx context.Context, endpoint string, out interface{}) error {
+	c.mu.Lock()
+	first := c.preferred[endpoint]
+	c.mu.Unlock()
+
+	tried := 0
+	var err error
+	for i := range c.tokens {
+		idx := (first + i) % len(c.tokens)
+		if !c.tokens[idx].allows(endpoint) {
+			continue
+		}
+		tried++
+		err = c.getWith(ctx, endpoint, c.tokens[idx], out)
+		if errors.Is(err, ErrForbidden) {
+			continue
+		}
+		if err == nil {
+			c.mu.Lock()
+			c.preferred[endpoint] = idx
+			c.mu.Unlock()
+		}
+		return err
+	}
+	if tried == 0 {
+		return fmt.Errorf("%s: %w: no token is scoped for it", endpoint, ErrForbidden)
+	}
+	return err
+}
+
+// getWith calls endpoint with one token; an OAuth access token that is
+// refused is renewed and tried once more, in case it was revoked early
+func (c *Client) getWith(ctx context.Context, endpoint string, t Token, out interface{}) error {
+	value, err := t.value(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", endpoint, err)
+	}
+	err = c.getWithToken(ctx, endpoint, value, out)
+	if t.OAuth == nil || !errors.Is(err, ErrForbidden) {
+		return err
+	}
+	t.OAuth.Invalidate()
+	if value, err = t.value(ctx); err != nil {
+		return fmt.Errorf("%s: %w", endpoint, err)
+	}
+	return c.getWithToken(ctx, endpoint, value, out)
+}
+
+func (c *Client) getWithToken(ctx context.Context, endpoint, token string, out interface{}) error {
+	ctx, span := tracing.Start(ctx, "map "+endpoint, tracing.KindClient)
+	defer span.End()
+
+	url := fmt.Sprintf("%s/%s?slug=%s", c.baseURL, endpoint, c.slug)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	client := httpclient.Or(c.http)
+	resp, err := client.Do(req)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	span.SetAttr("http.status_code", resp.StatusCode)
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		err = fmt.Errorf("%s: %w (%s)", endpoint, ErrForbidden, resp.Status)
+		span.RecordError(err)
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("%s: bad status %s", endpoint, resp.Status)
+		span.RecordError(err)
+		return err
+	}
+	return schema.DecodeReader("map "+endpoint, resp.Body, out)
+}
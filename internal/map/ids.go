@@ -0,0 +1,31 @@
+package mapapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+)
+
+// eveID is an EVE entity ID that the map API may send as a string or a
+// number. Missing, empty or malformed IDs decode to 0 instead of failing the
+// whole response; 0 never matches a killmail attacker.
+type eveID int64
+
+func (id *eveID) UnmarshalJSON(data []byte) error {
+	*id = 0
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		// not a string, so a number
+		s = string(data)
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return nil
+	}
+	*id = eveID(n)
+	return nil
+}
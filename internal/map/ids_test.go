@@ -0,0 +1,67 @@
+package mapapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEveIDUnmarshal(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want int64
+	}{
+		{"string", `{"id": "2112625428"}`, 2112625428},
+		{"number", `{"id": 2112625428}`, 2112625428},
+		{"beyond int32", `{"id": "2147483648000"}`, 2147483648000},
+		{"missing", `{}`, 0},
+		{"null", `{"id": null}`, 0},
+		{"empty string", `{"id": ""}`, 0},
+		{"zero", `{"id": "0"}`, 0},
+		{"malformed", `{"id": "abc"}`, 0},
+		{"negative", `{"id": -5}`, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v struct {
+				ID eveID `json:"id"`
+			}
+			if err := json.Unmarshal([]byte(tt.json), &v); err != nil {
+				t.Fatalf("unmarshal %s: %v", tt.json, err)
+			}
+			if int64(v.ID) != tt.want {
+				t.Errorf("unmarshal %s = %d, want %d", tt.json, v.ID, tt.want)
+			}
+		})
+	}
+}
+
+func TestCharactersKeepsEntriesWithBadIDs(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data": [
+			{"character": {"eve_id": "2112625428", "name": "Alice"}},
+			{"character": {"eve_id": 95465499, "name": "Bob"}},
+			{"character": {"eve_id": "", "name": "Carol"}},
+			{"character": {"name": "Dave"}}
+		]}`)
+	}))
+	defer srv.Close()
+
+	chars, err := NewClient(srv.URL, "slug", Token{Value: "t"}).Characters(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int64{"Alice": 2112625428, "Bob": 95465499, "Carol": 0, "Dave": 0}
+	if len(chars) != len(want) {
+		t.Fatalf("got %d characters, want %d", len(chars), len(want))
+	}
+	for _, c := range chars {
+		if c.CharacterId != want[c.Name] {
+			t.Errorf("%s: CharacterId = %d, want %d", c.Name, c.CharacterId, want[c.Name])
+		}
+	}
+}
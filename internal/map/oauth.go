@@ -0,0 +1,126 @@
+package mapapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/internal/httpclient"
+)
+
+// OAuth renews map API access tokens with the refresh_token grant
+type OAuth struct {
+	tokenURL     string
+	clientID     string
+	clientSecret string
+
+	// OnRefresh is called with every new refresh token the server issues, so it can be saved
+	OnRefresh func(refreshToken string)
+	// OnError is called when refreshing starts failing, and not again until a refresh succeeds
+	OnError func(err error)
+	// HTTPClient sends the refresh requests; nil uses the default client
+	HTTPClient *http.Client
+
+	mu           sync.Mutex
+	refreshToken string
+	accessToken  string
+	expires      time.Time
+	failing      bool
+}
+
+// NewOAuth constructor
+func NewOAuth(tokenURL, clientID, clientSecret, refreshToken string) *OAuth {
+	return &OAuth{
+		tokenURL:     tokenURL,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		refreshToken: refreshToken,
+	}
+}
+
+// AccessToken returns a current access token, refreshing it when it is about to expire
+func (o *OAuth) AccessToken(ctx context.Context) (string, error) {
+	o.mu.Lock()
+	if o.accessToken != "" && time.Now().Before(o.expires) {
+		defer o.mu.Unlock()
+		return o.accessToken, nil
+	}
+
+	err := o.refresh(ctx)
+	started := err != nil && !o.failing
+	o.failing = err != nil
+	token := o.accessToken
+	o.mu.Unlock()
+
+	if err != nil {
+		if started && o.OnError != nil {
+			o.OnError(err)
+		}
+		return "", err
+	}
+	return token, nil
+}
+
+// Invalidate drops the access token, e.g. after the map API refused it
+func (o *OAuth) Invalidate() {
+	o.mu.Lock()
+	o.accessToken = ""
+	o.mu.Unlock()
+}
+
+func (o *OAuth) refresh(ctx context.Context) error {
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {o.refreshToken},
+		"client_id":     {o.clientID},
+	}
+	if o.clientSecret != "" {
+		form.Set("client_secret", o.clientSecret)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	client := httpclient.Or(o.HTTPClient)
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("refresh map API token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int    `json:"expires_in"`
+		Error        string `json:"error"`
+		Description  string `json:"error_description"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 || body.AccessToken == "" {
+		return fmt.Errorf("refresh map API token: status %d: %s", resp.StatusCode, strings.TrimSpace(body.Error+" "+body.Description))
+	}
+
+	o.accessToken = body.AccessToken
+	// renew a minute early so a request never carries a token that expires in flight
+	lifetime := time.Duration(body.ExpiresIn)*time.Second - time.Minute
+	if body.ExpiresIn <= 0 || lifetime <= 0 {
+		lifetime = 5 * time.Minute
+	}
+	o.expires = time.Now().Add(lifetime)
+	if body.RefreshToken != "" && body.RefreshToken != o.refreshToken {
+		// servers that rotate refresh tokens revoke the old one
+		o.refreshToken = body.RefreshToken
+		if o.OnRefresh != nil {
+			o.OnRefresh(body.RefreshToken)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,275 @@
+// Package schema decodes upstream JSON (zKillboard, ESI, the map API) and
+// watches it for drift: decode failures are counted and sampled, and fields
+// the models don't know about are reported once each, so a schema change
+// shows up in the logs instead of as mysteriously missing data.
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+)
+
+// maxSample caps how much of an offending payload is kept
+const maxSample = 64 << 10
+
+// maxBody caps how much of a response body is read
+const maxBody = 32 << 20
+
+// Config sets where samples go
+type Config struct {
+	// SampleDir receives "<source>.failure.json" and "<source>.drift.json"
+	// with the latest offending payload per source; empty keeps samples in memory only
+	SampleDir string `json:"sampleDir"`
+}
+
+// SourceStats are the decode counters for one upstream source, e.g. "esi killmails"
+type SourceStats struct {
+	Decoded  int64 `json:"decoded"`
+	Failures int64 `json:"failures"`
+	// UnknownFields are JSON paths the models don't have, e.g. "zkb.labels"
+	UnknownFields []string `json:"unknown_fields,omitempty"`
+	LastError     string   `json:"last_error,omitempty"`
+	LastFailureAt string   `json:"last_failure_at,omitempty"`
+	// Sample is the start of the last payload that failed to decode
+	Sample string `json:"sample,omitempty"`
+}
+
+type monitor struct {
+	mu      sync.Mutex
+	logger  logger.Logger
+	dir     string
+	sources map[string]*source
+}
+
+type source struct {
+	stats   SourceStats
+	unknown map[string]bool
+}
+
+var std = &monitor{logger: logger.Default(), sources: map[string]*source{}}
+
+// Init sets the logger and sample directory; until then slog.Default() is used
+func Init(logger logger.Logger, config Config) error {
+	if config.SampleDir != "" {
+		if err := os.MkdirAll(config.SampleDir, 0o755); err != nil {
+			return err
+		}
+	}
+	std.mu.Lock()
+	std.logger, std.dir = logger, config.SampleDir
+	std.mu.Unlock()
+	return nil
+}
+
+// Stats returns a copy of every source's counters
+func Stats() map[string]SourceStats {
+	std.mu.Lock()
+	defer std.mu.Unlock()
+	out := make(map[string]SourceStats, len(std.sources))
+	for name, s := range std.sources {
+		st := s.stats
+		st.UnknownFields = append([]string(nil), st.UnknownFields...)
+		out[name] = st
+	}
+	return out
+}
+
+// Decode unmarshals data into v, which must be a pointer, and records the
+// outcome under source
+func Decode(source string, data []byte, v interface{}) error {
+	err := json.Unmarshal(data, v)
+	var unknown []string
+	if err == nil {
+		unknown = unknownFields(data, reflect.TypeOf(v))
+	}
+	std.record(source, data, err, unknown)
+	if err != nil {
+		return fmt.Errorf("decode %s: %w", source, err)
+	}
+	return nil
+}
+
+// DecodeReader reads a response body and decodes it like Decode
+func DecodeReader(source string, r io.Reader, v interface{}) error {
+	data, err := io.ReadAll(io.LimitReader(r, maxBody))
+	if err != nil {
+		return fmt.Errorf("read %s: %w", source, err)
+	}
+	return Decode(source, data, v)
+}
+
+func (m *monitor) record(name string, data []byte, err error, unknown []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.sources[name]
+	if s == nil {
+		s = &source{unknown: map[string]bool{}}
+		m.sources[name] = s
+	}
+	if err != nil {
+		s.stats.Failures++
+		s.stats.LastError = err.Error()
+		s.stats.LastFailureAt = time.Now().UTC().Format(time.RFC3339)
+		s.stats.Sample = string(truncate(data))
+		m.logger.Warnf("[Schema] %s payload failed to decode (%d failures): %v", name, s.stats.Failures, err)
+		m.saveSample(name, "failure", data)
+		return
+	}
+	s.stats.Decoded++
+
+	var fresh []string
+	for _, path := range unknown {
+		if !s.unknown[path] {
+			s.unknown[path] = true
+			fresh = append(fresh, path)
+		}
+	}
+	if len(fresh) == 0 {
+		return
+	}
+	s.stats.UnknownFields = append(s.stats.UnknownFields, fresh...)
+	sort.Strings(s.stats.UnknownFields)
+	m.logger.Infof("[Schema] %s sent fields the models don't have: %s", name, strings.Join(fresh, ", "))
+	m.saveSample(name, "drift", data)
+}
+
+func (m *monitor) saveSample(name, kind string, data []byte) {
+	if m.dir == "" {
+		return
+	}
+	file := filepath.Join(m.dir, fileName(name)+"."+kind+".json")
+	if err := os.WriteFile(file, truncate(data), 0o644); err != nil {
+		m.logger.Errorf("[Schema] Error saving %s sample: %v", name, err)
+	}
+}
+
+func truncate(data []byte) []byte {
+	if len(data) > maxSample {
+		return data[:maxSample]
+	}
+	return data
+}
+
+// fileName turns a source such as "esi universe/systems" into "esi_universe_systems"
+func fileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+var unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// unknownFields lists the JSON paths in data that t has no field for
+func unknownFields(data []byte, t reflect.Type) []string {
+	var raw interface{}
+	if json.Unmarshal(data, &raw) != nil {
+		return nil
+	}
+	found := map[string]bool{}
+	walk(raw, t, "", found)
+	paths := make([]string, 0, len(found))
+	for p := range found {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func walk(raw interface{}, t reflect.Type, path string, found map[string]bool) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	// types that decode themselves, e.g. time.Time, define their own shape
+	if reflect.PointerTo(t).Implements(unmarshalerType) {
+		return
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		obj, ok := raw.(map[string]interface{})
+		if !ok {
+			return
+		}
+		fields := jsonFields(t)
+		for key, val := range obj {
+			// encoding/json matches keys case-insensitively
+			ft, ok := fields[strings.ToLower(key)]
+			if !ok {
+				found[join(path, key)] = true
+				continue
+			}
+			walk(val, ft, join(path, key), found)
+		}
+	case reflect.Slice, reflect.Array:
+		arr, ok := raw.([]interface{})
+		if !ok {
+			return
+		}
+		for _, val := range arr {
+			walk(val, t.Elem(), path+"[]", found)
+		}
+	case reflect.Map:
+		obj, ok := raw.(map[string]interface{})
+		if !ok {
+			return
+		}
+		for _, val := range obj {
+			walk(val, t.Elem(), path+".*", found)
+		}
+	}
+}
+
+// jsonFields maps the lowercased JSON names of a struct's fields, including
+// promoted ones, to their types
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				for k, v := range jsonFields(ft) {
+					if _, ok := fields[k]; !ok {
+						fields[k] = v
+					}
+				}
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[strings.ToLower(name)] = f.Type
+	}
+	return fields
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,87 @@
+// Package seat reads corporation standings from a SeAT installation's API.
+package seat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/guarzo/eve-chainkills/internal/httpclient"
+)
+
+// maxPages stops a paginated listing that never ends
+const maxPages = 100
+
+// Contact is one entry of a corporation's contact list
+type Contact struct {
+	ContactID int64 `json:"contact_id"`
+	// ContactType is "character", "corporation", "alliance" or "faction"
+	ContactType string  `json:"contact_type"`
+	Standing    float64 `json:"standing"`
+}
+
+// Client calls SeAT's v2 API with an API token
+type Client struct {
+	baseURL string
+	token   string
+	// http is nil for the default client
+	http *http.Client
+}
+
+// NewClient constructor; baseURL is the SeAT site, e.g. https://seat.example.com
+func NewClient(baseURL, token string) *Client {
+	return &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		token:   token,
+	}
+}
+
+// SetHTTPClient sends requests through client instead of the default one
+func (c *Client) SetHTTPClient(client *http.Client) {
+	c.http = client
+}
+
+// Contacts lists a corporation's contacts, following SeAT's pagination
+func (c *Client) Contacts(ctx context.Context, corporationID int) ([]Contact, error) {
+	var contacts []Contact
+	url := fmt.Sprintf("%s/api/v2/corporation/contacts/%d", c.baseURL, corporationID)
+	for page := 0; url != "" && page < maxPages; page++ {
+		var body struct {
+			Data  []Contact `json:"data"`
+			Links struct {
+				Next string `json:"next"`
+			} `json:"links"`
+		}
+		if err := c.getJSON(ctx, url, &body); err != nil {
+			return nil, err
+		}
+		contacts = append(contacts, body.Data...)
+		url = body.Links.Next
+		if url != "" && !strings.HasPrefix(url, c.baseURL+"/") {
+			// the token goes with every request, so never off the SeAT site
+			return nil, fmt.Errorf("seat pagination left %s for %s", c.baseURL, url)
+		}
+	}
+	return contacts, nil
+}
+
+func (c *Client) getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("X-Token", c.token)
+	resp, err := httpclient.Or(c.http).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("seat %s got status %d", strings.TrimPrefix(url, c.baseURL), resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 8<<20)).Decode(v)
+}
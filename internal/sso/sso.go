@@ -0,0 +1,303 @@
+// Package sso signs characters in through EVE SSO and keeps their refresh
+// tokens, so ESI endpoints that need authentication can be called.
+package sso
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/internal/httpclient"
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+)
+
+// EVE SSO v2 endpoints
+const (
+	AuthorizeURL = "https://login.eveonline.com/v2/oauth/authorize"
+	TokenURL     = "https://login.eveonline.com/v2/oauth/token"
+)
+
+// ESI scopes used by chainkills
+const (
+	ScopeStructures         = "esi-universe.read_structures.v1"
+	ScopeCorporationMembers = "esi-corporations.read_corporation_membership.v1"
+	ScopeFleet              = "esi-fleets.read_fleet.v1"
+	ScopeWaypoint           = "esi-ui.write_waypoint.v1"
+)
+
+// DefaultScopes are requested by Login when none are configured
+var DefaultScopes = []string{ScopeStructures, ScopeCorporationMembers, ScopeFleet, ScopeWaypoint}
+
+// Config identifies the application registered at developers.eveonline.com
+type Config struct {
+	ClientID string
+	// ClientSecret is optional; without it the PKCE flow for native apps is used
+	ClientSecret string
+	// CallbackURL must match the application's callback, e.g. http://localhost:8765/callback
+	CallbackURL string
+}
+
+// Client signs characters in and hands out their access tokens
+type Client struct {
+	logger   logger.Logger
+	config   Config
+	store    *Store
+	tokenURL string
+	// http is nil for the default client
+	http *http.Client
+
+	mu     sync.Mutex
+	access map[int64]accessToken
+}
+
+type accessToken struct {
+	value   string
+	expires time.Time
+}
+
+// NewClient constructor
+func NewClient(logger logger.Logger, config Config, store *Store) *Client {
+	return &Client{
+		logger:   logger,
+		config:   config,
+		store:    store,
+		tokenURL: TokenURL,
+		access:   map[int64]accessToken{},
+	}
+}
+
+// SetHTTPClient requests tokens through client instead of the default one
+func (c *Client) SetHTTPClient(client *http.Client) {
+	c.http = client
+}
+
+// Characters lists the signed-in characters
+func (c *Client) Characters() []Character {
+	return c.store.List()
+}
+
+// CharacterWithScope returns a signed-in character whose token was granted scope
+func (c *Client) CharacterWithScope(scope string) (int64, bool) {
+	for _, ch := range c.store.List() {
+		if ch.HasScope(scope) {
+			return ch.ID, true
+		}
+	}
+	return 0, false
+}
+
+// AccessToken returns a current access token for a signed-in character
+func (c *Client) AccessToken(ctx context.Context, characterID int64) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if at, ok := c.access[characterID]; ok && time.Now().Before(at.expires) {
+		return at.value, nil
+	}
+	ch, ok := c.store.Get(characterID)
+	if !ok {
+		return "", fmt.Errorf("character %d is not signed in", characterID)
+	}
+
+	tok, err := c.requestToken(ctx, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {ch.RefreshToken},
+	})
+	if err != nil {
+		return "", fmt.Errorf("refresh token for %s: %w", ch.Name, err)
+	}
+	c.access[characterID] = accessToken{value: tok.AccessToken, expires: tok.expires()}
+	if tok.RefreshToken != "" && tok.RefreshToken != ch.RefreshToken {
+		// EVE SSO rotates refresh tokens; the old one stops working
+		ch.RefreshToken = tok.RefreshToken
+		if err := c.store.Put(ch); err != nil {
+			c.logger.Errorf("[SSO] Error saving the new refresh token for %s: %v", ch.Name, err)
+		}
+	}
+	return tok.AccessToken, nil
+}
+
+// Login runs the authorization code flow: it listens on the callback URL,
+// calls open with the URL the user has to visit, and stores the character
+// that signs in with the granted scopes.
+func (c *Client) Login(ctx context.Context, scopes []string, open func(authURL string)) (Character, error) {
+	callback, err := url.Parse(c.config.CallbackURL)
+	if err != nil || callback.Host == "" {
+		return Character{}, fmt.Errorf("invalid callback URL %q", c.config.CallbackURL)
+	}
+	if len(scopes) == 0 {
+		scopes = DefaultScopes
+	}
+	state, err := randomString()
+	if err != nil {
+		return Character{}, err
+	}
+	verifier, err := randomString()
+	if err != nil {
+		return Character{}, err
+	}
+	challenge := sha256.Sum256([]byte(verifier))
+
+	ln, err := net.Listen("tcp", callback.Host)
+	if err != nil {
+		return Character{}, fmt.Errorf("listen for the SSO callback: %w", err)
+	}
+	codes := make(chan string, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc(valueOr(callback.Path, "/"), func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("state") != state || q.Get("code") == "" {
+			http.Error(w, "invalid SSO callback", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintln(w, "Signed in; you can close this window.")
+		select {
+		case codes <- q.Get("code"):
+		default:
+		}
+	})
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() { _ = srv.Serve(ln) }()
+	defer srv.Close()
+
+	authURL := AuthorizeURL + "?" + url.Values{
+		"response_type":         {"code"},
+		"redirect_uri":          {c.config.CallbackURL},
+		"client_id":             {c.config.ClientID},
+		"scope":                 {strings.Join(scopes, " ")},
+		"state":                 {state},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}.Encode()
+	open(authURL)
+
+	var code string
+	select {
+	case <-ctx.Done():
+		return Character{}, ctx.Err()
+	case code = <-codes:
+	}
+
+	tok, err := c.requestToken(ctx, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"code_verifier": {verifier},
+	})
+	if err != nil {
+		return Character{}, err
+	}
+	ch, err := characterFromJWT(tok.AccessToken)
+	if err != nil {
+		return Character{}, err
+	}
+	ch.RefreshToken = tok.RefreshToken
+	if err = c.store.Put(ch); err != nil {
+		return Character{}, err
+	}
+	c.mu.Lock()
+	c.access[ch.ID] = accessToken{value: tok.AccessToken, expires: tok.expires()}
+	c.mu.Unlock()
+	return ch, nil
+}
+
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+	Error        string `json:"error"`
+	Description  string `json:"error_description"`
+}
+
+// expires is a minute early so a token never expires in flight
+func (t tokenResponse) expires() time.Time {
+	lifetime := time.Duration(t.ExpiresIn)*time.Second - time.Minute
+	if lifetime <= 0 {
+		lifetime = time.Minute
+	}
+	return time.Now().Add(lifetime)
+}
+
+func (c *Client) requestToken(ctx context.Context, form url.Values) (tokenResponse, error) {
+	var tok tokenResponse
+	form.Set("client_id", c.config.ClientID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return tok, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if c.config.ClientSecret != "" {
+		req.SetBasicAuth(c.config.ClientID, c.config.ClientSecret)
+	}
+
+	client := httpclient.Or(c.http)
+	resp, err := client.Do(req)
+	if err != nil {
+		return tok, err
+	}
+	defer resp.Body.Close()
+
+	_ = json.NewDecoder(resp.Body).Decode(&tok)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 || tok.AccessToken == "" {
+		return tok, fmt.Errorf("SSO token request got status %d: %s", resp.StatusCode, strings.TrimSpace(tok.Error+" "+tok.Description))
+	}
+	return tok, nil
+}
+
+// characterFromJWT reads the character and scopes from an access token. The
+// token came straight from the SSO over TLS, so its signature isn't checked.
+func characterFromJWT(token string) (Character, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Character{}, errors.New("access token is not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return Character{}, fmt.Errorf("access token payload: %w", err)
+	}
+	var claims struct {
+		Sub  string          `json:"sub"`
+		Name string          `json:"name"`
+		Scp  json.RawMessage `json:"scp"`
+	}
+	if err = json.Unmarshal(payload, &claims); err != nil {
+		return Character{}, fmt.Errorf("access token claims: %w", err)
+	}
+	id, err := strconv.ParseInt(strings.TrimPrefix(claims.Sub, "CHARACTER:EVE:"), 10, 64)
+	if err != nil {
+		return Character{}, fmt.Errorf("access token subject %q: %w", claims.Sub, err)
+	}
+	ch := Character{ID: id, Name: claims.Name}
+	// scp is a string for a single scope, an array otherwise
+	var one string
+	if json.Unmarshal(claims.Scp, &one) == nil {
+		ch.Scopes = []string{one}
+	} else {
+		_ = json.Unmarshal(claims.Scp, &ch.Scopes)
+	}
+	return ch, nil
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func valueOr(s, fallback string) string {
+	if s == "" {
+		return fallback
+	}
+	return s
+}
@@ -0,0 +1,268 @@
+package sso
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+)
+
+func discardLogger() logger.Logger {
+	return logger.Slog(slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+// fakeJWT builds an unsigned access token carrying claims
+func fakeJWT(t *testing.T, claims map[string]interface{}) string {
+	t.Helper()
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return "eyJhbGciOiJub25lIn0." + base64.RawURLEncoding.EncodeToString(payload) + ".sig"
+}
+
+// fakeSSO answers token requests with the next entry in responses and records the forms it got
+type fakeSSO struct {
+	t         *testing.T
+	responses []func(w http.ResponseWriter, form url.Values)
+
+	mu    sync.Mutex
+	forms []url.Values
+	auth  []string
+}
+
+func (f *fakeSSO) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/x-www-form-urlencoded" {
+		http.Error(w, "bad token request", http.StatusBadRequest)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.mu.Lock()
+	n := len(f.forms)
+	f.forms = append(f.forms, r.PostForm)
+	f.auth = append(f.auth, r.Header.Get("Authorization"))
+	f.mu.Unlock()
+	if n >= len(f.responses) {
+		f.t.Errorf("unexpected token request %d: %v", n+1, r.PostForm)
+		http.Error(w, "unexpected", http.StatusInternalServerError)
+		return
+	}
+	f.responses[n](w, r.PostForm)
+}
+
+func tokenJSON(access, refresh string, expiresIn int) func(w http.ResponseWriter, form url.Values) {
+	return func(w http.ResponseWriter, form url.Values) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token":  access,
+			"refresh_token": refresh,
+			"expires_in":    expiresIn,
+			"token_type":    "Bearer",
+		})
+	}
+}
+
+func newTestClient(t *testing.T, sso *fakeSSO, config Config) (*Client, string) {
+	t.Helper()
+	srv := httptest.NewServer(sso)
+	t.Cleanup(srv.Close)
+	path := filepath.Join(t.TempDir(), "sso.json")
+	store, err := OpenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewClient(discardLogger(), config, store)
+	c.tokenURL = srv.URL + "/v2/oauth/token"
+	c.SetHTTPClient(srv.Client())
+	return c, path
+}
+
+// freeCallbackURL picks a loopback port for Login to listen on
+func freeCallbackURL(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return "http://" + addr + "/callback"
+}
+
+func TestLogin(t *testing.T) {
+	access := fakeJWT(t, map[string]interface{}{
+		"sub":  "CHARACTER:EVE:2112000001",
+		"name": "Pilot One",
+		"scp":  []string{ScopeStructures, ScopeFleet},
+	})
+	var challenge string
+	sso := &fakeSSO{t: t}
+	sso.responses = append(sso.responses, func(w http.ResponseWriter, form url.Values) {
+		verifier := sha256.Sum256([]byte(form.Get("code_verifier")))
+		if base64.RawURLEncoding.EncodeToString(verifier[:]) != challenge {
+			http.Error(w, `{"error":"invalid_grant","error_description":"PKCE verification failed"}`, http.StatusBadRequest)
+			return
+		}
+		tokenJSON(access, "refresh-1", 1199)(w, form)
+	})
+	callback := freeCallbackURL(t)
+	c, path := newTestClient(t, sso, Config{ClientID: "app", ClientSecret: "secret", CallbackURL: callback})
+
+	var callbacks []int
+	ch, err := c.Login(context.Background(), []string{ScopeStructures, ScopeFleet}, func(authURL string) {
+		u, err := url.Parse(authURL)
+		if err != nil {
+			t.Errorf("auth URL %q: %v", authURL, err)
+			return
+		}
+		q := u.Query()
+		challenge = q.Get("code_challenge")
+		if q.Get("client_id") != "app" || q.Get("redirect_uri") != callback || q.Get("scope") != ScopeStructures+" "+ScopeFleet ||
+			q.Get("code_challenge_method") != "S256" || q.Get("response_type") != "code" {
+			t.Errorf("auth URL query %v", q)
+		}
+		// a callback with someone else's state is rejected, and the login keeps waiting
+		for _, state := range []string{"forged", q.Get("state")} {
+			resp, err := http.Get(callback + "?" + url.Values{"code": {"auth-code"}, "state": {state}}.Encode())
+			if err != nil {
+				t.Errorf("callback: %v", err)
+				return
+			}
+			resp.Body.Close()
+			callbacks = append(callbacks, resp.StatusCode)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(callbacks) != 2 || callbacks[0] != http.StatusBadRequest || callbacks[1] != http.StatusOK {
+		t.Errorf("callbacks answered %v, want the forged state rejected and the real one accepted", callbacks)
+	}
+
+	if ch.ID != 2112000001 || ch.Name != "Pilot One" || ch.RefreshToken != "refresh-1" || !ch.HasScope(ScopeFleet) || ch.HasScope(ScopeWaypoint) {
+		t.Errorf("signed in %+v", ch)
+	}
+	form := sso.forms[0]
+	if form.Get("grant_type") != "authorization_code" || form.Get("code") != "auth-code" || form.Get("client_id") != "app" {
+		t.Errorf("token request form %v", form)
+	}
+	if want := "Basic " + base64.StdEncoding.EncodeToString([]byte("app:secret")); sso.auth[0] != want {
+		t.Errorf("token request Authorization %q, want %q", sso.auth[0], want)
+	}
+
+	// the access token from the login is used until it expires
+	if tok, err := c.AccessToken(context.Background(), ch.ID); err != nil || tok != access {
+		t.Errorf("AccessToken = %q, %v, want the login's token", tok, err)
+	}
+	reopened, err := OpenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved, ok := reopened.Get(ch.ID); !ok || saved.RefreshToken != "refresh-1" {
+		t.Errorf("stored character %+v, want the refresh token saved", saved)
+	}
+}
+
+func TestLoginCancelled(t *testing.T) {
+	c, _ := newTestClient(t, &fakeSSO{t: t}, Config{ClientID: "app", CallbackURL: freeCallbackURL(t)})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.Login(ctx, nil, func(string) {}); err != context.DeadlineExceeded {
+		t.Errorf("Login = %v, want the deadline", err)
+	}
+}
+
+func TestAccessTokenRefresh(t *testing.T) {
+	sso := &fakeSSO{t: t, responses: []func(http.ResponseWriter, url.Values){
+		tokenJSON("access-1", "refresh-2", 1199),
+		tokenJSON("access-2", "", 1199),
+		func(w http.ResponseWriter, form url.Values) {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = io.WriteString(w, `{"error":"invalid_grant","error_description":"Invalid refresh token. Token missing/expired."}`)
+		},
+	}}
+	c, path := newTestClient(t, sso, Config{ClientID: "app"})
+	if err := c.store.Put(Character{ID: 2112000001, Name: "Pilot One", RefreshToken: "refresh-1"}); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// the second call is served from the cache
+	for i := 0; i < 2; i++ {
+		if tok, err := c.AccessToken(ctx, 2112000001); err != nil || tok != "access-1" {
+			t.Fatalf("AccessToken = %q, %v, want access-1", tok, err)
+		}
+	}
+	if len(sso.forms) != 1 {
+		t.Fatalf("made %d token requests, want 1", len(sso.forms))
+	}
+	if form := sso.forms[0]; form.Get("grant_type") != "refresh_token" || form.Get("refresh_token") != "refresh-1" || form.Get("client_id") != "app" {
+		t.Errorf("refresh form %v", form)
+	}
+	if sso.auth[0] != "" {
+		t.Errorf("sent Authorization %q without a client secret", sso.auth[0])
+	}
+	// the rotated refresh token is saved
+	reopened, err := OpenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ch, _ := reopened.Get(2112000001); ch.RefreshToken != "refresh-2" {
+		t.Errorf("stored refresh token %q, want refresh-2", ch.RefreshToken)
+	}
+
+	// once the access token expires the rotated refresh token is used
+	c.access[2112000001] = accessToken{value: "access-1", expires: time.Now().Add(-time.Second)}
+	if tok, err := c.AccessToken(ctx, 2112000001); err != nil || tok != "access-2" {
+		t.Fatalf("AccessToken after expiry = %q, %v, want access-2", tok, err)
+	}
+	if got := sso.forms[1].Get("refresh_token"); got != "refresh-2" {
+		t.Errorf("second refresh used %q, want refresh-2", got)
+	}
+	// a response without a new refresh token keeps the old one
+	if ch, _ := c.store.Get(2112000001); ch.RefreshToken != "refresh-2" {
+		t.Errorf("refresh token %q after a response without one, want refresh-2", ch.RefreshToken)
+	}
+
+	c.access[2112000001] = accessToken{}
+	_, err = c.AccessToken(ctx, 2112000001)
+	if err == nil || !strings.Contains(err.Error(), "status 400") || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Errorf("AccessToken with a revoked token = %v, want the SSO's error", err)
+	}
+
+	if _, err = c.AccessToken(ctx, 2112000002); err == nil || !strings.Contains(err.Error(), "not signed in") {
+		t.Errorf("AccessToken for an unknown character = %v, want not signed in", err)
+	}
+}
+
+func TestCharacterFromJWT(t *testing.T) {
+	ch, err := characterFromJWT(fakeJWT(t, map[string]interface{}{"sub": "CHARACTER:EVE:93000001", "name": "Solo", "scp": ScopeWaypoint}))
+	if err != nil || ch.ID != 93000001 || ch.Name != "Solo" || len(ch.Scopes) != 1 || !ch.HasScope(ScopeWaypoint) {
+		t.Errorf("single scope token = %+v, %v", ch, err)
+	}
+	for _, token := range []string{
+		"not-a-jwt",
+		"a.!!!.c",
+		fakeJWT(t, map[string]interface{}{"sub": "CHARACTER:EVE:pilot"}),
+	} {
+		if _, err := characterFromJWT(token); err == nil {
+			t.Errorf("characterFromJWT(%q) succeeded", token)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package sso
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"sort"
+	"sync"
+)
+
+// Character is a signed-in character and the scopes its refresh token grants
+type Character struct {
+	ID           int64    `json:"character_id"`
+	Name         string   `json:"name"`
+	Scopes       []string `json:"scopes"`
+	RefreshToken string   `json:"refresh_token"`
+}
+
+// HasScope reports whether the character's token was granted scope
+func (c Character) HasScope(scope string) bool {
+	for _, s := range c.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Store keeps the signed-in characters in a JSON file, readable only by its owner
+type Store struct {
+	path string
+
+	mu         sync.RWMutex
+	characters map[int64]Character
+}
+
+// OpenStore reads the store at path; a missing file is an empty store
+func OpenStore(path string) (*Store, error) {
+	s := &Store{path: path, characters: map[int64]Character{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var chars []Character
+	if err = json.Unmarshal(data, &chars); err != nil {
+		return nil, err
+	}
+	for _, ch := range chars {
+		s.characters[ch.ID] = ch
+	}
+	return s, nil
+}
+
+// Get returns a signed-in character
+func (s *Store) Get(id int64) (Character, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ch, ok := s.characters[id]
+	return ch, ok
+}
+
+// List returns the signed-in characters by name
+func (s *Store) List() []Character {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	chars := make([]Character, 0, len(s.characters))
+	for _, ch := range s.characters {
+		chars = append(chars, ch)
+	}
+	sort.Slice(chars, func(i, j int) bool { return chars[i].Name < chars[j].Name })
+	return chars
+}
+
+// Put adds or replaces a character and saves the file
+func (s *Store) Put(ch Character) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.characters[ch.ID] = ch
+
+	chars := make([]Character, 0, len(s.characters))
+	for _, c := range s.characters {
+		chars = append(chars, c)
+	}
+	sort.Slice(chars, func(i, j int) bool { return chars[i].ID < chars[j].ID })
+	data, err := json.MarshalIndent(chars, "", "  ")
+	if err != nil {
+		return err
+	}
+	// write then rename, so a crash never leaves a truncated store
+	tmp := s.path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,62 @@
+package sso
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sso.json")
+	store, err := OpenStore(path)
+	if err != nil {
+		t.Fatalf("opening a missing store: %v", err)
+	}
+	if len(store.List()) != 0 {
+		t.Fatalf("new store lists %v", store.List())
+	}
+	for _, ch := range []Character{
+		{ID: 2, Name: "Zed", RefreshToken: "z"},
+		{ID: 1, Name: "Alice", Scopes: []string{ScopeFleet}, RefreshToken: "a"},
+		{ID: 2, Name: "Zed", RefreshToken: "z2"},
+	} {
+		if err := store.Put(ch); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("store mode %v, want 0600 since it holds refresh tokens", info.Mode().Perm())
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary file left behind: %v", err)
+	}
+
+	reopened, err := OpenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := reopened.List()
+	if len(list) != 2 || list[0].Name != "Alice" || list[1].Name != "Zed" || list[1].RefreshToken != "z2" || !list[0].HasScope(ScopeFleet) {
+		t.Errorf("reopened store lists %+v", list)
+	}
+
+	c := NewClient(discardLogger(), Config{}, reopened)
+	if id, ok := c.CharacterWithScope(ScopeFleet); !ok || id != 1 {
+		t.Errorf("CharacterWithScope(fleet) = %d, %v, want Alice", id, ok)
+	}
+	if _, ok := c.CharacterWithScope(ScopeWaypoint); ok {
+		t.Errorf("CharacterWithScope found a character for an ungranted scope")
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenStore(path); err == nil {
+		t.Errorf("opening a corrupt store succeeded")
+	}
+}
@@ -0,0 +1,92 @@
+// Package supervise recovers panics in long-running goroutines, logging the
+// stack and counting them per component, and restarts supervised components
+// so one bad payload can't take the whole process down.
+package supervise
+
+import (
+	"context"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+)
+
+// Supervisor counts panics per component
+type Supervisor struct {
+	logger logger.Logger
+
+	// OnPanic, if set, is called for every recovered panic without its own handler
+	OnPanic func(component string, recovered interface{}, stack []byte)
+
+	mu     sync.Mutex
+	panics map[string]int64
+}
+
+// New constructor
+func New(logger logger.Logger) *Supervisor {
+	return &Supervisor{logger: logger, panics: map[string]int64{}}
+}
+
+// Recover must be deferred directly. It stops a panic, logs the stack, counts
+// it against component, then calls onPanic (or OnPanic when onPanic is nil).
+func (s *Supervisor) Recover(component string, onPanic func(recovered interface{}, stack []byte)) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	stack := debug.Stack()
+	s.mu.Lock()
+	s.panics[component]++
+	s.mu.Unlock()
+	s.logger.Errorf("Recovered panic in %s: %v\n%s", component, r, stack)
+
+	switch {
+	case onPanic != nil:
+		onPanic(r, stack)
+	case s.OnPanic != nil:
+		s.OnPanic(component, r, stack)
+	}
+}
+
+// Run calls fn, restarting it with backoff whenever it panics, until fn
+// returns normally or ctx is done
+func (s *Supervisor) Run(ctx context.Context, component string, fn func(ctx context.Context)) {
+	backoff := time.Second
+	for {
+		if !s.call(ctx, component, fn) || ctx.Err() != nil {
+			return
+		}
+		s.logger.Printf("Restarting %s in %s", component, backoff)
+		t := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C:
+		}
+		if backoff *= 2; backoff > time.Minute {
+			backoff = time.Minute
+		}
+	}
+}
+
+// call runs fn once and reports whether it panicked
+func (s *Supervisor) call(ctx context.Context, component string, fn func(ctx context.Context)) (panicked bool) {
+	panicked = true
+	defer s.Recover(component, nil)
+	fn(ctx)
+	panicked = false
+	return
+}
+
+// Panics returns the number of recovered panics per component
+func (s *Supervisor) Panics() map[string]int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]int64, len(s.panics))
+	for k, v := range s.panics {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,88 @@
+package templates
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Field is one value a template can reach, e.g. ".Kill.Victim.ShipTypeID int"
+type Field struct {
+	Path string
+	Type string
+}
+
+// maxDepth stops the walk in deeply nested or recursive types
+const maxDepth = 4
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Fields lists the fields of v's type and the methods that take no
+// arguments, depth first. Slice elements show up as "[]", e.g.
+// ".Kill.Attackers[].ShipTypeID"; a template reaches them with range or index.
+func Fields(v interface{}) []Field {
+	var out []Field
+	walk(reflect.TypeOf(v), "", 0, &out)
+	return out
+}
+
+func walk(t reflect.Type, path string, depth int, out *[]Field) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Struct && t != timeType && depth < maxDepth {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			p := path + "." + f.Name
+			if f.Anonymous {
+				// promoted fields are reached without the embedded type's name
+				p = path
+			} else {
+				*out = append(*out, Field{Path: p, Type: typeName(f.Type)})
+			}
+			walk(f.Type, p, depth+1, out)
+		}
+		methods(t, path, out)
+		return
+	}
+	if (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && depth < maxDepth {
+		walk(t.Elem(), path+"[]", depth+1, out)
+	}
+}
+
+// methods lists the exported methods of t callable without arguments
+func methods(t reflect.Type, path string, out *[]Field) {
+	for _, mt := range []reflect.Type{t, reflect.PointerTo(t)} {
+		for i := 0; i < mt.NumMethod(); i++ {
+			m := mt.Method(i)
+			// the receiver is the only argument
+			if m.Type.NumIn() != 1 || m.Type.NumOut() != 1 {
+				continue
+			}
+			if mt != t {
+				if _, ok := t.MethodByName(m.Name); ok {
+					continue
+				}
+			}
+			*out = append(*out, Field{Path: path + "." + m.Name, Type: typeName(m.Type.Out(0)) + " (method)"})
+		}
+	}
+}
+
+// typeName is a short type name, e.g. "[]killmail.Attacker" or "time.Time"
+func typeName(t reflect.Type) string {
+	name := t.String()
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// String renders a field for listings
+func (f Field) String() string {
+	return fmt.Sprintf("%s %s", f.Path, f.Type)
+}
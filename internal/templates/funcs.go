@@ -0,0 +1,193 @@
+// Package templates is the function library for embed templates: helpers
+// that raw Go templates over a notification lack, and a description of the
+// fields and functions a template can use, for "chainkills templates funcs".
+package templates
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/guarzo/eve-chainkills/pkg/killmail"
+)
+
+// Lookups resolves what a template function can't work out from the kill alone
+type Lookups interface {
+	// Ticker is a corporation's or alliance's ticker
+	Ticker(ctx context.Context, id int) (string, error)
+	// Jumps is the number of jumps between two solar systems
+	Jumps(ctx context.Context, from, to int) (int, error)
+}
+
+// Func is one template function with its documentation
+type Func struct {
+	Name string
+	// Args is the call's shape, e.g. "value [format]"
+	Args string
+	Doc  string
+	// Example is a call and its result
+	Example string
+	Fn      interface{}
+}
+
+// Funcs is the function library. Lookups may be nil, in which case tickerFor
+// renders "" and jumpDistance -1.
+func Funcs(ctx context.Context, lookups Lookups) []Func {
+	return []Func{
+		{
+			Name:    "iskFormat",
+			Args:    "value [short|full|both]",
+			Doc:     "renders ISK like the iskFormat setting; defaults to short",
+			Example: `{{ iskFormat .Kill.TotalValue }} → 1.24b ISK`,
+			Fn: func(value float64, format ...string) string {
+				f := ""
+				if len(format) > 0 {
+					f = format[0]
+				}
+				return killmail.FormatISK(value, f)
+			},
+		},
+		{
+			Name:    "shortName",
+			Args:    "name [max]",
+			Doc:     "cuts a name to max characters (default 20) with an ellipsis",
+			Example: `{{ shortName .Kill.VictimCorpName 12 }} → Some Very L…`,
+			Fn: func(name string, max ...int) string {
+				n := 20
+				if len(max) > 0 && max[0] > 0 {
+					n = max[0]
+				}
+				return shorten(name, n)
+			},
+		},
+		{
+			Name:    "tickerFor",
+			Args:    "id",
+			Doc:     "the ticker of a corporation or alliance ID, or \"\" when unknown",
+			Example: `[{{ tickerFor .Kill.Victim.CorporationID }}] → [TICKR]`,
+			Fn: func(id interface{}) string {
+				n, ok := toInt(id)
+				if lookups == nil || !ok || n == 0 {
+					return ""
+				}
+				ticker, err := lookups.Ticker(ctx, n)
+				if err != nil {
+					return ""
+				}
+				return ticker
+			},
+		},
+		{
+			Name:    "jumpDistance",
+			Args:    "fromSystemID toSystemID",
+			Doc:     "jumps between two systems through k-space gates, or -1 when there's no route",
+			Example: `{{ jumpDistance .Kill.SolarSystemID 30000142 }} → 7`,
+			Fn: func(from, to int) int {
+				if lookups == nil {
+					return -1
+				}
+				jumps, err := lookups.Jumps(ctx, from, to)
+				if err != nil {
+					return -1
+				}
+				return jumps
+			},
+		},
+		{
+			Name:    "relativeTime",
+			Args:    "time",
+			Doc:     "how long ago a time was, or how far ahead",
+			Example: `{{ relativeTime .Kill.KillMailTime }} → 3 minutes ago`,
+			Fn: func(t time.Time) string {
+				return relative(time.Since(t))
+			},
+		},
+		{
+			Name:    "zkillLink",
+			Args:    "kind id",
+			Doc:     "a zKillboard link; kind is kill, character, corporation, alliance, system or ship",
+			Example: `{{ zkillLink "character" .Kill.Victim.CharacterID }} → https://zkillboard.com/character/2112345678/`,
+			Fn: func(kind string, id interface{}) string {
+				return fmt.Sprintf("https://zkillboard.com/%s/%v/", kind, id)
+			},
+		},
+		{
+			Name:    "json",
+			Args:    "value",
+			Doc:     "encodes a value as JSON, e.g. to quote a string in a JSON payload",
+			Example: `{{ json .Kill.SystemName }} → "J123456"`,
+			Fn: func(v interface{}) (string, error) {
+				b, err := json.Marshal(v)
+				return string(b), err
+			},
+		},
+	}
+}
+
+// FuncMap is the library for template.Funcs
+func FuncMap(ctx context.Context, lookups Lookups) template.FuncMap {
+	m := template.FuncMap{}
+	for _, f := range Funcs(ctx, lookups) {
+		m[f.Name] = f.Fn
+	}
+	return m
+}
+
+// toInt takes any integer, since the models mix int and int64 IDs
+func toInt(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case int32:
+		return int(n), true
+	case int64:
+		return int(n), true
+	}
+	return 0, false
+}
+
+func shorten(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-1]) + "…"
+}
+
+// relative renders a duration as "3 minutes ago", or "in 3 minutes" when negative
+func relative(d time.Duration) string {
+	future := d < 0
+	if future {
+		d = -d
+	}
+	var text string
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		text = unit(int(d/time.Minute), "minute")
+	case d < 24*time.Hour:
+		text = unit(int(d/time.Hour), "hour")
+	default:
+		text = unit(int(d/(24*time.Hour)), "day")
+	}
+	if future {
+		return "in " + text
+	}
+	return text + " ago"
+}
+
+func unit(n int, name string) string {
+	if n == 1 {
+		return "1 " + name
+	}
+	return fmt.Sprintf("%d %ss", n, name)
+}
+
+// Signature renders a function for listings, e.g. "iskFormat value [short|full|both]"
+func (f Func) Signature() string {
+	return strings.TrimSpace(f.Name + " " + f.Args)
+}
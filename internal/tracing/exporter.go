@@ -0,0 +1,279 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/internal/httpclient"
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+)
+
+const (
+	exportBatchSize = 256
+	exportInterval  = 5 * time.Second
+	exportQueueSize = 4096
+	// a collector that is down fails every export; one log line a minute is enough
+	exportLogInterval = time.Minute
+)
+
+// Exporter batches finished spans and POSTs them to {endpoint}/v1/traces
+type Exporter struct {
+	logger logger.Logger
+	config Config
+	queue  chan *Span
+	flush  chan chan struct{}
+	done   chan struct{}
+	once   sync.Once
+
+	failures atomic.Int64
+	// lastLog and suppressed are only touched by loop
+	lastLog    time.Time
+	suppressed int
+}
+
+func newExporter(log logger.Logger, config Config) *Exporter {
+	e := &Exporter{
+		logger: log,
+		config: config,
+		queue:  make(chan *Span, exportQueueSize),
+		flush:  make(chan chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go e.loop()
+	return e
+}
+
+// enqueue drops the span rather than block the pipeline when the queue is full
+func (e *Exporter) enqueue(s *Span) {
+	select {
+	case e.queue <- s:
+	default:
+	}
+}
+
+func (e *Exporter) loop() {
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+	var batch []*Span
+	send := func() {
+		if len(batch) > 0 {
+			if err := e.export(batch); err != nil {
+				e.exportFailed(len(batch), err)
+			}
+			batch = nil
+		}
+	}
+	for {
+		select {
+		case s := <-e.queue:
+			batch = append(batch, s)
+			if len(batch) >= exportBatchSize {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case ack := <-e.flush:
+			for drained := false; !drained; {
+				select {
+				case s := <-e.queue:
+					batch = append(batch, s)
+				default:
+					drained = true
+				}
+			}
+			send()
+			close(ack)
+		case <-e.done:
+			return
+		}
+	}
+}
+
+// exportFailed counts a failed export and logs it, at most once every
+// exportLogInterval with the number of failures held back since
+func (e *Exporter) exportFailed(spans int, err error) {
+	e.failures.Add(1)
+	if time.Since(e.lastLog) < exportLogInterval {
+		e.suppressed++
+		return
+	}
+	more := ""
+	if e.suppressed > 0 {
+		more = fmt.Sprintf(" (%d more failed exports since the last report)", e.suppressed)
+	}
+	e.logger.Warnf("[Tracing] Dropped %d spans: %v%s", spans, err, more)
+	e.lastLog, e.suppressed = time.Now(), 0
+}
+
+// Failures is the number of exports that failed since the exporter started
+func (e *Exporter) Failures() int64 {
+	return e.failures.Load()
+}
+
+// QueueLen is the number of finished spans waiting to be exported
+func (e *Exporter) QueueLen() int {
+	return len(e.queue)
+}
+
+// Shutdown exports any queued spans and stops the exporter
+func (e *Exporter) Shutdown(ctx context.Context) error {
+	var err error
+	e.once.Do(func() {
+		exporter.CompareAndSwap(e, nil)
+		ack := make(chan struct{})
+		select {
+		case e.flush <- ack:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		if err == nil {
+			select {
+			case <-ack:
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
+		}
+		close(e.done)
+	})
+	return err
+}
+
+// OTLP JSON shapes, see opentelemetry-proto's trace/v1 and its JSON mapping
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              SpanKind        `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpScopeSpans struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResourceSpans struct {
+	Resource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	} `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+func (e *Exporter) export(batch []*Span) error {
+	var scope otlpScopeSpans
+	scope.Scope.Name = "github.com/guarzo/eve-chainkills"
+	for _, s := range batch {
+		scope.Spans = append(scope.Spans, s.toOTLP())
+	}
+	var rs otlpResourceSpans
+	rs.Resource.Attributes = []otlpAttribute{attribute("service.name", e.config.ServiceName)}
+	rs.ScopeSpans = []otlpScopeSpans{scope}
+	req := otlpRequest{ResourceSpans: []otlpResourceSpans{rs}}
+
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	url := strings.TrimRight(e.config.Endpoint, "/") + "/v1/traces"
+	httpReq, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(payload))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	for k, v := range e.config.Headers {
+		httpReq.Header.Set(k, v)
+	}
+
+	client := httpclient.Or(e.config.HTTPClient)
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("otlp export got status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *Span) toOTLP() otlpSpan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := otlpSpan{
+		TraceID:           hex.EncodeToString(s.traceID[:]),
+		SpanID:            hex.EncodeToString(s.spanID[:]),
+		Name:              s.name,
+		Kind:              s.kind,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+		Status:            otlpStatus{Code: 1},
+	}
+	if s.parentID != [8]byte{} {
+		out.ParentSpanID = hex.EncodeToString(s.parentID[:])
+	}
+	for k, v := range s.attrs {
+		out.Attributes = append(out.Attributes, attribute(k, v))
+	}
+	if s.err != nil {
+		out.Status = otlpStatus{Code: 2, Message: s.err.Error()}
+	}
+	return out
+}
+
+func attribute(key string, v interface{}) otlpAttribute {
+	var val otlpValue
+	switch tv := v.(type) {
+	case string:
+		val.StringValue = &tv
+	case bool:
+		val.BoolValue = &tv
+	case int:
+		s := strconv.Itoa(tv)
+		val.IntValue = &s
+	case int64:
+		s := strconv.FormatInt(tv, 10)
+		val.IntValue = &s
+	case float64:
+		val.DoubleValue = &tv
+	default:
+		s := fmt.Sprint(tv)
+		val.StringValue = &s
+	}
+	return otlpAttribute{Key: key, Value: val}
+}
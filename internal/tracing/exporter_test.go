@@ -0,0 +1,143 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+)
+
+func discardLogger() logger.Logger {
+	return logger.Slog(slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+// collector records the OTLP requests it receives and answers with status
+type collector struct {
+	status int
+
+	mu       sync.Mutex
+	requests []otlpRequest
+	headers  []http.Header
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/v1/traces" || r.Method != http.MethodPost {
+		http.NotFound(w, r)
+		return
+	}
+	var req otlpRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	c.requests = append(c.requests, req)
+	c.headers = append(c.headers, r.Header.Clone())
+	c.mu.Unlock()
+	w.WriteHeader(c.status)
+}
+
+func TestExporterSendsSpans(t *testing.T) {
+	col := &collector{status: http.StatusOK}
+	srv := httptest.NewServer(col)
+	defer srv.Close()
+
+	exp, err := Init(discardLogger(), Config{Endpoint: srv.URL + "/", Headers: map[string]string{"X-Api-Key": "secret"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, root := Start(context.Background(), "kill", KindInternal)
+	root.SetAttr("killmail.id", int64(123))
+	root.SetAttr("replay", true)
+	_, child := Start(ctx, "deliver discord", KindClient)
+	child.RecordError(errors.New("webhook got status 500"))
+	child.End()
+	root.End()
+	if err := exp.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(col.requests) != 1 {
+		t.Fatalf("collector got %d requests, want 1", len(col.requests))
+	}
+	if got := col.headers[0].Get("X-Api-Key"); got != "secret" {
+		t.Errorf("X-Api-Key header = %q, want secret", got)
+	}
+	rs := col.requests[0].ResourceSpans
+	if len(rs) != 1 || len(rs[0].ScopeSpans) != 1 {
+		t.Fatalf("want one resource and scope, got %+v", rs)
+	}
+	if attr := rs[0].Resource.Attributes; len(attr) != 1 || attr[0].Key != "service.name" || *attr[0].Value.StringValue != "eve-chainkills" {
+		t.Errorf("resource attributes = %+v, want service.name eve-chainkills", attr)
+	}
+	spans := map[string]otlpSpan{}
+	for _, s := range rs[0].ScopeSpans[0].Spans {
+		spans[s.Name] = s
+	}
+	kill, deliver := spans["kill"], spans["deliver discord"]
+	if kill.SpanID == "" || deliver.SpanID == "" {
+		t.Fatalf("missing spans, got %+v", spans)
+	}
+	if len(kill.TraceID) != 32 || deliver.TraceID != kill.TraceID {
+		t.Errorf("trace IDs %q and %q, want the same 32 hex digits", kill.TraceID, deliver.TraceID)
+	}
+	if kill.ParentSpanID != "" || deliver.ParentSpanID != kill.SpanID {
+		t.Errorf("parents %q and %q, want none and %q", kill.ParentSpanID, deliver.ParentSpanID, kill.SpanID)
+	}
+	if kill.Kind != KindInternal || deliver.Kind != KindClient {
+		t.Errorf("kinds %d and %d, want %d and %d", kill.Kind, deliver.Kind, KindInternal, KindClient)
+	}
+	if kill.StartTimeUnixNano == "" || kill.EndTimeUnixNano < kill.StartTimeUnixNano {
+		t.Errorf("kill span runs from %s to %s", kill.StartTimeUnixNano, kill.EndTimeUnixNano)
+	}
+	attrs := map[string]otlpValue{}
+	for _, a := range kill.Attributes {
+		attrs[a.Key] = a.Value
+	}
+	if v := attrs["killmail.id"].IntValue; v == nil || *v != "123" {
+		t.Errorf("killmail.id = %+v, want intValue \"123\"", attrs["killmail.id"])
+	}
+	if v := attrs["replay"].BoolValue; v == nil || !*v {
+		t.Errorf("replay = %+v, want boolValue true", attrs["replay"])
+	}
+	// OTLP status codes: 1 is OK, 2 is ERROR with the message
+	if kill.Status != (otlpStatus{Code: 1}) {
+		t.Errorf("kill status = %+v, want code 1", kill.Status)
+	}
+	if deliver.Status != (otlpStatus{Code: 2, Message: "webhook got status 500"}) {
+		t.Errorf("deliver status = %+v, want code 2 with the error", deliver.Status)
+	}
+	if exp.Failures() != 0 {
+		t.Errorf("Failures() = %d, want 0", exp.Failures())
+	}
+}
+
+func TestExporterCountsFailedExports(t *testing.T) {
+	col := &collector{status: http.StatusServiceUnavailable}
+	srv := httptest.NewServer(col)
+	defer srv.Close()
+
+	exp := newExporter(discardLogger(), Config{Endpoint: srv.URL, ServiceName: "test"})
+	defer close(exp.done)
+	span := &Span{name: "kill", kind: KindInternal}
+	err := exp.export([]*Span{span})
+	if err == nil || !strings.Contains(err.Error(), "status 503") {
+		t.Fatalf("export error = %v, want status 503", err)
+	}
+	exp.exportFailed(1, err)
+	exp.exportFailed(1, err)
+	if exp.Failures() != 2 {
+		t.Errorf("Failures() = %d, want 2", exp.Failures())
+	}
+	if exp.suppressed != 1 {
+		t.Errorf("suppressed = %d, want the second failure held back", exp.suppressed)
+	}
+}
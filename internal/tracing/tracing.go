@@ -0,0 +1,153 @@
+// Package tracing records spans for each kill's trip through the pipeline and
+// exports them to an OpenTelemetry collector over OTLP/HTTP (JSON encoding).
+// Tracing is a no-op until Init is called with an endpoint.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+)
+
+// Config selects the OTLP collector
+type Config struct {
+	// Endpoint is the collector's base URL, e.g. http://localhost:4318; empty disables tracing
+	Endpoint    string            `json:"endpoint"`
+	ServiceName string            `json:"serviceName"` // default "eve-chainkills"
+	Headers     map[string]string `json:"headers"`
+	// HTTPClient sends the exports; nil uses the default client
+	HTTPClient *http.Client `json:"-"`
+}
+
+// SpanKind mirrors the OTLP span kinds we use
+type SpanKind int
+
+const (
+	KindInternal SpanKind = 1
+	KindClient   SpanKind = 3
+)
+
+// Span is one timed operation
+type Span struct {
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+	name     string
+	kind     SpanKind
+	start    time.Time
+
+	mu    sync.Mutex
+	end   time.Time
+	attrs map[string]interface{}
+	err   error
+	ended bool
+}
+
+// SetAttr records a string, bool, int or float attribute
+func (s *Span) SetAttr(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.attrs == nil {
+		s.attrs = map[string]interface{}{}
+	}
+	s.attrs[key] = value
+}
+
+// RecordError marks the span as failed
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.err = err
+	s.mu.Unlock()
+}
+
+// End finishes the span and queues it for export
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mu.Unlock()
+	if exp := exporter.Load(); exp != nil {
+		exp.enqueue(s)
+	}
+}
+
+// TraceID returns the hex trace ID, or "" for a nil span
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return hex.EncodeToString(s.traceID[:])
+}
+
+// Traceparent is the W3C trace context header value that makes a request's
+// receiver a child of the span, or "" for a nil span
+func (s *Span) Traceparent() string {
+	if s == nil {
+		return ""
+	}
+	return fmt.Sprintf("00-%s-%s-01", hex.EncodeToString(s.traceID[:]), hex.EncodeToString(s.spanID[:]))
+}
+
+type spanKey struct{}
+
+// exporter is nil until Init is called, which makes Start a no-op
+var exporter atomic.Pointer[Exporter]
+
+// Start begins a span as a child of the span in ctx, if any. When tracing is
+// disabled it returns ctx unchanged and a nil span, whose methods are no-ops.
+func Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	if exporter.Load() == nil {
+		return ctx, nil
+	}
+	s := &Span{name: name, kind: kind, start: time.Now()}
+	if parent := FromContext(ctx); parent != nil {
+		s.traceID = parent.traceID
+		s.parentID = parent.spanID
+	} else {
+		_, _ = rand.Read(s.traceID[:])
+	}
+	_, _ = rand.Read(s.spanID[:])
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// FromContext returns the current span, or nil
+func FromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// Init starts exporting spans to config.Endpoint, logging failed exports to
+// log. Calling it again replaces the previous exporter after flushing it.
+func Init(log logger.Logger, config Config) (*Exporter, error) {
+	if config.Endpoint == "" {
+		return nil, fmt.Errorf("tracing endpoint not configured")
+	}
+	if config.ServiceName == "" {
+		config.ServiceName = "eve-chainkills"
+	}
+	exp := newExporter(log, config)
+	if old := exporter.Swap(exp); old != nil {
+		_ = old.Shutdown(context.Background())
+	}
+	return exp, nil
+}
@@ -0,0 +1,32 @@
+package zkill
+
+import (
+	"testing"
+
+	"github.com/guarzo/eve-chainkills/internal/benchdata"
+)
+
+func BenchmarkScanIDs(b *testing.B) {
+	for _, k := range benchdata.Kills() {
+		b.Run(k.Name, func(b *testing.B) {
+			b.SetBytes(int64(len(k.Raw)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				// the worst case: no ID is tracked, so every one is read
+				ScanIDs(k.Raw, func(key []byte, id int64) bool { return false })
+			}
+		})
+	}
+}
+
+func BenchmarkPeek(b *testing.B) {
+	for _, k := range benchdata.Kills() {
+		b.Run(k.Name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				Peek(k.Raw)
+				PeekID(k.Raw)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+// Package zkill maintains the zKillboard killstream websocket connection.
+package zkill
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+)
+
+// DefaultURL is zKillboard's public websocket endpoint
+const DefaultURL = "wss://zkillboard.com/websocket/"
+
+// MaxFrameSize caps a killstream frame; the largest killmails are well under
+// a megabyte, and anything bigger drops the connection rather than memory
+const MaxFrameSize = 2 << 20
+
+// DefaultChannel is zKillboard's channel carrying every kill
+const DefaultChannel = "killstream"
+
+// Client subscribes to the killstream and hands every frame to a handler,
+// reconnecting whenever the socket drops.
+type Client struct {
+	logger logger.Logger
+	url    string
+
+	// Channels are subscribed to instead of the whole killstream, e.g.
+	// "region:10000060" or "corporation:98000001"; kills in more than one
+	// arrive once per channel
+	Channels []string
+
+	// OnOpen is called after every successful (re)subscription
+	OnOpen func(ctx context.Context)
+	// OnClose is called when the socket drops, before reconnecting
+	OnClose func(err error)
+	// Quiet, when it returns true, logs dial errors and reconnects at debug
+	// level, e.g. during EVE's downtime
+	Quiet func() bool
+	// ReconnectDelay is the wait before redialing; defaults to 10s
+	ReconnectDelay time.Duration
+	// PingInterval sends a websocket ping this often; 0 sends none
+	PingInterval time.Duration
+	// OnPong is called for every websocket pong received
+	OnPong func()
+
+	conn       *websocket.Conn
+	cancelFunc context.CancelFunc
+	mu         sync.Mutex
+}
+
+// NewClient constructor
+func NewClient(logger logger.Logger, url string) *Client {
+	if url == "" {
+		url = DefaultURL
+	}
+	return &Client{
+		logger: logger,
+		url:    url,
+	}
+}
+
+// Run attempts a (re)connection to the zKillboard feed until ctx is done,
+// calling handle in its own goroutine for every message received
+func (c *Client) Run(ctx context.Context, handle func(raw []byte)) {
+	reconnectDelay := c.ReconnectDelay
+	if reconnectDelay <= 0 {
+		reconnectDelay = 10 * time.Second
+	}
+	for ctx.Err() == nil {
+		connected, err := c.session(ctx, handle)
+		if ctx.Err() != nil {
+			break
+		}
+		logf := c.logger.Printf
+		if c.Quiet != nil && c.Quiet() {
+			logf = c.logger.Debugf
+		}
+		if !connected {
+			logf("WebSocket dial error: %v. Retrying in %s ...", err, reconnectDelay)
+			sleepCtx(ctx, reconnectDelay)
+			continue
+		}
+		if c.OnClose != nil {
+			c.OnClose(err)
+		}
+		logf("Socket closed; reattempting in %s", reconnectDelay)
+		sleepCtx(ctx, reconnectDelay)
+	}
+	c.logger.Println("zKill listener stopped.")
+}
+
+// session dials, subscribes and reads until the socket drops. The deferred
+// cleanup also runs if handle or the read loop panics.
+func (c *Client) session(ctx context.Context, handle func(raw []byte)) (bool, error) {
+	connCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	c.mu.Lock()
+	c.cancelFunc = cancel
+	c.mu.Unlock()
+
+	conn, _, err := websocket.DefaultDialer.DialContext(connCtx, c.url, nil)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	c.logger.Printf("Connected to zKillboard feed.")
+	c.mu.Lock()
+	c.conn = conn
+	c.mu.Unlock()
+	// unblock the read loop when ctx is cancelled or Close is called
+	stop := context.AfterFunc(connCtx, func() { conn.Close() })
+	defer stop()
+
+	// subscribe to the killstream, or to each configured channel
+	channels := c.Channels
+	if len(channels) == 0 {
+		channels = []string{DefaultChannel}
+	}
+	for _, channel := range channels {
+		subMessage := map[string]string{
+			"action":  "sub",
+			"channel": channel,
+		}
+		if err = conn.WriteJSON(subMessage); err != nil {
+			c.logger.Printf("Error sending sub message to zKill: %v", err)
+			return true, err
+		}
+		c.logger.Printf("Sent sub message to zkill: %+v", subMessage)
+	}
+	if c.OnOpen != nil {
+		c.OnOpen(connCtx)
+	}
+
+	if c.PingInterval > 0 {
+		go c.pingLoop(connCtx, conn)
+	}
+
+	// read messages in a loop
+	if err = c.readLoop(conn, handle); err != nil && connCtx.Err() == nil {
+		c.logger.Printf("readLoop error: %v", err)
+	}
+	return true, err
+}
+
+// sleepCtx waits for d or until ctx is done, whichever comes first
+func sleepCtx(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-t.C:
+	}
+}
+
+// pingLoop pings the server until the session ends; pongs are handled by the read loop
+func (c *Client) pingLoop(ctx context.Context, conn *websocket.Conn) {
+	ticker := time.NewTicker(c.PingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(c.PingInterval)); err != nil {
+			c.logger.Debugf("Error sending websocket ping: %v", err)
+			return
+		}
+	}
+}
+
+// readLoop reads from the websocket until an error
+func (c *Client) readLoop(conn *websocket.Conn, handle func(raw []byte)) error {
+	conn.SetReadLimit(MaxFrameSize)
+	if c.OnPong != nil {
+		conn.SetPongHandler(func(string) error {
+			c.OnPong()
+			return nil
+		})
+	}
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		go handle(message)
+	}
+}
+
+// Close tears down the current connection
+func (c *Client) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn != nil {
+		c.conn.Close()
+	}
+	if c.cancelFunc != nil {
+		c.cancelFunc()
+	}
+}
@@ -0,0 +1,65 @@
+package zkill
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/internal/zkilltest"
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+)
+
+func TestClientReceivesAndReconnects(t *testing.T) {
+	srv := zkilltest.NewServer()
+	defer srv.Close()
+	srv.Ack = []byte(`{"action":"tqStatus","tqStatus":"ONLINE"}`)
+
+	c := NewClient(logger.Slog(slog.New(slog.NewTextHandler(io.Discard, nil))), srv.URL())
+	c.ReconnectDelay = 10 * time.Millisecond
+	opened := make(chan struct{}, 4)
+	c.OnOpen = func(context.Context) { opened <- struct{}{} }
+	closed := make(chan error, 4)
+	c.OnClose = func(err error) { closed <- err }
+	frames := make(chan []byte, 16)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		c.Run(ctx, func(raw []byte) { frames <- raw })
+		close(done)
+	}()
+
+	if err := srv.WaitSubscriptions(ctx, 1); err != nil {
+		t.Fatalf("no subscription: %v", err)
+	}
+	<-opened
+	if got := Classify(<-frames); got != FrameAction {
+		t.Errorf("ack classified as %s, want %s", got, FrameAction)
+	}
+	srv.Send([]byte(`{"killmail_id":123,"solar_system_id":31000123}`))
+	if got := Classify(<-frames); got != FrameKillmail {
+		t.Errorf("kill classified as %s, want %s", got, FrameKillmail)
+	}
+
+	srv.Disconnect()
+	select {
+	case <-closed:
+	case <-ctx.Done():
+		t.Fatal("OnClose not called after the server dropped the connection")
+	}
+	if err := srv.WaitSubscriptions(ctx, 2); err != nil {
+		t.Fatalf("did not resubscribe: %v", err)
+	}
+	<-opened
+	<-frames // the ack again
+	srv.Send([]byte("ping"))
+	if got := Classify(<-frames); got != FramePing {
+		t.Errorf("ping classified as %s, want %s", got, FramePing)
+	}
+
+	cancel()
+	<-done
+}
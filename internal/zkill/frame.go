@@ -0,0 +1,149 @@
+package zkill
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frame is what kind of payload a killstream message carries
+type Frame string
+
+// Frame kinds
+const (
+	FrameKillmail Frame = "killmail"
+	// FramePing is a keepalive, either bare text or {"action":"ping"}
+	FramePing Frame = "ping"
+	// FrameAction is any other {"action":...} message, e.g. an announcement or tqStatus
+	FrameAction Frame = "action"
+	FrameEmpty  Frame = "empty"
+	// FrameUnknown is valid JSON without a killmail ID or an action
+	FrameUnknown   Frame = "unknown"
+	FrameMalformed Frame = "malformed"
+)
+
+// Classify tells killmails apart from everything else the killstream sends,
+// without decoding the whole message
+func Classify(raw []byte) Frame {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return FrameEmpty
+	}
+	if raw[0] != '{' {
+		switch strings.ToLower(string(raw)) {
+		case "ping", "pong":
+			return FramePing
+		}
+		return FrameMalformed
+	}
+	var probe struct {
+		KillmailID json.RawMessage `json:"killmail_id"`
+		Action     string          `json:"action"`
+	}
+	if json.Unmarshal(raw, &probe) != nil {
+		return FrameMalformed
+	}
+	if id, err := strconv.ParseInt(string(bytes.Trim(probe.KillmailID, `"`)), 10, 64); err == nil && id > 0 {
+		return FrameKillmail
+	}
+	switch strings.ToLower(probe.Action) {
+	case "":
+		return FrameUnknown
+	case "ping", "pong":
+		return FramePing
+	}
+	return FrameAction
+}
+
+// Peek reads a killmail's solar system and time straight from the raw
+// message, for counting every kill on the stream without decoding it. A
+// zero system or time means the field wasn't found.
+func Peek(raw []byte) (systemID int, killTime time.Time) {
+	if v := field(raw, `"solar_system_id":`); v != nil {
+		end := 0
+		for end < len(v) && v[end] >= '0' && v[end] <= '9' {
+			end++
+		}
+		systemID, _ = strconv.Atoi(string(v[:end]))
+	}
+	if v := field(raw, `"killmail_time":`); len(v) > 0 && v[0] == '"' {
+		if end := bytes.IndexByte(v[1:], '"'); end > 0 {
+			killTime, _ = time.Parse(time.RFC3339, string(v[1:end+1]))
+		}
+	}
+	return systemID, killTime
+}
+
+// PeekID reads a killmail's ID straight from the raw message, or 0
+func PeekID(raw []byte) int64 {
+	v := field(raw, `"killmail_id":`)
+	end := 0
+	for end < len(v) && v[end] >= '0' && v[end] <= '9' {
+		end++
+	}
+	id, _ := strconv.ParseInt(string(v[:end]), 10, 64)
+	return id
+}
+
+// field returns what follows key in raw, past any whitespace, or nil. Only
+// the top level of a killmail has these keys, so the first match is the one.
+func field(raw []byte, key string) []byte {
+	i := bytes.Index(raw, []byte(key))
+	if i < 0 {
+		return nil
+	}
+	return bytes.TrimLeft(raw[i+len(key):], " \t\r\n")
+}
+
+// ScanIDs calls fn with every numeric ID in a raw killmail, at any depth: the
+// values of keys ending in "_id", e.g. "corporation_id", and of "locationID".
+// It decodes nothing and doesn't allocate, so it can look at every kill on the
+// stream; it stops early and returns true once fn does.
+func ScanIDs(raw []byte, fn func(key []byte, id int64) bool) bool {
+	for i := 0; i < len(raw); i++ {
+		if raw[i] != '"' {
+			continue
+		}
+		// a string, maybe a key; skip to its closing quote
+		start := i + 1
+		i = start
+		for i < len(raw) && raw[i] != '"' {
+			if raw[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		if i >= len(raw) {
+			return false
+		}
+		key := raw[start:i]
+		if !bytes.HasSuffix(key, []byte("_id")) && !bytes.Equal(key, []byte("locationID")) {
+			continue
+		}
+		j := skipSpace(raw, i+1)
+		if j >= len(raw) || raw[j] != ':' {
+			continue
+		}
+		j = skipSpace(raw, j+1)
+		var id int64
+		digits := 0
+		for ; j < len(raw) && raw[j] >= '0' && raw[j] <= '9' && digits < 18; j++ {
+			id = id*10 + int64(raw[j]-'0')
+			digits++
+		}
+		if digits > 0 && fn(key, id) {
+			return true
+		}
+		i = j - 1
+	}
+	return false
+}
+
+func skipSpace(raw []byte, i int) int {
+	for i < len(raw) && (raw[i] == ' ' || raw[i] == '\t' || raw[i] == '\r' || raw[i] == '\n') {
+		i++
+	}
+	return i
+}
@@ -0,0 +1,31 @@
+package zkill
+
+import "testing"
+
+func FuzzFrame(f *testing.F) {
+	for _, seed := range []string{
+		`{"killmail_id":123,"killmail_time":"2024-05-01T12:00:00Z","solar_system_id":31000001}`,
+		`{"killmail_id":"123"}`,
+		`{"killmail_id":-5,"solar_system_id":-31000001}`,
+		`{"solar_system_id":99999999999999999999999,"killmail_time":"`,
+		`{"action":"ping"}`,
+		`ping`,
+		`{"killmail_time":"2024-05-01T12:00:00Z"`,
+		``,
+	} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, raw []byte) {
+		_ = Classify(raw)
+		_ = PeekID(raw)
+		ScanIDs(raw, func(key []byte, id int64) bool {
+			if id < 0 {
+				t.Fatalf("negative %s %d from %q", key, id, raw)
+			}
+			return false
+		})
+		if systemID, _ := Peek(raw); systemID < 0 {
+			t.Fatalf("negative system %d from %q", systemID, raw)
+		}
+	})
+}
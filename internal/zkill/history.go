@@ -0,0 +1,102 @@
+package zkill
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/internal/httpclient"
+	"github.com/guarzo/eve-chainkills/internal/schema"
+	"github.com/guarzo/eve-chainkills/pkg/killmail"
+)
+
+// HistoryURL is zKillboard's REST API
+const HistoryURL = "https://zkillboard.com/api"
+
+// zKillboard's REST limits: 200 kills per page, and at most a week back
+const (
+	historyPageSize = 200
+	historyMaxPages = 10
+	historyMaxPast  = 7 * 24 * time.Hour
+)
+
+// Recent lists the kills involving an entity in the last past, newest first.
+// entity is a zKillboard modifier such as "corporationID", "allianceID" or
+// "systemID". Only KillmailID and ZKB are set; the rest has to come from ESI.
+// A nil client uses the default one, here and in MonthPage and Kill.
+func Recent(ctx context.Context, client *http.Client, baseURL, entity string, id int, past time.Duration) ([]killmail.ZkillMail, error) {
+	if baseURL == "" {
+		baseURL = HistoryURL
+	}
+	// zKillboard wants whole hours
+	if r := past % time.Hour; r != 0 {
+		past += time.Hour - r
+	}
+	past = min(past, historyMaxPast)
+
+	var kills []killmail.ZkillMail
+	for page := 1; page <= historyMaxPages; page++ {
+		url := fmt.Sprintf("%s/%s/%d/pastSeconds/%d/page/%d/", baseURL, entity, id, int(past.Seconds()), page)
+		batch, err := fetchHistory(ctx, client, url)
+		if err != nil {
+			return kills, err
+		}
+		kills = append(kills, batch...)
+		if len(batch) < historyPageSize {
+			break
+		}
+	}
+	return kills, nil
+}
+
+// MonthPage lists one page of an entity's kills in one month, newest first,
+// and whether there may be more pages. Only KillmailID and ZKB are set.
+func MonthPage(ctx context.Context, client *http.Client, baseURL, entity string, id, year int, month time.Month, page int) ([]killmail.ZkillMail, bool, error) {
+	if baseURL == "" {
+		baseURL = HistoryURL
+	}
+	url := fmt.Sprintf("%s/%s/%d/year/%d/month/%d/page/%d/", baseURL, entity, id, year, int(month), page)
+	kills, err := fetchHistory(ctx, client, url)
+	return kills, len(kills) >= historyPageSize, err
+}
+
+// Kill fetches one kill from the REST API, e.g. to read its settled value.
+// Only KillmailID and ZKB are set.
+func Kill(ctx context.Context, client *http.Client, baseURL string, killID int64) (killmail.ZkillMail, error) {
+	if baseURL == "" {
+		baseURL = HistoryURL
+	}
+	kills, err := fetchHistory(ctx, client, fmt.Sprintf("%s/killID/%d/", baseURL, killID))
+	if err != nil {
+		return killmail.ZkillMail{}, err
+	}
+	if len(kills) == 0 {
+		return killmail.ZkillMail{}, fmt.Errorf("kill %d not found on zKillboard", killID)
+	}
+	return kills[0], nil
+}
+
+func fetchHistory(ctx context.Context, client *http.Client, url string) ([]killmail.ZkillMail, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "eve-chainkills (https://github.com/guarzo/eve-chainkills)")
+
+	resp, err := httpclient.Or(client).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("zkill history got status %d", resp.StatusCode)
+	}
+	var kills []killmail.ZkillMail
+	if err = schema.DecodeReader("zkill history", resp.Body, &kills); err != nil {
+		return nil, err
+	}
+	return kills, nil
+}
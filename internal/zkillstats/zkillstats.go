@@ -0,0 +1,218 @@
+// Package zkillstats looks up zKillboard's statistics for a corporation or
+// system and condenses them into a one-line threat summary. Lookups are
+// cached because zKillboard rate-limits its API and the numbers change slowly.
+package zkillstats
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/internal/httpclient"
+	"github.com/guarzo/eve-chainkills/internal/schema"
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+)
+
+// Stats is the part of zKillboard's statistics used for the threat summary
+type Stats struct {
+	DangerRatio    int
+	GangRatio      int
+	ShipsDestroyed int
+	ShipsLost      int
+	// RecentKills is the number of kills in the last 7 days
+	RecentKills int
+	// PeakHour is the UTC hour at the middle of the busiest six hours, or -1 if unknown
+	PeakHour int
+}
+
+// Timezone names the EVE timezone PeakHour falls in
+func (s Stats) Timezone() string {
+	switch h := s.PeakHour; {
+	case h < 0:
+		return ""
+	case h >= 16 && h < 23:
+		return "EU TZ"
+	case h >= 23 || h < 6:
+		return "US TZ"
+	case h < 12:
+		return "AU TZ"
+	default:
+		return "RU TZ"
+	}
+}
+
+// Summary renders the stats in one line, e.g.
+// "danger 87%, 1234 kills / 56 losses, 42 kills in 7d, peak 20:00 UTC (EU TZ)"
+func (s Stats) Summary() string {
+	parts := []string{
+		fmt.Sprintf("danger %d%%", s.DangerRatio),
+		fmt.Sprintf("%d kills / %d losses", s.ShipsDestroyed, s.ShipsLost),
+		fmt.Sprintf("%d kills in 7d", s.RecentKills),
+	}
+	if tz := s.Timezone(); tz != "" {
+		parts = append(parts, fmt.Sprintf("peak %02d:00 UTC (%s)", s.PeakHour, tz))
+	}
+	return strings.Join(parts, ", ")
+}
+
+type cacheEntry struct {
+	stats   Stats
+	err     error
+	fetched time.Time
+}
+
+// Client fetches and caches corporation and system statistics
+type Client struct {
+	logger  logger.Logger
+	baseURL string
+	ttl     time.Duration
+	// http is nil for the default client
+	http *http.Client
+
+	mu sync.Mutex
+	// cache is keyed by entity and ID, e.g. "corporationID/98000001"
+	cache map[string]cacheEntry
+}
+
+// NewClient constructor; results, including failures, are cached for ttl
+func NewClient(logger logger.Logger, ttl time.Duration) *Client {
+	return &Client{
+		logger:  logger,
+		baseURL: "https://zkillboard.com/api/stats",
+		ttl:     ttl,
+		cache:   map[string]cacheEntry{},
+	}
+}
+
+// SetHTTPClient sends requests through client instead of the default one
+func (c *Client) SetHTTPClient(client *http.Client) {
+	c.http = client
+}
+
+// Corporation returns the statistics for a corporation
+func (c *Client) Corporation(ctx context.Context, corpID int) (Stats, error) {
+	return c.lookup(ctx, "corporationID", corpID)
+}
+
+// System returns the statistics for a solar system
+func (c *Client) System(ctx context.Context, systemID int) (Stats, error) {
+	return c.lookup(ctx, "solarSystemID", systemID)
+}
+
+// lookup returns an entity's statistics, from the cache while they're fresh
+func (c *Client) lookup(ctx context.Context, entity string, id int) (Stats, error) {
+	key := fmt.Sprintf("%s/%d", entity, id)
+	c.mu.Lock()
+	entry, ok := c.cache[key]
+	c.mu.Unlock()
+	if ok && time.Since(entry.fetched) < c.ttl {
+		return entry.stats, entry.err
+	}
+
+	stats, err := c.fetch(ctx, key)
+	if err != nil {
+		c.logger.Printf("Error fetching zkill stats for %s %d: %v", entity, id, err)
+	}
+	if ctx.Err() != nil {
+		// don't cache a lookup that was only cut short by shutdown
+		return stats, err
+	}
+	c.mu.Lock()
+	c.cache[key] = cacheEntry{stats: stats, err: err, fetched: time.Now()}
+	for k, e := range c.cache {
+		if time.Since(e.fetched) >= c.ttl {
+			delete(c.cache, k)
+		}
+	}
+	c.mu.Unlock()
+	return stats, err
+}
+
+// fetch reads the stats at key, e.g. "corporationID/98000001"
+func (c *Client) fetch(ctx context.Context, key string) (Stats, error) {
+	url := fmt.Sprintf("%s/%s/", c.baseURL, key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return Stats{}, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "eve-chainkills (https://github.com/guarzo/eve-chainkills)")
+
+	client := httpclient.Or(c.http)
+	resp, err := client.Do(req)
+	if err != nil {
+		return Stats{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return Stats{}, fmt.Errorf("zkill stats got status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		DangerRatio    int `json:"dangerRatio"`
+		GangRatio      int `json:"gangRatio"`
+		ShipsDestroyed int `json:"shipsDestroyed"`
+		ShipsLost      int `json:"shipsLost"`
+		ActivePVP      struct {
+			Kills struct {
+				Count int `json:"count"`
+			} `json:"kills"`
+		} `json:"activepvp"`
+		// Activity maps day of week -> hour -> kills, plus "max" and "days" keys
+		Activity map[string]json.RawMessage `json:"activity"`
+	}
+	if err = schema.DecodeReader("zkill stats", resp.Body, &body); err != nil {
+		return Stats{}, err
+	}
+	return Stats{
+		DangerRatio:    body.DangerRatio,
+		GangRatio:      body.GangRatio,
+		ShipsDestroyed: body.ShipsDestroyed,
+		ShipsLost:      body.ShipsLost,
+		RecentKills:    body.ActivePVP.Kills.Count,
+		PeakHour:       peakHour(body.Activity),
+	}, nil
+}
+
+// peakHour finds the six-hour window with the most kills across all days and
+// returns its middle hour, or -1 without activity data
+func peakHour(activity map[string]json.RawMessage) int {
+	var hours [24]int
+	total := 0
+	for day, raw := range activity {
+		if _, err := strconv.Atoi(day); err != nil {
+			continue
+		}
+		var byHour map[string]int
+		if err := json.Unmarshal(raw, &byHour); err != nil {
+			continue
+		}
+		for h, n := range byHour {
+			if hour, err := strconv.Atoi(h); err == nil && hour >= 0 && hour < 24 {
+				hours[hour] += n
+				total += n
+			}
+		}
+	}
+	if total == 0 {
+		return -1
+	}
+
+	best, bestStart := -1, 0
+	for start := 0; start < 24; start++ {
+		sum := 0
+		for i := 0; i < 6; i++ {
+			sum += hours[(start+i)%24]
+		}
+		if sum > best {
+			best, bestStart = sum, start
+		}
+	}
+	return (bestStart + 3) % 24
+}
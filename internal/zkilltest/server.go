@@ -0,0 +1,143 @@
+// Package zkilltest is a local stand-in for zKillboard's websocket, for tests
+// that exercise the killstream without network access: it accepts the
+// killstream subscription, pushes frames to subscribers and drops connections
+// on demand to trigger reconnects.
+package zkilltest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// Server speaks the zKillboard websocket protocol on a local port
+type Server struct {
+	// Ack, if set, is sent to a connection right after it subscribes
+	Ack []byte
+
+	srv      *httptest.Server
+	upgrader websocket.Upgrader
+
+	mu    sync.Mutex
+	conns map[*websocket.Conn]bool // true once subscribed to the killstream
+	subs  int
+	// changed is closed and replaced whenever subs grows
+	changed chan struct{}
+}
+
+// NewServer starts a server; Close it when done
+func NewServer() *Server {
+	s := &Server{conns: map[*websocket.Conn]bool{}, changed: make(chan struct{})}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// URL is the ws:// address to dial
+func (s *Server) URL() string {
+	return "ws" + strings.TrimPrefix(s.srv.URL, "http")
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	s.conns[conn] = false
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	for {
+		var msg struct {
+			Action  string `json:"action"`
+			Channel string `json:"channel"`
+		}
+		if err := conn.ReadJSON(&msg); err != nil {
+			return
+		}
+		if msg.Action != "sub" || msg.Channel != "killstream" {
+			continue
+		}
+		s.mu.Lock()
+		s.conns[conn] = true
+		if s.Ack != nil {
+			_ = conn.WriteMessage(websocket.TextMessage, s.Ack)
+		}
+		s.subs++
+		close(s.changed)
+		s.changed = make(chan struct{})
+		s.mu.Unlock()
+	}
+}
+
+// Subscriptions counts killstream subscriptions so far, reconnects included
+func (s *Server) Subscriptions() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.subs
+}
+
+// WaitSubscriptions waits until there have been at least n subscriptions
+func (s *Server) WaitSubscriptions(ctx context.Context, n int) error {
+	for {
+		s.mu.Lock()
+		subs, changed := s.subs, s.changed
+		s.mu.Unlock()
+		if subs >= n {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// Send pushes a raw frame, e.g. a killmail, "ping" or a truncated message,
+// to every subscribed connection, and returns how many received it
+func (s *Server) Send(frame []byte) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sent := 0
+	for conn, subscribed := range s.conns {
+		if subscribed && conn.WriteMessage(websocket.TextMessage, frame) == nil {
+			sent++
+		}
+	}
+	return sent
+}
+
+// SendJSON marshals v, e.g. a killmail.ZkillMail, and sends it like Send
+func (s *Server) SendJSON(v interface{}) (int, error) {
+	frame, err := json.Marshal(v)
+	if err != nil {
+		return 0, err
+	}
+	return s.Send(frame), nil
+}
+
+// Disconnect drops every connection, as zKillboard does on restarts
+func (s *Server) Disconnect() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+}
+
+// Close drops every connection and stops the server
+func (s *Server) Close() {
+	s.Disconnect()
+	s.srv.Close()
+}
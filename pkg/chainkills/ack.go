@@ -0,0 +1,179 @@
+package chainkills
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/pkg/notify"
+	"github.com/guarzo/eve-chainkills/pkg/pipeline"
+	"golang.org/x/exp/slices"
+)
+
+// ackTracker remembers sent chain alerts until someone reacts to them or
+// their window runs out, and the outcomes until the next digest
+type ackTracker struct {
+	mu       sync.Mutex
+	pending  []*pendingAck
+	outcomes []ackOutcome
+}
+
+type pendingAck struct {
+	sink   notify.AckSink
+	name   string
+	ref    string
+	killID int64
+	rule   string
+	where  string
+	url    string
+	sentAt time.Time
+	// priority is the alert's tier, which escalation levels can require
+	priority pipeline.Priority
+	// escalated marks the escalation levels this alert has been escalated to
+	escalated map[int]bool
+}
+
+// ackOutcome is one alert's result; By is empty when nobody reacted in time
+type ackOutcome struct {
+	killID int64
+	by     string
+	took   time.Duration
+}
+
+func newAckTracker() *ackTracker {
+	return &ackTracker{}
+}
+
+// watch starts watching an alert sent by a sink
+func (t *ackTracker) watch(s notify.AckSink, name, ref string, n notify.Notification, priority pipeline.Priority) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pending = append(t.pending, &pendingAck{
+		sink:      s,
+		name:      name,
+		ref:       ref,
+		killID:    n.KillMailID,
+		rule:      n.Reason.Rule,
+		where:     n.Where(),
+		url:       n.ZkillURL(),
+		sentAt:    time.Now(),
+		priority:  priority,
+		escalated: map[int]bool{},
+	})
+}
+
+// pollAcks checks watched alerts for the acknowledgment reaction and escalates
+// the ones nobody reacts to
+func (ck *Checker) pollAcks(ctx context.Context) {
+	interval := 30 * time.Second
+	if ps := ck.config.Acknowledgments.PollSeconds; ps > 0 {
+		interval = time.Duration(ps) * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		ck.checkAcks(ctx)
+		ck.escalate(ctx)
+	}
+}
+
+// checkAcks records the alerts that were acknowledged or ran out of time
+func (ck *Checker) checkAcks(ctx context.Context) {
+	emoji := ck.config.Acknowledgments.Emoji
+	if emoji == "" {
+		emoji = "✅"
+	}
+	window := 30 * time.Minute
+	if wm := ck.config.Acknowledgments.WindowMinutes; wm > 0 {
+		window = time.Duration(wm) * time.Minute
+	}
+
+	ck.acks.mu.Lock()
+	pending := ck.acks.pending
+	ck.acks.pending = nil
+	ck.acks.mu.Unlock()
+
+	var still []*pendingAck
+	var outcomes []ackOutcome
+	acked := map[int64]bool{}
+	for _, p := range pending {
+		if ck.tags != nil {
+			ck.checkTagReactions(ctx, p)
+		}
+		names, err := p.sink.Acks(ctx, p.ref, emoji)
+		if err != nil {
+			ck.logger.Errorf("[Acks] Error reading reactions to kill %d on %s: %v", p.killID, p.name, err)
+		}
+		took := time.Since(p.sentAt)
+		switch {
+		case len(names) > 0:
+			// reactions come sorted by user, not time; the poll interval bounds the error
+			ck.logger.Printf("[Acks] %s acknowledged kill %d %s after %s", names[0], p.killID, p.where, took.Round(time.Second))
+			outcomes = append(outcomes, ackOutcome{killID: p.killID, by: names[0], took: took})
+			acked[p.killID] = true
+		case took > window:
+			ck.logger.Printf("[Acks] Nobody acknowledged kill %d %s within %s", p.killID, p.where, window)
+			outcomes = append(outcomes, ackOutcome{killID: p.killID})
+		default:
+			still = append(still, p)
+		}
+	}
+
+	// an alert watched on several sinks is acknowledged on any of them
+	still = slices.DeleteFunc(still, func(p *pendingAck) bool { return acked[p.killID] })
+
+	ck.acks.mu.Lock()
+	ck.acks.pending = append(still, ck.acks.pending...)
+	ck.acks.outcomes = append(ck.acks.outcomes, outcomes...)
+	ck.acks.mu.Unlock()
+}
+
+// digest sums up the outcomes since the last digest and starts over
+func (t *ackTracker) digest() string {
+	t.mu.Lock()
+	outcomes := t.outcomes
+	t.outcomes = nil
+	t.mu.Unlock()
+
+	if len(outcomes) == 0 {
+		return "Chain alert acknowledgments today: no alerts"
+	}
+	var acked int
+	var total time.Duration
+	byName := map[string]int{}
+	for _, o := range outcomes {
+		if o.by != "" {
+			acked++
+			total += o.took
+			byName[o.by]++
+		}
+	}
+	text := fmt.Sprintf("Chain alert acknowledgments today: %d of %d acknowledged", acked, len(outcomes))
+	if acked == 0 {
+		return text
+	}
+	text += fmt.Sprintf(", average response %s", (total / time.Duration(acked)).Round(time.Second))
+
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if byName[names[i]] != byName[names[j]] {
+			return byName[names[i]] > byName[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	for i, name := range names {
+		names[i] = fmt.Sprintf("%s (%d)", name, byName[name])
+	}
+	return text + "\nAcknowledged by: " + strings.Join(names, ", ")
+}
@@ -0,0 +1,213 @@
+package chainkills
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/internal/zkill"
+	"github.com/guarzo/eve-chainkills/pkg/killmail"
+)
+
+// activityHours is how long hourly kill counts are kept
+const activityHours = 48
+
+// regionResolver looks up the region of a solar system
+type regionResolver interface {
+	SystemRegion(ctx context.Context, systemID int) (int, string, error)
+}
+
+type region struct {
+	id   int
+	name string
+}
+
+// galaxyActivity counts every kill on the killstream, matched or not, per
+// system and hour, for the galaxy activity line in digests
+type galaxyActivity struct {
+	mu sync.Mutex
+	// hours maps a unix hour to kills per system
+	hours map[int64]map[int]int
+
+	// regionMu guards regions, which is kept for good: systems don't move
+	regionMu sync.Mutex
+	regions  map[int]region
+}
+
+func newGalaxyActivity() *galaxyActivity {
+	return &galaxyActivity{hours: map[int64]map[int]int{}, regions: map[int]region{}}
+}
+
+// record counts one raw killstream message, without decoding it
+func (g *galaxyActivity) record(raw []byte) {
+	systemID, killTime := zkill.Peek(raw)
+	// Abyssal pockets have no region to rank and a new ID every run
+	if systemID == 0 || killmail.IsAbyssal(systemID) {
+		return
+	}
+	now := time.Now()
+	if killTime.IsZero() || killTime.After(now) {
+		killTime = now
+	}
+	hour := killTime.Unix() / 3600
+	oldest := now.Unix()/3600 - activityHours
+	if hour <= oldest {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	counts := g.hours[hour]
+	if counts == nil {
+		counts = map[int]int{}
+		g.hours[hour] = counts
+		for h := range g.hours {
+			if h <= oldest {
+				delete(g.hours, h)
+			}
+		}
+	}
+	counts[systemID]++
+}
+
+// since sums kills per system from since until now
+func (g *galaxyActivity) since(since time.Time) map[int]int {
+	from := since.Unix() / 3600
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	out := map[int]int{}
+	for h, counts := range g.hours {
+		if h < from {
+			continue
+		}
+		for sys, n := range counts {
+			out[sys] += n
+		}
+	}
+	return out
+}
+
+// region returns a system's region, asking ESI the first time
+func (g *galaxyActivity) region(ctx context.Context, rr regionResolver, systemID int) (region, error) {
+	g.regionMu.Lock()
+	r, ok := g.regions[systemID]
+	g.regionMu.Unlock()
+	if ok {
+		return r, nil
+	}
+	id, name, err := rr.SystemRegion(ctx, systemID)
+	if err != nil {
+		return region{}, err
+	}
+	r = region{id: id, name: name}
+	g.regionMu.Lock()
+	g.regions[systemID] = r
+	g.regionMu.Unlock()
+	return r, nil
+}
+
+// galaxyLine sums up the last day's kills across the galaxy and places the
+// instance's home region in the ranking, or "" when there's nothing to say
+func (ck *Checker) galaxyLine(ctx context.Context) string {
+	if ck.activity == nil {
+		return ""
+	}
+	rr, ok := ck.esi.(regionResolver)
+	if !ok {
+		return ""
+	}
+	bySystem := ck.activity.since(time.Now().Add(-24 * time.Hour))
+	if len(bySystem) == 0 {
+		return ""
+	}
+
+	// every active system's region is needed for the ranking; after the
+	// first digest most of them are cached
+	var total, failed int
+	byRegion := map[region]int{}
+	for sys, n := range bySystem {
+		total += n
+		r, err := ck.activity.region(ctx, rr, sys)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ""
+			}
+			failed++
+			continue
+		}
+		byRegion[r] += n
+	}
+	if failed > 0 {
+		ck.logger.Warnf("[Activity] Could not look up the region of %d systems; their kills are left out of the ranking", failed)
+	}
+
+	ranked := make([]region, 0, len(byRegion))
+	for r := range byRegion {
+		ranked = append(ranked, r)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if byRegion[ranked[i]] != byRegion[ranked[j]] {
+			return byRegion[ranked[i]] > byRegion[ranked[j]]
+		}
+		return ranked[i].name < ranked[j].name
+	})
+	if len(ranked) == 0 {
+		return ""
+	}
+	text := fmt.Sprintf("Galaxy activity, last 24 hours: %d kills; busiest region %s (%d)", total, ranked[0].name, byRegion[ranked[0]])
+
+	if home, ok := ck.homeRegion(ctx, rr); ok {
+		for i, r := range ranked {
+			if r == home {
+				text += fmt.Sprintf("\nYour region, %s, was the %s most active of %d (%d kills)", r.name, ordinal(i+1), len(ranked), byRegion[r])
+				break
+			}
+		}
+	}
+
+	var chain int
+	for _, s := range ck.state().systems {
+		chain += bySystem[s.SystemId]
+	}
+	return text + fmt.Sprintf("\nChain systems: %d kills", chain)
+}
+
+// homeRegion is the region of the home system, or else the one most chain
+// systems are in
+func (ck *Checker) homeRegion(ctx context.Context, rr regionResolver) (region, bool) {
+	if home := ck.config.HomeSystemId; home != 0 {
+		r, err := ck.activity.region(ctx, rr, home)
+		return r, err == nil
+	}
+	counts := map[region]int{}
+	var best region
+	for _, s := range ck.state().systems {
+		r, err := ck.activity.region(ctx, rr, s.SystemId)
+		if err != nil {
+			continue
+		}
+		counts[r]++
+		if counts[r] > counts[best] || counts[r] == counts[best] && r.name < best.name {
+			best = r
+		}
+	}
+	return best, counts[best] > 0
+}
+
+// ordinal renders 1 as "1st", 2 as "2nd" and so on
+func ordinal(n int) string {
+	suffix := "th"
+	if n%100 < 11 || n%100 > 13 {
+		switch n % 10 {
+		case 1:
+			suffix = "st"
+		case 2:
+			suffix = "nd"
+		case 3:
+			suffix = "rd"
+		}
+	}
+	return fmt.Sprintf("%d%s", n, suffix)
+}
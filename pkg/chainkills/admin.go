@@ -0,0 +1,435 @@
+package chainkills
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/internal/attribution"
+	"github.com/guarzo/eve-chainkills/internal/audit"
+	"github.com/guarzo/eve-chainkills/internal/killtags"
+	"github.com/guarzo/eve-chainkills/pkg/notify"
+	"golang.org/x/exp/slices"
+)
+
+// adminServer serves the admin API that edits tracked IDs and ignored systems
+type adminServer struct {
+	hub *Hub
+	srv *http.Server
+}
+
+func newAdminServer(h *Hub, config AdminConfig) (*adminServer, error) {
+	if config.Token == "" {
+		return nil, errors.New("admin API requires a token")
+	}
+	a := &adminServer{hub: h}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/tracked", a.handleList(trackedList))
+	mux.HandleFunc("POST /admin/tracked", a.handleAdd(trackedList))
+	mux.HandleFunc("DELETE /admin/tracked/{id}", a.handleRemove(trackedList))
+	mux.HandleFunc("GET /admin/ignored-systems", a.handleList(ignoredList))
+	mux.HandleFunc("POST /admin/ignored-systems", a.handleAdd(ignoredList))
+	mux.HandleFunc("DELETE /admin/ignored-systems/{id}", a.handleRemove(ignoredList))
+	mux.HandleFunc("GET /admin/why/{killID}", a.handleWhy)
+	mux.HandleFunc("GET /admin/leaderboard", a.handleLeaderboard)
+	mux.HandleFunc("GET /admin/op", a.handleOp)
+	mux.HandleFunc("POST /admin/op", a.handleStartOp)
+	mux.HandleFunc("DELETE /admin/op", a.handleEndOp)
+	mux.HandleFunc("GET /admin/rule-groups", a.handleRuleGroups)
+	mux.HandleFunc("POST /admin/rule-groups/{name}", a.handleSetRuleGroup)
+	mux.HandleFunc("GET /admin/threat", a.handleThreat)
+	mux.HandleFunc("GET /admin/tags", a.handleTaggedKills)
+	mux.HandleFunc("GET /admin/tags/{killID}", a.handleKillTags)
+	mux.HandleFunc("POST /admin/tags/{killID}", a.handleTagKill)
+	mux.HandleFunc("DELETE /admin/tags/{killID}/{tag}", a.handleUntagKill)
+	root := http.NewServeMux()
+	root.Handle("/admin/", authorizeBearer(config.Token, mux))
+	// health carries no secrets, so load balancers and uptime checks need no token
+	root.HandleFunc("GET /health", a.handleHealth)
+	// peers sign what they send instead
+	root.HandleFunc("POST /federation/kills", a.handleFederatedKill)
+	a.srv = &http.Server{
+		Addr:              config.Listen,
+		Handler:           root,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return a, nil
+}
+
+// start listens in the background
+func (a *adminServer) start() error {
+	ln, err := net.Listen("tcp", a.srv.Addr)
+	if err != nil {
+		return err
+	}
+	a.hub.logger.Printf("Admin API listening on %s", ln.Addr())
+	go func() {
+		if err := a.srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			a.hub.logger.Printf("Admin server error: %v", err)
+		}
+	}()
+	return nil
+}
+
+func (a *adminServer) shutdown(ctx context.Context) error {
+	return a.srv.Shutdown(ctx)
+}
+
+func authorizeBearer(token string, next http.Handler) http.Handler {
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// checker picks the instance named by ?instance=, which may be omitted when there is only one
+func (a *adminServer) checker(r *http.Request) (*Checker, error) {
+	return a.hub.instance(r.URL.Query().Get("instance"))
+}
+
+func (a *adminServer) handleList(list trackingList) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ck, err := a.checker(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ids, err := list.get(ck)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotImplemented)
+			return
+		}
+		writeAdminJSON(w, map[string]interface{}{"instance": ck.config.Name, "ids": nonNil(ids)})
+	}
+}
+
+// handleAdd adds the IDs in a {"ids": [...]} body, skipping any already present
+func (a *adminServer) handleAdd(list trackingList) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			IDs []int `json:"ids"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&body); err != nil || len(body.IDs) == 0 {
+			http.Error(w, `body must be {"ids": [...]}`, http.StatusBadRequest)
+			return
+		}
+		a.update(w, r, list, func(ids []int) []int {
+			for _, id := range body.IDs {
+				if !slices.Contains(ids, id) {
+					ids = append(ids, id)
+				}
+			}
+			return ids
+		})
+	}
+}
+
+func (a *adminServer) handleRemove(list trackingList) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		a.update(w, r, list, func(ids []int) []int {
+			return slices.DeleteFunc(ids, func(v int) bool { return v == id })
+		})
+	}
+}
+
+func (a *adminServer) update(w http.ResponseWriter, r *http.Request, list trackingList, change func([]int) []int) {
+	ck, err := a.checker(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ids, persisted, err := a.hub.editTracking(ck, list, change)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+		return
+	}
+	writeAdminJSON(w, map[string]interface{}{"instance": ck.config.Name, "ids": nonNil(ids), "persisted": persisted})
+}
+
+// handleWhy returns the audit log records for a kill
+func (a *adminServer) handleWhy(w http.ResponseWriter, r *http.Request) {
+	if a.hub.audit == nil {
+		http.Error(w, "the audit log is not enabled", http.StatusNotFound)
+		return
+	}
+	killID, err := strconv.ParseInt(r.PathValue("killID"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid kill ID", http.StatusBadRequest)
+		return
+	}
+	records, err := a.hub.audit.Find(killID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if records == nil {
+		records = []audit.Record{}
+	}
+	writeAdminJSON(w, map[string]interface{}{"kill_id": killID, "records": records})
+}
+
+// handleLeaderboard returns per-pilot stats for the last ?since (default 168h)
+func (a *adminServer) handleLeaderboard(w http.ResponseWriter, r *http.Request) {
+	if a.hub.attribution == nil {
+		http.Error(w, "attribution is not enabled", http.StatusNotFound)
+		return
+	}
+	since := 7 * 24 * time.Hour
+	if s := r.URL.Query().Get("since"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			http.Error(w, "invalid since", http.StatusBadRequest)
+			return
+		}
+		since = d
+	}
+	records, err := attribution.Read(a.hub.attribution.Path(), time.Now().Add(-since))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeAdminJSON(w, map[string]interface{}{"since": since.String(), "pilots": attribution.Leaderboard(records)})
+}
+
+// handleOp reports whether a fleet op is on
+func (a *adminServer) handleOp(w http.ResponseWriter, r *http.Request) {
+	ck, err := a.checker(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeOp(w, ck)
+}
+
+// handleStartOp declares a fleet op; the body, {"minutes": 90}, is optional
+func (a *adminServer) handleStartOp(w http.ResponseWriter, r *http.Request) {
+	ck, err := a.checker(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var body struct {
+		Minutes int `json:"minutes"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&body); (err != nil && !errors.Is(err, io.EOF)) || body.Minutes < 0 {
+		http.Error(w, `body must be empty or {"minutes": 90}`, http.StatusBadRequest)
+		return
+	}
+	ck.startOp(body.Minutes, "admin API")
+	writeOp(w, ck)
+}
+
+// handleEndOp ends the fleet op early
+func (a *adminServer) handleEndOp(w http.ResponseWriter, r *http.Request) {
+	ck, err := a.checker(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ck.endOp("admin API")
+	writeOp(w, ck)
+}
+
+func writeOp(w http.ResponseWriter, ck *Checker) {
+	out := map[string]interface{}{"instance": ck.config.Name, "active": false}
+	if until := ck.opUntil(); !until.IsZero() {
+		out["active"] = true
+		out["until"] = until.UTC().Format(time.RFC3339)
+		out["action"] = ck.opAction()
+	}
+	writeAdminJSON(w, out)
+}
+
+// handleRuleGroups lists the rule groups and whether each is enabled
+func (a *adminServer) handleRuleGroups(w http.ResponseWriter, r *http.Request) {
+	ck, err := a.checker(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeAdminJSON(w, map[string]interface{}{"instance": ck.config.Name, "groups": ck.RuleGroups()})
+}
+
+// handleSetRuleGroup switches a rule group on or off with {"enabled": false}
+func (a *adminServer) handleSetRuleGroup(w http.ResponseWriter, r *http.Request) {
+	ck, err := a.checker(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var body struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&body); err != nil || body.Enabled == nil {
+		http.Error(w, `body must be {"enabled": true|false}`, http.StatusBadRequest)
+		return
+	}
+	if err := ck.SetRuleGroupEnabled(r.PathValue("name"), *body.Enabled); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeAdminJSON(w, map[string]interface{}{"instance": ck.config.Name, "groups": ck.RuleGroups()})
+}
+
+// handleThreat reports the threat level and the signals behind it
+func (a *adminServer) handleThreat(w http.ResponseWriter, r *http.Request) {
+	ck, err := a.checker(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	threat, ok := ck.Threat()
+	if !ok {
+		http.Error(w, "threat is not enabled", http.StatusNotFound)
+		return
+	}
+	writeAdminJSON(w, map[string]interface{}{"instance": ck.config.Name, "threat": threat})
+}
+
+// handleFederatedKill takes a signed alert from a federated peer
+func (a *adminServer) handleFederatedKill(w http.ResponseWriter, r *http.Request) {
+	ck, err := a.checker(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	outcome, err := ck.ReceiveFederated(r.Context(), body, r.Header.Get(notify.WebhookSignatureHeader))
+	switch {
+	case errors.Is(err, ErrFederationUntrusted):
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeAdminJSON(w, map[string]interface{}{"instance": ck.config.Name, "outcome": outcome})
+}
+
+// handleTaggedKills lists the kills tagged in the last ?since (default 168h), or only those with ?tag
+func (a *adminServer) handleTaggedKills(w http.ResponseWriter, r *http.Request) {
+	since := 7 * 24 * time.Hour
+	if s := r.URL.Query().Get("since"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			http.Error(w, "since must be a duration such as 168h", http.StatusBadRequest)
+			return
+		}
+		since = d
+	}
+	kills, err := a.hub.TaggedKills(r.URL.Query().Get("tag"), time.Now().Add(-since))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeAdminJSON(w, map[string]interface{}{"since": since.String(), "kills": kills})
+}
+
+// handleKillTags returns one kill's tags
+func (a *adminServer) handleKillTags(w http.ResponseWriter, r *http.Request) {
+	killID, err := strconv.ParseInt(r.PathValue("killID"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid kill ID", http.StatusBadRequest)
+		return
+	}
+	a.writeKillTags(w, killID)
+}
+
+// handleTagKill tags a kill with {"tag": "srp-approved", "by": "Alice"}; by is optional
+func (a *adminServer) handleTagKill(w http.ResponseWriter, r *http.Request) {
+	killID, err := strconv.ParseInt(r.PathValue("killID"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid kill ID", http.StatusBadRequest)
+		return
+	}
+	var body struct {
+		Tag string `json:"tag"`
+		By  string `json:"by"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&body); err != nil || body.Tag == "" {
+		http.Error(w, `body must be {"tag": "..."}`, http.StatusBadRequest)
+		return
+	}
+	if body.By == "" {
+		body.By = "admin API"
+	}
+	if err := a.hub.TagKill(killID, body.Tag, body.By); err != nil {
+		http.Error(w, err.Error(), tagErrorStatus(err))
+		return
+	}
+	a.writeKillTags(w, killID)
+}
+
+// handleUntagKill takes a tag off a kill
+func (a *adminServer) handleUntagKill(w http.ResponseWriter, r *http.Request) {
+	killID, err := strconv.ParseInt(r.PathValue("killID"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid kill ID", http.StatusBadRequest)
+		return
+	}
+	if err := a.hub.UntagKill(killID, r.PathValue("tag"), "admin API"); err != nil {
+		http.Error(w, err.Error(), tagErrorStatus(err))
+		return
+	}
+	a.writeKillTags(w, killID)
+}
+
+func (a *adminServer) writeKillTags(w http.ResponseWriter, killID int64) {
+	tags, err := a.hub.KillTags(killID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if tags == nil {
+		tags = []killtags.Tag{}
+	}
+	writeAdminJSON(w, map[string]interface{}{"kill_id": killID, "tags": tags})
+}
+
+// tagErrorStatus is 404 when tags are off and 400 for a bad tag
+func tagErrorStatus(err error) int {
+	if errors.Is(err, ErrTagsDisabled) {
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
+
+// handleHealth reports the killstream and every instance's metrics; it
+// answers 503 while the killstream is silent past feedTimeoutMinutes
+func (a *adminServer) handleHealth(w http.ResponseWriter, r *http.Request) {
+	status := "ok"
+	if !a.hub.feedHealthy() {
+		status = "unhealthy"
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	writeAdminJSON(w, map[string]interface{}{
+		"status":    status,
+		"feed":      a.hub.feedStatus(),
+		"instances": a.hub.Metrics(),
+	})
+}
+
+func writeAdminJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_ = json.NewEncoder(w).Encode(v)
+}
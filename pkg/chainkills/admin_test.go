@@ -0,0 +1,252 @@
+package chainkills
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+	"golang.org/x/exp/slices"
+)
+
+func TestAuthorizeBearer(t *testing.T) {
+	handler := authorizeBearer("s3cret", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	for _, tc := range []struct {
+		header string
+		want   int
+	}{
+		{"Bearer s3cret", http.StatusNoContent},
+		{"", http.StatusUnauthorized},
+		{"s3cret", http.StatusUnauthorized},
+		{"Bearer s3cre", http.StatusUnauthorized},
+		{"Bearer s3cret ", http.StatusUnauthorized},
+		{"bearer s3cret", http.StatusUnauthorized},
+		{"Basic czNjcmV0", http.StatusUnauthorized},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/admin/tracked", nil)
+		if tc.header != "" {
+			req.Header.Set("Authorization", tc.header)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Errorf("Authorization %q got status %d, want %d", tc.header, rec.Code, tc.want)
+		}
+	}
+}
+
+func TestNewAdminServerRequiresToken(t *testing.T) {
+	if _, err := newAdminServer(&Hub{}, AdminConfig{Listen: "127.0.0.1:0"}); err == nil {
+		t.Fatal("newAdminServer without a token succeeded")
+	}
+}
+
+// adminResponse is the body the tracking endpoints answer with
+type adminResponse struct {
+	Instance  string `json:"instance"`
+	IDs       []int  `json:"ids"`
+	Persisted bool   `json:"persisted"`
+}
+
+// newAdminTest serves the admin API for a hub over checkers, saving edits to configPath
+func newAdminTest(t *testing.T, configPath string, instanced bool, checkers ...*Checker) *httptest.Server {
+	t.Helper()
+	h := &Hub{
+		logger:     logger.Slog(slog.New(slog.NewTextHandler(io.Discard, nil))),
+		checkers:   checkers,
+		configPath: configPath,
+		instanced:  instanced,
+	}
+	a, err := newAdminServer(h, AdminConfig{Token: "s3cret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(a.srv.Handler)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func adminDo(t *testing.T, srv *httptest.Server, method, path, body string) (int, adminResponse, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer s3cret")
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(resp.Body)
+	var out adminResponse
+	if resp.StatusCode == http.StatusOK {
+		if err := json.Unmarshal(raw, &out); err != nil {
+			t.Fatalf("%s %s: %v in %s", method, path, err, raw)
+		}
+	}
+	return resp.StatusCode, out, string(raw)
+}
+
+func TestAdminRejectsWithoutToken(t *testing.T) {
+	ck := newScopeChecker(t, echoESI{}, &Config{InsightTrackedIds: []int{98000001}})
+	srv := newAdminTest(t, "", false, ck)
+
+	for _, req := range []struct{ method, path, body string }{
+		{http.MethodGet, "/admin/tracked", ""},
+		{http.MethodPost, "/admin/tracked", `{"ids":[98000002]}`},
+		{http.MethodDelete, "/admin/tracked/98000001", ""},
+		{http.MethodPost, "/admin/ignored-systems", `{"ids":[31000001]}`},
+	} {
+		r, _ := http.NewRequest(req.method, srv.URL+req.path, strings.NewReader(req.body))
+		r.Header.Set("Authorization", "Bearer wrong")
+		resp, err := srv.Client().Do(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("%s %s with a wrong token got status %d, want 401", req.method, req.path, resp.StatusCode)
+		}
+	}
+	if ids, _ := ck.TrackedIds(); !slices.Equal(ids, []int{98000001}) {
+		t.Errorf("tracked IDs = %v after rejected requests, want them unchanged", ids)
+	}
+}
+
+func TestAdminAddRemove(t *testing.T) {
+	ck := newScopeChecker(t, echoESI{}, &Config{InsightTrackedIds: []int{98000001}})
+	srv := newAdminTest(t, "", false, ck)
+
+	code, out, raw := adminDo(t, srv, http.MethodPost, "/admin/tracked", `{"ids":[98000002,98000001,98000002]}`)
+	if code != http.StatusOK || !slices.Equal(out.IDs, []int{98000001, 98000002}) {
+		t.Fatalf("add got %d %s, want each ID once", code, raw)
+	}
+	// adding again changes nothing
+	if _, out, raw = adminDo(t, srv, http.MethodPost, "/admin/tracked", `{"ids":[98000002]}`); !slices.Equal(out.IDs, []int{98000001, 98000002}) {
+		t.Errorf("second add got %s, want the IDs unchanged", raw)
+	}
+	if out.Persisted {
+		t.Errorf("persisted = true without a config file")
+	}
+
+	if _, out, raw = adminDo(t, srv, http.MethodDelete, "/admin/tracked/98000001", ""); !slices.Equal(out.IDs, []int{98000002}) {
+		t.Errorf("remove got %s, want only 98000002 left", raw)
+	}
+	// removing an ID that isn't there is not an error
+	if code, out, raw = adminDo(t, srv, http.MethodDelete, "/admin/tracked/98000001", ""); code != http.StatusOK || !slices.Equal(out.IDs, []int{98000002}) {
+		t.Errorf("second remove got %d %s, want the IDs unchanged", code, raw)
+	}
+	if ids, _ := ck.TrackedIds(); !slices.Equal(ids, []int{98000002}) {
+		t.Errorf("checker tracks %v, want the API's edits", ids)
+	}
+
+	// the ignored systems reach the map snapshot the pipeline reads
+	if _, out, raw = adminDo(t, srv, http.MethodPost, "/admin/ignored-systems", `{"ids":[31000001]}`); !slices.Equal(out.IDs, []int{31000001}) {
+		t.Errorf("ignore got %s, want [31000001]", raw)
+	}
+	if _, ok := ck.state().ignored[31000001]; !ok {
+		t.Errorf("ignored system missing from the map snapshot")
+	}
+	if code, out, _ = adminDo(t, srv, http.MethodGet, "/admin/ignored-systems", ""); code != http.StatusOK || !slices.Equal(out.IDs, []int{31000001}) {
+		t.Errorf("list got %d %v, want [31000001]", code, out.IDs)
+	}
+
+	for _, req := range []struct{ method, path, body string }{
+		{http.MethodPost, "/admin/tracked", ``},
+		{http.MethodPost, "/admin/tracked", `{"ids":[]}`},
+		{http.MethodPost, "/admin/tracked", `{"ids":["98000003"]}`},
+		{http.MethodDelete, "/admin/tracked/corp", ""},
+	} {
+		if code, _, raw := adminDo(t, srv, req.method, req.path, req.body); code != http.StatusBadRequest {
+			t.Errorf("%s %s %q got %d %s, want 400", req.method, req.path, req.body, code, raw)
+		}
+	}
+}
+
+func TestAdminInstance(t *testing.T) {
+	a := newScopeChecker(t, echoESI{}, &Config{Name: "a", InsightTrackedIds: []int{98000001}})
+	b := newScopeChecker(t, echoESI{}, &Config{Name: "b"})
+	srv := newAdminTest(t, "", true, a, b)
+
+	if code, _, raw := adminDo(t, srv, http.MethodPost, "/admin/tracked", `{"ids":[98000002]}`); code != http.StatusBadRequest || !strings.Contains(raw, "instance is required") {
+		t.Errorf("add without ?instance= got %d %s, want 400", code, raw)
+	}
+	if code, _, raw := adminDo(t, srv, http.MethodDelete, "/admin/tracked/98000001?instance=c", ""); code != http.StatusBadRequest || !strings.Contains(raw, `unknown instance "c"`) {
+		t.Errorf("remove from an unknown instance got %d %s, want 400", code, raw)
+	}
+	code, out, raw := adminDo(t, srv, http.MethodPost, "/admin/tracked?instance=b", `{"ids":[98000002]}`)
+	if code != http.StatusOK || out.Instance != "b" || !slices.Equal(out.IDs, []int{98000002}) {
+		t.Errorf("add to b got %d %s", code, raw)
+	}
+	if ids, _ := a.TrackedIds(); !slices.Equal(ids, []int{98000001}) {
+		t.Errorf("instance a tracks %v, want it untouched", ids)
+	}
+}
+
+func TestAdminPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	original := `{
+  "instances": [
+    {"name": "a", "insightTrackedIds": [98000001]},
+    {"name": "b", "insightTrackedIds": [], "ignoreSystemIds": [31000005]}
+  ],
+  "logLevel": "info"
+}`
+	if err := os.WriteFile(path, []byte(original), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	a := newScopeChecker(t, echoESI{}, &Config{Name: "a", InsightTrackedIds: []int{98000001}})
+	b := newScopeChecker(t, echoESI{}, &Config{Name: "b", IgnoreSystemIds: []int{31000005}})
+	srv := newAdminTest(t, path, true, a, b)
+
+	code, out, raw := adminDo(t, srv, http.MethodPost, "/admin/tracked?instance=b", `{"ids":[98000002]}`)
+	if code != http.StatusOK || !out.Persisted {
+		t.Fatalf("add got %d %s, want it persisted", code, raw)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved struct {
+		Instances []struct {
+			Name              string `json:"name"`
+			InsightTrackedIds []int  `json:"insightTrackedIds"`
+			IgnoreSystemIds   []int  `json:"ignoreSystemIds"`
+		} `json:"instances"`
+		LogLevel string `json:"logLevel"`
+	}
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if len(saved.Instances) != 2 || saved.LogLevel != "info" {
+		t.Fatalf("saved config %s lost its other settings", data)
+	}
+	if got := saved.Instances[0].InsightTrackedIds; !slices.Equal(got, []int{98000001}) {
+		t.Errorf("instance a saved with %v, want it untouched", got)
+	}
+	if got := saved.Instances[1]; !slices.Equal(got.InsightTrackedIds, []int{98000002}) || !slices.Equal(got.IgnoreSystemIds, []int{31000005}) {
+		t.Errorf("instance b saved with %+v, want the new ID and its ignored system", got)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o640 {
+		t.Errorf("config mode after saving = %v, %v, want 0640 kept", info.Mode().Perm(), err)
+	}
+
+	// a config file that can't be saved still changes the live lists
+	if err := os.WriteFile(path, []byte("not json"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	code, out, raw = adminDo(t, srv, http.MethodDelete, "/admin/tracked/98000002?instance=b", "")
+	if code != http.StatusOK || out.Persisted || len(out.IDs) != 0 {
+		t.Errorf("remove with a broken config file got %d %s, want it applied but not persisted", code, raw)
+	}
+}
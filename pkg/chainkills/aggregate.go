@@ -0,0 +1,132 @@
+package chainkills
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/pkg/notify"
+	"github.com/guarzo/eve-chainkills/pkg/pipeline"
+)
+
+// aggregateKey groups held alerts: one batch per rule, kind and system
+type aggregateKey struct {
+	rule   string
+	kind   notify.Kind
+	system int
+}
+
+// aggregator holds alerts for the configured delay and delivers each
+// system's kills together
+type aggregator struct {
+	mu      sync.Mutex
+	pending map[aggregateKey]*aggregateBatch
+}
+
+type aggregateBatch struct {
+	ctx    context.Context
+	events []*pipeline.Event
+	timer  *time.Timer
+}
+
+func newAggregator() *aggregator {
+	return &aggregator{pending: map[aggregateKey]*aggregateBatch{}}
+}
+
+// aggregateDelay returns how long alerts matched by rule are held, or 0
+func (ck *Checker) aggregateDelay(rule string) time.Duration {
+	secs, ok := ck.config.Aggregate.DelaySeconds[rule]
+	if !ok {
+		secs = ck.config.Aggregate.DelaySeconds["*"]
+	}
+	return time.Duration(secs) * time.Second
+}
+
+// hold adds an event to its system's batch, starting the batch's timer if it is the first
+func (ck *Checker) hold(ctx context.Context, ev *pipeline.Event, delay time.Duration) {
+	key := aggregateKey{rule: ev.Reason.Rule, kind: ev.Kind, system: ev.Zkill.SolarSystemID}
+	ev.Held = true
+	ck.aggregates.mu.Lock()
+	defer ck.aggregates.mu.Unlock()
+	if b, ok := ck.aggregates.pending[key]; ok {
+		b.events = append(b.events, ev)
+		return
+	}
+	// the kill's context ends with its message; keep its values for tracing
+	b := &aggregateBatch{ctx: context.WithoutCancel(ctx), events: []*pipeline.Event{ev}}
+	b.timer = time.AfterFunc(delay, func() { ck.flushAggregate(key) })
+	ck.aggregates.pending[key] = b
+}
+
+// flushAggregate delivers one batch, if it is still pending
+func (ck *Checker) flushAggregate(key aggregateKey) {
+	ck.aggregates.mu.Lock()
+	b, ok := ck.aggregates.pending[key]
+	delete(ck.aggregates.pending, key)
+	ck.aggregates.mu.Unlock()
+	if !ok {
+		return
+	}
+	defer ck.supervisor.Recover("aggregate", nil)
+	ck.deliverBatch(b.ctx, b.events)
+}
+
+// flushAggregates delivers every pending batch right away, e.g. on shutdown
+func (ck *Checker) flushAggregates(ctx context.Context) {
+	ck.aggregates.mu.Lock()
+	pending := ck.aggregates.pending
+	ck.aggregates.pending = map[aggregateKey]*aggregateBatch{}
+	ck.aggregates.mu.Unlock()
+	for _, b := range pending {
+		b.timer.Stop()
+		ck.deliverBatch(ctx, b.events)
+	}
+}
+
+// deliverBatch sends held events to every sink: as one message to sinks that
+// support batches, one by one to the rest. Sinks in a fallback chain get each
+// event through the chain, and cross-posts always go one by one. Each event's
+// audit record is written here, once its deliveries are known.
+func (ck *Checker) deliverBatch(ctx context.Context, events []*pipeline.Event) {
+	ck.logger.Printf("Sending %d aggregated %s alerts for system %d", len(events), events[0].Kind, events[0].Zkill.SolarSystemID)
+	for _, s := range ck.sinks {
+		var wanted []*pipeline.Event
+		for _, ev := range events {
+			if ev.WantsSink(s.Name()) && ck.fallbackChain(ev.Kind, s.Name()) < 0 {
+				wanted = append(wanted, ev)
+			}
+		}
+		if bs, ok := s.(notify.BatchSink); ok && len(wanted) > 1 {
+			ns := make([]notify.Notification, len(wanted))
+			for i, ev := range wanted {
+				ns[i] = ev.Notification
+			}
+			err := bs.SendBatch(ctx, ns)
+			for _, ev := range wanted {
+				ev.Deliveries = append(ev.Deliveries, pipeline.Delivery{Sink: s.Name(), Err: err})
+			}
+			if err == nil {
+				ck.stats.delivered()
+			} else {
+				ck.logger.Printf("Error sending %d aggregated alerts to %s: %v", len(ns), s.Name(), err)
+				ck.stats.sinkFailed(s.Name())
+				tags := ck.errorTags(wanted[0], pipeline.StageDeliver)
+				tags["sink"] = s.Name()
+				ck.reportError(ctx, err, tags)
+			}
+		} else {
+			for _, ev := range wanted {
+				ck.deliver(ctx, ev, s, ev.Notification)
+			}
+		}
+	}
+	for _, ev := range events {
+		ck.deliverChains(ctx, ev, ev.Notification)
+		if ev.CrossPost != nil {
+			ck.deliverAll(ctx, ev, *ev.CrossPost)
+		}
+		if ck.audit != nil {
+			ck.recordDecision(ev, nil)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package chainkills
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"github.com/guarzo/eve-chainkills/internal/audit"
+	"github.com/guarzo/eve-chainkills/pkg/killmail"
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+	"github.com/guarzo/eve-chainkills/pkg/notify"
+)
+
+// sinkOutcomes maps the named sinks a record delivered to onto their errors
+func sinkOutcomes(r audit.Record, names ...string) map[string]string {
+	out := map[string]string{}
+	for _, d := range r.Deliveries {
+		for _, name := range names {
+			if d.Sink == name {
+				out[name] = d.Error
+			}
+		}
+	}
+	return out
+}
+
+func TestAggregateAuditsDeliveries(t *testing.T) {
+	log, err := audit.Open(filepath.Join(t.TempDir(), "audit.jsonl"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+	batch := &batchSink{scriptSink{name: "batch"}}
+	single := &scriptSink{name: "single", fails: 1}
+	ck, err := newChecker(&options{
+		logger: logger.Slog(slog.New(slog.NewTextHandler(io.Discard, nil))),
+		esi:    echoESI{},
+		sinks:  []notify.Sink{batch, single},
+		audit:  log,
+	}, &Config{Aggregate: AggregateConfig{DelaySeconds: map[string]int{"*": 3600}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ck.updateState(func(s *mapState) {
+		s.systems = []killmail.SystemInfo{{SystemId: 31000001, Alias: "home"}}
+	})
+	ctx := context.Background()
+
+	for _, id := range []int64{1, 2} {
+		ck.HandleMessage(ctx, []byte(fmt.Sprintf(`{"killmail_id":%d,"solar_system_id":31000001,"victim":{"corporation_id":98000009},"attackers":[{"corporation_id":98000002}],"zkb":{"hash":"abc123"}}`, id)))
+	}
+	// nothing was sent yet, so there is nothing to record
+	if records, _ := log.Find(1); len(records) != 0 {
+		t.Fatalf("held kill recorded before it was sent: %+v", records)
+	}
+
+	ck.flushAggregates(ctx)
+	if len(batch.batches) != 1 || len(single.sent) != 1 {
+		t.Fatalf("batch sink got %v and single sink sent %v, want one batch and one of two sends", batch.batches, single.sent)
+	}
+	for id, want := range map[int64]map[string]string{
+		1: {"batch": "", "single": "single is down"},
+		2: {"batch": "", "single": ""},
+	} {
+		records, err := log.Find(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != 1 || !records[0].Matched || records[0].Kind != string(notify.KindChain) {
+			t.Fatalf("kill %d records %+v, want one chain match", id, records)
+		}
+		if got := sinkOutcomes(records[0], "batch", "single"); len(got) != 2 || got["batch"] != want["batch"] || got["single"] != want["single"] {
+			t.Errorf("kill %d deliveries %v, want %v", id, got, want)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package chainkills
+
+import (
+	"context"
+	"sort"
+
+	"github.com/guarzo/eve-chainkills/pkg/killmail"
+)
+
+// affiliationResolver is implemented by ESI clients that can batch-resolve
+// names and look up tickers, which the attacker summary needs
+type affiliationResolver interface {
+	Names(ctx context.Context, ids []int) (map[int]string, error)
+	CorporationInfo(ctx context.Context, corpID int) (string, string, error)
+	AllianceInfo(ctx context.Context, allianceID int) (string, string, error)
+}
+
+// summarizeAttackers fills in the attackers' ship counts and dominant group
+// with two ESI requests: one batched name lookup and one ticker lookup
+func (ck *Checker) summarizeAttackers(ctx context.Context, fkm *killmail.FlattenedKillMail) {
+	resolver, ok := ck.esi.(affiliationResolver)
+	if !ok || len(fkm.Attackers) == 0 {
+		return
+	}
+
+	shipCounts := map[int]int{}
+	groupCounts := map[int]int{}
+	alliances := map[int]bool{}
+	for _, att := range fkm.Attackers {
+		if att.ShipTypeID > 0 {
+			shipCounts[att.ShipTypeID]++
+		}
+		switch {
+		case att.AllianceID > 0:
+			groupCounts[att.AllianceID]++
+			alliances[att.AllianceID] = true
+		case att.CorporationID > 0:
+			groupCounts[att.CorporationID]++
+		}
+	}
+
+	if len(shipCounts) > 0 {
+		ids := make([]int, 0, len(shipCounts))
+		for id := range shipCounts {
+			ids = append(ids, id)
+		}
+		names, err := resolver.Names(ctx, ids)
+		if err != nil {
+			ck.logger.Printf("Error resolving attacker ship names: %v", err)
+		}
+		for _, id := range ids {
+			name := names[id]
+			if name == "" {
+				name = "Unknown"
+			}
+			fkm.AttackerShips = append(fkm.AttackerShips, killmail.ShipCount{TypeID: id, Name: name, Count: shipCounts[id]})
+		}
+		sort.Slice(fkm.AttackerShips, func(i, j int) bool {
+			a, b := fkm.AttackerShips[i], fkm.AttackerShips[j]
+			if a.Count != b.Count {
+				return a.Count > b.Count
+			}
+			return a.Name < b.Name
+		})
+	}
+
+	topID, topCount := 0, 0
+	for id, n := range groupCounts {
+		if n > topCount || (n == topCount && id < topID) {
+			topID, topCount = id, n
+		}
+	}
+	if topID == 0 {
+		return
+	}
+	group := &killmail.AttackingGroup{ID: topID, IsAlliance: alliances[topID], Count: topCount}
+	var err error
+	if group.IsAlliance {
+		group.Name, group.Ticker, err = resolver.AllianceInfo(ctx, topID)
+	} else {
+		group.Name, group.Ticker, err = resolver.CorporationInfo(ctx, topID)
+	}
+	if err != nil {
+		ck.logger.Printf("Error resolving attacker group %d: %v", topID, err)
+		return
+	}
+	fkm.AttackerGroup = group
+}
@@ -0,0 +1,134 @@
+package chainkills
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/internal/filter"
+	"github.com/guarzo/eve-chainkills/pkg/killmail"
+)
+
+// autoIgnorer ignores chain systems for a while where map characters keep
+// getting kills, e.g. our own ratting holes
+type autoIgnorer struct {
+	minKills int
+	window   time.Duration
+	idle     time.Duration
+
+	mu sync.Mutex
+	// kills are the times of recent kills by map characters, per system
+	kills map[int][]time.Time
+	// until is when each auto-ignored system comes back
+	until map[int]time.Time
+}
+
+func newAutoIgnorer(c AutoIgnoreConfig) *autoIgnorer {
+	a := &autoIgnorer{minKills: 5, window: 2 * time.Hour, idle: 6 * time.Hour,
+		kills: map[int][]time.Time{}, until: map[int]time.Time{}}
+	if c.MinKills > 0 {
+		a.minKills = c.MinKills
+	}
+	if c.WindowHours > 0 {
+		a.window = time.Duration(c.WindowHours) * time.Hour
+	}
+	if c.IdleHours > 0 {
+		a.idle = time.Duration(c.IdleHours) * time.Hour
+	}
+	return a
+}
+
+// record counts a kill by map characters in a system and reports whether it
+// made the system ignored; kills in an ignored system keep it ignored
+func (a *autoIgnorer) record(systemID int, now time.Time) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, on := a.until[systemID]; on {
+		a.until[systemID] = now.Add(a.idle)
+		return false
+	}
+	cutoff := now.Add(-a.window)
+	kept := []time.Time{}
+	for _, t := range a.kills[systemID] {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	kept = append(kept, now)
+	if len(kept) < a.minKills {
+		a.kills[systemID] = kept
+		return false
+	}
+	delete(a.kills, systemID)
+	a.until[systemID] = now.Add(a.idle)
+	return true
+}
+
+// expire brings back the systems idle for long enough and returns them
+func (a *autoIgnorer) expire(now time.Time) []int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var out []int
+	for id, until := range a.until {
+		if !now.Before(until) {
+			delete(a.until, id)
+			out = append(out, id)
+		}
+	}
+	cutoff := now.Add(-a.window)
+	for id, times := range a.kills {
+		if !times[len(times)-1].After(cutoff) {
+			delete(a.kills, id)
+		}
+	}
+	sort.Ints(out)
+	return out
+}
+
+// ignored reports whether a system is auto-ignored
+func (a *autoIgnorer) ignored(systemID int) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, on := a.until[systemID]
+	return on
+}
+
+// systems lists the auto-ignored systems
+func (a *autoIgnorer) systems() []int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	out := make([]int, 0, len(a.until))
+	for id := range a.until {
+		out = append(out, id)
+	}
+	sort.Ints(out)
+	return out
+}
+
+// trackFriendlyKills feeds a kill to auto-ignore when map characters are
+// among the attackers in a chain system other than home, and announces
+// systems as they are ignored and come back
+func (ck *Checker) trackFriendlyKills(ctx context.Context, zm killmail.ZkillMail, ix *filter.Index) {
+	now := time.Now()
+	for _, id := range ck.autoIgnore.expire(now) {
+		ck.logger.Printf("[AutoIgnore] System %d idle, no longer ignored", id)
+		ck.sendInfoMessage(ctx, fmt.Sprintf("No kills by map characters in %s lately; its chain alerts are back on.", ck.state().alias(id)))
+	}
+	sys, ok := ix.System(zm.SolarSystemID)
+	if !ok || sys.SystemId == ck.config.HomeSystemId {
+		return
+	}
+	for _, att := range zm.Attackers {
+		if !ix.Mapped(att.CharacterID) {
+			continue
+		}
+		if ck.autoIgnore.record(sys.SystemId, now) {
+			ck.logger.Printf("[AutoIgnore] Ignoring system %d (%s) for friendly activity", sys.SystemId, sys.Alias)
+			ck.sendInfoMessage(ctx, fmt.Sprintf("Map characters keep getting kills in %s, so its chain alerts are off until it's quiet for %s.",
+				sys.Alias, ck.autoIgnore.idle))
+		}
+		return
+	}
+}
@@ -0,0 +1,105 @@
+package chainkills
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/pkg/killmail"
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+	"github.com/guarzo/eve-chainkills/pkg/notify"
+	"golang.org/x/exp/slices"
+)
+
+func TestAutoIgnoreWindow(t *testing.T) {
+	a := newAutoIgnorer(AutoIgnoreConfig{MinKills: 3, WindowHours: 1, IdleHours: 2})
+	t0 := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, tc := range []struct {
+		after   time.Duration
+		ignored bool
+	}{
+		{0, false},
+		{30 * time.Minute, false},
+		// the first kill has left the window, so this is only the second
+		{70 * time.Minute, false},
+		{80 * time.Minute, true},
+	} {
+		if got := a.record(31000002, t0.Add(tc.after)); got != tc.ignored {
+			t.Errorf("kill at +%s ignored the system = %v, want %v", tc.after, got, tc.ignored)
+		}
+	}
+	if !a.ignored(31000002) || a.ignored(31000003) {
+		t.Fatalf("ignored systems %v, want only 31000002", a.systems())
+	}
+
+	// another kill keeps it ignored for the idle time from then
+	last := t0.Add(3 * time.Hour)
+	if a.record(31000002, last) {
+		t.Error("a kill in an ignored system ignored it again")
+	}
+	if back := a.expire(last.Add(2*time.Hour - time.Second)); len(back) != 0 {
+		t.Errorf("systems %v back before the idle time", back)
+	}
+	if back := a.expire(last.Add(2 * time.Hour)); !slices.Equal(back, []int{31000002}) {
+		t.Errorf("systems %v back after the idle time, want 31000002", back)
+	}
+	if a.ignored(31000002) {
+		t.Error("system still ignored after coming back")
+	}
+
+	// counts start over once the system is back, and stale ones are dropped
+	a.record(31000002, last.Add(3*time.Hour))
+	a.record(31000004, last.Add(3*time.Hour))
+	a.expire(last.Add(5 * time.Hour))
+	if len(a.kills) != 0 {
+		t.Errorf("kept kill counts %v past the window", a.kills)
+	}
+}
+
+func TestAutoIgnoreFriendlyKills(t *testing.T) {
+	sink := &renderSink{}
+	ck, err := newChecker(&options{
+		logger: logger.Slog(slog.New(slog.NewTextHandler(io.Discard, nil))),
+		esi:    echoESI{},
+		sinks:  []notify.Sink{sink},
+	}, &Config{HomeSystemId: 31000001, AutoIgnore: AutoIgnoreConfig{Enabled: true, MinKills: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ck.updateState(func(s *mapState) {
+		s.systems = []killmail.SystemInfo{{SystemId: 31000001, Alias: "home"}, {SystemId: 31000002, Alias: "c1"}}
+		s.mapCharacters = []killmail.MapCharacter{{CharacterId: 90000001, Name: "Our Pilot", SolarSystemId: 31000001}}
+	})
+	ctx := context.Background()
+	kill := func(id int64, system int, attacker int64) {
+		ck.HandleMessage(ctx, []byte(fmt.Sprintf(`{"killmail_id":%d,"solar_system_id":%d,"victim":{"corporation_id":98000009},"attackers":[{"character_id":%d,"corporation_id":98000002}],"zkb":{"hash":"abc123"}}`, id, system, attacker)))
+	}
+
+	// strangers' kills don't count, and home is never ignored
+	kill(1, 31000002, 90000009)
+	kill(2, 31000002, 90000009)
+	kill(3, 31000001, 90000001)
+	kill(4, 31000001, 90000001)
+	if got := ck.autoIgnore.systems(); len(got) != 0 {
+		t.Fatalf("auto-ignored %v", got)
+	}
+
+	kill(5, 31000002, 90000001)
+	kill(6, 31000002, 90000001)
+	if got := ck.autoIgnore.systems(); !slices.Equal(got, []int{31000002}) {
+		t.Fatalf("auto-ignored %v, want c1", got)
+	}
+	before := sink.sent
+	kill(7, 31000002, 90000009)
+	if sink.sent != before {
+		t.Error("sent a chain alert for an auto-ignored system")
+	}
+	kill(8, 31000001, 90000009)
+	if sink.sent != before+1 {
+		t.Error("home's chain alerts stopped too")
+	}
+}
@@ -0,0 +1,66 @@
+package chainkills
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/guarzo/eve-chainkills/internal/benchdata"
+	"github.com/guarzo/eve-chainkills/pkg/killmail"
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+	"github.com/guarzo/eve-chainkills/pkg/notify"
+	"github.com/guarzo/eve-chainkills/pkg/pipeline"
+)
+
+func benchChecker(b *testing.B) *Checker {
+	b.Helper()
+	ck, err := newChecker(&options{
+		logger: logger.Slog(slog.New(slog.NewTextHandler(io.Discard, nil))),
+		esi:    echoESI{},
+		sinks:  []notify.Sink{&renderSink{}},
+	}, &Config{TrackedCorporationIds: []int{benchdata.TrackedCorp}})
+	if err != nil {
+		b.Fatal(err)
+	}
+	ck.updateState(func(s *mapState) {
+		s.systems = []killmail.SystemInfo{{SystemId: benchdata.ChainSystem, Alias: "home"}}
+	})
+	return ck
+}
+
+func BenchmarkPrefilter(b *testing.B) {
+	ck := benchChecker(b)
+	for _, k := range benchdata.Kills() {
+		b.Run(k.Name, func(b *testing.B) {
+			b.SetBytes(int64(len(k.Raw)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				ck.prefilterStage(context.Background(), &pipeline.Event{Raw: k.Raw})
+			}
+		})
+	}
+}
+
+// BenchmarkMatchPath runs a kill from the raw message to a formatted
+// notification; dedup is left out so the same kill can run again
+func BenchmarkMatchPath(b *testing.B) {
+	ck := benchChecker(b)
+	p := pipeline.New(
+		pipeline.NewStage(pipeline.StagePrefilter, ck.prefilterStage),
+		pipeline.Decode(),
+		pipeline.NewStage(pipeline.StageMatch, ck.matchStage),
+		pipeline.NewStage(pipeline.StageEnrich, ck.enrichStage),
+		pipeline.NewStage(pipeline.StageFormat, ck.formatStage),
+	)
+	for _, k := range benchdata.Kills() {
+		b.Run(k.Name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := p.Run(context.Background(), &pipeline.Event{Raw: k.Raw}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,553 @@
+// Package chainkills watches the zKillboard killstream for kills in a mapped
+// wormhole chain or involving tracked corporations, and notifies Discord and
+// any other configured sinks. Embed it by creating a Hub with New and functional
+// options; the Hub runs one Checker per configured instance off a single zKill
+// connection.
+package chainkills
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/internal/attribution"
+	"github.com/guarzo/eve-chainkills/internal/audit"
+	"github.com/guarzo/eve-chainkills/internal/discord"
+	"github.com/guarzo/eve-chainkills/internal/errreport"
+	"github.com/guarzo/eve-chainkills/internal/filter"
+	"github.com/guarzo/eve-chainkills/internal/httpclient"
+	"github.com/guarzo/eve-chainkills/internal/killtags"
+	"github.com/guarzo/eve-chainkills/internal/lanes"
+	"github.com/guarzo/eve-chainkills/internal/logging"
+	mapapi "github.com/guarzo/eve-chainkills/internal/map"
+	"github.com/guarzo/eve-chainkills/internal/supervise"
+	"github.com/guarzo/eve-chainkills/internal/tracing"
+	"github.com/guarzo/eve-chainkills/internal/zkill"
+	"github.com/guarzo/eve-chainkills/internal/zkillstats"
+	"github.com/guarzo/eve-chainkills/pkg/killmail"
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+	"github.com/guarzo/eve-chainkills/pkg/notify"
+	"github.com/guarzo/eve-chainkills/pkg/pipeline"
+)
+
+// Checker is the Go equivalent of the ChainKillChecker class in JS.
+type Checker struct {
+	logger            logger.Logger
+	mapLogger         logger.Logger
+	pipelineLogger    logger.Logger
+	config            *Config
+	trackingMu        sync.Mutex
+	insightTrackedIds []int
+	minToSendDiscord  int
+	// snapshot is the chain and its characters; replace it with updateState
+	snapshot atomic.Pointer[mapState]
+	stateMu  sync.Mutex
+	// charsMu guards the fleet and systems-only mode
+	charsMu sync.RWMutex
+	// fleetMembers is the fleet boss's current fleet; their kills never raise chain alerts
+	fleetMembers map[int64]bool
+	// charsDeniedUntil is set while the map API refuses character access
+	charsDeniedUntil time.Time
+	// lastUpdateTime and lastDiscordStatusTime are Unix nanoseconds, read and
+	// set by every pipeline worker
+	lastUpdateTime        atomic.Int64
+	lastDiscordStatusTime atomic.Int64
+	// refreshing is set while one kill runs updateSystems, so the others skip it
+	refreshing            atomic.Bool
+	minToGetLatestSystems int
+	sinks                 []notify.Sink
+
+	esi          ESIClient
+	http         *http.Client
+	webhooks     *discord.Webhooks
+	mapAPI       *mapapi.Client
+	matcher      Filter
+	tracked      *filter.Matcher // the built-in matcher, for scope checks under a custom filter too
+	pipeline     *pipeline.Pipeline
+	stageMetrics *pipeline.Metrics
+	reporter     *errreport.Reporter
+	supervisor   *supervise.Supervisor
+	stats        *checkerStats
+	// groups is nil unless newGroupAlert is enabled
+	groups *groupTracker
+	// threats is nil unless zkillStats is enabled
+	threats *zkillstats.Client
+	// intelStats looks up system stats for intel reports; it is threats when that's set
+	intelStats *zkillstats.Client
+	// audit is nil unless the audit log is enabled
+	audit *audit.Log
+	// attribution is nil unless per-pilot records are enabled
+	attribution *attribution.Store
+	// tags is nil unless kill tags are enabled
+	tags *killtags.Store
+	// aggregates holds alerts during their aggregation delay
+	aggregates *aggregator
+	// acks watches sent chain alerts for acknowledgments
+	acks *ackTracker
+	// activity is nil unless galaxy activity is counted
+	activity *galaxyActivity
+	// lanes limits the kills being enriched and delivered at once, most urgent first
+	lanes *lanes.Gate
+	// info routes info webhook messages by severity
+	info *infoChannels
+	// op is the declared fleet op, if any
+	op opMode
+	// autoIgnore is nil unless autoIgnore is enabled
+	autoIgnore *autoIgnorer
+	// sieges is nil unless structureSiege is enabled
+	sieges *siegeTracker
+	// threat is nil unless threat is enabled
+	threat *threatTracker
+	// timers is nil unless timerboard.url is set
+	timers *timerboard
+	// fallbacks are the sink chains tried in order
+	fallbacks []fallbackChain
+	// federated holds the kills alerted lately, ours and our peers', when federating
+	federated *killRing
+	// rules holds which rule groups are switched off
+	rules *ruleGroups
+}
+
+// NewChecker constructor. A Checker processes messages for a single instance;
+// use a Hub to feed it from zKillboard.
+func NewChecker(logger logger.Logger, config *Config) (*Checker, error) {
+	return newChecker(&options{logger: logger}, config)
+}
+
+// newChecker builds a checker for one instance, applying any overrides from opts
+func newChecker(o *options, config *Config) (*Checker, error) {
+	base := o.rawLogger()
+	if config.Name != "" {
+		base = base.WithField("instance", config.Name)
+	}
+	logger := o.componentLogger(base, "")
+	if o.http == nil {
+		// checkers built without a hub, e.g. in tests
+		o.http = httpclient.Client()
+	}
+
+	var ignoreSys []int
+	if len(config.IgnoreSystemIds) > 0 {
+		ignoreSys = config.IgnoreSystemIds
+	}
+
+	er := config.ErrorReporting
+	reporter, err := errreport.New(logger, errreport.Config{
+		SentryDSN:           er.SentryDSN,
+		WebhookURL:          er.WebhookURL,
+		Environment:         er.Environment,
+		RepeatWindowMinutes: er.RepeatWindowMinutes,
+		HTTPClient:          o.http,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	supervisor := o.supervisor
+	if supervisor == nil {
+		supervisor = supervise.New(logger)
+	}
+
+	esiClient := o.esiClient(o.componentLogger(base, logging.ESI))
+	if err = config.resolveWebhooks(); err != nil {
+		return nil, err
+	}
+	mappedVictim, mappedAttackers, err := config.MappedCharacters.sides()
+	if err != nil {
+		return nil, err
+	}
+	if err = config.CrossPost.validate(); err != nil {
+		return nil, err
+	}
+	if err = config.Downtime.validate(); err != nil {
+		return nil, err
+	}
+	if err = config.Acknowledgments.validate(config.Escalations); err != nil {
+		return nil, err
+	}
+	if err = config.Digest.validate(); err != nil {
+		return nil, err
+	}
+	if err = config.InfoChannels.validate(); err != nil {
+		return nil, err
+	}
+	if err = config.OpMode.validate(); err != nil {
+		return nil, err
+	}
+	if err = config.Threat.validate(); err != nil {
+		return nil, err
+	}
+	if err = config.Timerboard.validate(); err != nil {
+		return nil, err
+	}
+	if err = validateFallbacks(config.Fallbacks); err != nil {
+		return nil, err
+	}
+	if err = config.Federation.validate(); err != nil {
+		return nil, err
+	}
+	if err = config.Killstream.validate(); err != nil {
+		return nil, err
+	}
+	if err = config.FactionWarfare.validate(); err != nil {
+		return nil, err
+	}
+	if err = config.TrackedSource.validate(); err != nil {
+		return nil, err
+	}
+	rules, err := newRuleGroups(config.RuleGroups)
+	if err != nil {
+		return nil, err
+	}
+	if err = config.validateFormats(); err != nil {
+		return nil, err
+	}
+	if dk := config.DiscordKillNotifications; dk.MaxBatch < 0 || dk.BatchWindowMs < 0 {
+		return nil, fmt.Errorf("discordKillNotifications.maxBatch and batchWindowMs must not be negative")
+	}
+	builtin := filter.NewMatcher(o.componentLogger(base, logging.Filter), config.InsightTrackedIds, ignoreSys)
+	builtin.SetMappedSides(mappedVictim, mappedAttackers)
+	builtin.SetTyped(filter.Tracked{
+		Corporations: config.TrackedCorporationIds,
+		Alliances:    config.TrackedAllianceIds,
+		Characters:   config.TrackedCharacterIds,
+	})
+	var matcher Filter = matcherFilter{builtin}
+	if o.filter != nil {
+		matcher = o.filter
+	}
+	var displayTZ *time.Location
+	if config.DisplayTimezone != "" {
+		if displayTZ, err = time.LoadLocation(config.DisplayTimezone); err != nil {
+			return nil, fmt.Errorf("displayTimezone: %w", err)
+		}
+	}
+	sinks := buildSinks(o.componentLogger(base, logging.Discord), o.componentLogger(base, logging.Sinks), config, displayTZ, esiClient, o.http, o.webhooks)
+	ck := &Checker{
+		logger:                logger,
+		mapLogger:             o.componentLogger(base, logging.Map),
+		pipelineLogger:        o.componentLogger(base, logging.Pipeline),
+		config:                config,
+		insightTrackedIds:     config.InsightTrackedIds,
+		minToSendDiscord:      config.DiscordStatusReportMins,
+		minToGetLatestSystems: 0, // was 0 in the JS code
+		sinks:                 append(sinks, o.sinks...),
+		esi:                   esiClient,
+		http:                  o.http,
+		webhooks:              o.webhooks,
+		matcher:               matcher,
+		tracked:               builtin,
+		stageMetrics:          pipeline.NewMetrics(),
+		reporter:              reporter,
+		supervisor:            supervisor,
+		audit:                 o.audit,
+		attribution:           o.attribution,
+		tags:                  o.tags,
+		activity:              o.activity,
+		lanes:                 o.lanes,
+		aggregates:            newAggregator(),
+		acks:                  newAckTracker(),
+		stats:                 newCheckerStats(),
+		info:                  newInfoChannels(config.InfoChannels),
+		rules:                 rules,
+	}
+	oauth, err := ck.mapOAuth()
+	if err != nil {
+		return nil, err
+	}
+	ck.lastUpdateTime.Store(time.Now().UnixNano())
+	ck.lastDiscordStatusTime.Store(time.Now().UnixNano())
+	ck.mapAPI = mapapi.NewClient(config.APIBaseUrl, config.APISlug, config.mapTokens(oauth)...)
+	ck.mapAPI.SetHTTPClient(o.http)
+	if zs := config.ZkillStats; zs.Enabled {
+		ttl := 12 * time.Hour
+		if zs.CacheHours > 0 {
+			ttl = time.Duration(zs.CacheHours) * time.Hour
+		}
+		ck.threats = zkillstats.NewClient(logger, ttl)
+		ck.threats.SetHTTPClient(o.http)
+	}
+	ck.intelStats = ck.threats
+	if ck.intelStats == nil {
+		ck.intelStats = zkillstats.NewClient(logger, time.Hour)
+		ck.intelStats.SetHTTPClient(o.http)
+	}
+	if config.NewGroupAlert.Enabled {
+		ck.groups = newGroupTracker(config.NewGroupAlert)
+	}
+	if config.AutoIgnore.Enabled {
+		ck.autoIgnore = newAutoIgnorer(config.AutoIgnore)
+	}
+	if config.StructureSiege.Enabled {
+		ck.sieges = newSiegeTracker(config.StructureSiege)
+	}
+	if config.Threat.Enabled {
+		ck.threat = newThreatTracker(config.Threat)
+	}
+	ck.fallbacks = newFallbackChains(config.Fallbacks)
+	if config.Timerboard.URL != "" {
+		if ck.timers, err = newTimerboard(config.Timerboard); err != nil {
+			return nil, err
+		}
+	}
+	if len(config.Federation.Peers) > 0 {
+		ck.federated = newKillRing(1000)
+	}
+	ck.updateState(func(s *mapState) { s.ignored = systemSet(config.IgnoreSystemIds) })
+	if kc := config.Killstream; len(kc.RegionIds) > 0 || len(kc.SystemIds) > 0 {
+		// the scope holds from the first kill; until loadScope expands
+		// regionIds, only systemIds and the chain are in it
+		ck.updateState(func(s *mapState) { s.scope = systemSet(kc.SystemIds) })
+	}
+	ck.pipeline = ck.buildPipeline()
+	ck.logger.Printf("[ChainKillChecker] Initialized. insightTrackedIds: %v", ck.insightTrackedIds)
+	return ck, nil
+}
+
+// Start expands killstream.regionIds and Pochven and fetches the initial
+// systems and characters from the map API
+func (ck *Checker) Start(ctx context.Context) {
+	if len(ck.config.Killstream.RegionIds) > 0 {
+		ck.loadScope(ctx)
+	}
+	ck.loadPochven(ctx)
+	if err := ck.updateSystems(ctx); err != nil {
+		ck.logger.Printf("Error updating systems on startup: %v", err)
+	}
+	if err := ck.getMapCharacters(ctx); err != nil {
+		ck.logger.Printf("Error updating map characters on startup: %v", err)
+	}
+}
+
+// HandleMessage runs one raw killstream message through the pipeline
+func (ck *Checker) HandleMessage(ctx context.Context, raw []byte) {
+	ev := &pipeline.Event{Raw: raw, ReceivedAt: time.Now()}
+	// the ID is peeked so even kills dropped before decoding carry it
+	ctx = pipeline.WithKillID(ctx, zkill.PeekID(raw))
+	ctx, span := tracing.Start(ctx, "kill", tracing.KindInternal)
+	defer span.End()
+	span.SetAttr("instance", ck.config.Name)
+	defer ck.supervisor.Recover("handler", func(r interface{}, stack []byte) {
+		ck.reporter.Panic(ctx, r, stack, ck.errorTags(ev, ""))
+	})
+
+	e := ck.pipeline.Run(ctx, ev)
+	ck.stats.seen()
+	span.SetAttr("killmail.id", ev.Zkill.KillmailID)
+	if !ev.Zkill.KillmailTime.IsZero() {
+		// how far behind the kill itself the killstream delivered it
+		span.SetAttr("killstream.lag_ms", ev.ReceivedAt.Sub(ev.Zkill.KillmailTime).Milliseconds())
+	}
+	if ev.Kind != "" {
+		span.SetAttr("kill.kind", string(ev.Kind))
+	}
+	if e != nil {
+		span.RecordError(e)
+		ck.logger.Printf("Error handling zKill message: %v", e)
+		var se *pipeline.StageError
+		stage := ""
+		if errors.As(e, &se) {
+			stage = se.Stage
+		}
+		ck.stats.failed(ErrorsPipeline)
+		ck.reportError(ctx, e, ck.errorTags(ev, stage))
+	}
+	if ev.Traced() {
+		ck.pipelineLogger.Debugf("[trace] killId=%d %s", pipeline.KillID(ctx), ev.TraceLine())
+	}
+	if ck.audit != nil && ev.Zkill.KillmailID != 0 && !ev.Held {
+		// held events are recorded by deliverBatch once they're sent
+		ck.recordDecision(ev, e)
+	}
+	if ck.attribution != nil && ev.Kind != "" {
+		ck.recordAttribution(ev)
+	}
+}
+
+// recordAttribution stores which map characters and corp members were among a
+// matched kill's attackers
+func (ck *Checker) recordAttribution(ev *pipeline.Event) {
+	ours := map[int64]killmail.MapCharacter{}
+	for _, mc := range ck.characters() {
+		ours[mc.CharacterId] = mc
+	}
+	var records []attribution.Record
+	for _, att := range ev.Zkill.Attackers {
+		mc, ok := ours[att.CharacterID]
+		if att.CharacterID == 0 || !ok {
+			continue
+		}
+		records = append(records, attribution.Record{
+			Time:          ev.Zkill.KillmailTime,
+			KillID:        ev.Zkill.KillmailID,
+			Instance:      ck.config.Name,
+			Kind:          string(ev.Kind),
+			SystemID:      ev.Zkill.SolarSystemID,
+			CharacterID:   att.CharacterID,
+			Name:          mc.Name,
+			CorporationID: att.CorporationID,
+			ShipTypeID:    att.ShipTypeID,
+			Damage:        att.DamageDone,
+			FinalBlow:     att.FinalBlow,
+			Value:         ev.Zkill.ZKB.TotalValue,
+		})
+	}
+	if len(records) == 0 {
+		return
+	}
+	if err := ck.attribution.Write(records...); err != nil {
+		ck.logger.Printf("Error writing attribution records: %v", err)
+	}
+}
+
+// recordDecision writes what happened to a kill to the audit log
+func (ck *Checker) recordDecision(ev *pipeline.Event, err error) {
+	r := audit.Record{
+		Time:      time.Now().UTC(),
+		KillID:    ev.Zkill.KillmailID,
+		Instance:  ck.config.Name,
+		SystemID:  ev.Zkill.SolarSystemID,
+		Matched:   ev.Kind != "",
+		Kind:      string(ev.Kind),
+		Rule:      ev.Reason.Rule,
+		Reason:    ev.Reason.Text,
+		StoppedAt: ev.StoppedAt,
+	}
+	var se *pipeline.StageError
+	if errors.As(err, &se) {
+		r.StoppedAt = se.Stage
+		r.Error = se.Err.Error()
+	} else if err != nil {
+		r.Error = err.Error()
+	}
+	for _, d := range ev.Deliveries {
+		delivery := audit.Delivery{Sink: d.Sink, MessageID: d.Ref, URL: d.URL}
+		if d.Err != nil {
+			delivery.Error = d.Err.Error()
+		}
+		r.Deliveries = append(r.Deliveries, delivery)
+	}
+	if werr := ck.audit.Write(r); werr != nil {
+		ck.logger.Printf("Error writing audit log: %v", werr)
+	}
+}
+
+// killLogger is the logger for lines about the kill in ctx, with its
+// killmail_id field so one kill can be followed through the logs
+func (ck *Checker) killLogger(ctx context.Context) logger.Logger {
+	if id := pipeline.KillID(ctx); id != 0 {
+		return ck.logger.WithField("killmail_id", id)
+	}
+	return ck.logger
+}
+
+// errorTags identifies the instance, kill and stage an error report came from
+func (ck *Checker) errorTags(ev *pipeline.Event, stage string) map[string]string {
+	tags := map[string]string{"killmail_id": strconv.FormatInt(ev.Zkill.KillmailID, 10)}
+	if ck.config.Name != "" {
+		tags["instance"] = ck.config.Name
+	}
+	if stage != "" {
+		tags["stage"] = stage
+	}
+	return tags
+}
+
+// Pipeline exposes the checker's pipeline so callers can insert stages or middleware
+func (ck *Checker) Pipeline() *pipeline.Pipeline {
+	return ck.pipeline
+}
+
+// updateSystems fetches systems from the new API
+func (ck *Checker) updateSystems(ctx context.Context) error {
+	ck.mapLogger.Println("Updating system list from API...")
+	systems, err := ck.mapAPI.Systems(ctx)
+	if err != nil {
+		ck.sendErrorMessage(ctx, fmt.Sprintf("Error updateSystems : %v", err))
+		ck.stats.failed(ErrorsMap)
+		ck.reportError(ctx, err, map[string]string{"instance": ck.config.Name, "component": "map"})
+		return err
+	}
+	ck.mapLogger.Printf("[updateSystems] Fetched %d systems.\n", len(systems))
+	var conns []killmail.Connection
+	fetched := false
+	if ck.config.HomeSystemId != 0 || len(ck.config.DiscordCommands.Characters) > 0 {
+		// connections are only needed for the route home and the way out; keep the old ones on failure
+		if conns, err = ck.mapAPI.Connections(ctx); err != nil {
+			ck.mapLogger.Printf("[updateSystems] Error fetching connections: %v", err)
+		} else {
+			fetched = true
+		}
+	}
+	ck.updateState(func(s *mapState) {
+		s.systems = systems
+		if fetched {
+			s.connections = conns
+		}
+	})
+	ck.lastUpdateTime.Store(time.Now().UnixNano())
+	return nil
+}
+
+// getMapCharacters fetches the characters from your new API
+func (ck *Checker) getMapCharacters(ctx context.Context) error {
+	ck.mapLogger.Println("Getting characters from API...")
+	chars, err := ck.mapAPI.Characters(ctx)
+	if errors.Is(err, mapapi.ErrForbidden) {
+		ck.charactersDenied(ctx, err)
+		return nil
+	}
+	if err != nil {
+		ck.sendErrorMessage(ctx, fmt.Sprintf("Error getMapCharacters : %v", err))
+		ck.stats.failed(ErrorsMap)
+		ck.reportError(ctx, err, map[string]string{"instance": ck.config.Name, "component": "map"})
+		return err
+	}
+	ck.setCharacters(chars)
+	ck.mapLogger.Printf("[getMapCharacters] Fetched %d characters.\n", len(chars))
+	return nil
+}
+
+// charactersRetry is how long systems-only mode lasts before characters are tried again
+const charactersRetry = time.Hour
+
+// charactersDenied switches to systems-only mode when no token may read the
+// map's characters, announcing it once rather than on every refresh
+func (ck *Checker) charactersDenied(ctx context.Context, err error) {
+	ck.charsMu.Lock()
+	first := ck.charsDeniedUntil.IsZero()
+	ck.charsDeniedUntil = time.Now().Add(charactersRetry)
+	ck.charsMu.Unlock()
+	ck.updateState(func(s *mapState) { s.mapCharacters = nil })
+
+	ck.mapLogger.Warnf("[getMapCharacters] %v; running in systems-only mode, retrying in %s", err, charactersRetry)
+	if first {
+		ck.sendWarningMessage(ctx, "The map API refuses character access, so running in systems-only mode: "+
+			"chain kills are alerted even when our own pilots are among the attackers.")
+	}
+}
+
+// charactersAllowed reports whether characters should be fetched now
+func (ck *Checker) charactersAllowed() bool {
+	ck.charsMu.RLock()
+	defer ck.charsMu.RUnlock()
+	return time.Now().After(ck.charsDeniedUntil)
+}
+
+// characters returns the map characters followed by the corp members who
+// aren't on the map
+func (ck *Checker) characters() []killmail.MapCharacter {
+	return ck.state().characters
+}
+
+func (ck *Checker) setCharacters(chars []killmail.MapCharacter) {
+	ck.charsMu.Lock()
+	ck.charsDeniedUntil = time.Time{}
+	ck.charsMu.Unlock()
+	ck.updateState(func(s *mapState) { s.mapCharacters = chars })
+}
@@ -0,0 +1,325 @@
+package chainkills
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/internal/discord"
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+	"golang.org/x/exp/slices"
+)
+
+const commandsHelp = "commands: `track <id>`, `untrack <id>`, `ignore <system>`, `unignore <system>`, `list`, `status`, " +
+	"`op [minutes]`, `op off`, `intel <system>`, `rules`, `enable <group>`, `disable <group>`, `threat`, " +
+	"`tag <killID> <tag>`, `untag <killID> <tag>`, `tags <killID>`, `dest <system>`; " +
+	"put an instance name first when several are configured, e.g. `!ck corp-a ignore J123456`"
+
+// systemResolver is implemented by ESI clients that can look up a system ID by name
+type systemResolver interface {
+	SystemID(ctx context.Context, name string) (int, error)
+}
+
+// waypointSetter is implemented by ESI clients that can set a signed-in character's autopilot
+type waypointSetter interface {
+	SetWaypoint(ctx context.Context, characterID int64, destinationID int) error
+}
+
+// commandPoller reads "!ck ..." admin commands from a Discord channel and
+// answers on the info webhook, so a webhook-only setup needs no shell access
+type commandPoller struct {
+	logger   logger.Logger
+	hub      *Hub
+	config   DiscordCommandsConfig
+	poller   *discord.ChannelPoller
+	interval time.Duration
+}
+
+func newCommandPoller(logger logger.Logger, h *Hub, config DiscordCommandsConfig) (*commandPoller, error) {
+	if config.BotToken == "" || config.ChannelId == "" {
+		return nil, errors.New("discordCommands requires botToken and channelId")
+	}
+	if len(config.AllowedUserIds) == 0 {
+		return nil, errors.New("discordCommands requires allowedUserIds, or anyone who can post in the channel could run commands")
+	}
+	if config.Prefix == "" {
+		config.Prefix = "!ck"
+	}
+	interval := 15 * time.Second
+	if config.PollSeconds > 0 {
+		interval = time.Duration(config.PollSeconds) * time.Second
+	}
+	return &commandPoller{
+		logger:   logger,
+		hub:      h,
+		config:   config,
+		poller:   discord.NewChannelPoller(h.http, config.BotToken, config.ChannelId),
+		interval: interval,
+	}, nil
+}
+
+// run polls the channel until ctx is done
+func (cp *commandPoller) run(ctx context.Context) {
+	cp.logger.Printf("[Commands] Reading %s commands from channel %s", cp.config.Prefix, cp.config.ChannelId)
+	ticker := time.NewTicker(cp.interval)
+	defer ticker.Stop()
+	for {
+		msgs, err := cp.poller.Poll(ctx)
+		if err != nil {
+			cp.logger.Printf("[Commands] Error reading channel: %v", err)
+		}
+		for _, m := range msgs {
+			cp.handle(ctx, m)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// handle runs one message if it is a command from an allowed user
+func (cp *commandPoller) handle(ctx context.Context, m discord.Message) {
+	fields := strings.Fields(m.Content)
+	if len(fields) == 0 || fields[0] != cp.config.Prefix || m.Author.Bot {
+		return
+	}
+	if !slices.Contains(cp.config.AllowedUserIds, m.Author.ID) {
+		cp.logger.Printf("[Commands] Ignoring command from %s (%s): not in allowedUserIds", m.Author.Username, m.Author.ID)
+		return
+	}
+	cp.logger.Printf("[Commands] %s: %s", m.Author.Username, m.Content)
+
+	args := fields[1:]
+	instance := ""
+	if len(args) > 0 && !isCommand(args[0]) {
+		instance, args = args[0], args[1:]
+	}
+	if instance == "" && len(args) > 0 && args[0] == "status" {
+		// without an instance name, report them all
+		lines := []string{}
+		for _, m := range cp.hub.Metrics() {
+			lines = append(lines, m.String())
+		}
+		cp.hub.checkers[0].sendInfoMessage(ctx, strings.Join(lines, "\n"))
+		return
+	}
+	ck, err := cp.hub.instance(instance)
+	if err != nil {
+		cp.hub.checkers[0].sendInfoMessage(ctx, err.Error())
+		return
+	}
+	if len(args) > 0 && args[0] == "op" {
+		ck.sendInfoMessage(ctx, cp.op(ck, args[1:], m.Author.Username))
+		return
+	}
+	if len(args) > 0 && (args[0] == "tag" || args[0] == "untag" || args[0] == "tags") {
+		ck.sendInfoMessage(ctx, cp.tag(args, m.Author.Username))
+		return
+	}
+	if len(args) > 0 && args[0] == "dest" {
+		ck.sendInfoMessage(ctx, cp.dest(ctx, ck, args[1:], discord.User(m.Author)))
+		return
+	}
+	ck.sendInfoMessage(ctx, cp.execute(ctx, ck, args))
+}
+
+func isCommand(word string) bool {
+	switch word {
+	case "track", "untrack", "ignore", "unignore", "list", "status", "op", "intel", "rules", "enable", "disable", "threat", "tag", "untag", "tags", "dest", "help":
+		return true
+	}
+	return false
+}
+
+// execute runs a command and returns the reply
+func (cp *commandPoller) execute(ctx context.Context, ck *Checker, args []string) string {
+	if len(args) == 0 || args[0] == "help" {
+		return commandsHelp
+	}
+	if args[0] == "status" {
+		return ck.Metrics().String()
+	}
+	if args[0] == "list" {
+		tracked, err := ck.TrackedIds()
+		if err != nil {
+			return err.Error()
+		}
+		ignored, _ := ck.IgnoredSystemIds()
+		reply := fmt.Sprintf("tracked: %v, ignored systems: %v", tracked, ignored)
+		if ck.autoIgnore != nil {
+			reply += fmt.Sprintf(", auto-ignored: %v", ck.autoIgnore.systems())
+		}
+		return reply + ", " + ck.opStatus()
+	}
+	if args[0] == "rules" {
+		return ck.ruleGroupsText()
+	}
+	if args[0] == "threat" {
+		threat, ok := ck.Threat()
+		if !ok {
+			return "threat is not enabled"
+		}
+		return fmt.Sprintf("threat level %s, score %d: %s", threat.Level, threat.Score, threat.summary())
+	}
+	if (args[0] == "enable" || args[0] == "disable") && len(args) > 1 {
+		// group names may have spaces, e.g. "home defense"
+		if err := ck.SetRuleGroupEnabled(strings.Join(args[1:], " "), args[0] == "enable"); err != nil {
+			return err.Error()
+		}
+		return ck.ruleGroupsText()
+	}
+	if len(args) != 2 {
+		return commandsHelp
+	}
+	if args[0] == "intel" {
+		report, err := ck.intel(ctx, args[1])
+		if err != nil {
+			return err.Error()
+		}
+		return report.String()
+	}
+
+	var (
+		list = trackedList
+		id   int
+		err  error
+	)
+	switch args[0] {
+	case "track", "untrack":
+		id, err = strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Sprintf("%q is not a corporation or alliance ID", args[1])
+		}
+	case "ignore", "unignore":
+		list = ignoredList
+		id, err = cp.systemID(ctx, ck, args[1])
+		if err != nil {
+			return fmt.Sprintf("could not resolve system %q: %v", args[1], err)
+		}
+	default:
+		return commandsHelp
+	}
+	add := args[0] == "track" || args[0] == "ignore"
+
+	ids, persisted, err := cp.hub.editTracking(ck, list, func(ids []int) []int {
+		if add {
+			if !slices.Contains(ids, id) {
+				ids = append(ids, id)
+			}
+			return ids
+		}
+		return slices.DeleteFunc(ids, func(v int) bool { return v == id })
+	})
+	if err != nil {
+		return err.Error()
+	}
+	reply := fmt.Sprintf("%s %d done, now %v", args[0], id, ids)
+	if !persisted {
+		reply += " (not saved to config.json; it will be lost on restart)"
+	}
+	return reply
+}
+
+// op starts a fleet op for an optional number of minutes, or ends it with "off"
+func (cp *commandPoller) op(ck *Checker, args []string, by string) string {
+	switch {
+	case len(args) == 0:
+		ck.startOp(0, by)
+		return "Fleet op mode on: " + ck.opStatus()
+	case len(args) == 1 && args[0] == "off":
+		if !ck.endOp(by) {
+			return "no fleet op"
+		}
+		return "Fleet op mode ended; chain alerts are back to normal."
+	case len(args) == 1:
+		minutes, err := strconv.Atoi(args[0])
+		if err != nil || minutes <= 0 {
+			return fmt.Sprintf("%q is not a number of minutes", args[0])
+		}
+		ck.startOp(minutes, by)
+		return "Fleet op mode on: " + ck.opStatus()
+	}
+	return commandsHelp
+}
+
+// tag tags or untags a kill, or lists its tags
+func (cp *commandPoller) tag(args []string, by string) string {
+	if len(args) < 2 || (args[0] != "tags" && len(args) != 3) {
+		return commandsHelp
+	}
+	killID, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return fmt.Sprintf("%q is not a kill ID", args[1])
+	}
+	switch args[0] {
+	case "tag":
+		err = cp.hub.TagKill(killID, args[2], by)
+	case "untag":
+		err = cp.hub.UntagKill(killID, args[2], by)
+	}
+	if err != nil {
+		return err.Error()
+	}
+	tags, err := cp.hub.KillTags(killID)
+	if err != nil {
+		return err.Error()
+	}
+	if len(tags) == 0 {
+		return fmt.Sprintf("kill %d has no tags", killID)
+	}
+	names := make([]string, len(tags))
+	for i, t := range tags {
+		names[i] = fmt.Sprintf("%s (%s)", t.Tag, t.By)
+	}
+	return fmt.Sprintf("kill %d tags: %s", killID, strings.Join(names, ", "))
+}
+
+// dest sets the autopilot of the author's character to the way out of a
+// chain system: the system itself in known space, else its nearest k-space exit
+func (cp *commandPoller) dest(ctx context.Context, ck *Checker, args []string, author discord.User) string {
+	if len(args) != 1 {
+		return commandsHelp
+	}
+	characterID, ok := cp.config.Characters[author.ID]
+	if !ok {
+		return fmt.Sprintf("%s has no character; add your Discord user ID to discordCommands.characters", author.Username)
+	}
+	setter, ok := ck.esi.(waypointSetter)
+	if !ok {
+		return "the ESI client cannot set waypoints"
+	}
+	systemID, err := cp.systemID(ctx, ck, args[0])
+	if err != nil {
+		return fmt.Sprintf("could not resolve system %q: %v", args[0], err)
+	}
+	exit, jumps, ok := ck.kspaceExit(systemID)
+	if !ok {
+		return fmt.Sprintf("no known-space exit from %s on the map", ck.state().alias(systemID))
+	}
+	if err := setter.SetWaypoint(ctx, characterID, exit); err != nil {
+		return fmt.Sprintf("could not set %s's destination: %v", author.Username, err)
+	}
+	st := ck.state()
+	if jumps == 0 {
+		return fmt.Sprintf("destination set to %s for %s", st.alias(exit), author.Username)
+	}
+	return fmt.Sprintf("destination set to %s, %s's exit %s, for %s",
+		st.alias(exit), st.alias(systemID), plural(jumps, "jump"), author.Username)
+}
+
+// systemID accepts a system ID or an exact system name such as J123456
+func (cp *commandPoller) systemID(ctx context.Context, ck *Checker, arg string) (int, error) {
+	if id, err := strconv.Atoi(arg); err == nil {
+		return id, nil
+	}
+	resolver, ok := ck.esi.(systemResolver)
+	if !ok {
+		return 0, errors.New("the ESI client cannot look up names; use the system ID")
+	}
+	return resolver.SystemID(ctx, arg)
+}
@@ -0,0 +1,144 @@
+package chainkills
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/guarzo/eve-chainkills/internal/discord"
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+	"github.com/guarzo/eve-chainkills/pkg/pipeline"
+	"golang.org/x/exp/slices"
+)
+
+// replyTransport records the text of every message posted to a Discord webhook
+type replyTransport struct {
+	mu      sync.Mutex
+	replies []string
+}
+
+func (rt *replyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var msg struct {
+		Content string `json:"content"`
+	}
+	if req.Body != nil {
+		_ = json.NewDecoder(req.Body).Decode(&msg)
+	}
+	rt.mu.Lock()
+	rt.replies = append(rt.replies, msg.Content)
+	rt.mu.Unlock()
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"id":"1"}`)),
+		Request:    req,
+	}, nil
+}
+
+func (rt *replyTransport) take() []string {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	replies := rt.replies
+	rt.replies = nil
+	return replies
+}
+
+func newCommandTest(t *testing.T, config *Config) (*commandPoller, *Checker, *replyTransport) {
+	t.Helper()
+	transport := &replyTransport{}
+	client := &http.Client{Transport: transport}
+	log := logger.Slog(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	config.DiscordInfoWebhookId, config.DiscordInfoWebhookToken = "1", "token"
+	ck, err := newChecker(&options{
+		logger:   log,
+		esi:      echoESI{},
+		http:     client,
+		webhooks: discord.NewWebhooks(client, 0, pipeline.NumPriorities),
+	}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &Hub{logger: log, checkers: []*Checker{ck}, http: client}
+	cp, err := newCommandPoller(log, h, DiscordCommandsConfig{BotToken: "bot", ChannelId: "2", AllowedUserIds: []string{"111"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cp, ck, transport
+}
+
+func commandMessage(authorID, content string) discord.Message {
+	var m discord.Message
+	m.Content = content
+	m.Author.ID, m.Author.Username = authorID, "user"+authorID
+	return m
+}
+
+func TestCommandsRequireAllowedUsers(t *testing.T) {
+	h := &Hub{}
+	if _, err := newCommandPoller(logger.Slog(slog.New(slog.NewTextHandler(io.Discard, nil))), h, DiscordCommandsConfig{BotToken: "bot", ChannelId: "2"}); err == nil {
+		t.Error("newCommandPoller without allowedUserIds succeeded")
+	}
+	if _, err := newCommandPoller(logger.Slog(slog.New(slog.NewTextHandler(io.Discard, nil))), h, DiscordCommandsConfig{ChannelId: "2", AllowedUserIds: []string{"111"}}); err == nil {
+		t.Error("newCommandPoller without a bot token succeeded")
+	}
+}
+
+func TestCommandsRejectUnauthorized(t *testing.T) {
+	cp, ck, transport := newCommandTest(t, &Config{InsightTrackedIds: []int{98000001}})
+	ctx := context.Background()
+
+	bot := commandMessage("111", "!ck track 98000002")
+	bot.Author.Bot = true
+	for _, m := range []discord.Message{
+		commandMessage("222", "!ck track 98000002"),
+		commandMessage("222", "!ck untrack 98000001"),
+		bot,
+	} {
+		cp.handle(ctx, m)
+	}
+	if ids, _ := ck.TrackedIds(); !slices.Equal(ids, []int{98000001}) {
+		t.Errorf("tracked %v after commands from others, want it unchanged", ids)
+	}
+	if replies := transport.take(); len(replies) != 0 {
+		t.Errorf("answered others with %q", replies)
+	}
+}
+
+func TestCommandsEditTracking(t *testing.T) {
+	cp, ck, transport := newCommandTest(t, &Config{InsightTrackedIds: []int{98000001}})
+	ctx := context.Background()
+
+	cp.handle(ctx, commandMessage("111", "!ck track 98000002"))
+	if ids, _ := ck.TrackedIds(); !slices.Equal(ids, []int{98000001, 98000002}) {
+		t.Errorf("tracked %v after track, want 98000002 added", ids)
+	}
+	cp.handle(ctx, commandMessage("111", "!ck untrack 98000001"))
+	if ids, _ := ck.TrackedIds(); !slices.Equal(ids, []int{98000002}) {
+		t.Errorf("tracked %v after untrack, want 98000001 removed", ids)
+	}
+	cp.handle(ctx, commandMessage("111", "!ck ignore 31000001"))
+	if ids, _ := ck.IgnoredSystemIds(); !slices.Equal(ids, []int{31000001}) {
+		t.Errorf("ignored %v after ignore, want 31000001", ids)
+	}
+	cp.handle(ctx, commandMessage("111", "!ck track corp"))
+	// other prefixes are someone else's bot
+	cp.handle(ctx, commandMessage("111", "!other track 98000003"))
+
+	replies := transport.take()
+	if len(replies) != 4 {
+		t.Fatalf("replies %q, want one per command", replies)
+	}
+	for i, want := range []string{"track 98000002 done, now [98000001 98000002]", "untrack 98000001 done, now [98000002]", "ignore 31000001 done", `"corp" is not a corporation or alliance ID`} {
+		if !strings.Contains(replies[i], want) {
+			t.Errorf("reply %d = %q, want it to contain %q", i, replies[i], want)
+		}
+	}
+	if !strings.Contains(replies[0], "not saved to config.json") {
+		t.Errorf("reply %q doesn't say the change isn't saved", replies[0])
+	}
+}
@@ -0,0 +1,1145 @@
+package chainkills
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/internal/httpclient"
+	mapapi "github.com/guarzo/eve-chainkills/internal/map"
+	"github.com/guarzo/eve-chainkills/pkg/killmail"
+	"github.com/guarzo/eve-chainkills/pkg/notify"
+	"github.com/guarzo/eve-chainkills/pkg/pipeline"
+)
+
+// Config mirrors your config.json fields
+type Config struct {
+	// Name identifies an instance in logs; optional for single-instance setups
+	Name string `json:"name"`
+
+	IgnoreSystemIds              []int  `json:"ignoreSystemIds"`
+	DiscordChainkillWebhookId    string `json:"discordChainkillWebhookId"`
+	DiscordChainkillWebhookToken string `json:"discordChainkillWebhookToken"`
+	DiscordInfoWebhookId         string `json:"discordInfoWebhookId"`
+	DiscordInfoWebhookToken      string `json:"discordInfoWebhookToken"`
+	DiscordCorpkillWebhookId     string `json:"discordCorpkillWebhookId"`
+	DiscordCorpkillWebhookToken  string `json:"discordCorpkillWebhookToken"`
+	// DiscordWebhooks names webhooks, e.g. "defense" or "leadership", for the
+	// discord*Webhook fields here and the discordWebhook fields of other settings
+	DiscordWebhooks         map[string]DiscordWebhookConfig `json:"discordWebhooks"`
+	DiscordChainkillWebhook string                          `json:"discordChainkillWebhook"`
+	DiscordInfoWebhook      string                          `json:"discordInfoWebhook"`
+	DiscordCorpkillWebhook  string                          `json:"discordCorpkillWebhook"`
+
+	CharacterIdForUpdates        string `json:"characterIdForUpdates"`
+	SystemKillStatusResetMinutes int    `json:"systemKillStatusResetMinutes"`
+	DiscordStatusReportMins      int    `json:"discordStatusReportMins"`
+	LogLevel                     string `json:"logLevel"`
+	InsightTrackedIds            []int  `json:"insightTrackedIds"`
+
+	// TrackedCorporationIds, TrackedAllianceIds and TrackedCharacterIds match
+	// only their own entity type; insightTrackedIds match corporations and alliances alike
+	TrackedCorporationIds []int   `json:"trackedCorporationIds"`
+	TrackedAllianceIds    []int   `json:"trackedAllianceIds"`
+	TrackedCharacterIds   []int64 `json:"trackedCharacterIds"`
+
+	// TrackedSource adds tracked IDs from an external list, refreshed while running
+	TrackedSource TrackedSourceConfig `json:"trackedSource"`
+
+	// LogLevels overrides LogLevel per component: zkill, esi, map, discord, filter, pipeline, sinks
+	LogLevels map[string]string `json:"logLevels"`
+
+	DiscordKillNotifications struct {
+		KillColor string `json:"killColor"`
+		LossColor string `json:"lossColor"`
+		// ISKFormat is "short" (default), "full" or "both"
+		ISKFormat string `json:"iskFormat"`
+		// Format is the corp-kill webhooks' profile, ChainFormat the chain webhook's:
+		// rich (default), compact, plain or json
+		Format      notify.Format `json:"format"`
+		ChainFormat notify.Format `json:"chainFormat"`
+		// MaxBatch is how many kill embeds queued for one webhook during a
+		// burst share a request (default and most 10, 1 disables batching)
+		MaxBatch int `json:"maxBatch"`
+		// BatchWindowMs is how long a kill embed waits for others to join its
+		// request (default 0: only those already queued do)
+		BatchWindowMs int `json:"batchWindowMs"`
+	} `json:"discordKillNotifications"`
+
+	// Images selects the image server and sizes used in embeds
+	Images killmail.Images `json:"images"`
+	// DisplayTimezone is an IANA zone, e.g. "America/New_York", that plain-text
+	// sinks show kill times in next to EVE time
+	DisplayTimezone string `json:"displayTimezone"`
+
+	// The new API fields:
+	APIBaseUrl string `json:"apiBaseUrl"`
+	APISlug    string `json:"apiSlug"`
+	APIToken   string `json:"apiToken"`
+	// APITokens are tried after apiToken, each only for the endpoints in its scopes
+	APITokens []MapToken `json:"apiTokens"`
+	// APIOAuth renews an expiring map API token; it replaces apiToken when set
+	APIOAuth MapOAuthConfig `json:"apiOAuth"`
+
+	// Additional notification sinks
+	Telegram   notify.TelegramConfig    `json:"telegram"`
+	Webhooks   []notify.WebhookConfig   `json:"webhooks"`
+	NATS       notify.NATSConfig        `json:"nats"`
+	MQTT       notify.MQTTConfig        `json:"mqtt"`
+	XMPP       notify.XMPPConfig        `json:"xmpp"`
+	Push       notify.PushConfig        `json:"push"`
+	Mattermost notify.ChatWebhookConfig `json:"mattermost"`
+	RocketChat notify.ChatWebhookConfig `json:"rocketChat"`
+	// Federation shares alerts with allied instances and takes theirs
+	Federation FederationConfig `json:"federation"`
+	// Fallbacks deliver alerts to the first sink of a chain that takes them
+	Fallbacks []FallbackConfig `json:"fallbacks"`
+
+	// Killstream picks the zKillboard channels subscribed to and where kills are processed
+	Killstream KillstreamConfig `json:"killstream"`
+
+	Pipeline PipelineConfig `json:"pipeline"`
+	// Hooks are external commands run, in order, for every matched kill
+	Hooks []pipeline.HookConfig `json:"hooks"`
+
+	// SelfTest checks the map API and ESI at startup
+	SelfTest SelfTestConfig `json:"selfTest"`
+
+	// Status tunes the periodic status report sent to the info webhook
+	Status StatusConfig `json:"status"`
+
+	// HTTP tunes the HTTP client shared by every outbound request
+	HTTP HTTPConfig `json:"http"`
+	// Priority limits concurrent work so urgent kills go first under load
+	Priority PriorityConfig `json:"priority"`
+	// Tracing exports OpenTelemetry spans for each kill to an OTLP/HTTP collector
+	Tracing TracingConfig `json:"tracing"`
+
+	// Schema saves samples of upstream payloads that fail to decode or carry unknown fields
+	Schema SchemaConfig `json:"schema"`
+
+	// ErrorReporting sends pipeline errors and panics to Sentry and/or a webhook
+	ErrorReporting ErrorReportingConfig `json:"errorReporting"`
+
+	// Debug serves pprof and /debug/vars for diagnosing a running process
+	Debug DebugConfig `json:"debug"`
+
+	// Systemd tunes the sd_notify watchdog when running as a Type=notify service
+	Systemd SystemdConfig `json:"systemd"`
+
+	// Heartbeat pings an Uptime Kuma or Healthchecks.io push URL while the killstream is alive; top-level only
+	Heartbeat HeartbeatConfig `json:"heartbeat"`
+
+	// Admin serves endpoints that change tracked IDs and ignored systems while running
+	Admin AdminConfig `json:"admin"`
+
+	// Audit records what happened to every processed kill, for "chainkills why"
+	Audit AuditConfig `json:"audit"`
+
+	// Attribution records which of our pilots were on each matched kill
+	Attribution AttributionConfig `json:"attribution"`
+
+	// Tags keeps labels people put on kills, e.g. "srp-approved"
+	Tags TagsConfig `json:"tags"`
+
+	// GalaxyActivity counts every kill on the killstream, for the digest's galaxy activity line
+	GalaxyActivity GalaxyActivityConfig `json:"galaxyActivity"`
+
+	// SSO signs characters in through EVE SSO for authenticated ESI calls
+	SSO SSOConfig `json:"sso"`
+
+	// DiscordCommands reads "!ck ignore J123456" style commands from a Discord channel
+	DiscordCommands DiscordCommandsConfig `json:"discordCommands"`
+
+	// Instances run independent map slugs, tracked IDs and sinks off one zKill
+	// connection. Each instance inherits every top-level field it doesn't set.
+	Instances []Config `json:"instances"`
+
+	// Locations are named structures or celestials; kills there always alert
+	Locations []LocationConfig `json:"locations"`
+
+	// TriglavianSpace alerts on kills in Pochven and Zarzakh like a location
+	TriglavianSpace TriglavianSpaceConfig `json:"triglavianSpace"`
+
+	// FactionWarfare adds militia and warzone status to kills, and can route or alert on them
+	FactionWarfare FactionWarfareConfig `json:"factionWarfare"`
+
+	// Abyssal sends corp kills and losses in Abyssal Deadspace somewhere else
+	Abyssal AbyssalConfig `json:"abyssal"`
+
+	// MapLinks adds a link to the kill's system in the mapping tool's UI to chain alerts
+	MapLinks MapLinksConfig `json:"mapLinks"`
+
+	// HomeSystemId makes chain alerts show the route from the kill back to home
+	HomeSystemId int `json:"homeSystemId"`
+
+	// ZkillStats adds a threat summary of the final blow's corporation to chain alerts and corp losses
+	ZkillStats ZkillStatsConfig `json:"zkillStats"`
+
+	// NewGroupAlert flags chain alerts whose attackers haven't been seen in the chain recently
+	NewGroupAlert NewGroupAlertConfig `json:"newGroupAlert"`
+
+	// FriendlyDanger alerts when a kill by non-map pilots happens where map characters are
+	FriendlyDanger FriendlyDangerConfig `json:"friendlyDanger"`
+
+	// CorpMembers adds the corporation roster from ESI to the map characters
+	CorpMembers CorpMembersConfig `json:"corpMembers"`
+
+	// Fleet suppresses chain alerts for kills made by the signed-in fleet boss's fleet
+	Fleet FleetConfig `json:"fleet"`
+
+	// RuleGroups name sets of match rules whose alerts can be switched off while running
+	RuleGroups []RuleGroupConfig `json:"ruleGroups"`
+
+	// Threat scores recent signs of an eviction into a green/yellow/red threat level
+	Threat ThreatConfig `json:"threat"`
+
+	// StructureSiege sums up repeated structure kills against one corporation in a system
+	StructureSiege StructureSiegeConfig `json:"structureSiege"`
+
+	// Timerboard creates timerboard entries for structure kills
+	Timerboard TimerboardConfig `json:"timerboard"`
+
+	// AutoIgnore ignores chain systems for a while where map characters keep getting kills
+	AutoIgnore AutoIgnoreConfig `json:"autoIgnore"`
+
+	// OpMode quiets or suppresses chain alerts during a fleet op declared by command or admin API
+	OpMode OpModeConfig `json:"opMode"`
+
+	// ChainAlerts tunes which kills in chain systems alert
+	ChainAlerts ChainAlertsConfig `json:"chainAlerts"`
+
+	// CrossPost decides whether a corp kill in a chain system also, or instead, goes out as a chain alert
+	CrossPost CrossPostConfig `json:"crossPost"`
+
+	// ValueUpdates edits corp-kill embeds once zKillboard's value for the kill settles
+	ValueUpdates ValueUpdatesConfig `json:"valueUpdates"`
+
+	// Aggregate holds alerts briefly and sends the kills in one system together
+	Aggregate AggregateConfig `json:"aggregate"`
+
+	// BothSides sends kills with tracked or mapped pilots on both sides, e.g. an awox, somewhere else
+	BothSides BothSidesConfig `json:"bothSides"`
+
+	// MappedCharacters picks which side of a kill map characters are matched on, and where those alerts go
+	MappedCharacters MappedCharactersConfig `json:"mappedCharacters"`
+
+	// InfoChannels routes info webhook messages by severity, each with its own rate limit
+	InfoChannels InfoChannelsConfig `json:"infoChannels"`
+
+	// Downtime is EVE's daily downtime, when outages are expected and errors aren't posted; top-level only
+	Downtime DowntimeConfig `json:"downtime"`
+
+	// Acknowledgments watches chain alerts for a reaction and reports response times daily
+	Acknowledgments AcknowledgmentsConfig `json:"acknowledgments"`
+	// Digest sets when the daily digest of acknowledgments and galaxy activity is sent
+	Digest DigestConfig `json:"digest"`
+
+	// Escalations are fallbacks for when chain alerts pile up unacknowledged
+	Escalations []EscalationConfig `json:"escalations"`
+
+	// path is the file LoadConfig read, where admin changes are saved
+	path string
+}
+
+// SelfTestConfig controls the startup self-test
+type SelfTestConfig struct {
+	// Disabled skips the self-test, e.g. when the map API is expected to come up later
+	Disabled bool `json:"disabled"`
+	// AnnounceStartup posts "starting up, <version>, tracking N systems" to the info webhook
+	AnnounceStartup bool `json:"announceStartup"`
+}
+
+// StatusConfig tunes the periodic status report
+type StatusConfig struct {
+	// IntervalMinutes between reports; defaults to discordStatusReportMins
+	IntervalMinutes int `json:"intervalMinutes"`
+	// Verbosity is "text", "summary" (default) or "detailed"
+	Verbosity string `json:"verbosity"`
+	// LogMinutes between metrics log lines; defaults to 15, negative turns them off
+	LogMinutes int `json:"logMinutes"`
+}
+
+// HTTPConfig tunes the connection pool of the shared HTTP client
+type HTTPConfig struct {
+	// TimeoutSeconds per request (default 10)
+	TimeoutSeconds int `json:"timeoutSeconds"`
+	// MaxIdleConns kept open across all hosts (default 100)
+	MaxIdleConns int `json:"maxIdleConns"`
+	// MaxIdleConnsPerHost kept open to each host, e.g. ESI (default 10)
+	MaxIdleConnsPerHost int `json:"maxIdleConnsPerHost"`
+	// MaxConnsPerHost caps connections to each host; 0 is unlimited
+	MaxConnsPerHost int `json:"maxConnsPerHost"`
+	// IdleConnTimeoutSeconds before an idle connection is closed (default 90)
+	IdleConnTimeoutSeconds int `json:"idleConnTimeoutSeconds"`
+	// DisableHTTP2 sticks to HTTP/1.1
+	DisableHTTP2 bool `json:"disableHttp2"`
+}
+
+func (hc HTTPConfig) validate() error {
+	for name, v := range map[string]int{
+		"timeoutSeconds":         hc.TimeoutSeconds,
+		"maxIdleConns":           hc.MaxIdleConns,
+		"maxIdleConnsPerHost":    hc.MaxIdleConnsPerHost,
+		"maxConnsPerHost":        hc.MaxConnsPerHost,
+		"idleConnTimeoutSeconds": hc.IdleConnTimeoutSeconds,
+	} {
+		if v < 0 {
+			return fmt.Errorf("http.%s must not be negative, got %d", name, v)
+		}
+	}
+	return nil
+}
+
+func (hc HTTPConfig) client() httpclient.Config {
+	return httpclient.Config{
+		Timeout:             time.Duration(hc.TimeoutSeconds) * time.Second,
+		MaxIdleConns:        hc.MaxIdleConns,
+		MaxIdleConnsPerHost: hc.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     hc.MaxConnsPerHost,
+		IdleConnTimeout:     time.Duration(hc.IdleConnTimeoutSeconds) * time.Second,
+		DisableHTTP2:        hc.DisableHTTP2,
+	}
+}
+
+// PriorityConfig sets how many kills are worked on and how many Discord
+// posts are sent at once; past that, kills wait in priority order
+type PriorityConfig struct {
+	// Workers is how many matched kills are enriched and delivered at once
+	// (default 8, -1 disables the limit)
+	Workers int `json:"workers"`
+	// DiscordSends is how many Discord webhook requests run at once
+	// (default 2, -1 disables the limit)
+	DiscordSends int `json:"discordSends"`
+}
+
+func (pc PriorityConfig) validate() error {
+	for name, v := range map[string]int{"workers": pc.Workers, "discordSends": pc.DiscordSends} {
+		if v < -1 {
+			return fmt.Errorf("priority.%s must be -1 or more, got %d", name, v)
+		}
+	}
+	return nil
+}
+
+// gateSlots turns a setting into a gate size, 0 for no limit
+func gateSlots(v, def int) int {
+	switch {
+	case v < 0:
+		return 0
+	case v == 0:
+		return def
+	}
+	return v
+}
+
+// TracingConfig selects the OTLP/HTTP collector; an empty endpoint disables tracing
+type TracingConfig struct {
+	Endpoint    string            `json:"endpoint"` // e.g. http://localhost:4318
+	ServiceName string            `json:"serviceName"`
+	Headers     map[string]string `json:"headers"`
+}
+
+// ErrorReportingConfig selects where errors are reported; with neither a DSN
+// nor a webhook URL, reporting is off
+type ErrorReportingConfig struct {
+	SentryDSN   string `json:"sentryDsn"`
+	WebhookURL  string `json:"webhookUrl"`
+	Environment string `json:"environment"`
+	// RepeatWindowMinutes folds repeats of one error into a single report (default 60)
+	RepeatWindowMinutes int `json:"repeatWindowMinutes"`
+}
+
+// DebugConfig enables the pprof and /debug/vars endpoints; an empty Listen disables them
+type DebugConfig struct {
+	Listen string `json:"listen"` // e.g. 127.0.0.1:6060
+	// Token is required as a Bearer token or ?token=; mandatory on non-loopback addresses
+	Token string `json:"token"`
+}
+
+// SystemdConfig tunes the watchdog; it only applies when started by systemd
+type SystemdConfig struct {
+	// FeedTimeoutMinutes stops watchdog pings once the killstream has been
+	// silent this long, so systemd restarts a wedged process (default 15, -1 disables)
+	FeedTimeoutMinutes int `json:"feedTimeoutMinutes"`
+}
+
+// HeartbeatConfig selects the push monitor; an empty URL disables heartbeats
+type HeartbeatConfig struct {
+	// URL is requested every IntervalSeconds while the feed is alive
+	URL string `json:"url"`
+	// DownURL, when set, is requested instead while the feed is silent, e.g.
+	// a Healthchecks.io ".../fail" URL or Uptime Kuma's "?status=down"
+	DownURL string `json:"downUrl"`
+	// IntervalSeconds between heartbeats (default 60)
+	IntervalSeconds int `json:"intervalSeconds"`
+	// MaxSilenceSeconds since the last kill or websocket pong for the feed to
+	// count as alive (default 300)
+	MaxSilenceSeconds int `json:"maxSilenceSeconds"`
+}
+
+// LocationConfig names a point of interest by zKillboard's locationID, the
+// nearest celestial or structure to the kill
+type LocationConfig struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+// TriglavianSpaceConfig alerts on kills in Pochven, and optionally Zarzakh,
+// for groups that live there rather than in a chain
+type TriglavianSpaceConfig struct {
+	Enabled bool `json:"enabled"`
+	// Zarzakh includes kills in Zarzakh
+	Zarzakh bool `json:"zarzakh"`
+}
+
+// FactionWarfareConfig picks the militias a group cares about and where
+// their kills go
+type FactionWarfareConfig struct {
+	Enabled bool `json:"enabled"`
+	// FactionIds are the militias, e.g. 500001 for the Caldari State; empty means all of them
+	FactionIds []int `json:"factionIds"`
+	// Alert sends kills in a warzone with those militias' pilots on either side
+	// as corp kills or losses, even when nothing else matched
+	Alert bool `json:"alert"`
+	// RefreshMinutes between warzone status fetches (default 30, ESI's cache time)
+	RefreshMinutes int `json:"refreshMinutes"`
+	// DiscordWebhookId and DiscordWebhookToken post them to a separate channel
+	// instead of the corp-kill one
+	DiscordWebhookId    string `json:"discordWebhookId"`
+	DiscordWebhookToken string `json:"discordWebhookToken"`
+	// DiscordWebhook names a discordWebhooks entry instead
+	DiscordWebhook string `json:"discordWebhook"`
+	// Sinks limits them to these sinks; empty means every sink
+	Sinks []string `json:"sinks"`
+}
+
+// AbyssalConfig routes kills in Abyssal Deadspace, mostly losses to its NPCs
+type AbyssalConfig struct {
+	// DiscordWebhookId and DiscordWebhookToken post them to a separate channel
+	// instead of the corp-kill one
+	DiscordWebhookId    string `json:"discordWebhookId"`
+	DiscordWebhookToken string `json:"discordWebhookToken"`
+	// DiscordWebhook names a discordWebhooks entry instead
+	DiscordWebhook string `json:"discordWebhook"`
+	// Sinks limits them to these sinks; empty means every sink
+	Sinks []string `json:"sinks"`
+}
+
+// ZkillStatsConfig enables zKillboard stats lookups, which add latency to each alert
+type ZkillStatsConfig struct {
+	Enabled bool `json:"enabled"`
+	// CacheHours keeps each corporation's stats (default 12)
+	CacheHours int `json:"cacheHours"`
+}
+
+// NewGroupAlertConfig tunes the "NEW GROUP IN CHAIN" prefix on chain alerts
+type NewGroupAlertConfig struct {
+	Enabled bool `json:"enabled"`
+	// WindowHours a corp/alliance must have been absent to count as new (default 72)
+	WindowHours int `json:"windowHours"`
+	// WarmupMinutes after startup during which groups are only learned (default 60, -1 disables)
+	WarmupMinutes int `json:"warmupMinutes"`
+}
+
+// FriendlyDangerConfig polls the map for character locations
+type FriendlyDangerConfig struct {
+	Enabled bool `json:"enabled"`
+	// PollSeconds between character location refreshes (default 60)
+	PollSeconds int `json:"pollSeconds"`
+}
+
+// SSOConfig is an application registered at developers.eveonline.com;
+// characters are signed in with "chainkills sso login"
+type SSOConfig struct {
+	ClientID     string `json:"clientId"`
+	ClientSecret string `json:"clientSecret"`
+	// CallbackURL must match the application's (default http://localhost:8765/callback)
+	CallbackURL string `json:"callbackUrl"`
+	// TokenFile stores the signed-in characters' refresh tokens (default sso-tokens.json)
+	TokenFile string `json:"tokenFile"`
+	// Scopes requested at login; defaults to every scope chainkills uses
+	Scopes []string `json:"scopes"`
+}
+
+// TrackedSourceConfig fetches tracked IDs from a URL, e.g. an alliance's
+// standings endpoint, or from SeAT. A URL's response is a JSON array of
+// insightTrackedIds, or an object with any of insightTrackedIds,
+// trackedCorporationIds, trackedAllianceIds and trackedCharacterIds.
+type TrackedSourceConfig struct {
+	// Kind is "url" (the default) or "seat"
+	Kind string `json:"kind"`
+	// URL is fetched on startup and every RefreshMinutes; empty turns it off.
+	// For SeAT it is the site, e.g. https://seat.example.com
+	URL string `json:"url"`
+	// Headers are added to every request, e.g. {"Authorization": "Bearer ..."}
+	Headers map[string]string `json:"headers"`
+	// RefreshMinutes between fetches (default 15)
+	RefreshMinutes int `json:"refreshMinutes"`
+
+	// Token is SeAT's API token
+	Token string `json:"token"`
+	// CorporationIds are tracked, along with the contacts SeAT has for them
+	CorporationIds []int `json:"corporationIds"`
+	// MinStanding a SeAT contact needs to be tracked (default: any positive standing)
+	MinStanding float64 `json:"minStanding"`
+}
+
+// CorpMembersConfig reads the member list of a signed-in director's corporation
+type CorpMembersConfig struct {
+	Enabled bool `json:"enabled"`
+	// RefreshMinutes between roster fetches (default 60, ESI's cache time)
+	RefreshMinutes int `json:"refreshMinutes"`
+}
+
+// FleetConfig reads the fleet of a signed-in fleet boss
+type FleetConfig struct {
+	Enabled bool `json:"enabled"`
+	// PollSeconds between fleet member fetches (default 60)
+	PollSeconds int `json:"pollSeconds"`
+}
+
+// MapLinksConfig builds the mapping tool links in chain alerts
+type MapLinksConfig struct {
+	Enabled bool `json:"enabled"`
+	// URLTemplate fills in {base} (apiBaseUrl without a trailing /api), {slug},
+	// {systemId} and {alias}; defaults to "{base}/{slug}?system={systemId}"
+	URLTemplate string `json:"urlTemplate"`
+}
+
+// RuleGroupConfig names a set of match rules, e.g. "brags" for
+// ["attacker_tracked", "mapped_character"]
+type RuleGroupConfig struct {
+	Name  string   `json:"name"`
+	Rules []string `json:"rules"`
+	// Disabled starts the group switched off
+	Disabled bool `json:"disabled"`
+}
+
+// ThreatConfig sets how signals are weighed into a threat level and where it's posted
+type ThreatConfig struct {
+	Enabled bool `json:"enabled"`
+	// Weights override defaultThreatWeights by signal, e.g. {"near_home": 2}; 0 ignores a signal
+	Weights map[string]int `json:"weights"`
+	// WindowHours of signals are scored (default 6)
+	WindowHours int `json:"windowHours"`
+	// YellowScore and RedScore are the scores where those levels start (default 8 and 20)
+	YellowScore int `json:"yellowScore"`
+	RedScore    int `json:"redScore"`
+	// NearHomeJumps is how close to home through the chain a kill counts as near (default 2)
+	NearHomeJumps int `json:"nearHomeJumps"`
+	// Overrides notify.DefaultCapitalGroupIds
+	CapitalGroupIds []int `json:"capitalGroupIds"`
+	// Level changes go to this webhook, or the info webhook when unset
+	DiscordWebhookId    string `json:"discordWebhookId"`
+	DiscordWebhookToken string `json:"discordWebhookToken"`
+	// DiscordWebhook names a discordWebhooks entry instead
+	DiscordWebhook string `json:"discordWebhook"`
+}
+
+// StructureSiegeConfig sets when structure kills become a siege and where its summary goes
+type StructureSiegeConfig struct {
+	Enabled bool `json:"enabled"`
+	// MinKills of one corporation's structures in a system start a siege (default 2);
+	// it is over once WindowHours pass without another (default 24)
+	MinKills    int `json:"minKills"`
+	WindowHours int `json:"windowHours"`
+	// UpdateMinutes between edits of the summary message (default 5)
+	UpdateMinutes int `json:"updateMinutes"`
+	// Overrides defaultStructureGroupIds
+	StructureGroupIds []int `json:"structureGroupIds"`
+	// The summary goes to this webhook, or the info webhook when unset
+	DiscordWebhookId    string `json:"discordWebhookId"`
+	DiscordWebhookToken string `json:"discordWebhookToken"`
+	// DiscordWebhook names a discordWebhooks entry instead
+	DiscordWebhook string `json:"discordWebhook"`
+}
+
+// FallbackConfig is a chain of sinks, e.g. discord, telegram, push: an alert
+// goes to the next one only when every try at the one before has failed
+type FallbackConfig struct {
+	// Kinds of alert the chain is for; empty means all
+	Kinds []notify.Kind `json:"kinds"`
+	// Sinks by name, in the order they're tried
+	Sinks []string `json:"sinks"`
+	// Retries of each sink before the next is tried (default 2), RetryDelaySeconds apart (default 2)
+	Retries           int `json:"retries"`
+	RetryDelaySeconds int `json:"retryDelaySeconds"`
+}
+
+// TimerboardConfig sends structure kills to a timerboard's REST API
+type TimerboardConfig struct {
+	// URL receives a request per timer; empty turns the timerboard off
+	URL string `json:"url"`
+	// Method is POST (the default) or PUT
+	Method string `json:"method"`
+	// Headers are added to every request, e.g. {"Authorization": "Bearer ..."}
+	Headers map[string]string `json:"headers"`
+	// Payload is a Go template over a TimerEntry; it defaults to a JSON object of its fields
+	Payload string `json:"payload"`
+	// AggressionHours estimates the timer after a kill next to a structure (default 24)
+	AggressionHours int `json:"aggressionHours"`
+	// CooldownMinutes between entries for kills next to the same structure (default 60)
+	CooldownMinutes int `json:"cooldownMinutes"`
+	// Overrides defaultStructureGroupIds
+	StructureGroupIds []int `json:"structureGroupIds"`
+}
+
+// AutoIgnoreConfig sets when a chain system is ignored for friendly activity and for how long
+type AutoIgnoreConfig struct {
+	Enabled bool `json:"enabled"`
+	// MinKills by map characters within WindowHours ignore a system (default 5 in 2 hours)
+	MinKills    int `json:"minKills"`
+	WindowHours int `json:"windowHours"`
+	// IdleHours without such kills bring it back (default 6)
+	IdleHours int `json:"idleHours"`
+}
+
+// OpModeConfig sets what a declared fleet op does to chain alerts
+type OpModeConfig struct {
+	// Action is "quiet" (default: sent without the mention or push) or "suppress"
+	Action string `json:"action"`
+	// DefaultMinutes an op lasts when started without a duration (default 120)
+	DefaultMinutes int `json:"defaultMinutes"`
+	// MaxMinutes caps an op's duration, so a forgotten one still ends (default 480)
+	MaxMinutes int `json:"maxMinutes"`
+}
+
+// ChainAlertsConfig filters chain alerts
+type ChainAlertsConfig struct {
+	// RequirePlayerAttacker skips chain kills without a player among the attackers, and NPC deaths
+	RequirePlayerAttacker bool `json:"requirePlayerAttacker"`
+}
+
+// Cross-posting modes for corp kills in chain systems
+const (
+	CrossPostCorp  = "corp"
+	CrossPostChain = "chain"
+	CrossPostBoth  = "both"
+)
+
+// CrossPostConfig picks, per match rule, how a corp kill in a chain system is posted
+type CrossPostConfig struct {
+	// Default is "corp" (only the corp kill, the default), "chain" (only the
+	// chain alert) or "both" (the corp kill, plus the chain alert without a mention)
+	Default string `json:"default"`
+	// Rules overrides Default per match rule, e.g. {"victim_tracked": "both"}
+	Rules map[string]string `json:"rules"`
+}
+
+// mode returns the cross-posting mode for a match rule
+func (cp CrossPostConfig) mode(rule string) string {
+	if m, ok := cp.Rules[rule]; ok {
+		return m
+	}
+	if cp.Default == "" {
+		return CrossPostCorp
+	}
+	return cp.Default
+}
+
+// validate rejects unknown modes
+func (cp CrossPostConfig) validate() error {
+	modes := map[string]string{"default": cp.Default}
+	for rule, m := range cp.Rules {
+		modes["rules."+rule] = m
+	}
+	for key, m := range modes {
+		switch m {
+		case "", CrossPostCorp, CrossPostChain, CrossPostBoth:
+		default:
+			return fmt.Errorf("crossPost.%s: unknown mode %q", key, m)
+		}
+	}
+	return nil
+}
+
+// ValueUpdatesConfig sets when a sent corp kill's value is checked again
+type ValueUpdatesConfig struct {
+	// DelayMinutes after sending; 0 disables updates
+	DelayMinutes int `json:"delayMinutes"`
+}
+
+// InfoChannelsConfig splits the info webhook by severity: lifecycle (startup,
+// reconnects, downtime, status reports, digests and command replies), warnings
+// and errors
+type InfoChannelsConfig struct {
+	Lifecycle InfoChannelConfig `json:"lifecycle"`
+	Warnings  InfoChannelConfig `json:"warnings"`
+	Errors    InfoChannelConfig `json:"errors"`
+}
+
+// InfoChannelConfig is where one severity goes and how often
+type InfoChannelConfig struct {
+	// DiscordWebhookId and DiscordWebhookToken default to the info webhook
+	DiscordWebhookId    string `json:"discordWebhookId"`
+	DiscordWebhookToken string `json:"discordWebhookToken"`
+	// DiscordWebhook names a discordWebhooks entry instead
+	DiscordWebhook string `json:"discordWebhook"`
+	// MaxPerHour caps the messages sent; past it they're only logged (default 0, no limit)
+	MaxPerHour int `json:"maxPerHour"`
+}
+
+func (ic InfoChannelsConfig) validate() error {
+	for name, c := range map[string]InfoChannelConfig{"lifecycle": ic.Lifecycle, "warnings": ic.Warnings, "errors": ic.Errors} {
+		if c.MaxPerHour < 0 {
+			return fmt.Errorf("infoChannels.%s.maxPerHour must not be negative, got %d", name, c.MaxPerHour)
+		}
+	}
+	return nil
+}
+
+// DowntimeConfig sets the daily downtime window
+type DowntimeConfig struct {
+	// Disabled treats downtime like any other time
+	Disabled bool `json:"disabled"`
+	// Start is the time of day, UTC, as HH:MM; defaults to 11:00
+	Start string `json:"start"`
+	// Minutes errors are expected for after Start; defaults to 30
+	Minutes int `json:"minutes"`
+}
+
+// AcknowledgmentsConfig sets how chain alerts are acknowledged
+type AcknowledgmentsConfig struct {
+	Enabled bool `json:"enabled"`
+	// Emoji is the reaction that acknowledges an alert; defaults to ✅. Custom emoji are "name:id".
+	Emoji string `json:"emoji"`
+	// BotToken reads the reactions; defaults to discordCommands.botToken. The bot needs to see the chain channel.
+	BotToken string `json:"botToken"`
+	// WindowMinutes an alert is watched before it counts as missed; defaults to 30
+	WindowMinutes int `json:"windowMinutes"`
+	// PollSeconds between reaction checks; defaults to 30
+	PollSeconds int `json:"pollSeconds"`
+}
+
+// botToken is the token reactions are read with, or "" when acknowledgments are off
+func (a AcknowledgmentsConfig) botToken(config *Config) string {
+	if !a.Enabled {
+		return ""
+	}
+	if a.BotToken != "" {
+		return a.BotToken
+	}
+	return config.DiscordCommands.BotToken
+}
+
+func (a AcknowledgmentsConfig) validate(escalations []EscalationConfig) error {
+	if len(escalations) > 0 && !a.Enabled {
+		return errors.New("escalations need acknowledgments.enabled")
+	}
+	for i, e := range escalations {
+		if e.Count <= 0 || e.Minutes <= 0 {
+			return fmt.Errorf("escalations[%d] needs a count and minutes", i)
+		}
+		if e.GraceMinutes < 0 || e.GraceMinutes >= e.Minutes {
+			return fmt.Errorf("escalations[%d].graceMinutes must be under minutes", i)
+		}
+		if _, ok := escalationPriority(e.Priority); !ok {
+			return fmt.Errorf("escalations[%d].priority: unknown priority %q, want home or chain", i, e.Priority)
+		}
+	}
+	return nil
+}
+
+// EscalationConfig is one escalation level: when Count alerts sent within the
+// last Minutes are still unacknowledged after GraceMinutes, they are escalated
+// to the level's targets
+type EscalationConfig struct {
+	Count   int `json:"count"`
+	Minutes int `json:"minutes"`
+	// GraceMinutes an alert goes unacknowledged before it counts (default 5, or half of minutes when that's shorter)
+	GraceMinutes int `json:"graceMinutes"`
+	// Priority is the lowest tier that counts: "home" for home-system alerts only,
+	// or "chain" (the default) for chain and danger alerts too, but not aggregated ones
+	Priority string `json:"priority"`
+	// Rules limits which alerts count by match rule, e.g. ["friendly_danger"]; empty counts all
+	Rules []string `json:"rules"`
+
+	DiscordWebhookId    string `json:"discordWebhookId"`
+	DiscordWebhookToken string `json:"discordWebhookToken"`
+	// DiscordWebhook names a discordWebhooks entry instead
+	DiscordWebhook string `json:"discordWebhook"`
+	// Mention starts the Discord message, e.g. "<@&123456789012345678>" to ping a role
+	Mention string `json:"mention"`
+	// Push sends an urgent ntfy and/or Pushover notification; only ntfy and pushover are used
+	Push notify.PushConfig `json:"push"`
+	// WebhookURL receives the escalation as JSON, e.g. for a phone-call service
+	WebhookURL string `json:"webhookUrl"`
+}
+
+// AggregateConfig sets the aggregation delays
+type AggregateConfig struct {
+	// DelaySeconds holds alerts per match rule, e.g. {"chain_system": 60};
+	// "*" covers every rule not listed. Unset or 0 sends right away.
+	DelaySeconds map[string]int `json:"delaySeconds"`
+}
+
+// BothSidesConfig routes kills with our pilots on both sides
+type BothSidesConfig struct {
+	// DiscordWebhookId and DiscordWebhookToken post them to a separate channel,
+	// e.g. leadership, instead of the corp-kill one
+	DiscordWebhookId    string `json:"discordWebhookId"`
+	DiscordWebhookToken string `json:"discordWebhookToken"`
+	// DiscordWebhook names a discordWebhooks entry instead
+	DiscordWebhook string `json:"discordWebhook"`
+	// Sinks limits them to these sinks; empty means every sink
+	Sinks []string `json:"sinks"`
+}
+
+// MappedCharactersConfig matches kills on map characters (and corp members)
+// that aren't in a tracked corporation
+type MappedCharactersConfig struct {
+	// Match is "both" (default), "victim", "attackers" or "none"
+	Match string `json:"match"`
+	// VictimSinks limits losses matched on a map character to these sinks; empty means every sink
+	VictimSinks []string `json:"victimSinks"`
+	// AttackerSinks does the same for kills matched on a map character
+	AttackerSinks []string `json:"attackerSinks"`
+}
+
+// sides turns Match into the matcher's victim and attacker switches
+func (mc MappedCharactersConfig) sides() (victim, attackers bool, err error) {
+	switch mc.Match {
+	case "", "both":
+		return true, true, nil
+	case "victim":
+		return true, false, nil
+	case "attackers":
+		return false, true, nil
+	case "none":
+		return false, false, nil
+	}
+	return false, false, fmt.Errorf("mappedCharacters.match: unknown value %q", mc.Match)
+}
+
+// MapToken is a map API token limited to some endpoints: "systems",
+// "characters" and "connections"; no scopes means all of them
+type MapToken struct {
+	Token  string   `json:"token"`
+	Scopes []string `json:"scopes"`
+}
+
+// MapOAuthConfig gets map API access tokens with the OAuth refresh_token grant
+type MapOAuthConfig struct {
+	TokenURL     string `json:"tokenUrl"`
+	ClientID     string `json:"clientId"`
+	ClientSecret string `json:"clientSecret"`
+	RefreshToken string `json:"refreshToken"`
+	// TokenFile keeps the latest refresh token across restarts, for servers
+	// that issue a new one on every refresh; it wins over refreshToken once written
+	TokenFile string `json:"tokenFile"`
+}
+
+// mapTokens lists oauth, or apiToken, then apiTokens
+func (c *Config) mapTokens(oauth *mapapi.OAuth) []mapapi.Token {
+	var tokens []mapapi.Token
+	switch {
+	case oauth != nil:
+		tokens = append(tokens, mapapi.Token{OAuth: oauth})
+	case c.APIToken != "" || len(c.APITokens) == 0:
+		tokens = append(tokens, mapapi.Token{Value: c.APIToken})
+	}
+	for _, t := range c.APITokens {
+		tokens = append(tokens, mapapi.Token{Value: t.Token, Scopes: t.Scopes})
+	}
+	return tokens
+}
+
+// AdminConfig enables the admin API; an empty Listen disables it
+type AdminConfig struct {
+	Listen string `json:"listen"` // e.g. 127.0.0.1:6061
+	// Token is required as "Authorization: Bearer <token>"
+	Token string `json:"token"`
+}
+
+// SchemaConfig sets where decode failure and drift samples are written
+type SchemaConfig struct {
+	// SampleDir gets the latest offending payload per source; empty keeps them in memory only
+	SampleDir string `json:"sampleDir"`
+}
+
+// AttributionConfig enables per-pilot kill records; an empty Path disables them
+type AttributionConfig struct {
+	Path string `json:"path"`
+}
+
+// TagsConfig enables kill tags; an empty Path disables them
+type TagsConfig struct {
+	Path string `json:"path"`
+	// Reactions maps an emoji to the tag it puts on a watched chain alert's kill, e.g. {"💰": "srp-approved"}
+	Reactions map[string]string `json:"reactions"`
+}
+
+// AuditConfig enables the decision log; an empty Path disables it
+type AuditConfig struct {
+	Path string `json:"path"`
+	// MaxSizeMB rotates the file to "<path>.1" at this size; defaults to 50
+	MaxSizeMB int `json:"maxSizeMB"`
+}
+
+// DiscordCommandsConfig enables admin commands read from a Discord channel; an
+// empty ChannelId disables them. Replies go to the info webhook.
+type DiscordCommandsConfig struct {
+	// BotToken only needs to read the channel; the bot must have the Message Content intent
+	BotToken  string `json:"botToken"`
+	ChannelId string `json:"channelId"`
+	// AllowedUserIds are the Discord users who may run commands; at least one is required
+	AllowedUserIds []string `json:"allowedUserIds"`
+	// PollSeconds between channel reads (default 15)
+	PollSeconds int `json:"pollSeconds"`
+	// Prefix starts every command (default "!ck")
+	Prefix string `json:"prefix"`
+	// Characters links Discord user IDs to the EVE characters, signed in
+	// through EVE SSO, that `dest` sets the autopilot of
+	Characters map[string]int64 `json:"characters"`
+}
+
+// KillstreamConfig narrows the killstream to what an instance cares about
+type KillstreamConfig struct {
+	// Channels are zKillboard websocket channels subscribed to instead of the
+	// whole killstream, e.g. "region:10000060" or "alliance:99000001". Kills
+	// outside them are never seen, chain kills included. Top-level only, as
+	// instances share one connection.
+	Channels []string `json:"channels"`
+	// RegionIds and SystemIds limit processing to kills there, plus kills in
+	// chain systems and those involving tracked entities or map characters
+	RegionIds []int `json:"regionIds"`
+	SystemIds []int `json:"systemIds"`
+}
+
+// PipelineConfig tunes kill processing
+type PipelineConfig struct {
+	// DedupSize is how many recent killmail IDs are remembered to drop repeats (default 1000)
+	DedupSize int `json:"dedupSize"`
+	// LogStages logs every stage's outcome at debug level
+	LogStages bool `json:"logStages"`
+	// LogSampleEvery limits stage logging and tracing to one in every N kills
+	LogSampleEvery int `json:"logSampleEvery"`
+	// Trace logs one line per kill at debug level with every stage's outcome
+	// and duration, the match decision and the sinks sent to
+	Trace bool `json:"trace"`
+	// DisablePrefilter decodes every kill on the stream, not only those the
+	// prefilter finds a tracked ID, chain system or location in
+	DisablePrefilter bool `json:"disablePrefilter"`
+	// MaxAttackers is the most attackers a kill keeps in full once formatted
+	// (default 100); bigger kills keep only their attacker stats
+	MaxAttackers int `json:"maxAttackers"`
+	// FullAttackerDetail keeps every kill's attackers regardless of MaxAttackers
+	FullAttackerDetail bool `json:"fullAttackerDetail"`
+	// EnrichTimeoutSeconds bounds ESI enrichment per kill; past it the alert is
+	// sent with IDs for the names not yet resolved (default 5, -1 disables)
+	EnrichTimeoutSeconds int `json:"enrichTimeoutSeconds"`
+	// EditWhenResolved finishes enriching a kill sent partial and edits the
+	// message once done, on sinks that can edit (Discord bot mode)
+	EditWhenResolved bool `json:"editWhenResolved"`
+}
+
+// LoadConfig loads JSON from file into Config
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := ParseConfig(data)
+	if err != nil {
+		return nil, err
+	}
+	cfg.path = path
+	return cfg, nil
+}
+
+// ParseConfig decodes a config document, layering each instance over the top-level fields
+func ParseConfig(data []byte) (*Config, error) {
+	cfg := &Config{}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, err
+	}
+
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	rawInstances := []json.RawMessage{}
+	if raw, ok := doc["instances"]; ok {
+		if err := json.Unmarshal(raw, &rawInstances); err != nil {
+			return nil, err
+		}
+	}
+	delete(doc, "instances")
+	base, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.Instances = make([]Config, 0, len(rawInstances))
+	for i, raw := range rawInstances {
+		var inst Config
+		if err = json.Unmarshal(base, &inst); err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal(raw, &inst); err != nil {
+			return nil, fmt.Errorf("instance %d: %w", i, err)
+		}
+		if len(inst.Instances) > 0 {
+			return nil, fmt.Errorf("instance %d: instances cannot be nested", i)
+		}
+		if inst.Name == "" {
+			inst.Name = fmt.Sprintf("instance-%d", i+1)
+		}
+		cfg.Instances = append(cfg.Instances, inst)
+	}
+	return cfg, nil
+}
+
+// InstanceConfigs returns the configured instances, or the top-level config
+// itself when no instances are defined
+func (c *Config) InstanceConfigs() []*Config {
+	if len(c.Instances) == 0 {
+		return []*Config{c}
+	}
+	out := make([]*Config, len(c.Instances))
+	for i := range c.Instances {
+		out[i] = &c.Instances[i]
+	}
+	return out
+}
+
+// saveTrackingLists rewrites the tracked and ignored IDs in the config file at
+// path, in the instance at index or at the top level when index is -1. The file
+// is replaced atomically; other fields are kept but keys are re-sorted.
+func saveTrackingLists(path string, index int, tracked, ignored []int) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+
+	target := doc
+	var instances []map[string]json.RawMessage
+	if index >= 0 {
+		if err := json.Unmarshal(doc["instances"], &instances); err != nil {
+			return fmt.Errorf("instances: %w", err)
+		}
+		if index >= len(instances) {
+			return fmt.Errorf("instance %d not found in %s", index+1, path)
+		}
+		target = instances[index]
+	}
+	if target["insightTrackedIds"], err = json.Marshal(nonNil(tracked)); err != nil {
+		return err
+	}
+	if target["ignoreSystemIds"], err = json.Marshal(nonNil(ignored)); err != nil {
+		return err
+	}
+	if index >= 0 {
+		if doc["instances"], err = json.Marshal(instances); err != nil {
+			return err
+		}
+	}
+
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(out, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// nonNil keeps an empty list as [] rather than null in the saved config
+func nonNil(ids []int) []int {
+	if ids == nil {
+		return []int{}
+	}
+	return ids
+}
+
+// validateFormats checks every sink's formatting profile
+func (c *Config) validateFormats() error {
+	formats := map[string]notify.Format{
+		"discordKillNotifications.format":      c.DiscordKillNotifications.Format,
+		"discordKillNotifications.chainFormat": c.DiscordKillNotifications.ChainFormat,
+		"telegram.format":                      c.Telegram.Format,
+		"mattermost.format":                    c.Mattermost.Format,
+		"rocketChat.format":                    c.RocketChat.Format,
+	}
+	if c.XMPP.Format == notify.FormatRich {
+		return fmt.Errorf("xmpp.format: xmpp has no rich format, use compact, plain or json")
+	}
+	formats["xmpp.format"] = c.XMPP.Format
+	for i, wc := range c.Webhooks {
+		if wc.Format == notify.FormatRich {
+			return fmt.Errorf("webhooks[%d].format: webhooks have no rich format, use json, compact or plain", i)
+		}
+		formats[fmt.Sprintf("webhooks[%d].format", i)] = wc.Format
+	}
+	for name, f := range formats {
+		if err := f.Validate(); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// DigestConfig sets when the daily digest goes to the info webhook
+type DigestConfig struct {
+	// Hour, UTC, 0-23
+	Hour int `json:"hour"`
+}
+
+func (d DigestConfig) validate() error {
+	if d.Hour < 0 || d.Hour > 23 {
+		return fmt.Errorf("digest.hour must be 0-23, got %d", d.Hour)
+	}
+	return nil
+}
+
+// GalaxyActivityConfig turns on counting every kill on the killstream, matched
+// or not, per system and hour
+type GalaxyActivityConfig struct {
+	Enabled bool `json:"enabled"`
+}
+
+// FederationConfig names this instance to its peers and lists the peers it trusts
+type FederationConfig struct {
+	// Name is how peers know this instance (default the instance name)
+	Name  string                 `json:"name"`
+	Peers []FederationPeerConfig `json:"peers"`
+}
+
+// FederationPeerConfig is one allied instance
+type FederationPeerConfig struct {
+	// Name must match the peer's federation.name
+	Name string `json:"name"`
+	// Secret signs what we send the peer and checks what it sends us
+	Secret string `json:"secret"`
+	// URL is the peer's /federation/kills endpoint; empty only receives from it
+	URL string `json:"url"`
+	// Accept lists the alert kinds taken from the peer, e.g. ["chain"]; empty takes none
+	Accept []notify.Kind `json:"accept"`
+	// MinValue skips the peer's alerts for kills worth less, in ISK
+	MinValue float64 `json:"minValue"`
+}
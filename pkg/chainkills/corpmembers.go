@@ -0,0 +1,57 @@
+package chainkills
+
+import (
+	"context"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/pkg/killmail"
+)
+
+// corpMemberLister is implemented by ESI clients signed in with a director
+type corpMemberLister interface {
+	CorporationMembers(ctx context.Context) (int, []int64, error)
+}
+
+// pollCorpMembers refreshes the corporation roster until ctx is done
+func (ck *Checker) pollCorpMembers(ctx context.Context) {
+	lister, ok := ck.esi.(corpMemberLister)
+	if !ok {
+		ck.logger.Warnf("[CorpMembers] The ESI client can't list corporation members")
+		return
+	}
+	interval := time.Hour
+	if rm := ck.config.CorpMembers.RefreshMinutes; rm > 0 {
+		interval = time.Duration(rm) * time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		ck.updateCorpMembers(ctx, lister)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// updateCorpMembers replaces the roster; on error the last one is kept
+func (ck *Checker) updateCorpMembers(ctx context.Context, lister corpMemberLister) {
+	corpID, ids, err := lister.CorporationMembers(ctx)
+	if err != nil {
+		ck.logger.Errorf("[CorpMembers] Error fetching corporation members: %v", err)
+		return
+	}
+	members := make([]killmail.MapCharacter, 0, len(ids))
+	for _, id := range ids {
+		members = append(members, killmail.MapCharacter{CharacterId: id, CorporationId: corpID})
+	}
+	ck.updateState(func(s *mapState) { s.corpMembers = members })
+	ck.logger.Printf("[CorpMembers] Fetched %d members of corporation %d", len(members), corpID)
+}
+
+// characterCounts returns the number of map characters and corp members
+func (ck *Checker) characterCounts() (mapped, members int) {
+	st := ck.state()
+	return len(st.mapCharacters), len(st.corpMembers)
+}
@@ -0,0 +1,46 @@
+package chainkills
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// digestEnabled reports whether anything goes into the daily digest
+func (ck *Checker) digestEnabled() bool {
+	return ck.config.Acknowledgments.Enabled || ck.activity != nil || ck.tags != nil
+}
+
+// runDigest sends the daily digest to the info webhook at digest.hour UTC
+func (ck *Checker) runDigest(ctx context.Context) {
+	for {
+		now := time.Now().UTC()
+		next := time.Date(now.Year(), now.Month(), now.Day(), ck.config.Digest.Hour, 0, 0, 0, time.UTC)
+		if !next.After(now) {
+			next = next.AddDate(0, 0, 1)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(next)):
+		}
+		if text := ck.digest(ctx); text != "" {
+			ck.sendInfoMessage(ctx, text)
+		}
+	}
+}
+
+// digest puts together the sections of the daily digest
+func (ck *Checker) digest(ctx context.Context) string {
+	var sections []string
+	if ck.config.Acknowledgments.Enabled {
+		sections = append(sections, ck.acks.digest())
+	}
+	if line := ck.galaxyLine(ctx); line != "" {
+		sections = append(sections, line)
+	}
+	if ck.tags != nil {
+		sections = append(sections, ck.tagDigest())
+	}
+	return strings.Join(sections, "\n\n")
+}
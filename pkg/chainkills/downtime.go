@@ -0,0 +1,111 @@
+package chainkills
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// defaultDowntimeStart is when EVE's daily downtime begins, UTC
+const defaultDowntimeStart = "11:00"
+
+// schedule returns the window's start as an offset from midnight UTC and its length
+func (d DowntimeConfig) schedule() (time.Duration, time.Duration, error) {
+	start := d.Start
+	if start == "" {
+		start = defaultDowntimeStart
+	}
+	t, err := time.Parse("15:04", start)
+	if err != nil {
+		return 0, 0, fmt.Errorf("downtime.start %q is not HH:MM: %w", d.Start, err)
+	}
+	length := 30 * time.Minute
+	if d.Minutes > 0 {
+		length = time.Duration(d.Minutes) * time.Minute
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, length, nil
+}
+
+func (d DowntimeConfig) validate() error {
+	_, _, err := d.schedule()
+	return err
+}
+
+// window returns the downtime window around t: the one t falls in, or else the next one
+func (d DowntimeConfig) window(t time.Time) (time.Time, time.Time) {
+	offset, length, _ := d.schedule()
+	t = t.UTC()
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	// yesterday's window may run past midnight
+	for _, day := range []int{-1, 0, 1} {
+		start := midnight.AddDate(0, 0, day).Add(offset)
+		if end := start.Add(length); t.Before(end) {
+			return start, end
+		}
+	}
+	return time.Time{}, time.Time{}
+}
+
+// active reports whether t falls within downtime
+func (d DowntimeConfig) active(t time.Time) bool {
+	if d.Disabled {
+		return false
+	}
+	start, _ := d.window(t)
+	return !t.Before(start)
+}
+
+// inDowntime reports whether EVE's daily downtime is on, when ESI and
+// zKillboard outages are expected
+func (ck *Checker) inDowntime() bool {
+	return ck.config.Downtime.active(time.Now())
+}
+
+func (h *Hub) inDowntime() bool {
+	return h.downtime.active(time.Now())
+}
+
+// sendErrorMessage posts an error to the errors info channel, except during
+// downtime, when errors are expected and only logged
+func (ck *Checker) sendErrorMessage(ctx context.Context, messageBody string) {
+	if ck.inDowntime() {
+		ck.logger.Printf("Not sending error message during downtime: %s", messageBody)
+		return
+	}
+	ck.sendInfo(ctx, SeverityError, messageBody)
+}
+
+// reportError sends an error to error reporting, except during downtime
+func (ck *Checker) reportError(ctx context.Context, err error, tags map[string]string) {
+	if ck.inDowntime() {
+		return
+	}
+	ck.reporter.Error(ctx, err, tags)
+}
+
+// runDowntime posts one note to each instance's info webhook as every downtime starts
+func (h *Hub) runDowntime(ctx context.Context) {
+	dt := h.downtime
+	for {
+		start, end := dt.window(time.Now())
+		if wait := time.Until(start); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+		h.logger.Printf("[Hub] EVE downtime until %s UTC; holding back error messages", end.Format("15:04"))
+		for _, ck := range h.checkers {
+			ck.sendInfoMessage(ctx, fmt.Sprintf("EVE downtime, resuming shortly (errors are expected until %s UTC).", end.Format("15:04")))
+		}
+		// wait out the window so the note goes once
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(end)):
+		}
+	}
+}
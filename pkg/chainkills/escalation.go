@@ -0,0 +1,142 @@
+package chainkills
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/pkg/notify"
+	"github.com/guarzo/eve-chainkills/pkg/pipeline"
+	"golang.org/x/exp/slices"
+)
+
+// escalatedAlert is an unacknowledged alert as sent to escalation webhooks
+type escalatedAlert struct {
+	KillID int64     `json:"kill_id"`
+	Rule   string    `json:"rule,omitempty"`
+	Where  string    `json:"where"`
+	URL    string    `json:"url"`
+	SentAt time.Time `json:"sent_at"`
+}
+
+// escalationPriority parses an escalation level's priority; "" is chain
+func escalationPriority(name string) (pipeline.Priority, bool) {
+	switch name {
+	case "home":
+		return pipeline.PriorityHome, true
+	case "", "chain":
+		return pipeline.PriorityChain, true
+	}
+	return 0, false
+}
+
+// grace is how long an alert goes unacknowledged before the level counts it
+func (level EscalationConfig) grace() time.Duration {
+	if level.GraceMinutes > 0 {
+		return time.Duration(level.GraceMinutes) * time.Minute
+	}
+	return min(5*time.Minute, time.Duration(level.Minutes)*time.Minute/2)
+}
+
+// escalate checks the unacknowledged alerts against every escalation level.
+// An alert is escalated to each level at most once, and counts once however
+// many sinks it was sent to.
+func (ck *Checker) escalate(ctx context.Context) {
+	type firing struct {
+		level  EscalationConfig
+		alerts []escalatedAlert
+	}
+	var fire []firing
+	now := time.Now()
+
+	ck.acks.mu.Lock()
+	for i, level := range ck.config.Escalations {
+		maxPriority, _ := escalationPriority(level.Priority)
+		var hits []*pendingAck
+		for _, p := range ck.acks.pending {
+			age := now.Sub(p.sentAt)
+			if p.escalated[i] || age < level.grace() || age > time.Duration(level.Minutes)*time.Minute || p.priority > maxPriority {
+				continue
+			}
+			if len(level.Rules) == 0 || slices.Contains(level.Rules, p.rule) {
+				hits = append(hits, p)
+			}
+		}
+		kills := map[int64]bool{}
+		for _, p := range hits {
+			kills[p.killID] = true
+		}
+		if len(kills) < level.Count {
+			continue
+		}
+		f := firing{level: level}
+		for _, p := range hits {
+			p.escalated[i] = true
+			if kills[p.killID] {
+				delete(kills, p.killID)
+				f.alerts = append(f.alerts, escalatedAlert{KillID: p.killID, Rule: p.rule, Where: p.where, URL: p.url, SentAt: p.sentAt})
+			}
+		}
+		fire = append(fire, f)
+	}
+	ck.acks.mu.Unlock()
+
+	for _, f := range fire {
+		ck.sendEscalation(ctx, f.level, f.alerts)
+	}
+}
+
+// sendEscalation sends one escalation to each of the level's targets
+func (ck *Checker) sendEscalation(ctx context.Context, level EscalationConfig, alerts []escalatedAlert) {
+	title := fmt.Sprintf("%d alerts unacknowledged for up to %d minutes", len(alerts), level.Minutes)
+	lines := make([]string, len(alerts))
+	for i, a := range alerts {
+		lines[i] = fmt.Sprintf("• Kill %s, %s ago: %s", a.Where, time.Since(a.SentAt).Round(time.Minute), a.URL)
+	}
+	ck.logger.Warnf("[Escalation] %s", title)
+
+	if level.DiscordWebhookId != "" {
+		text := strings.TrimSpace(level.Mention + " ESCALATION: " + title + "\n" + strings.Join(lines, "\n"))
+		if err := ck.webhooks.SendWebhook(ctx, ck.logger, level.DiscordWebhookId, level.DiscordWebhookToken, text, nil); err != nil {
+			ck.logger.Errorf("[Escalation] Error sending to Discord: %v", err)
+		}
+	}
+	if level.Push.Ntfy.Topic != "" || (level.Push.Pushover.AppToken != "" && level.Push.Pushover.UserKey != "") {
+		push := notify.NewPushSink(ck.logger, level.Push, nil)
+		if err := push.Push(ctx, "Escalation: "+title, strings.Join(lines, "\n"), alerts[0].URL, true); err != nil {
+			ck.logger.Errorf("[Escalation] Error sending push: %v", err)
+		}
+	}
+	if level.WebhookURL != "" {
+		body := map[string]interface{}{"text": title, "instance": ck.config.Name, "alerts": alerts}
+		if err := ck.postEscalation(ctx, level.WebhookURL, body); err != nil {
+			ck.logger.Errorf("[Escalation] Error sending to webhook: %v", err)
+		}
+	}
+}
+
+func (ck *Checker) postEscalation(ctx context.Context, url string, body interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := ck.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("escalation webhook got status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+package chainkills
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+	"github.com/guarzo/eve-chainkills/pkg/notify"
+	"github.com/guarzo/eve-chainkills/pkg/pipeline"
+)
+
+// escalationHook records the escalations posted to a level's webhookUrl
+type escalationHook struct {
+	mu    sync.Mutex
+	posts [][]escalatedAlert
+}
+
+func (eh *escalationHook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Alerts []escalatedAlert `json:"alerts"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	eh.mu.Lock()
+	eh.posts = append(eh.posts, body.Alerts)
+	eh.mu.Unlock()
+}
+
+func (eh *escalationHook) take() [][]escalatedAlert {
+	eh.mu.Lock()
+	defer eh.mu.Unlock()
+	posts := eh.posts
+	eh.posts = nil
+	return posts
+}
+
+func newEscalationChecker(t *testing.T, levels ...EscalationConfig) (*Checker, *escalationHook) {
+	t.Helper()
+	hook := &escalationHook{}
+	srv := httptest.NewServer(hook)
+	t.Cleanup(srv.Close)
+	for i := range levels {
+		levels[i].WebhookURL = srv.URL
+	}
+	config := &Config{Acknowledgments: AcknowledgmentsConfig{Enabled: true}, Escalations: levels}
+	if err := config.Acknowledgments.validate(config.Escalations); err != nil {
+		t.Fatal(err)
+	}
+	ck, err := newChecker(&options{
+		logger: logger.Slog(slog.New(slog.NewTextHandler(io.Discard, nil))),
+		esi:    echoESI{},
+		http:   srv.Client(),
+	}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ck, hook
+}
+
+// sent adds a watched alert sent age ago
+func sent(ck *Checker, sink string, killID int64, age time.Duration, priority pipeline.Priority) {
+	ck.acks.pending = append(ck.acks.pending, &pendingAck{
+		name:      sink,
+		ref:       sink + "-ref",
+		killID:    killID,
+		rule:      notify.RuleChainSystem,
+		sentAt:    time.Now().Add(-age),
+		priority:  priority,
+		escalated: map[int]bool{},
+	})
+}
+
+func TestEscalateWaitsForGrace(t *testing.T) {
+	ck, hook := newEscalationChecker(t, EscalationConfig{Count: 1, Minutes: 15})
+	ctx := context.Background()
+
+	// nobody has had time to react to a fresh alert yet
+	sent(ck, "discord", 1, 30*time.Second, pipeline.PriorityChain)
+	ck.escalate(ctx)
+	if posts := hook.take(); len(posts) != 0 {
+		t.Fatalf("escalated a 30s old alert: %v", posts)
+	}
+
+	ck.acks.pending[0].sentAt = time.Now().Add(-6 * time.Minute)
+	ck.escalate(ctx)
+	posts := hook.take()
+	if len(posts) != 1 || len(posts[0]) != 1 || posts[0][0].KillID != 1 {
+		t.Fatalf("escalations %v, want kill 1 once past the grace", posts)
+	}
+
+	// each alert is escalated to a level once
+	ck.escalate(ctx)
+	if posts := hook.take(); len(posts) != 0 {
+		t.Errorf("escalated again: %v", posts)
+	}
+
+	// nor are alerts older than the level's minutes
+	sent(ck, "discord", 2, 20*time.Minute, pipeline.PriorityChain)
+	ck.escalate(ctx)
+	if posts := hook.take(); len(posts) != 0 {
+		t.Errorf("escalated an alert older than minutes: %v", posts)
+	}
+}
+
+func TestEscalateGrace(t *testing.T) {
+	for _, tc := range []struct {
+		level EscalationConfig
+		want  time.Duration
+	}{
+		{EscalationConfig{Minutes: 15}, 5 * time.Minute},
+		{EscalationConfig{Minutes: 4}, 2 * time.Minute},
+		{EscalationConfig{Minutes: 15, GraceMinutes: 10}, 10 * time.Minute},
+	} {
+		if got := tc.level.grace(); got != tc.want {
+			t.Errorf("grace of %+v = %s, want %s", tc.level, got, tc.want)
+		}
+	}
+}
+
+func TestEscalateCountsKillsOnce(t *testing.T) {
+	ck, hook := newEscalationChecker(t, EscalationConfig{Count: 2, Minutes: 15, GraceMinutes: 1})
+	ctx := context.Background()
+
+	// one kill watched on two sinks is one unacknowledged alert
+	sent(ck, "discord", 1, 2*time.Minute, pipeline.PriorityChain)
+	sent(ck, "slack", 1, 2*time.Minute, pipeline.PriorityChain)
+	ck.escalate(ctx)
+	if posts := hook.take(); len(posts) != 0 {
+		t.Fatalf("escalated one kill as two alerts: %v", posts)
+	}
+
+	sent(ck, "discord", 2, 2*time.Minute, pipeline.PriorityChain)
+	ck.escalate(ctx)
+	posts := hook.take()
+	if len(posts) != 1 || len(posts[0]) != 2 || posts[0][0].KillID == posts[0][1].KillID {
+		t.Fatalf("escalations %v, want kills 1 and 2 once each", posts)
+	}
+	for _, p := range ck.acks.pending {
+		if !p.escalated[0] {
+			t.Errorf("kill %d on %s not marked escalated", p.killID, p.name)
+		}
+	}
+}
+
+func TestEscalatePriority(t *testing.T) {
+	ck, hook := newEscalationChecker(t,
+		EscalationConfig{Count: 1, Minutes: 15, GraceMinutes: 1},
+		EscalationConfig{Count: 1, Minutes: 15, GraceMinutes: 1, Priority: "home"},
+	)
+	ctx := context.Background()
+
+	sent(ck, "discord", 1, 2*time.Minute, pipeline.PriorityDigest)
+	ck.escalate(ctx)
+	if posts := hook.take(); len(posts) != 0 {
+		t.Fatalf("escalated an aggregated alert: %v", posts)
+	}
+
+	sent(ck, "discord", 2, 2*time.Minute, pipeline.PriorityChain)
+	ck.escalate(ctx)
+	if posts := hook.take(); len(posts) != 1 || posts[0][0].KillID != 2 {
+		t.Fatalf("escalations %v, want kill 2 on the chain level only", posts)
+	}
+
+	sent(ck, "discord", 3, 2*time.Minute, pipeline.PriorityHome)
+	ck.escalate(ctx)
+	if posts := hook.take(); len(posts) != 2 || posts[0][0].KillID != 3 || posts[1][0].KillID != 3 {
+		t.Fatalf("escalations %v, want kill 3 on both levels", posts)
+	}
+}
+
+func TestEscalationValidate(t *testing.T) {
+	ack := AcknowledgmentsConfig{Enabled: true}
+	for _, tc := range []struct {
+		level EscalationConfig
+		err   string
+	}{
+		{level: EscalationConfig{Count: 1, Minutes: 10, GraceMinutes: 3, Priority: "home"}},
+		{level: EscalationConfig{Count: 1, Minutes: 10, GraceMinutes: 10}, err: "graceMinutes must be under minutes"},
+		{level: EscalationConfig{Count: 1, Minutes: 10, GraceMinutes: -1}, err: "graceMinutes must be under minutes"},
+		{level: EscalationConfig{Count: 1, Minutes: 10, Priority: "corp"}, err: `unknown priority "corp"`},
+		{level: EscalationConfig{Minutes: 10}, err: "needs a count and minutes"},
+	} {
+		err := ack.validate([]EscalationConfig{tc.level})
+		switch {
+		case tc.err == "" && err != nil:
+			t.Errorf("validate(%+v) = %v, want nil", tc.level, err)
+		case tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)):
+			t.Errorf("validate(%+v) = %v, want an error containing %q", tc.level, err, tc.err)
+		}
+	}
+}
+
+// reactionSink reports acks for the refs in acked
+type reactionSink struct {
+	acked map[string]string
+}
+
+func (rs *reactionSink) SendAckable(ctx context.Context, n notify.Notification) (string, error) {
+	return "", nil
+}
+
+func (rs *reactionSink) Acks(ctx context.Context, ref, emoji string) ([]string, error) {
+	if name, ok := rs.acked[ref]; ok {
+		return []string{name}, nil
+	}
+	return nil, nil
+}
+
+func TestAckOnAnySinkStopsEscalation(t *testing.T) {
+	ck, hook := newEscalationChecker(t, EscalationConfig{Count: 1, Minutes: 15, GraceMinutes: 1})
+	rs := &reactionSink{acked: map[string]string{"slack-ref": "Alice"}}
+	sent(ck, "discord", 1, 2*time.Minute, pipeline.PriorityChain)
+	sent(ck, "slack", 1, 2*time.Minute, pipeline.PriorityChain)
+	for _, p := range ck.acks.pending {
+		p.sink = rs
+	}
+
+	ck.checkAcks(context.Background())
+	if len(ck.acks.pending) != 0 {
+		t.Errorf("still watching %d copies of an acknowledged kill", len(ck.acks.pending))
+	}
+	ck.escalate(context.Background())
+	if posts := hook.take(); len(posts) != 0 {
+		t.Errorf("escalated an acknowledged kill: %v", posts)
+	}
+}
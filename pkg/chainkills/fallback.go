@@ -0,0 +1,137 @@
+package chainkills
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/pkg/notify"
+	"github.com/guarzo/eve-chainkills/pkg/pipeline"
+	"golang.org/x/exp/slices"
+)
+
+// fallbackChain is a configured chain with its defaults applied
+type fallbackChain struct {
+	kinds   []notify.Kind
+	sinks   []string
+	retries int
+	delay   time.Duration
+}
+
+func (fc FallbackConfig) validate(i int) error {
+	if len(fc.Sinks) < 2 {
+		return fmt.Errorf("fallbacks[%d] needs at least two sinks", i)
+	}
+	for _, k := range fc.Kinds {
+		switch k {
+		case notify.KindChain, notify.KindCorpKill, notify.KindLocation, notify.KindDanger:
+		default:
+			return fmt.Errorf("fallbacks[%d]: unknown kind %q, want chain, corpKill, location or danger", i, k)
+		}
+	}
+	if fc.Retries < 0 || fc.RetryDelaySeconds < 0 {
+		return fmt.Errorf("fallbacks[%d]: retries and retryDelaySeconds can't be negative", i)
+	}
+	return nil
+}
+
+func validateFallbacks(fallbacks []FallbackConfig) error {
+	claimed := map[notify.Kind]map[string]int{}
+	for i, fc := range fallbacks {
+		if err := fc.validate(i); err != nil {
+			return err
+		}
+		kinds := fc.Kinds
+		if len(kinds) == 0 {
+			kinds = []notify.Kind{notify.KindChain, notify.KindCorpKill, notify.KindLocation, notify.KindDanger}
+		}
+		for _, k := range kinds {
+			if claimed[k] == nil {
+				claimed[k] = map[string]int{}
+			}
+			for _, s := range fc.Sinks {
+				if j, ok := claimed[k][s]; ok {
+					return fmt.Errorf("fallbacks[%d]: sink %s is already in fallbacks[%d] for %s alerts", i, s, j, k)
+				}
+				claimed[k][s] = i
+			}
+		}
+	}
+	return nil
+}
+
+func newFallbackChains(fallbacks []FallbackConfig) []fallbackChain {
+	chains := make([]fallbackChain, len(fallbacks))
+	for i, fc := range fallbacks {
+		chains[i] = fallbackChain{kinds: fc.Kinds, sinks: fc.Sinks, retries: 2, delay: 2 * time.Second}
+		if fc.Retries > 0 {
+			chains[i].retries = fc.Retries
+		}
+		if fc.RetryDelaySeconds > 0 {
+			chains[i].delay = time.Duration(fc.RetryDelaySeconds) * time.Second
+		}
+	}
+	return chains
+}
+
+// fallbackChain returns the index of the chain a sink is in for alerts of kind, or -1
+func (ck *Checker) fallbackChain(kind notify.Kind, sink string) int {
+	for i, fc := range ck.fallbacks {
+		if (len(fc.kinds) == 0 || slices.Contains(fc.kinds, kind)) && slices.Contains(fc.sinks, sink) {
+			return i
+		}
+	}
+	return -1
+}
+
+// deliverAll sends n to every sink the event wants. Sinks in a fallback
+// chain for n's kind get it through the chain instead.
+func (ck *Checker) deliverAll(ctx context.Context, ev *pipeline.Event, n notify.Notification) {
+	for _, s := range ck.sinks {
+		if ev.WantsSink(s.Name()) && ck.fallbackChain(n.Kind, s.Name()) < 0 {
+			ck.deliver(ctx, ev, s, n)
+		}
+	}
+	ck.deliverChains(ctx, ev, n)
+}
+
+// deliverChains sends n through each fallback chain for its kind that has a sink the event wants
+func (ck *Checker) deliverChains(ctx context.Context, ev *pipeline.Event, n notify.Notification) {
+	started := map[int]bool{}
+	for _, s := range ck.sinks {
+		if !ev.WantsSink(s.Name()) {
+			continue
+		}
+		if i := ck.fallbackChain(n.Kind, s.Name()); i >= 0 && !started[i] {
+			started[i] = true
+			ck.deliverChain(ctx, ev, ck.fallbacks[i], n)
+		}
+	}
+}
+
+// deliverChain tries each sink of the chain in order, retrying each, until one takes n
+func (ck *Checker) deliverChain(ctx context.Context, ev *pipeline.Event, fc fallbackChain, n notify.Notification) {
+	for i, name := range fc.sinks {
+		j := slices.IndexFunc(ck.sinks, func(s notify.Sink) bool { return s.Name() == name })
+		if j < 0 || !ev.WantsSink(name) {
+			continue
+		}
+		for attempt := 0; attempt <= fc.retries; attempt++ {
+			if attempt > 0 {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(fc.delay):
+				}
+			}
+			if ck.deliver(ctx, ev, ck.sinks[j], n) == nil {
+				if i > 0 {
+					ck.killLogger(ctx).Printf("[Fallback] Kill %d fell back to %s", n.KillMailID, name)
+					ev.Note("fallback", name)
+				}
+				return
+			}
+		}
+	}
+	ck.killLogger(ctx).Errorf("[Fallback] Kill %d: every sink in %v failed", n.KillMailID, fc.sinks)
+}
@@ -0,0 +1,178 @@
+package chainkills
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+	"github.com/guarzo/eve-chainkills/pkg/notify"
+	"github.com/guarzo/eve-chainkills/pkg/pipeline"
+)
+
+// scriptSink fails its first fails sends and records the kills it took
+type scriptSink struct {
+	name  string
+	fails int
+
+	mu      sync.Mutex
+	calls   int
+	sent    []int64
+	batches [][]int64
+}
+
+func (s *scriptSink) Name() string { return s.name }
+
+func (s *scriptSink) Send(ctx context.Context, n notify.Notification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if s.calls <= s.fails {
+		return errors.New(s.name + " is down")
+	}
+	s.sent = append(s.sent, n.KillMailID)
+	return nil
+}
+
+// batchSink is a scriptSink that takes aggregated alerts as one message
+type batchSink struct{ scriptSink }
+
+func (s *batchSink) SendBatch(ctx context.Context, ns []notify.Notification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := make([]int64, len(ns))
+	for i, n := range ns {
+		ids[i] = n.KillMailID
+	}
+	s.batches = append(s.batches, ids)
+	return nil
+}
+
+func newFallbackChecker(t *testing.T, config *Config, sinks ...notify.Sink) *Checker {
+	t.Helper()
+	ck, err := newChecker(&options{
+		logger: logger.Slog(slog.New(slog.NewTextHandler(io.Discard, nil))),
+		esi:    echoESI{},
+		sinks:  sinks,
+	}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range ck.fallbacks {
+		ck.fallbacks[i].delay = time.Millisecond
+	}
+	return ck
+}
+
+func chainEvent(killID int64) *pipeline.Event {
+	ev := &pipeline.Event{Kind: notify.KindChain, Reason: notify.MatchReason{Rule: notify.RuleChainSystem}}
+	ev.Zkill.KillmailID = killID
+	ev.Zkill.SolarSystemID = 31000001
+	ev.Notification = notify.Notification{Kind: notify.KindChain, KillMailID: killID}
+	return ev
+}
+
+func TestFallbackPrimarySucceeds(t *testing.T) {
+	primary, backup, other := &scriptSink{name: "primary"}, &scriptSink{name: "backup"}, &scriptSink{name: "other"}
+	ck := newFallbackChecker(t, &Config{Fallbacks: []FallbackConfig{{Sinks: []string{"primary", "backup"}}}}, primary, backup, other)
+
+	ev := chainEvent(1)
+	if _, err := ck.deliverStage(context.Background(), ev); err != nil {
+		t.Fatal(err)
+	}
+	if len(primary.sent) != 1 || backup.calls != 0 {
+		t.Errorf("primary sent %v and backup was called %d times, want only the primary", primary.sent, backup.calls)
+	}
+	if len(other.sent) != 1 {
+		t.Errorf("sink outside the chain sent %v, want the alert", other.sent)
+	}
+}
+
+func TestFallbackRetriesThenFallsBack(t *testing.T) {
+	// the primary fails its first try and both retries
+	primary, backup := &scriptSink{name: "primary", fails: 3}, &scriptSink{name: "backup"}
+	ck := newFallbackChecker(t, &Config{Fallbacks: []FallbackConfig{{Sinks: []string{"primary", "backup"}}}}, primary, backup)
+
+	ev := chainEvent(1)
+	if _, err := ck.deliverStage(context.Background(), ev); err != nil {
+		t.Fatal(err)
+	}
+	if primary.calls != 3 || len(primary.sent) != 0 {
+		t.Errorf("primary called %d times, want a try and 2 retries", primary.calls)
+	}
+	if len(backup.sent) != 1 {
+		t.Errorf("backup sent %v, want the alert", backup.sent)
+	}
+	var chain []pipeline.Delivery
+	for _, d := range ev.Deliveries {
+		if d.Sink == "primary" || d.Sink == "backup" {
+			chain = append(chain, d)
+		}
+	}
+	if len(chain) != 4 || chain[3].Sink != "backup" || chain[3].Err != nil {
+		t.Errorf("deliveries %+v, want three failures then the backup", chain)
+	}
+}
+
+func TestFallbackRetrySucceeds(t *testing.T) {
+	primary, backup := &scriptSink{name: "primary", fails: 1}, &scriptSink{name: "backup"}
+	ck := newFallbackChecker(t, &Config{Fallbacks: []FallbackConfig{{Sinks: []string{"primary", "backup"}, Retries: 1}}}, primary, backup)
+
+	if _, err := ck.deliverStage(context.Background(), chainEvent(1)); err != nil {
+		t.Fatal(err)
+	}
+	if primary.calls != 2 || len(primary.sent) != 1 || backup.calls != 0 {
+		t.Errorf("primary called %d times and backup %d, want the retry to go through", primary.calls, backup.calls)
+	}
+}
+
+func TestFallbackKinds(t *testing.T) {
+	primary, backup := &scriptSink{name: "primary", fails: 100}, &scriptSink{name: "backup"}
+	ck := newFallbackChecker(t, &Config{Fallbacks: []FallbackConfig{{Kinds: []notify.Kind{notify.KindCorpKill}, Sinks: []string{"primary", "backup"}}}}, primary, backup)
+
+	// chain alerts aren't covered by the chain, so every sink gets them once
+	if _, err := ck.deliverStage(context.Background(), chainEvent(1)); err != nil {
+		t.Fatal(err)
+	}
+	if primary.calls != 1 || len(backup.sent) != 1 {
+		t.Errorf("primary called %d times and backup sent %v, want one send each", primary.calls, backup.sent)
+	}
+}
+
+func TestAggregateUsesFallbacks(t *testing.T) {
+	primary := &batchSink{scriptSink{name: "primary", fails: 100}}
+	backup := &scriptSink{name: "backup"}
+	other := &batchSink{scriptSink{name: "other"}}
+	ck := newFallbackChecker(t, &Config{
+		Fallbacks: []FallbackConfig{{Sinks: []string{"primary", "backup"}}},
+		Aggregate: AggregateConfig{DelaySeconds: map[string]int{"*": 3600}},
+	}, primary, backup, other)
+
+	ctx := context.Background()
+	for _, id := range []int64{1, 2} {
+		if _, err := ck.deliverStage(ctx, chainEvent(id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if primary.calls+backup.calls+other.calls+len(other.batches) != 0 {
+		t.Fatalf("alerts went out before the aggregation delay")
+	}
+	ck.flushAggregates(ctx)
+
+	if len(primary.batches) != 0 {
+		t.Errorf("primary in a fallback chain got batches %v", primary.batches)
+	}
+	if primary.calls != 6 {
+		t.Errorf("primary called %d times, want 3 tries per alert", primary.calls)
+	}
+	if len(backup.sent) != 2 {
+		t.Errorf("backup sent %v, want both held alerts", backup.sent)
+	}
+	if len(other.batches) != 1 || len(other.batches[0]) != 2 {
+		t.Errorf("sink outside the chain got batches %v, want one of both alerts", other.batches)
+	}
+}
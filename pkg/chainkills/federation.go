@@ -0,0 +1,135 @@
+package chainkills
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/pkg/notify"
+	"github.com/guarzo/eve-chainkills/pkg/pipeline"
+	"golang.org/x/exp/slices"
+)
+
+// federationMaxAge is how old an envelope may be before it's refused as a replay
+const federationMaxAge = 10 * time.Minute
+
+// Errors from ReceiveFederated
+var (
+	ErrFederationUntrusted = errors.New("chainkills: unknown federation source or bad signature")
+	ErrFederationStale     = errors.New("chainkills: federation envelope too old")
+)
+
+func (fc FederationConfig) validate() error {
+	seen := map[string]bool{}
+	for i, p := range fc.Peers {
+		if p.Name == "" || p.Secret == "" {
+			return fmt.Errorf("federation.peers[%d] needs a name and secret", i)
+		}
+		if seen[p.Name] {
+			return fmt.Errorf("federation.peers[%d]: duplicate name %q", i, p.Name)
+		}
+		seen[p.Name] = true
+	}
+	return nil
+}
+
+// source is how peers know this instance
+func (fc FederationConfig) source(c *Config) string {
+	switch {
+	case fc.Name != "":
+		return fc.Name
+	case c.Name != "":
+		return c.Name
+	}
+	return "chainkills"
+}
+
+// sendPeers lists the peers our alerts go to
+func (fc FederationConfig) sendPeers() []notify.FederationPeer {
+	var peers []notify.FederationPeer
+	for _, p := range fc.Peers {
+		if p.URL != "" {
+			peers = append(peers, notify.FederationPeer{Name: p.Name, URL: p.URL, Secret: p.Secret})
+		}
+	}
+	return peers
+}
+
+// killRing remembers the last few thousand alerted kill IDs, ours and federated
+type killRing struct {
+	mu   sync.Mutex
+	seen map[int64]struct{}
+	ring []int64
+	next int
+}
+
+func newKillRing(size int) *killRing {
+	return &killRing{seen: make(map[int64]struct{}, size), ring: make([]int64, size)}
+}
+
+// add records id and reports whether it is new
+func (kr *killRing) add(id int64) bool {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	if _, ok := kr.seen[id]; ok {
+		return false
+	}
+	if old := kr.ring[kr.next]; old != 0 {
+		delete(kr.seen, old)
+	}
+	kr.ring[kr.next] = id
+	kr.next = (kr.next + 1) % len(kr.ring)
+	kr.seen[id] = struct{}{}
+	return true
+}
+
+// ReceiveFederated checks a signed envelope from a peer and sends its alert
+// to our sinks when our federation settings take it. It returns what
+// happened, e.g. "sent" or "already alerted".
+func (ck *Checker) ReceiveFederated(ctx context.Context, body []byte, signature string) (string, error) {
+	var env notify.FederationEnvelope
+	if err := json.Unmarshal(body, &env); err != nil {
+		return "", fmt.Errorf("decoding federation envelope: %w", err)
+	}
+	i := slices.IndexFunc(ck.config.Federation.Peers, func(p FederationPeerConfig) bool { return p.Name == env.Source })
+	if i < 0 || !notify.VerifySignature(ck.config.Federation.Peers[i].Secret, body, signature) {
+		return "", ErrFederationUntrusted
+	}
+	peer := ck.config.Federation.Peers[i]
+	if age := time.Since(env.SentAt); age > federationMaxAge || age < -federationMaxAge {
+		return "", ErrFederationStale
+	}
+	n := env.Kill.Notification()
+	if n.Kill == nil || n.KillMailID == 0 {
+		return "", errors.New("federation envelope has no killmail")
+	}
+
+	switch {
+	case !slices.Contains(peer.Accept, n.Kind):
+		return fmt.Sprintf("%s alerts from %s not accepted", n.Kind, peer.Name), nil
+	case n.Kill.TotalValue < peer.MinValue:
+		return "below minValue", nil
+	case ck.ignoredSystem(n.Kill.SolarSystemID):
+		return "system ignored", nil
+	case !ck.federated.add(n.KillMailID):
+		return "already alerted", nil
+	}
+
+	ctx = pipeline.WithKillID(ctx, n.KillMailID)
+	// the route and map link are the peer's, not ours
+	n.Via, n.RouteHome, n.MapURL, n.Quiet = peer.Name, nil, "", true
+	n.Reason.Text = fmt.Sprintf("via %s: %s", peer.Name, n.Reason.Text)
+	ck.killLogger(ctx).Printf("[Federation] %s alert for kill %d from %s", n.Kind, n.KillMailID, peer.Name)
+	ev := &pipeline.Event{Kind: n.Kind, IsKill: n.IsKill, Reason: n.Reason, Kill: *n.Kill, Notification: n}
+	ck.deliverAll(ctx, ev, n)
+	return "sent", nil
+}
+
+// ignoredSystem reports whether a system is on the ignore list
+func (ck *Checker) ignoredSystem(id int) bool {
+	_, ignored := ck.state().ignored[id]
+	return ignored
+}
@@ -0,0 +1,163 @@
+package chainkills
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/pkg/killmail"
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+	"github.com/guarzo/eve-chainkills/pkg/notify"
+)
+
+func newFederationChecker(t *testing.T, peers ...FederationPeerConfig) (*Checker, *scriptSink) {
+	t.Helper()
+	sink := &scriptSink{name: "ours"}
+	config := &Config{Federation: FederationConfig{Peers: peers}, IgnoreSystemIds: []int{31000009}}
+	if err := config.Federation.validate(); err != nil {
+		t.Fatal(err)
+	}
+	return newFallbackChecker(t, config, sink), sink
+}
+
+// federatedKill is a peer's chain alert for killID
+func federatedKill(killID int64, system int, value float64) notify.KillEvent {
+	return notify.NewKillEvent(notify.Notification{
+		Kind:       notify.KindChain,
+		KillMailID: killID,
+		Reason:     notify.MatchReason{Rule: notify.RuleChainSystem, Text: "in J123456"},
+		Kill:       &killmail.FlattenedKillMail{KillMailID: killID, SolarSystemID: system, TotalValue: value},
+	})
+}
+
+// signedEnvelope signs an envelope from source the way a peer's FederationSink does
+func signedEnvelope(t *testing.T, secret, source string, sentAt time.Time, kill notify.KillEvent) ([]byte, string) {
+	t.Helper()
+	body, err := json.Marshal(notify.FederationEnvelope{Version: notify.FederationVersion, Source: source, SentAt: sentAt, Kill: kill})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return body, "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestReceiveFederatedTrust(t *testing.T) {
+	ck, sink := newFederationChecker(t, FederationPeerConfig{Name: "allies", Secret: "shared", Accept: []notify.Kind{notify.KindChain}})
+	ctx := context.Background()
+	now := time.Now()
+
+	body, sig := signedEnvelope(t, "shared", "allies", now, federatedKill(1, 31000001, 1e9))
+	tampered, _ := signedEnvelope(t, "shared", "allies", now, federatedKill(1, 31000002, 1e9))
+	other, otherSig := signedEnvelope(t, "shared", "strangers", now, federatedKill(2, 31000001, 1e9))
+	stale, staleSig := signedEnvelope(t, "shared", "allies", now.Add(-time.Hour), federatedKill(3, 31000001, 1e9))
+	future, futureSig := signedEnvelope(t, "shared", "allies", now.Add(time.Hour), federatedKill(4, 31000001, 1e9))
+	forged, forgedSig := signedEnvelope(t, "guessed", "allies", now, federatedKill(5, 31000001, 1e9))
+
+	for _, tc := range []struct {
+		name      string
+		body      []byte
+		signature string
+		err       error
+	}{
+		{"bad signature", body, "sha256=00", ErrFederationUntrusted},
+		{"no signature", body, "", ErrFederationUntrusted},
+		{"tampered body", tampered, sig, ErrFederationUntrusted},
+		{"wrong secret", forged, forgedSig, ErrFederationUntrusted},
+		{"unknown source", other, otherSig, ErrFederationUntrusted},
+		{"stale envelope", stale, staleSig, ErrFederationStale},
+		{"envelope from the future", future, futureSig, ErrFederationStale},
+	} {
+		if outcome, err := ck.ReceiveFederated(ctx, tc.body, tc.signature); !errors.Is(err, tc.err) {
+			t.Errorf("%s: ReceiveFederated = %q, %v, want %v", tc.name, outcome, err, tc.err)
+		}
+	}
+	if len(sink.sent) != 0 {
+		t.Fatalf("refused envelopes sent %v", sink.sent)
+	}
+
+	if outcome, err := ck.ReceiveFederated(ctx, body, sig); err != nil || outcome != "sent" {
+		t.Fatalf("ReceiveFederated = %q, %v, want sent", outcome, err)
+	}
+	// a replayed envelope is the same kill again
+	if outcome, err := ck.ReceiveFederated(ctx, body, sig); err != nil || outcome != "already alerted" {
+		t.Errorf("replayed envelope = %q, %v, want already alerted", outcome, err)
+	}
+	if len(sink.sent) != 1 || sink.sent[0] != 1 {
+		t.Errorf("sent %v, want kill 1 once", sink.sent)
+	}
+}
+
+func TestReceiveFederatedFilters(t *testing.T) {
+	ck, sink := newFederationChecker(t,
+		FederationPeerConfig{Name: "allies", Secret: "shared", Accept: []notify.Kind{notify.KindChain}, MinValue: 1e8},
+		FederationPeerConfig{Name: "neighbours", Secret: "other"},
+	)
+	ctx := context.Background()
+	// a kill we alerted on ourselves isn't alerted again when a peer sends it
+	ck.federated.add(6)
+
+	for _, tc := range []struct {
+		secret, source string
+		kill           notify.KillEvent
+		want           string
+	}{
+		{"shared", "allies", federatedKill(1, 31000001, 1e9), "sent"},
+		{"shared", "allies", federatedKill(2, 31000001, 1e7), "below minValue"},
+		{"shared", "allies", notify.KillEvent{Event: notify.KindCorpKill, KillMail: &killmail.FlattenedKillMail{KillMailID: 3, TotalValue: 1e9}}, "corpKill alerts from allies not accepted"},
+		{"other", "neighbours", federatedKill(4, 31000001, 1e9), "chain alerts from neighbours not accepted"},
+		{"shared", "allies", federatedKill(5, 31000009, 1e9), "system ignored"},
+		{"shared", "allies", federatedKill(6, 31000001, 1e9), "already alerted"},
+	} {
+		body, sig := signedEnvelope(t, tc.secret, tc.source, time.Now(), tc.kill)
+		if outcome, err := ck.ReceiveFederated(ctx, body, sig); err != nil || outcome != tc.want {
+			t.Errorf("kill %d from %s = %q, %v, want %q", tc.kill.KillMail.KillMailID, tc.source, outcome, err, tc.want)
+		}
+	}
+	if len(sink.sent) != 1 || sink.sent[0] != 1 {
+		t.Errorf("sent %v, want only kill 1", sink.sent)
+	}
+
+	// filtered kills weren't remembered, so the same kill passes once it's worth enough
+	body, sig := signedEnvelope(t, "shared", "allies", time.Now(), federatedKill(2, 31000001, 1e9))
+	if outcome, err := ck.ReceiveFederated(ctx, body, sig); err != nil || outcome != "sent" {
+		t.Errorf("kill 2 at 1e9 = %q, %v, want sent", outcome, err)
+	}
+}
+
+func TestFederationSinkToReceiver(t *testing.T) {
+	ck, sink := newFederationChecker(t, FederationPeerConfig{Name: "allies", Secret: "shared", Accept: []notify.Kind{notify.KindChain}})
+	var outcome string
+	var receiveErr error
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		outcome, receiveErr = ck.ReceiveFederated(r.Context(), body, r.Header.Get(notify.WebhookSignatureHeader))
+	}))
+	defer srv.Close()
+
+	fs := notify.NewFederationSink(logger.Slog(slog.New(slog.NewTextHandler(io.Discard, nil))), "allies", []notify.FederationPeer{{Name: "us", URL: srv.URL, Secret: "shared"}})
+	fs.SetHTTPClient(srv.Client())
+	n := federatedKill(1, 31000001, 1e9).Notification()
+	if err := fs.Send(context.Background(), n); err != nil {
+		t.Fatal(err)
+	}
+	if receiveErr != nil || outcome != "sent" || len(sink.sent) != 1 {
+		t.Fatalf("received %q, %v and sent %v, want the peer's alert sent", outcome, receiveErr, sink.sent)
+	}
+
+	// alerts that came from a peer aren't sent on
+	n.Via = "elsewhere"
+	outcome = ""
+	if err := fs.Send(context.Background(), n); err != nil || outcome != "" {
+		t.Errorf("forwarded a federated alert: %q, %v", outcome, err)
+	}
+}
@@ -0,0 +1,73 @@
+package chainkills
+
+import (
+	"context"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/pkg/killmail"
+)
+
+// fleetLister is implemented by ESI clients signed in with a fleet boss
+type fleetLister interface {
+	FleetMembers(ctx context.Context) (int64, []int64, error)
+}
+
+// pollFleet refreshes the fleet members until ctx is done
+func (ck *Checker) pollFleet(ctx context.Context) {
+	lister, ok := ck.esi.(fleetLister)
+	if !ok {
+		ck.logger.Warnf("[Fleet] The ESI client can't list fleet members")
+		return
+	}
+	interval := time.Minute
+	if ps := ck.config.Fleet.PollSeconds; ps > 0 {
+		interval = time.Duration(ps) * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var lastFleet int64
+	for {
+		fleetID, ids, err := lister.FleetMembers(ctx)
+		if err != nil {
+			// keep the last members; a failed poll shouldn't bring back alerts for our own fleet
+			ck.logger.Errorf("[Fleet] Error fetching fleet members: %v", err)
+		} else {
+			switch {
+			case fleetID == lastFleet:
+			case fleetID == 0:
+				ck.logger.Printf("[Fleet] Left fleet %d", lastFleet)
+			default:
+				ck.logger.Printf("[Fleet] In fleet %d with %d members", fleetID, len(ids))
+			}
+			lastFleet = fleetID
+			ck.setFleet(ids)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (ck *Checker) setFleet(ids []int64) {
+	members := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		members[id] = true
+	}
+	ck.charsMu.Lock()
+	ck.fleetMembers = members
+	ck.charsMu.Unlock()
+}
+
+// fleetAttacker returns an attacker who is in our fleet, or 0
+func (ck *Checker) fleetAttacker(zm killmail.ZkillMail) int64 {
+	ck.charsMu.RLock()
+	defer ck.charsMu.RUnlock()
+	for _, att := range zm.Attackers {
+		if att.CharacterID != 0 && ck.fleetMembers[att.CharacterID] {
+			return att.CharacterID
+		}
+	}
+	return 0
+}
@@ -0,0 +1,72 @@
+package chainkills
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/internal/filter"
+	mapapi "github.com/guarzo/eve-chainkills/internal/map"
+	"github.com/guarzo/eve-chainkills/pkg/killmail"
+)
+
+// friendliesAt names the online map characters in the kill's system, or returns
+// nil when any attacker is on the map, since then it is our own fight. The
+// victim is left out: they are already dead.
+func friendliesAt(zm killmail.ZkillMail, ix *filter.Index, chars []killmail.MapCharacter) []string {
+	if !ix.Occupied(zm.SolarSystemID) {
+		return nil
+	}
+	for _, att := range zm.Attackers {
+		if ix.Mapped(att.CharacterID) {
+			return nil
+		}
+	}
+
+	var names []string
+	for _, mc := range chars {
+		if mc.SolarSystemId != zm.SolarSystemID || !mc.Online || mc.CharacterId == zm.Victim.CharacterID {
+			continue
+		}
+		name := mc.Name
+		if name == "" {
+			name = strconv.FormatInt(mc.CharacterId, 10)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// pollCharacters refreshes the map characters, and so their locations, until ctx is done
+func (ck *Checker) pollCharacters(ctx context.Context) {
+	interval := time.Minute
+	if ps := ck.config.FriendlyDanger.PollSeconds; ps > 0 {
+		interval = time.Duration(ps) * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if !ck.charactersAllowed() {
+			continue
+		}
+		// errors are only logged; the startup fetch already reports a broken map API
+		chars, err := ck.mapAPI.Characters(ctx)
+		if errors.Is(err, mapapi.ErrForbidden) {
+			ck.charactersDenied(ctx, err)
+			continue
+		}
+		if err != nil {
+			ck.mapLogger.Printf("[pollCharacters] Error fetching characters: %v", err)
+			continue
+		}
+		ck.setCharacters(chars)
+	}
+}
@@ -0,0 +1,106 @@
+package chainkills
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/pkg/killmail"
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+	"github.com/guarzo/eve-chainkills/pkg/notify"
+)
+
+// echoESI answers GetKillDetails from the killstream message itself
+type echoESI struct{}
+
+func (echoESI) GetKillDetails(ctx context.Context, raw []byte) (killmail.FlattenedKillMail, error) {
+	var zm killmail.ZkillMail
+	if err := json.Unmarshal(raw, &zm); err != nil {
+		return killmail.FlattenedKillMail{}, err
+	}
+	return killmail.FlattenedKillMail{
+		KillMailID:    zm.KillmailID,
+		KillMailTime:  zm.KillmailTime,
+		SolarSystemID: zm.SolarSystemID,
+		Victim:        zm.Victim,
+		Attackers:     zm.Attackers,
+		TotalValue:    zm.ZKB.TotalValue,
+	}, nil
+}
+
+func (echoESI) TypeInfo(ctx context.Context, typeID int) (string, int, error) {
+	return "Ship", 25, nil
+}
+
+// renderSink renders every notification in every format, like the real sinks would
+type renderSink struct {
+	mu   sync.Mutex
+	sent int
+}
+
+func (s *renderSink) Name() string { return "render" }
+
+func (s *renderSink) Send(ctx context.Context, n notify.Notification) error {
+	_ = n.CompactText(killmail.ISKFormatBoth)
+	_ = n.PlainText(killmail.ISKFormatBoth, time.UTC)
+	_ = n.JSONText(true)
+	s.mu.Lock()
+	s.sent++
+	s.mu.Unlock()
+	return nil
+}
+
+func FuzzHandleMessage(f *testing.F) {
+	for _, seed := range []string{
+		`{"killmail_id":123,"killmail_time":"2024-05-01T12:00:00Z","solar_system_id":31000001,"victim":{"corporation_id":98000001,"ship_type_id":587,"position":{"x":1,"y":2,"z":3}},"attackers":[{"character_id":90000001,"corporation_id":98000002,"final_blow":true}],"zkb":{"hash":"abc123","totalValue":1e9,"locationID":40000001}}`,
+		`{"killmail_id":124,"solar_system_id":31000001,"victim":{},"attackers":[],"zkb":{"hash":"ff"}}`,
+		`{"killmail_id":-1,"solar_system_id":-5,"victim":{"character_id":-7},"attackers":[{"corporation_id":-3}],"zkb":{"hash":"../../x","totalValue":-1e308}}`,
+		`{"killmail_id":125,"attackers":[{},{},{},{}],"zkb":{"totalValue":1e308}}`,
+		`{"killmail_id":126,"killmail_time":"not a time"`,
+		`{"action":"tqStatus","tqStatus":"ONLINE"}`,
+		`ping`,
+		``,
+		`[]`,
+		`null`,
+		`{"killmail_id":"127"}`,
+		`{"killmail_id":9223372036854775807,"solar_system_id":2147483647}`,
+	} {
+		f.Add([]byte(seed))
+	}
+
+	config := &Config{TrackedCorporationIds: []int{98000001}}
+	sink := &renderSink{}
+	ck, err := newChecker(&options{
+		logger: logger.Slog(slog.New(slog.NewTextHandler(io.Discard, nil))),
+		esi:    echoESI{},
+		sinks:  []notify.Sink{sink},
+	}, config)
+	if err != nil {
+		f.Fatal(err)
+	}
+	ck.updateState(func(s *mapState) {
+		s.systems = []killmail.SystemInfo{{SystemId: 31000001, Alias: "home"}}
+	})
+
+	f.Fuzz(func(t *testing.T, raw []byte) {
+		// HandleMessage recovers its own panics, so count them instead
+		before := ck.supervisor.Panics()["handler"]
+		ck.HandleMessage(context.Background(), raw)
+		if ck.supervisor.Panics()["handler"] != before {
+			t.Fatalf("panic handling %q", truncate(raw))
+		}
+	})
+}
+
+func truncate(raw []byte) string {
+	s := string(raw)
+	if len(s) > 200 {
+		s = s[:200] + "…"
+	}
+	return strings.ToValidUTF8(s, "?")
+}
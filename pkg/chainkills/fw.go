@@ -0,0 +1,192 @@
+package chainkills
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/pkg/killmail"
+	"github.com/guarzo/eve-chainkills/pkg/notify"
+)
+
+// militias are the factions pilots can enlist with in Faction Warfare
+var militias = map[int]string{
+	500001: "Caldari State",
+	500002: "Minmatar Republic",
+	500003: "Amarr Empire",
+	500004: "Gallente Federation",
+	500010: "Guristas Pirates",
+	500011: "Angel Cartel",
+}
+
+// warzoneLister reads the status of the Faction Warfare systems
+type warzoneLister interface {
+	FWSystems(ctx context.Context) ([]killmail.EsiFWSystem, error)
+}
+
+func (fc FactionWarfareConfig) validate() error {
+	if !fc.Enabled {
+		return nil
+	}
+	for _, id := range fc.FactionIds {
+		if _, ok := militias[id]; !ok {
+			return fmt.Errorf("factionWarfare.factionIds: %d is not a militia faction, e.g. 500001 for the Caldari State", id)
+		}
+	}
+	if fc.Alert && len(fc.FactionIds) == 0 {
+		return fmt.Errorf("factionWarfare.alert needs factionIds, or every kill in a warzone would alert")
+	}
+	if fc.RefreshMinutes < 0 {
+		return fmt.Errorf("factionWarfare.refreshMinutes must not be negative")
+	}
+	return nil
+}
+
+// pollWarzone refreshes the warzone systems' status until ctx is done
+func (ck *Checker) pollWarzone(ctx context.Context) {
+	interval := 30 * time.Minute
+	if rm := ck.config.FactionWarfare.RefreshMinutes; rm > 0 {
+		interval = time.Duration(rm) * time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := ck.loadWarzone(ctx); err != nil {
+			ck.logger.Errorf("[FW] Error fetching the warzone, keeping the last status: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// loadWarzone fetches every Faction Warfare system's status from ESI
+func (ck *Checker) loadWarzone(ctx context.Context) error {
+	lister, ok := ck.esi.(warzoneLister)
+	if !ok {
+		return fmt.Errorf("the ESI client can't list Faction Warfare systems")
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	systems, err := lister.FWSystems(ctx)
+	if err != nil {
+		return err
+	}
+	warzone := make(map[int]killmail.EsiFWSystem, len(systems))
+	for _, sys := range systems {
+		warzone[sys.SolarSystemID] = sys
+	}
+	ck.updateState(func(s *mapState) { s.warzone = warzone })
+	return nil
+}
+
+// militia reports whether a faction is one of factionWarfare.factionIds, or
+// with none set, any militia
+func (ck *Checker) militia(factionID int) bool {
+	if _, ok := militias[factionID]; !ok {
+		return false
+	}
+	ids := ck.config.FactionWarfare.FactionIds
+	if len(ids) == 0 {
+		return true
+	}
+	for _, id := range ids {
+		if id == factionID {
+			return true
+		}
+	}
+	return false
+}
+
+// factionWarfare sums up a kill's Faction Warfare side, e.g. "occupied by
+// Gallente Federation, contested 43% · victim in the Caldari State militia ·
+// 5 attackers in the Gallente Federation militia". It returns "" unless the
+// system's holders or a pilot on either side are among the militias cared about.
+func (ck *Checker) factionWarfare(zm killmail.ZkillMail) string {
+	if !ck.config.FactionWarfare.Enabled {
+		return ""
+	}
+	var parts []string
+	relevant := false
+	if sys, ok := ck.state().warzone[zm.SolarSystemID]; ok {
+		relevant = ck.militia(sys.OwnerFactionID) || ck.militia(sys.OccupierFactionID)
+		parts = append(parts, warzoneStatus(sys))
+	}
+	if name, ok := militias[zm.Victim.FactionID]; ok {
+		relevant = relevant || ck.militia(zm.Victim.FactionID)
+		parts = append(parts, fmt.Sprintf("victim in the %s militia", name))
+	}
+	counts := map[int]int{}
+	for _, att := range zm.Attackers {
+		if _, ok := militias[att.FactionID]; ok {
+			counts[att.FactionID]++
+			relevant = relevant || ck.militia(att.FactionID)
+		}
+	}
+	factions := make([]int, 0, len(counts))
+	for id := range counts {
+		factions = append(factions, id)
+	}
+	sort.Slice(factions, func(i, j int) bool {
+		if counts[factions[i]] != counts[factions[j]] {
+			return counts[factions[i]] > counts[factions[j]]
+		}
+		return factions[i] < factions[j]
+	})
+	for _, id := range factions {
+		parts = append(parts, fmt.Sprintf("%s in the %s militia", plural(counts[id], "attacker"), militias[id]))
+	}
+	if !relevant {
+		return ""
+	}
+	return strings.Join(parts, " · ")
+}
+
+// warzoneStatus describes who holds a system and how close it is to changing
+// hands, e.g. "occupied by Caldari State, contested 43%"
+func warzoneStatus(sys killmail.EsiFWSystem) string {
+	holder, ok := militias[sys.OccupierFactionID]
+	if !ok {
+		holder = fmt.Sprintf("faction %d", sys.OccupierFactionID)
+	}
+	status := fmt.Sprintf("occupied by %s, %s", holder, sys.Contested)
+	if sys.Contested != "uncontested" && sys.VictoryPointsThreshold > 0 {
+		status += fmt.Sprintf(" %d%%", sys.VictoryPoints*100/sys.VictoryPointsThreshold)
+	}
+	return status
+}
+
+// fwMatch matches a kill in a warzone system with a pilot of
+// factionWarfare.factionIds on either side, as a corp loss or kill
+func (ck *Checker) fwMatch(zm killmail.ZkillMail) (Match, bool) {
+	if _, ok := ck.state().warzone[zm.SolarSystemID]; !ok {
+		return Match{}, false
+	}
+	if ck.militia(zm.Victim.FactionID) {
+		return Match{Kind: notify.KindCorpKill, Reason: notify.MatchReason{
+			Rule: notify.RuleFactionWarfare,
+			ID:   int64(zm.Victim.FactionID),
+			Text: fmt.Sprintf("victim in the %s militia", militias[zm.Victim.FactionID]),
+		}}, true
+	}
+	for _, att := range zm.Attackers {
+		if ck.militia(att.FactionID) {
+			return Match{Kind: notify.KindCorpKill, IsKill: true, Reason: notify.MatchReason{
+				Rule: notify.RuleFactionWarfare,
+				ID:   int64(att.FactionID),
+				Text: fmt.Sprintf("attacker in the %s militia", militias[att.FactionID]),
+			}}, true
+		}
+	}
+	return Match{}, false
+}
+
+// fwAlerts reports whether kills with militia pilots alert on their own
+func (ck *Checker) fwAlerts() bool {
+	fc := ck.config.FactionWarfare
+	return fc.Enabled && fc.Alert
+}
@@ -0,0 +1,81 @@
+package chainkills
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// heartbeatPingInterval is how often the zKill socket is pinged while heartbeats
+// are on, so a quiet killstream still shows it's alive
+const heartbeatPingInterval = 30 * time.Second
+
+// feedAlive reports whether the killstream is connected and a kill, frame or
+// pong arrived within maxSilence
+func (h *Hub) feedAlive(maxSilence time.Duration) bool {
+	if !h.feed.connected.Load() {
+		return false
+	}
+	last := h.feed.lastActivity.Load()
+	return last != 0 && time.Since(time.Unix(0, last)) < maxSilence
+}
+
+// runHeartbeat requests the heartbeat URL on a schedule while the feed is
+// alive, and the down URL, if any, while it isn't. Nothing is sent during
+// downtime, when the feed is expected to drop.
+func (h *Hub) runHeartbeat(ctx context.Context) {
+	hc := h.heartbeat
+	interval := 60 * time.Second
+	if hc.IntervalSeconds > 0 {
+		interval = time.Duration(hc.IntervalSeconds) * time.Second
+	}
+	maxSilence := 5 * time.Minute
+	if hc.MaxSilenceSeconds > 0 {
+		maxSilence = time.Duration(hc.MaxSilenceSeconds) * time.Second
+	}
+	h.logger.Printf("[Heartbeat] Pinging every %s while the killstream is alive", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if h.inDowntime() {
+			continue
+		}
+		url := hc.URL
+		if !h.feedAlive(maxSilence) {
+			h.logger.Printf("[Heartbeat] Killstream silent for over %s; withholding heartbeat", maxSilence)
+			if hc.DownURL == "" {
+				continue
+			}
+			url = hc.DownURL
+		}
+		if err := h.pingHeartbeat(ctx, url); err != nil {
+			h.logger.Printf("[Heartbeat] Error sending heartbeat: %v", err)
+		}
+	}
+}
+
+// pingHeartbeat requests a push monitor URL
+func (h *Hub) pingHeartbeat(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := h.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("heartbeat got status %d", resp.StatusCode)
+	}
+	return nil
+}
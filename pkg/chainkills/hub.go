@@ -0,0 +1,499 @@
+package chainkills
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/internal/attribution"
+	"github.com/guarzo/eve-chainkills/internal/audit"
+	"github.com/guarzo/eve-chainkills/internal/debugserver"
+	"github.com/guarzo/eve-chainkills/internal/discord"
+	"github.com/guarzo/eve-chainkills/internal/errreport"
+	"github.com/guarzo/eve-chainkills/internal/httpclient"
+	"github.com/guarzo/eve-chainkills/internal/killtags"
+	"github.com/guarzo/eve-chainkills/internal/lanes"
+	"github.com/guarzo/eve-chainkills/internal/logging"
+	"github.com/guarzo/eve-chainkills/internal/schema"
+	"github.com/guarzo/eve-chainkills/internal/supervise"
+	"github.com/guarzo/eve-chainkills/internal/systemd"
+	"github.com/guarzo/eve-chainkills/internal/tracing"
+	"github.com/guarzo/eve-chainkills/internal/zkill"
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+	"github.com/guarzo/eve-chainkills/pkg/pipeline"
+	"golang.org/x/exp/slices"
+)
+
+// Hub shares one killstream source between every configured instance
+type Hub struct {
+	logger   logger.Logger
+	checkers []*Checker
+	source   pipeline.Source
+
+	mu      sync.Mutex
+	started bool
+	cancel  context.CancelFunc
+	// wg tracks the source and every in-flight message
+	wg sync.WaitGroup
+
+	tracer   *tracing.Exporter
+	debug    *debugserver.Server
+	admin    *adminServer
+	commands *commandPoller
+	// configPath is where admin changes are saved; instanced reports whether it uses "instances"
+	configPath string
+	instanced  bool
+	// trackingMu serialises tracking edits so concurrent saves don't overwrite each other
+	trackingMu sync.Mutex
+	// inflight counts messages still being processed by a checker
+	inflight atomic.Int64
+
+	feedTimeout time.Duration
+	feed        feedState
+	// seen drops killmails already dispatched, before they are counted
+	seen *killRing
+
+	supervisor *supervise.Supervisor
+	reporter   *errreport.Reporter
+	audit      *audit.Log
+	// attribution is nil unless per-pilot records are enabled
+	attribution *attribution.Store
+	// tags is nil unless kill tags are enabled
+	tags *killtags.Store
+	// activity is nil unless galaxy activity is counted
+	activity *galaxyActivity
+	// lanes is nil when priority.workers is -1
+	lanes *lanes.Gate
+	// http sends every outbound request and webhooks every Discord message,
+	// for all instances
+	http     *http.Client
+	webhooks *discord.Webhooks
+
+	// heartbeat and downtime are top-level only, as they watch the shared killstream
+	heartbeat HeartbeatConfig
+	downtime  DowntimeConfig
+
+	skipSelfTest bool
+}
+
+// NewHub creates a Checker for each instance in config, fed from zKillboard
+func NewHub(logger logger.Logger, config *Config) (*Hub, error) {
+	return newHub(&options{logger: logger, config: config})
+}
+
+func newHub(o *options) (*Hub, error) {
+	o.raw = o.logger
+	if len(o.config.LogLevels) > 0 {
+		levels, err := logging.ParseLevels(o.config.LogLevel, o.config.LogLevels)
+		if err != nil {
+			return nil, fmt.Errorf("logLevels: %w", err)
+		}
+		o.levels = &levels
+		o.logger = levels.For(o.raw, "")
+	}
+
+	h := &Hub{
+		logger:       o.logger,
+		supervisor:   supervise.New(o.logger),
+		skipSelfTest: o.config.SelfTest.Disabled,
+		configPath:   o.config.path,
+		instanced:    len(o.config.Instances) > 0,
+		seen:         newKillRing(1000),
+		heartbeat:    o.config.Heartbeat,
+		downtime:     o.config.Downtime,
+	}
+	if o.supervisor == nil {
+		o.supervisor = h.supervisor
+	}
+	if err := o.config.HTTP.validate(); err != nil {
+		return nil, err
+	}
+	if o.http == nil {
+		o.http = httpclient.New(o.config.HTTP.client())
+	}
+	h.http = o.http
+	if err := o.config.Priority.validate(); err != nil {
+		return nil, err
+	}
+	h.lanes = lanes.New(gateSlots(o.config.Priority.Workers, 8), pipeline.NumPriorities)
+	o.lanes = h.lanes
+	h.webhooks = discord.NewWebhooks(o.http, gateSlots(o.config.Priority.DiscordSends, 2), pipeline.NumPriorities)
+	o.webhooks = h.webhooks
+	er := o.config.ErrorReporting
+	reporter, err := errreport.New(o.logger, errreport.Config{
+		SentryDSN:           er.SentryDSN,
+		WebhookURL:          er.WebhookURL,
+		Environment:         er.Environment,
+		RepeatWindowMinutes: er.RepeatWindowMinutes,
+		HTTPClient:          o.http,
+	})
+	if err != nil {
+		return nil, err
+	}
+	h.reporter = reporter
+	h.supervisor.OnPanic = func(component string, r interface{}, stack []byte) {
+		h.reporter.Panic(context.Background(), r, stack, map[string]string{"component": component})
+	}
+	switch ft := o.config.Systemd.FeedTimeoutMinutes; {
+	case ft == 0:
+		h.feedTimeout = 15 * time.Minute
+	case ft > 0:
+		h.feedTimeout = time.Duration(ft) * time.Minute
+	}
+	if tc := o.config.Tracing; tc.Endpoint != "" {
+		exp, err := tracing.Init(o.logger, tracing.Config{Endpoint: tc.Endpoint, ServiceName: tc.ServiceName, Headers: tc.Headers, HTTPClient: o.http})
+		if err != nil {
+			return nil, err
+		}
+		h.tracer = exp
+		h.logger.Printf("[Hub] Exporting traces to %s", tc.Endpoint)
+	}
+	if err := schema.Init(o.logger, schema.Config{SampleDir: o.config.Schema.SampleDir}); err != nil {
+		return nil, fmt.Errorf("schema.sampleDir: %w", err)
+	}
+	if ac := o.config.Audit; ac.Path != "" {
+		maxMB := ac.MaxSizeMB
+		if maxMB <= 0 {
+			maxMB = 50
+		}
+		auditLog, err := audit.Open(ac.Path, int64(maxMB)<<20)
+		if err != nil {
+			return nil, fmt.Errorf("audit log: %w", err)
+		}
+		h.audit = auditLog
+		o.audit = auditLog
+	}
+	if sc := o.config.SSO; sc.ClientID != "" {
+		client, err := NewSSO(o.componentLogger(o.rawLogger(), logging.ESI), sc)
+		if err != nil {
+			return nil, fmt.Errorf("sso: %w", err)
+		}
+		client.SetHTTPClient(o.http)
+		o.sso = client
+		if chars := client.Characters(); len(chars) == 0 {
+			h.logger.Warnf("[Hub] EVE SSO is configured but no character is signed in; run \"chainkills sso login\"")
+		}
+	}
+	if ac := o.config.Attribution; ac.Path != "" {
+		store, err := attribution.Open(ac.Path)
+		if err != nil {
+			return nil, fmt.Errorf("attribution: %w", err)
+		}
+		h.attribution = store
+		o.attribution = store
+	}
+	if tc := o.config.Tags; tc.Path != "" {
+		store, err := killtags.Open(tc.Path)
+		if err != nil {
+			return nil, fmt.Errorf("tags: %w", err)
+		}
+		h.tags = store
+		o.tags = store
+	}
+	if o.config.GalaxyActivity.Enabled {
+		h.activity = newGalaxyActivity()
+		o.activity = h.activity
+	}
+	for _, ic := range o.config.InstanceConfigs() {
+		if !slices.Equal(ic.Killstream.Channels, o.config.Killstream.Channels) {
+			return nil, fmt.Errorf("instance %s: killstream.channels is top-level only, as instances share one zKillboard connection", ic.Name)
+		}
+		if ic.Heartbeat != o.config.Heartbeat {
+			return nil, fmt.Errorf("instance %s: heartbeat is top-level only, as instances share one killstream", ic.Name)
+		}
+		if ic.Downtime != o.config.Downtime {
+			return nil, fmt.Errorf("instance %s: downtime is top-level only, as instances share one killstream", ic.Name)
+		}
+		ck, err := newChecker(o, ic)
+		if err != nil {
+			return nil, err
+		}
+		h.checkers = append(h.checkers, ck)
+	}
+
+	if dc := o.config.Debug; dc.Listen != "" {
+		srv, err := debugserver.New(h.logger, dc.Listen, dc.Token, h.DebugVars)
+		if err != nil {
+			return nil, err
+		}
+		h.debug = srv
+	}
+	if ac := o.config.Admin; ac.Listen != "" {
+		srv, err := newAdminServer(h, ac)
+		if err != nil {
+			return nil, err
+		}
+		h.admin = srv
+	}
+	if dc := o.config.DiscordCommands; dc.ChannelId != "" {
+		cp, err := newCommandPoller(h.logger, h, dc)
+		if err != nil {
+			return nil, err
+		}
+		h.commands = cp
+	}
+
+	if o.source != nil {
+		h.source = o.source
+	} else {
+		zk := zkill.NewClient(o.componentLogger(o.raw, logging.Zkill), o.killstreamURL)
+		zk.OnOpen = func(ctx context.Context) {
+			h.feedConnected()
+			if h.inDowntime() {
+				// reconnects are routine during downtime
+				return
+			}
+			for _, ck := range h.checkers {
+				ck.sendInfoMessage(ctx, "zkill socket opened.")
+			}
+		}
+		zk.OnClose = h.feedDisconnected
+		zk.Channels = o.config.Killstream.Channels
+		if o.config.Heartbeat.URL != "" {
+			zk.PingInterval = heartbeatPingInterval
+			zk.OnPong = h.feed.pong
+		}
+		zk.Quiet = h.inDowntime
+		h.source = zk
+	}
+
+	h.logger.Printf("[Hub] Initialized with %d instance(s).", len(h.checkers))
+	return h, nil
+}
+
+// Checkers returns the per-instance checkers
+func (h *Hub) Checkers() []*Checker {
+	return h.checkers
+}
+
+// StartListening loads each instance's map data, then starts the source.
+// Everything stops once ctx is done.
+func (h *Hub) StartListening(ctx context.Context) {
+	for _, ck := range h.checkers {
+		ck.Start(ctx)
+	}
+
+	// start a goroutine that attempts to maintain the WebSocket connection,
+	// restarting it if it panics
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		h.supervisor.Run(ctx, "source", func(ctx context.Context) {
+			h.source.Run(ctx, h.dispatch(ctx))
+		})
+	}()
+}
+
+// dispatch hands each killmail to every checker in its own goroutine; pings,
+// announcements and malformed frames are only counted
+func (h *Hub) dispatch(ctx context.Context) func(raw []byte) {
+	return func(raw []byte) {
+		defer h.supervisor.Recover("dispatch", nil)
+		if frame := zkill.Classify(raw); frame != zkill.FrameKillmail {
+			h.feed.skip(frame)
+			h.logger.Debugf("Skipping %s frame from the killstream (%d bytes)", frame, len(raw))
+			return
+		}
+		if id := zkill.PeekID(raw); id != 0 && !h.seen.add(id) {
+			// subscribed channels overlap, so a kill can arrive once per channel
+			h.feed.duplicate()
+			return
+		}
+		h.feed.received()
+		if h.activity != nil {
+			h.activity.record(raw)
+		}
+		for _, ck := range h.checkers {
+			h.wg.Add(1)
+			h.inflight.Add(1)
+			go func(ck *Checker) {
+				defer h.wg.Done()
+				defer h.inflight.Add(-1)
+				ck.HandleMessage(ctx, raw)
+			}(ck)
+		}
+	}
+}
+
+// Start runs the self-test (unless disabled), loads each instance's map data and
+// starts the source; it fails if already started or the self-test fails.
+// The hub runs until ctx is done or Stop is called.
+func (h *Hub) Start(ctx context.Context) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.started {
+		return errors.New("chainkills: hub already started")
+	}
+	if !h.skipSelfTest {
+		if err := h.SelfTest(ctx); err != nil {
+			return fmt.Errorf("self-test: %w", err)
+		}
+	}
+	if h.debug != nil {
+		if err := h.debug.Start(); err != nil {
+			return fmt.Errorf("start debug server: %w", err)
+		}
+	}
+	if h.admin != nil {
+		if err := h.admin.start(); err != nil {
+			return fmt.Errorf("start admin API: %w", err)
+		}
+	}
+	h.started = true
+	runCtx, cancel := context.WithCancel(ctx)
+	h.cancel = cancel
+	h.StartListening(runCtx)
+	if _, ok := h.source.(*zkill.Client); !ok {
+		// custom sources have no connection to wait for
+		h.feedConnected()
+	}
+	go h.supervisor.Run(runCtx, "systemd", h.runSystemd)
+	if h.heartbeat.URL != "" {
+		go h.supervisor.Run(runCtx, "heartbeat", h.runHeartbeat)
+	}
+	if !h.downtime.Disabled {
+		go h.supervisor.Run(runCtx, "downtime", h.runDowntime)
+	}
+	if h.metricsLogInterval() > 0 {
+		go h.supervisor.Run(runCtx, "metrics log", h.logMetrics)
+	}
+	if h.commands != nil {
+		go h.supervisor.Run(runCtx, "commands", h.commands.run)
+	}
+	for _, ck := range h.checkers {
+		if ck.config.FriendlyDanger.Enabled {
+			go h.supervisor.Run(runCtx, "characters", ck.pollCharacters)
+		}
+		if ck.config.CorpMembers.Enabled {
+			go h.supervisor.Run(runCtx, "corp members", ck.pollCorpMembers)
+		}
+		if ck.config.FactionWarfare.Enabled {
+			go h.supervisor.Run(runCtx, "warzone", ck.pollWarzone)
+		}
+		if ck.config.TrackedSource.URL != "" {
+			go h.supervisor.Run(runCtx, "tracked source", ck.pollTrackedSource)
+		}
+		if ck.config.Fleet.Enabled {
+			go h.supervisor.Run(runCtx, "fleet", ck.pollFleet)
+		}
+		if ck.config.Acknowledgments.Enabled {
+			go h.supervisor.Run(runCtx, "acknowledgments", ck.pollAcks)
+		}
+		if ck.digestEnabled() {
+			go h.supervisor.Run(runCtx, "digest", ck.runDigest)
+		}
+		if ck.sieges != nil {
+			go h.supervisor.Run(runCtx, "structure sieges", ck.runSieges)
+		}
+		if ck.threat != nil {
+			go h.supervisor.Run(runCtx, "threat", ck.runThreat)
+		}
+	}
+	return nil
+}
+
+// Stop cancels the hub and waits for in-flight messages to finish, or for ctx
+// to be done; it fails if the hub was not started
+func (h *Hub) Stop(ctx context.Context) error {
+	h.mu.Lock()
+	if !h.started {
+		h.mu.Unlock()
+		return errors.New("chainkills: hub not started")
+	}
+	h.started = false
+	h.cancel()
+	h.mu.Unlock()
+	h.notifySystemd(systemd.Stopping)
+	h.Close()
+	if h.debug != nil {
+		if err := h.debug.Shutdown(ctx); err != nil {
+			h.logger.Printf("Error stopping debug server: %v", err)
+		}
+	}
+	if h.admin != nil {
+		if err := h.admin.shutdown(ctx); err != nil {
+			h.logger.Printf("Error stopping admin API: %v", err)
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		h.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	for _, ck := range h.checkers {
+		ck.flushAggregates(ctx)
+	}
+	if h.audit != nil {
+		if err := h.audit.Close(); err != nil {
+			h.logger.Printf("Error closing audit log: %v", err)
+		}
+	}
+	if h.attribution != nil {
+		if err := h.attribution.Close(); err != nil {
+			h.logger.Printf("Error closing attribution store: %v", err)
+		}
+	}
+	if h.tags != nil {
+		if err := h.tags.Close(); err != nil {
+			h.logger.Printf("Error closing tag store: %v", err)
+		}
+	}
+	if h.tracer != nil {
+		return h.tracer.Shutdown(ctx)
+	}
+	return nil
+}
+
+// DebugVars reports queue lengths and per-instance state for /debug/vars
+func (h *Hub) DebugVars() map[string]interface{} {
+	instances := make([]map[string]interface{}, 0, len(h.checkers))
+	for _, ck := range h.checkers {
+		mapped, members := ck.characterCounts()
+		instances = append(instances, map[string]interface{}{
+			"name":          ck.config.Name,
+			"systems":       len(ck.state().systems),
+			"mapCharacters": mapped,
+			"corpMembers":   members,
+			"sinks":         len(ck.sinks),
+			"stages":        ck.stageMetrics.Snapshot(),
+			"metrics":       ck.Metrics(),
+		})
+	}
+	vars := map[string]interface{}{
+		"inflightMessages": h.inflight.Load(),
+		"instances":        instances,
+		"panics":           h.supervisor.Panics(),
+		"decode":           schema.Stats(),
+		"skippedFrames":    h.feed.skippedFrames(),
+		"duplicateKills":   h.feed.duplicates.Load(),
+	}
+	if h.tracer != nil {
+		vars["traceExportQueue"] = h.tracer.QueueLen()
+		vars["traceExportFailures"] = h.tracer.Failures()
+	}
+	if h.lanes != nil {
+		vars["laneQueue"] = priorityCounts(h.lanes.Waiting())
+	}
+	if queued := h.webhooks.QueuedSends(); queued != nil {
+		vars["discordSendQueue"] = priorityCounts(queued)
+	}
+	return vars
+}
+
+// Close shuts down the source if it supports closing
+func (h *Hub) Close() {
+	h.logger.Println("ChainKillChecker closing.")
+	if c, ok := h.source.(interface{ Close() }); ok {
+		c.Close()
+	}
+}
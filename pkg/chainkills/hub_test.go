@@ -0,0 +1,62 @@
+package chainkills
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/guarzo/eve-chainkills/internal/supervise"
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+)
+
+func TestDispatchCountsEachKillOnce(t *testing.T) {
+	log := logger.Slog(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	h := &Hub{logger: log, supervisor: supervise.New(log), seen: newKillRing(10), activity: newGalaxyActivity()}
+	dispatch := h.dispatch(context.Background())
+
+	// the same kill on two subscribed channels, then another kill
+	dispatch([]byte(`{"killmail_id":1,"solar_system_id":30000142,"victim":{},"attackers":[]}`))
+	dispatch([]byte(`{"killmail_id":1,"solar_system_id":30000142,"victim":{},"attackers":[]}`))
+	dispatch([]byte(`{"killmail_id":2,"solar_system_id":30000142,"victim":{},"attackers":[]}`))
+	h.wg.Wait()
+
+	if got := h.feed.messages.Load(); got != 2 {
+		t.Errorf("received %d kills, want 2", got)
+	}
+	if got := h.feed.duplicates.Load(); got != 1 {
+		t.Errorf("counted %d duplicates, want 1", got)
+	}
+}
+
+func TestNewHubRejectsInstanceHeartbeatAndDowntime(t *testing.T) {
+	log := logger.Slog(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	for _, tc := range []struct {
+		config, err string
+	}{
+		{`{"heartbeat":{"url":"http://kuma.test/push"},"instances":[{"name":"a"},{"name":"b","heartbeat":{"url":"http://kuma.test/other"}}]}`, "instance b: heartbeat is top-level only"},
+		{`{"instances":[{"name":"a","downtime":{"disabled":true}},{"name":"b"}]}`, "instance a: downtime is top-level only"},
+	} {
+		config, err := ParseConfig([]byte(tc.config))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := NewHub(log, config); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("NewHub(%s) = %v, want %q", tc.config, err, tc.err)
+		}
+	}
+
+	// instances inherit the top-level settings, which the hub runs with
+	config, err := ParseConfig([]byte(`{"heartbeat":{"url":"http://kuma.test/push"},"downtime":{"start":"12:00"},"instances":[{"name":"a"},{"name":"b"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := NewHub(log, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.heartbeat.URL != "http://kuma.test/push" || h.downtime.Start != "12:00" {
+		t.Errorf("hub heartbeat %+v and downtime %+v, want the top-level ones", h.heartbeat, h.downtime)
+	}
+}
@@ -0,0 +1,201 @@
+package chainkills
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/internal/attribution"
+	"github.com/guarzo/eve-chainkills/internal/zkill"
+	"github.com/guarzo/eve-chainkills/pkg/killmail"
+	"github.com/guarzo/eve-chainkills/pkg/notify"
+)
+
+// ImportOptions selects the history Import pulls from zKillboard
+type ImportOptions struct {
+	// Entity is a zKillboard modifier, "corporationID", "allianceID" or
+	// "characterID"; empty guesses from the ID like insightTrackedIds
+	Entity string
+	// ID is the entity to import; 0 imports every tracked ID
+	ID int
+	// Since is the oldest kill time to import
+	Since time.Time
+	// ESIGap spaces ESI requests out; defaults to 250ms
+	ESIGap time.Duration
+}
+
+// ImportResult counts what Import did
+type ImportResult struct {
+	Listed   int // kills zKillboard listed
+	Skipped  int // already in the store
+	Failed   int // ESI couldn't provide the details
+	Imported int // kills with records written
+	Records  int
+}
+
+// Import pages through zKillboard's history of the tracked entities month by
+// month, fetches each kill from ESI, and writes attribution records for our
+// pilots on the attacking side, so leaderboards start with history. Pilots are
+// ours when they are map characters or in the imported entity. Kills already
+// in the store are skipped, so an interrupted import can be run again.
+func (h *Hub) Import(ctx context.Context, opts ImportOptions) (ImportResult, error) {
+	var res ImportResult
+	if h.attribution == nil {
+		return res, errors.New("attribution is not enabled; set attribution.path")
+	}
+	if opts.Since.IsZero() || opts.Since.After(time.Now()) {
+		return res, errors.New("import needs a since date in the past")
+	}
+	if opts.ESIGap <= 0 {
+		opts.ESIGap = 250 * time.Millisecond
+	}
+	ck := h.checkers[0]
+
+	type query struct {
+		entity string
+		id     int
+	}
+	var queries []query
+	if opts.ID != 0 {
+		entity := opts.Entity
+		if entity == "" {
+			entity = entityType(opts.ID)
+		}
+		queries = append(queries, query{entity, opts.ID})
+	} else {
+		for _, id := range ck.config.InsightTrackedIds {
+			queries = append(queries, query{entityType(id), id})
+		}
+		for _, id := range ck.config.TrackedCorporationIds {
+			queries = append(queries, query{"corporationID", id})
+		}
+		for _, id := range ck.config.TrackedAllianceIds {
+			queries = append(queries, query{"allianceID", id})
+		}
+		for _, id := range ck.config.TrackedCharacterIds {
+			queries = append(queries, query{"characterID", int(id)})
+		}
+	}
+	if len(queries) == 0 {
+		return res, errors.New("nothing to import: no entity given and no tracked IDs")
+	}
+
+	existing, err := attribution.Read(h.attribution.Path(), opts.Since)
+	if err != nil {
+		return res, fmt.Errorf("read attribution store: %w", err)
+	}
+	done := map[int64]bool{}
+	for _, r := range existing {
+		done[r.KillID] = true
+	}
+	ours := map[int64]killmail.MapCharacter{}
+	if chars, err := ck.mapAPI.Characters(ctx); err != nil {
+		ck.logger.Printf("[Import] Error getting map characters, counting only the imported entities' pilots: %v", err)
+	} else {
+		for _, mc := range chars {
+			ours[mc.CharacterId] = mc
+		}
+	}
+
+	for _, q := range queries {
+		ck.logger.Printf("[Import] %s %d since %s", q.entity, q.id, opts.Since.Format("2006-01-02"))
+		kills, err := listSince(ctx, ck.http, q.entity, q.id, opts.Since)
+		res.Listed += len(kills)
+		if err != nil {
+			return res, fmt.Errorf("list %s %d: %w", q.entity, q.id, err)
+		}
+		for i, zm := range kills {
+			if i > 0 && i%100 == 0 {
+				ck.logger.Printf("[Import] %s %d: %d of %d kills", q.entity, q.id, i, len(kills))
+			}
+			if done[zm.KillmailID] {
+				res.Skipped++
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return res, ctx.Err()
+			case <-time.After(opts.ESIGap):
+			}
+			if err := ck.fillKillmail(ctx, &zm); err != nil {
+				ck.logger.Printf("[Import] Error fetching kill %d: %v", zm.KillmailID, err)
+				res.Failed++
+				continue
+			}
+			done[zm.KillmailID] = true
+			if zm.KillmailTime.Before(opts.Since) {
+				continue
+			}
+			records := importRecords(ck.config.Name, zm, q.entity, q.id, ours)
+			if len(records) == 0 {
+				continue
+			}
+			if err := h.attribution.Write(records...); err != nil {
+				return res, fmt.Errorf("write attribution records: %w", err)
+			}
+			res.Imported++
+			res.Records += len(records)
+		}
+	}
+	return res, nil
+}
+
+// listSince pages through an entity's kills one month at a time, from the
+// current month back to since's
+func listSince(ctx context.Context, client *http.Client, entity string, id int, since time.Time) ([]killmail.ZkillMail, error) {
+	var kills []killmail.ZkillMail
+	first := time.Date(since.Year(), since.Month(), 1, 0, 0, 0, 0, time.UTC)
+	now := time.Now().UTC()
+	for month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC); !month.Before(first); month = month.AddDate(0, -1, 0) {
+		for page := 1; ; page++ {
+			select {
+			case <-ctx.Done():
+				return kills, ctx.Err()
+			case <-time.After(zkillRequestGap):
+			}
+			batch, more, err := zkill.MonthPage(ctx, client, zkill.HistoryURL, entity, id, month.Year(), month.Month(), page)
+			if err != nil {
+				return kills, err
+			}
+			kills = append(kills, batch...)
+			if !more {
+				break
+			}
+		}
+	}
+	return kills, nil
+}
+
+// importRecords builds the attribution records of one historical kill
+func importRecords(instance string, zm killmail.ZkillMail, entity string, id int, ours map[int64]killmail.MapCharacter) []attribution.Record {
+	var records []attribution.Record
+	for _, att := range zm.Attackers {
+		if att.CharacterID == 0 {
+			continue
+		}
+		mc, onMap := ours[att.CharacterID]
+		inEntity := entity == "corporationID" && att.CorporationID == id ||
+			entity == "allianceID" && att.AllianceID == id ||
+			entity == "characterID" && att.CharacterID == int64(id)
+		if !onMap && !inEntity {
+			continue
+		}
+		records = append(records, attribution.Record{
+			Time:          zm.KillmailTime,
+			KillID:        zm.KillmailID,
+			Instance:      instance,
+			Kind:          string(notify.KindCorpKill),
+			SystemID:      zm.SolarSystemID,
+			CharacterID:   att.CharacterID,
+			Name:          mc.Name,
+			CorporationID: att.CorporationID,
+			ShipTypeID:    att.ShipTypeID,
+			Damage:        att.DamageDone,
+			FinalBlow:     att.FinalBlow,
+			Value:         zm.ZKB.TotalValue,
+		})
+	}
+	return records
+}
@@ -0,0 +1,125 @@
+package chainkills
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Info message severities, each routed and rate limited on its own
+const (
+	SeverityLifecycle = "lifecycle"
+	SeverityWarning   = "warning"
+	SeverityError     = "error"
+)
+
+// infoChannel tracks what one severity has sent, to limit its rate and fold repeats
+type infoChannel struct {
+	mu sync.Mutex
+	// last is the last message posted and repeats how often it came again since
+	last    string
+	repeats int
+	// sent are the send times within the last hour
+	sent []time.Time
+	// dropped counts messages held back by the rate limit since the last send
+	dropped int
+}
+
+// infoChannels holds a checker's channels by severity
+type infoChannels struct {
+	config   InfoChannelsConfig
+	channels map[string]*infoChannel
+}
+
+func newInfoChannels(config InfoChannelsConfig) *infoChannels {
+	return &infoChannels{config: config, channels: map[string]*infoChannel{
+		SeverityLifecycle: {},
+		SeverityWarning:   {},
+		SeverityError:     {},
+	}}
+}
+
+// channelConfig returns the settings for a severity
+func (ic *infoChannels) channelConfig(severity string) InfoChannelConfig {
+	switch severity {
+	case SeverityWarning:
+		return ic.config.Warnings
+	case SeverityError:
+		return ic.config.Errors
+	}
+	return ic.config.Lifecycle
+}
+
+// admit decides whether a message goes out now and returns it with notes
+// about what was held back before it. Lifecycle messages aren't folded, so
+// a command asked twice is answered twice.
+func (ic *infoChannels) admit(severity, message string, now time.Time) (string, bool) {
+	ch := ic.channels[severity]
+	limit := ic.channelConfig(severity).MaxPerHour
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	if severity != SeverityLifecycle && message == ch.last {
+		ch.repeats++
+		return "", false
+	}
+
+	cutoff := now.Add(-time.Hour)
+	kept := ch.sent[:0]
+	for _, t := range ch.sent {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	ch.sent = kept
+	if limit > 0 && len(ch.sent) >= limit {
+		ch.dropped++
+		return "", false
+	}
+
+	var notes string
+	if ch.repeats > 0 {
+		notes += fmt.Sprintf("(last message repeated %s)\n", plural(ch.repeats, "time"))
+	}
+	if ch.dropped > 0 {
+		notes += fmt.Sprintf("(%d messages held back by the rate limit)\n", ch.dropped)
+	}
+	ch.last, ch.repeats, ch.dropped = message, 0, 0
+	ch.sent = append(ch.sent, now)
+	return notes + message, true
+}
+
+// infoWebhook returns the webhook a severity posts to
+func (ck *Checker) infoWebhook(severity string) (string, string) {
+	c := ck.info.channelConfig(severity)
+	if c.DiscordWebhookId != "" {
+		return c.DiscordWebhookId, c.DiscordWebhookToken
+	}
+	return ck.config.DiscordInfoWebhookId, ck.config.DiscordInfoWebhookToken
+}
+
+// sendInfo posts a message to its severity's webhook, unless it repeats the
+// last one or the severity is over its rate limit
+func (ck *Checker) sendInfo(ctx context.Context, severity, messageBody string) {
+	text, ok := ck.info.admit(severity, messageBody, time.Now())
+	if !ok {
+		ck.logger.Printf("Not sending %s message (repeat or rate limited): %s", severity, messageBody)
+		return
+	}
+	ck.logger.Printf("Sending %s message: %s", severity, messageBody)
+	id, token := ck.infoWebhook(severity)
+	if err := ck.webhooks.SendWebhook(ctx, ck.logger, id, token, text, nil); err != nil {
+		ck.logger.Printf("Error sending %s message: %v", severity, err)
+	}
+}
+
+// sendInfoMessage posts a lifecycle message
+func (ck *Checker) sendInfoMessage(ctx context.Context, messageBody string) {
+	ck.sendInfo(ctx, SeverityLifecycle, messageBody)
+}
+
+// sendWarningMessage posts a warning
+func (ck *Checker) sendWarningMessage(ctx context.Context, messageBody string) {
+	ck.sendInfo(ctx, SeverityWarning, messageBody)
+}
@@ -0,0 +1,84 @@
+package chainkills
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestInfoFoldsRepeats(t *testing.T) {
+	ic := newInfoChannels(InfoChannelsConfig{})
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	for i, tc := range []struct {
+		severity, message string
+		want              string
+		sent              bool
+	}{
+		{SeverityError, "Error updateSystems", "Error updateSystems", true},
+		{SeverityError, "Error updateSystems", "", false},
+		{SeverityError, "Error updateSystems", "", false},
+		// repeats are per severity
+		{SeverityWarning, "Error updateSystems", "Error updateSystems", true},
+		{SeverityError, "Error updateCharacters", "(last message repeated 2 times)\nError updateCharacters", true},
+		{SeverityError, "Error updateSystems", "Error updateSystems", true},
+		// lifecycle messages, e.g. command replies, are never folded
+		{SeverityLifecycle, "tracked: [1]", "tracked: [1]", true},
+		{SeverityLifecycle, "tracked: [1]", "tracked: [1]", true},
+	} {
+		got, sent := ic.admit(tc.severity, tc.message, now.Add(time.Duration(i)*time.Second))
+		if got != tc.want || sent != tc.sent {
+			t.Errorf("%d: admit(%s, %q) = %q, %v, want %q, %v", i, tc.severity, tc.message, got, sent, tc.want, tc.sent)
+		}
+	}
+}
+
+func TestInfoRateLimit(t *testing.T) {
+	ic := newInfoChannels(InfoChannelsConfig{Errors: InfoChannelConfig{MaxPerHour: 2}})
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 5; i++ {
+		_, sent := ic.admit(SeverityError, "error "+string(rune('a'+i)), now.Add(time.Duration(i)*time.Minute))
+		if want := i < 2; sent != want {
+			t.Errorf("error %d sent = %v, want %v", i, sent, want)
+		}
+	}
+	// other severities have their own limits
+	if _, sent := ic.admit(SeverityWarning, "warning", now.Add(5*time.Minute)); !sent {
+		t.Error("warning held back by the error limit")
+	}
+
+	// an hour after the first send there's room again, and the held back messages are counted
+	got, sent := ic.admit(SeverityError, "error f", now.Add(time.Hour+time.Second))
+	if !sent || got != "(3 messages held back by the rate limit)\nerror f" {
+		t.Errorf("admit after the hour = %q, %v, want it sent with the held back count", got, sent)
+	}
+	if _, sent := ic.admit(SeverityError, "error g", now.Add(time.Hour+2*time.Second)); sent {
+		t.Error("sent a third message within the hour of the second")
+	}
+}
+
+func TestInfoRouting(t *testing.T) {
+	_, ck, transport := newCommandTest(t, &Config{InfoChannels: InfoChannelsConfig{
+		Errors: InfoChannelConfig{DiscordWebhookId: "ops", DiscordWebhookToken: "ops-token"},
+	}})
+	for _, tc := range []struct{ severity, id, token string }{
+		{SeverityLifecycle, "1", "token"},
+		{SeverityWarning, "1", "token"},
+		{SeverityError, "ops", "ops-token"},
+	} {
+		if id, token := ck.infoWebhook(tc.severity); id != tc.id || token != tc.token {
+			t.Errorf("%s goes to %s/%s, want %s/%s", tc.severity, id, token, tc.id, tc.token)
+		}
+	}
+
+	// repeated errors go out once, then the count comes with the next message
+	ctx := context.Background()
+	ck.sendInfo(ctx, SeverityError, "Error updateSystems")
+	ck.sendInfo(ctx, SeverityError, "Error updateSystems")
+	ck.sendInfo(ctx, SeverityError, "map is back")
+	replies := transport.take()
+	if len(replies) != 2 || replies[1] != "(last message repeated 1 time)\nmap is back" {
+		t.Errorf("sent %q, want the error once, then the repeat count", replies)
+	}
+}
@@ -0,0 +1,235 @@
+package chainkills
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/internal/zkill"
+	"github.com/guarzo/eve-chainkills/internal/zkillstats"
+	"github.com/guarzo/eve-chainkills/pkg/killmail"
+)
+
+// intelWindow is how far back intel looks for kills
+const intelWindow = 24 * time.Hour
+
+// intelDetailKills is how many of the newest kills are looked up on ESI for
+// their time and the groups involved
+const intelDetailKills = 10
+
+// IntelGroup is a corporation or alliance seen on a system's recent kills
+type IntelGroup struct {
+	ID         int
+	Name       string
+	IsAlliance bool
+	// Kills counts the looked-up kills it was on, on either side
+	Kills int
+}
+
+// IntelReport sums up recent activity in one system
+type IntelReport struct {
+	SystemID int
+	// System is the map alias, or the name or ID asked for
+	System string
+	// Kills and ISK are zKillboard's for the last 24 hours
+	Kills int
+	ISK   float64
+	// LastKill is when the newest kill happened, or zero without any
+	LastKill time.Time
+	// LastHour counts kills in the last hour; from the galaxy activity counts
+	// when they're kept, otherwise from the looked-up kills
+	LastHour int
+	// Groups are those on the newest kills, most kills first
+	Groups []IntelGroup
+	// Stats are zKillboard's statistics for the system; nil when they couldn't be fetched
+	Stats *zkillstats.Stats
+}
+
+// String renders the report in a few lines for Discord and the CLI
+func (r IntelReport) String() string {
+	lines := []string{fmt.Sprintf("Intel for %s (%d)", r.System, r.SystemID)}
+	if r.Kills == 0 {
+		lines = append(lines, "No kills in the last 24 hours")
+	} else {
+		lines = append(lines, fmt.Sprintf("Last 24 hours: %s, %s destroyed; %d in the last hour",
+			plural(r.Kills, "kill"), killmail.FormatISKValue(r.ISK), r.LastHour))
+	}
+	if !r.LastKill.IsZero() {
+		lines = append(lines, fmt.Sprintf("Last kill: %s ago (%s)",
+			time.Since(r.LastKill).Truncate(time.Minute), killmail.FormatEVETime(r.LastKill)))
+	}
+	if len(r.Groups) > 0 {
+		groups := make([]string, len(r.Groups))
+		for i, g := range r.Groups {
+			name := g.Name
+			if name == "" {
+				name = strconv.Itoa(g.ID)
+			}
+			groups[i] = fmt.Sprintf("%s (%d)", name, g.Kills)
+		}
+		lines = append(lines, "Seen: "+strings.Join(groups, ", "))
+	}
+	if r.Stats != nil {
+		lines = append(lines, "zKillboard: "+r.Stats.Summary())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// plural renders a count with its noun, e.g. "1 kill" or "12 kills"
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// Intel reports recent kills in a system, given by map alias, name or ID, for
+// the named instance (optional with only one)
+func (h *Hub) Intel(ctx context.Context, instance, system string) (IntelReport, error) {
+	ck, err := h.instance(instance)
+	if err != nil {
+		return IntelReport{}, err
+	}
+	return ck.intel(ctx, system)
+}
+
+func (ck *Checker) intel(ctx context.Context, system string) (IntelReport, error) {
+	id, name, err := ck.lookupSystem(ctx, system)
+	if err != nil {
+		return IntelReport{}, err
+	}
+	report := IntelReport{SystemID: id, System: name}
+
+	kills, err := zkill.Recent(ctx, ck.http, zkill.HistoryURL, "systemID", id, intelWindow)
+	if err != nil && len(kills) == 0 {
+		return report, fmt.Errorf("listing kills on zKillboard: %w", err)
+	}
+	report.Kills = len(kills)
+	for _, zm := range kills {
+		report.ISK += zm.ZKB.TotalValue
+	}
+
+	// zKillboard lists the newest first
+	groups := map[int]*IntelGroup{}
+	hourAgo := time.Now().Add(-time.Hour)
+	for i := range kills[:min(len(kills), intelDetailKills)] {
+		zm := kills[i]
+		if err := ck.fillKillmail(ctx, &zm); err != nil {
+			ck.logger.Printf("[Intel] Error fetching kill %d: %v", zm.KillmailID, err)
+			continue
+		}
+		if zm.KillmailTime.After(report.LastKill) {
+			report.LastKill = zm.KillmailTime
+		}
+		if zm.KillmailTime.After(hourAgo) {
+			report.LastHour++
+		}
+		for _, g := range killGroups(zm) {
+			if groups[g.ID] == nil {
+				groups[g.ID] = &IntelGroup{ID: g.ID, IsAlliance: g.IsAlliance}
+			}
+			groups[g.ID].Kills++
+		}
+	}
+	if ck.activity != nil {
+		report.LastHour = ck.activity.since(hourAgo)[id]
+	}
+	report.Groups = ck.namedGroups(ctx, groups)
+
+	if stats, err := ck.intelStats.System(ctx, id); err == nil {
+		report.Stats = &stats
+	}
+	return report, nil
+}
+
+// killGroups lists the alliance, or else corporation, of the victim and of
+// every attacker, once each
+func killGroups(zm killmail.ZkillMail) []IntelGroup {
+	seen := map[int]bool{}
+	var out []IntelGroup
+	add := func(corpID, allianceID int) {
+		g := IntelGroup{ID: corpID}
+		if allianceID > 0 {
+			g = IntelGroup{ID: allianceID, IsAlliance: true}
+		}
+		if g.ID > 0 && !seen[g.ID] {
+			seen[g.ID] = true
+			out = append(out, g)
+		}
+	}
+	add(zm.Victim.CorporationID, zm.Victim.AllianceID)
+	for _, att := range zm.Attackers {
+		add(att.CorporationID, att.AllianceID)
+	}
+	return out
+}
+
+// namedGroups sorts the groups by kills and names the top ones
+func (ck *Checker) namedGroups(ctx context.Context, groups map[int]*IntelGroup) []IntelGroup {
+	out := make([]IntelGroup, 0, len(groups))
+	for _, g := range groups {
+		out = append(out, *g)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Kills != out[j].Kills {
+			return out[i].Kills > out[j].Kills
+		}
+		return out[i].ID < out[j].ID
+	})
+	out = out[:min(len(out), 8)]
+	resolver, ok := ck.esi.(affiliationResolver)
+	if !ok || len(out) == 0 {
+		return out
+	}
+	ids := make([]int, len(out))
+	for i, g := range out {
+		ids[i] = g.ID
+	}
+	names, err := resolver.Names(ctx, ids)
+	if err != nil {
+		ck.logger.Printf("[Intel] Error resolving group names: %v", err)
+	}
+	for i := range out {
+		out[i].Name = names[out[i].ID]
+	}
+	return out
+}
+
+// lookupSystem finds a system by map alias, ID or name, returning its ID and
+// how to show it
+func (ck *Checker) lookupSystem(ctx context.Context, query string) (int, string, error) {
+	systems := ck.state().systems
+	if len(systems) == 0 {
+		// not started, e.g. from the CLI; read the map directly
+		var err error
+		if systems, err = ck.mapAPI.Systems(ctx); err != nil {
+			ck.logger.Printf("[Intel] Error getting map systems, aliases won't resolve: %v", err)
+		}
+	}
+	if id, err := strconv.Atoi(query); err == nil {
+		for _, sys := range systems {
+			if sys.SystemId == id && sys.Alias != "" {
+				return id, sys.Alias, nil
+			}
+		}
+		return id, query, nil
+	}
+	for _, sys := range systems {
+		if strings.EqualFold(sys.Alias, query) {
+			return sys.SystemId, sys.Alias, nil
+		}
+	}
+	resolver, ok := ck.esi.(systemResolver)
+	if !ok {
+		return 0, "", errors.New("the ESI client cannot look up names; use the system ID or a map alias")
+	}
+	id, err := resolver.SystemID(ctx, query)
+	if err != nil {
+		return 0, "", fmt.Errorf("could not resolve system %q: %w", query, err)
+	}
+	return id, query, nil
+}
@@ -0,0 +1,32 @@
+package chainkills
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/guarzo/eve-chainkills/pkg/killmail"
+)
+
+// defaultMapLinkTemplate opens the map's page for the slug at the system
+const defaultMapLinkTemplate = "{base}/{slug}?system={systemId}"
+
+// mapLink returns the link to the mapping tool's UI at sys, or "" when map links are off
+func (ck *Checker) mapLink(sys killmail.SystemInfo) string {
+	ml := ck.config.MapLinks
+	if !ml.Enabled {
+		return ""
+	}
+	tmpl := ml.URLTemplate
+	if tmpl == "" {
+		tmpl = defaultMapLinkTemplate
+	}
+	// the UI is served from the API's host, without its /api path
+	base := strings.TrimSuffix(strings.TrimSuffix(ck.config.APIBaseUrl, "/"), "/api")
+	return strings.NewReplacer(
+		"{base}", base,
+		"{slug}", url.PathEscape(ck.config.APISlug),
+		"{systemId}", strconv.Itoa(sys.SystemId),
+		"{alias}", url.QueryEscape(sys.Alias),
+	).Replace(tmpl)
+}
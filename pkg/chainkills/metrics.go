@@ -0,0 +1,167 @@
+package chainkills
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/internal/schema"
+)
+
+// Error categories in MetricsSummary.Errors
+const (
+	ErrorsSink     = "sink"     // a sink failed to deliver
+	ErrorsPipeline = "pipeline" // a kill failed in a pipeline stage
+	ErrorsMap      = "map"      // the map API failed
+	ErrorsESI      = "esi"      // an ESI request failed
+	ErrorsDecode   = "decode"   // an upstream payload failed to decode
+)
+
+// MetricsSummary is an instance's counters since start and over the last hour
+type MetricsSummary struct {
+	Instance string           `json:"instance,omitempty"`
+	Uptime   string           `json:"uptime"`
+	Seen     int64            `json:"seen"`
+	Matched  int64            `json:"matched"`
+	Sent     int64            `json:"sent"`
+	Errors   map[string]int64 `json:"errors"`
+	LastHour MetricsWindow    `json:"last_hour"`
+}
+
+// MetricsWindow counts a rolling window; errors are summed over every category
+// the checker counts itself, i.e. not ESI and decode failures
+type MetricsWindow struct {
+	Seen    int64 `json:"seen"`
+	Matched int64 `json:"matched"`
+	Sent    int64 `json:"sent"`
+	Errors  int64 `json:"errors"`
+}
+
+// rollingCounter counts events over the last hour in one-minute buckets
+type rollingCounter struct {
+	mu      sync.Mutex
+	buckets [60]int64
+	minutes [60]int64 // the unix minute each bucket holds
+}
+
+func (rc *rollingCounter) add(n int64) {
+	now := time.Now().Unix() / 60
+	i := now % 60
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.minutes[i] != now {
+		rc.minutes[i], rc.buckets[i] = now, 0
+	}
+	rc.buckets[i] += n
+}
+
+func (rc *rollingCounter) lastHour() int64 {
+	now := time.Now().Unix() / 60
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	var sum int64
+	for i, m := range rc.minutes {
+		if now-m < 60 {
+			sum += rc.buckets[i]
+		}
+	}
+	return sum
+}
+
+// Metrics returns the checker's counters
+func (ck *Checker) Metrics() MetricsSummary {
+	st := ck.stats
+	errs := st.errorCounts()
+	if es, ok := ck.esi.(esiStats); ok {
+		if _, failures := es.Stats(); failures > 0 {
+			errs[ErrorsESI] = failures
+		}
+	}
+	// decode failures are process-wide, not per instance
+	var decode int64
+	for _, s := range schema.Stats() {
+		decode += s.Failures
+	}
+	if decode > 0 {
+		errs[ErrorsDecode] = decode
+	}
+	return MetricsSummary{
+		Instance: ck.config.Name,
+		Uptime:   formatUptime(time.Since(st.started)),
+		Seen:     st.processed.Load(),
+		Matched:  st.chainKills.Load() + st.corpKills.Load() + st.corpLosses.Load() + st.locations.Load() + st.dangers.Load(),
+		Sent:     st.sent.Load(),
+		Errors:   errs,
+		LastHour: MetricsWindow{
+			Seen:    st.seenHour.lastHour(),
+			Matched: st.matchedHour.lastHour(),
+			Sent:    st.sentHour.lastHour(),
+			Errors:  st.errorsHour.lastHour(),
+		},
+	}
+}
+
+// Metrics returns every instance's counters
+func (h *Hub) Metrics() []MetricsSummary {
+	out := make([]MetricsSummary, len(h.checkers))
+	for i, ck := range h.checkers {
+		out[i] = ck.Metrics()
+	}
+	return out
+}
+
+// String renders the summary as one line, e.g. for the periodic log
+func (m MetricsSummary) String() string {
+	s := fmt.Sprintf("up %s: %d seen (%d last hour), %d matched (%d), %d sent (%d), errors: %s (%d last hour)",
+		m.Uptime, m.Seen, m.LastHour.Seen, m.Matched, m.LastHour.Matched, m.Sent, m.LastHour.Sent, formatErrors(m.Errors), m.LastHour.Errors)
+	if m.Instance != "" {
+		s = m.Instance + " " + s
+	}
+	return s
+}
+
+func formatErrors(errs map[string]int64) string {
+	if len(errs) == 0 {
+		return "none"
+	}
+	names := make([]string, 0, len(errs))
+	for name := range errs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		names[i] = fmt.Sprintf("%s %d", name, errs[name])
+	}
+	return strings.Join(names, ", ")
+}
+
+// metricsLogInterval is how often the metrics line is logged, or 0 when it is off
+func (h *Hub) metricsLogInterval() time.Duration {
+	mins := h.checkers[0].config.Status.LogMinutes
+	if mins < 0 {
+		return 0
+	}
+	if mins == 0 {
+		mins = 15
+	}
+	return time.Duration(mins) * time.Minute
+}
+
+// logMetrics logs every instance's metrics line until ctx is done
+func (h *Hub) logMetrics(ctx context.Context) {
+	ticker := time.NewTicker(h.metricsLogInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for _, m := range h.Metrics() {
+			h.logger.Printf("[Metrics] %s", m)
+		}
+	}
+}
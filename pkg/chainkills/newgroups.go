@@ -0,0 +1,107 @@
+package chainkills
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/pkg/killmail"
+	"github.com/guarzo/eve-chainkills/pkg/notify"
+)
+
+// groupTracker remembers when each attacking corp/alliance was last seen in the chain
+type groupTracker struct {
+	window  time.Duration
+	warmup  time.Duration
+	started time.Time
+
+	mu        sync.Mutex
+	lastSeen  map[int]time.Time
+	lastPrune time.Time
+}
+
+func newGroupTracker(config NewGroupAlertConfig) *groupTracker {
+	window := 72 * time.Hour
+	if config.WindowHours > 0 {
+		window = time.Duration(config.WindowHours) * time.Hour
+	}
+	warmup := time.Hour
+	if config.WarmupMinutes != 0 {
+		warmup = time.Duration(config.WarmupMinutes) * time.Minute
+	}
+	return &groupTracker{
+		window:   window,
+		warmup:   warmup,
+		started:  time.Now(),
+		lastSeen: map[int]time.Time{},
+	}
+}
+
+// observe records the attacking groups and returns those not seen within the
+// window. Nothing is reported as new during warmup, while the tracker learns
+// which groups are regulars.
+func (gt *groupTracker) observe(groups []notify.Group, now time.Time) []notify.Group {
+	gt.mu.Lock()
+	defer gt.mu.Unlock()
+
+	if now.Sub(gt.lastPrune) > time.Hour {
+		for id, seen := range gt.lastSeen {
+			if now.Sub(seen) > gt.window {
+				delete(gt.lastSeen, id)
+			}
+		}
+		gt.lastPrune = now
+	}
+
+	warm := now.Sub(gt.started) >= gt.warmup
+	var fresh []notify.Group
+	for _, g := range groups {
+		seen, ok := gt.lastSeen[g.ID]
+		if warm && (!ok || now.Sub(seen) > gt.window) {
+			fresh = append(fresh, g)
+		}
+		gt.lastSeen[g.ID] = now
+	}
+	return fresh
+}
+
+// attackingGroups lists each attacker's alliance, or corporation when it has none, once
+func attackingGroups(attackers []killmail.Attacker) []notify.Group {
+	var groups []notify.Group
+	seen := map[int]bool{}
+	for _, att := range attackers {
+		g := notify.Group{ID: att.CorporationID}
+		if att.AllianceID > 0 {
+			g = notify.Group{ID: att.AllianceID, IsAlliance: true}
+		}
+		if g.ID == 0 || seen[g.ID] {
+			continue
+		}
+		seen[g.ID] = true
+		groups = append(groups, g)
+	}
+	return groups
+}
+
+// newGroups returns the attacking groups of a chain kill that are new to the
+// chain, with their names resolved when the ESI client supports it
+func (ck *Checker) newGroups(ctx context.Context, attackers []killmail.Attacker) []notify.Group {
+	fresh := ck.groups.observe(attackingGroups(attackers), time.Now())
+	if len(fresh) == 0 {
+		return nil
+	}
+	if resolver, ok := ck.esi.(affiliationResolver); ok {
+		ids := make([]int, len(fresh))
+		for i, g := range fresh {
+			ids[i] = g.ID
+		}
+		names, err := resolver.Names(ctx, ids)
+		if err != nil {
+			ck.logger.Printf("Error resolving new group names: %v", err)
+		}
+		for i := range fresh {
+			fresh[i].Name = names[fresh[i].ID]
+		}
+	}
+	return fresh
+}
@@ -0,0 +1,51 @@
+package chainkills
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	mapapi "github.com/guarzo/eve-chainkills/internal/map"
+)
+
+// mapOAuth sets up refreshing of the map API token when apiOAuth is configured.
+// Refresh failures are reported to the info webhook, once until a refresh works again.
+func (ck *Checker) mapOAuth() (*mapapi.OAuth, error) {
+	oc := ck.config.APIOAuth
+	if oc.TokenURL == "" {
+		return nil, nil
+	}
+	refreshToken := oc.RefreshToken
+	if oc.TokenFile != "" {
+		saved, err := os.ReadFile(oc.TokenFile)
+		switch {
+		case err == nil && strings.TrimSpace(string(saved)) != "":
+			refreshToken = strings.TrimSpace(string(saved))
+		case err != nil && !errors.Is(err, fs.ErrNotExist):
+			return nil, fmt.Errorf("apiOAuth tokenFile: %w", err)
+		}
+	}
+	if refreshToken == "" {
+		return nil, errors.New("apiOAuth needs a refreshToken or a tokenFile holding one")
+	}
+
+	oauth := mapapi.NewOAuth(oc.TokenURL, oc.ClientID, oc.ClientSecret, refreshToken)
+	oauth.HTTPClient = ck.http
+	oauth.OnRefresh = func(token string) {
+		if oc.TokenFile == "" {
+			ck.mapLogger.Warnf("[mapOAuth] The map API issued a new refresh token; set apiOAuth.tokenFile so it survives a restart")
+			return
+		}
+		if err := os.WriteFile(oc.TokenFile, []byte(token+"\n"), 0o600); err != nil {
+			ck.mapLogger.Errorf("[mapOAuth] Error saving the new refresh token: %v", err)
+		}
+	}
+	oauth.OnError = func(err error) {
+		ck.mapLogger.Errorf("[mapOAuth] %v", err)
+		ck.sendErrorMessage(context.Background(), fmt.Sprintf("Could not renew the map API token, so the map can't be read until it works again: %v", err))
+	}
+	return oauth, nil
+}
@@ -0,0 +1,119 @@
+package chainkills
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Op mode actions for chain alerts while a fleet op is on
+const (
+	OpModeQuiet    = "quiet"    // send them without the mention, push or acknowledgment watch
+	OpModeSuppress = "suppress" // don't send them
+)
+
+// opMode is a declared fleet op; it ends on its own once until passes
+type opMode struct {
+	mu    sync.Mutex
+	until time.Time
+	by    string
+	timer *time.Timer
+}
+
+func (oc OpModeConfig) validate() error {
+	switch oc.Action {
+	case "", OpModeQuiet, OpModeSuppress:
+	default:
+		return fmt.Errorf("opMode.action: unknown value %q", oc.Action)
+	}
+	if oc.DefaultMinutes < 0 || oc.MaxMinutes < 0 {
+		return fmt.Errorf("opMode.defaultMinutes and maxMinutes must not be negative")
+	}
+	return nil
+}
+
+// duration returns how long an op started for minutes lasts, 0 for the default
+func (oc OpModeConfig) duration(minutes int) time.Duration {
+	if minutes <= 0 {
+		minutes = 120
+		if oc.DefaultMinutes > 0 {
+			minutes = oc.DefaultMinutes
+		}
+	}
+	limit := 480
+	if oc.MaxMinutes > 0 {
+		limit = oc.MaxMinutes
+	}
+	if minutes > limit {
+		minutes = limit
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// startOp declares a fleet op for minutes (0 for the default) and returns when it ends
+func (ck *Checker) startOp(minutes int, by string) time.Time {
+	d := ck.config.OpMode.duration(minutes)
+	until := time.Now().Add(d)
+	ck.op.mu.Lock()
+	if ck.op.timer != nil {
+		ck.op.timer.Stop()
+	}
+	ck.op.until, ck.op.by = until, by
+	ck.op.timer = time.AfterFunc(d, func() {
+		ck.logger.Printf("[OpMode] Fleet op ended")
+		ck.sendInfoMessage(context.Background(), "Fleet op mode ended; chain alerts are back to normal.")
+	})
+	ck.op.mu.Unlock()
+	ck.logger.Printf("[OpMode] Fleet op declared by %s until %s", by, until.UTC().Format("15:04"))
+	return until
+}
+
+// endOp ends the fleet op early; it reports whether one was on
+func (ck *Checker) endOp(by string) bool {
+	ck.op.mu.Lock()
+	defer ck.op.mu.Unlock()
+	if ck.op.timer != nil {
+		ck.op.timer.Stop()
+		ck.op.timer = nil
+	}
+	on := time.Now().Before(ck.op.until)
+	ck.op.until = time.Time{}
+	if on {
+		ck.logger.Printf("[OpMode] Fleet op ended by %s", by)
+	}
+	return on
+}
+
+// opUntil returns when the current fleet op ends, or the zero time when none is on
+func (ck *Checker) opUntil() time.Time {
+	ck.op.mu.Lock()
+	defer ck.op.mu.Unlock()
+	if time.Now().Before(ck.op.until) {
+		return ck.op.until
+	}
+	return time.Time{}
+}
+
+// opAction returns what happens to chain alerts right now: "" outside a fleet op
+func (ck *Checker) opAction() string {
+	if ck.opUntil().IsZero() {
+		return ""
+	}
+	if ck.config.OpMode.Action == "" {
+		return OpModeQuiet
+	}
+	return ck.config.OpMode.Action
+}
+
+// opStatus describes the fleet op for replies, e.g. "fleet op by Alice on until 20:15 UTC (quiet)"
+func (ck *Checker) opStatus() string {
+	until := ck.opUntil()
+	if until.IsZero() {
+		return "no fleet op"
+	}
+	ck.op.mu.Lock()
+	by := ck.op.by
+	ck.op.mu.Unlock()
+	return fmt.Sprintf("fleet op by %s on until %s UTC (%s)", by, until.UTC().Format("15:04"), ck.opAction())
+}
@@ -0,0 +1,207 @@
+package chainkills
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/guarzo/eve-chainkills/internal/attribution"
+	"github.com/guarzo/eve-chainkills/internal/audit"
+	"github.com/guarzo/eve-chainkills/internal/discord"
+	"github.com/guarzo/eve-chainkills/internal/esi"
+	"github.com/guarzo/eve-chainkills/internal/filter"
+	"github.com/guarzo/eve-chainkills/internal/killtags"
+	"github.com/guarzo/eve-chainkills/internal/lanes"
+	"github.com/guarzo/eve-chainkills/internal/logging"
+	"github.com/guarzo/eve-chainkills/internal/sso"
+	"github.com/guarzo/eve-chainkills/internal/supervise"
+	"github.com/guarzo/eve-chainkills/pkg/killmail"
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+	"github.com/guarzo/eve-chainkills/pkg/notify"
+	"github.com/guarzo/eve-chainkills/pkg/pipeline"
+)
+
+// ESIClient resolves killmail details and type information from ESI
+type ESIClient interface {
+	GetKillDetails(ctx context.Context, raw []byte) (killmail.FlattenedKillMail, error)
+	TypeInfo(ctx context.Context, typeId int) (string, int, error)
+}
+
+// Match is the outcome of filtering one kill; an empty Kind means no match
+type Match struct {
+	Kind   notify.Kind
+	IsKill bool // for corp kills: a tracked entity was on the attacking side
+	// BothSides marks a corp loss with tracked or mapped pilots among the attackers too
+	BothSides bool
+
+	// System is the matched chain system for chain kills
+	System *killmail.SystemInfo
+	// Reason is shown on the alert and included in webhook/NATS/MQTT payloads;
+	// for unmatched kills it only goes to the audit log
+	Reason notify.MatchReason
+}
+
+// Filter decides whether a kill is a corp kill, a chain kill, or neither
+type Filter interface {
+	Match(zm killmail.ZkillMail, systems []killmail.SystemInfo, mapCharacters []killmail.MapCharacter) Match
+}
+
+// Option configures a Hub created with New
+type Option func(*options)
+
+type options struct {
+	logger     logger.Logger
+	config     *Config
+	configPath string
+	esi        ESIClient
+	filter     Filter
+	sinks      []notify.Sink
+	source     pipeline.Source
+	// killstreamURL replaces zkill.DefaultURL, e.g. with a zkilltest server
+	killstreamURL string
+	supervisor    *supervise.Supervisor
+	// audit is shared by every instance of a hub; nil when disabled
+	audit *audit.Log
+	// attribution is shared like audit; nil when disabled
+	attribution *attribution.Store
+	// tags is shared like audit; nil when disabled
+	tags *killtags.Store
+	// activity is shared like audit; nil when galaxy activity is off
+	activity *galaxyActivity
+	// lanes is shared like audit; nil when priority.workers is -1
+	lanes *lanes.Gate
+	// sso authenticates ESI calls; nil when EVE SSO isn't configured
+	sso *sso.Client
+	// http sends every outbound request, built from the http config unless
+	// WithHTTPClient supplied one; webhooks sends through it to Discord
+	http     *http.Client
+	webhooks *discord.Webhooks
+
+	// levels filters per-component logging when logLevels is configured; raw
+	// is the unfiltered logger the component loggers are derived from
+	levels *logging.Levels
+	raw    logger.Logger
+}
+
+// componentLogger returns the logger for component ("" for general logging),
+// derived from base, which should be unfiltered
+func (o *options) componentLogger(base logger.Logger, component string) logger.Logger {
+	if o.levels == nil {
+		return base
+	}
+	return o.levels.For(base, component)
+}
+
+// rawLogger is the unfiltered logger
+func (o *options) rawLogger() logger.Logger {
+	if o.raw != nil {
+		return o.raw
+	}
+	return o.logger
+}
+
+// WithLogger sets the logger; defaults to slog.Default(). Wrap logrus with
+// logruslogger.New and slog or zap with logger.Slog.
+func WithLogger(l logger.Logger) Option {
+	return func(o *options) { o.logger = l }
+}
+
+// WithConfig uses an already loaded config
+func WithConfig(config *Config) Option {
+	return func(o *options) { o.config = config }
+}
+
+// WithConfigFile loads the config from path when New is called
+func WithConfigFile(path string) Option {
+	return func(o *options) { o.configPath = path }
+}
+
+// WithESIClient replaces the built-in ESI client, e.g. with a caching one
+func WithESIClient(client ESIClient) Option {
+	return func(o *options) { o.esi = client }
+}
+
+// WithHTTPClient sends every outbound request through client instead of one
+// built from the http config
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *options) { o.http = client }
+}
+
+// WithSink adds a sink to every instance, alongside the configured ones
+func WithSink(sink notify.Sink) Option {
+	return func(o *options) { o.sinks = append(o.sinks, sink) }
+}
+
+// WithFilter replaces the built-in tracked-ID and chain matcher
+func WithFilter(f Filter) Option {
+	return func(o *options) { o.filter = f }
+}
+
+// WithSource replaces the zKillboard websocket as the killstream source
+func WithSource(source pipeline.Source) Option {
+	return func(o *options) { o.source = source }
+}
+
+// WithKillstreamURL points the built-in websocket source at another server,
+// e.g. a local stand-in in tests; unlike WithSource it keeps the feed
+// health tracking and reconnects
+func WithKillstreamURL(url string) Option {
+	return func(o *options) { o.killstreamURL = url }
+}
+
+// New creates a Hub from functional options. A config is required, through
+// WithConfig or WithConfigFile; everything else has a default.
+func New(opts ...Option) (*Hub, error) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.logger == nil {
+		o.logger = logger.Default()
+	}
+	if o.config == nil && o.configPath != "" {
+		cfg, err := LoadConfig(o.configPath)
+		if err != nil {
+			return nil, err
+		}
+		o.config = cfg
+	}
+	if o.config == nil {
+		return nil, errors.New("chainkills: no config, use WithConfig or WithConfigFile")
+	}
+	return newHub(o)
+}
+
+// matcherFilter adapts the built-in matcher to the Filter interface
+type matcherFilter struct {
+	matcher *filter.Matcher
+}
+
+func (mf matcherFilter) Match(zm killmail.ZkillMail, systems []killmail.SystemInfo, mapCharacters []killmail.MapCharacter) Match {
+	return toMatch(mf.matcher.Match(zm, systems, mapCharacters))
+}
+
+// toMatch converts the built-in matcher's result
+func toMatch(result filter.Result) Match {
+	reason := notify.MatchReason{Rule: result.Reason.Rule, ID: int64(result.Reason.ID), Text: result.Reason.Text}
+	switch result.Kind {
+	case filter.CorpKill:
+		return Match{Kind: notify.KindCorpKill, IsKill: result.IsKill, BothSides: result.BothSides, Reason: reason}
+	case filter.ChainKill:
+		return Match{Kind: notify.KindChain, System: result.System, Reason: reason}
+	}
+	return Match{Reason: reason}
+}
+
+// esiClient returns the built-in ESI client unless one was supplied
+func (o *options) esiClient(logger logger.Logger) ESIClient {
+	if o.esi != nil {
+		return o.esi
+	}
+	c := esi.NewClient(logger)
+	c.SetHTTPClient(o.http)
+	if o.sso != nil {
+		c.SetAuth(o.sso)
+	}
+	return c
+}
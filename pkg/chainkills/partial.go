@@ -0,0 +1,63 @@
+package chainkills
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/pkg/killmail"
+	"github.com/guarzo/eve-chainkills/pkg/notify"
+	"github.com/guarzo/eve-chainkills/pkg/pipeline"
+)
+
+// enrichBudget is how long enrichment may take per kill, or 0 for no limit
+func (ck *Checker) enrichBudget() time.Duration {
+	switch secs := ck.config.Pipeline.EnrichTimeoutSeconds; {
+	case secs < 0:
+		return 0
+	case secs == 0:
+		return 5 * time.Second
+	default:
+		return time.Duration(secs) * time.Second
+	}
+}
+
+// resolvePartial finishes enriching a kill that was sent partial, without the
+// per-kill budget, and edits the sent message once done; the value update, if
+// any, is scheduled after so it edits the resolved kill
+func (ck *Checker) resolvePartial(ctx context.Context, s notify.EditableSink, ref string, ev *pipeline.Event, n notify.Notification, valueUpdate bool) {
+	// the kill's context ends with its message; keep its values for tracing
+	ctx = context.WithoutCancel(ctx)
+	raw, zm := bytes.Clone(ev.Raw), ev.Zkill
+	go func() {
+		defer ck.supervisor.Recover("partial kill", nil)
+		rctx, cancel := context.WithTimeout(ctx, time.Minute)
+		defer cancel()
+		if resolved, ok := ck.resolveKill(rctx, s, ref, raw, zm, n); ok {
+			n = resolved
+		}
+		if valueUpdate {
+			ck.scheduleValueUpdate(ctx, s, ref, n)
+		}
+	}()
+}
+
+func (ck *Checker) resolveKill(ctx context.Context, s notify.EditableSink, ref string, raw []byte, zm killmail.ZkillMail, n notify.Notification) (notify.Notification, bool) {
+	fkm, err := ck.enrichKill(ctx, n.Kind, n.IsKill, raw)
+	if err != nil {
+		ck.killLogger(ctx).Printf("Error resolving partial kill %d: %v", n.KillMailID, err)
+		return n, false
+	}
+	// names a lookup still failed on keep showing their IDs
+	fkm.FillMissing(zm)
+	ck.trimAttackers(&fkm)
+	n.Kill = &fkm
+	n.SystemAlias = fkm.SystemName
+	n.AttackerCount = fkm.AttackerCount()
+	n.Partial = false
+	ck.killLogger(ctx).Printf("Kill %d resolved; updating %s", n.KillMailID, ref)
+	if err := s.Edit(ctx, ref, n); err != nil {
+		ck.killLogger(ctx).Printf("Error updating the message for kill %d: %v", n.KillMailID, err)
+	}
+	return n, true
+}
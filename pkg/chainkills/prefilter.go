@@ -0,0 +1,64 @@
+package chainkills
+
+import (
+	"context"
+
+	"github.com/guarzo/eve-chainkills/internal/zkill"
+	"github.com/guarzo/eve-chainkills/pkg/pipeline"
+)
+
+// prefilterStage drops kills that can't match before they are decoded. Most
+// of the killstream is elsewhere in New Eden, so it scans the raw message for
+// a tracked corporation, alliance or character, a map character, a chain
+// system, a system a map character is in, a configured location, with
+// triglavianSpace a Pochven system, or with factionWarfare.alert a militia
+// pilot, and only those kills get the full decode. Custom filters see every kill, unless
+// killstream.regionIds or systemIds limit where kills are processed.
+func (ck *Checker) prefilterStage(ctx context.Context, ev *pipeline.Event) (bool, error) {
+	state := ck.state()
+	ix := state.index
+	if state.scope != nil && !ck.inScope(ev, state) {
+		ev.Reason.Text = "prefilter: outside killstream.regionIds and systemIds"
+		return false, nil
+	}
+	mf, builtin := ck.matcher.(matcherFilter)
+	if !builtin || ck.config.Pipeline.DisablePrefilter {
+		return true, nil
+	}
+	keep := zkill.ScanIDs(ev.Raw, func(key []byte, id int64) bool {
+		switch field := string(key); field {
+		case "killmail_id":
+			// for the audit log, should the kill be dropped
+			ev.Zkill.KillmailID = id
+		case "solar_system_id":
+			ev.Zkill.SolarSystemID = int(id)
+			return ck.prefilterSystem(state, int(id))
+		case "locationID":
+			return ck.locationName(id) != ""
+		case "character_id":
+			return mf.matcher.Tracks(field, id) || ix.Mapped(id)
+		case "corporation_id", "alliance_id":
+			return mf.matcher.Tracks(field, id)
+		case "faction_id":
+			return ck.fwAlerts() && ck.militia(int(id))
+		}
+		return false
+	})
+	if !keep {
+		ev.Reason.Text = "prefilter: no tracked ID, map character, chain system, location or militia"
+	}
+	return keep, nil
+}
+
+// prefilterSystem reports whether a kill in the system could alert: it is in
+// the chain or alerted Triglavian space, or with friendlyDanger, a map
+// character is there
+func (ck *Checker) prefilterSystem(state *mapState, id int) bool {
+	if _, ok := state.index.System(id); ok {
+		return true
+	}
+	if ck.triglavianAlert(state, id) != "" {
+		return true
+	}
+	return ck.config.FriendlyDanger.Enabled && state.index.Occupied(id)
+}
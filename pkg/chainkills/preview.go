@@ -0,0 +1,106 @@
+package chainkills
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/guarzo/eve-chainkills/internal/discord"
+	"github.com/guarzo/eve-chainkills/internal/zkill"
+	"github.com/guarzo/eve-chainkills/pkg/notify"
+	"github.com/guarzo/eve-chainkills/pkg/pipeline"
+)
+
+// KillPreview is one past kill rendered the way an instance's Discord webhook
+// would post it
+type KillPreview struct {
+	Instance string
+	Kind     notify.Kind
+	// Matched is false when no rule would have alerted; the kill is then
+	// previewed as a corp kill or loss anyway
+	Matched bool
+	Reason  notify.MatchReason
+	Text    string
+	Embed   *discord.Embed
+}
+
+// Preview fetches a kill from zKillboard and ESI, matches it against every
+// instance's map and tracked IDs, and renders the Discord message it would
+// get. Nothing is sent to any sink.
+func (h *Hub) Preview(ctx context.Context, killID int64) ([]KillPreview, error) {
+	var previews []KillPreview
+	for _, ck := range h.checkers {
+		p, err := ck.preview(ctx, killID)
+		if err != nil {
+			if ck.config.Name != "" {
+				err = fmt.Errorf("instance %s: %w", ck.config.Name, err)
+			}
+			return previews, err
+		}
+		previews = append(previews, p)
+	}
+	return previews, nil
+}
+
+func (ck *Checker) preview(ctx context.Context, killID int64) (KillPreview, error) {
+	if err := ck.loadReplayMap(ctx); err != nil {
+		return KillPreview{}, err
+	}
+	zm, err := zkill.Kill(ctx, ck.http, zkill.HistoryURL, killID)
+	if err != nil {
+		return KillPreview{}, err
+	}
+	if err := ck.fillKillmail(ctx, &zm); err != nil {
+		return KillPreview{}, fmt.Errorf("kill %d from ESI: %w", killID, err)
+	}
+	raw, err := json.Marshal(zm)
+	if err != nil {
+		return KillPreview{}, err
+	}
+
+	st := ck.state()
+	result, location := ck.classify(zm, st.index, st.systems, st.characters)
+	p := KillPreview{Instance: ck.config.Name, Matched: result.Kind != ""}
+	if !p.Matched {
+		result.Kind = notify.KindCorpKill
+		text := "no rule matched, previewed as a corp kill"
+		if result.Reason.Text != "" {
+			text = result.Reason.Text + "; " + text
+		}
+		result.Reason = notify.MatchReason{Text: text}
+	}
+	ev := &pipeline.Event{
+		Raw:       raw,
+		Zkill:     zm,
+		Kind:      result.Kind,
+		IsKill:    result.IsKill,
+		BothSides: result.BothSides,
+		System:    result.System,
+		Location:  location,
+		Reason:    result.Reason,
+	}
+	if _, err := ck.enrichStage(ctx, ev); err != nil {
+		return KillPreview{}, err
+	}
+	if _, err := ck.dryFormatStage(ctx, ev); err != nil {
+		return KillPreview{}, err
+	}
+	ds := ck.discordSink()
+	if ds == nil {
+		return KillPreview{}, errors.New("no Discord sink")
+	}
+	p.Kind, p.Reason = ev.Kind, ev.Reason
+	p.Text, p.Embed = ds.Preview(ev.Notification)
+	return p, nil
+}
+
+// discordSink returns the built-in Discord sink
+func (ck *Checker) discordSink() *notify.DiscordSink {
+	for _, s := range ck.sinks {
+		if ds, ok := s.(*notify.DiscordSink); ok {
+			return ds
+		}
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package chainkills
+
+import (
+	"github.com/guarzo/eve-chainkills/pkg/notify"
+	"github.com/guarzo/eve-chainkills/pkg/pipeline"
+)
+
+// priority sorts a matched kill into its tier: home system, then chain and
+// other alerts, then corp brags; kills held for a batch go last
+func (ck *Checker) priority(ev *pipeline.Event) pipeline.Priority {
+	if home := ck.config.HomeSystemId; home != 0 && ev.Zkill.SolarSystemID == home {
+		return pipeline.PriorityHome
+	}
+	if ck.aggregateDelay(ev.Reason.Rule) > 0 {
+		return pipeline.PriorityDigest
+	}
+	if ev.Kind == notify.KindCorpKill && ev.IsKill {
+		return pipeline.PriorityCorp
+	}
+	return pipeline.PriorityChain
+}
+
+// priorityCounts labels per-priority counts with the tier names
+func priorityCounts(counts []int) map[string]int {
+	labeled := make(map[string]int, len(counts))
+	for p, n := range counts {
+		labeled[pipeline.Priority(p).String()] = n
+	}
+	return labeled
+}
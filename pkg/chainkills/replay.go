@@ -0,0 +1,141 @@
+package chainkills
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/internal/zkill"
+	"github.com/guarzo/eve-chainkills/pkg/pipeline"
+)
+
+// ReplayOptions controls Hub.Replay
+type ReplayOptions struct {
+	// Speed replays kills at this multiple of the pace they happened at, by
+	// killmail_time; 0 replays them as fast as possible
+	Speed float64
+	// Enrich looks kills up on ESI as the live pipeline does; without it they
+	// keep zKillboard's copy, with IDs for names
+	Enrich bool
+}
+
+// ReplayResult sums up a replay
+type ReplayResult struct {
+	// Messages counts the lines read, Matched the kills any instance would
+	// have alerted on and Errors the pipeline failures
+	Messages int
+	Matched  int
+	Errors   int
+	Elapsed  time.Duration
+	// Stages is the time and outcome of each stage, over every instance
+	Stages map[string]pipeline.StageStats
+}
+
+// Replay runs an archived killstream, one message per line, through every
+// instance's pipeline from prefilter to format, for profiling. The map is read
+// once up front; nothing is sent to any sink or info channel, and live state
+// such as auto-ignore, new group tracking and the match counts is left alone.
+func (h *Hub) Replay(ctx context.Context, r io.Reader, opts ReplayOptions) (ReplayResult, error) {
+	metrics := pipeline.NewMetrics()
+	pipelines := make([]*pipeline.Pipeline, len(h.checkers))
+	for i, ck := range h.checkers {
+		if err := ck.loadReplayMap(ctx); err != nil {
+			return ReplayResult{}, err
+		}
+		pipelines[i] = ck.replayPipeline(opts.Enrich)
+		pipelines[i].Use(metrics.Middleware())
+	}
+
+	var res ReplayResult
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), zkill.MaxFrameSize)
+	start := time.Now()
+	var first time.Time
+	for scanner.Scan() {
+		raw := scanner.Bytes()
+		if len(raw) == 0 {
+			continue
+		}
+		if _, killTime := zkill.Peek(raw); opts.Speed > 0 && !killTime.IsZero() {
+			if first.IsZero() {
+				first = killTime
+			}
+			due := start.Add(time.Duration(float64(killTime.Sub(first)) / opts.Speed))
+			select {
+			case <-ctx.Done():
+				return res, ctx.Err()
+			case <-time.After(time.Until(due)):
+			}
+		}
+		res.Messages++
+		matched := false
+		for _, p := range pipelines {
+			ev := &pipeline.Event{Raw: raw, ReceivedAt: time.Now()}
+			if err := p.Run(ctx, ev); err != nil {
+				res.Errors++
+			}
+			matched = matched || ev.Kind != ""
+		}
+		if matched {
+			res.Matched++
+		}
+	}
+	res.Elapsed = time.Since(start)
+	res.Stages = metrics.Snapshot()
+	if err := scanner.Err(); err != nil {
+		return res, fmt.Errorf("read line %d: %w", res.Messages+1, err)
+	}
+	return res, ctx.Err()
+}
+
+// loadReplayMap reads the chain and its characters once; unlike the usual
+// refresh it reports errors to the caller, not Discord
+func (ck *Checker) loadReplayMap(ctx context.Context) error {
+	systems, err := ck.mapAPI.Systems(ctx)
+	if err != nil {
+		return fmt.Errorf("map systems: %w", err)
+	}
+	chars, err := ck.mapAPI.Characters(ctx)
+	if err != nil {
+		ck.logger.Printf("[Replay] Error getting map characters, matching without them: %v", err)
+	}
+	ck.updateState(func(s *mapState) {
+		s.systems = systems
+		s.mapCharacters = chars
+	})
+	if ck.config.TriglavianSpace.Enabled {
+		ck.loadPochven(ctx)
+	}
+	if ck.config.FactionWarfare.Enabled {
+		if err := ck.loadWarzone(ctx); err != nil {
+			ck.logger.Printf("[Replay] Error fetching the warzone, matching without it: %v", err)
+		}
+	}
+	return nil
+}
+
+// replayPipeline is the live pipeline without the map refresh, worker slots
+// and delivery, and with the match and format stages that change no state
+func (ck *Checker) replayPipeline(enrich bool) *pipeline.Pipeline {
+	enrichStage := ck.enrichStage
+	if !enrich {
+		enrichStage = func(ctx context.Context, ev *pipeline.Event) (bool, error) {
+			ev.Kill.FillMissing(ev.Zkill)
+			return true, nil
+		}
+	}
+	dedupSize := ck.config.Pipeline.DedupSize
+	if dedupSize <= 0 {
+		dedupSize = 1000
+	}
+	return pipeline.New(
+		pipeline.NewStage(pipeline.StagePrefilter, ck.prefilterStage),
+		pipeline.Decode(),
+		pipeline.Dedup(dedupSize),
+		pipeline.NewStage(pipeline.StageMatch, ck.dryMatchStage),
+		pipeline.NewStage(pipeline.StageEnrich, enrichStage),
+		pipeline.NewStage(pipeline.StageFormat, ck.dryFormatStage),
+	)
+}
@@ -0,0 +1,109 @@
+package chainkills
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/guarzo/eve-chainkills/internal/discord"
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+	"github.com/guarzo/eve-chainkills/pkg/notify"
+	"github.com/guarzo/eve-chainkills/pkg/pipeline"
+)
+
+// mapTransport answers the map API from canned bodies and records every other request
+type mapTransport struct {
+	endpoints map[string]string
+
+	mu    sync.Mutex
+	other []string
+}
+
+func (mt *mapTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, ok := mt.endpoints[req.URL.Path]
+	if req.URL.Host != "map.test" || !ok {
+		mt.mu.Lock()
+		mt.other = append(mt.other, req.Method+" "+req.URL.String())
+		mt.mu.Unlock()
+		body = `{"id":"1"}`
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+func (mt *mapTransport) requests() []string {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+	return append([]string(nil), mt.other...)
+}
+
+func TestReplayHasNoSideEffects(t *testing.T) {
+	transport := &mapTransport{endpoints: map[string]string{
+		"/api/systems":    `{"data":[{"id":"a","name":"J123456","solar_system_id":31000001}]}`,
+		"/api/characters": `{"data":[{"id":"c","character":{"eve_id":"90000001","name":"Our Pilot","solar_system_id":31000001}}]}`,
+	}}
+	client := &http.Client{Transport: transport}
+	sink := &renderSink{}
+	ck, err := newChecker(&options{
+		logger:   logger.Slog(slog.New(slog.NewTextHandler(io.Discard, nil))),
+		esi:      echoESI{},
+		sinks:    []notify.Sink{sink},
+		http:     client,
+		webhooks: discord.NewWebhooks(client, 0, pipeline.NumPriorities),
+	}, &Config{
+		APIBaseUrl:              "http://map.test/api",
+		APISlug:                 "chain",
+		DiscordInfoWebhookId:    "1",
+		DiscordInfoWebhookToken: "token",
+		AutoIgnore:              AutoIgnoreConfig{Enabled: true, MinKills: 1},
+		NewGroupAlert:           NewGroupAlertConfig{Enabled: true, WarmupMinutes: -1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &Hub{checkers: []*Checker{ck}}
+
+	// map characters kill in a chain system, which would auto-ignore it at once
+	archive := `{"killmail_id":1,"solar_system_id":31000001,"victim":{"corporation_id":98000009},"attackers":[{"character_id":90000001,"corporation_id":98000001}]}
+{"killmail_id":2,"solar_system_id":31000001,"victim":{"corporation_id":98000009},"attackers":[{"character_id":90000001,"corporation_id":98000001}]}
+`
+	res, err := h.Replay(context.Background(), strings.NewReader(archive), ReplayOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Messages != 2 || res.Matched != 2 || res.Errors != 0 {
+		t.Errorf("replay = %+v, want both kills matched as chain kills", res)
+	}
+	if got := transport.requests(); len(got) != 0 {
+		t.Errorf("replay made requests %v, want none beyond the map", got)
+	}
+	if sink.sent != 0 {
+		t.Errorf("replay sent %d notifications", sink.sent)
+	}
+	if ck.autoIgnore.ignored(31000001) {
+		t.Errorf("replay auto-ignored the system")
+	}
+	if n := ck.stats.chainKills.Load(); n != 0 {
+		t.Errorf("replay counted %d chain kills in the live stats", n)
+	}
+	if fresh := ck.groups.observe([]notify.Group{{ID: 98000001}}, ck.groups.started); len(fresh) != 1 {
+		t.Errorf("replay taught the new group tracker the attackers")
+	}
+
+	// the same kill handled live does auto-ignore and announce it, so the above is the replay's doing
+	ck.HandleMessage(context.Background(), []byte(strings.SplitN(archive, "\n", 2)[0]))
+	if !ck.autoIgnore.ignored(31000001) {
+		t.Errorf("live kill didn't auto-ignore the system")
+	}
+	if got := transport.requests(); len(got) != 1 || !strings.Contains(got[0], "discord.com/api/webhooks/1/token") {
+		t.Errorf("live kill made requests %v, want the auto-ignore info message", got)
+	}
+}
@@ -0,0 +1,85 @@
+package chainkills
+
+// routeHome returns the aliases of the systems on the shortest path from
+// systemID to the home system through the chain's connections, both ends
+// included, or nil without a home system or a path to it
+func (ck *Checker) routeHome(systemID int) []string {
+	home := ck.config.HomeSystemId
+	if home == 0 {
+		return nil
+	}
+	st := ck.state()
+	if systemID == home {
+		return []string{st.alias(home)}
+	}
+
+	links := map[int][]int{}
+	for _, c := range st.connections {
+		links[c.Source] = append(links[c.Source], c.Target)
+		links[c.Target] = append(links[c.Target], c.Source)
+	}
+	// breadth-first from the kill, remembering how each system was reached
+	prev := map[int]int{systemID: 0}
+	queue := []int{systemID}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		if cur == home {
+			break
+		}
+		for _, next := range links[cur] {
+			if _, seen := prev[next]; !seen {
+				prev[next] = cur
+				queue = append(queue, next)
+			}
+		}
+	}
+	if _, ok := prev[home]; !ok {
+		return nil
+	}
+
+	var route []string
+	for id := home; id != 0; id = prev[id] {
+		route = append(route, st.alias(id))
+	}
+	// walked back from home, so reverse to start at the kill
+	for i, j := 0, len(route)-1; i < j; i, j = i+1, j-1 {
+		route[i], route[j] = route[j], route[i]
+	}
+	return route
+}
+
+// isKSpace reports whether a solar system is in known space; wormhole
+// systems start at 31000000
+func isKSpace(systemID int) bool {
+	return systemID >= 30000000 && systemID < 31000000
+}
+
+// kspaceExit finds the known-space system nearest systemID through the
+// chain's connections and how many jumps away it is; ok is false when the
+// chain has no way out from there. Pochven and Zarzakh don't count, as their
+// gates don't lead to the rest of known space.
+func (ck *Checker) kspaceExit(systemID int) (exit, jumps int, ok bool) {
+	st := ck.state()
+	links := map[int][]int{}
+	for _, c := range st.connections {
+		links[c.Source] = append(links[c.Source], c.Target)
+		links[c.Target] = append(links[c.Target], c.Source)
+	}
+	dist := map[int]int{systemID: 0}
+	queue := []int{systemID}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		if isKSpace(cur) && st.triglavianSpace(cur) == "" {
+			return cur, dist[cur], true
+		}
+		for _, next := range links[cur] {
+			if _, seen := dist[next]; !seen {
+				dist[next] = dist[cur] + 1
+				queue = append(queue, next)
+			}
+		}
+	}
+	return 0, 0, false
+}
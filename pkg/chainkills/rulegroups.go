@@ -0,0 +1,115 @@
+package chainkills
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrUnknownRuleGroup is returned when toggling a rule group that isn't configured
+var ErrUnknownRuleGroup = errors.New("chainkills: unknown rule group")
+
+// RuleGroup is a named set of match rules and whether its alerts are sent
+type RuleGroup struct {
+	Name    string   `json:"name"`
+	Rules   []string `json:"rules"`
+	Enabled bool     `json:"enabled"`
+}
+
+// ruleGroups tracks which configured rule groups are switched off
+type ruleGroups struct {
+	groups []RuleGroupConfig
+
+	mu       sync.RWMutex
+	disabled map[string]bool
+}
+
+func newRuleGroups(groups []RuleGroupConfig) (*ruleGroups, error) {
+	rg := &ruleGroups{groups: groups, disabled: map[string]bool{}}
+	seen := map[string]bool{}
+	for i, g := range groups {
+		if g.Name == "" || len(g.Rules) == 0 {
+			return nil, fmt.Errorf("ruleGroups[%d] needs a name and rules", i)
+		}
+		if seen[g.Name] {
+			return nil, fmt.Errorf("ruleGroups[%d]: duplicate name %q", i, g.Name)
+		}
+		seen[g.Name] = true
+		rg.disabled[g.Name] = g.Disabled
+	}
+	return rg, nil
+}
+
+// blocking returns the first disabled group holding rule, or ""
+func (rg *ruleGroups) blocking(rule string) string {
+	rg.mu.RLock()
+	defer rg.mu.RUnlock()
+	for _, g := range rg.groups {
+		if !rg.disabled[g.Name] {
+			continue
+		}
+		for _, r := range g.Rules {
+			if r == rule {
+				return g.Name
+			}
+		}
+	}
+	return ""
+}
+
+// set switches a group on or off
+func (rg *ruleGroups) set(name string, enabled bool) error {
+	rg.mu.Lock()
+	defer rg.mu.Unlock()
+	if _, ok := rg.disabled[name]; !ok {
+		return fmt.Errorf("%w %q", ErrUnknownRuleGroup, name)
+	}
+	rg.disabled[name] = !enabled
+	return nil
+}
+
+// list returns the groups in name order
+func (rg *ruleGroups) list() []RuleGroup {
+	rg.mu.RLock()
+	defer rg.mu.RUnlock()
+	out := make([]RuleGroup, len(rg.groups))
+	for i, g := range rg.groups {
+		out[i] = RuleGroup{Name: g.Name, Rules: g.Rules, Enabled: !rg.disabled[g.Name]}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// RuleGroups lists the configured rule groups and whether each is enabled
+func (ck *Checker) RuleGroups() []RuleGroup {
+	return ck.rules.list()
+}
+
+// SetRuleGroupEnabled switches a rule group's alerts on or off until the next
+// restart; it takes effect from the next kill
+func (ck *Checker) SetRuleGroupEnabled(name string, enabled bool) error {
+	if err := ck.rules.set(name, enabled); err != nil {
+		return err
+	}
+	ck.logger.Printf("[RuleGroups] %s enabled: %t", name, enabled)
+	return nil
+}
+
+// ruleGroupsText renders the groups for a command reply, e.g. "brags: on (attacker_tracked)"
+func (ck *Checker) ruleGroupsText() string {
+	groups := ck.RuleGroups()
+	if len(groups) == 0 {
+		return "no rule groups configured"
+	}
+	lines := make([]string, len(groups))
+	for i, g := range groups {
+		state := "on"
+		if !g.Enabled {
+			state = "off"
+		}
+		lines[i] = fmt.Sprintf("%s: %s (%s)", g.Name, state, strings.Join(g.Rules, ", "))
+	}
+	return strings.Join(lines, "\n")
+}
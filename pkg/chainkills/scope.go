@@ -0,0 +1,105 @@
+package chainkills
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/guarzo/eve-chainkills/internal/zkill"
+	"github.com/guarzo/eve-chainkills/pkg/pipeline"
+)
+
+// zkillChannelKinds are the websocket channels zKillboard filters by an ID
+var zkillChannelKinds = map[string]bool{
+	"region": true, "constellation": true, "system": true,
+	"corporation": true, "alliance": true, "faction": true, "character": true,
+	"ship": true, "group": true,
+}
+
+// regionSystemLister expands a region into its solar systems
+type regionSystemLister interface {
+	RegionSystems(ctx context.Context, regionID int) ([]int, error)
+}
+
+func (kc KillstreamConfig) validate() error {
+	for i, ch := range kc.Channels {
+		if ch == zkill.DefaultChannel || ch == "all:*" {
+			continue
+		}
+		kind, rest, ok := strings.Cut(ch, ":")
+		if kind == "label" && rest != "" {
+			continue
+		}
+		if id, err := strconv.Atoi(rest); !ok || err != nil || id <= 0 || !zkillChannelKinds[kind] {
+			return fmt.Errorf("killstream.channels[%d]: %q is not a zKillboard channel, e.g. region:10000060", i, ch)
+		}
+	}
+	for _, id := range kc.RegionIds {
+		if id <= 0 {
+			return fmt.Errorf("killstream.regionIds: %d is not a region ID", id)
+		}
+	}
+	for _, id := range kc.SystemIds {
+		if id <= 0 {
+			return fmt.Errorf("killstream.systemIds: %d is not a system ID", id)
+		}
+	}
+	return nil
+}
+
+// loadScope expands killstream.regionIds into their systems. Should ESI fail,
+// kills are processed anywhere rather than dropping every kill in those regions.
+func (ck *Checker) loadScope(ctx context.Context) {
+	kc := ck.config.Killstream
+	systems := append([]int(nil), kc.SystemIds...)
+	if len(kc.RegionIds) > 0 {
+		lister, ok := ck.esi.(regionSystemLister)
+		if !ok {
+			ck.logger.Warnf("[Killstream] The ESI client can't list region systems; killstream.regionIds is ignored")
+			ck.updateState(func(s *mapState) { s.scope = nil })
+			return
+		}
+		for _, id := range kc.RegionIds {
+			ids, err := lister.RegionSystems(ctx, id)
+			if err != nil {
+				ck.logger.Errorf("[Killstream] Error listing the systems of region %d, processing kills anywhere: %v", id, err)
+				ck.updateState(func(s *mapState) { s.scope = nil })
+				return
+			}
+			systems = append(systems, ids...)
+		}
+	}
+	ck.updateState(func(s *mapState) { s.scope = systemSet(systems) })
+	ck.logger.Printf("[Killstream] Processing kills in %d systems plus the chain and tracked entities", len(systems))
+}
+
+// inScope reports whether a kill is in killstream.regionIds or systemIds, or
+// could alert anyway as the prefilter sees it: in a chain system, alerted
+// Triglavian space, a configured location or, with friendlyDanger, a system a
+// map character is in, or involving a tracked entity, map character or, with
+// factionWarfare.alert, militia pilot
+func (ck *Checker) inScope(ev *pipeline.Event, state *mapState) bool {
+	ix := state.index
+	return zkill.ScanIDs(ev.Raw, func(key []byte, id int64) bool {
+		switch field := string(key); field {
+		case "killmail_id":
+			ev.Zkill.KillmailID = id
+		case "solar_system_id":
+			ev.Zkill.SolarSystemID = int(id)
+			if _, ok := state.scope[int(id)]; ok {
+				return true
+			}
+			return ck.prefilterSystem(state, int(id))
+		case "locationID":
+			return ck.locationName(id) != ""
+		case "character_id":
+			return ck.tracked.Tracks(field, id) || ix.Mapped(id)
+		case "corporation_id", "alliance_id":
+			return ck.tracked.Tracks(field, id)
+		case "faction_id":
+			return ck.fwAlerts() && ck.militia(int(id))
+		}
+		return false
+	})
+}
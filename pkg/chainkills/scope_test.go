@@ -0,0 +1,152 @@
+package chainkills
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/guarzo/eve-chainkills/pkg/killmail"
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+	"github.com/guarzo/eve-chainkills/pkg/pipeline"
+)
+
+// regionESI lists a region's systems from a map, failing for regions it lacks
+type regionESI struct {
+	echoESI
+	regions map[int][]int
+}
+
+func (re regionESI) RegionSystems(ctx context.Context, regionID int) ([]int, error) {
+	ids, ok := re.regions[regionID]
+	if !ok {
+		return nil, errors.New("universe/regions got status 404")
+	}
+	return ids, nil
+}
+
+func newScopeChecker(t *testing.T, esi ESIClient, config *Config) *Checker {
+	t.Helper()
+	ck, err := newChecker(&options{
+		logger: logger.Slog(slog.New(slog.NewTextHandler(io.Discard, nil))),
+		esi:    esi,
+	}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ck
+}
+
+func TestKillstreamValidate(t *testing.T) {
+	for _, tc := range []struct {
+		config KillstreamConfig
+		err    string
+	}{
+		{config: KillstreamConfig{}},
+		{config: KillstreamConfig{Channels: []string{"killstream", "all:*", "region:10000060", "label:all", "alliance:99000001"}}},
+		{config: KillstreamConfig{RegionIds: []int{10000060}, SystemIds: []int{31000001}}},
+		{config: KillstreamConfig{Channels: []string{"region:"}}, err: "killstream.channels[0]"},
+		{config: KillstreamConfig{Channels: []string{"killstream", "region:-1"}}, err: "killstream.channels[1]"},
+		{config: KillstreamConfig{Channels: []string{"planet:40000001"}}, err: "not a zKillboard channel"},
+		{config: KillstreamConfig{Channels: []string{"label:"}}, err: "not a zKillboard channel"},
+		{config: KillstreamConfig{RegionIds: []int{0}}, err: "killstream.regionIds: 0"},
+		{config: KillstreamConfig{SystemIds: []int{31000001, -5}}, err: "killstream.systemIds: -5"},
+	} {
+		err := tc.config.validate()
+		switch {
+		case tc.err == "" && err != nil:
+			t.Errorf("validate(%+v) = %v, want nil", tc.config, err)
+		case tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)):
+			t.Errorf("validate(%+v) = %v, want an error containing %q", tc.config, err, tc.err)
+		}
+	}
+}
+
+func TestInScope(t *testing.T) {
+	ck := newScopeChecker(t, echoESI{}, &Config{
+		TrackedCorporationIds: []int{98000001},
+		Killstream:            KillstreamConfig{SystemIds: []int{30000142}},
+		Locations:             []LocationConfig{{ID: 40000001, Name: "Staging Keepstar"}},
+		FriendlyDanger:        FriendlyDangerConfig{Enabled: true},
+	})
+	ck.updateState(func(s *mapState) {
+		s.systems = []killmail.SystemInfo{{SystemId: 31000001, Alias: "home"}}
+		s.mapCharacters = []killmail.MapCharacter{{CharacterId: 90000001, SolarSystemId: 30002510, Online: true}}
+	})
+
+	for _, tc := range []struct {
+		name string
+		raw  string
+		want bool
+	}{
+		{"scoped system", `{"killmail_id":1,"solar_system_id":30000142,"victim":{"corporation_id":98000009},"attackers":[]}`, true},
+		{"chain system", `{"killmail_id":2,"solar_system_id":31000001,"victim":{},"attackers":[]}`, true},
+		{"tracked victim elsewhere", `{"killmail_id":3,"solar_system_id":30002187,"victim":{"corporation_id":98000001},"attackers":[]}`, true},
+		{"tracked attacker elsewhere", `{"killmail_id":4,"solar_system_id":30002187,"victim":{},"attackers":[{"corporation_id":98000001}]}`, true},
+		{"configured location elsewhere", `{"killmail_id":6,"solar_system_id":30002187,"victim":{},"attackers":[],"zkb":{"locationID":40000001}}`, true},
+		{"other location elsewhere", `{"killmail_id":7,"solar_system_id":30002187,"victim":{},"attackers":[],"zkb":{"locationID":40000002}}`, false},
+		{"map character's system", `{"killmail_id":8,"solar_system_id":30002510,"victim":{"corporation_id":98000009},"attackers":[]}`, true},
+		{"out of scope", `{"killmail_id":5,"solar_system_id":30002187,"victim":{"corporation_id":98000009},"attackers":[{"corporation_id":98000010}]}`, false},
+	} {
+		ev := &pipeline.Event{Raw: []byte(tc.raw)}
+		if got := ck.inScope(ev, ck.state()); got != tc.want {
+			t.Errorf("%s: inScope = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	// without friendlyDanger a map character's system is only in scope like any other
+	ck.config.FriendlyDanger.Enabled = false
+	ev := &pipeline.Event{Raw: []byte(`{"killmail_id":9,"solar_system_id":30002510,"victim":{},"attackers":[]}`)}
+	if ck.inScope(ev, ck.state()) {
+		t.Errorf("map character's system in scope without friendlyDanger")
+	}
+}
+
+func TestLoadScope(t *testing.T) {
+	esi := regionESI{regions: map[int][]int{10000060: {30004759, 30004760}}}
+	ck := newScopeChecker(t, esi, &Config{
+		Killstream: KillstreamConfig{RegionIds: []int{10000060}, SystemIds: []int{30000142}},
+	})
+	outside := &pipeline.Event{Raw: []byte(`{"killmail_id":1,"solar_system_id":30004759,"victim":{},"attackers":[]}`)}
+
+	// until the regions are expanded, only systemIds are in scope
+	if scope := ck.state().scope; scope == nil || len(scope) != 1 {
+		t.Fatalf("scope before loadScope = %v, want only systemIds", scope)
+	}
+	if ck.inScope(outside, ck.state()) {
+		t.Errorf("kill in the region was in scope before loadScope")
+	}
+
+	ck.loadScope(context.Background())
+	for _, id := range []int{30000142, 30004759, 30004760} {
+		if _, ok := ck.state().scope[id]; !ok {
+			t.Errorf("system %d not in scope after loadScope", id)
+		}
+	}
+	if !ck.inScope(outside, ck.state()) {
+		t.Errorf("kill in the region wasn't in scope after loadScope")
+	}
+
+	// a region ESI can't expand processes kills anywhere rather than dropping them
+	ck = newScopeChecker(t, esi, &Config{Killstream: KillstreamConfig{RegionIds: []int{10000061}}})
+	ck.loadScope(context.Background())
+	if scope := ck.state().scope; scope != nil {
+		t.Errorf("scope after a failed loadScope = %v, want nil", scope)
+	}
+}
+
+func TestNewRejectsInstanceChannels(t *testing.T) {
+	config, err := ParseConfig([]byte(`{
+		"killstream": {"channels": ["region:10000060"]},
+		"instances": [{"name": "a"}, {"name": "b", "killstream": {"channels": ["region:10000061"]}}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = New(WithLogger(logger.Slog(slog.New(slog.NewTextHandler(io.Discard, nil)))), WithConfig(config), WithESIClient(echoESI{}))
+	if err == nil || !strings.Contains(err.Error(), "instance b: killstream.channels is top-level only") {
+		t.Fatalf("New = %v, want instance b's channels rejected", err)
+	}
+}
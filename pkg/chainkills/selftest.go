@@ -0,0 +1,58 @@
+package chainkills
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	mapapi "github.com/guarzo/eve-chainkills/internal/map"
+)
+
+// Version is set at build time with
+// -ldflags "-X github.com/guarzo/eve-chainkills/pkg/chainkills.Version=v1.2.3"
+var Version = "dev"
+
+// selfTestTypeID is a type every ESI deployment knows (Rifter), used to check ESI is reachable
+const selfTestTypeID = 587
+
+// SelfTest checks the map API credentials and ESI before the feed starts.
+// A map API failure is returned as an error, since it means the instance is
+// misconfigured; an unreachable ESI is only logged. When startup announcements
+// are enabled it also posts a "starting up" message to the info webhook.
+func (ck *Checker) SelfTest(ctx context.Context) error {
+	systems, err := ck.mapAPI.Systems(ctx)
+	if err != nil {
+		return fmt.Errorf("map API check failed (check apiBaseUrl, apiSlug and apiToken): %w", err)
+	}
+	if _, err = ck.mapAPI.Characters(ctx); errors.Is(err, mapapi.ErrForbidden) {
+		ck.logger.Warnf("[SelfTest] Map API refuses character access, the instance will run in systems-only mode: %v", err)
+	} else if err != nil {
+		return fmt.Errorf("map API characters check failed: %w", err)
+	}
+	ck.logger.Printf("[SelfTest] Map API OK, %d systems.", len(systems))
+
+	if name, _, err := ck.esi.TypeInfo(ctx, selfTestTypeID); err != nil {
+		ck.logger.Warnf("[SelfTest] ESI lookup failed, kill details will be incomplete until it recovers: %v", err)
+	} else {
+		ck.logger.Printf("[SelfTest] ESI OK, resolved type %d to %s.", selfTestTypeID, name)
+	}
+
+	if ck.config.SelfTest.AnnounceStartup {
+		ck.sendInfoMessage(ctx, fmt.Sprintf("Chainkills starting up, %s, tracking %d systems and %d IDs.",
+			Version, len(systems), len(ck.insightTrackedIds)))
+	}
+	return nil
+}
+
+// SelfTest runs every instance's self-test, stopping at the first failure
+func (h *Hub) SelfTest(ctx context.Context) error {
+	for _, ck := range h.checkers {
+		if err := ck.SelfTest(ctx); err != nil {
+			if ck.config.Name != "" {
+				return fmt.Errorf("instance %s: %w", ck.config.Name, err)
+			}
+			return err
+		}
+	}
+	return nil
+}
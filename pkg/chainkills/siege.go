@@ -0,0 +1,246 @@
+package chainkills
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/internal/discord"
+	"github.com/guarzo/eve-chainkills/pkg/killmail"
+	"github.com/guarzo/eve-chainkills/pkg/pipeline"
+	"golang.org/x/exp/slices"
+)
+
+// defaultStructureGroupIds are the ESI inventory groups counted as structures:
+// control towers, customs offices, citadels, engineering complexes and refineries
+var defaultStructureGroupIds = []int{365, 1025, 1404, 1406, 1657}
+
+// siegeKey is one corporation's structures in one system
+type siegeKey struct {
+	corp   int
+	system int
+}
+
+type siegeKill struct {
+	killID int64
+	name   string
+	value  float64
+	at     time.Time
+}
+
+// siege is a run of structure kills against one corporation in one system
+type siege struct {
+	corpName string
+	where    string
+	kills    []siegeKill
+	// messageID is the summary message once posted; dirty marks kills it doesn't show yet
+	messageID string
+	dirty     bool
+}
+
+// siegeTracker collects structure kills into sieges and keeps one edited
+// summary message per siege
+type siegeTracker struct {
+	minKills   int
+	window     time.Duration
+	interval   time.Duration
+	structures []int
+
+	mu     sync.Mutex
+	sieges map[siegeKey]*siege
+}
+
+func newSiegeTracker(c StructureSiegeConfig) *siegeTracker {
+	st := &siegeTracker{minKills: 2, window: 24 * time.Hour, interval: 5 * time.Minute,
+		structures: defaultStructureGroupIds, sieges: map[siegeKey]*siege{}}
+	if c.MinKills > 0 {
+		st.minKills = c.MinKills
+	}
+	if c.WindowHours > 0 {
+		st.window = time.Duration(c.WindowHours) * time.Hour
+	}
+	if c.UpdateMinutes > 0 {
+		st.interval = time.Duration(c.UpdateMinutes) * time.Minute
+	}
+	if len(c.StructureGroupIds) > 0 {
+		st.structures = c.StructureGroupIds
+	}
+	return st
+}
+
+// record adds a structure kill to its siege
+func (st *siegeTracker) record(key siegeKey, corpName, where string, kill siegeKill) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	s, ok := st.sieges[key]
+	if !ok {
+		s = &siege{}
+		st.sieges[key] = s
+	}
+	for _, k := range s.kills {
+		if k.killID == kill.killID {
+			return
+		}
+	}
+	s.corpName, s.where = corpName, where
+	s.kills = append(s.kills, kill)
+	s.dirty = len(s.kills) >= st.minKills
+}
+
+// siegeUpdate is a summary to post or edit, taken under the lock
+type siegeUpdate struct {
+	key   siegeKey
+	siege siege
+	over  bool
+}
+
+// pending returns the sieges whose summary needs posting or editing, and
+// drops those quiet for longer than the window
+func (st *siegeTracker) pending(now time.Time) []siegeUpdate {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	var out []siegeUpdate
+	for key, s := range st.sieges {
+		over := now.Sub(s.kills[len(s.kills)-1].at) > st.window
+		if over {
+			delete(st.sieges, key)
+		}
+		if s.dirty || (over && s.messageID != "") {
+			s.dirty = false
+			out = append(out, siegeUpdate{key: key, siege: *s, over: over})
+		}
+	}
+	return out
+}
+
+// posted remembers where a siege's summary went; "" retries on the next update
+func (st *siegeTracker) posted(key siegeKey, messageID string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if s, ok := st.sieges[key]; ok {
+		s.messageID = messageID
+		s.dirty = s.dirty || messageID == ""
+	}
+}
+
+// summary renders a siege as an embed
+func (s siege) summary(over bool) discord.Embed {
+	title := fmt.Sprintf("Structure siege in progress: %s in %s", s.corpName, s.where)
+	color := 0xE67E22
+	if over {
+		title = fmt.Sprintf("Structure siege over: %s in %s", s.corpName, s.where)
+		color = 0x95A5A6
+	}
+	var (
+		isk   float64
+		lines []string
+	)
+	names := map[string]int{}
+	for _, k := range s.kills {
+		isk += k.value
+		names[k.name]++
+		lines = append(lines, fmt.Sprintf("%s [%s](%s) %s", killmail.FormatEVETime(k.at), k.name,
+			fmt.Sprintf("https://zkillboard.com/kill/%d/", k.killID), killmail.FormatISKValue(k.value)))
+	}
+	counts := make([]string, 0, len(names))
+	for name, n := range names {
+		counts = append(counts, fmt.Sprintf("%d× %s", n, name))
+	}
+	sort.Strings(counts)
+	first, last := s.kills[0].at, s.kills[len(s.kills)-1].at
+	desc := fmt.Sprintf("%s lost (%s), %s\nFirst kill %s, last %s ago\n\n%s",
+		plural(len(s.kills), "structure"), strings.Join(counts, ", "), killmail.FormatISKValue(isk),
+		killmail.FormatEVETime(first), time.Since(last).Truncate(time.Minute), strings.Join(lines, "\n"))
+	return discord.Embed{Title: title, Description: desc, Color: color, Timestamp: last.UTC().Format(time.RFC3339)}
+}
+
+// trackSiege records a notified kill whose victim is a structure
+func (ck *Checker) trackSiege(ctx context.Context, ev *pipeline.Event) {
+	fkm := &ev.Kill
+	if fkm.Victim.CharacterID != 0 || fkm.Victim.CorporationID == 0 {
+		// structures have no pilot
+		return
+	}
+	name, groupID, err := ck.victimType(ctx, fkm)
+	if err != nil {
+		ck.killLogger(ctx).Printf("[Siege] Error fetching the type of kill %d: %v", fkm.KillMailID, err)
+		return
+	}
+	if !slices.Contains(ck.sieges.structures, groupID) {
+		return
+	}
+	where := fkm.SystemName
+	if ev.System != nil {
+		where = ev.System.Alias
+	}
+	if where == "" {
+		where = fmt.Sprintf("SystemID:%d", fkm.SolarSystemID)
+	}
+	if name == "" {
+		name = "Structure"
+	}
+	ck.sieges.record(siegeKey{corp: fkm.Victim.CorporationID, system: fkm.SolarSystemID}, ck.siegeCorpName(ctx, fkm), where,
+		siegeKill{killID: fkm.KillMailID, name: name, value: fkm.TotalValue, at: fkm.KillMailTime})
+}
+
+// victimType returns the name and ESI inventory group of the victim's ship or structure
+func (ck *Checker) victimType(ctx context.Context, fkm *killmail.FlattenedKillMail) (string, int, error) {
+	if fkm.VictimShipGroupID != 0 {
+		return fkm.VictimShipName, fkm.VictimShipGroupID, nil
+	}
+	// chain alerts skip ESI enrichment, so look the hull up ourselves
+	return ck.esi.TypeInfo(ctx, fkm.Victim.ShipTypeID)
+}
+
+// siegeCorpName names the victim corporation, falling back to its ID
+func (ck *Checker) siegeCorpName(ctx context.Context, fkm *killmail.FlattenedKillMail) string {
+	if fkm.VictimCorpName != "" {
+		return fkm.VictimCorpName
+	}
+	if resolver, ok := ck.esi.(affiliationResolver); ok {
+		if names, err := resolver.Names(ctx, []int{fkm.Victim.CorporationID}); err == nil && names[fkm.Victim.CorporationID] != "" {
+			return names[fkm.Victim.CorporationID]
+		}
+	}
+	return fmt.Sprintf("corporation %d", fkm.Victim.CorporationID)
+}
+
+// runSieges posts and edits the siege summaries until ctx is done
+func (ck *Checker) runSieges(ctx context.Context) {
+	ticker := time.NewTicker(ck.sieges.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for _, u := range ck.sieges.pending(time.Now()) {
+			ck.updateSiege(ctx, u)
+		}
+	}
+}
+
+// updateSiege posts a siege's summary, or edits the one already posted
+func (ck *Checker) updateSiege(ctx context.Context, u siegeUpdate) {
+	id, token := ck.config.StructureSiege.DiscordWebhookId, ck.config.StructureSiege.DiscordWebhookToken
+	if id == "" {
+		id, token = ck.infoWebhook(SeverityLifecycle)
+	}
+	embed := u.siege.summary(u.over)
+	if u.siege.messageID != "" {
+		if err := ck.webhooks.EditWebhookMessage(ctx, ck.logger, id, token, u.siege.messageID, "", embed); err != nil {
+			ck.logger.Printf("[Siege] Error updating the summary for corporation %d in %s: %v", u.key.corp, u.siege.where, err)
+		}
+		return
+	}
+	ck.logger.Printf("[Siege] Structure siege against corporation %d in %s: %d kills", u.key.corp, u.siege.where, len(u.siege.kills))
+	msg, err := ck.webhooks.PostWebhookMessage(ctx, ck.logger, id, token, "", &embed)
+	if err != nil {
+		ck.logger.Printf("[Siege] Error posting the summary for corporation %d in %s: %v", u.key.corp, u.siege.where, err)
+	}
+	ck.sieges.posted(u.key, msg.ID)
+}
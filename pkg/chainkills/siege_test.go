@@ -0,0 +1,127 @@
+package chainkills
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/internal/discord"
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+	"github.com/guarzo/eve-chainkills/pkg/pipeline"
+)
+
+func TestSiegeTracker(t *testing.T) {
+	st := newSiegeTracker(StructureSiegeConfig{MinKills: 2, WindowHours: 1})
+	t0 := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	key := siegeKey{corp: 98000009, system: 31000002}
+	kill := func(id int64, after time.Duration) siegeKill {
+		return siegeKill{killID: id, name: "Astrahus", value: 1e9, at: t0.Add(after)}
+	}
+
+	// one structure kill isn't a siege yet
+	st.record(key, "Victims Inc", "c1", kill(1, 0))
+	if ups := st.pending(t0); len(ups) != 0 {
+		t.Fatalf("posted a siege of one kill: %+v", ups)
+	}
+	st.record(key, "Victims Inc", "c1", kill(2, 10*time.Minute))
+	// another corporation's structures in the same system are another siege
+	st.record(siegeKey{corp: 98000010, system: 31000002}, "Others", "c1", kill(3, 10*time.Minute))
+	ups := st.pending(t0.Add(10 * time.Minute))
+	if len(ups) != 1 || ups[0].key != key || len(ups[0].siege.kills) != 2 || ups[0].over || ups[0].siege.messageID != "" {
+		t.Fatalf("pending %+v, want a new siege of kills 1 and 2", ups)
+	}
+	st.posted(key, "m1")
+
+	// the same kill seen again, e.g. as a cross-post, changes nothing
+	st.record(key, "Victims Inc", "c1", kill(2, 10*time.Minute))
+	if ups := st.pending(t0.Add(11 * time.Minute)); len(ups) != 0 {
+		t.Fatalf("a repeated kill updated the summary: %+v", ups)
+	}
+
+	// new kills edit the posted summary
+	st.record(key, "Victims Inc", "c1", kill(4, 20*time.Minute))
+	ups = st.pending(t0.Add(20 * time.Minute))
+	if len(ups) != 1 || ups[0].siege.messageID != "m1" || len(ups[0].siege.kills) != 3 {
+		t.Fatalf("pending %+v, want an edit of m1 with 3 kills", ups)
+	}
+
+	// a failed post is retried on the next update
+	st.posted(key, "")
+	if ups := st.pending(t0.Add(25 * time.Minute)); len(ups) != 1 || ups[0].siege.messageID != "" {
+		t.Fatalf("pending %+v after a failed post, want a retry", ups)
+	}
+	st.posted(key, "m2")
+
+	// a window after the last kill the siege is over: the posted one says so,
+	// the one never posted is dropped
+	ups = st.pending(t0.Add(20*time.Minute + time.Hour + time.Second))
+	if len(ups) != 1 || !ups[0].over || ups[0].siege.messageID != "m2" {
+		t.Fatalf("pending %+v, want the siege marked over", ups)
+	}
+	if len(st.sieges) != 0 {
+		t.Errorf("still tracking %d sieges after the window", len(st.sieges))
+	}
+	if embed := ups[0].siege.summary(true); !strings.HasPrefix(embed.Title, "Structure siege over: Victims Inc in c1") ||
+		!strings.Contains(embed.Description, "3 structures lost (3× Astrahus)") {
+		t.Errorf("summary %q: %q", embed.Title, embed.Description)
+	}
+}
+
+// structureESI answers every type lookup as an Astrahus
+type structureESI struct{ echoESI }
+
+func (structureESI) TypeInfo(ctx context.Context, typeID int) (string, int, error) {
+	return "Astrahus", 1657, nil
+}
+
+func TestSiegeSummaryPostedThenEdited(t *testing.T) {
+	transport := &mapTransport{}
+	client := &http.Client{Transport: transport}
+	ck, err := newChecker(&options{
+		logger:   logger.Slog(slog.New(slog.NewTextHandler(io.Discard, nil))),
+		esi:      structureESI{},
+		http:     client,
+		webhooks: discord.NewWebhooks(client, 0, pipeline.NumPriorities),
+	}, &Config{StructureSiege: StructureSiegeConfig{Enabled: true, DiscordWebhookId: "5", DiscordWebhookToken: "siege"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	structureKill := func(id int64) *pipeline.Event {
+		ev := &pipeline.Event{}
+		ev.Kill.KillMailID, ev.Kill.SolarSystemID, ev.Kill.KillMailTime = id, 31000002, time.Now()
+		ev.Kill.Victim.CorporationID, ev.Kill.VictimCorpName = 98000009, "Victims Inc"
+		return ev
+	}
+	// a pilot's loss isn't a structure
+	pilot := structureKill(9)
+	pilot.Kill.Victim.CharacterID = 90000001
+	ck.trackSiege(ctx, pilot)
+
+	ck.trackSiege(ctx, structureKill(1))
+	ck.trackSiege(ctx, structureKill(2))
+	for _, u := range ck.sieges.pending(time.Now()) {
+		ck.updateSiege(ctx, u)
+	}
+	ck.trackSiege(ctx, structureKill(3))
+	for _, u := range ck.sieges.pending(time.Now()) {
+		ck.updateSiege(ctx, u)
+	}
+
+	var posts, edits int
+	for _, r := range transport.requests() {
+		switch {
+		case strings.HasPrefix(r, "POST https://discord.com/api/webhooks/5/siege"):
+			posts++
+		case strings.HasPrefix(r, "PATCH https://discord.com/api/webhooks/5/siege/messages/1"):
+			edits++
+		}
+	}
+	if posts != 1 || edits != 1 {
+		t.Errorf("requests %v, want the summary posted once, then edited", transport.requests())
+	}
+}
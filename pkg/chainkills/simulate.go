@@ -0,0 +1,184 @@
+package chainkills
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/internal/filter"
+	"github.com/guarzo/eve-chainkills/internal/zkill"
+	"github.com/guarzo/eve-chainkills/pkg/killmail"
+	"github.com/guarzo/eve-chainkills/pkg/notify"
+)
+
+// SimulatedKill is how the current config would have handled one past kill
+type SimulatedKill struct {
+	Instance string
+	KillID   int64
+	Time     time.Time
+	SystemID int
+	// Kind is empty when the kill would not have alerted
+	Kind     notify.Kind
+	IsKill   bool
+	Location string
+	Reason   notify.MatchReason
+	// Err is set when the kill's details could not be fetched from ESI
+	Err error
+}
+
+// killmailFetcher is implemented by ESI clients that can fetch a bare killmail
+type killmailFetcher interface {
+	Killmail(ctx context.Context, killmailID int64, hash string) (killmail.EsiKillMail, error)
+}
+
+// zkillRequestGap keeps simulations under zKillboard's REST rate limit
+const zkillRequestGap = time.Second
+
+// Simulate pulls the last since of kills for every instance's tracked IDs and
+// chain systems from zKillboard's REST API, and reports how each would have
+// matched under the current config, oldest first. Nothing is sent to any sink.
+// zKillboard only goes back a week.
+func (h *Hub) Simulate(ctx context.Context, since time.Duration) ([]SimulatedKill, error) {
+	if since <= 0 || since > 7*24*time.Hour {
+		return nil, errors.New("simulations can look back between 1s and 168h")
+	}
+	var results []SimulatedKill
+	for _, ck := range h.checkers {
+		sims, err := ck.simulate(ctx, since)
+		if err != nil {
+			if ck.config.Name != "" {
+				err = fmt.Errorf("instance %s: %w", ck.config.Name, err)
+			}
+			return results, err
+		}
+		results = append(results, sims...)
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Time.Before(results[j].Time) })
+	return results, nil
+}
+
+func (ck *Checker) simulate(ctx context.Context, since time.Duration) ([]SimulatedKill, error) {
+	// read the map directly; the usual refresh reports errors to Discord
+	systems, err := ck.mapAPI.Systems(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("map systems: %w", err)
+	}
+	chars, err := ck.mapAPI.Characters(ctx)
+	if err != nil {
+		ck.logger.Printf("[Simulate] Error getting map characters, matching without them: %v", err)
+	}
+	if ck.config.TriglavianSpace.Enabled {
+		// classify reads Pochven from the live state, empty until the first map refresh
+		ck.loadPochven(ctx)
+	}
+
+	tracked, err := ck.TrackedIds()
+	if errors.Is(err, ErrCustomFilter) {
+		tracked = ck.config.InsightTrackedIds
+	}
+	type query struct {
+		entity string
+		id     int
+	}
+	var queries []query
+	for _, id := range tracked {
+		queries = append(queries, query{entityType(id), id})
+	}
+	for _, id := range ck.config.TrackedCorporationIds {
+		queries = append(queries, query{"corporationID", id})
+	}
+	for _, id := range ck.config.TrackedAllianceIds {
+		queries = append(queries, query{"allianceID", id})
+	}
+	for _, id := range ck.config.TrackedCharacterIds {
+		queries = append(queries, query{"characterID", int(id)})
+	}
+	for _, sys := range systems {
+		queries = append(queries, query{"systemID", sys.SystemId})
+	}
+
+	seen := map[int64]bool{}
+	var kills []killmail.ZkillMail
+	for i, q := range queries {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(zkillRequestGap):
+			}
+		}
+		found, err := zkill.Recent(ctx, ck.http, zkill.HistoryURL, q.entity, q.id, since)
+		if err != nil {
+			ck.logger.Printf("[Simulate] Error listing kills for %s %d: %v", q.entity, q.id, err)
+		}
+		for _, zm := range found {
+			if !seen[zm.KillmailID] {
+				seen[zm.KillmailID] = true
+				kills = append(kills, zm)
+			}
+		}
+	}
+	ck.logger.Printf("[Simulate] %d kills from %d tracked IDs and %d chain systems", len(kills), len(queries)-len(systems), len(systems))
+
+	cutoff := time.Now().Add(-since)
+	ix := filter.NewIndex(systems, chars)
+	var results []SimulatedKill
+	for _, zm := range kills {
+		sim := SimulatedKill{Instance: ck.config.Name, KillID: zm.KillmailID}
+		if err := ck.fillKillmail(ctx, &zm); err != nil {
+			sim.Err = err
+			results = append(results, sim)
+			continue
+		}
+		if zm.KillmailTime.Before(cutoff) {
+			// zKillboard rounds the window up to whole hours
+			continue
+		}
+		result, location := ck.classify(zm, ix, systems, chars)
+		sim.Time = zm.KillmailTime
+		sim.SystemID = zm.SolarSystemID
+		sim.Kind = result.Kind
+		sim.IsKill = result.IsKill
+		sim.Location = location
+		sim.Reason = result.Reason
+		results = append(results, sim)
+	}
+	return results, nil
+}
+
+// fillKillmail adds the victim, attackers, system and time from ESI to a kill
+// listed by zKillboard's REST API
+func (ck *Checker) fillKillmail(ctx context.Context, zm *killmail.ZkillMail) error {
+	if fetcher, ok := ck.esi.(killmailFetcher); ok {
+		km, err := fetcher.Killmail(ctx, zm.KillmailID, zm.ZKB.Hash)
+		if err != nil {
+			return err
+		}
+		zm.KillmailTime, zm.SolarSystemID = km.KillMailTime, km.SolarSystemID
+		zm.Victim, zm.Attackers = km.Victim, km.Attackers
+		return nil
+	}
+	raw, err := json.Marshal(zm)
+	if err != nil {
+		return err
+	}
+	fkm, err := ck.esi.GetKillDetails(ctx, raw)
+	if err != nil {
+		return err
+	}
+	zm.KillmailTime, zm.SolarSystemID = fkm.KillMailTime, fkm.SolarSystemID
+	zm.Victim, zm.Attackers = fkm.Victim, fkm.Attackers
+	return nil
+}
+
+// entityType guesses whether an insightTrackedIds entry is a corporation or an alliance;
+// player alliances are numbered from 99000000, player corporations below that
+func entityType(id int) string {
+	if id >= 99000000 && id < 100000000 {
+		return "allianceID"
+	}
+	return "corporationID"
+}
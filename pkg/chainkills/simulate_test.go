@@ -0,0 +1,68 @@
+package chainkills
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+	"github.com/guarzo/eve-chainkills/pkg/notify"
+)
+
+// historyTransport answers zKillboard's REST API with history and the map API like mapTransport
+type historyTransport struct {
+	mapTransport
+	history string
+}
+
+func (ht *historyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host != "zkillboard.com" {
+		return ht.mapTransport.RoundTrip(req)
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(ht.history)),
+		Request:    req,
+	}, nil
+}
+
+func TestSimulateClassifiesPochven(t *testing.T) {
+	transport := &historyTransport{
+		mapTransport: mapTransport{endpoints: map[string]string{
+			"/api/systems":    `{"data":[]}`,
+			"/api/characters": `{"data":[]}`,
+		}},
+		history: fmt.Sprintf(`[{"killmail_id":1,"killmail_time":%q,"solar_system_id":30002702,"victim":{"corporation_id":98000009},"attackers":[{"corporation_id":98000002}],"zkb":{"hash":"abc"}}]`,
+			time.Now().UTC().Add(-time.Minute).Format(time.RFC3339)),
+	}
+	client := &http.Client{Transport: transport}
+	ck, err := newChecker(&options{
+		logger: logger.Slog(slog.New(slog.NewTextHandler(io.Discard, nil))),
+		esi:    regionESI{regions: map[int][]int{PochvenRegionID: {30002702}}},
+		http:   client,
+	}, &Config{
+		APIBaseUrl:        "http://map.test/api",
+		APISlug:           "chain",
+		InsightTrackedIds: []int{98000001},
+		TriglavianSpace:   TriglavianSpaceConfig{Enabled: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &Hub{checkers: []*Checker{ck}}
+
+	// the kill isn't ours, so only Pochven, which a simulation has to load itself, matches it
+	sims, err := h.Simulate(context.Background(), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sims) != 1 || sims[0].Err != nil || sims[0].Kind != notify.KindLocation || sims[0].Reason.Rule != notify.RuleTriglavianSpace {
+		t.Errorf("simulated %+v, want kill 1 matched in Pochven", sims)
+	}
+}
@@ -0,0 +1,106 @@
+package chainkills
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/internal/discord"
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+	"github.com/guarzo/eve-chainkills/pkg/notify"
+)
+
+// buildSinks creates every sink that has been configured in config.json. The
+// Discord sink logs to discordLogger and sends through webhooks, every other
+// sink logs to logger and sends through client. Plain-text sinks show kill
+// times in displayTZ as well as EVE time when it is set.
+func buildSinks(discordLogger, logger logger.Logger, config *Config, displayTZ *time.Location, types notify.TypeResolver,
+	client *http.Client, webhooks *discord.Webhooks) []notify.Sink {
+	sinks := []notify.Sink{
+		notify.NewDiscordSink(discordLogger, notify.DiscordConfig{
+			ChainWebhookID:             config.DiscordChainkillWebhookId,
+			ChainWebhookToken:          config.DiscordChainkillWebhookToken,
+			CorpWebhookID:              config.DiscordCorpkillWebhookId,
+			CorpWebhookToken:           config.DiscordCorpkillWebhookToken,
+			BothSidesWebhookID:         config.BothSides.DiscordWebhookId,
+			BothSidesWebhookToken:      config.BothSides.DiscordWebhookToken,
+			AbyssalWebhookID:           config.Abyssal.DiscordWebhookId,
+			AbyssalWebhookToken:        config.Abyssal.DiscordWebhookToken,
+			FactionWarfareWebhookID:    config.FactionWarfare.DiscordWebhookId,
+			FactionWarfareWebhookToken: config.FactionWarfare.DiscordWebhookToken,
+			KillColor:                  config.DiscordKillNotifications.KillColor,
+			LossColor:                  config.DiscordKillNotifications.LossColor,
+			Images:                     config.Images,
+			ISKFormat:                  config.DiscordKillNotifications.ISKFormat,
+			BotToken:                   config.Acknowledgments.botToken(config),
+			Format:                     config.DiscordKillNotifications.Format,
+			ChainFormat:                config.DiscordKillNotifications.ChainFormat,
+			MaxBatch:                   config.DiscordKillNotifications.MaxBatch,
+			BatchWindow:                time.Duration(config.DiscordKillNotifications.BatchWindowMs) * time.Millisecond,
+			Webhooks:                   webhooks,
+		}),
+	}
+	if config.Telegram.BotToken != "" && len(config.Telegram.ChatIds) > 0 {
+		tc := config.Telegram
+		tc.Images = config.Images
+		tc.Timezone = displayTZ
+		ts := notify.NewTelegramSink(logger, tc)
+		ts.SetHTTPClient(client)
+		sinks = append(sinks, ts)
+	}
+	if config.NATS.URL != "" && config.NATS.Subject != "" {
+		sinks = append(sinks, notify.NewNATSSink(logger, config.NATS))
+	}
+	if config.MQTT.Broker != "" && config.MQTT.Topic != "" {
+		sinks = append(sinks, notify.NewMQTTSink(logger, config.MQTT))
+	}
+	if config.XMPP.JID != "" && (config.XMPP.Room != "" || len(config.XMPP.Recipients) > 0) {
+		sinks = append(sinks, notify.NewXMPPSink(logger, config.XMPP))
+	}
+	if config.Push.Ntfy.Topic != "" || (config.Push.Pushover.AppToken != "" && config.Push.Pushover.UserKey != "") {
+		ps := notify.NewPushSink(logger, config.Push, types)
+		ps.SetHTTPClient(client)
+		sinks = append(sinks, ps)
+	}
+	if config.Mattermost.URL != "" {
+		cs := notify.NewChatWebhookSink(logger, notify.ChatFlavorMattermost, config.withEmbedColors(config.Mattermost, displayTZ))
+		cs.SetHTTPClient(client)
+		sinks = append(sinks, cs)
+	}
+	if config.RocketChat.URL != "" {
+		cs := notify.NewChatWebhookSink(logger, notify.ChatFlavorRocketChat, config.withEmbedColors(config.RocketChat, displayTZ))
+		cs.SetHTTPClient(client)
+		sinks = append(sinks, cs)
+	}
+	for _, wc := range config.Webhooks {
+		if wc.ISKFormat == "" {
+			wc.ISKFormat = config.DiscordKillNotifications.ISKFormat
+		}
+		if wc.URL != "" {
+			ws := notify.NewWebhookSink(logger, wc)
+			ws.SetHTTPClient(client)
+			sinks = append(sinks, ws)
+		}
+	}
+	if peers := config.Federation.sendPeers(); len(peers) > 0 {
+		fs := notify.NewFederationSink(logger, config.Federation.source(config), peers)
+		fs.SetHTTPClient(client)
+		sinks = append(sinks, fs)
+	}
+	return sinks
+}
+
+// withEmbedColors defaults a chat webhook's colors and ISK format to the Discord ones, and sets its images and timezone
+func (c *Config) withEmbedColors(cw notify.ChatWebhookConfig, displayTZ *time.Location) notify.ChatWebhookConfig {
+	cw.Images = c.Images
+	cw.Timezone = displayTZ
+	if cw.KillColor == "" {
+		cw.KillColor = c.DiscordKillNotifications.KillColor
+	}
+	if cw.LossColor == "" {
+		cw.LossColor = c.DiscordKillNotifications.LossColor
+	}
+	if cw.ISKFormat == "" {
+		cw.ISKFormat = c.DiscordKillNotifications.ISKFormat
+	}
+	return cw
+}
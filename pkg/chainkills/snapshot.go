@@ -0,0 +1,86 @@
+package chainkills
+
+import (
+	"strconv"
+
+	"github.com/guarzo/eve-chainkills/internal/filter"
+	"github.com/guarzo/eve-chainkills/pkg/killmail"
+)
+
+// mapState is what kills are matched against: the chain, the characters on
+// it and the ignored systems. A published mapState is never modified;
+// refreshes build a new one and swap it in, so handlers get a consistent view
+// without locking.
+type mapState struct {
+	systems     []killmail.SystemInfo
+	connections []killmail.Connection
+	// mapCharacters come from the map API, corpMembers from the ESI roster
+	mapCharacters []killmail.MapCharacter
+	corpMembers   []killmail.MapCharacter
+	ignored       map[int]struct{}
+	// scope is the killstream.regionIds and systemIds systems; nil processes kills anywhere
+	scope map[int]struct{}
+	// pochven is the Pochven region's systems, loaded at startup
+	pochven map[int]struct{}
+	// warzone is the Faction Warfare systems' status, refreshed with factionWarfare
+	warzone map[int]killmail.EsiFWSystem
+
+	// derived from the fields above by updateState
+	// characters is the map characters followed by the corp members who aren't on the map
+	characters []killmail.MapCharacter
+	index      *filter.Index
+}
+
+// state is the current snapshot
+func (ck *Checker) state() *mapState {
+	return ck.snapshot.Load()
+}
+
+// updateState publishes a copy of the snapshot changed by fn; stateMu keeps
+// concurrent refreshes from overwriting each other's changes
+func (ck *Checker) updateState(fn func(s *mapState)) {
+	ck.stateMu.Lock()
+	defer ck.stateMu.Unlock()
+	next := mapState{}
+	if cur := ck.snapshot.Load(); cur != nil {
+		next = *cur
+	}
+	fn(&next)
+	next.characters = mergeCharacters(next.mapCharacters, next.corpMembers)
+	next.index = filter.NewIndex(next.systems, next.characters)
+	ck.snapshot.Store(&next)
+}
+
+func mergeCharacters(mapChars, members []killmail.MapCharacter) []killmail.MapCharacter {
+	if len(members) == 0 {
+		return mapChars
+	}
+	onMap := make(map[int64]bool, len(mapChars))
+	for _, mc := range mapChars {
+		onMap[mc.CharacterId] = true
+	}
+	chars := make([]killmail.MapCharacter, len(mapChars), len(mapChars)+len(members))
+	copy(chars, mapChars)
+	for _, m := range members {
+		if !onMap[m.CharacterId] {
+			chars = append(chars, m)
+		}
+	}
+	return chars
+}
+
+func systemSet(ids []int) map[int]struct{} {
+	set := make(map[int]struct{}, len(ids))
+	for _, id := range ids {
+		set[id] = struct{}{}
+	}
+	return set
+}
+
+// alias returns a chain system's alias, or its ID if it isn't mapped
+func (s *mapState) alias(id int) string {
+	if sys, ok := s.index.System(id); ok {
+		return sys.Alias
+	}
+	return strconv.Itoa(id)
+}
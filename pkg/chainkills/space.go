@@ -0,0 +1,69 @@
+package chainkills
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/guarzo/eve-chainkills/pkg/killmail"
+	"github.com/guarzo/eve-chainkills/pkg/notify"
+)
+
+// Triglavian space: Pochven's systems keep their known-space IDs but are
+// reached only through filaments and conduits, and Zarzakh only through its
+// own restricted gates, so neither is a way out of a chain
+const (
+	PochvenRegionID = 10000070
+	ZarzakhSystemID = 30100000
+)
+
+// loadPochven expands the Pochven region into its systems. Should ESI fail,
+// Pochven is treated as ordinary known space until the next start.
+func (ck *Checker) loadPochven(ctx context.Context) {
+	lister, ok := ck.esi.(regionSystemLister)
+	if !ok {
+		ck.logger.Warnf("[Pochven] The ESI client can't list region systems; Pochven is treated as known space")
+		return
+	}
+	ids, err := lister.RegionSystems(ctx, PochvenRegionID)
+	if err != nil {
+		ck.logger.Errorf("[Pochven] Error listing Pochven's systems, treating them as known space: %v", err)
+		return
+	}
+	ck.updateState(func(s *mapState) { s.pochven = systemSet(ids) })
+	ck.logger.Debugf("[Pochven] Loaded %d Pochven systems", len(ids))
+}
+
+// triglavianSpace names the Triglavian space a system is in, "Pochven" or
+// "Zarzakh", or returns ""
+func (s *mapState) triglavianSpace(systemID int) string {
+	if systemID == ZarzakhSystemID {
+		return "Zarzakh"
+	}
+	if _, ok := s.pochven[systemID]; ok {
+		return "Pochven"
+	}
+	return ""
+}
+
+// triglavianAlert returns the Triglavian space a kill in the system alerts
+// for under triglavianSpace, or ""
+func (ck *Checker) triglavianAlert(s *mapState, systemID int) string {
+	tc := ck.config.TriglavianSpace
+	if !tc.Enabled {
+		return ""
+	}
+	space := s.triglavianSpace(systemID)
+	if space == "Zarzakh" && !tc.Zarzakh {
+		return ""
+	}
+	return space
+}
+
+// triglavianMatch matches a kill in Triglavian space as a location kill
+func triglavianMatch(zm killmail.ZkillMail, space string) Match {
+	return Match{Kind: notify.KindLocation, Reason: notify.MatchReason{
+		Rule: notify.RuleTriglavianSpace,
+		ID:   int64(zm.SolarSystemID),
+		Text: fmt.Sprintf("system %d in %s", zm.SolarSystemID, space),
+	}}
+}
@@ -0,0 +1,25 @@
+package chainkills
+
+import (
+	"github.com/guarzo/eve-chainkills/internal/sso"
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+)
+
+// NewSSO opens the token file of the EVE SSO application in sc
+func NewSSO(logger logger.Logger, sc SSOConfig) (*sso.Client, error) {
+	if sc.TokenFile == "" {
+		sc.TokenFile = "sso-tokens.json"
+	}
+	if sc.CallbackURL == "" {
+		sc.CallbackURL = "http://localhost:8765/callback"
+	}
+	store, err := sso.OpenStore(sc.TokenFile)
+	if err != nil {
+		return nil, err
+	}
+	return sso.NewClient(logger, sso.Config{
+		ClientID:     sc.ClientID,
+		ClientSecret: sc.ClientSecret,
+		CallbackURL:  sc.CallbackURL,
+	}, store), nil
+}
@@ -0,0 +1,500 @@
+package chainkills
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/internal/filter"
+	"github.com/guarzo/eve-chainkills/internal/lanes"
+	"github.com/guarzo/eve-chainkills/internal/tracing"
+	"github.com/guarzo/eve-chainkills/internal/zkill"
+	"github.com/guarzo/eve-chainkills/pkg/killmail"
+	"github.com/guarzo/eve-chainkills/pkg/notify"
+	"github.com/guarzo/eve-chainkills/pkg/pipeline"
+)
+
+// buildPipeline assembles the default stages for this checker
+func (ck *Checker) buildPipeline() *pipeline.Pipeline {
+	dedupSize := ck.config.Pipeline.DedupSize
+	if dedupSize <= 0 {
+		dedupSize = 1000
+	}
+
+	p := pipeline.New(
+		pipeline.NewStage(pipeline.StageRefresh, ck.refreshStage),
+		pipeline.NewStage(pipeline.StagePrefilter, ck.prefilterStage),
+		pipeline.Decode(),
+		pipeline.Dedup(dedupSize),
+		pipeline.NewStage(pipeline.StageMatch, ck.matchStage),
+		pipeline.NewStage(pipeline.StageEnrich, ck.enrichStage),
+		pipeline.NewStage(pipeline.StageFormat, ck.formatStage),
+		pipeline.NewStage(pipeline.StageDeliver, ck.deliverStage),
+	)
+	if ck.lanes != nil {
+		p.InsertAfter(pipeline.StageMatch, pipeline.Lane(ck.lanes))
+	}
+	for _, hc := range ck.config.Hooks {
+		if hc.Command != "" {
+			p.InsertBefore(pipeline.StageDeliver, pipeline.Hook(ck.pipelineLogger, hc))
+		}
+	}
+	p.Use(ck.stageMetrics.Middleware(), pipeline.Tracing())
+	if ck.config.Pipeline.LogStages {
+		p.Use(pipeline.Sample(ck.config.Pipeline.LogSampleEvery, pipeline.Logging(ck.pipelineLogger)))
+	}
+	if ck.config.Pipeline.Trace {
+		p.Use(pipeline.Sample(ck.config.Pipeline.LogSampleEvery, pipeline.Trace()))
+	}
+	return p
+}
+
+// refreshStage sends the periodic status message and refreshes the map systems
+func (ck *Checker) refreshStage(ctx context.Context, ev *pipeline.Event) (bool, error) {
+	// the message isn't decoded yet
+	killID := zkill.PeekID(ev.Raw)
+	systemID, _ := zkill.Peek(ev.Raw)
+
+	// Possibly send a status update; only the worker that moves the time on sends it
+	now := time.Now()
+	lastStatus := ck.lastDiscordStatusTime.Load()
+	minSinceLastStatus := now.Sub(time.Unix(0, lastStatus)).Minutes()
+	if int(minSinceLastStatus) > ck.statusInterval() && ck.lastDiscordStatusTime.CompareAndSwap(lastStatus, now.UnixNano()) {
+		ck.sendStatus(ctx)
+	}
+
+	// Possibly refresh systems from API, unless another kill already is
+	minSinceLastSystems := now.Sub(time.Unix(0, ck.lastUpdateTime.Load())).Minutes()
+	ck.killLogger(ctx).Printf("[ZKill] killId=%d, solarSystem=%d, lastSysUpdate=%.1f mins, lastStatus=%.1f mins",
+		killID, systemID, minSinceLastSystems, minSinceLastStatus)
+	if int(minSinceLastSystems) > ck.minToGetLatestSystems && ck.refreshing.CompareAndSwap(false, true) {
+		defer ck.refreshing.Store(false)
+		if err := ck.updateSystems(ctx); err != nil {
+			ck.killLogger(ctx).Printf("Error updating systems: %v", err)
+		}
+	}
+	return true, nil
+}
+
+// matchStage classifies the kill; unmatched kills stop here
+func (ck *Checker) matchStage(ctx context.Context, ev *pipeline.Event) (bool, error) {
+	return ck.match(ctx, ev, true)
+}
+
+// dryMatchStage classifies the kill like matchStage, but leaves auto-ignore,
+// its announcements and the match counts alone, for replays
+func (ck *Checker) dryMatchStage(ctx context.Context, ev *pipeline.Event) (bool, error) {
+	return ck.match(ctx, ev, false)
+}
+
+// match classifies the kill; live records it for auto-ignore and the stats
+func (ck *Checker) match(ctx context.Context, ev *pipeline.Event, live bool) (bool, error) {
+	st := ck.state()
+	result, location := ck.classify(ev.Zkill, st.index, st.systems, st.characters)
+	fleetAttacker := ck.fleetAttacker(ev.Zkill)
+	if result.Kind == notify.KindChain && fleetAttacker != 0 {
+		result = Match{Reason: notify.MatchReason{Text: fmt.Sprintf(
+			"%s, but attacker %d is in our fleet", result.Reason.Text, fleetAttacker)}}
+	}
+	if ck.autoIgnore != nil {
+		if live {
+			ck.trackFriendlyKills(ctx, ev.Zkill, st.index)
+		}
+		if result.Kind == notify.KindChain && ck.autoIgnore.ignored(ev.Zkill.SolarSystemID) {
+			result = Match{Reason: notify.MatchReason{Text: fmt.Sprintf(
+				"%s, but the system is auto-ignored for friendly activity", result.Reason.Text)}}
+		}
+	}
+	if result.Kind == notify.KindChain && ck.opAction() == OpModeSuppress {
+		result = Match{Reason: notify.MatchReason{Text: fmt.Sprintf(
+			"%s, but a fleet op is on until %s UTC", result.Reason.Text, ck.opUntil().UTC().Format("15:04"))}}
+	}
+	if ck.config.FriendlyDanger.Enabled && fleetAttacker == 0 {
+		ev.Friendlies = friendliesAt(ev.Zkill, st.index, st.characters)
+		if result.Kind == "" && len(ev.Friendlies) > 0 {
+			result = Match{Kind: notify.KindDanger, Reason: notify.MatchReason{
+				Rule: notify.RuleFriendlyDanger,
+				ID:   int64(ev.Zkill.SolarSystemID),
+				Text: fmt.Sprintf("%d map characters in system %d", len(ev.Friendlies), ev.Zkill.SolarSystemID),
+			}}
+		}
+	}
+	if result.Kind == notify.KindCorpKill {
+		result = ck.crossPost(ev, result)
+	}
+	if group := ck.rules.blocking(result.Reason.Rule); result.Kind != "" && group != "" {
+		result = Match{Reason: notify.MatchReason{Text: fmt.Sprintf(
+			"%s, but rule group %s is disabled", result.Reason.Text, group)}}
+	}
+	if result.Reason.Rule != "" {
+		ev.Note("rule", result.Reason.Rule)
+	}
+	if result.Reason.Text != "" {
+		ev.Note("reason", result.Reason.Text)
+	}
+	if result.Kind == "" {
+		ev.Reason = result.Reason
+		return false, nil
+	}
+	if result.Kind == notify.KindChain && result.System == nil {
+		return false, fmt.Errorf("filter matched chain kill %d without a system", ev.Zkill.KillmailID)
+	}
+	ev.Kind = result.Kind
+	ev.IsKill = result.IsKill
+	ev.BothSides = result.BothSides
+	ev.System = result.System
+	ev.Location = location
+	ev.Reason = result.Reason
+	switch ev.Reason.Rule {
+	case notify.RuleMappedVictim:
+		ev.Route = ck.config.MappedCharacters.VictimSinks
+	case notify.RuleMappedCharacter:
+		ev.Route = ck.config.MappedCharacters.AttackerSinks
+	}
+	if ev.BothSides && len(ck.config.BothSides.Sinks) > 0 {
+		ev.Route = ck.config.BothSides.Sinks
+	} else if killmail.IsAbyssal(ev.Zkill.SolarSystemID) && len(ck.config.Abyssal.Sinks) > 0 {
+		ev.Route = ck.config.Abyssal.Sinks
+	} else if len(ck.config.FactionWarfare.Sinks) > 0 && ck.factionWarfare(ev.Zkill) != "" {
+		ev.Route = ck.config.FactionWarfare.Sinks
+	}
+	ev.Priority = ck.priority(ev)
+	ev.Note("priority", ev.Priority.String())
+	if live {
+		ck.stats.matched(ev.Kind, ev.IsKill)
+	}
+	return true, nil
+}
+
+// crossPost applies the crossPost setting to a corp kill in a chain system:
+// it becomes a chain alert, or the chain alert is sent as well
+func (ck *Checker) crossPost(ev *pipeline.Event, result Match) Match {
+	mode := ck.config.CrossPost.mode(result.Reason.Rule)
+	if mode == CrossPostCorp {
+		return result
+	}
+	sys := ck.chainSystem(ev.Zkill.SolarSystemID)
+	if sys == nil {
+		return result
+	}
+	if mode == CrossPostBoth {
+		ev.CrossPostSystem = sys
+		return result
+	}
+	return Match{Kind: notify.KindChain, System: sys, Reason: notify.MatchReason{
+		Rule: notify.RuleChainSystem,
+		ID:   int64(sys.SystemId),
+		Text: fmt.Sprintf("chain system %d alias %s (also %s)", sys.SystemId, sys.Alias, result.Reason.Text),
+	}}
+}
+
+// chainSystem returns the mapped system with the given ID, unless it is ignored
+func (ck *Checker) chainSystem(id int) *killmail.SystemInfo {
+	st := ck.state()
+	if _, ignored := st.ignored[id]; ignored {
+		return nil
+	}
+	if sys, ok := st.index.System(id); ok {
+		return &sys
+	}
+	return nil
+}
+
+// enrichStage resolves names from ESI for corp and location kills; chain alerts
+// use zKill data plus a summary of the attackers
+func (ck *Checker) enrichStage(ctx context.Context, ev *pipeline.Event) (bool, error) {
+	if budget := ck.enrichBudget(); budget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, budget)
+		defer cancel()
+	}
+	if ev.Kind == notify.KindChain {
+		ev.Kill = killmail.FlattenZkill(ev.Zkill)
+		ck.summarizeAttackers(ctx, &ev.Kill)
+		ck.addThreatSummary(ctx, &ev.Kill)
+		ev.Kill.FactionWarfare = ck.factionWarfare(ev.Zkill)
+		return true, nil
+	}
+
+	fkm, err := ck.enrichKill(ctx, ev.Kind, ev.IsKill, ev.Raw)
+	if err != nil {
+		ck.killLogger(ctx).Printf("GetKillDetails error: %v", err)
+	}
+	if err != nil || ctx.Err() != nil {
+		ck.killLogger(ctx).Printf("Kill %d only partly enriched; sending IDs for unresolved names", ev.Zkill.KillmailID)
+		fkm.FillMissing(ev.Zkill)
+		ev.Partial = true
+		ev.Note("partial", "true")
+	}
+	fkm.FactionWarfare = ck.factionWarfare(ev.Zkill)
+	ev.Kill = fkm
+	return true, nil
+}
+
+// enrichKill fetches a corp, location or danger kill's details from ESI
+func (ck *Checker) enrichKill(ctx context.Context, kind notify.Kind, isKill bool, raw []byte) (killmail.FlattenedKillMail, error) {
+	// We’ll pass the original raw message, which includes zkb hash
+	fkm, err := ck.esi.GetKillDetails(ctx, raw)
+	if kind == notify.KindLocation || kind == notify.KindDanger {
+		ck.summarizeAttackers(ctx, &fkm)
+	}
+	if !isKill {
+		ck.addThreatSummary(ctx, &fkm)
+	}
+	return fkm, err
+}
+
+// formatStage builds the sink-agnostic notification
+func (ck *Checker) formatStage(ctx context.Context, ev *pipeline.Event) (bool, error) {
+	return ck.format(ctx, ev, true)
+}
+
+// dryFormatStage builds the notification like formatStage, without teaching
+// the new group tracker the kill's attackers
+func (ck *Checker) dryFormatStage(ctx context.Context, ev *pipeline.Event) (bool, error) {
+	return ck.format(ctx, ev, false)
+}
+
+// format builds the notification; live records the attackers for new group alerts
+func (ck *Checker) format(ctx context.Context, ev *pipeline.Event, live bool) (bool, error) {
+	n := notify.Notification{
+		Kind:          ev.Kind,
+		KillMailID:    ev.Zkill.KillmailID,
+		AttackerCount: len(ev.Zkill.Attackers),
+		IsKill:        ev.IsKill,
+		BothSides:     ev.BothSides,
+		Location:      ev.Location,
+		Reason:        ev.Reason,
+		Friendlies:    ev.Friendlies,
+		Partial:       ev.Partial,
+		Kill:          &ev.Kill,
+	}
+	if ev.Kind == notify.KindChain {
+		n.SystemAlias = ev.System.Alias
+		n.RouteHome = ck.routeHome(ev.System.SystemId)
+		n.MapURL = ck.mapLink(*ev.System)
+		n.Quiet = ck.opAction() == OpModeQuiet
+		if ck.groups != nil && live {
+			n.NewGroups = ck.newGroups(ctx, ev.Kill.Attackers)
+		}
+	} else {
+		n.SystemAlias = ev.Kill.SystemName
+		n.AttackerCount = len(ev.Kill.Attackers)
+	}
+	// the notification outlives the message when held, edited later or
+	// posted as a webhook payload, so big kills drop their attacker list
+	ck.trimAttackers(&ev.Kill)
+	ev.Notification = n
+	if sys := ev.CrossPostSystem; sys != nil {
+		ev.CrossPost = &notify.Notification{
+			Kind:          notify.KindChain,
+			KillMailID:    ev.Zkill.KillmailID,
+			SystemAlias:   sys.Alias,
+			AttackerCount: len(ev.Zkill.Attackers),
+			Location:      ev.Location,
+			Reason: notify.MatchReason{
+				Rule: notify.RuleChainSystem,
+				ID:   int64(sys.SystemId),
+				Text: fmt.Sprintf("chain system %d alias %s (also %s)", sys.SystemId, sys.Alias, ev.Reason.Text),
+			},
+			RouteHome: ck.routeHome(sys.SystemId),
+			MapURL:    ck.mapLink(*sys),
+			Quiet:     true,
+			Kill:      &ev.Kill,
+		}
+	}
+	return true, nil
+}
+
+// deliverStage sends the notification to every sink the event is routed to, or
+// holds it for aggregation
+func (ck *Checker) deliverStage(ctx context.Context, ev *pipeline.Event) (bool, error) {
+	ctx = lanes.WithPriority(ctx, int(ev.Priority))
+	if ck.sieges != nil {
+		ck.trackSiege(ctx, ev)
+	}
+	if ck.threat != nil {
+		ck.trackThreat(ctx, ev)
+	}
+	if ck.timers != nil {
+		ck.trackTimer(ctx, ev)
+	}
+	if ck.federated != nil {
+		// a peer's alert for this kill would repeat ours
+		ck.federated.add(ev.Zkill.KillmailID)
+	}
+	if delay := ck.aggregateDelay(ev.Reason.Rule); delay > 0 {
+		ck.hold(ctx, ev, delay)
+		return true, nil
+	}
+	if len(ck.fallbacks) > 0 {
+		ck.deliverAll(ctx, ev, ev.Notification)
+		if ev.CrossPost != nil {
+			ck.deliverAll(ctx, ev, *ev.CrossPost)
+		}
+		return true, nil
+	}
+	for _, s := range ck.sinks {
+		if !ev.WantsSink(s.Name()) {
+			continue
+		}
+		ck.deliver(ctx, ev, s, ev.Notification)
+		if ev.CrossPost != nil {
+			ck.deliver(ctx, ev, s, *ev.CrossPost)
+		}
+	}
+	return true, nil
+}
+
+// deliver sends one notification to one sink and records the outcome
+func (ck *Checker) deliver(ctx context.Context, ev *pipeline.Event, s notify.Sink, n notify.Notification) error {
+	sendCtx, span := tracing.Start(ctx, "deliver "+s.Name(), tracing.KindClient)
+	span.SetAttr("killmail.id", n.KillMailID)
+	var (
+		ref, url string
+		err      error
+	)
+	linker, linked := s.(notify.LinkSink)
+	es, editable := s.(notify.EditableSink)
+	valueUpdate := ck.valueUpdateDelay() > 0 && n.Kind == notify.KindCorpKill
+	resolve := n.Partial && ck.config.Pipeline.EditWhenResolved && n.Kind == notify.KindCorpKill
+	if editable && (valueUpdate || resolve) {
+		ref, err = es.SendRef(sendCtx, n)
+		switch {
+		case ref == "":
+		case resolve:
+			ck.resolvePartial(ctx, es, ref, ev, n, valueUpdate)
+		default:
+			ck.scheduleValueUpdate(ctx, es, ref, n)
+		}
+	} else if as, ok := s.(notify.AckSink); ok && ck.config.Acknowledgments.Enabled && (n.Kind == notify.KindChain || n.Kind == notify.KindDanger) && !n.Quiet {
+		ref, err = as.SendAckable(sendCtx, n)
+		if ref != "" {
+			ck.acks.watch(as, s.Name(), ref, n, ev.Priority)
+		}
+	} else if linked {
+		url, err = linker.SendLink(sendCtx, n)
+	} else {
+		err = s.Send(sendCtx, n)
+	}
+	if linked && url == "" && ref != "" {
+		url = linker.Link(ref)
+	}
+	span.RecordError(err)
+	span.End()
+	ev.Deliveries = append(ev.Deliveries, pipeline.Delivery{Sink: s.Name(), Err: err, Ref: ref, URL: url})
+	if err == nil {
+		ck.stats.delivered()
+	} else {
+		ck.killLogger(ctx).Printf("Error sending %s notification to %s: %v", n.Kind, s.Name(), err)
+		ck.stats.sinkFailed(s.Name())
+		tags := ck.errorTags(ev, pipeline.StageDeliver)
+		tags["sink"] = s.Name()
+		ck.reportError(ctx, err, tags)
+	}
+	return err
+}
+
+// classify runs the filter, then falls back to the configured locations; it
+// also returns the kill's location name, if any
+func (ck *Checker) classify(zm killmail.ZkillMail, ix *filter.Index, systems []killmail.SystemInfo, chars []killmail.MapCharacter) (Match, string) {
+	var result Match
+	if mf, builtin := ck.matcher.(matcherFilter); builtin {
+		result = toMatch(mf.matcher.MatchIndex(zm, ix))
+	} else {
+		result = ck.matcher.Match(zm, systems, chars)
+	}
+	if result.Kind == notify.KindChain && ck.config.ChainAlerts.RequirePlayerAttacker {
+		if why := npcOnly(zm); why != "" {
+			result = Match{Reason: notify.MatchReason{Text: result.Reason.Text + ", but " + why}}
+		}
+	}
+	if result.Kind == notify.KindChain && killmail.IsAbyssal(zm.SolarSystemID) {
+		// a pocket is never part of a chain, whatever a custom filter thinks
+		result = Match{Reason: notify.MatchReason{Text: result.Reason.Text + ", but the kill was in Abyssal space"}}
+	}
+	location := ck.locationName(zm.ZKB.LocationID)
+	if result.Kind == "" && location != "" {
+		result = Match{Kind: notify.KindLocation, Reason: notify.MatchReason{
+			Rule: notify.RuleLocation,
+			ID:   zm.ZKB.LocationID,
+			Text: fmt.Sprintf("location %d (%s)", zm.ZKB.LocationID, location),
+		}}
+	}
+	if result.Kind == "" {
+		if space := ck.triglavianAlert(ck.state(), zm.SolarSystemID); space != "" {
+			result, location = triglavianMatch(zm, space), space
+		}
+	}
+	if result.Kind == "" && ck.fwAlerts() {
+		if m, ok := ck.fwMatch(zm); ok {
+			result = m
+		}
+	}
+	return result, location
+}
+
+// npcOnly says why a chain kill is NPC business, e.g. a Sleeper site gone
+// wrong or a rat dying, or returns "" when players were involved
+func npcOnly(zm killmail.ZkillMail) string {
+	if players, _ := killmail.CountAttackers(zm.Attackers); players == 0 {
+		return "no player attackers"
+	}
+	// NPC corporations are numbered 1000000-1999999; structures have no character but a player corp
+	if zm.Victim.CharacterID == 0 && zm.Victim.CorporationID > 0 && zm.Victim.CorporationID < 2000000 {
+		return "the victim is an NPC"
+	}
+	return ""
+}
+
+// locationName returns the configured name for a zKillboard locationID, or ""
+func (ck *Checker) locationName(id int64) string {
+	if id == 0 {
+		return ""
+	}
+	for _, loc := range ck.config.Locations {
+		if loc.ID == id {
+			return loc.Name
+		}
+	}
+	return ""
+}
+
+// trimAttackers applies pipeline.maxAttackers to a kill about to be notified
+func (ck *Checker) trimAttackers(fkm *killmail.FlattenedKillMail) {
+	max := ck.config.Pipeline.MaxAttackers
+	if max <= 0 {
+		max = 100
+	}
+	if ck.config.Pipeline.FullAttackerDetail {
+		max = len(fkm.Attackers)
+	}
+	fkm.TrimAttackers(max)
+}
+
+// addThreatSummary looks up zKillboard's stats for the final blow's corporation, when enabled
+func (ck *Checker) addThreatSummary(ctx context.Context, fkm *killmail.FlattenedKillMail) {
+	// the Abyss's NPCs have no zKillboard stats worth a lookup
+	if ck.threats == nil || killmail.IsAbyssal(fkm.SolarSystemID) {
+		return
+	}
+	corpID := fkm.FinalAttackerCorpID
+	if corpID == 0 {
+		// chain alerts skip ESI, so find the final blow in the zKill attackers
+		for _, att := range fkm.Attackers {
+			if att.FinalBlow {
+				corpID = att.CorporationID
+				break
+			}
+		}
+	}
+	if corpID == 0 {
+		return
+	}
+	// failures are logged, and cached, by the client
+	stats, err := ck.threats.Corporation(ctx, corpID)
+	if err != nil {
+		return
+	}
+	fkm.ThreatSummary = stats.Summary()
+}
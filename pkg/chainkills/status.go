@@ -0,0 +1,260 @@
+package chainkills
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/internal/discord"
+	"github.com/guarzo/eve-chainkills/internal/schema"
+	"github.com/guarzo/eve-chainkills/pkg/notify"
+)
+
+// Status message verbosity levels
+const (
+	StatusText     = "text"     // the plain "Chainkills checker running." line
+	StatusSummary  = "summary"  // an embed with the headline numbers (default)
+	StatusDetailed = "detailed" // the summary plus per-stage and per-sink figures
+)
+
+// statusColor is the embed stripe for status reports
+const statusColor = 0x5865F2
+
+// checkerStats counts what a checker has done since it started
+type checkerStats struct {
+	started    time.Time
+	processed  atomic.Int64
+	chainKills atomic.Int64
+	corpKills  atomic.Int64
+	corpLosses atomic.Int64
+	locations  atomic.Int64
+	dangers    atomic.Int64
+	sent       atomic.Int64
+
+	seenHour    rollingCounter
+	matchedHour rollingCounter
+	sentHour    rollingCounter
+	errorsHour  rollingCounter
+
+	mu         sync.Mutex
+	sinkErrors map[string]int64
+	// errors counts failures by category, e.g. ErrorsPipeline
+	errors map[string]int64
+}
+
+func newCheckerStats() *checkerStats {
+	return &checkerStats{started: time.Now(), sinkErrors: map[string]int64{}, errors: map[string]int64{}}
+}
+
+func (cs *checkerStats) seen() {
+	cs.processed.Add(1)
+	cs.seenHour.add(1)
+}
+
+func (cs *checkerStats) delivered() {
+	cs.sent.Add(1)
+	cs.sentHour.add(1)
+}
+
+func (cs *checkerStats) failed(category string) {
+	cs.mu.Lock()
+	cs.errors[category]++
+	cs.mu.Unlock()
+	cs.errorsHour.add(1)
+}
+
+// errorCounts returns a copy of the failures by category
+func (cs *checkerStats) errorCounts() map[string]int64 {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	out := make(map[string]int64, len(cs.errors))
+	for c, n := range cs.errors {
+		out[c] = n
+	}
+	return out
+}
+
+func (cs *checkerStats) matched(kind notify.Kind, isKill bool) {
+	cs.matchedHour.add(1)
+	switch {
+	case kind == notify.KindChain:
+		cs.chainKills.Add(1)
+	case kind == notify.KindLocation:
+		cs.locations.Add(1)
+	case kind == notify.KindDanger:
+		cs.dangers.Add(1)
+	case isKill:
+		cs.corpKills.Add(1)
+	default:
+		cs.corpLosses.Add(1)
+	}
+}
+
+func (cs *checkerStats) sinkFailed(name string) {
+	cs.mu.Lock()
+	cs.sinkErrors[name]++
+	cs.mu.Unlock()
+	cs.failed(ErrorsSink)
+}
+
+// esiStats is implemented by the built-in ESI client
+type esiStats interface {
+	Stats() (requests, failures int64)
+}
+
+// statusInterval is how often the status report is sent
+func (ck *Checker) statusInterval() int {
+	if ck.config.Status.IntervalMinutes > 0 {
+		return ck.config.Status.IntervalMinutes
+	}
+	return ck.minToSendDiscord
+}
+
+// sendStatus posts the periodic status report to the info webhook
+func (ck *Checker) sendStatus(ctx context.Context) {
+	verbosity := strings.ToLower(ck.config.Status.Verbosity)
+	if verbosity == StatusText {
+		ck.sendInfoMessage(ctx, "Chainkills checker running.")
+		return
+	}
+
+	embed := ck.statusEmbed(verbosity == StatusDetailed)
+	ck.logger.Printf("Sending status report: %s", embed.Description)
+	id, token := ck.infoWebhook(SeverityLifecycle)
+	err := ck.webhooks.SendWebhook(ctx, ck.logger, id, token, "", &embed)
+	if err != nil {
+		ck.logger.Printf("Error sending status report: %v", err)
+	}
+}
+
+// statusEmbed builds the status report from the checker's counters
+func (ck *Checker) statusEmbed(detailed bool) discord.Embed {
+	st := ck.stats
+	title := "Chainkills checker running"
+	if ck.config.Name != "" {
+		title += " (" + ck.config.Name + ")"
+	}
+
+	esiRate := "n/a"
+	if es, ok := ck.esi.(esiStats); ok {
+		if requests, failures := es.Stats(); requests > 0 {
+			esiRate = fmt.Sprintf("%.1f%% (%d/%d)", float64(failures)*100/float64(requests), failures, requests)
+		} else {
+			esiRate = "0 requests"
+		}
+	}
+
+	lastSync := "never"
+	if last := ck.lastUpdateTime.Load(); last != 0 {
+		lastSync = fmt.Sprintf("%s ago", time.Since(time.Unix(0, last)).Truncate(time.Second))
+	}
+
+	mapped, members := ck.characterCounts()
+	embed := discord.Embed{
+		Title:       title,
+		Description: fmt.Sprintf("Up %s, %d kills processed", formatUptime(time.Since(st.started)), st.processed.Load()),
+		Color:       statusColor,
+		Timestamp:   time.Now().UTC().Format(time.RFC3339),
+		Fields: []discord.Field{
+			{Name: "Chain kills", Value: fmt.Sprint(st.chainKills.Load()), Inline: true},
+			{Name: "Corp kills", Value: fmt.Sprint(st.corpKills.Load()), Inline: true},
+			{Name: "Corp losses", Value: fmt.Sprint(st.corpLosses.Load()), Inline: true},
+			{Name: "Tracked systems", Value: fmt.Sprint(len(ck.state().systems)), Inline: true},
+			{Name: "Map characters", Value: fmt.Sprint(mapped), Inline: true},
+			{Name: "Last map sync", Value: lastSync, Inline: true},
+			{Name: "ESI error rate", Value: esiRate, Inline: true},
+			{Name: "Last hour", Value: lastHour(ck.Metrics().LastHour), Inline: true},
+		},
+	}
+
+	if len(ck.config.Locations) > 0 {
+		embed.Fields = append(embed.Fields, discord.Field{Name: "Location kills", Value: fmt.Sprint(st.locations.Load()), Inline: true})
+	}
+	if ck.config.CorpMembers.Enabled {
+		embed.Fields = append(embed.Fields, discord.Field{Name: "Corp members", Value: fmt.Sprint(members), Inline: true})
+	}
+	if ck.config.FriendlyDanger.Enabled {
+		embed.Fields = append(embed.Fields, discord.Field{Name: "Friendlies in danger", Value: fmt.Sprint(st.dangers.Load()), Inline: true})
+	}
+	if detailed {
+		embed.Fields = append(embed.Fields,
+			discord.Field{Name: "Stages", Value: ck.stageSummary()},
+			discord.Field{Name: "Sink errors", Value: st.sinkErrorSummary()},
+			discord.Field{Name: "Decode failures", Value: decodeFailureSummary()},
+		)
+	}
+	return embed
+}
+
+// lastHour renders e.g. "56 seen, 2 matched, 2 sent, 0 errors"
+func lastHour(w MetricsWindow) string {
+	return fmt.Sprintf("%d seen, %d matched, %d sent, %d errors", w.Seen, w.Matched, w.Sent, w.Errors)
+}
+
+// stageSummary lists each stage's count, drops and mean time
+func (ck *Checker) stageSummary() string {
+	snap := ck.stageMetrics.Snapshot()
+	var lines []string
+	for _, name := range ck.pipeline.Stages() {
+		s, ok := snap[name]
+		if !ok || s.Processed == 0 {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("`%s` %d in, %d dropped, %d errors, avg %s",
+			name, s.Processed, s.Dropped, s.Errors, (s.TotalTime/time.Duration(s.Processed)).Truncate(time.Microsecond)))
+	}
+	if len(lines) == 0 {
+		return "none yet"
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (cs *checkerStats) sinkErrorSummary() string {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if len(cs.sinkErrors) == 0 {
+		return "none"
+	}
+	names := make([]string, 0, len(cs.sinkErrors))
+	for name := range cs.sinkErrors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s: %d", name, cs.sinkErrors[name])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// decodeFailureSummary lists the upstream sources whose payloads failed to decode
+func decodeFailureSummary() string {
+	var parts []string
+	for name, st := range schema.Stats() {
+		if st.Failures > 0 {
+			parts = append(parts, fmt.Sprintf("%s: %d", name, st.Failures))
+		}
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
+
+// formatUptime renders e.g. "3d 4h 12m"
+func formatUptime(d time.Duration) string {
+	d = d.Truncate(time.Minute)
+	days := d / (24 * time.Hour)
+	d -= days * 24 * time.Hour
+	hours := d / time.Hour
+	d -= hours * time.Hour
+	if days > 0 {
+		return fmt.Sprintf("%dd %dh %dm", days, hours, d/time.Minute)
+	}
+	return fmt.Sprintf("%dh %dm", hours, d/time.Minute)
+}
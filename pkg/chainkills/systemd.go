@@ -0,0 +1,139 @@
+package chainkills
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/internal/systemd"
+	"github.com/guarzo/eve-chainkills/internal/zkill"
+)
+
+// feedState tracks killstream health for systemd's STATUS and watchdog
+type feedState struct {
+	connected    atomic.Bool
+	messages     atomic.Int64
+	duplicates   atomic.Int64 // killmails already received, e.g. on another channel
+	lastActivity atomic.Int64 // unix nanos of the last message, pong or (re)connect
+	readyOnce    sync.Once
+
+	skippedMu sync.Mutex
+	// skipped counts frames that weren't killmails, by zkill.Frame
+	skipped map[zkill.Frame]int64
+}
+
+func (fs *feedState) received() {
+	fs.messages.Add(1)
+	fs.lastActivity.Store(time.Now().UnixNano())
+}
+
+// duplicate counts a killmail already received; it still shows the socket is alive
+func (fs *feedState) duplicate() {
+	fs.duplicates.Add(1)
+	fs.lastActivity.Store(time.Now().UnixNano())
+}
+
+// pong records a websocket pong; like a message, it shows the socket is alive
+func (fs *feedState) pong() {
+	fs.lastActivity.Store(time.Now().UnixNano())
+}
+
+// skip counts a non-killmail frame; it still shows the socket is alive
+func (fs *feedState) skip(frame zkill.Frame) {
+	fs.lastActivity.Store(time.Now().UnixNano())
+	fs.skippedMu.Lock()
+	defer fs.skippedMu.Unlock()
+	if fs.skipped == nil {
+		fs.skipped = map[zkill.Frame]int64{}
+	}
+	fs.skipped[frame]++
+}
+
+// skippedFrames returns a copy of the non-killmail frame counts
+func (fs *feedState) skippedFrames() map[zkill.Frame]int64 {
+	fs.skippedMu.Lock()
+	defer fs.skippedMu.Unlock()
+	out := make(map[zkill.Frame]int64, len(fs.skipped))
+	for f, n := range fs.skipped {
+		out[f] = n
+	}
+	return out
+}
+
+// feedConnected marks the killstream up and tells systemd we're ready the first time
+func (h *Hub) feedConnected() {
+	h.feed.connected.Store(true)
+	h.feed.lastActivity.Store(time.Now().UnixNano())
+	h.feed.readyOnce.Do(func() {
+		h.notifySystemd(systemd.Ready)
+	})
+	h.notifySystemd(systemd.Status(h.feedStatus()))
+}
+
+func (h *Hub) feedDisconnected(err error) {
+	h.feed.connected.Store(false)
+	if h.inDowntime() {
+		h.notifySystemd(systemd.Status("EVE downtime; reconnecting to zKill"))
+		return
+	}
+	h.notifySystemd(systemd.Status(fmt.Sprintf("zKill socket closed (%v); reconnecting", err)))
+}
+
+// feedStatus is the one-line summary shown by systemctl status
+func (h *Hub) feedStatus() string {
+	if !h.feed.connected.Load() {
+		return "Waiting for zKill connection"
+	}
+	last := time.Unix(0, h.feed.lastActivity.Load())
+	return fmt.Sprintf("Connected; %d kills received, last activity %s ago, %d in flight",
+		h.feed.messages.Load(), time.Since(last).Truncate(time.Second), h.inflight.Load())
+}
+
+// feedHealthy is false once the feed has been silent longer than the
+// configured timeout, outside downtime
+func (h *Hub) feedHealthy() bool {
+	if h.feedTimeout <= 0 || h.inDowntime() {
+		return true
+	}
+	last := h.feed.lastActivity.Load()
+	return last != 0 && time.Since(time.Unix(0, last)) < h.feedTimeout
+}
+
+// runSystemd refreshes STATUS and pings the watchdog while the feed is healthy
+func (h *Hub) runSystemd(ctx context.Context) {
+	if !systemd.Enabled() {
+		return
+	}
+	watchdog := systemd.WatchdogInterval()
+	interval := 30 * time.Second
+	if watchdog > 0 {
+		interval = watchdog / 2
+		h.logger.Printf("[Hub] systemd watchdog enabled, pinging every %s", interval)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		h.notifySystemd(systemd.Status(h.feedStatus()))
+		if watchdog <= 0 {
+			continue
+		}
+		if h.feedHealthy() {
+			h.notifySystemd(systemd.Watchdog)
+		} else {
+			h.logger.Printf("[Hub] killstream silent for over %s; withholding watchdog ping", h.feedTimeout)
+		}
+	}
+}
+
+func (h *Hub) notifySystemd(state string) {
+	if _, err := systemd.Notify(state); err != nil {
+		h.logger.Printf("Error notifying systemd: %v", err)
+	}
+}
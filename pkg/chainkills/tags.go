@@ -0,0 +1,103 @@
+package chainkills
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/internal/killtags"
+)
+
+// ErrTagsDisabled is returned by the tag methods unless tags.path is set
+var ErrTagsDisabled = errors.New("chainkills: kill tags are not enabled")
+
+// TagKill puts a tag such as "srp-approved" on a kill; by says who, for the record
+func (h *Hub) TagKill(killID int64, tag, by string) error {
+	if h.tags == nil {
+		return ErrTagsDisabled
+	}
+	if err := h.tags.Add(killID, strings.ToLower(tag), by); err != nil {
+		return err
+	}
+	h.logger.Printf("[Tags] %s tagged kill %d %s", by, killID, tag)
+	return nil
+}
+
+// UntagKill takes a tag off a kill
+func (h *Hub) UntagKill(killID int64, tag, by string) error {
+	if h.tags == nil {
+		return ErrTagsDisabled
+	}
+	if err := h.tags.Remove(killID, strings.ToLower(tag), by); err != nil {
+		return err
+	}
+	h.logger.Printf("[Tags] %s untagged kill %d %s", by, killID, tag)
+	return nil
+}
+
+// KillTags returns a kill's tags
+func (h *Hub) KillTags(killID int64) ([]killtags.Tag, error) {
+	if h.tags == nil {
+		return nil, ErrTagsDisabled
+	}
+	return h.tags.Tags(killID), nil
+}
+
+// TaggedKills lists the kills tagged since, optionally only those with tag
+func (h *Hub) TaggedKills(tag string, since time.Time) (map[int64][]killtags.Tag, error) {
+	if h.tags == nil {
+		return nil, ErrTagsDisabled
+	}
+	return h.tags.Tagged(strings.ToLower(tag), since), nil
+}
+
+// checkTagReactions tags a watched alert's kill for each configured emoji someone reacted with
+func (ck *Checker) checkTagReactions(ctx context.Context, p *pendingAck) {
+	for emoji, tag := range ck.config.Tags.Reactions {
+		names, err := p.sink.Acks(ctx, p.ref, emoji)
+		if err != nil {
+			ck.logger.Errorf("[Tags] Error reading %s reactions to kill %d on %s: %v", emoji, p.killID, p.name, err)
+			continue
+		}
+		if len(names) == 0 {
+			continue
+		}
+		if err := ck.tags.Add(p.killID, tag, names[0]); err != nil {
+			ck.logger.Printf("[Tags] Error tagging kill %d %s: %v", p.killID, tag, err)
+		}
+	}
+}
+
+// tagDigest counts the tags put on kills in the last day, e.g.
+// "Kills tagged today: srp-approved 3, bait 1"
+func (ck *Checker) tagDigest() string {
+	since := time.Now().Add(-24 * time.Hour)
+	counts := map[string]int{}
+	for _, tags := range ck.tags.Tagged("", since) {
+		for _, t := range tags {
+			if !t.Time.Before(since) {
+				counts[t.Tag]++
+			}
+		}
+	}
+	if len(counts) == 0 {
+		return "Kills tagged today: none"
+	}
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	for i, name := range names {
+		names[i] = fmt.Sprintf("%s %d", name, counts[name])
+	}
+	return "Kills tagged today: " + strings.Join(names, ", ")
+}
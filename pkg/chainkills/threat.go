@@ -0,0 +1,262 @@
+package chainkills
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/pkg/notify"
+	"github.com/guarzo/eve-chainkills/pkg/pipeline"
+	"golang.org/x/exp/slices"
+)
+
+// Threat levels, from quiet to likely eviction
+const (
+	ThreatGreen  = "green"
+	ThreatYellow = "yellow"
+	ThreatRed    = "red"
+)
+
+// Threat signals, the keys of threat.weights
+const (
+	SignalNewGroup  = "new_group"      // an attacking group new to the chain
+	SignalCapital   = "capital_kill"   // a capital dying in the chain, or one of ours anywhere
+	SignalStructure = "structure_kill" // a structure dying in the chain, or one of ours anywhere
+	SignalNearHome  = "near_home"      // a chain kill within threat.nearHomeJumps of home
+)
+
+// defaultThreatWeights are the points each signal adds to the threat score
+var defaultThreatWeights = map[string]int{
+	SignalNewGroup:  3,
+	SignalCapital:   5,
+	SignalStructure: 4,
+	SignalNearHome:  1,
+}
+
+// threatNouns name the signals in the level change message
+var threatNouns = map[string]string{
+	SignalNewGroup:  "new hostile group",
+	SignalCapital:   "capital kill",
+	SignalStructure: "structure kill",
+	SignalNearHome:  "kill near home",
+}
+
+// ThreatSignal is one observation counted towards the threat level
+type ThreatSignal struct {
+	Kind   string    `json:"kind"`
+	Points int       `json:"points"`
+	Text   string    `json:"text"`
+	At     time.Time `json:"at"`
+}
+
+// ThreatAssessment is the current threat level and what makes it up
+type ThreatAssessment struct {
+	Level string `json:"level"`
+	Score int    `json:"score"`
+	// Since is when the level last changed
+	Since time.Time `json:"since"`
+	// Signals are those within the window, oldest first
+	Signals []ThreatSignal `json:"signals"`
+}
+
+// threatTracker scores recent signals into a threat level
+type threatTracker struct {
+	window        time.Duration
+	weights       map[string]int
+	yellow, red   int
+	nearHomeJumps int
+	capitals      []int
+
+	mu      sync.Mutex
+	signals []ThreatSignal
+	level   string
+	since   time.Time
+}
+
+func (tc ThreatConfig) validate() error {
+	for kind := range tc.Weights {
+		if _, ok := defaultThreatWeights[kind]; !ok {
+			return fmt.Errorf("threat.weights: unknown signal %q", kind)
+		}
+	}
+	if tc.YellowScore < 0 || tc.RedScore < 0 || (tc.RedScore > 0 && tc.RedScore < tc.YellowScore) {
+		return fmt.Errorf("threat.redScore must be at least threat.yellowScore")
+	}
+	return nil
+}
+
+func newThreatTracker(c ThreatConfig) *threatTracker {
+	tt := &threatTracker{window: 6 * time.Hour, weights: map[string]int{}, yellow: 8, red: 20, nearHomeJumps: 2,
+		capitals: notify.DefaultCapitalGroupIds, level: ThreatGreen, since: time.Now()}
+	for kind, w := range defaultThreatWeights {
+		tt.weights[kind] = w
+	}
+	for kind, w := range c.Weights {
+		tt.weights[kind] = w
+	}
+	if c.WindowHours > 0 {
+		tt.window = time.Duration(c.WindowHours) * time.Hour
+	}
+	if c.YellowScore > 0 {
+		tt.yellow = c.YellowScore
+	}
+	if c.RedScore > 0 {
+		tt.red = c.RedScore
+	}
+	if c.NearHomeJumps > 0 {
+		tt.nearHomeJumps = c.NearHomeJumps
+	}
+	if len(c.CapitalGroupIds) > 0 {
+		tt.capitals = c.CapitalGroupIds
+	}
+	return tt
+}
+
+// add records a signal at its kind's weight; zero-weight signals are dropped
+func (tt *threatTracker) add(kind, text string, now time.Time) {
+	points := tt.weights[kind]
+	if points == 0 {
+		return
+	}
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+	tt.signals = append(tt.signals, ThreatSignal{Kind: kind, Points: points, Text: text, At: now})
+}
+
+// assess drops signals older than the window and scores the rest
+func (tt *threatTracker) assess(now time.Time) ThreatAssessment {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+	cutoff := now.Add(-tt.window)
+	kept := tt.signals[:0]
+	for _, s := range tt.signals {
+		if s.At.After(cutoff) {
+			kept = append(kept, s)
+		}
+	}
+	tt.signals = kept
+
+	a := ThreatAssessment{Level: ThreatGreen, Since: tt.since, Signals: append([]ThreatSignal(nil), tt.signals...)}
+	for _, s := range tt.signals {
+		a.Score += s.Points
+	}
+	switch {
+	case a.Score >= tt.red:
+		a.Level = ThreatRed
+	case a.Score >= tt.yellow:
+		a.Level = ThreatYellow
+	}
+	if a.Level != tt.level {
+		a.Since = now
+	}
+	return a
+}
+
+// settle records the assessed level and returns the one before it
+func (tt *threatTracker) settle(level string, now time.Time) (previous string, changed bool) {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+	previous = tt.level
+	if level == previous {
+		return previous, false
+	}
+	tt.level, tt.since = level, now
+	return previous, true
+}
+
+// summary counts the signals by kind, e.g. "2 new hostile groups, 3 kills near home"
+func (a ThreatAssessment) summary() string {
+	counts := map[string]int{}
+	for _, s := range a.Signals {
+		counts[s.Kind]++
+	}
+	var parts []string
+	for _, kind := range []string{SignalNewGroup, SignalCapital, SignalStructure, SignalNearHome} {
+		if n := counts[kind]; n > 0 {
+			parts = append(parts, plural(n, threatNouns[kind]))
+		}
+	}
+	if len(parts) == 0 {
+		return "nothing of note"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Threat returns the current threat assessment; ok is false unless threat is enabled
+func (ck *Checker) Threat() (ThreatAssessment, bool) {
+	if ck.threat == nil {
+		return ThreatAssessment{}, false
+	}
+	return ck.threat.assess(time.Now()), true
+}
+
+// trackThreat records the signals in a notified kill
+func (ck *Checker) trackThreat(ctx context.Context, ev *pipeline.Event) {
+	now := time.Now()
+	where := ev.Notification.SystemAlias
+	for _, g := range ev.Notification.NewGroups {
+		name := g.Name
+		if name == "" {
+			name = fmt.Sprint(g.ID)
+		}
+		ck.threat.add(SignalNewGroup, fmt.Sprintf("%s new in %s", name, where), now)
+	}
+	if ev.Kind == notify.KindChain || (ev.Kind == notify.KindCorpKill && !ev.IsKill) {
+		name, groupID, err := ck.victimType(ctx, &ev.Kill)
+		if err != nil {
+			ck.killLogger(ctx).Printf("[Threat] Error fetching the type of kill %d: %v", ev.Kill.KillMailID, err)
+		}
+		structures := defaultStructureGroupIds
+		if ck.sieges != nil {
+			structures = ck.sieges.structures
+		}
+		switch {
+		case err != nil:
+		case slices.Contains(ck.threat.capitals, groupID):
+			ck.threat.add(SignalCapital, fmt.Sprintf("%s lost in %s", name, where), now)
+		case slices.Contains(structures, groupID):
+			ck.threat.add(SignalStructure, fmt.Sprintf("%s lost in %s", name, where), now)
+		}
+	}
+	if route := ev.Notification.RouteHome; ev.Kind == notify.KindChain && len(route) > 0 && len(route)-1 <= ck.threat.nearHomeJumps {
+		ck.threat.add(SignalNearHome, fmt.Sprintf("kill %d jumps from home in %s", len(route)-1, where), now)
+	}
+	ck.updateThreat(ctx)
+}
+
+// runThreat re-scores the threat level every minute so it falls as signals age out
+func (ck *Checker) runThreat(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			ck.updateThreat(ctx)
+		}
+	}
+}
+
+// updateThreat posts the threat level to the status channel when it changes
+func (ck *Checker) updateThreat(ctx context.Context) {
+	now := time.Now()
+	a := ck.threat.assess(now)
+	previous, changed := ck.threat.settle(a.Level, now)
+	if !changed {
+		return
+	}
+	ck.logger.Printf("[Threat] Level %s -> %s (score %d)", previous, a.Level, a.Score)
+	msg := fmt.Sprintf("Threat level is now %s (was %s), score %d: %s in the last %s.",
+		strings.ToUpper(a.Level), previous, a.Score, a.summary(), ck.threat.window)
+	id, token := ck.config.Threat.DiscordWebhookId, ck.config.Threat.DiscordWebhookToken
+	if id == "" {
+		ck.sendInfoMessage(ctx, msg)
+		return
+	}
+	if err := ck.webhooks.SendWebhook(ctx, ck.logger, id, token, msg, nil); err != nil {
+		ck.logger.Printf("[Threat] Error posting the threat level: %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package chainkills
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/pkg/notify"
+	"github.com/guarzo/eve-chainkills/pkg/pipeline"
+)
+
+func TestThreatLevels(t *testing.T) {
+	tt := newThreatTracker(ThreatConfig{WindowHours: 1, Weights: map[string]int{SignalNearHome: 0}})
+	t0 := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	for i, tc := range []struct {
+		kind  string
+		after time.Duration
+		score int
+		level string
+	}{
+		{SignalNewGroup, 0, 3, ThreatGreen},
+		// a zero weight turns a signal off
+		{SignalNearHome, time.Minute, 3, ThreatGreen},
+		{SignalStructure, 10 * time.Minute, 7, ThreatGreen},
+		{SignalNewGroup, 20 * time.Minute, 10, ThreatYellow},
+		{SignalCapital, 30 * time.Minute, 15, ThreatYellow},
+		{SignalCapital, 40 * time.Minute, 20, ThreatRed},
+	} {
+		now := t0.Add(tc.after)
+		tt.add(tc.kind, "signal", now)
+		a := tt.assess(now)
+		if a.Score != tc.score || a.Level != tc.level {
+			t.Errorf("%d: after %s scored %d %s, want %d %s", i, tc.kind, a.Score, a.Level, tc.score, tc.level)
+		}
+		tt.settle(a.Level, now)
+	}
+
+	// signals leave the window one by one and the level falls with them
+	a := tt.assess(t0.Add(time.Hour + 15*time.Minute))
+	if a.Score != 13 || a.Level != ThreatYellow || len(a.Signals) != 3 {
+		t.Errorf("an hour on scored %d %s from %d signals, want 13 yellow from 3", a.Score, a.Level, len(a.Signals))
+	}
+	if a.Since != t0.Add(time.Hour+15*time.Minute) {
+		t.Errorf("since %s, want the time the level changed", a.Since)
+	}
+	if got := a.summary(); got != "1 new hostile group, 2 capital kills" {
+		t.Errorf("summary %q", got)
+	}
+	if a := tt.assess(t0.Add(2 * time.Hour)); a.Score != 0 || a.Level != ThreatGreen || a.summary() != "nothing of note" {
+		t.Errorf("two hours on scored %d %s (%s), want 0 green", a.Score, a.Level, a.summary())
+	}
+
+	if _, changed := tt.settle(ThreatRed, t0.Add(2*time.Hour)); changed {
+		t.Error("settling on the same level reported a change")
+	}
+	if previous, changed := tt.settle(ThreatGreen, t0.Add(2*time.Hour)); !changed || previous != ThreatRed {
+		t.Errorf("settle = %s, %v, want a change from red", previous, changed)
+	}
+}
+
+func TestThreatValidate(t *testing.T) {
+	for _, tc := range []struct {
+		config ThreatConfig
+		err    string
+	}{
+		{config: ThreatConfig{Weights: map[string]int{SignalCapital: 10}, YellowScore: 5, RedScore: 10}},
+		{config: ThreatConfig{Weights: map[string]int{"gate_camp": 1}}, err: `unknown signal "gate_camp"`},
+		{config: ThreatConfig{YellowScore: 10, RedScore: 5}, err: "redScore must be at least"},
+		{config: ThreatConfig{YellowScore: -1}, err: "redScore must be at least"},
+	} {
+		err := tc.config.validate()
+		switch {
+		case tc.err == "" && err != nil:
+			t.Errorf("validate(%+v) = %v, want nil", tc.config, err)
+		case tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)):
+			t.Errorf("validate(%+v) = %v, want an error containing %q", tc.config, err, tc.err)
+		}
+	}
+}
+
+func TestTrackThreat(t *testing.T) {
+	_, ck, transport := newCommandTest(t, &Config{Threat: ThreatConfig{Enabled: true, YellowScore: 8}})
+	ctx := context.Background()
+
+	// a titan dies two jumps from home, killed by a group new to the chain
+	ev := &pipeline.Event{Kind: notify.KindChain}
+	ev.Kill.KillMailID, ev.Kill.VictimShipGroupID, ev.Kill.VictimShipName = 1, 30, "Avatar"
+	ev.Notification = notify.Notification{
+		SystemAlias: "c2",
+		NewGroups:   []notify.Group{{ID: 98000002, Name: "Evictors"}},
+		RouteHome:   []string{"c2", "c1", "home"},
+	}
+	ck.trackThreat(ctx, ev)
+
+	threat, _ := ck.Threat()
+	if threat.Score != 9 || threat.Level != ThreatYellow || threat.summary() != "1 new hostile group, 1 capital kill, 1 kill near home" {
+		t.Errorf("threat %d %s (%s), want 9 yellow from all three signals", threat.Score, threat.Level, threat.summary())
+	}
+	replies := transport.take()
+	if len(replies) != 1 || !strings.HasPrefix(replies[0], "Threat level is now YELLOW (was green), score 9") {
+		t.Errorf("posted %q, want the change to yellow", replies)
+	}
+
+	// a frigate further out adds nothing, and an unchanged level isn't posted again
+	ev = &pipeline.Event{Kind: notify.KindChain}
+	ev.Kill.KillMailID = 2
+	ev.Notification = notify.Notification{SystemAlias: "c5", RouteHome: []string{"c5", "c4", "c3", "c2", "c1", "home"}}
+	ck.trackThreat(ctx, ev)
+	if threat, _ := ck.Threat(); threat.Score != 9 {
+		t.Errorf("score %d after a frigate far from home, want 9", threat.Score)
+	}
+	if replies := transport.take(); len(replies) != 0 {
+		t.Errorf("posted %q without a level change", replies)
+	}
+}
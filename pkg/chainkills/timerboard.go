@@ -0,0 +1,196 @@
+package chainkills
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/internal/templates"
+	"github.com/guarzo/eve-chainkills/pkg/notify"
+	"github.com/guarzo/eve-chainkills/pkg/pipeline"
+	"golang.org/x/exp/slices"
+)
+
+// Timer kinds
+const (
+	TimerDestroyed  = "destroyed"  // a structure died
+	TimerAggression = "aggression" // a kill next to a structure, which is likely under attack
+)
+
+// defaultTimerPayload is the body sent when timerboard.payload is unset
+const defaultTimerPayload = `{"kind": {{ json .Kind }}, "system": {{ json .System }}, "system_id": {{ .SystemID }}, ` +
+	`"structure_type": {{ json .StructureType }}, "structure_name": {{ json .StructureName }}, ` +
+	`"timer": {{ if .Timer.IsZero }}null{{ else }}{{ json .Timer }}{{ end }}, ` +
+	`"kill_id": {{ .KillID }}, "kill_url": {{ json .KillURL }}, "instance": {{ json .Instance }}}`
+
+// TimerEntry is what the timerboard payload template renders
+type TimerEntry struct {
+	Kind     string
+	Instance string
+	System   string
+	SystemID int
+	// StructureType is the destroyed structure's type, e.g. "Astrahus"; empty for aggression
+	StructureType string
+	// StructureName is the structure a kill happened next to, when ESI could name it
+	StructureName string
+	// Timer is the estimated time the structure comes out of reinforcement; zero when there is none
+	Timer    time.Time
+	KillID   int64
+	KillURL  string
+	KillTime time.Time
+}
+
+// timerboard creates timerboard entries for structure kills
+type timerboard struct {
+	config     TimerboardConfig
+	payload    *template.Template
+	structures []int
+	aggression time.Duration
+	cooldown   time.Duration
+
+	mu sync.Mutex
+	// created is when an aggression entry was last made per structure location
+	created map[int64]time.Time
+}
+
+func (tc TimerboardConfig) validate() error {
+	if tc.URL == "" {
+		return nil
+	}
+	if _, err := tc.template(); err != nil {
+		return fmt.Errorf("timerboard.payload: %w", err)
+	}
+	if tc.Method != "" && tc.Method != http.MethodPost && tc.Method != http.MethodPut {
+		return fmt.Errorf("timerboard.method must be POST or PUT, not %q", tc.Method)
+	}
+	return nil
+}
+
+func (tc TimerboardConfig) template() (*template.Template, error) {
+	text := tc.Payload
+	if text == "" {
+		text = defaultTimerPayload
+	}
+	return template.New("timerboard").Funcs(templates.FuncMap(context.Background(), nil)).Parse(text)
+}
+
+func newTimerboard(c TimerboardConfig) (*timerboard, error) {
+	payload, err := c.template()
+	if err != nil {
+		return nil, err
+	}
+	tb := &timerboard{config: c, payload: payload, structures: defaultStructureGroupIds,
+		aggression: 24 * time.Hour, cooldown: time.Hour, created: map[int64]time.Time{}}
+	if c.Method == "" {
+		tb.config.Method = http.MethodPost
+	}
+	if len(c.StructureGroupIds) > 0 {
+		tb.structures = c.StructureGroupIds
+	}
+	if c.AggressionHours > 0 {
+		tb.aggression = time.Duration(c.AggressionHours) * time.Hour
+	}
+	if c.CooldownMinutes > 0 {
+		tb.cooldown = time.Duration(c.CooldownMinutes) * time.Minute
+	}
+	return tb, nil
+}
+
+// fresh reports whether a kill next to a structure should make an entry,
+// which it does once per cooldown so a fight doesn't fill the timerboard
+func (tb *timerboard) fresh(locationID int64, now time.Time) bool {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	if last, ok := tb.created[locationID]; ok && now.Sub(last) < tb.cooldown {
+		return false
+	}
+	for id, last := range tb.created {
+		if now.Sub(last) >= tb.cooldown {
+			delete(tb.created, id)
+		}
+	}
+	tb.created[locationID] = now
+	return true
+}
+
+// trackTimer creates a timerboard entry for a structure kill, or a kill next
+// to a structure. Killmails don't show reinforcement, so a kill at a structure
+// is the best hint one is being shot, and its timer is only an estimate.
+func (ck *Checker) trackTimer(ctx context.Context, ev *pipeline.Event) {
+	fkm := &ev.Kill
+	entry := TimerEntry{
+		Instance: ck.config.Name,
+		System:   fkm.SystemName,
+		SystemID: fkm.SolarSystemID,
+		KillID:   fkm.KillMailID,
+		KillURL:  fmt.Sprintf("https://zkillboard.com/kill/%d/", fkm.KillMailID),
+		KillTime: fkm.KillMailTime,
+	}
+	if ev.System != nil {
+		entry.System = ev.System.Alias
+	}
+	if entry.System == "" {
+		entry.System = fmt.Sprintf("SystemID:%d", fkm.SolarSystemID)
+	}
+
+	if fkm.Victim.CharacterID == 0 && fkm.Victim.CorporationID != 0 {
+		name, groupID, err := ck.victimType(ctx, fkm)
+		if err != nil {
+			ck.killLogger(ctx).Printf("[Timerboard] Error fetching the type of kill %d: %v", fkm.KillMailID, err)
+			return
+		}
+		if slices.Contains(ck.timers.structures, groupID) {
+			entry.Kind, entry.StructureType = TimerDestroyed, name
+			go ck.createTimer(context.WithoutCancel(ctx), entry)
+			return
+		}
+	}
+	if fkm.LocationID < 1_000_000_000_000 || !ck.timers.fresh(fkm.LocationID, time.Now()) {
+		// not next to an Upwell structure
+		return
+	}
+	entry.Kind, entry.StructureName = TimerAggression, fkm.NearestCelestial
+	at := fkm.KillMailTime
+	if at.IsZero() {
+		at = time.Now()
+	}
+	entry.Timer = at.Add(ck.timers.aggression).UTC()
+	go ck.createTimer(context.WithoutCancel(ctx), entry)
+}
+
+// createTimer sends one entry to the timerboard
+func (ck *Checker) createTimer(ctx context.Context, entry TimerEntry) {
+	var body bytes.Buffer
+	if err := ck.timers.payload.Execute(&body, entry); err != nil {
+		ck.killLogger(ctx).Errorf("[Timerboard] Error rendering the payload for kill %d: %v", entry.KillID, err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, ck.timers.config.Method, ck.timers.config.URL, &body)
+	if err != nil {
+		ck.killLogger(ctx).Errorf("[Timerboard] Error creating the request: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range ck.timers.config.Headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set(notify.KillIDHeader, strconv.FormatInt(entry.KillID, 10))
+	resp, err := ck.http.Do(req)
+	if err != nil {
+		ck.killLogger(ctx).Errorf("[Timerboard] Error creating a timer for kill %d: %v", entry.KillID, err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		ck.killLogger(ctx).Errorf("[Timerboard] Creating a timer for kill %d got status %d", entry.KillID, resp.StatusCode)
+		return
+	}
+	ck.killLogger(ctx).Printf("[Timerboard] Created a %s timer in %s for kill %d", entry.Kind, entry.System, entry.KillID)
+}
@@ -0,0 +1,197 @@
+package chainkills
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/internal/filter"
+	"github.com/guarzo/eve-chainkills/internal/seat"
+	"golang.org/x/exp/slices"
+)
+
+// Tracked source kinds
+const (
+	TrackedSourceURL  = "url"
+	TrackedSourceSeAT = "seat"
+)
+
+// trackedSourceMaxBytes caps the tracked list response
+const trackedSourceMaxBytes = 4 << 20
+
+// remoteTracked is the tracked list document fetched from trackedSource.url
+type remoteTracked struct {
+	InsightTrackedIds     []int   `json:"insightTrackedIds"`
+	TrackedCorporationIds []int   `json:"trackedCorporationIds"`
+	TrackedAllianceIds    []int   `json:"trackedAllianceIds"`
+	TrackedCharacterIds   []int64 `json:"trackedCharacterIds"`
+}
+
+func (rt remoteTracked) count() int {
+	return len(rt.InsightTrackedIds) + len(rt.TrackedCorporationIds) + len(rt.TrackedAllianceIds) + len(rt.TrackedCharacterIds)
+}
+
+// normalize sorts the lists and drops repeats, so unchanged lists compare equal
+func (rt *remoteTracked) normalize() {
+	for _, ids := range []*[]int{&rt.InsightTrackedIds, &rt.TrackedCorporationIds, &rt.TrackedAllianceIds} {
+		sort.Ints(*ids)
+		*ids = slices.Compact(*ids)
+	}
+	slices.Sort(rt.TrackedCharacterIds)
+	rt.TrackedCharacterIds = slices.Compact(rt.TrackedCharacterIds)
+}
+
+func (rt remoteTracked) equal(other remoteTracked) bool {
+	return slices.Equal(rt.InsightTrackedIds, other.InsightTrackedIds) &&
+		slices.Equal(rt.TrackedCorporationIds, other.TrackedCorporationIds) &&
+		slices.Equal(rt.TrackedAllianceIds, other.TrackedAllianceIds) &&
+		slices.Equal(rt.TrackedCharacterIds, other.TrackedCharacterIds)
+}
+
+func (tc TrackedSourceConfig) validate() error {
+	if tc.URL == "" {
+		return nil
+	}
+	if u, err := url.Parse(tc.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("trackedSource.url %q is not an http(s) URL", tc.URL)
+	}
+	if tc.RefreshMinutes < 0 {
+		return fmt.Errorf("trackedSource.refreshMinutes must not be negative")
+	}
+	switch tc.Kind {
+	case "", TrackedSourceURL:
+	case TrackedSourceSeAT:
+		if tc.Token == "" || len(tc.CorporationIds) == 0 {
+			return fmt.Errorf("trackedSource kind seat needs a token and corporationIds")
+		}
+	default:
+		return fmt.Errorf("trackedSource.kind must be url or seat, not %q", tc.Kind)
+	}
+	return nil
+}
+
+// pollTrackedSource refreshes the tracked IDs from trackedSource until ctx is done
+func (ck *Checker) pollTrackedSource(ctx context.Context) {
+	if _, builtin := ck.matcher.(matcherFilter); !builtin {
+		ck.logger.Warnf("[Tracking] trackedSource IDs only limit the killstream scope under a custom filter")
+	}
+	interval := 15 * time.Minute
+	if rm := ck.config.TrackedSource.RefreshMinutes; rm > 0 {
+		interval = time.Duration(rm) * time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var last remoteTracked
+	for {
+		rt, err := ck.fetchTrackedSource(ctx)
+		switch {
+		case err != nil:
+			ck.logger.Errorf("[Tracking] Error fetching tracked IDs, keeping the last list: %v", err)
+		case rt.count() == 0:
+			// more likely a broken endpoint than a coalition with no members
+			ck.logger.Errorf("[Tracking] trackedSource returned no IDs, keeping the last list")
+		case !rt.equal(last):
+			ck.applyTrackedSource(rt)
+			last = rt
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// fetchTrackedSource reads the tracked IDs from trackedSource
+func (ck *Checker) fetchTrackedSource(ctx context.Context) (remoteTracked, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	var (
+		rt  remoteTracked
+		err error
+	)
+	if ck.config.TrackedSource.Kind == TrackedSourceSeAT {
+		rt, err = ck.fetchSeAT(ctx)
+	} else {
+		rt, err = ck.fetchTrackedURL(ctx)
+	}
+	rt.normalize()
+	return rt, err
+}
+
+func (ck *Checker) fetchTrackedURL(ctx context.Context) (remoteTracked, error) {
+	var rt remoteTracked
+	tc := ck.config.TrackedSource
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tc.URL, nil)
+	if err != nil {
+		return rt, err
+	}
+	req.Header.Set("Accept", "application/json")
+	for k, v := range tc.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := ck.http.Do(req)
+	if err != nil {
+		return rt, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return rt, fmt.Errorf("%s got status %d", tc.URL, resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, trackedSourceMaxBytes))
+	if err != nil {
+		return rt, err
+	}
+	if body = bytes.TrimSpace(body); len(body) > 0 && body[0] == '[' {
+		err = json.Unmarshal(body, &rt.InsightTrackedIds)
+	} else {
+		err = json.Unmarshal(body, &rt)
+	}
+	return rt, err
+}
+
+// fetchSeAT tracks the configured corporations and their contacts with at
+// least minStanding
+func (ck *Checker) fetchSeAT(ctx context.Context) (remoteTracked, error) {
+	tc := ck.config.TrackedSource
+	client := seat.NewClient(tc.URL, tc.Token)
+	client.SetHTTPClient(ck.http)
+	rt := remoteTracked{TrackedCorporationIds: slices.Clone(tc.CorporationIds)}
+	for _, corpID := range tc.CorporationIds {
+		contacts, err := client.Contacts(ctx, corpID)
+		if err != nil {
+			return remoteTracked{}, fmt.Errorf("contacts of corporation %d: %w", corpID, err)
+		}
+		for _, c := range contacts {
+			if c.Standing <= 0 || c.Standing < tc.MinStanding {
+				continue
+			}
+			switch c.ContactType {
+			case "character":
+				rt.TrackedCharacterIds = append(rt.TrackedCharacterIds, c.ContactID)
+			case "corporation":
+				rt.TrackedCorporationIds = append(rt.TrackedCorporationIds, int(c.ContactID))
+			case "alliance":
+				rt.TrackedAllianceIds = append(rt.TrackedAllianceIds, int(c.ContactID))
+			}
+		}
+	}
+	return rt, nil
+}
+
+// applyTrackedSource puts the fetched IDs on top of the configured ones
+func (ck *Checker) applyTrackedSource(rt remoteTracked) {
+	ck.tracked.SetRemote(rt.InsightTrackedIds, filter.Tracked{
+		Corporations: rt.TrackedCorporationIds,
+		Alliances:    rt.TrackedAllianceIds,
+		Characters:   rt.TrackedCharacterIds,
+	})
+	ck.logger.Printf("[Tracking] Fetched %d tracked IDs from trackedSource (%d insight, %d corporations, %d alliances, %d characters)",
+		rt.count(), len(rt.InsightTrackedIds), len(rt.TrackedCorporationIds), len(rt.TrackedAllianceIds), len(rt.TrackedCharacterIds))
+}
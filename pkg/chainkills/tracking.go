@@ -0,0 +1,126 @@
+package chainkills
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/guarzo/eve-chainkills/internal/filter"
+	"golang.org/x/exp/slices"
+)
+
+// ErrCustomFilter is returned when changing tracking lists on a checker that uses WithFilter
+var ErrCustomFilter = errors.New("chainkills: tracking lists are managed by a custom filter")
+
+// builtinMatcher returns the matcher behind the default filter
+func (ck *Checker) builtinMatcher() (*filter.Matcher, error) {
+	mf, ok := ck.matcher.(matcherFilter)
+	if !ok {
+		return nil, ErrCustomFilter
+	}
+	return mf.matcher, nil
+}
+
+// TrackedIds returns the corporation and alliance IDs currently tracked
+func (ck *Checker) TrackedIds() ([]int, error) {
+	m, err := ck.builtinMatcher()
+	if err != nil {
+		return nil, err
+	}
+	return m.TrackedIds(), nil
+}
+
+// SetTrackedIds replaces the tracked IDs; it takes effect from the next kill
+func (ck *Checker) SetTrackedIds(ids []int) error {
+	m, err := ck.builtinMatcher()
+	if err != nil {
+		return err
+	}
+	m.SetTrackedIds(ids)
+	ck.trackingMu.Lock()
+	ck.insightTrackedIds = m.TrackedIds()
+	ck.config.InsightTrackedIds = ck.insightTrackedIds
+	ck.trackingMu.Unlock()
+	ck.logger.Printf("[ChainKillChecker] insightTrackedIds now: %v", ids)
+	return nil
+}
+
+// IgnoredSystemIds returns the system IDs whose chain kills are not reported
+func (ck *Checker) IgnoredSystemIds() ([]int, error) {
+	m, err := ck.builtinMatcher()
+	if err != nil {
+		return nil, err
+	}
+	return m.IgnoreSystemIds(), nil
+}
+
+// SetIgnoredSystemIds replaces the ignored systems; it takes effect from the next kill
+func (ck *Checker) SetIgnoredSystemIds(ids []int) error {
+	m, err := ck.builtinMatcher()
+	if err != nil {
+		return err
+	}
+	m.SetIgnoreSystemIds(ids)
+	ck.trackingMu.Lock()
+	ck.config.IgnoreSystemIds = m.IgnoreSystemIds()
+	ck.trackingMu.Unlock()
+	ck.updateState(func(s *mapState) { s.ignored = systemSet(ids) })
+	ck.logger.Printf("[ChainKillChecker] ignoreSystemIds now: %v", ids)
+	return nil
+}
+
+// trackingList names one of the editable ID lists
+type trackingList struct {
+	get func(ck *Checker) ([]int, error)
+	set func(ck *Checker, ids []int) error
+}
+
+var (
+	trackedList = trackingList{get: (*Checker).TrackedIds, set: (*Checker).SetTrackedIds}
+	ignoredList = trackingList{get: (*Checker).IgnoredSystemIds, set: (*Checker).SetIgnoredSystemIds}
+)
+
+// instance returns the checker with the given name; the name may be empty when there is only one
+func (h *Hub) instance(name string) (*Checker, error) {
+	if name == "" {
+		if len(h.checkers) == 1 {
+			return h.checkers[0], nil
+		}
+		return nil, errors.New("instance is required when several instances are configured")
+	}
+	for _, ck := range h.checkers {
+		if ck.config.Name == name {
+			return ck, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown instance %q", name)
+}
+
+// editTracking applies change to one of ck's live lists, then saves both lists
+// back to the config file. persisted is false when there is no file or saving failed.
+func (h *Hub) editTracking(ck *Checker, list trackingList, change func([]int) []int) (ids []int, persisted bool, err error) {
+	h.trackingMu.Lock()
+	defer h.trackingMu.Unlock()
+	ids, err = list.get(ck)
+	if err != nil {
+		return nil, false, err
+	}
+	ids = change(ids)
+	if err = list.set(ck, ids); err != nil {
+		return nil, false, err
+	}
+
+	if h.configPath == "" {
+		return ids, false, nil
+	}
+	index := -1
+	if h.instanced {
+		index = slices.Index(h.checkers, ck)
+	}
+	tracked, _ := ck.TrackedIds()
+	ignored, _ := ck.IgnoredSystemIds()
+	if err := saveTrackingLists(h.configPath, index, tracked, ignored); err != nil {
+		h.logger.Printf("Error saving tracking change to %s: %v", h.configPath, err)
+		return ids, false, nil
+	}
+	return ids, true, nil
+}
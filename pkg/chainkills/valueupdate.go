@@ -0,0 +1,50 @@
+package chainkills
+
+import (
+	"context"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/internal/zkill"
+	"github.com/guarzo/eve-chainkills/pkg/notify"
+)
+
+// valueUpdateDelay is how long after sending a corp kill its value is checked again, or 0
+func (ck *Checker) valueUpdateDelay() time.Duration {
+	return time.Duration(ck.config.ValueUpdates.DelayMinutes) * time.Minute
+}
+
+// scheduleValueUpdate re-reads the kill's zKillboard value after the configured
+// delay and edits the sent message if it changed
+func (ck *Checker) scheduleValueUpdate(ctx context.Context, s notify.EditableSink, ref string, n notify.Notification) {
+	// the kill's context ends with its message; keep its values for tracing
+	ctx = context.WithoutCancel(ctx)
+	time.AfterFunc(ck.valueUpdateDelay(), func() {
+		defer ck.supervisor.Recover("value update", nil)
+		ctx, cancel := context.WithTimeout(ctx, time.Minute)
+		defer cancel()
+		ck.updateValue(ctx, s, ref, n)
+	})
+}
+
+func (ck *Checker) updateValue(ctx context.Context, s notify.EditableSink, ref string, n notify.Notification) {
+	zm, err := zkill.Kill(ctx, ck.http, zkill.HistoryURL, n.KillMailID)
+	if err != nil {
+		ck.killLogger(ctx).Printf("Error re-reading the value of kill %d: %v", n.KillMailID, err)
+		return
+	}
+	if n.Kill == nil || zm.ZKB.TotalValue == n.Kill.TotalValue {
+		return
+	}
+	kill := *n.Kill
+	ck.killLogger(ctx).Printf("Kill %d value settled from %.0f to %.0f ISK; updating %s", n.KillMailID, kill.TotalValue, zm.ZKB.TotalValue, ref)
+	kill.FittedValue = zm.ZKB.FittedValue
+	kill.DroppedValue = zm.ZKB.DroppedValue
+	kill.DestroyedValue = zm.ZKB.DestroyedValue
+	kill.TotalValue = zm.ZKB.TotalValue
+	kill.Points = zm.ZKB.Points
+	n.Kill = &kill
+	n.Updated = true
+	if err := s.Edit(ctx, ref, n); err != nil {
+		ck.killLogger(ctx).Printf("Error updating the message for kill %d: %v", n.KillMailID, err)
+	}
+}
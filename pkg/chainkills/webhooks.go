@@ -0,0 +1,63 @@
+package chainkills
+
+import "fmt"
+
+// DiscordWebhookConfig is one entry of the discordWebhooks registry
+type DiscordWebhookConfig struct {
+	Id    string `json:"id"`
+	Token string `json:"token"`
+}
+
+// webhookRef is a setting that may name a registered webhook instead of
+// giving its ID and token
+type webhookRef struct {
+	path      string
+	name      string
+	id, token *string
+}
+
+// webhookRefs lists every setting that takes a Discord webhook
+func (c *Config) webhookRefs() []webhookRef {
+	refs := []webhookRef{
+		{"discordChainkillWebhook", c.DiscordChainkillWebhook, &c.DiscordChainkillWebhookId, &c.DiscordChainkillWebhookToken},
+		{"discordInfoWebhook", c.DiscordInfoWebhook, &c.DiscordInfoWebhookId, &c.DiscordInfoWebhookToken},
+		{"discordCorpkillWebhook", c.DiscordCorpkillWebhook, &c.DiscordCorpkillWebhookId, &c.DiscordCorpkillWebhookToken},
+		{"threat.discordWebhook", c.Threat.DiscordWebhook, &c.Threat.DiscordWebhookId, &c.Threat.DiscordWebhookToken},
+		{"structureSiege.discordWebhook", c.StructureSiege.DiscordWebhook, &c.StructureSiege.DiscordWebhookId, &c.StructureSiege.DiscordWebhookToken},
+		{"bothSides.discordWebhook", c.BothSides.DiscordWebhook, &c.BothSides.DiscordWebhookId, &c.BothSides.DiscordWebhookToken},
+		{"abyssal.discordWebhook", c.Abyssal.DiscordWebhook, &c.Abyssal.DiscordWebhookId, &c.Abyssal.DiscordWebhookToken},
+		{"factionWarfare.discordWebhook", c.FactionWarfare.DiscordWebhook, &c.FactionWarfare.DiscordWebhookId, &c.FactionWarfare.DiscordWebhookToken},
+	}
+	for name, ic := range map[string]*InfoChannelConfig{"lifecycle": &c.InfoChannels.Lifecycle, "warnings": &c.InfoChannels.Warnings, "errors": &c.InfoChannels.Errors} {
+		refs = append(refs, webhookRef{"infoChannels." + name + ".discordWebhook", ic.DiscordWebhook, &ic.DiscordWebhookId, &ic.DiscordWebhookToken})
+	}
+	for i := range c.Escalations {
+		e := &c.Escalations[i]
+		refs = append(refs, webhookRef{fmt.Sprintf("escalations[%d].discordWebhook", i), e.DiscordWebhook, &e.DiscordWebhookId, &e.DiscordWebhookToken})
+	}
+	return refs
+}
+
+// resolveWebhooks fills in the ID and token of every setting that names a
+// discordWebhooks entry
+func (c *Config) resolveWebhooks() error {
+	for name, wh := range c.DiscordWebhooks {
+		if wh.Id == "" || wh.Token == "" {
+			return fmt.Errorf("discordWebhooks.%s needs an id and token", name)
+		}
+	}
+	for _, ref := range c.webhookRefs() {
+		if ref.name == "" {
+			continue
+		}
+		wh, ok := c.DiscordWebhooks[ref.name]
+		if !ok {
+			return fmt.Errorf("%s: no discordWebhooks entry named %q", ref.path, ref.name)
+		}
+		if *ref.id != "" && *ref.id != wh.Id {
+			return fmt.Errorf("%s names a webhook but its ID is set too; use one or the other", ref.path)
+		}
+		*ref.id, *ref.token = wh.Id, wh.Token
+	}
+	return nil
+}
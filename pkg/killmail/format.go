@@ -0,0 +1,91 @@
+package killmail
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// ISK display formats, selectable per sink
+const (
+	ISKFormatShort = "short" // "1.24b ISK" (default)
+	ISKFormatFull  = "full"  // "1,240,000,000 ISK"
+	ISKFormatBoth  = "both"  // "1.24b ISK (1,240,000,000 ISK)"
+)
+
+// FormatISK renders an ISK amount in one of the ISKFormat* formats; unknown formats use short
+func FormatISK(amount float64, format string) string {
+	full := formatThousands(int64(math.Round(amount))) + " ISK"
+	switch format {
+	case ISKFormatFull:
+		return full
+	case ISKFormatBoth:
+		return fmt.Sprintf("%s (%s)", FormatISKValue(amount), full)
+	default:
+		return FormatISKValue(amount)
+	}
+}
+
+// FormatISKValue renders an ISK amount with an m, b or t suffix.
+func FormatISKValue(amount float64) string {
+	switch {
+	case amount < 1_000_000:
+		return "<1 M ISK"
+	case amount < 1_000_000_000:
+		millions := amount / 1_000_000
+		millions = math.Round(millions*100) / 100
+		return fmt.Sprintf("%.2fm ISK", millions)
+	case amount < 1_000_000_000_000:
+		billions := amount / 1_000_000_000
+		billions = math.Round(billions*100) / 100
+		return fmt.Sprintf("%.2fb ISK", billions)
+	default:
+		trillions := amount / 1_000_000_000_000
+		trillions = math.Round(trillions*100) / 100
+		return fmt.Sprintf("%.2ft ISK", trillions)
+	}
+}
+
+// auMeters is one astronomical unit in meters
+const auMeters = 149_597_870_700
+
+// FormatDistance renders meters the way the game's overview does: m, km or AU
+func FormatDistance(meters float64) string {
+	switch {
+	case meters < 10_000:
+		return fmt.Sprintf("%.0f m", meters)
+	case meters < 0.1*auMeters:
+		return fmt.Sprintf("%s km", formatThousands(int64(math.Round(meters/1000))))
+	default:
+		return fmt.Sprintf("%.1f AU", meters/auMeters)
+	}
+}
+
+// formatThousands adds comma separators, e.g. 12345 -> "12,345"
+func formatThousands(n int64) string {
+	s := fmt.Sprint(n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
+
+// FormatEVETime renders a time in EVE time, which is UTC, e.g. "2024-05-01 18:04 EVE"
+func FormatEVETime(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04") + " EVE"
+}
+
+// FormatKillTime renders a kill time in EVE time, followed by the time in loc
+// when loc is set and not UTC, e.g. "2024-05-01 18:04 EVE (14:04 EDT)"
+func FormatKillTime(t time.Time, loc *time.Location) string {
+	text := FormatEVETime(t)
+	if loc == nil || loc == time.UTC {
+		return text
+	}
+	local, eve := t.In(loc), t.UTC()
+	layout := "15:04 MST"
+	if local.YearDay() != eve.YearDay() {
+		layout = "Jan 2 15:04 MST"
+	}
+	return fmt.Sprintf("%s (%s)", text, local.Format(layout))
+}
@@ -0,0 +1,66 @@
+package killmail
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// DefaultImageBaseURL is CCP's image server
+const DefaultImageBaseURL = "https://images.evetech.net"
+
+// imageSizes are the sizes the image server accepts
+var imageSizes = []int{32, 64, 128, 256, 512, 1024}
+
+// Images builds image server URLs for entities and ships. The zero value uses
+// images.evetech.net at 64px with ship icons.
+type Images struct {
+	// BaseURL of an images.evetech.net compatible server, e.g. a caching proxy
+	BaseURL string `json:"baseUrl"`
+	// IconSize is used for corp/alliance logos and portraits: 32, 64, 128, 256, 512 or 1024 (default 64)
+	IconSize int `json:"iconSize"`
+	// ThumbnailSize is used for ship images (default 64)
+	ThumbnailSize int `json:"thumbnailSize"`
+	// ShipRenders uses the 3D ship render instead of the flat icon for ship images
+	ShipRenders bool `json:"shipRenders"`
+}
+
+func (im Images) url(category string, id int64, variation string, size int) string {
+	base := strings.TrimRight(im.BaseURL, "/")
+	if base == "" {
+		base = DefaultImageBaseURL
+	}
+	if !slices.Contains(imageSizes, size) {
+		size = 64
+	}
+	return fmt.Sprintf("%s/%s/%d/%s?size=%d", base, category, id, variation, size)
+}
+
+// AllianceLogo returns the URL of an alliance's logo
+func (im Images) AllianceLogo(allianceID int) string {
+	return im.url("alliances", int64(allianceID), "logo", im.IconSize)
+}
+
+// CorporationLogo returns the URL of a corporation's logo
+func (im Images) CorporationLogo(corpID int) string {
+	return im.url("corporations", int64(corpID), "logo", im.IconSize)
+}
+
+// CharacterPortrait returns the URL of a character's portrait
+func (im Images) CharacterPortrait(characterID int64) string {
+	return im.url("characters", characterID, "portrait", im.IconSize)
+}
+
+// Ship returns the URL of a ship type's icon, or its render when ShipRenders is set
+func (im Images) Ship(typeID int) string {
+	size := im.ThumbnailSize
+	if im.ShipRenders {
+		// renders stop at 512px
+		if size > 512 {
+			size = 512
+		}
+		return im.url("types", int64(typeID), "render", size)
+	}
+	return im.url("types", int64(typeID), "icon", size)
+}
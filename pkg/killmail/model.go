@@ -0,0 +1,399 @@
+// Package killmail holds the zKillboard, ESI and merged killmail models
+// shared by every part of the pipeline.
+package killmail
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// -------------------------------------------------------------------
+// EVE / zKill Models
+// -------------------------------------------------------------------
+
+// ZkillMail represents the JSON structure from the zKillboard feed.
+type ZkillMail struct {
+	KillmailID    int64      `json:"killmail_id"`
+	KillmailTime  time.Time  `json:"killmail_time"`
+	SolarSystemID int        `json:"solar_system_id"`
+	Victim        Victim     `json:"victim"`
+	Attackers     []Attacker `json:"attackers"`
+	ZKB           ZKB        `json:"zkb"`
+}
+
+// ZKB holds the hash and economic info from zKill
+type ZKB struct {
+	LocationID     int64   `json:"locationID"`
+	Hash           string  `json:"hash"`
+	FittedValue    float64 `json:"fittedValue"`
+	DroppedValue   float64 `json:"droppedValue"`
+	DestroyedValue float64 `json:"destroyedValue"`
+	TotalValue     float64 `json:"totalValue"`
+	Points         int     `json:"points"`
+	NPC            bool    `json:"npc"`
+	Solo           bool    `json:"solo"`
+	Awox           bool    `json:"awox"`
+}
+
+// Victim from either zKill or ESI
+type Victim struct {
+	AllianceID    int   `json:"alliance_id"`
+	CorporationID int   `json:"corporation_id"`
+	CharacterID   int64 `json:"character_id"`
+	DamageTaken   int   `json:"damage_taken"`
+	// FactionID is set for pilots enlisted in a Faction Warfare militia
+	FactionID int `json:"faction_id"`
+
+	// ESI-specific
+	ShipTypeID int       `json:"ship_type_id"`
+	Position   *Position `json:"position,omitempty"`
+}
+
+// Position is a point in a solar system, in meters
+type Position struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	Z float64 `json:"z"`
+}
+
+// Distance returns the distance between two positions in meters
+func (p Position) Distance(o Position) float64 {
+	return math.Sqrt((p.X-o.X)*(p.X-o.X) + (p.Y-o.Y)*(p.Y-o.Y) + (p.Z-o.Z)*(p.Z-o.Z))
+}
+
+// Attacker from either zKill or ESI
+type Attacker struct {
+	AllianceID     int     `json:"alliance_id"`
+	CharacterID    int64   `json:"character_id"`
+	CorporationID  int     `json:"corporation_id"`
+	DamageDone     int     `json:"damage_done"`
+	FactionID      int     `json:"faction_id"` // militia pilots and faction NPCs
+	FinalBlow      bool    `json:"final_blow"`
+	SecurityStatus float64 `json:"security_status"`
+	ShipTypeID     int     `json:"ship_type_id"`
+	WeaponTypeID   int     `json:"weapon_type_id"`
+}
+
+// -------------------------------------------------------------------
+// ESI Models
+// -------------------------------------------------------------------
+
+// EsiKillMail is what ESI returns for a killmail lookup.
+type EsiKillMail struct {
+	KillMailID    int64      `json:"killmail_id"`
+	KillMailTime  time.Time  `json:"killmail_time"`
+	SolarSystemID int        `json:"solar_system_id"`
+	Victim        Victim     `json:"victim"`
+	Attackers     []Attacker `json:"attackers"`
+}
+
+// EsiFWSystem is a Faction Warfare system's status from ESI's fw/systems
+type EsiFWSystem struct {
+	SolarSystemID     int `json:"solar_system_id"`
+	OwnerFactionID    int `json:"owner_faction_id"`
+	OccupierFactionID int `json:"occupier_faction_id"`
+	// Contested is "uncontested", "contested", "vulnerable" or "captured"
+	Contested              string `json:"contested"`
+	VictoryPoints          int    `json:"victory_points"`
+	VictoryPointsThreshold int    `json:"victory_points_threshold"`
+}
+
+// EsiCharacterResponse is the part of ESI's character lookup we use.
+type EsiCharacterResponse struct {
+	Name          string `json:"name"`
+	CorporationID int    `json:"corporation_id"`
+}
+
+// -------------------------------------------------------------------
+// FlattenedKillMail merges zKill + ESI data
+// -------------------------------------------------------------------
+
+// FlattenedKillMail merges zKill + ESI data into one record.
+type FlattenedKillMail struct {
+	// Basic IDs
+	KillMailID    int64     `json:"killmail_id"`
+	Hash          string    `json:"hash"`          // from zKill's "zkb.hash"
+	KillMailTime  time.Time `json:"killmail_time"` // from ESI
+	SolarSystemID int       `json:"solar_system_id"`
+
+	LocationID     int64   `json:"locationID"`
+	FittedValue    float64 `json:"fittedValue"`
+	DroppedValue   float64 `json:"droppedValue"`
+	DestroyedValue float64 `json:"destroyedValue"`
+	TotalValue     float64 `json:"totalValue"`
+	Points         int     `json:"points"`
+	NPC            bool    `json:"npc"`
+	Solo           bool    `json:"solo"`
+	Awox           bool    `json:"awox"`
+
+	SystemName string `json:"system_name"`
+
+	// NearestCelestial is the name of zKill's locationID, e.g. "J123456 V - Moon 3", and
+	// CelestialDistance the victim's distance from it in meters; both need ESI
+	NearestCelestial  string  `json:"nearest_celestial,omitempty"`
+	CelestialDistance float64 `json:"celestial_distance,omitempty"`
+
+	// Entities
+	Victim Victim `json:"victim"`
+	// Attackers is nil for big kills once TrimAttackers has run; their
+	// AttackerStats stay
+	Attackers     []Attacker     `json:"attackers"`
+	AttackerStats *AttackerStats `json:"attacker_stats,omitempty"`
+
+	VictimCharacterName string `json:"victim_character_name"`
+
+	FinalAttackerID           int64  `json:"final_attacker_id"`
+	FinalAttackerName         string `json:"final_attacker_name"`
+	FinalAttackerCorpID       int    `json:"final_attacker_corp_id"`
+	FinalAttackerAllianceID   int    `json:"final_attacker_alliance_id"`
+	FinalAttackerShipName     string `json:"final_attacker_ship_name"`
+	FinalAttackerCorpName     string `json:"final_attacker_corp_name"`
+	FinalAttackerAllianceName string `json:"final_attacker_alliance_name"`
+
+	VictimShipName     string `json:"victim_ship_name"`
+	VictimShipGroupID  int    `json:"victim_ship_group_id"`
+	VictimCorpName     string `json:"victim_corp_name"`
+	VictimAllianceName string `json:"victim_alliance_name"`
+
+	// AttackerShips counts the attackers' hulls, most common first, and
+	// AttackerGroup is the corp or alliance with the most attackers
+	AttackerShips []ShipCount     `json:"attacker_ships,omitempty"`
+	AttackerGroup *AttackingGroup `json:"attacker_group,omitempty"`
+
+	// ThreatSummary condenses zKillboard's stats for the final blow's corporation
+	ThreatSummary string `json:"threat_summary,omitempty"`
+
+	// FactionWarfare sums up the warzone status of the system and the militias on each side
+	FactionWarfare string `json:"faction_warfare,omitempty"`
+}
+
+// ShipCount is how many attackers flew one ship type
+type ShipCount struct {
+	TypeID int    `json:"type_id"`
+	Name   string `json:"name"`
+	Count  int    `json:"count"`
+}
+
+// AttackingGroup is the corporation or alliance most attackers belonged to
+type AttackingGroup struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	Ticker     string `json:"ticker"`
+	IsAlliance bool   `json:"is_alliance"`
+	Count      int    `json:"count"`
+}
+
+// AttackerStats condenses an attacker list
+type AttackerStats struct {
+	Count   int `json:"count"`
+	Players int `json:"players"`
+	NPCs    int `json:"npcs"`
+	// Corporations is how many distinct corporations took part
+	Corporations int       `json:"corporations"`
+	FinalBlow    *Attacker `json:"final_blow,omitempty"`
+	TopDamage    *Attacker `json:"top_damage,omitempty"`
+}
+
+// SummarizeAttackers computes AttackerStats in one pass
+func SummarizeAttackers(attackers []Attacker) AttackerStats {
+	st := AttackerStats{Count: len(attackers)}
+	corps := map[int]struct{}{}
+	for i := range attackers {
+		att := &attackers[i]
+		if att.IsPlayer() {
+			st.Players++
+		} else {
+			st.NPCs++
+		}
+		if att.CorporationID != 0 {
+			corps[att.CorporationID] = struct{}{}
+		}
+		if att.FinalBlow && st.FinalBlow == nil {
+			fb := *att
+			st.FinalBlow = &fb
+		}
+		if st.TopDamage == nil || att.DamageDone > st.TopDamage.DamageDone {
+			top := *att
+			st.TopDamage = &top
+		}
+	}
+	st.Corporations = len(corps)
+	return st
+}
+
+// TrimAttackers fills in AttackerStats and, past max attackers, drops the
+// attacker list so big structure kills don't hold thousands of entries
+func (fkm *FlattenedKillMail) TrimAttackers(max int) {
+	st := SummarizeAttackers(fkm.Attackers)
+	fkm.AttackerStats = &st
+	if len(fkm.Attackers) > max {
+		fkm.Attackers = nil
+	}
+}
+
+// FillMissing completes a kill ESI only partly resolved, e.g. when enrichment
+// ran out of time: without the ESI killmail the victim and attackers come from
+// zKillboard's copy, and every unresolved name shows its ID instead
+func (fkm *FlattenedKillMail) FillMissing(zm ZkillMail) {
+	if fkm.KillMailTime.IsZero() {
+		systemName := fkm.SystemName
+		*fkm = FlattenZkill(zm)
+		fkm.SystemName = systemName
+	}
+	var final *Attacker
+	for i := range fkm.Attackers {
+		if fkm.Attackers[i].FinalBlow {
+			final = &fkm.Attackers[i]
+			break
+		}
+	}
+	if final == nil && len(fkm.Attackers) > 0 {
+		final = &fkm.Attackers[0]
+	}
+	if final != nil && fkm.FinalAttackerID == 0 && fkm.FinalAttackerCorpID == 0 {
+		fkm.FinalAttackerID = final.CharacterID
+		fkm.FinalAttackerCorpID = final.CorporationID
+		fkm.FinalAttackerAllianceID = final.AllianceID
+	}
+
+	fill := func(name *string, kind string, id int64) {
+		if *name == "" && id > 0 {
+			*name = fmt.Sprintf("%s %d", kind, id)
+		}
+	}
+	if fkm.SystemName == "" && IsAbyssal(fkm.SolarSystemID) {
+		fkm.SystemName = AbyssalSystemName
+	}
+	fill(&fkm.SystemName, "system", int64(fkm.SolarSystemID))
+	fill(&fkm.VictimCharacterName, "character", fkm.Victim.CharacterID)
+	fill(&fkm.VictimCorpName, "corporation", int64(fkm.Victim.CorporationID))
+	fill(&fkm.VictimAllianceName, "alliance", int64(fkm.Victim.AllianceID))
+	fill(&fkm.VictimShipName, "type", int64(fkm.Victim.ShipTypeID))
+	fill(&fkm.FinalAttackerName, "character", fkm.FinalAttackerID)
+	fill(&fkm.FinalAttackerCorpName, "corporation", int64(fkm.FinalAttackerCorpID))
+	fill(&fkm.FinalAttackerAllianceName, "alliance", int64(fkm.FinalAttackerAllianceID))
+	if final != nil {
+		fill(&fkm.FinalAttackerShipName, "type", int64(final.ShipTypeID))
+	}
+}
+
+// AttackerCount is the number of attackers, trimmed or not
+func (fkm FlattenedKillMail) AttackerCount() int {
+	if fkm.AttackerStats != nil {
+		return fkm.AttackerStats.Count
+	}
+	return len(fkm.Attackers)
+}
+
+// PlayersAndNPCs is CountAttackers, trimmed or not
+func (fkm FlattenedKillMail) PlayersAndNPCs() (players, npcs int) {
+	if st := fkm.AttackerStats; st != nil {
+		return st.Players, st.NPCs
+	}
+	return CountAttackers(fkm.Attackers)
+}
+
+// AbyssalSystemName stands in for the name of an Abyssal Deadspace pocket,
+// which ESI doesn't know
+const AbyssalSystemName = "Abyssal Deadspace"
+
+// IsAbyssal reports whether a solar system is an Abyssal Deadspace pocket,
+// proving grounds included. They are numbered from 32000000, have no
+// gates, and only exist while someone is in them.
+func IsAbyssal(systemID int) bool {
+	return systemID >= 32000000 && systemID < 33000000
+}
+
+// IsPlayer reports whether the attacker is a capsuleer; NPCs have no character ID
+func (a Attacker) IsPlayer() bool {
+	return a.CharacterID > 0
+}
+
+// CountAttackers splits attackers into players and NPCs
+func CountAttackers(attackers []Attacker) (players, npcs int) {
+	for _, att := range attackers {
+		if att.IsPlayer() {
+			players++
+		} else {
+			npcs++
+		}
+	}
+	return players, npcs
+}
+
+// AttackerSummary renders the attacker composition, e.g.
+// "3x Loki, 2x Sabre, 1x Devoter; mostly [TICKR] Some Alliance (5/6)", or "" if unknown
+func (fkm FlattenedKillMail) AttackerSummary() string {
+	const maxShips = 5
+	var parts []string
+	for i, sc := range fkm.AttackerShips {
+		if i == maxShips {
+			parts = append(parts, fmt.Sprintf("+%d more", len(fkm.AttackerShips)-maxShips))
+			break
+		}
+		parts = append(parts, fmt.Sprintf("%dx %s", sc.Count, sc.Name))
+	}
+	summary := strings.Join(parts, ", ")
+	if g := fkm.AttackerGroup; g != nil && g.Name != "" {
+		group := g.Name
+		if g.Ticker != "" {
+			group = fmt.Sprintf("[%s] %s", g.Ticker, g.Name)
+		}
+		if summary != "" {
+			summary += "; "
+		}
+		summary += fmt.Sprintf("mostly %s (%d/%d)", group, g.Count, fkm.AttackerCount())
+	}
+	return summary
+}
+
+// FlattenZkill copies the fields zKill already provides, before any ESI lookups.
+func FlattenZkill(zm ZkillMail) FlattenedKillMail {
+	return FlattenedKillMail{
+		KillMailID:     zm.KillmailID,
+		Hash:           zm.ZKB.Hash,
+		KillMailTime:   zm.KillmailTime,
+		SolarSystemID:  zm.SolarSystemID,
+		LocationID:     zm.ZKB.LocationID,
+		FittedValue:    zm.ZKB.FittedValue,
+		DroppedValue:   zm.ZKB.DroppedValue,
+		DestroyedValue: zm.ZKB.DestroyedValue,
+		TotalValue:     zm.ZKB.TotalValue,
+		Points:         zm.ZKB.Points,
+		NPC:            zm.ZKB.NPC,
+		Solo:           zm.ZKB.Solo,
+		Awox:           zm.ZKB.Awox,
+		Victim:         zm.Victim,
+		Attackers:      zm.Attackers,
+	}
+}
+
+// -------------------------------------------------------------------
+// Systems and Characters Models
+// -------------------------------------------------------------------
+
+// SystemInfo is a mapped chain system and its alias on the map.
+type SystemInfo struct {
+	SystemId int
+	Alias    string
+}
+
+// Connection links two mapped systems
+type Connection struct {
+	Source int
+	Target int
+}
+
+// MapCharacter is a character registered on the map.
+type MapCharacter struct {
+	// CharacterId is 0 when the map didn't report a valid ID
+	CharacterId   int64
+	CorporationId int
+	AllianceId    int
+	Name          string
+	// SolarSystemId is where the map last saw the character, 0 if unknown
+	SolarSystemId int
+	Online        bool
+}
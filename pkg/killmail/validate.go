@@ -0,0 +1,54 @@
+package killmail
+
+import (
+	"errors"
+	"fmt"
+)
+
+// MaxAttackers is more than any real killmail has had; the biggest
+// structure kills stay in the low thousands
+const MaxAttackers = 10000
+
+// Validate rejects killstream messages no real kill could produce, e.g.
+// negative IDs or absurd attacker counts
+func (zm ZkillMail) Validate() error {
+	if zm.KillmailID < 0 {
+		return fmt.Errorf("negative killmail_id %d", zm.KillmailID)
+	}
+	if zm.ZKB.LocationID < 0 {
+		return fmt.Errorf("negative locationID %d", zm.ZKB.LocationID)
+	}
+	// the hash goes into ESI's URL path
+	for _, r := range zm.ZKB.Hash {
+		if !('0' <= r && r <= '9' || 'a' <= r && r <= 'f' || 'A' <= r && r <= 'F') {
+			return errors.New("hash is not hexadecimal")
+		}
+	}
+	return validateParts(zm.SolarSystemID, zm.Victim, zm.Attackers)
+}
+
+// Validate rejects ESI killmails no real kill could produce
+func (km EsiKillMail) Validate() error {
+	if km.KillMailID <= 0 {
+		return fmt.Errorf("invalid killmail_id %d", km.KillMailID)
+	}
+	return validateParts(km.SolarSystemID, km.Victim, km.Attackers)
+}
+
+func validateParts(systemID int, victim Victim, attackers []Attacker) error {
+	if systemID < 0 {
+		return fmt.Errorf("negative solar_system_id %d", systemID)
+	}
+	if len(attackers) > MaxAttackers {
+		return fmt.Errorf("%d attackers, more than %d", len(attackers), MaxAttackers)
+	}
+	if victim.AllianceID < 0 || victim.CorporationID < 0 || victim.CharacterID < 0 || victim.ShipTypeID < 0 {
+		return errors.New("negative victim ID")
+	}
+	for _, a := range attackers {
+		if a.AllianceID < 0 || a.CorporationID < 0 || a.CharacterID < 0 || a.ShipTypeID < 0 || a.WeaponTypeID < 0 {
+			return errors.New("negative attacker ID")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+// Package logger is the small logging interface the library packages log
+// through, so an embedding program can plug in logrus, slog, zap or its own
+// logger without chainkills depending on any of them.
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// Logger is what chainkills logs to. Printf and Println log at info level.
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Printf(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+	Println(args ...interface{})
+	// WithField returns a logger that adds key=value to every entry
+	WithField(key string, value interface{}) Logger
+}
+
+// Slog adapts a log/slog logger; zap users can wrap theirs with zapslog first
+func Slog(l *slog.Logger) Logger {
+	return slogLogger{l}
+}
+
+// Default logs through slog.Default()
+func Default() Logger {
+	return Slog(slog.Default())
+}
+
+type slogLogger struct {
+	l *slog.Logger
+}
+
+func (s slogLogger) log(level slog.Level, msg string) {
+	s.l.Log(context.Background(), level, msg)
+}
+
+func (s slogLogger) Debugf(format string, args ...interface{}) {
+	s.log(slog.LevelDebug, fmt.Sprintf(format, args...))
+}
+
+func (s slogLogger) Infof(format string, args ...interface{}) {
+	s.log(slog.LevelInfo, fmt.Sprintf(format, args...))
+}
+
+func (s slogLogger) Printf(format string, args ...interface{}) {
+	s.log(slog.LevelInfo, fmt.Sprintf(format, args...))
+}
+
+func (s slogLogger) Warnf(format string, args ...interface{}) {
+	s.log(slog.LevelWarn, fmt.Sprintf(format, args...))
+}
+
+func (s slogLogger) Errorf(format string, args ...interface{}) {
+	s.log(slog.LevelError, fmt.Sprintf(format, args...))
+}
+
+func (s slogLogger) Println(args ...interface{}) {
+	msg := fmt.Sprintln(args...)
+	s.log(slog.LevelInfo, msg[:len(msg)-1])
+}
+
+func (s slogLogger) WithField(key string, value interface{}) Logger {
+	return slogLogger{s.l.With(key, value)}
+}
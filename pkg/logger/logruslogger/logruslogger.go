@@ -0,0 +1,21 @@
+// Package logruslogger adapts logrus to logger.Logger. It is the only
+// library package that imports logrus.
+package logruslogger
+
+import (
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+	"github.com/sirupsen/logrus"
+)
+
+// New wraps a logrus logger or entry
+func New(l logrus.FieldLogger) logger.Logger {
+	return adapter{l}
+}
+
+type adapter struct {
+	logrus.FieldLogger
+}
+
+func (a adapter) WithField(key string, value interface{}) logger.Logger {
+	return adapter{a.FieldLogger.WithField(key, value)}
+}
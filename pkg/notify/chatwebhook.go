@@ -0,0 +1,207 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/internal/discord"
+	"github.com/guarzo/eve-chainkills/internal/httpclient"
+	"github.com/guarzo/eve-chainkills/pkg/killmail"
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+)
+
+// Incoming-webhook flavors that accept Slack-style attachments
+const (
+	ChatFlavorMattermost = "mattermost"
+	ChatFlavorRocketChat = "rocketchat"
+)
+
+// ChatWebhookConfig configures a Mattermost or Rocket.Chat incoming webhook
+type ChatWebhookConfig struct {
+	URL      string `json:"url"`
+	Username string `json:"username"` // Rocket.Chat calls this the alias
+	IconURL  string `json:"iconUrl"`  // Rocket.Chat calls this the avatar
+	Channel  string `json:"channel"`  // optional channel override
+
+	// "#RRGGBB" attachment colors; default to discordKillNotifications
+	KillColor string `json:"killColor"`
+	LossColor string `json:"lossColor"`
+	// ISKFormat is "short", "full" or "both"; defaults to discordKillNotifications.iskFormat
+	ISKFormat string `json:"iskFormat"`
+	// Format is rich (default), compact, plain or json
+	Format Format `json:"format"`
+
+	// Images defaults to the top-level images setting
+	Images killmail.Images `json:"-"`
+	// Timezone is the top-level displayTimezone; kill times are always shown in EVE time too
+	Timezone *time.Location `json:"-"`
+}
+
+// chatAttachment is the subset of the Slack attachment format both servers understand
+type chatAttachment struct {
+	Fallback   string      `json:"fallback,omitempty"`
+	Color      string      `json:"color,omitempty"`
+	AuthorName string      `json:"author_name,omitempty"`
+	AuthorLink string      `json:"author_link,omitempty"`
+	AuthorIcon string      `json:"author_icon,omitempty"`
+	Title      string      `json:"title,omitempty"`
+	TitleLink  string      `json:"title_link,omitempty"`
+	Text       string      `json:"text,omitempty"`
+	ThumbURL   string      `json:"thumb_url,omitempty"`
+	Fields     []chatField `json:"fields,omitempty"`
+	Footer     string      `json:"footer,omitempty"`
+	// Ts is Unix seconds for Mattermost, as in Slack, and an ISO 8601 string for Rocket.Chat
+	Ts interface{} `json:"ts,omitempty"`
+}
+
+type chatField struct {
+	Short bool   `json:"short"`
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+// chatWebhookBody covers both payloads; the flavor decides which identity keys are set
+type chatWebhookBody struct {
+	Text        string           `json:"text,omitempty"`
+	Channel     string           `json:"channel,omitempty"`
+	Username    string           `json:"username,omitempty"` // Mattermost
+	IconURL     string           `json:"icon_url,omitempty"` // Mattermost
+	Alias       string           `json:"alias,omitempty"`    // Rocket.Chat
+	Avatar      string           `json:"avatar,omitempty"`   // Rocket.Chat
+	Attachments []chatAttachment `json:"attachments,omitempty"`
+}
+
+// ChatWebhookSink posts alerts to a Mattermost or Rocket.Chat incoming webhook
+type ChatWebhookSink struct {
+	logger logger.Logger
+	flavor string
+	config ChatWebhookConfig
+	// http is nil for the default client
+	http *http.Client
+}
+
+// NewChatWebhookSink constructor
+func NewChatWebhookSink(logger logger.Logger, flavor string, config ChatWebhookConfig) *ChatWebhookSink {
+	return &ChatWebhookSink{
+		logger: logger,
+		flavor: flavor,
+		config: config,
+	}
+}
+
+// SetHTTPClient sends through client instead of the default one
+func (cs *ChatWebhookSink) SetHTTPClient(client *http.Client) {
+	cs.http = client
+}
+
+func (cs *ChatWebhookSink) Name() string {
+	return cs.flavor
+}
+
+// Send builds the flavor's payload and POSTs it
+func (cs *ChatWebhookSink) Send(ctx context.Context, n Notification) error {
+	body := chatWebhookBody{Channel: cs.config.Channel}
+	if cs.flavor == ChatFlavorRocketChat {
+		body.Alias = cs.config.Username
+		body.Avatar = cs.config.IconURL
+	} else {
+		body.Username = cs.config.Username
+		body.IconURL = cs.config.IconURL
+	}
+
+	if text := formatText(cs.config.Format, n, cs.config.ISKFormat, cs.config.Timezone); text != "" {
+		body.Text = text
+	} else if n.Kind == KindCorpKill && n.Kill != nil {
+		style := discord.EmbedStyle{
+			Colors:    discord.Colors{Kill: cs.config.KillColor, Loss: cs.config.LossColor},
+			Images:    cs.config.Images,
+			ISKFormat: cs.config.ISKFormat,
+			PlainTime: true,
+			Timezone:  cs.config.Timezone,
+		}
+		embed := discord.NewKillEmbed(cs.logger, style, *n.Kill, n.IsKill).CreateEmbed()
+		addReasonFooter(&embed, n.Reason)
+		body.Attachments = []chatAttachment{cs.embedToAttachment(embed)}
+		body.Text = n.SidesText()
+		if danger := n.DangerText(); danger != "" {
+			body.Text = strings.TrimSpace(n.mention() + danger + " " + body.Text)
+		}
+	} else {
+		body.Text = n.plainKillTime(n.mention()+n.ChainText(), cs.config.Timezone)
+		if n.Reason.Text != "" {
+			body.Text += "\nMatched: " + n.Reason.Text
+		}
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cs.config.URL, bytes.NewBuffer(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	setCorrelation(ctx, req, n.KillMailID)
+
+	client := httpclient.Or(cs.http)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s webhook got status %d", cs.flavor, resp.StatusCode)
+	}
+	return nil
+}
+
+// embedToAttachment approximates a Discord embed with a Slack-style attachment
+func (cs *ChatWebhookSink) embedToAttachment(embed discord.Embed) chatAttachment {
+	text := embed.Description
+	if cs.flavor == ChatFlavorRocketChat {
+		// Rocket.Chat renders bold with single asterisks
+		text = strings.ReplaceAll(text, "**", "*")
+	}
+
+	att := chatAttachment{
+		Fallback:  embed.Title,
+		Color:     fmt.Sprintf("#%06X", embed.Color),
+		Title:     embed.Title,
+		TitleLink: embed.URL,
+		Text:      text,
+	}
+	if t, err := time.Parse(time.RFC3339, embed.Timestamp); err == nil {
+		if cs.flavor == ChatFlavorRocketChat {
+			att.Ts = embed.Timestamp
+		} else {
+			att.Ts = t.Unix()
+		}
+	}
+	if embed.Author != nil {
+		att.AuthorName = embed.Author.Name
+		att.AuthorLink = embed.Author.URL
+		att.AuthorIcon = embed.Author.IconURL
+	}
+	if embed.Thumbnail != nil {
+		att.ThumbURL = embed.Thumbnail.URL
+	}
+	for _, f := range embed.Fields {
+		att.Fields = append(att.Fields, chatField{Short: f.Inline, Title: f.Name, Value: f.Value})
+	}
+	if embed.Footer != nil {
+		// Rocket.Chat has no footer, so keep the value visible as a field there
+		if cs.flavor == ChatFlavorRocketChat {
+			att.Fields = append(att.Fields, chatField{Short: true, Title: "Value", Value: strings.TrimPrefix(embed.Footer.Text, "Value: ")})
+		} else {
+			att.Footer = embed.Footer.Text
+		}
+	}
+	return att
+}
@@ -0,0 +1,52 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/pkg/killmail"
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+)
+
+func TestChatWebhookAttachmentTimestamp(t *testing.T) {
+	killTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		flavor string
+		want   interface{}
+	}{
+		{ChatFlavorMattermost, float64(killTime.Unix())},
+		{ChatFlavorRocketChat, "2024-05-01T12:00:00Z"},
+	} {
+		var body struct {
+			Attachments []struct {
+				Ts interface{} `json:"ts"`
+			} `json:"attachments"`
+		}
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			}
+		}))
+		cs := NewChatWebhookSink(logger.Slog(slog.New(slog.NewTextHandler(io.Discard, nil))), tc.flavor, ChatWebhookConfig{URL: srv.URL})
+		cs.SetHTTPClient(srv.Client())
+		err := cs.Send(context.Background(), Notification{
+			Kind:       KindCorpKill,
+			KillMailID: 1,
+			IsKill:     true,
+			Kill:       &killmail.FlattenedKillMail{KillMailID: 1, KillMailTime: killTime, SolarSystemID: 31000001},
+		})
+		srv.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", tc.flavor, err)
+		}
+		if len(body.Attachments) != 1 || body.Attachments[0].Ts != tc.want {
+			t.Errorf("%s attachments %+v, want ts %v", tc.flavor, body.Attachments, tc.want)
+		}
+	}
+}
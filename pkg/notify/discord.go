@@ -0,0 +1,371 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/internal/discord"
+	"github.com/guarzo/eve-chainkills/pkg/killmail"
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+	"golang.org/x/exp/slices"
+)
+
+// DiscordConfig holds the chain and corp-kill webhooks plus the embed colors
+type DiscordConfig struct {
+	ChainWebhookID    string
+	ChainWebhookToken string
+	CorpWebhookID     string
+	CorpWebhookToken  string
+	// BothSidesWebhookID and BothSidesWebhookToken take kills with us on both
+	// sides instead of the corp webhook when set
+	BothSidesWebhookID    string
+	BothSidesWebhookToken string
+	// AbyssalWebhookID and AbyssalWebhookToken take corp kills in Abyssal
+	// Deadspace instead of the corp webhook when set
+	AbyssalWebhookID    string
+	AbyssalWebhookToken string
+	// FactionWarfareWebhookID and FactionWarfareWebhookToken take corp kills
+	// with a Faction Warfare summary instead of the corp webhook when set
+	FactionWarfareWebhookID    string
+	FactionWarfareWebhookToken string
+	KillColor                  string
+	LossColor                  string
+	Images                     killmail.Images
+	ISKFormat                  string
+	// BotToken reads reactions to chain alerts; without it they can't be acknowledged
+	BotToken string
+	// Format is the profile for corp kills, ChainFormat for the chain webhook; both default to rich
+	Format      Format
+	ChainFormat Format
+	// MaxBatch is how many kill embeds queued up for one webhook go out in
+	// one request during bursts (default and most 10, 1 disables batching);
+	// BatchWindow is how long a post waits for more to join it (default 0)
+	MaxBatch    int
+	BatchWindow time.Duration
+	// Webhooks sends the messages; nil uses the default client without a send limit
+	Webhooks *discord.Webhooks
+}
+
+// DiscordSink posts chain and location alerts as "@here" text and corp kills as embeds
+type DiscordSink struct {
+	logger logger.Logger
+	config DiscordConfig
+	// batcher is nil when batching is disabled
+	batcher *embedBatcher
+
+	// links remembers the URLs of the last maxLinks messages by ref, for Link
+	linksMu  sync.Mutex
+	links    map[string]string
+	linkRefs []string
+}
+
+// maxLinks is how many message URLs a DiscordSink remembers
+const maxLinks = 256
+
+// NewDiscordSink constructor
+func NewDiscordSink(logger logger.Logger, config DiscordConfig) *DiscordSink {
+	ds := &DiscordSink{
+		logger: logger,
+		config: config,
+	}
+	switch max := config.MaxBatch; {
+	case max == 0 || max > discord.MaxEmbeds:
+		ds.batcher = newEmbedBatcher(logger, config.Webhooks, config.BatchWindow, discord.MaxEmbeds)
+	case max > 1:
+		ds.batcher = newEmbedBatcher(logger, config.Webhooks, config.BatchWindow, max)
+	}
+	return ds
+}
+
+func (ds *DiscordSink) Name() string {
+	return "discord"
+}
+
+// Send routes chain alerts and corp kills to their respective webhooks
+func (ds *DiscordSink) Send(ctx context.Context, n Notification) error {
+	_, err := ds.SendLink(ctx, n)
+	return err
+}
+
+// SendLink sends like Send and returns the message's URL; during bursts,
+// embeds may share their message with other kills
+func (ds *DiscordSink) SendLink(ctx context.Context, n Notification) (string, error) {
+	text, embed := ds.message(n)
+	id, token := ds.webhook(n)
+	if embed != nil && ds.batcher != nil {
+		msg, err := ds.batcher.post(ctx, id, token, text, *embed)
+		ds.logSent(n, msg, err)
+		return msg.URL(), err
+	}
+	msg, err := ds.post(ctx, n, id, token, text, embed)
+	return msg.URL(), err
+}
+
+// SendRef sends like Send; rich corp kills return "<webhook ID>/<message ID>" for Edit
+func (ds *DiscordSink) SendRef(ctx context.Context, n Notification) (string, error) {
+	text, embed := ds.message(n)
+	if embed == nil {
+		return "", ds.Send(ctx, n)
+	}
+	id, token := ds.webhook(n)
+	msg, err := ds.post(ctx, n, id, token, text, embed)
+	if err != nil || msg.ID == "" {
+		return "", err
+	}
+	ref := id + "/" + msg.ID
+	ds.remember(ref, msg.URL())
+	return ref, nil
+}
+
+// post sends one message, waiting for Discord to say where it landed, and logs its URL
+func (ds *DiscordSink) post(ctx context.Context, n Notification, webhookID, webhookToken, text string, embed *discord.Embed) (discord.Posted, error) {
+	msg, err := ds.config.Webhooks.PostWebhookMessage(ctx, ds.logger, webhookID, webhookToken, text, embed)
+	ds.logSent(n, msg, err)
+	return msg, err
+}
+
+// logSent logs where a notification landed
+func (ds *DiscordSink) logSent(n Notification, msg discord.Posted, err error) {
+	if url := msg.URL(); err == nil && url != "" {
+		ds.logger.Printf("Sent %s notification for kill %d to %s", n.Kind, n.KillMailID, url)
+	}
+}
+
+// remember keeps a message's URL for Link
+func (ds *DiscordSink) remember(ref, url string) {
+	if url == "" {
+		return
+	}
+	ds.linksMu.Lock()
+	defer ds.linksMu.Unlock()
+	if ds.links == nil {
+		ds.links = make(map[string]string, maxLinks)
+	}
+	if len(ds.linkRefs) == maxLinks {
+		delete(ds.links, ds.linkRefs[0])
+		ds.linkRefs = ds.linkRefs[1:]
+	}
+	ds.links[ref] = url
+	ds.linkRefs = append(ds.linkRefs, ref)
+}
+
+// Link returns the URL of a recent message SendRef or SendAckable returned ref for
+func (ds *DiscordSink) Link(ref string) string {
+	ds.linksMu.Lock()
+	defer ds.linksMu.Unlock()
+	return ds.links[ref]
+}
+
+// SendAckable sends like Send; chain alerts return "<channel ID>/<message ID>" for Acks
+func (ds *DiscordSink) SendAckable(ctx context.Context, n Notification) (string, error) {
+	if ds.config.BotToken == "" || n.Kind == KindCorpKill {
+		return "", ds.Send(ctx, n)
+	}
+	text, _ := ds.message(n)
+	msg, err := ds.post(ctx, n, ds.config.ChainWebhookID, ds.config.ChainWebhookToken, text, nil)
+	if err != nil || msg.ID == "" || msg.ChannelID == "" {
+		return "", err
+	}
+	ref := msg.ChannelID + "/" + msg.ID
+	ds.remember(ref, msg.URL())
+	return ref, nil
+}
+
+// Acks lists the people, not bots, who reacted with emoji to a chain alert sent by SendAckable
+func (ds *DiscordSink) Acks(ctx context.Context, ref, emoji string) ([]string, error) {
+	channelID, msgID, ok := strings.Cut(ref, "/")
+	if !ok {
+		return nil, fmt.Errorf("cannot read reactions to discord message %q", ref)
+	}
+	users, err := discord.Reactions(ctx, ds.config.Webhooks.Client(), ds.config.BotToken, channelID, msgID, emoji)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, u := range users {
+		if !u.Bot {
+			names = append(names, u.Username)
+		}
+	}
+	return names, nil
+}
+
+// Edit replaces a corp-kill embed sent by SendRef
+func (ds *DiscordSink) Edit(ctx context.Context, ref string, n Notification) error {
+	webhookID, msgID, ok := strings.Cut(ref, "/")
+	if !ok || n.Kill == nil {
+		return fmt.Errorf("cannot edit discord message %q", ref)
+	}
+	var token string
+	switch webhookID {
+	case ds.config.CorpWebhookID:
+		token = ds.config.CorpWebhookToken
+	case ds.config.BothSidesWebhookID:
+		token = ds.config.BothSidesWebhookToken
+	case ds.config.AbyssalWebhookID:
+		token = ds.config.AbyssalWebhookToken
+	case ds.config.FactionWarfareWebhookID:
+		token = ds.config.FactionWarfareWebhookToken
+	default:
+		return fmt.Errorf("discord message %q was sent by an unknown webhook", ref)
+	}
+	text, embed := ds.corpEmbed(n)
+	return ds.config.Webhooks.EditWebhookMessage(ctx, ds.logger, webhookID, token, msgID, text, embed)
+}
+
+// SendBatch posts aggregated kills from one system together: rich corp kills
+// as one message with an embed each, rich chain alerts and compact kills as one
+// message with a line each. Plain and JSON kills go one by one.
+func (ds *DiscordSink) SendBatch(ctx context.Context, ns []Notification) error {
+	if len(ns) == 0 {
+		return nil
+	}
+	first := ns[0]
+	switch ds.format(first) {
+	case FormatCompact:
+		lines := make([]string, len(ns))
+		for i, n := range ns {
+			lines[i] = n.CompactText(ds.config.ISKFormat)
+		}
+		id, token := ds.webhook(first)
+		return ds.config.Webhooks.SendWebhook(ctx, ds.logger, id, token, first.alertMention()+strings.Join(lines, "\n"), nil)
+	case FormatPlain, FormatJSON:
+		var lastErr error
+		for _, n := range ns {
+			if err := ds.Send(ctx, n); err != nil {
+				lastErr = err
+			}
+		}
+		return lastErr
+	}
+	if first.Kind == KindCorpKill && first.Kill != nil {
+		texts := []string{}
+		embeds := make([]discord.Embed, 0, len(ns))
+		for _, n := range ns {
+			text, embed := ds.corpEmbed(n)
+			if text != "" && !slices.Contains(texts, text) {
+				texts = append(texts, text)
+			}
+			embeds = append(embeds, embed)
+		}
+		id, token := ds.corpWebhook(first)
+		return ds.config.Webhooks.SendWebhookEmbeds(ctx, ds.logger, id, token, strings.Join(texts, "\n"), embeds)
+	}
+
+	lines := make([]string, len(ns))
+	for i, n := range ns {
+		lines[i] = "• " + ds.chainPost(n)
+	}
+	post := fmt.Sprintf("%s%d ships died %s:\n%s", first.mention(), len(ns), first.Where(), strings.Join(lines, "\n"))
+	return ds.config.Webhooks.SendWebhook(ctx, ds.logger, ds.config.ChainWebhookID, ds.config.ChainWebhookToken, post, nil)
+}
+
+// format is the profile of the webhook n goes to
+func (ds *DiscordSink) format(n Notification) Format {
+	if n.Kind == KindCorpKill && n.Kill != nil {
+		return ds.config.Format
+	}
+	return ds.config.ChainFormat
+}
+
+// message renders n in its webhook's format: the text, plus the embed of a rich corp kill
+func (ds *DiscordSink) message(n Notification) (string, *discord.Embed) {
+	if text := formatText(ds.format(n), n, ds.config.ISKFormat, nil); text != "" {
+		return text, nil
+	}
+	if n.Kind == KindCorpKill && n.Kill != nil {
+		text, embed := ds.corpEmbed(n)
+		return text, &embed
+	}
+	return n.mention() + ds.chainPost(n), nil
+}
+
+// Preview renders n as it would be posted, without posting it
+func (ds *DiscordSink) Preview(n Notification) (string, *discord.Embed) {
+	return ds.message(n)
+}
+
+// webhook picks the webhook n goes to
+func (ds *DiscordSink) webhook(n Notification) (string, string) {
+	if n.Kind == KindCorpKill && n.Kill != nil {
+		return ds.corpWebhook(n)
+	}
+	return ds.config.ChainWebhookID, ds.config.ChainWebhookToken
+}
+
+// corpEmbed builds the embed for a corp kill and the text posted with it
+func (ds *DiscordSink) corpEmbed(n Notification) (string, discord.Embed) {
+	style := discord.EmbedStyle{
+		Colors:    discord.Colors{Kill: ds.config.KillColor, Loss: ds.config.LossColor},
+		Images:    ds.config.Images,
+		ISKFormat: ds.config.ISKFormat,
+	}
+	embed := discord.NewKillEmbed(ds.logger, style, *n.Kill, n.IsKill).CreateEmbed()
+	addReasonFooter(&embed, n.Reason)
+	if n.Updated {
+		addFooter(&embed, "Updated with the settled value")
+	}
+	if n.Partial {
+		addFooter(&embed, "ESI was slow; some names are IDs")
+	}
+	text := ""
+	if n.Location != "" {
+		text = "At " + n.Location
+	}
+	if sides := n.SidesText(); sides != "" {
+		text = strings.TrimSpace(sides + " " + text)
+	}
+	if danger := n.DangerText(); danger != "" {
+		text = strings.TrimSpace(n.mention() + danger + " " + text)
+	}
+	return text, embed
+}
+
+// corpWebhook picks the webhook for a corp kill
+func (ds *DiscordSink) corpWebhook(n Notification) (string, string) {
+	if n.BothSides && ds.config.BothSidesWebhookID != "" {
+		return ds.config.BothSidesWebhookID, ds.config.BothSidesWebhookToken
+	}
+	if n.Kill != nil && killmail.IsAbyssal(n.Kill.SolarSystemID) && ds.config.AbyssalWebhookID != "" {
+		return ds.config.AbyssalWebhookID, ds.config.AbyssalWebhookToken
+	}
+	if n.Kill != nil && n.Kill.FactionWarfare != "" && ds.config.FactionWarfareWebhookID != "" {
+		return ds.config.FactionWarfareWebhookID, ds.config.FactionWarfareWebhookToken
+	}
+	return ds.config.CorpWebhookID, ds.config.CorpWebhookToken
+}
+
+// chainPost is a chain alert's text without the mention
+func (ds *DiscordSink) chainPost(n Notification) string {
+	post := n.ChainText()
+	if t := n.KillTime(); !t.IsZero() {
+		post += fmt.Sprintf("\nKilled %s (%s)", discord.Timestamp(t, "R"), killmail.FormatEVETime(t))
+	}
+	if n.Reason.Text != "" {
+		// "-# " is Discord's small subtext, the closest a plain message has to a footer
+		post += "\n-# Matched: " + n.Reason.Text
+	}
+	return post
+}
+
+// addReasonFooter appends the match reason to an embed's footer
+func addReasonFooter(embed *discord.Embed, reason MatchReason) {
+	if reason.Text == "" {
+		return
+	}
+	addFooter(embed, "Matched: "+reason.Text)
+}
+
+// addFooter appends text to an embed's footer
+func addFooter(embed *discord.Embed, text string) {
+	if embed.Footer == nil {
+		embed.Footer = &discord.Footer{}
+	}
+	if embed.Footer.Text != "" {
+		embed.Footer.Text += " · "
+	}
+	embed.Footer.Text += text
+}
@@ -0,0 +1,123 @@
+package notify
+
+import (
+	"context"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/internal/discord"
+	"github.com/guarzo/eve-chainkills/internal/lanes"
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+)
+
+// embedBatcher combines kill embeds bound for the same webhook into one
+// request during bursts. A webhook with nothing in flight posts right away
+// (after the window, if one is set); embeds that arrive while a post is in
+// flight wait and go out together with the next one, up to max per request.
+type embedBatcher struct {
+	logger   logger.Logger
+	webhooks *discord.Webhooks
+	window   time.Duration
+	max      int
+
+	mu     sync.Mutex
+	queues map[string]*embedQueue
+}
+
+type embedQueue struct {
+	busy    bool
+	pending []*queuedEmbed
+}
+
+// queuedEmbed is one Send waiting for its batch to be posted
+type queuedEmbed struct {
+	ctx   context.Context
+	token string
+	text  string
+	embed discord.Embed
+	done  chan struct{}
+	msg   discord.Posted
+	err   error
+}
+
+func newEmbedBatcher(logger logger.Logger, webhooks *discord.Webhooks, window time.Duration, max int) *embedBatcher {
+	return &embedBatcher{logger: logger, webhooks: webhooks, window: window, max: max, queues: map[string]*embedQueue{}}
+}
+
+// post queues one embed and waits until its batch is sent
+func (b *embedBatcher) post(ctx context.Context, webhookID, webhookToken, text string, embed discord.Embed) (discord.Posted, error) {
+	item := &queuedEmbed{ctx: ctx, token: webhookToken, text: text, embed: embed, done: make(chan struct{})}
+	b.mu.Lock()
+	q := b.queues[webhookID]
+	if q == nil {
+		q = &embedQueue{}
+		b.queues[webhookID] = q
+	}
+	q.pending = append(q.pending, item)
+	if !q.busy {
+		q.busy = true
+		go b.drain(webhookID, q)
+	}
+	b.mu.Unlock()
+
+	select {
+	case <-item.done:
+		return item.msg, item.err
+	case <-ctx.Done():
+		// the batch may still go out; only this caller stops waiting
+		return discord.Posted{}, ctx.Err()
+	}
+}
+
+// drain posts the queue's batches until it is empty
+func (b *embedBatcher) drain(webhookID string, q *embedQueue) {
+	for {
+		if b.window > 0 {
+			time.Sleep(b.window)
+		}
+		b.mu.Lock()
+		n := min(len(q.pending), b.max)
+		batch := q.pending[:n:n]
+		q.pending = q.pending[n:]
+		if n == 0 {
+			q.busy = false
+			b.mu.Unlock()
+			return
+		}
+		b.mu.Unlock()
+		b.send(webhookID, batch)
+	}
+}
+
+// send posts a batch as one request, under the context of its most urgent kill
+func (b *embedBatcher) send(webhookID string, batch []*queuedEmbed) {
+	lead := batch[0]
+	var texts []string
+	embeds := make([]discord.Embed, len(batch))
+	for i, item := range batch {
+		if lanes.Priority(item.ctx, math.MaxInt) < lanes.Priority(lead.ctx, math.MaxInt) {
+			lead = item
+		}
+		if item.text != "" {
+			texts = append(texts, item.text)
+		}
+		embeds[i] = item.embed
+	}
+	// a batch outlives any one caller giving up on it
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(lead.ctx), time.Minute)
+	defer cancel()
+	if len(batch) > 1 {
+		b.logger.Debugf("Posting %d kill embeds to discord webhook %s in one request", len(batch), webhookID)
+	}
+	posted, err := b.webhooks.PostWebhookEmbeds(ctx, b.logger, webhookID, lead.token, strings.Join(texts, "\n"), embeds)
+	for i, item := range batch {
+		if i < len(posted) {
+			item.msg = posted[i]
+		} else {
+			item.err = err
+		}
+		close(item.done)
+	}
+}
@@ -0,0 +1,216 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/internal/discord"
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+)
+
+// webhookRequest is one request the batch transport got
+type webhookRequest struct {
+	webhook string
+	content string
+	embeds  []string
+}
+
+// batchTransport answers Discord webhook posts, holding the first one back
+// until release is closed and failing webhooks named "down"
+type batchTransport struct {
+	release chan struct{}
+	started chan struct{}
+
+	mu       sync.Mutex
+	requests []webhookRequest
+}
+
+func (bt *batchTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body struct {
+		Content string `json:"content"`
+		Embeds  []struct {
+			Title string `json:"title"`
+		} `json:"embeds"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	r := webhookRequest{webhook: strings.Split(strings.TrimPrefix(req.URL.Path, "/api/webhooks/"), "/")[0], content: body.Content}
+	for _, e := range body.Embeds {
+		r.embeds = append(r.embeds, e.Title)
+	}
+	bt.mu.Lock()
+	bt.requests = append(bt.requests, r)
+	first := len(bt.requests) == 1
+	n := len(bt.requests)
+	bt.mu.Unlock()
+	if first && bt.release != nil {
+		close(bt.started)
+		<-bt.release
+	}
+	status, reply := http.StatusOK, fmt.Sprintf(`{"id":"m%d","channel_id":"c","guild_id":"g"}`, n)
+	if r.webhook == "down" {
+		status, reply = http.StatusInternalServerError, `{}`
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(reply)),
+		Request:    req,
+	}, nil
+}
+
+func (bt *batchTransport) sizes(webhook string) []int {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+	var sizes []int
+	for _, r := range bt.requests {
+		if r.webhook == webhook {
+			sizes = append(sizes, len(r.embeds))
+		}
+	}
+	return sizes
+}
+
+func newTestBatcher(bt *batchTransport, window time.Duration, max int) *embedBatcher {
+	client := &http.Client{Transport: bt}
+	return newEmbedBatcher(logger.Slog(slog.New(slog.NewTextHandler(io.Discard, nil))), discord.NewWebhooks(client, 0, 1), window, max)
+}
+
+// queued counts the embeds waiting for a webhook
+func (b *embedBatcher) queued(webhookID string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if q := b.queues[webhookID]; q != nil {
+		return len(q.pending)
+	}
+	return 0
+}
+
+func TestEmbedBatcherBatchesWhileBusy(t *testing.T) {
+	bt := &batchTransport{release: make(chan struct{}), started: make(chan struct{})}
+	b := newTestBatcher(bt, 0, 10)
+	ctx := context.Background()
+
+	type result struct {
+		msg discord.Posted
+		err error
+	}
+	results := make([]result, 13)
+	var wg sync.WaitGroup
+	postKill := func(i int) {
+		defer wg.Done()
+		msg, err := b.post(ctx, "1", "token", "", discord.Embed{Title: fmt.Sprintf("kill %d", i)})
+		results[i] = result{msg, err}
+	}
+
+	// a webhook with nothing in flight posts right away
+	wg.Add(1)
+	go postKill(0)
+	<-bt.started
+	// the rest arrive while that post is in flight
+	for i := 1; i < len(results); i++ {
+		wg.Add(1)
+		go postKill(i)
+	}
+	for b.queued("1") < len(results)-1 {
+		time.Sleep(time.Millisecond)
+	}
+	// other webhooks don't wait for this one
+	if _, err := b.post(ctx, "2", "token", "", discord.Embed{Title: "elsewhere"}); err != nil {
+		t.Fatal(err)
+	}
+	close(bt.release)
+	wg.Wait()
+
+	if sizes := bt.sizes("1"); len(sizes) != 3 || sizes[0] != 1 || sizes[1] != 10 || sizes[2] != 2 {
+		t.Errorf("webhook 1 got requests of %v embeds, want 1, then 10, then 2", sizes)
+	}
+	if sizes := bt.sizes("2"); len(sizes) != 1 {
+		t.Errorf("webhook 2 got requests of %v embeds, want one", sizes)
+	}
+	ids := map[string]int{}
+	for i, r := range results {
+		if r.err != nil || r.msg.ID == "" {
+			t.Errorf("kill %d posted %+v, %v", i, r.msg, r.err)
+		}
+		ids[r.msg.ID]++
+	}
+	if len(ids) != 3 {
+		t.Errorf("kills landed in messages %v, want 3", ids)
+	}
+	// the queue is idle again and posts right away
+	if _, err := b.post(ctx, "1", "token", "", discord.Embed{Title: "later"}); err != nil || b.queued("1") != 0 {
+		t.Errorf("post after the burst = %v with %d queued", err, b.queued("1"))
+	}
+}
+
+func TestEmbedBatcherWindow(t *testing.T) {
+	bt := &batchTransport{}
+	b := newTestBatcher(bt, 50*time.Millisecond, 10)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			text := ""
+			if i == 1 {
+				text = "@here"
+			}
+			if _, err := b.post(context.Background(), "1", "token", text, discord.Embed{Title: fmt.Sprintf("kill %d", i)}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if sizes := bt.sizes("1"); len(sizes) != 1 || sizes[0] != 3 {
+		t.Errorf("got requests of %v embeds, want the 3 kills in the window together", sizes)
+	}
+	if content := bt.requests[0].content; content != "@here" {
+		t.Errorf("batch content %q, want the kills' texts", content)
+	}
+}
+
+func TestEmbedBatcherErrors(t *testing.T) {
+	bt := &batchTransport{}
+	b := newTestBatcher(bt, 20*time.Millisecond, 10)
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = b.post(context.Background(), "down", "token", "", discord.Embed{Title: "kill"})
+		}()
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err == nil || !strings.Contains(err.Error(), "status 500") {
+			t.Errorf("kill %d in a failed batch got %v, want the webhook's error", i, err)
+		}
+	}
+
+	// a caller that gives up stops waiting, but its kill still goes out
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := b.post(ctx, "1", "token", "", discord.Embed{Title: "kill"}); err != context.Canceled {
+		t.Errorf("cancelled post = %v, want context.Canceled", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for len(bt.sizes("1")) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if sizes := bt.sizes("1"); len(sizes) != 1 {
+		t.Errorf("cancelled kill went out in %v requests, want 1", sizes)
+	}
+}
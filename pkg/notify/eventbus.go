@@ -0,0 +1,122 @@
+package notify
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Event bus payload encodings
+const (
+	EncodingJSON     = "json"
+	EncodingProtobuf = "protobuf"
+)
+
+// encodeKillEvent serializes a notification for the NATS/MQTT sinks.
+// The protobuf layout is documented in proto/killevent.proto.
+func encodeKillEvent(n Notification, encoding string) ([]byte, error) {
+	switch strings.ToLower(encoding) {
+	case "", EncodingJSON:
+		return json.Marshal(NewKillEvent(n))
+	case EncodingProtobuf:
+		return marshalKillEventProto(NewKillEvent(n)), nil
+	default:
+		return nil, fmt.Errorf("unknown event encoding %q", encoding)
+	}
+}
+
+// marshalKillEventProto hand-encodes a KillEvent in protobuf wire format,
+// so we don't need generated code or the protobuf runtime for one message.
+func marshalKillEventProto(ev KillEvent) []byte {
+	var pb protoBuffer
+	pb.string(1, string(ev.Event))
+	pb.bool(2, ev.IsKill)
+	pb.string(3, ev.SystemAlias)
+	pb.varint(4, uint64(ev.AttackerCount))
+	pb.string(5, ev.ZkillURL)
+	if !ev.SentAt.IsZero() {
+		pb.varint(6, uint64(ev.SentAt.Unix()))
+	}
+	pb.string(7, ev.Location)
+	pb.bool(37, ev.BothSides)
+	pb.string(38, ev.Via)
+	if r := ev.MatchReason; r != nil {
+		pb.string(8, r.Rule)
+		pb.string(9, r.Text)
+		pb.varint(19, uint64(r.ID))
+	}
+
+	if km := ev.KillMail; km != nil {
+		pb.varint(10, uint64(km.KillMailID))
+		pb.string(11, km.Hash)
+		if !km.KillMailTime.IsZero() {
+			pb.varint(12, uint64(km.KillMailTime.Unix()))
+		}
+		pb.varint(13, uint64(km.SolarSystemID))
+		pb.string(14, km.SystemName)
+		pb.double(15, km.TotalValue)
+		pb.bool(16, km.NPC)
+		pb.bool(17, km.Solo)
+		pb.bool(18, km.Awox)
+
+		pb.varint(20, uint64(km.Victim.CharacterID))
+		pb.varint(21, uint64(km.Victim.CorporationID))
+		pb.varint(22, uint64(km.Victim.AllianceID))
+		pb.varint(23, uint64(km.Victim.ShipTypeID))
+		pb.string(24, km.VictimCharacterName)
+		pb.string(25, km.VictimShipName)
+		pb.string(26, km.VictimCorpName)
+		pb.string(27, km.VictimAllianceName)
+
+		pb.varint(30, uint64(km.FinalAttackerID))
+		pb.varint(31, uint64(km.FinalAttackerCorpID))
+		pb.varint(32, uint64(km.FinalAttackerAllianceID))
+		pb.string(33, km.FinalAttackerName)
+		pb.string(34, km.FinalAttackerShipName)
+		pb.string(35, km.FinalAttackerCorpName)
+		pb.string(36, km.FinalAttackerAllianceName)
+	}
+	return pb.buf
+}
+
+// protoBuffer is a minimal protobuf writer. Zero values are skipped, as proto3 does.
+type protoBuffer struct {
+	buf []byte
+}
+
+func (pb *protoBuffer) tag(field int, wireType int) {
+	pb.buf = binary.AppendUvarint(pb.buf, uint64(field<<3|wireType))
+}
+
+func (pb *protoBuffer) varint(field int, v uint64) {
+	if v == 0 {
+		return
+	}
+	pb.tag(field, 0)
+	pb.buf = binary.AppendUvarint(pb.buf, v)
+}
+
+func (pb *protoBuffer) bool(field int, v bool) {
+	if v {
+		pb.varint(field, 1)
+	}
+}
+
+func (pb *protoBuffer) double(field int, v float64) {
+	if v == 0 {
+		return
+	}
+	pb.tag(field, 1)
+	pb.buf = binary.LittleEndian.AppendUint64(pb.buf, math.Float64bits(v))
+}
+
+func (pb *protoBuffer) string(field int, v string) {
+	if v == "" {
+		return
+	}
+	pb.tag(field, 2)
+	pb.buf = binary.AppendUvarint(pb.buf, uint64(len(v)))
+	pb.buf = append(pb.buf, v...)
+}
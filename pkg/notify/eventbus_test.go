@@ -0,0 +1,140 @@
+package notify
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/pkg/killmail"
+)
+
+// protoField is one decoded field: a varint or fixed64 in num, bytes in raw
+type protoField struct {
+	wireType int
+	num      uint64
+	raw      []byte
+}
+
+// decodeProto splits protobuf wire format into its fields by number
+func decodeProto(t *testing.T, buf []byte) map[int]protoField {
+	t.Helper()
+	fields := map[int]protoField{}
+	for len(buf) > 0 {
+		key, n := binary.Uvarint(buf)
+		if n <= 0 {
+			t.Fatalf("bad field key at %x", buf)
+		}
+		buf = buf[n:]
+		field, wireType := int(key>>3), int(key&7)
+		if _, dup := fields[field]; dup {
+			t.Fatalf("field %d encoded twice", field)
+		}
+		f := protoField{wireType: wireType}
+		switch wireType {
+		case 0:
+			f.num, n = binary.Uvarint(buf)
+			if n <= 0 {
+				t.Fatalf("bad varint in field %d", field)
+			}
+			buf = buf[n:]
+		case 1:
+			if len(buf) < 8 {
+				t.Fatalf("short fixed64 in field %d", field)
+			}
+			f.num, buf = binary.LittleEndian.Uint64(buf), buf[8:]
+		case 2:
+			size, n := binary.Uvarint(buf)
+			if n <= 0 || uint64(len(buf)-n) < size {
+				t.Fatalf("bad length in field %d", field)
+			}
+			f.raw, buf = buf[n:n+int(size)], buf[n+int(size):]
+		default:
+			t.Fatalf("unexpected wire type %d in field %d", wireType, field)
+		}
+		fields[field] = f
+	}
+	return fields
+}
+
+func TestMarshalKillEventProto(t *testing.T) {
+	killTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	sentAt := time.Date(2024, 5, 1, 12, 0, 5, 0, time.UTC)
+	ev := KillEvent{
+		Event:         KindCorpKill,
+		IsKill:        true,
+		BothSides:     true,
+		SystemAlias:   "home",
+		Location:      "Staging",
+		MatchReason:   &MatchReason{Rule: "attacker_tracked", ID: 98000001, Text: "attacker corp 98000001 in tracked list"},
+		AttackerCount: 12,
+		ZkillURL:      "https://zkillboard.com/kill/123456789/",
+		SentAt:        sentAt,
+		Via:           "peer",
+		KillMail: &killmail.FlattenedKillMail{
+			KillMailID:    123456789,
+			Hash:          "abc123",
+			KillMailTime:  killTime,
+			SolarSystemID: 31000001,
+			SystemName:    "J123456",
+			TotalValue:    1.5e9,
+			Solo:          true,
+			// character IDs past 2^31, as newer EVE characters have
+			Victim:                  killmail.Victim{CharacterID: 2_200_000_001, CorporationID: 98000002, AllianceID: 99000003, ShipTypeID: 587},
+			VictimCharacterName:     "Victim Pilot",
+			VictimShipName:          "Rifter",
+			VictimCorpName:          "Victim Corp",
+			VictimAllianceName:      "Victim Alliance",
+			FinalAttackerID:         3_000_000_004,
+			FinalAttackerCorpID:     98000001,
+			FinalAttackerAllianceID: 99000005,
+			FinalAttackerName:       "Attacker Pilot",
+			FinalAttackerShipName:   "Loki",
+			FinalAttackerCorpName:   "Our Corp",
+		},
+	}
+
+	fields := decodeProto(t, marshalKillEventProto(ev))
+
+	// field numbers from proto/killevent.proto
+	wantStrings := map[int]string{
+		1: "corpKill", 3: "home", 5: "https://zkillboard.com/kill/123456789/", 7: "Staging",
+		8: "attacker_tracked", 9: "attacker corp 98000001 in tracked list", 11: "abc123", 14: "J123456",
+		24: "Victim Pilot", 25: "Rifter", 26: "Victim Corp", 27: "Victim Alliance",
+		33: "Attacker Pilot", 34: "Loki", 35: "Our Corp", 38: "peer",
+	}
+	for field, want := range wantStrings {
+		if f := fields[field]; f.wireType != 2 || string(f.raw) != want {
+			t.Errorf("field %d = %+v, want string %q", field, f, want)
+		}
+	}
+	wantVarints := map[int]uint64{
+		2: 1, 4: 12, 6: uint64(sentAt.Unix()), 17: 1, 19: 98000001, 37: 1,
+		10: 123456789, 12: uint64(killTime.Unix()), 13: 31000001,
+		20: 2_200_000_001, 21: 98000002, 22: 99000003, 23: 587,
+		30: 3_000_000_004, 31: 98000001, 32: 99000005,
+	}
+	for field, want := range wantVarints {
+		if f := fields[field]; f.wireType != 0 || f.num != want {
+			t.Errorf("field %d = %+v, want varint %d", field, f, want)
+		}
+	}
+	if f := fields[15]; f.wireType != 1 || math.Float64frombits(f.num) != 1.5e9 {
+		t.Errorf("field 15 = %+v, want double 1.5e9", f)
+	}
+	// zero values are left out, as proto3 does
+	for _, field := range []int{16, 18, 36} {
+		if f, ok := fields[field]; ok {
+			t.Errorf("field %d = %+v, want it omitted", field, f)
+		}
+	}
+	if want := len(wantStrings) + len(wantVarints) + 1; len(fields) != want {
+		t.Errorf("decoded %d fields, want %d", len(fields), want)
+	}
+}
+
+func TestMarshalKillEventProtoEmpty(t *testing.T) {
+	if buf := marshalKillEventProto(KillEvent{}); len(buf) != 0 {
+		t.Errorf("empty event encoded as %x, want nothing", buf)
+	}
+}
@@ -0,0 +1,137 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/internal/httpclient"
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+)
+
+// FederationVersion is the envelope format FederationSink sends
+const FederationVersion = 1
+
+// FederationEnvelope carries one alert to a federated instance. The request
+// body is signed like a generic webhook, with the secret shared with that peer.
+type FederationEnvelope struct {
+	Version int `json:"v"`
+	// Source names the sending instance; the receiver picks the secret by it
+	Source string    `json:"source"`
+	SentAt time.Time `json:"sent_at"`
+	Kill   KillEvent `json:"kill"`
+}
+
+// FederationPeer is an allied instance alerts are sent to
+type FederationPeer struct {
+	Name   string
+	URL    string
+	Secret string
+}
+
+// FederationSink sends every alert of ours to each peer in a signed envelope
+type FederationSink struct {
+	logger logger.Logger
+	source string
+	peers  []FederationPeer
+	// http is nil for the default client
+	http *http.Client
+}
+
+// NewFederationSink constructor; source is how peers know this instance
+func NewFederationSink(logger logger.Logger, source string, peers []FederationPeer) *FederationSink {
+	return &FederationSink{
+		logger: logger,
+		source: source,
+		peers:  peers,
+	}
+}
+
+// SetHTTPClient sends through client instead of the default one
+func (fs *FederationSink) SetHTTPClient(client *http.Client) {
+	fs.http = client
+}
+
+func (fs *FederationSink) Name() string {
+	return "federation"
+}
+
+// Send posts the notification to every peer; alerts that came from a peer aren't sent on
+func (fs *FederationSink) Send(ctx context.Context, n Notification) error {
+	if n.Via != "" {
+		return nil
+	}
+	payload, err := json.Marshal(FederationEnvelope{
+		Version: FederationVersion,
+		Source:  fs.source,
+		SentAt:  time.Now().UTC(),
+		Kill:    NewKillEvent(n),
+	})
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, p := range fs.peers {
+		if err := fs.post(ctx, p, payload, n.KillMailID); err != nil {
+			errs = append(errs, fmt.Errorf("peer %s: %w", p.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (fs *FederationSink) post(ctx context.Context, p FederationPeer, payload []byte, killID int64) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookSignatureHeader, "sha256="+signWebhookPayload(p.Secret, payload))
+	setCorrelation(ctx, req, killID)
+	resp, err := httpclient.Or(fs.http).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("federation %s got status %d", p.URL, resp.StatusCode)
+	}
+	return nil
+}
+
+// VerifySignature reports whether header, "sha256=<hex>", is payload's HMAC-SHA256 keyed by secret
+func VerifySignature(secret string, payload []byte, header string) bool {
+	sig, ok := strings.CutPrefix(header, "sha256=")
+	if !ok || secret == "" {
+		return false
+	}
+	return hmac.Equal([]byte(sig), []byte(signWebhookPayload(secret, payload)))
+}
+
+// Notification converts a received KillEvent back into a notification
+func (ev KillEvent) Notification() Notification {
+	n := Notification{
+		Kind:          ev.Event,
+		SystemAlias:   ev.SystemAlias,
+		AttackerCount: ev.AttackerCount,
+		IsKill:        ev.IsKill,
+		BothSides:     ev.BothSides,
+		Location:      ev.Location,
+		NewGroups:     ev.NewGroups,
+		Friendlies:    ev.Friendlies,
+		Via:           ev.Via,
+		Kill:          ev.KillMail,
+	}
+	if ev.MatchReason != nil {
+		n.Reason = *ev.MatchReason
+	}
+	if ev.KillMail != nil {
+		n.KillMailID = ev.KillMail.KillMailID
+	}
+	return n
+}
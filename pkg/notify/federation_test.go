@@ -0,0 +1,26 @@
+package notify
+
+import "testing"
+
+func TestVerifySignature(t *testing.T) {
+	payload := []byte(`{"v":1,"source":"allies"}`)
+	good := "sha256=" + signWebhookPayload("shared", payload)
+	for _, tc := range []struct {
+		secret, header string
+		payload        []byte
+		want           bool
+	}{
+		{"shared", good, payload, true},
+		{"other", good, payload, false},
+		{"shared", good, []byte(`{"v":1,"source":"allied"}`), false},
+		{"shared", good[len("sha256="):], payload, false},
+		{"shared", "sha1=" + good[len("sha256="):], payload, false},
+		{"shared", "", payload, false},
+		// without a secret nothing verifies, even a signature made with none
+		{"", "sha256=" + signWebhookPayload("", payload), payload, false},
+	} {
+		if got := VerifySignature(tc.secret, tc.payload, tc.header); got != tc.want {
+			t.Errorf("VerifySignature(%q, %s, %q) = %v, want %v", tc.secret, tc.payload, tc.header, got, tc.want)
+		}
+	}
+}
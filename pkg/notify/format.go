@@ -0,0 +1,153 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/pkg/killmail"
+)
+
+// Format is a sink's formatting profile: how the same notification is rendered
+type Format string
+
+const (
+	// FormatRich is the sink's full layout, e.g. Discord embeds; the default for chat sinks
+	FormatRich Format = "rich"
+	// FormatCompact is one line per kill
+	FormatCompact Format = "compact"
+	// FormatPlain is multi-line text without embeds or markup
+	FormatPlain Format = "plain"
+	// FormatJSON is the KillEvent; chat sinks post it as a code block
+	FormatJSON Format = "json"
+)
+
+// Validate checks a configured format; "" is the sink's default
+func (f Format) Validate() error {
+	switch f {
+	case "", FormatRich, FormatCompact, FormatPlain, FormatJSON:
+		return nil
+	}
+	return fmt.Errorf("unknown format %q, want rich, compact, plain or json", string(f))
+}
+
+// label names what happened, e.g. "Kill", "Loss" or "Chain kill"
+func (n Notification) label() string {
+	switch n.Kind {
+	case KindCorpKill:
+		switch {
+		case n.BothSides:
+			return "Both sides"
+		case n.IsKill:
+			return "Kill"
+		}
+		return "Loss"
+	case KindLocation:
+		return "Location kill"
+	case KindDanger:
+		return "Friendlies in danger"
+	}
+	return "Chain kill"
+}
+
+// alertMention is the mention for formatted text: chain, location and danger
+// alerts ping, corp kills only when friendlies are in danger
+func (n Notification) alertMention() string {
+	if n.Kind == KindCorpKill && len(n.Friendlies) == 0 {
+		return ""
+	}
+	return n.mention()
+}
+
+// where is Where, falling back to the kill's system name for corp kills
+func (n Notification) where() string {
+	if n.SystemAlias == "" && n.Kill != nil && n.Kill.SystemName != "" {
+		return "in " + n.Kill.SystemName
+	}
+	return n.Where()
+}
+
+// CompactText renders the notification as one line, e.g.
+// "Kill: Loki (Alice) in J123456, 1.2b ISK, 6 attackers · https://zkillboard.com/kill/1/"
+func (n Notification) CompactText(iskFormat string) string {
+	if n.Kind == KindCorpKill && n.Kill != nil {
+		k := n.Kill
+		return fmt.Sprintf("%s: %s (%s) %s, %s, %s · %s", n.label(),
+			valueOr(k.VictimShipName, "UnknownShip"), valueOr(k.VictimCharacterName, "UnknownVictim"), n.where(),
+			killmail.FormatISK(k.TotalValue, iskFormat), plural(k.AttackerCount(), "attacker"), n.ZkillURL())
+	}
+	text := fmt.Sprintf("%s %s: %s", n.label(), n.where(), plural(n.AttackerCount, "attacker"))
+	if len(n.Friendlies) > 0 {
+		text += ", near " + strings.Join(n.Friendlies, ", ")
+	}
+	if len(n.NewGroups) > 0 {
+		text += fmt.Sprintf(", %s new to the chain", plural(len(n.NewGroups), "group"))
+	}
+	text += " · " + n.ZkillURL()
+	if n.MapURL != "" {
+		text += " · map: " + n.MapURL
+	}
+	return text
+}
+
+// PlainText renders the notification as multi-line text without markup, with
+// kill times in loc as well as EVE time when loc is set
+func (n Notification) PlainText(iskFormat string, loc *time.Location) string {
+	var text string
+	if n.Kind == KindCorpKill && n.Kill != nil {
+		k := n.Kill
+		var lines []string
+		if danger := n.DangerText(); danger != "" {
+			lines = append(lines, danger)
+		}
+		if sides := n.SidesText(); sides != "" {
+			lines = append(lines, sides)
+		}
+		lines = append(lines,
+			fmt.Sprintf("%s: %s destroyed %s", n.label(), valueOr(k.VictimShipName, "UnknownShip"), n.where()),
+			"Victim: "+valueOr(k.VictimCharacterName, "UnknownVictim"),
+			fmt.Sprintf("Final blow: %s (%s)", valueOr(k.FinalAttackerName, "UnknownAttacker"), valueOr(k.FinalAttackerShipName, "UnknownShip")),
+			fmt.Sprintf("Attackers: %d", k.AttackerCount()),
+			"Value: "+killmail.FormatISK(k.TotalValue, iskFormat),
+			n.ZkillURL())
+		if n.Location != "" {
+			lines = append(lines, "At "+n.Location)
+		}
+		text = strings.Join(lines, "\n")
+	} else {
+		text = n.ChainText()
+	}
+	text = n.plainKillTime(text, loc)
+	if n.Reason.Text != "" {
+		text += "\nMatched: " + n.Reason.Text
+	}
+	return text
+}
+
+// JSONText is the indented KillEvent. Chat messages are short, so withKill
+// false leaves the killmail out.
+func (n Notification) JSONText(withKill bool) string {
+	ev := NewKillEvent(n)
+	if !withKill {
+		ev.KillMail = nil
+	}
+	b, err := json.MarshalIndent(ev, "", "  ")
+	if err != nil {
+		return err.Error()
+	}
+	return string(b)
+}
+
+// formatText renders a notification for a text-only format, or "" for rich
+func formatText(f Format, n Notification, iskFormat string, loc *time.Location) string {
+	switch f {
+	case FormatCompact:
+		return n.alertMention() + n.CompactText(iskFormat)
+	case FormatPlain:
+		return n.alertMention() + n.PlainText(iskFormat, loc)
+	case FormatJSON:
+		return "```json\n" + n.JSONText(false) + "\n```"
+	}
+	return ""
+}
@@ -0,0 +1,219 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+)
+
+// MQTTConfig configures publishing matched kills to an MQTT topic
+type MQTTConfig struct {
+	Broker   string `json:"broker"` // tcp://host:1883 or ssl://host:8883
+	Topic    string `json:"topic"`
+	ClientID string `json:"clientId"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	QoS      int    `json:"qos"` // 0 or 1
+	Retain   bool   `json:"retain"`
+	Encoding string `json:"encoding"` // "json" (default) or "protobuf"
+}
+
+// MQTT 3.1.1 control packet types
+const (
+	mqttConnect    = 0x10
+	mqttConnack    = 0x20
+	mqttPublish    = 0x30
+	mqttPuback     = 0x40
+	mqttDisconnect = 0xE0
+)
+
+// MQTTSink publishes each notification with a minimal MQTT 3.1.1 client,
+// using a short-lived connection per publish.
+type MQTTSink struct {
+	logger logger.Logger
+	config MQTTConfig
+}
+
+// NewMQTTSink constructor
+func NewMQTTSink(logger logger.Logger, config MQTTConfig) *MQTTSink {
+	if config.ClientID == "" {
+		config.ClientID = "eve-chainkills"
+	}
+	return &MQTTSink{
+		logger: logger,
+		config: config,
+	}
+}
+
+func (ms *MQTTSink) Name() string {
+	return "mqtt"
+}
+
+// Send encodes and publishes the notification
+func (ms *MQTTSink) Send(ctx context.Context, n Notification) error {
+	payload, err := encodeKillEvent(n, ms.config.Encoding)
+	if err != nil {
+		return err
+	}
+	return ms.publish(ctx, payload)
+}
+
+func (ms *MQTTSink) publish(ctx context.Context, payload []byte) error {
+	u, err := url.Parse(ms.config.Broker)
+	if err != nil {
+		return fmt.Errorf("parse mqtt broker: %w", err)
+	}
+	useTLS := u.Scheme == "ssl" || u.Scheme == "tls" || u.Scheme == "mqtts"
+	host := u.Host
+	if u.Port() == "" {
+		port := "1883"
+		if useTLS {
+			port = "8883"
+		}
+		host = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	var conn net.Conn
+	if useTLS {
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: u.Hostname()}}
+		conn, err = tlsDialer.DialContext(ctx, "tcp", host)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", host)
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	// Closing the connection unblocks any read or write when ctx is cancelled
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+	return ms.exchange(conn, payload)
+}
+
+// exchange connects, publishes payload and disconnects over conn
+func (ms *MQTTSink) exchange(conn net.Conn, payload []byte) error {
+	r := bufio.NewReader(conn)
+
+	// CONNECT
+	var flags byte = 0x02 // clean session
+	var connectPayload []byte
+	connectPayload = appendMQTTString(connectPayload, ms.config.ClientID)
+	if ms.config.Username != "" {
+		flags |= 0x80
+		connectPayload = appendMQTTString(connectPayload, ms.config.Username)
+		if ms.config.Password != "" {
+			flags |= 0x40
+			connectPayload = appendMQTTString(connectPayload, ms.config.Password)
+		}
+	}
+	var connect []byte
+	connect = appendMQTTString(connect, "MQTT")
+	connect = append(connect, 4, flags) // protocol level 4 = 3.1.1
+	connect = binary.BigEndian.AppendUint16(connect, 30)
+	connect = append(connect, connectPayload...)
+	if err := writeMQTTPacket(conn, mqttConnect, connect); err != nil {
+		return err
+	}
+
+	header, body, err := readMQTTPacket(r)
+	if err != nil {
+		return fmt.Errorf("waiting for mqtt CONNACK: %w", err)
+	}
+	if header&0xF0 != mqttConnack || len(body) < 2 {
+		return fmt.Errorf("unexpected mqtt packet 0x%02x instead of CONNACK", header)
+	}
+	if body[1] != 0 {
+		return fmt.Errorf("mqtt connection refused, return code %d", body[1])
+	}
+
+	// PUBLISH
+	qos := byte(0)
+	if ms.config.QoS > 0 {
+		qos = 1
+	}
+	publishHeader := byte(mqttPublish) | qos<<1
+	if ms.config.Retain {
+		publishHeader |= 0x01
+	}
+	var publish []byte
+	publish = appendMQTTString(publish, ms.config.Topic)
+	const packetID = 1
+	if qos > 0 {
+		publish = binary.BigEndian.AppendUint16(publish, packetID)
+	}
+	publish = append(publish, payload...)
+	if err := writeMQTTPacket(conn, publishHeader, publish); err != nil {
+		return err
+	}
+
+	if qos > 0 {
+		header, body, err = readMQTTPacket(r)
+		if err != nil {
+			return fmt.Errorf("waiting for mqtt PUBACK: %w", err)
+		}
+		if header&0xF0 != mqttPuback || len(body) < 2 || binary.BigEndian.Uint16(body) != packetID {
+			return fmt.Errorf("unexpected mqtt packet 0x%02x instead of PUBACK", header)
+		}
+	}
+
+	return writeMQTTPacket(conn, mqttDisconnect, nil)
+}
+
+func appendMQTTString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// writeMQTTPacket writes the fixed header, remaining length and body
+func writeMQTTPacket(w io.Writer, header byte, body []byte) error {
+	pkt := []byte{header}
+	length := len(body)
+	for {
+		b := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			b |= 0x80
+		}
+		pkt = append(pkt, b)
+		if length == 0 {
+			break
+		}
+	}
+	pkt = append(pkt, body...)
+	_, err := w.Write(pkt)
+	return err
+}
+
+func readMQTTPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length, multiplier := 0, 1
+	for i := 0; i < 4; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length += int(b&0x7F) * multiplier
+		if b&0x80 == 0 {
+			break
+		}
+		multiplier *= 128
+	}
+	body := make([]byte, length)
+	if _, err = io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header, body, nil
+}
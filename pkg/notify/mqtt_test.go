@@ -0,0 +1,157 @@
+package notify
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+)
+
+func discardLogger() logger.Logger {
+	return logger.Slog(slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+// readMQTTString reads a length-prefixed MQTT string off the front of b
+func readMQTTString(t *testing.T, b []byte) (string, []byte) {
+	t.Helper()
+	if len(b) < 2 || len(b) < 2+int(binary.BigEndian.Uint16(b)) {
+		t.Fatalf("short MQTT string in %x", b)
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	return string(b[2 : 2+n]), b[2+n:]
+}
+
+// mqttBroker plays the broker's side of one publish on conn, answering
+// CONNECT with returnCode, and reports the packets it read
+type mqttBroker struct {
+	returnCode byte
+	packets    []mqttPacket
+	err        error
+}
+
+type mqttPacket struct {
+	header byte
+	body   []byte
+}
+
+func (b *mqttBroker) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		header, body, err := readMQTTPacket(r)
+		if err != nil {
+			if err != io.EOF {
+				b.err = err
+			}
+			return
+		}
+		b.packets = append(b.packets, mqttPacket{header, body})
+		switch header & 0xF0 {
+		case mqttConnect:
+			if err = writeMQTTPacket(conn, mqttConnack, []byte{0, b.returnCode}); err != nil {
+				b.err = err
+				return
+			}
+		case mqttPublish:
+			if qos := header >> 1 & 0x03; qos > 0 {
+				// the packet ID follows the topic
+				topicLen := 2 + int(binary.BigEndian.Uint16(body))
+				if err = writeMQTTPacket(conn, mqttPuback, body[topicLen:topicLen+2]); err != nil {
+					b.err = err
+					return
+				}
+			}
+		case mqttDisconnect:
+			return
+		}
+	}
+}
+
+func TestMQTTExchange(t *testing.T) {
+	ms := NewMQTTSink(discardLogger(), MQTTConfig{Topic: "eve/kills", Username: "user", Password: "secret", QoS: 1, Retain: true})
+	client, server := net.Pipe()
+	broker := &mqttBroker{}
+	done := make(chan struct{})
+	go func() { broker.serve(server); close(done) }()
+
+	_ = client.SetDeadline(time.Now().Add(5 * time.Second))
+	payload := bytes.Repeat([]byte("k"), 300) // a remaining length over 127 takes two bytes
+	if err := ms.exchange(client, payload); err != nil {
+		t.Fatal(err)
+	}
+	client.Close()
+	<-done
+	if broker.err != nil {
+		t.Fatal(broker.err)
+	}
+
+	if len(broker.packets) != 3 {
+		t.Fatalf("broker read %d packets, want CONNECT, PUBLISH and DISCONNECT", len(broker.packets))
+	}
+	connect := broker.packets[0]
+	if connect.header != mqttConnect {
+		t.Fatalf("first packet 0x%02x, want CONNECT", connect.header)
+	}
+	protocol, rest := readMQTTString(t, connect.body)
+	if protocol != "MQTT" || rest[0] != 4 {
+		t.Errorf("protocol %q level %d, want MQTT 4", protocol, rest[0])
+	}
+	if flags := rest[1]; flags != 0x80|0x40|0x02 {
+		t.Errorf("connect flags 0x%02x, want username, password and clean session", flags)
+	}
+	if keepAlive := binary.BigEndian.Uint16(rest[2:]); keepAlive != 30 {
+		t.Errorf("keep alive %d, want 30", keepAlive)
+	}
+	clientID, rest := readMQTTString(t, rest[4:])
+	user, rest := readMQTTString(t, rest)
+	pass, rest := readMQTTString(t, rest)
+	if clientID != "eve-chainkills" || user != "user" || pass != "secret" || len(rest) != 0 {
+		t.Errorf("connect payload %q %q %q %x, want the default client ID and credentials", clientID, user, pass, rest)
+	}
+
+	publish := broker.packets[1]
+	if publish.header != mqttPublish|1<<1|0x01 {
+		t.Errorf("publish header 0x%02x, want QoS 1 and retain", publish.header)
+	}
+	topic, rest := readMQTTString(t, publish.body)
+	if topic != "eve/kills" || binary.BigEndian.Uint16(rest) != 1 || !bytes.Equal(rest[2:], payload) {
+		t.Errorf("publish to %q with %x, want eve/kills, packet ID 1 and the payload", topic, rest[:2])
+	}
+	if broker.packets[2].header != mqttDisconnect {
+		t.Errorf("last packet 0x%02x, want DISCONNECT", broker.packets[2].header)
+	}
+}
+
+func TestMQTTExchangeRefused(t *testing.T) {
+	ms := NewMQTTSink(discardLogger(), MQTTConfig{Topic: "eve/kills"})
+	client, server := net.Pipe()
+	broker := &mqttBroker{returnCode: 5}
+	go broker.serve(server)
+	defer client.Close()
+
+	_ = client.SetDeadline(time.Now().Add(5 * time.Second))
+	err := ms.exchange(client, []byte("{}"))
+	if err == nil || !strings.Contains(err.Error(), "return code 5") {
+		t.Fatalf("exchange = %v, want the connection refused with return code 5", err)
+	}
+}
+
+func TestMQTTPacketLength(t *testing.T) {
+	for _, size := range []int{0, 127, 128, 16383, 16384, 2097152} {
+		var buf bytes.Buffer
+		if err := writeMQTTPacket(&buf, mqttPublish, make([]byte, size)); err != nil {
+			t.Fatal(err)
+		}
+		header, body, err := readMQTTPacket(bufio.NewReader(&buf))
+		if err != nil || header != mqttPublish || len(body) != size {
+			t.Errorf("%d byte body came back as 0x%02x with %d bytes, %v", size, header, len(body), err)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+)
+
+// NATSConfig configures publishing matched kills to a NATS subject
+type NATSConfig struct {
+	URL      string `json:"url"` // nats://host:4222 or tls://host:4222
+	Subject  string `json:"subject"`
+	Token    string `json:"token"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+	Encoding string `json:"encoding"` // "json" (default) or "protobuf"
+}
+
+// NATSSink publishes each notification with the core NATS text protocol.
+// Matched kills are rare enough that a short-lived connection per publish is fine.
+type NATSSink struct {
+	logger logger.Logger
+	config NATSConfig
+}
+
+// NewNATSSink constructor
+func NewNATSSink(logger logger.Logger, config NATSConfig) *NATSSink {
+	return &NATSSink{
+		logger: logger,
+		config: config,
+	}
+}
+
+func (ns *NATSSink) Name() string {
+	return "nats"
+}
+
+// Send encodes and publishes the notification, waiting for the server's PONG
+func (ns *NATSSink) Send(ctx context.Context, n Notification) error {
+	payload, err := encodeKillEvent(n, ns.config.Encoding)
+	if err != nil {
+		return err
+	}
+	return ns.publish(ctx, payload)
+}
+
+func (ns *NATSSink) publish(ctx context.Context, payload []byte) error {
+	u, err := url.Parse(ns.config.URL)
+	if err != nil {
+		return fmt.Errorf("parse nats url: %w", err)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "4222")
+	}
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	// Closing the connection unblocks any read or write when ctx is cancelled
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+	return ns.exchange(conn, u, payload)
+}
+
+// exchange reads the server's greeting, then connects and publishes payload
+// over conn, switching to TLS when u or the server asks for it
+func (ns *NATSSink) exchange(conn net.Conn, u *url.URL, payload []byte) error {
+	r := bufio.NewReader(conn)
+	// The server greets with INFO {...}
+	info, err := r.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("read nats INFO: %w", err)
+	}
+	if !strings.HasPrefix(info, "INFO ") {
+		return fmt.Errorf("unexpected nats greeting: %q", strings.TrimSpace(info))
+	}
+
+	var rw net.Conn = conn
+	if u.Scheme == "tls" || strings.Contains(info, `"tls_required":true`) {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: u.Hostname()})
+		if err = tlsConn.Handshake(); err != nil {
+			return fmt.Errorf("nats tls handshake: %w", err)
+		}
+		rw = tlsConn
+		r = bufio.NewReader(tlsConn)
+	}
+
+	connect := map[string]interface{}{
+		"verbose":  false,
+		"pedantic": false,
+		"name":     "eve-chainkills",
+		"lang":     "go",
+		"protocol": 0,
+	}
+	if ns.config.Token != "" {
+		connect["auth_token"] = ns.config.Token
+	}
+	if ns.config.User != "" {
+		connect["user"] = ns.config.User
+		connect["pass"] = ns.config.Password
+	}
+	if u.User != nil {
+		connect["user"] = u.User.Username()
+		connect["pass"], _ = u.User.Password()
+	}
+	connectJSON, err := json.Marshal(connect)
+	if err != nil {
+		return err
+	}
+
+	var msg []byte
+	msg = fmt.Appendf(msg, "CONNECT %s\r\n", connectJSON)
+	msg = fmt.Appendf(msg, "PUB %s %d\r\n", ns.config.Subject, len(payload))
+	msg = append(msg, payload...)
+	msg = append(msg, "\r\nPING\r\n"...)
+	if _, err = rw.Write(msg); err != nil {
+		return err
+	}
+
+	// PONG confirms the server processed everything before it; -ERR means it didn't
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("waiting for nats PONG: %w", err)
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PONG":
+			return nil
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("nats: %s", line)
+		case line == "PING":
+			if _, err = rw.Write([]byte("PONG\r\n")); err != nil {
+				return err
+			}
+		}
+	}
+}
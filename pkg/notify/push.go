@@ -0,0 +1,235 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/guarzo/eve-chainkills/internal/httpclient"
+	"github.com/guarzo/eve-chainkills/pkg/killmail"
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+	"golang.org/x/exp/slices"
+)
+
+// DefaultCapitalGroupIds are the ESI inventory groups treated as capital ships:
+// Titan, Dreadnought, Carrier, Supercarrier, Capital Industrial Ship, Force Auxiliary, Lancer Dreadnought
+var DefaultCapitalGroupIds = []int{30, 485, 547, 659, 883, 1538, 4594}
+
+// PushConfig configures mobile push notifications for high-priority alerts only
+type PushConfig struct {
+	// Chain kills in these systems are pushed
+	HomeSystemIds []int `json:"homeSystemIds"`
+	// Push any notification whose victim is a capital ship
+	CapitalKills bool `json:"capitalKills"`
+	// Overrides DefaultCapitalGroupIds
+	CapitalGroupIds []int `json:"capitalGroupIds"`
+	// ISKFormat is "short" (default), "full" or "both"
+	ISKFormat string `json:"iskFormat"`
+
+	Ntfy     NtfyConfig     `json:"ntfy"`
+	Pushover PushoverConfig `json:"pushover"`
+}
+
+type NtfyConfig struct {
+	Server string `json:"server"` // defaults to https://ntfy.sh
+	Topic  string `json:"topic"`
+	Token  string `json:"token"`
+}
+
+type PushoverConfig struct {
+	AppToken string `json:"appToken"`
+	UserKey  string `json:"userKey"`
+}
+
+// pushMessage is the provider-neutral content of a push notification
+type pushMessage struct {
+	Title  string
+	Body   string
+	URL    string
+	Urgent bool // home system alerts; capital kills are merely high
+}
+
+// TypeResolver looks up an inventory type's name and group ID
+type TypeResolver interface {
+	TypeInfo(ctx context.Context, typeID int) (string, int, error)
+}
+
+// PushSink sends ntfy and/or Pushover notifications for home-system and capital kills
+type PushSink struct {
+	logger logger.Logger
+	config PushConfig
+	types  TypeResolver
+	// http is nil for the default client
+	http *http.Client
+}
+
+// NewPushSink constructor. types is used to classify hulls on chain alerts,
+// which are not ESI-enriched.
+func NewPushSink(logger logger.Logger, config PushConfig, types TypeResolver) *PushSink {
+	if config.Ntfy.Server == "" {
+		config.Ntfy.Server = "https://ntfy.sh"
+	}
+	if len(config.CapitalGroupIds) == 0 {
+		config.CapitalGroupIds = DefaultCapitalGroupIds
+	}
+	return &PushSink{
+		logger: logger,
+		config: config,
+		types:  types,
+	}
+}
+
+// SetHTTPClient sends through client instead of the default one
+func (ps *PushSink) SetHTTPClient(client *http.Client) {
+	ps.http = client
+}
+
+func (ps *PushSink) Name() string {
+	return "push"
+}
+
+// Send pushes the notification if it matches one of the high-priority rules
+func (ps *PushSink) Send(ctx context.Context, n Notification) error {
+	if n.Quiet {
+		return nil
+	}
+	msg, ok := ps.classify(ctx, n)
+	if !ok {
+		return nil
+	}
+	ps.logger.Printf("Sending push notification for killId %d: %s", n.KillMailID, msg.Title)
+	return ps.push(ctx, msg)
+}
+
+// Push sends a push that isn't about a single kill, e.g. an escalation
+func (ps *PushSink) Push(ctx context.Context, title, body, link string, urgent bool) error {
+	return ps.push(ctx, pushMessage{Title: title, Body: body, URL: link, Urgent: urgent})
+}
+
+// push sends msg to every configured provider
+func (ps *PushSink) push(ctx context.Context, msg pushMessage) error {
+	var errs []string
+	if ps.config.Ntfy.Topic != "" {
+		if err := ps.sendNtfy(ctx, msg); err != nil {
+			errs = append(errs, fmt.Sprintf("ntfy: %v", err))
+		}
+	}
+	if ps.config.Pushover.AppToken != "" && ps.config.Pushover.UserKey != "" {
+		if err := ps.sendPushover(ctx, msg); err != nil {
+			errs = append(errs, fmt.Sprintf("pushover: %v", err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// classify decides whether a notification is high priority and builds its text
+func (ps *PushSink) classify(ctx context.Context, n Notification) (pushMessage, bool) {
+	if len(n.Friendlies) > 0 {
+		return pushMessage{
+			Title:  fmt.Sprintf("Friendlies in danger in %s", n.SystemAlias),
+			Body:   fmt.Sprintf("%s: a ship just died to %d hostiles", strings.Join(n.Friendlies, ", "), n.AttackerCount),
+			URL:    n.ZkillURL(),
+			Urgent: true,
+		}, true
+	}
+	if n.Kind == KindChain && n.Kill != nil && slices.Contains(ps.config.HomeSystemIds, n.Kill.SolarSystemID) {
+		return pushMessage{
+			Title:  fmt.Sprintf("Home system kill in %s", n.SystemAlias),
+			Body:   fmt.Sprintf("A ship just died in %s to %d people", n.SystemAlias, n.AttackerCount),
+			URL:    n.ZkillURL(),
+			Urgent: true,
+		}, true
+	}
+
+	if ps.config.CapitalKills && n.Kill != nil && n.Kill.Victim.ShipTypeID > 0 {
+		shipName, groupID := n.Kill.VictimShipName, n.Kill.VictimShipGroupID
+		if groupID == 0 {
+			// chain alerts skip ESI enrichment, so look the hull up ourselves
+			var err error
+			shipName, groupID, err = ps.types.TypeInfo(ctx, n.Kill.Victim.ShipTypeID)
+			if err != nil {
+				ps.logger.Printf("Error fetching ship group for push: %v", err)
+				return pushMessage{}, false
+			}
+		}
+		if !slices.Contains(ps.config.CapitalGroupIds, groupID) {
+			return pushMessage{}, false
+		}
+
+		what := "Capital kill"
+		if n.Kind == KindCorpKill && !n.IsKill {
+			what = "Capital loss"
+		}
+		where := n.SystemAlias
+		if where == "" {
+			where = fmt.Sprintf("SystemID:%d", n.Kill.SolarSystemID)
+		}
+		return pushMessage{
+			Title: fmt.Sprintf("%s: %s", what, valueOr(shipName, "UnknownShip")),
+			Body: fmt.Sprintf("%s destroyed in %s (%s)",
+				valueOr(shipName, "UnknownShip"), where, killmail.FormatISK(n.Kill.TotalValue, ps.config.ISKFormat)),
+			URL: n.ZkillURL(),
+		}, true
+	}
+	return pushMessage{}, false
+}
+
+func (ps *PushSink) sendNtfy(ctx context.Context, msg pushMessage) error {
+	endpoint := strings.TrimRight(ps.config.Ntfy.Server, "/") + "/" + ps.config.Ntfy.Topic
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(msg.Body))
+	if err != nil {
+		return err
+	}
+	priority := "high"
+	if msg.Urgent {
+		priority = "urgent"
+	}
+	req.Header.Set("Title", msg.Title)
+	req.Header.Set("Priority", priority)
+	req.Header.Set("Click", msg.URL)
+	req.Header.Set("Tags", "skull")
+	if ps.config.Ntfy.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+ps.config.Ntfy.Token)
+	}
+	return ps.do(req)
+}
+
+func (ps *PushSink) sendPushover(ctx context.Context, msg pushMessage) error {
+	form := url.Values{
+		"token":     {ps.config.Pushover.AppToken},
+		"user":      {ps.config.Pushover.UserKey},
+		"title":     {msg.Title},
+		"message":   {msg.Body},
+		"priority":  {"1"}, // high; 2 (emergency) would require retry/expire parameters
+		"url":       {msg.URL},
+		"url_title": {"zKillboard"},
+	}
+	if msg.Urgent {
+		form.Set("sound", "siren")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://api.pushover.net/1/messages.json",
+		strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return ps.do(req)
+}
+
+func (ps *PushSink) do(req *http.Request) error {
+	client := httpclient.Or(ps.http)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("got status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,312 @@
+// Package notify defines the Sink interface and the non-Discord notification
+// destinations (Telegram, generic webhooks, NATS, MQTT, push, Mattermost, Rocket.Chat).
+package notify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/pkg/killmail"
+)
+
+// Kind tells a sink which path produced the alert.
+type Kind string
+
+const (
+	// KindChain is a kill in a mapped chain system with no mapped attackers.
+	KindChain Kind = "chain"
+	// KindCorpKill is a kill or loss involving a tracked corp/alliance/character.
+	KindCorpKill Kind = "corpKill"
+	// KindLocation is a kill at a configured location that matched nothing else.
+	KindLocation Kind = "location"
+	// KindDanger is a kill by non-map pilots where map characters are, that matched nothing else.
+	KindDanger Kind = "danger"
+)
+
+// Notification is the sink-agnostic description of an alert.
+type Notification struct {
+	Kind          Kind
+	KillMailID    int64
+	SystemAlias   string
+	AttackerCount int
+	IsKill        bool
+	// BothSides marks a corp loss with tracked or mapped pilots among the attackers too
+	BothSides bool
+	// Location names the configured location the kill happened at, if any
+	Location string
+	// NewGroups are attacking corps/alliances not seen in the chain recently
+	NewGroups []Group
+	// Reason is the rule that made this kill alert
+	Reason MatchReason
+	// Friendlies names the map characters in the kill's system when none of the attackers are on the map
+	Friendlies []string
+	// RouteHome lists the chain systems from the kill to the home system, both included
+	RouteHome []string
+	// MapURL opens the mapping tool at the kill's system, for chain alerts
+	MapURL string
+	// Updated marks a corp kill re-sent with its settled zKillboard value
+	Updated bool
+	// Partial marks a kill sent before ESI resolved all of its names
+	Partial bool
+	// Quiet drops the "@here" mention and push, e.g. for a chain alert cross-posted after a corp kill
+	Quiet bool
+	// Via names the federated instance that sent this alert; such alerts aren't federated again
+	Via string
+
+	// Kill holds the ESI-enriched kill for corp kills and only the zKill fields for chain alerts
+	Kill *killmail.FlattenedKillMail
+}
+
+// ZkillURL returns the zKillboard link for the notification's kill
+func (n Notification) ZkillURL() string {
+	return fmt.Sprintf("https://zkillboard.com/kill/%d/", n.KillMailID)
+}
+
+// Match rules reported in MatchReason.Rule
+const (
+	RuleVictimTracked   = "victim_tracked"
+	RuleAttackerTracked = "attacker_tracked"
+	RuleMappedCharacter = "mapped_character"
+	RuleMappedVictim    = "mapped_victim"
+	RuleBothSides       = "both_sides"
+	RuleChainSystem     = "chain_system"
+	RuleLocation        = "location"
+	RuleTriglavianSpace = "triglavian_space"
+	RuleFriendlyDanger  = "friendly_danger"
+	RuleFactionWarfare  = "faction_warfare"
+)
+
+// MatchReason says why a kill alerted, for people and for programs
+type MatchReason struct {
+	// Rule is one of the Rule* values, or whatever a custom filter reports
+	Rule string `json:"rule"`
+	// ID is the tracked corp/alliance, mapped character, chain system or location that matched
+	ID int64 `json:"id,omitempty"`
+	// Text is the human-readable reason, e.g. "attacker corp 98012345 in tracked list"
+	Text string `json:"text"`
+}
+
+// Group is an attacking corporation or alliance
+type Group struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name,omitempty"`
+	IsAlliance bool   `json:"is_alliance"`
+}
+
+// ZkillURL returns the group's zKillboard stats page
+func (g Group) ZkillURL() string {
+	if g.IsAlliance {
+		return fmt.Sprintf("https://zkillboard.com/alliance/%d/", g.ID)
+	}
+	return fmt.Sprintf("https://zkillboard.com/corporation/%d/", g.ID)
+}
+
+// ChainText is the plain-text chain alert, prefixed with "NEW GROUP IN CHAIN"
+// and the groups' zKillboard links when any attackers are new to the chain
+func (n Notification) ChainText() string {
+	text := fmt.Sprintf("A ship just died %s to %s, zkill link: %s", n.Where(), n.Attackers(), n.ZkillURL())
+	if n.Kill != nil && n.Kill.ThreatSummary != "" {
+		text += "\nFinal blow corp: " + n.Kill.ThreatSummary
+	}
+	if route := n.RouteText(); route != "" {
+		text += "\n" + route
+	}
+	if n.MapURL != "" {
+		text += "\nMap: " + n.MapURL
+	}
+	if len(n.Friendlies) > 0 {
+		text = n.DangerText() + ". " + text
+	}
+	if len(n.NewGroups) == 0 {
+		return text
+	}
+	groups := make([]string, len(n.NewGroups))
+	for i, g := range n.NewGroups {
+		groups[i] = fmt.Sprintf("%s (%s)", valueOr(g.Name, fmt.Sprint(g.ID)), g.ZkillURL())
+	}
+	return fmt.Sprintf("NEW GROUP IN CHAIN: %s. %s", strings.Join(groups, ", "), text)
+}
+
+// KillTime returns when the ship died, or the zero time if unknown
+func (n Notification) KillTime() time.Time {
+	if n.Kill == nil {
+		return time.Time{}
+	}
+	return n.Kill.KillMailTime
+}
+
+// plainKillTime appends the kill time as plain text to an alert, e.g. "\nKilled 2024-05-01 18:04 EVE"
+func (n Notification) plainKillTime(text string, loc *time.Location) string {
+	if t := n.KillTime(); !t.IsZero() {
+		text += "\nKilled " + killmail.FormatKillTime(t, loc)
+	}
+	return text
+}
+
+// SidesText is "TRACKED PILOTS ON BOTH SIDES" for kills with us on both sides, or ""
+func (n Notification) SidesText() string {
+	if !n.BothSides {
+		return ""
+	}
+	return "TRACKED PILOTS ON BOTH SIDES"
+}
+
+// mention is "@here " unless the notification is quiet
+func (n Notification) mention() string {
+	if n.Quiet {
+		return ""
+	}
+	return "@here "
+}
+
+// DangerText names the friendlies in the kill's system, e.g.
+// "FRIENDLIES IN DANGER: Alice, Bob", or "" without any
+func (n Notification) DangerText() string {
+	if len(n.Friendlies) == 0 {
+		return ""
+	}
+	return "FRIENDLIES IN DANGER: " + strings.Join(n.Friendlies, ", ")
+}
+
+// RouteText describes the way home, e.g. "Route home: Home-2 → C3a → Home (2 jumps)",
+// or "" without a route
+func (n Notification) RouteText() string {
+	switch len(n.RouteHome) {
+	case 0:
+		return ""
+	case 1:
+		return "In the home system"
+	case 2:
+		return fmt.Sprintf("Route home: %s (1 jump)", strings.Join(n.RouteHome, " → "))
+	}
+	return fmt.Sprintf("Route home: %s (%d jumps)", strings.Join(n.RouteHome, " → "), len(n.RouteHome)-1)
+}
+
+// Where describes where the kill happened, e.g. "in J123456" or "at Home static (J123456)"
+func (n Notification) Where() string {
+	if n.Location != "" {
+		return fmt.Sprintf("at %s (%s)", n.Location, n.SystemAlias)
+	}
+	return "in " + n.SystemAlias
+}
+
+// Attackers describes who made the kill, e.g. "6 people",
+// "6 people (3x Loki, 2x Sabre, 1x Devoter; mostly [TICKR] Some Alliance (5/6))"
+// or, with NPCs among them, "47 people (1 player, 46 NPCs; ...)"
+func (n Notification) Attackers() string {
+	text := fmt.Sprintf("%d people", n.AttackerCount)
+	if n.Kill == nil {
+		return text
+	}
+	var details []string
+	if players, npcs := n.Kill.PlayersAndNPCs(); npcs > 0 {
+		details = append(details, fmt.Sprintf("%s, %s", plural(players, "player"), plural(npcs, "NPC")))
+	}
+	if summary := n.Kill.AttackerSummary(); summary != "" {
+		details = append(details, summary)
+	}
+	if len(details) > 0 {
+		text += " (" + strings.Join(details, "; ") + ")"
+	}
+	return text
+}
+
+// plural renders a count with its noun, e.g. "1 player" or "46 NPCs"
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// KillEvent is the machine-readable form of a notification used by the
+// generic webhook and NATS/MQTT sinks
+type KillEvent struct {
+	Event         Kind                        `json:"event"`
+	IsKill        bool                        `json:"is_kill"`
+	BothSides     bool                        `json:"both_sides,omitempty"`
+	SystemAlias   string                      `json:"system_alias"`
+	Location      string                      `json:"location,omitempty"`
+	NewGroups     []Group                     `json:"new_groups,omitempty"`
+	MatchReason   *MatchReason                `json:"match_reason,omitempty"`
+	RouteHome     []string                    `json:"route_home,omitempty"`
+	MapURL        string                      `json:"map_url,omitempty"`
+	Friendlies    []string                    `json:"friendlies_in_danger,omitempty"`
+	AttackerCount int                         `json:"attacker_count"`
+	ZkillURL      string                      `json:"zkill_url"`
+	SentAt        time.Time                   `json:"sent_at"`
+	Via           string                      `json:"via,omitempty"`
+	KillMail      *killmail.FlattenedKillMail `json:"killmail,omitempty"`
+}
+
+// NewKillEvent converts a notification to its machine-readable form
+func NewKillEvent(n Notification) KillEvent {
+	return KillEvent{
+		Event:         n.Kind,
+		IsKill:        n.IsKill,
+		BothSides:     n.BothSides,
+		SystemAlias:   n.SystemAlias,
+		Location:      n.Location,
+		NewGroups:     n.NewGroups,
+		MatchReason:   n.matchReason(),
+		RouteHome:     n.RouteHome,
+		MapURL:        n.MapURL,
+		Friendlies:    n.Friendlies,
+		AttackerCount: n.AttackerCount,
+		ZkillURL:      n.ZkillURL(),
+		SentAt:        time.Now().UTC(),
+		Via:           n.Via,
+		KillMail:      n.Kill,
+	}
+}
+
+// matchReason returns the reason for KillEvent, or nil when the filter gave none
+func (n Notification) matchReason() *MatchReason {
+	if n.Reason.Rule == "" && n.Reason.Text == "" {
+		return nil
+	}
+	r := n.Reason
+	return &r
+}
+
+// Sink is a notification destination. Implement it to plug your own delivery
+// into the checker.
+type Sink interface {
+	Name() string
+	Send(ctx context.Context, n Notification) error
+}
+
+// EditableSink is implemented by sinks whose corp-kill messages can be edited
+// after sending, e.g. once the kill's value settles
+type EditableSink interface {
+	// SendRef sends like Send and returns a reference to the message, or "" when it can't be edited
+	SendRef(ctx context.Context, n Notification) (string, error)
+	// Edit replaces the message SendRef returned ref for
+	Edit(ctx context.Context, ref string, n Notification) error
+}
+
+// LinkSink is implemented by sinks that can link to the messages they send,
+// e.g. so operators can jump from the logs to the message
+type LinkSink interface {
+	// SendLink sends like Send and returns a link to the message, or "" when there is none
+	SendLink(ctx context.Context, n Notification) (string, error)
+	// Link returns the link to a recent message SendRef or SendAckable returned ref for, or ""
+	Link(ref string) string
+}
+
+// AckSink is implemented by sinks that can tell who acknowledged a chain
+// alert, e.g. by reacting to it
+type AckSink interface {
+	// SendAckable sends like Send and returns a reference to watch, or "" when it can't be watched
+	SendAckable(ctx context.Context, n Notification) (string, error)
+	// Acks lists the people who reacted to the message with emoji
+	Acks(ctx context.Context, ref, emoji string) ([]string, error)
+}
+
+// BatchSink is implemented by sinks that can post several aggregated kills
+// from one system as a single message; other sinks get one Send per kill
+type BatchSink interface {
+	SendBatch(ctx context.Context, ns []Notification) error
+}
@@ -0,0 +1,231 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/internal/httpclient"
+	"github.com/guarzo/eve-chainkills/pkg/killmail"
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+)
+
+// TelegramConfig holds the bot credentials and the chats to notify
+type TelegramConfig struct {
+	BotToken string   `json:"botToken"`
+	ChatIds  []string `json:"chatIds"` // numeric chat IDs or @channelnames
+	// ISKFormat is "short" (default), "full" or "both"
+	ISKFormat string `json:"iskFormat"`
+	// Format is rich (default), compact, plain or json
+	Format Format `json:"format"`
+
+	// Images defaults to the top-level images setting
+	Images killmail.Images `json:"-"`
+	// Timezone is the top-level displayTimezone; kill times are always shown in EVE time too
+	Timezone *time.Location `json:"-"`
+}
+
+// TelegramSink posts kill and chain alerts through the Telegram Bot API
+type TelegramSink struct {
+	logger logger.Logger
+	config TelegramConfig
+	// http is nil for the default client
+	http *http.Client
+}
+
+// NewTelegramSink constructor
+func NewTelegramSink(logger logger.Logger, config TelegramConfig) *TelegramSink {
+	return &TelegramSink{
+		logger: logger,
+		config: config,
+	}
+}
+
+// SetHTTPClient sends through client instead of the default one
+func (ts *TelegramSink) SetHTTPClient(client *http.Client) {
+	ts.http = client
+}
+
+func (ts *TelegramSink) Name() string {
+	return "telegram"
+}
+
+// Send delivers the notification to every configured chat
+func (ts *TelegramSink) Send(ctx context.Context, n Notification) error {
+	var lastErr error
+	for _, chatID := range ts.config.ChatIds {
+		var err error
+		if text := formatText(ts.config.Format, n, ts.config.ISKFormat, ts.config.Timezone); text != "" {
+			err = ts.sendMessage(ctx, chatID, escapeTelegramText(ts.config.Format, text), n.Quiet)
+		} else if n.Kind == KindCorpKill && n.Kill != nil {
+			err = ts.sendPhoto(ctx, chatID, ts.config.Images.Ship(n.Kill.Victim.ShipTypeID), formatTelegramKill(n, ts.config.ISKFormat, ts.config.Timezone))
+		} else {
+			err = ts.sendMessage(ctx, chatID, formatTelegramChain(n, ts.config.Timezone), n.Quiet)
+		}
+		if err != nil {
+			ts.logger.Printf("Error sending telegram message to %s: %v", chatID, err)
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+func (ts *TelegramSink) sendMessage(ctx context.Context, chatID, text string, silent bool) error {
+	return ts.call(ctx, "sendMessage", map[string]interface{}{
+		"chat_id":              chatID,
+		"text":                 text,
+		"parse_mode":           "MarkdownV2",
+		"disable_notification": silent,
+	})
+}
+
+func (ts *TelegramSink) sendPhoto(ctx context.Context, chatID, photoURL, caption string) error {
+	return ts.call(ctx, "sendPhoto", map[string]interface{}{
+		"chat_id":    chatID,
+		"photo":      photoURL,
+		"caption":    caption,
+		"parse_mode": "MarkdownV2",
+	})
+}
+
+// call POSTs a JSON payload to the given Bot API method
+func (ts *TelegramSink) call(ctx context.Context, method string, payload map[string]interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("https://api.telegram.org/bot%s/%s", ts.config.BotToken, method)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := httpclient.Or(ts.http)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var apiErr struct {
+			Description string `json:"description"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&apiErr)
+		return fmt.Errorf("telegram %s got status %d: %s", method, resp.StatusCode, apiErr.Description)
+	}
+	return nil
+}
+
+// formatTelegramChain renders a chain alert in MarkdownV2
+func formatTelegramChain(n Notification, loc *time.Location) string {
+	text := fmt.Sprintf("*A ship just died %s* to %s\n[zkill](%s)",
+		escapeTelegramMarkdown(n.Where()), escapeTelegramMarkdown(n.Attackers()), escapeTelegramURL(n.ZkillURL()))
+	if n.MapURL != "" {
+		text += fmt.Sprintf(" · [map](%s)", escapeTelegramURL(n.MapURL))
+	}
+	if n.Kill != nil && n.Kill.ThreatSummary != "" {
+		text += "\nFinal blow corp: " + escapeTelegramMarkdown(n.Kill.ThreatSummary)
+	}
+	if route := n.RouteText(); route != "" {
+		text += "\n" + escapeTelegramMarkdown(route)
+	}
+	if t := n.KillTime(); !t.IsZero() {
+		text += "\nKilled " + escapeTelegramMarkdown(killmail.FormatKillTime(t, loc))
+	}
+	if n.Reason.Text != "" {
+		text += "\n_Matched: " + escapeTelegramMarkdown(n.Reason.Text) + "_"
+	}
+	if danger := n.DangerText(); danger != "" {
+		text = "*" + escapeTelegramMarkdown(danger) + "*\n" + text
+	}
+	for _, g := range n.NewGroups {
+		text = fmt.Sprintf("*NEW GROUP IN CHAIN*: [%s](%s)\n",
+			escapeTelegramMarkdown(valueOr(g.Name, fmt.Sprint(g.ID))), escapeTelegramURL(g.ZkillURL())) + text
+	}
+	return text
+}
+
+// formatTelegramKill renders a corp kill/loss caption in MarkdownV2
+func formatTelegramKill(n Notification, iskFormat string, loc *time.Location) string {
+	fkm := n.Kill
+
+	header := "Loss"
+	if n.IsKill {
+		header = "Kill"
+	}
+	if fkm.Awox {
+		header = "Cowardly Awox"
+	}
+	if n.BothSides {
+		header = "Both sides"
+	}
+
+	systemName := fkm.SystemName
+	if systemName == "" {
+		systemName = fmt.Sprintf("SystemID:%d", fkm.SolarSystemID)
+	}
+
+	var sb strings.Builder
+	if danger := n.DangerText(); danger != "" {
+		fmt.Fprintf(&sb, "*%s*\n", escapeTelegramMarkdown(danger))
+	}
+	fmt.Fprintf(&sb, "*%s: %s destroyed in %s*\n",
+		escapeTelegramMarkdown(header),
+		escapeTelegramMarkdown(valueOr(fkm.VictimShipName, "UnknownShip")),
+		escapeTelegramMarkdown(systemName))
+	fmt.Fprintf(&sb, "Victim: %s\n", escapeTelegramMarkdown(valueOr(fkm.VictimCharacterName, "UnknownVictim")))
+	fmt.Fprintf(&sb, "Final blow: %s \\(%s\\)\n",
+		escapeTelegramMarkdown(valueOr(fkm.FinalAttackerName, "UnknownAttacker")),
+		escapeTelegramMarkdown(valueOr(fkm.FinalAttackerShipName, "UnknownShip")))
+	fmt.Fprintf(&sb, "Attackers: %d\n", fkm.AttackerCount())
+	if !fkm.KillMailTime.IsZero() {
+		fmt.Fprintf(&sb, "Time: %s\n", escapeTelegramMarkdown(killmail.FormatKillTime(fkm.KillMailTime, loc)))
+	}
+	fmt.Fprintf(&sb, "Value: %s\n", escapeTelegramMarkdown(killmail.FormatISK(fkm.TotalValue, iskFormat)))
+	if n.Reason.Text != "" {
+		fmt.Fprintf(&sb, "_Matched: %s_\n", escapeTelegramMarkdown(n.Reason.Text))
+	}
+	fmt.Fprintf(&sb, "[zkill](%s)", escapeTelegramURL(n.ZkillURL()))
+	return sb.String()
+}
+
+// escapeTelegramMarkdown escapes every character reserved by MarkdownV2
+func escapeTelegramMarkdown(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune("_*[]()~`>#+-=|{}.!\\", r) {
+			sb.WriteRune('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// escapeTelegramText escapes formatted text for MarkdownV2; inside a code
+// block only backslashes and backticks are reserved
+func escapeTelegramText(f Format, text string) string {
+	if f != FormatJSON {
+		return escapeTelegramMarkdown(text)
+	}
+	body := strings.TrimSuffix(strings.TrimPrefix(text, "```json\n"), "\n```")
+	return "```json\n" + strings.NewReplacer(`\`, `\\`, "`", "\\`").Replace(body) + "\n```"
+}
+
+// escapeTelegramURL escapes the characters MarkdownV2 reserves inside (...) link targets
+func escapeTelegramURL(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `)`, `\)`).Replace(s)
+}
+
+func valueOr(s, fallback string) string {
+	if s == "" {
+		return fallback
+	}
+	return s
+}
@@ -0,0 +1,117 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/guarzo/eve-chainkills/internal/httpclient"
+	"github.com/guarzo/eve-chainkills/internal/tracing"
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+)
+
+// WebhookSignatureHeader carries the hex HMAC-SHA256 of the request body
+const WebhookSignatureHeader = "X-Chainkills-Signature"
+
+// KillIDHeader carries the killmail ID, to find the request's kill in the logs
+const KillIDHeader = "X-Chainkills-Kill-Id"
+
+// setCorrelation marks an outbound request with its kill and, when the kill
+// is traced, a traceparent header making the receiver part of the trace
+func setCorrelation(ctx context.Context, req *http.Request, killID int64) {
+	if killID != 0 {
+		req.Header.Set(KillIDHeader, strconv.FormatInt(killID, 10))
+	}
+	if tp := tracing.FromContext(ctx).Traceparent(); tp != "" {
+		req.Header.Set("traceparent", tp)
+	}
+}
+
+// WebhookConfig describes one generic JSON webhook destination
+type WebhookConfig struct {
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	Secret  string            `json:"secret"` // HMAC key; no signature header when empty
+	// Format is json (default), or compact or plain to send {"text": ...} instead
+	Format Format `json:"format"`
+	// ISKFormat is "short" (default), "full" or "both", for the compact and plain formats
+	ISKFormat string `json:"iskFormat"`
+}
+
+// WebhookSink POSTs every notification as JSON to an arbitrary URL
+type WebhookSink struct {
+	logger logger.Logger
+	config WebhookConfig
+	// http is nil for the default client
+	http *http.Client
+}
+
+// NewWebhookSink constructor
+func NewWebhookSink(logger logger.Logger, config WebhookConfig) *WebhookSink {
+	return &WebhookSink{
+		logger: logger,
+		config: config,
+	}
+}
+
+// SetHTTPClient sends through client instead of the default one
+func (ws *WebhookSink) SetHTTPClient(client *http.Client) {
+	ws.http = client
+}
+
+func (ws *WebhookSink) Name() string {
+	return "webhook"
+}
+
+// Send marshals the notification, signs it and POSTs it
+func (ws *WebhookSink) Send(ctx context.Context, n Notification) error {
+	var body interface{} = NewKillEvent(n)
+	switch ws.config.Format {
+	case FormatCompact:
+		body = map[string]string{"text": n.CompactText(ws.config.ISKFormat)}
+	case FormatPlain:
+		body = map[string]string{"text": n.PlainText(ws.config.ISKFormat, nil)}
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ws.config.URL, bytes.NewBuffer(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range ws.config.Headers {
+		req.Header.Set(k, v)
+	}
+	setCorrelation(ctx, req, n.KillMailID)
+	if ws.config.Secret != "" {
+		req.Header.Set(WebhookSignatureHeader, "sha256="+signWebhookPayload(ws.config.Secret, payload))
+	}
+
+	client := httpclient.Or(ws.http)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook %s got status %d", ws.config.URL, resp.StatusCode)
+	}
+	return nil
+}
+
+// signWebhookPayload returns the hex HMAC-SHA256 of payload keyed by secret
+func signWebhookPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
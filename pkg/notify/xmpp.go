@@ -0,0 +1,352 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+	"golang.org/x/exp/slices"
+)
+
+// XMPPConfig configures Jabber broadcasts to a multi-user chat room and/or
+// individual JIDs
+type XMPPConfig struct {
+	// JID signs in, e.g. pingbot@jabber.example.com
+	JID      string `json:"jid"`
+	Password string `json:"password"`
+	// Server is host:port; defaults to the JID's domain on 5222, or 5223 with DirectTLS
+	Server string `json:"server"`
+	// DirectTLS connects with TLS from the start instead of STARTTLS
+	DirectTLS bool `json:"directTls"`
+	// Room is a MUC room JID, e.g. pings@conference.jabber.example.com
+	Room string `json:"room"`
+	// Nick in the room (default "chainkills")
+	Nick string `json:"nick"`
+	// Recipients get each alert as a direct message
+	Recipients []string `json:"recipients"`
+	// ISKFormat is "short" (default), "full" or "both"
+	ISKFormat string `json:"iskFormat"`
+	// Format is compact (default), plain or json
+	Format Format `json:"format"`
+}
+
+// XML namespaces of the parts of XMPP the sink speaks
+const (
+	xmppNSClient  = "jabber:client"
+	xmppNSStream  = "http://etherx.jabber.org/streams"
+	xmppNSTLS     = "urn:ietf:params:xml:ns:xmpp-tls"
+	xmppNSSASL    = "urn:ietf:params:xml:ns:xmpp-sasl"
+	xmppNSBind    = "urn:ietf:params:xml:ns:xmpp-bind"
+	xmppNSSession = "urn:ietf:params:xml:ns:xmpp-session"
+	xmppNSMUC     = "http://jabber.org/protocol/muc"
+)
+
+// XMPPSink sends each notification as text with a minimal XMPP client,
+// using a short-lived connection per send like the MQTT sink.
+type XMPPSink struct {
+	logger logger.Logger
+	config XMPPConfig
+}
+
+// NewXMPPSink constructor
+func NewXMPPSink(logger logger.Logger, config XMPPConfig) *XMPPSink {
+	if config.Nick == "" {
+		config.Nick = "chainkills"
+	}
+	if config.Format == "" {
+		config.Format = FormatCompact
+	}
+	return &XMPPSink{
+		logger: logger,
+		config: config,
+	}
+}
+
+func (xs *XMPPSink) Name() string {
+	return "xmpp"
+}
+
+// Send signs in, joins the room if there is one and sends the alert text
+func (xs *XMPPSink) Send(ctx context.Context, n Notification) error {
+	var text string
+	switch xs.config.Format {
+	case FormatPlain:
+		text = n.PlainText(xs.config.ISKFormat, nil)
+	case FormatJSON:
+		text = n.JSONText(false)
+	default:
+		text = n.CompactText(xs.config.ISKFormat)
+	}
+
+	xc, err := xs.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer xc.close()
+
+	if xs.config.Room != "" {
+		if err := xc.joinRoom(xs.config.Room, xs.config.Nick); err != nil {
+			return err
+		}
+		if err := xc.message(xs.config.Room, "groupchat", text); err != nil {
+			return err
+		}
+	}
+	for _, to := range xs.config.Recipients {
+		if err := xc.message(to, "chat", text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// xmppConn is a signed-in client stream
+type xmppConn struct {
+	conn   net.Conn
+	dec    *xml.Decoder
+	domain string
+	stop   func() bool
+}
+
+// xmppFeatures is the part of <stream:features> the sink uses
+type xmppFeatures struct {
+	StartTLS   *struct{} `xml:"urn:ietf:params:xml:ns:xmpp-tls starttls"`
+	Mechanisms []string  `xml:"urn:ietf:params:xml:ns:xmpp-sasl mechanisms>mechanism"`
+	Bind       *struct{} `xml:"urn:ietf:params:xml:ns:xmpp-bind bind"`
+	Session    *struct {
+		Optional *struct{} `xml:"optional"`
+	} `xml:"urn:ietf:params:xml:ns:xmpp-session session"`
+}
+
+// xmppStanza is any top-level element the sink waits for
+type xmppStanza struct {
+	XMLName xml.Name
+	Type    string `xml:"type,attr"`
+	ID      string `xml:"id,attr"`
+	From    string `xml:"from,attr"`
+}
+
+func (xs *XMPPSink) connect(ctx context.Context) (*xmppConn, error) {
+	user, domain, ok := strings.Cut(xs.config.JID, "@")
+	if !ok || user == "" || domain == "" {
+		return nil, fmt.Errorf("xmpp jid %q is not user@domain", xs.config.JID)
+	}
+	domain, _, _ = strings.Cut(domain, "/")
+	addr := xs.config.Server
+	if addr == "" {
+		port := "5222"
+		if xs.config.DirectTLS {
+			port = "5223"
+		}
+		addr = net.JoinHostPort(domain, port)
+	}
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	var (
+		conn net.Conn
+		err  error
+	)
+	if xs.config.DirectTLS {
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: domain}}
+		conn, err = tlsDialer.DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	xc := &xmppConn{conn: conn, domain: domain}
+	// Closing the connection unblocks any read or write when ctx is cancelled
+	xc.stop = context.AfterFunc(ctx, func() { conn.Close() })
+	_ = conn.SetDeadline(time.Now().Add(20 * time.Second))
+
+	if err := xc.signIn(user, xs.config.Password, xs.config.DirectTLS); err != nil {
+		xc.stop()
+		conn.Close()
+		return nil, err
+	}
+	return xc, nil
+}
+
+// signIn secures the stream, authenticates with SASL PLAIN and binds a resource
+func (xc *xmppConn) signIn(user, password string, secure bool) error {
+	features, err := xc.open()
+	if err != nil {
+		return err
+	}
+	if !secure {
+		if features.StartTLS == nil {
+			// never send the password in the clear
+			return errors.New("xmpp server does not offer STARTTLS")
+		}
+		if err := xc.write("<starttls xmlns='%s'/>", xmppNSTLS); err != nil {
+			return err
+		}
+		if st, err := xc.next(); err != nil {
+			return err
+		} else if st.XMLName.Local != "proceed" {
+			return fmt.Errorf("xmpp STARTTLS refused: %s", st.XMLName.Local)
+		}
+		tlsConn := tls.Client(xc.conn, &tls.Config{ServerName: xc.domain})
+		if err := tlsConn.Handshake(); err != nil {
+			return err
+		}
+		xc.conn = tlsConn
+		if features, err = xc.open(); err != nil {
+			return err
+		}
+	}
+
+	if !slices.Contains(features.Mechanisms, "PLAIN") {
+		return fmt.Errorf("xmpp server does not offer SASL PLAIN, only %s", strings.Join(features.Mechanisms, ", "))
+	}
+	auth := base64.StdEncoding.EncodeToString([]byte("\x00" + user + "\x00" + password))
+	if err := xc.write("<auth xmlns='%s' mechanism='PLAIN'>%s</auth>", xmppNSSASL, auth); err != nil {
+		return err
+	}
+	if st, err := xc.next(); err != nil {
+		return err
+	} else if st.XMLName.Local != "success" {
+		return fmt.Errorf("xmpp sign-in as %s@%s failed", user, xc.domain)
+	}
+
+	if features, err = xc.open(); err != nil {
+		return err
+	}
+	if features.Bind == nil {
+		return errors.New("xmpp server does not offer resource binding")
+	}
+	if err := xc.write("<iq type='set' id='bind'><bind xmlns='%s'><resource>chainkills</resource></bind></iq>", xmppNSBind); err != nil {
+		return err
+	}
+	if err := xc.result("bind"); err != nil {
+		return err
+	}
+	if features.Session != nil && features.Session.Optional == nil {
+		// older servers want a session before any stanza
+		if err := xc.write("<iq type='set' id='session'><session xmlns='%s'/></iq>", xmppNSSession); err != nil {
+			return err
+		}
+		return xc.result("session")
+	}
+	return nil
+}
+
+// open starts a new stream and reads the server's features
+func (xc *xmppConn) open() (xmppFeatures, error) {
+	var features xmppFeatures
+	err := xc.write("<?xml version='1.0'?><stream:stream to='%s' xmlns='%s' xmlns:stream='%s' version='1.0'>",
+		xmppEscape(xc.domain), xmppNSClient, xmppNSStream)
+	if err != nil {
+		return features, err
+	}
+	xc.dec = xml.NewDecoder(xc.conn)
+	for {
+		tok, err := xc.dec.Token()
+		if err != nil {
+			return features, err
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok || se.Name.Space != xmppNSStream {
+			continue
+		}
+		switch se.Name.Local {
+		case "features":
+			return features, xc.dec.DecodeElement(&features, &se)
+		case "error":
+			return features, errors.New("xmpp stream error")
+		}
+	}
+}
+
+// next reads the next top-level element of the stream
+func (xc *xmppConn) next() (xmppStanza, error) {
+	var st xmppStanza
+	for {
+		tok, err := xc.dec.Token()
+		if err != nil {
+			return st, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Space == xmppNSStream && t.Name.Local == "error" {
+				_ = xc.dec.Skip()
+				return st, errors.New("xmpp stream error")
+			}
+			return st, xc.dec.DecodeElement(&st, &t)
+		case xml.EndElement:
+			return st, errors.New("xmpp server closed the stream")
+		}
+	}
+}
+
+// result waits for the reply to the iq with id
+func (xc *xmppConn) result(id string) error {
+	for {
+		st, err := xc.next()
+		if err != nil {
+			return err
+		}
+		if st.XMLName.Local != "iq" || st.ID != id {
+			continue
+		}
+		if st.Type != "result" {
+			return fmt.Errorf("xmpp %s failed", id)
+		}
+		return nil
+	}
+}
+
+// joinRoom enters a MUC room without its history and waits to be let in
+func (xc *xmppConn) joinRoom(room, nick string) error {
+	me := room + "/" + nick
+	err := xc.write("<presence to='%s'><x xmlns='%s'><history maxstanzas='0'/></x></presence>", xmppEscape(me), xmppNSMUC)
+	if err != nil {
+		return err
+	}
+	for {
+		st, err := xc.next()
+		if err != nil {
+			return fmt.Errorf("joining xmpp room %s: %w", room, err)
+		}
+		if st.XMLName.Local != "presence" || !strings.EqualFold(st.From, me) {
+			continue
+		}
+		if st.Type == "error" {
+			return fmt.Errorf("xmpp room %s refused %s", room, nick)
+		}
+		return nil
+	}
+}
+
+// message sends a message stanza
+func (xc *xmppConn) message(to, kind, text string) error {
+	return xc.write("<message to='%s' type='%s'><body>%s</body></message>", xmppEscape(to), kind, xmppEscape(text))
+}
+
+// close ends the stream and the connection
+func (xc *xmppConn) close() {
+	xc.stop()
+	_ = xc.write("</stream:stream>")
+	xc.conn.Close()
+}
+
+func (xc *xmppConn) write(format string, args ...interface{}) error {
+	_, err := fmt.Fprintf(xc.conn, format, args...)
+	return err
+}
+
+// xmppEscape escapes text for an XML attribute or element
+func xmppEscape(s string) string {
+	var b bytes.Buffer
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
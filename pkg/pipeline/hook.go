@@ -0,0 +1,107 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+	"github.com/guarzo/eve-chainkills/pkg/notify"
+)
+
+// StageHook is the name of each external hook stage
+const StageHook = "hook"
+
+// HookSuppressExitCode is the exit code a hook uses to suppress an alert
+const HookSuppressExitCode = 1
+
+// HookConfig runs an external command for every matched kill.
+//
+// The command receives the notify.KillEvent as JSON on stdin. Exiting with 0
+// lets the alert through, exiting with 1 suppresses it, and any other exit
+// code (or a timeout) is an error handled according to FailOpen. On exit 0 the
+// command may print a HookResponse as JSON to change routing.
+type HookConfig struct {
+	Command        string   `json:"command"`
+	Args           []string `json:"args"`
+	TimeoutSeconds int      `json:"timeoutSeconds"` // default 5
+	// FailOpen delivers the alert anyway when the hook errors; otherwise it is dropped
+	FailOpen bool `json:"failOpen"`
+}
+
+// HookResponse is the optional JSON a hook prints on stdout
+type HookResponse struct {
+	Suppress bool `json:"suppress"`
+	// Sinks limits delivery to these sink names
+	Sinks []string `json:"sinks"`
+	// SkipSinks removes these sink names from delivery
+	SkipSinks []string `json:"skipSinks"`
+}
+
+// Hook builds a stage that pipes matched kills through an external command
+func Hook(logger logger.Logger, cfg HookConfig) Stage {
+	timeout := time.Duration(cfg.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+
+	return NewStage(StageHook, func(ctx context.Context, ev *Event) (bool, error) {
+		resp, suppress, err := runHook(ctx, cfg, timeout, ev.Notification)
+		if err != nil {
+			if cfg.FailOpen {
+				logger.Printf("Hook %s failed for killId %d, delivering anyway: %v", cfg.Command, ev.Zkill.KillmailID, err)
+				return true, nil
+			}
+			return false, fmt.Errorf("hook %s: %w", cfg.Command, err)
+		}
+		if suppress || resp.Suppress {
+			logger.Printf("Hook %s suppressed killId %d", cfg.Command, ev.Zkill.KillmailID)
+			return false, nil
+		}
+		if len(resp.Sinks) > 0 {
+			ev.Route = resp.Sinks
+		}
+		ev.SkipSinks = append(ev.SkipSinks, resp.SkipSinks...)
+		return true, nil
+	})
+}
+
+// runHook executes the command and interprets its exit code and stdout
+func runHook(ctx context.Context, cfg HookConfig, timeout time.Duration, n notify.Notification) (HookResponse, bool, error) {
+	var resp HookResponse
+	input, err := json.Marshal(notify.NewKillEvent(n))
+	if err != nil {
+		return resp, false, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, cfg.Command, cfg.Args...)
+	cmd.Stdin = bytes.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == HookSuppressExitCode:
+		return resp, true, nil
+	default:
+		return resp, false, fmt.Errorf("%w (stderr: %s)", err, strings.TrimSpace(stderr.String()))
+	}
+
+	if out := bytes.TrimSpace(stdout.Bytes()); len(out) > 0 {
+		if err = json.Unmarshal(out, &resp); err != nil {
+			return resp, false, fmt.Errorf("decode hook output: %w", err)
+		}
+	}
+	return resp, false, nil
+}
@@ -0,0 +1,114 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/internal/tracing"
+	"github.com/guarzo/eve-chainkills/pkg/logger"
+)
+
+// Logging logs each stage's outcome and duration at debug level
+func Logging(logger logger.Logger) Middleware {
+	return func(next Stage) Stage {
+		return NewStage(next.Name(), func(ctx context.Context, ev *Event) (bool, error) {
+			start := time.Now()
+			cont, err := next.Process(ctx, ev)
+			logger.Debugf("[pipeline] killId=%d stage=%s continue=%t err=%v took=%s",
+				ev.Zkill.KillmailID, next.Name(), cont, err, time.Since(start))
+			return cont, err
+		})
+	}
+}
+
+// Tracing records a span for every stage, as a child of the kill's span in ctx
+func Tracing() Middleware {
+	return func(next Stage) Stage {
+		return NewStage(next.Name(), func(ctx context.Context, ev *Event) (bool, error) {
+			ctx, span := tracing.Start(ctx, "stage "+next.Name(), tracing.KindInternal)
+			defer span.End()
+			if id := KillID(ctx); id != 0 {
+				span.SetAttr("killmail.id", id)
+			}
+			cont, err := next.Process(ctx, ev)
+			span.SetAttr("continue", cont)
+			span.RecordError(err)
+			return cont, err
+		})
+	}
+}
+
+// StageStats are the counters kept for one stage
+type StageStats struct {
+	Processed int64
+	Dropped   int64
+	Errors    int64
+	TotalTime time.Duration
+}
+
+// Metrics counts events, drops, errors and time spent per stage
+type Metrics struct {
+	mu     sync.Mutex
+	stages map[string]*StageStats
+}
+
+// NewMetrics constructor
+func NewMetrics() *Metrics {
+	return &Metrics{stages: map[string]*StageStats{}}
+}
+
+// Middleware records every stage invocation
+func (m *Metrics) Middleware() Middleware {
+	return func(next Stage) Stage {
+		return NewStage(next.Name(), func(ctx context.Context, ev *Event) (bool, error) {
+			start := time.Now()
+			cont, err := next.Process(ctx, ev)
+			elapsed := time.Since(start)
+
+			m.mu.Lock()
+			st, ok := m.stages[next.Name()]
+			if !ok {
+				st = &StageStats{}
+				m.stages[next.Name()] = st
+			}
+			st.Processed++
+			st.TotalTime += elapsed
+			if err != nil {
+				st.Errors++
+			} else if !cont {
+				st.Dropped++
+			}
+			m.mu.Unlock()
+			return cont, err
+		})
+	}
+}
+
+// Snapshot copies the current counters
+func (m *Metrics) Snapshot() map[string]StageStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make(map[string]StageStats, len(m.stages))
+	for name, st := range m.stages {
+		out[name] = *st
+	}
+	return out
+}
+
+// Sample applies mw to only one in every n events, e.g. to keep debug logging
+// readable on the full killstream. n <= 1 applies it to every event.
+func Sample(n int, mw Middleware) Middleware {
+	if n <= 1 {
+		return mw
+	}
+	return func(next Stage) Stage {
+		sampled := mw(next)
+		return NewStage(next.Name(), func(ctx context.Context, ev *Event) (bool, error) {
+			if ev.seq%uint64(n) == 0 {
+				return sampled.Process(ctx, ev)
+			}
+			return next.Process(ctx, ev)
+		})
+	}
+}
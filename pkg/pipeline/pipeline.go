@@ -0,0 +1,233 @@
+// Package pipeline runs each killstream message through an ordered list of
+// stages (refresh → prefilter → decode → dedup → match → lane → enrich →
+// format → deliver). Stages are
+// small interfaces, so callers can insert their own and wrap every stage with
+// middleware such as logging, metrics or sampling.
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/guarzo/eve-chainkills/pkg/killmail"
+	"github.com/guarzo/eve-chainkills/pkg/notify"
+	"golang.org/x/exp/slices"
+)
+
+// Event carries one killstream message through the pipeline; each stage fills in more of it
+type Event struct {
+	Raw        []byte
+	ReceivedAt time.Time
+
+	// Set by the decode stage
+	Zkill killmail.ZkillMail
+
+	// Set by the match stage; Kind stays empty for unmatched kills
+	Kind      notify.Kind
+	Priority  Priority
+	IsKill    bool
+	BothSides bool
+	System    *killmail.SystemInfo
+	Location  string
+	Reason    notify.MatchReason
+	// Friendlies are the map characters in the kill's system, with friendlyDanger enabled
+	Friendlies []string
+	// CrossPostSystem is the chain system of a corp kill that is also posted as a chain alert
+	CrossPostSystem *killmail.SystemInfo
+
+	// Set by the enrich stage; Partial marks a kill whose enrichment ran out of
+	// time or failed, so some of Kill's names are IDs
+	Kill    killmail.FlattenedKillMail
+	Partial bool
+
+	// Set by the format stage
+	Notification notify.Notification
+	// CrossPost is the chain alert sent after Notification, without a mention
+	CrossPost *notify.Notification
+
+	// Deliveries records each sink the deliver stage sent to, with its error
+	Deliveries []Delivery
+	// Held marks an event the deliver stage held back to send later with others,
+	// so its deliveries are only known once the batch goes out
+	Held bool
+	// StoppedAt is set by Run to the stage that stopped the event, if any
+	StoppedAt string
+	// Trace and Notes are filled in when the Trace middleware runs
+	Trace []StageTrace
+	Notes []TraceNote
+
+	// Route limits delivery to these sink names when non-empty; SkipSinks excludes sinks
+	Route     []string
+	SkipSinks []string
+
+	// seq numbers events in arrival order, for sampling
+	seq uint64
+	// tracing is set by the Trace middleware, so Note records
+	tracing bool
+	// done runs when Run returns
+	done []func()
+}
+
+// OnDone registers fn to run once the event leaves the pipeline, e.g. to
+// free a slot a stage took
+func (ev *Event) OnDone(fn func()) {
+	ev.done = append(ev.done, fn)
+}
+
+// Delivery is the outcome of sending an event's notification to one sink
+type Delivery struct {
+	Sink string
+	Err  error
+	// Ref identifies the sent message when the sink can edit it later, e.g. a Discord message ID
+	Ref string
+	// URL links to the sent message, for sinks that know it
+	URL string
+}
+
+// WantsSink reports whether the event should be delivered to the named sink
+func (ev *Event) WantsSink(name string) bool {
+	if slices.Contains(ev.SkipSinks, name) {
+		return false
+	}
+	return len(ev.Route) == 0 || slices.Contains(ev.Route, name)
+}
+
+type killIDKey struct{}
+
+// WithKillID returns ctx carrying the killmail ID, which correlates the logs,
+// spans and outbound requests made while handling one kill
+func WithKillID(ctx context.Context, id int64) context.Context {
+	return context.WithValue(ctx, killIDKey{}, id)
+}
+
+// KillID returns the killmail ID in ctx, or 0
+func KillID(ctx context.Context) int64 {
+	id, _ := ctx.Value(killIDKey{}).(int64)
+	return id
+}
+
+// Stage processes an event. Returning false stops the event without error.
+type Stage interface {
+	Name() string
+	Process(ctx context.Context, ev *Event) (bool, error)
+}
+
+// StageFunc adapts a function to the Stage interface
+type StageFunc struct {
+	StageName string
+	Fn        func(ctx context.Context, ev *Event) (bool, error)
+}
+
+func (sf StageFunc) Name() string {
+	return sf.StageName
+}
+
+func (sf StageFunc) Process(ctx context.Context, ev *Event) (bool, error) {
+	return sf.Fn(ctx, ev)
+}
+
+// NewStage builds a named stage from a function
+func NewStage(name string, fn func(ctx context.Context, ev *Event) (bool, error)) Stage {
+	return StageFunc{StageName: name, Fn: fn}
+}
+
+// Middleware wraps a stage, e.g. to time or log it
+type Middleware func(next Stage) Stage
+
+// Source produces raw killstream messages, calling emit for each one until ctx is done
+type Source interface {
+	Run(ctx context.Context, emit func(raw []byte))
+}
+
+// Pipeline is an ordered list of stages plus the middleware applied to each
+type Pipeline struct {
+	stages     []Stage
+	middleware []Middleware
+	seq        atomic.Uint64
+}
+
+// New creates a pipeline from stages in order
+func New(stages ...Stage) *Pipeline {
+	return &Pipeline{stages: stages}
+}
+
+// Use adds middleware; the first added is the outermost wrapper
+func (p *Pipeline) Use(mw ...Middleware) {
+	p.middleware = append(p.middleware, mw...)
+}
+
+// InsertAfter places stage directly after the stage called name, or at the end if not found
+func (p *Pipeline) InsertAfter(name string, stage Stage) {
+	for i, s := range p.stages {
+		if s.Name() == name {
+			p.stages = append(p.stages[:i+1], append([]Stage{stage}, p.stages[i+1:]...)...)
+			return
+		}
+	}
+	p.stages = append(p.stages, stage)
+}
+
+// InsertBefore places stage directly before the stage called name, or at the start if not found
+func (p *Pipeline) InsertBefore(name string, stage Stage) {
+	for i, s := range p.stages {
+		if s.Name() == name {
+			p.stages = append(p.stages[:i], append([]Stage{stage}, p.stages[i:]...)...)
+			return
+		}
+	}
+	p.stages = append([]Stage{stage}, p.stages...)
+}
+
+// Stages returns the stage names in order
+func (p *Pipeline) Stages() []string {
+	names := make([]string, len(p.stages))
+	for i, s := range p.stages {
+		names[i] = s.Name()
+	}
+	return names
+}
+
+// StageError is returned by Run when a stage fails
+type StageError struct {
+	Stage string
+	Err   error
+}
+
+func (e *StageError) Error() string {
+	return fmt.Sprintf("stage %s: %v", e.Stage, e.Err)
+}
+
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+// Run passes the event through every stage until one stops it, fails, or ctx is done
+func (p *Pipeline) Run(ctx context.Context, ev *Event) error {
+	ev.seq = p.seq.Add(1)
+	defer func() {
+		for i := len(ev.done) - 1; i >= 0; i-- {
+			ev.done[i]()
+		}
+		ev.done = nil
+	}()
+	for _, s := range p.stages {
+		if err := ctx.Err(); err != nil {
+			return &StageError{Stage: s.Name(), Err: err}
+		}
+		wrapped := s
+		for i := len(p.middleware) - 1; i >= 0; i-- {
+			wrapped = p.middleware[i](wrapped)
+		}
+		cont, err := wrapped.Process(ctx, ev)
+		if err != nil {
+			return &StageError{Stage: s.Name(), Err: err}
+		}
+		if !cont {
+			ev.StoppedAt = s.Name()
+			return nil
+		}
+	}
+	return nil
+}
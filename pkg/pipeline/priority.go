@@ -0,0 +1,57 @@
+package pipeline
+
+import (
+	"context"
+
+	"github.com/guarzo/eve-chainkills/internal/lanes"
+)
+
+// StageLane is the stage that waits for a processing slot
+const StageLane = "lane"
+
+// Priority orders kills when the pipeline or a sink is busy; lower goes first
+type Priority int
+
+// Priority tiers, most urgent first
+const (
+	// PriorityHome is a kill in the home system
+	PriorityHome Priority = iota
+	// PriorityChain is a chain, danger or location alert, or a corp loss
+	PriorityChain
+	// PriorityCorp is a corp kill for the brag channel
+	PriorityCorp
+	// PriorityDigest is a kill held for an aggregated batch, and anything
+	// sent without a kill such as status messages and digests
+	PriorityDigest
+
+	// NumPriorities is the number of tiers
+	NumPriorities = int(PriorityDigest) + 1
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityHome:
+		return "home"
+	case PriorityChain:
+		return "chain"
+	case PriorityCorp:
+		return "corp"
+	case PriorityDigest:
+		return "digest"
+	}
+	return "unknown"
+}
+
+// Lane waits for one of gate's slots at the event's priority and holds it
+// until the event leaves the pipeline, so the stages after it run for the
+// most urgent kills first when many arrive at once
+func Lane(gate *lanes.Gate) Stage {
+	return NewStage(StageLane, func(ctx context.Context, ev *Event) (bool, error) {
+		release, err := gate.Acquire(ctx, int(ev.Priority))
+		if err != nil {
+			return false, err
+		}
+		ev.OnDone(release)
+		return true, nil
+	})
+}
@@ -0,0 +1,70 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/guarzo/eve-chainkills/internal/schema"
+)
+
+// Stage names used by the built-in pipeline
+const (
+	StagePrefilter = "prefilter"
+	StageDecode    = "decode"
+	StageDedup     = "dedup"
+	StageRefresh   = "refresh"
+	StageMatch     = "match"
+	StageEnrich    = "enrich"
+	StageFormat    = "format"
+	StageDeliver   = "deliver"
+)
+
+// Decode unmarshals ev.Raw into ev.Zkill
+func Decode() Stage {
+	return NewStage(StageDecode, func(ctx context.Context, ev *Event) (bool, error) {
+		if err := schema.Decode("zkill", ev.Raw, &ev.Zkill); err != nil {
+			return false, fmt.Errorf("unmarshal zKill message: %w", err)
+		}
+		if err := ev.Zkill.Validate(); err != nil {
+			return false, fmt.Errorf("invalid zKill message: %w", err)
+		}
+		return true, nil
+	})
+}
+
+// Dedup drops kills whose killmail ID was seen among the last size kills
+func Dedup(size int) Stage {
+	d := &dedup{
+		seen: make(map[int64]struct{}, size),
+		ring: make([]int64, size),
+	}
+	return NewStage(StageDedup, d.process)
+}
+
+type dedup struct {
+	mu   sync.Mutex
+	seen map[int64]struct{}
+	ring []int64
+	next int
+}
+
+func (d *dedup) process(ctx context.Context, ev *Event) (bool, error) {
+	id := ev.Zkill.KillmailID
+	if id == 0 || len(d.ring) == 0 {
+		return true, nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.seen[id]; ok {
+		return false, nil
+	}
+	if old := d.ring[d.next]; old != 0 {
+		delete(d.seen, old)
+	}
+	d.ring[d.next] = id
+	d.next = (d.next + 1) % len(d.ring)
+	d.seen[id] = struct{}{}
+	return true, nil
+}
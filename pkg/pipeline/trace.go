@@ -0,0 +1,95 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// StageTrace is one stage's outcome, recorded by the Trace middleware
+type StageTrace struct {
+	Stage    string
+	Continue bool
+	Err      error
+	Took     time.Duration
+}
+
+// TraceNote is a decision a stage noted for the trace, e.g. rule=chain_system
+type TraceNote struct {
+	Key   string
+	Value string
+}
+
+// Trace records every stage's outcome and duration on the event, so the
+// whole kill can be logged as one line with TraceLine once Run returns
+func Trace() Middleware {
+	return func(next Stage) Stage {
+		return NewStage(next.Name(), func(ctx context.Context, ev *Event) (bool, error) {
+			ev.tracing = true
+			start := time.Now()
+			cont, err := next.Process(ctx, ev)
+			ev.Trace = append(ev.Trace, StageTrace{Stage: next.Name(), Continue: cont, Err: err, Took: time.Since(start)})
+			return cont, err
+		})
+	}
+}
+
+// Note records a decision for the trace line; it does nothing unless the
+// event is traced
+func (ev *Event) Note(key, value string) {
+	if ev.tracing {
+		ev.Notes = append(ev.Notes, TraceNote{Key: key, Value: value})
+	}
+}
+
+// Traced reports whether the Trace middleware ran on the event
+func (ev *Event) Traced() bool {
+	return ev.tracing
+}
+
+// TraceLine sums up the traced event in logfmt, e.g.
+// "prefilter=ok/3µs decode=ok/41µs ... match=stop/12µs reason=\"...\" sinks=discord:ok total=180ms"
+func (ev *Event) TraceLine() string {
+	var b strings.Builder
+	var total time.Duration
+	for _, st := range ev.Trace {
+		outcome := "ok"
+		switch {
+		case st.Err != nil:
+			outcome = "error"
+		case !st.Continue:
+			outcome = "stop"
+		}
+		fmt.Fprintf(&b, "%s=%s/%s ", st.Stage, outcome, st.Took.Round(time.Microsecond))
+		total += st.Took
+	}
+	for _, n := range ev.Notes {
+		fmt.Fprintf(&b, "%s=%s ", n.Key, logfmtValue(n.Value))
+	}
+	if len(ev.Deliveries) > 0 {
+		sinks := make([]string, len(ev.Deliveries))
+		for i, d := range ev.Deliveries {
+			sinks[i] = d.Sink + ":ok"
+			if d.Err != nil {
+				sinks[i] = d.Sink + ":error"
+			}
+		}
+		fmt.Fprintf(&b, "sinks=%s ", strings.Join(sinks, ","))
+	}
+	for _, st := range ev.Trace {
+		if st.Err != nil {
+			fmt.Fprintf(&b, "err=%s ", logfmtValue(st.Err.Error()))
+		}
+	}
+	fmt.Fprintf(&b, "total=%s", total.Round(time.Microsecond))
+	return b.String()
+}
+
+// logfmtValue quotes a value with spaces, quotes or an equals sign
+func logfmtValue(s string) string {
+	if s == "" || strings.ContainsAny(s, " \"=") {
+		return fmt.Sprintf("%q", s)
+	}
+	return s
+}